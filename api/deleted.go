@@ -0,0 +1,52 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"time"
+
+	"github.com/google/cadvisor/events"
+	"github.com/google/cadvisor/manager"
+)
+
+// Path of the deleted containers in the API, followed by their name.
+const deletedContainersPath = "/api/v2.0/deleted"
+
+// Links the events of the containers deleted since to their tombstone. The
+// events linked are copies, the others are shared with the event store.
+func linkTombstones(m manager.Manager, evs events.EventSlice) (events.EventSlice, error) {
+	deleted, err := m.GetDeletedContainers()
+	if err != nil {
+		return nil, err
+	}
+	if len(deleted) == 0 {
+		return evs, nil
+	}
+	deletionTimes := make(map[string]time.Time, len(deleted))
+	for _, d := range deleted {
+		deletionTimes[d.Name] = d.DeletionTime
+	}
+	ret := make(events.EventSlice, 0, len(evs))
+	for _, ev := range evs {
+		// Events after the deletion are of a new container with the name.
+		if deletionTime, ok := deletionTimes[ev.ContainerName]; ok && !ev.Timestamp.After(deletionTime) {
+			linked := *ev
+			linked.Tombstone = deletedContainersPath + ev.ContainerName
+			ev = &linked
+		}
+		ret = append(ret, ev)
+	}
+	return ret, nil
+}
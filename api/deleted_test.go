@@ -0,0 +1,49 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/events"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/google/cadvisor/manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkTombstones(t *testing.T) {
+	deletion := time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+	m := &manager.ManagerMock{}
+	m.On("GetDeletedContainers").Return([]v2.DeletedContainer{{Name: "/docker/web", DeletionTime: deletion}}, nil)
+	evs := events.EventSlice{
+		{ContainerName: "/docker/web", Timestamp: deletion.Add(-time.Minute), EventType: events.TypeOom},
+		{ContainerName: "/docker/web", Timestamp: deletion, EventType: events.TypeContainerDeletion},
+		{ContainerName: "/docker/web", Timestamp: deletion.Add(time.Minute), EventType: events.TypeContainerCreation},
+		{ContainerName: "/docker/db", Timestamp: deletion.Add(-time.Minute), EventType: events.TypeOom},
+	}
+
+	linked, err := linkTombstones(m, evs)
+	require.Nil(t, err)
+	require.Equal(t, len(evs), len(linked))
+	assert.Equal(t, "/api/v2.0/deleted/docker/web", linked[0].Tombstone)
+	assert.Equal(t, "/api/v2.0/deleted/docker/web", linked[1].Tombstone)
+	assert.Equal(t, "", linked[2].Tombstone)
+	assert.Equal(t, "", linked[3].Tombstone)
+
+	// The events of the store are left as they were.
+	assert.Equal(t, "", evs[0].Tombstone)
+}
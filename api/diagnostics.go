@@ -0,0 +1,187 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/google/cadvisor/manager"
+)
+
+const (
+	// Age of the oldest stats in a diagnostic bundle.
+	diagnosticStatsDuration = 5 * time.Minute
+
+	// Maximum numbers of stats and events in a diagnostic bundle, the most
+	// recent ones are kept.
+	maxDiagnosticStats  = 600
+	maxDiagnosticEvents = 100
+)
+
+// Gathers the diagnostic bundle of the container. Only a missing container
+// fails it, sections which cannot be gathered are reported in its errors.
+func getDiagnosticBundle(m manager.Manager, containerName string) (*v2.DiagnosticBundle, error) {
+	spec, err := m.GetContainerSpec(containerName)
+	if err != nil {
+		return nil, err
+	}
+	diagnostics, err := m.GetContainerDiagnostics(containerName)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	bundle := &v2.DiagnosticBundle{
+		Name:                 containerName,
+		Timestamp:            now,
+		Spec:                 spec,
+		Stats:                []*info.ContainerStats{},
+		Events:               []*events.Event{},
+		ContainerDiagnostics: diagnostics,
+	}
+	addError := func(section string, err error) {
+		if bundle.Errors == nil {
+			bundle.Errors = make(map[string]string)
+		}
+		bundle.Errors[section] = err.Error()
+	}
+
+	// One more than the maximum is requested to tell whether some were left out.
+	cinfo, err := m.GetContainerInfo(containerName, &info.ContainerInfoRequest{
+		NumStats: maxDiagnosticStats + 1,
+		Start:    now.Add(-diagnosticStatsDuration),
+	})
+	if err != nil {
+		addError("stats", err)
+	} else {
+		bundle.Stats = cinfo.Stats
+		if len(bundle.Stats) > maxDiagnosticStats {
+			bundle.Stats = bundle.Stats[len(bundle.Stats)-maxDiagnosticStats:]
+			bundle.Truncated = append(bundle.Truncated, "stats")
+		}
+	}
+
+	request := events.NewRequest()
+	request.ContainerName = containerName
+	request.MaxEventsReturned = maxDiagnosticEvents + 1
+	for _, eventType := range events.AllTypes() {
+		request.EventType[eventType] = true
+	}
+	evs, err := m.GetPastEvents(request)
+	if err != nil {
+		addError("events", err)
+	} else {
+		bundle.Events = evs
+		if len(bundle.Events) > maxDiagnosticEvents {
+			bundle.Events = bundle.Events[len(bundle.Events)-maxDiagnosticEvents:]
+			bundle.Truncated = append(bundle.Truncated, "events")
+		}
+	}
+
+	bundle.CollectionStatus, err = m.GetContainerCollectionStatus(containerName)
+	if err != nil {
+		addError("collection_status", err)
+	}
+	sort.Strings(bundle.Truncated)
+	return bundle, nil
+}
+
+// What a diagnostic bundle archive holds besides its sections.
+type diagnosticManifest struct {
+	Name      string            `json:"name"`
+	Timestamp time.Time         `json:"timestamp"`
+	Truncated []string          `json:"truncated,omitempty"`
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+// Writes the bundle as a tar archive holding each section as a JSON file and
+// the cgroup files as they were read:
+//
+// manifest.json
+// spec.json
+// stats.json
+// events.json
+// collection_status.json
+// processes.json
+// cgroup/<subsystem>/<file>
+func writeDiagnosticTar(bundle *v2.DiagnosticBundle, w http.ResponseWriter) error {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	addFile := func(name string, contents []byte) error {
+		err := tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(contents)),
+			ModTime: bundle.Timestamp,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(contents)
+		return err
+	}
+	sections := []struct {
+		name  string
+		value interface{}
+	}{
+		{"manifest.json", diagnosticManifest{
+			Name:      bundle.Name,
+			Timestamp: bundle.Timestamp,
+			Truncated: bundle.Truncated,
+			Errors:    bundle.Errors,
+		}},
+		{"spec.json", bundle.Spec},
+		{"stats.json", bundle.Stats},
+		{"events.json", bundle.Events},
+		{"collection_status.json", bundle.CollectionStatus},
+		{"processes.json", bundle.Processes},
+	}
+	for _, section := range sections {
+		out, err := json.MarshalIndent(section.value, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal the %s of the diagnostic bundle: %v", section.name, err)
+		}
+		if err := addFile(section.name, out); err != nil {
+			return fmt.Errorf("failed to archive the diagnostic bundle: %v", err)
+		}
+	}
+	files := make([]string, 0, len(bundle.CgroupFiles))
+	for file := range bundle.CgroupFiles {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		if err := addFile(path.Join("cgroup", file), []byte(bundle.CgroupFiles[file])); err != nil {
+			return fmt.Errorf("failed to archive the diagnostic bundle: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to archive the diagnostic bundle: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", `attachment; filename="cadvisor-diagnostics.tar"`)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,184 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/google/cadvisor/manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// The diagnostics of the "/docker/web" container.
+var testDiagnostics = v2.ContainerDiagnostics{
+	Processes: []v2.ProcessInfo{{Pid: 42, Name: "nginx", State: "S"}},
+	CgroupFiles: map[string]string{
+		"memory/memory.stat":   "cache 0\nrss 4096\n",
+		"cpu/cpu.cfs_quota_us": "-1\n",
+	},
+	Truncated: []string{"processes"},
+}
+
+// Returns a manager serving the diagnostics of the "/docker/web" container
+// with the specified stats. Other containers are unknown.
+func newDiagnosticsManager(stats []*info.ContainerStats) *manager.ManagerMock {
+	const name = "/docker/web"
+	unknown := fmt.Errorf("unknown container")
+	m := &manager.ManagerMock{}
+	m.On("GetContainerSpec", name).Return(v2.ContainerSpec{HasCpu: true, Cpu: v2.CpuSpec{Limit: 512}}, nil)
+	m.On("GetContainerSpec", mock.Anything).Return(v2.ContainerSpec{}, unknown)
+	m.On("GetContainerDiagnostics", name).Return(testDiagnostics, nil)
+	m.On("GetContainerDiagnostics", mock.Anything).Return(v2.ContainerDiagnostics{}, unknown)
+	m.On("GetContainerInfo", name, mock.Anything).Return(&info.ContainerInfo{Stats: stats}, nil)
+	m.On("GetContainerInfo", mock.Anything, mock.Anything).Return((*info.ContainerInfo)(nil), unknown)
+	m.On("GetContainerCollectionStatus", name).Return(v2.CollectionStatus{State: "running", TotalOverruns: 3}, nil)
+	m.On("GetContainerCollectionStatus", mock.Anything).Return(v2.CollectionStatus{}, unknown)
+	m.On("GetPastEvents", mock.Anything).Return(events.EventSlice{{
+		ContainerName: name,
+		Timestamp:     time.Now(),
+		EventType:     events.TypeOom,
+	}}, nil)
+	return m
+}
+
+// Returns stats of the last minute, one millisecond apart.
+func makeDiagnosticStats(numStats int) []*info.ContainerStats {
+	var stats []*info.ContainerStats
+	start := time.Now().Add(-time.Minute)
+	for i := 0; i < numStats; i++ {
+		stats = append(stats, &info.ContainerStats{Timestamp: start.Add(time.Duration(i) * time.Millisecond)})
+	}
+	return stats
+}
+
+// Returns the argument at the index of the first call to the method of the mock.
+func getCallArgument(m *manager.ManagerMock, method string, index int) interface{} {
+	for _, call := range m.Calls {
+		if call.Method == method {
+			return call.Arguments.Get(index)
+		}
+	}
+	return nil
+}
+
+func TestDiagnosticBundle(t *testing.T) {
+	stats := makeDiagnosticStats(maxDiagnosticStats + 10)
+	m := newDiagnosticsManager(stats)
+	mux := http.NewServeMux()
+	require.Nil(t, RegisterHandlers(mux, m))
+
+	w := serveRequest(mux, "GET", "http://localhost:8080/api/v2.0/debug/container/docker/web")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var bundle v2.DiagnosticBundle
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &bundle))
+
+	assert.Equal(t, "/docker/web", bundle.Name)
+	assert.False(t, bundle.Timestamp.IsZero())
+	assert.Equal(t, uint64(512), bundle.Spec.Cpu.Limit)
+	// The most recent stats are kept.
+	require.Equal(t, maxDiagnosticStats, len(bundle.Stats))
+	assert.True(t, bundle.Stats[len(bundle.Stats)-1].Timestamp.Equal(stats[len(stats)-1].Timestamp))
+	require.Equal(t, 1, len(bundle.Events))
+	assert.Equal(t, events.TypeOom, bundle.Events[0].EventType)
+	assert.Equal(t, "running", bundle.CollectionStatus.State)
+	assert.Equal(t, testDiagnostics.Processes, bundle.Processes)
+	assert.Equal(t, testDiagnostics.CgroupFiles, bundle.CgroupFiles)
+	assert.Equal(t, []string{"processes", "stats"}, bundle.Truncated)
+	assert.Empty(t, bundle.Errors)
+
+	// Only the stats of the last minutes and the events of the container, of any type.
+	statsQuery := getCallArgument(m, "GetContainerInfo", 1).(*info.ContainerInfoRequest)
+	assert.WithinDuration(t, time.Now().Add(-diagnosticStatsDuration), statsQuery.Start, time.Minute)
+	eventsRequest := getCallArgument(m, "GetPastEvents", 0).(*events.Request)
+	assert.Equal(t, "/docker/web", eventsRequest.ContainerName)
+	assert.False(t, eventsRequest.IncludeSubcontainers)
+	for _, eventType := range events.AllTypes() {
+		assert.True(t, eventsRequest.EventType[eventType], "event type %v", eventType)
+	}
+}
+
+func TestDiagnosticBundleTar(t *testing.T) {
+	mux := http.NewServeMux()
+	require.Nil(t, RegisterHandlers(mux, newDiagnosticsManager(makeDiagnosticStats(2))))
+
+	w := serveRequest(mux, "GET", "http://localhost:8080/api/v2.0/debug/container/docker/web?format=tar")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/x-tar", w.Header().Get("Content-Type"))
+
+	files := make(map[string][]byte)
+	var names []string
+	tr := tar.NewReader(bytes.NewReader(w.Body.Bytes()))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.Nil(t, err)
+		contents, err := ioutil.ReadAll(tr)
+		require.Nil(t, err)
+		names = append(names, header.Name)
+		files[header.Name] = contents
+	}
+	assert.Equal(t, []string{
+		"manifest.json",
+		"spec.json",
+		"stats.json",
+		"events.json",
+		"collection_status.json",
+		"processes.json",
+		"cgroup/cpu/cpu.cfs_quota_us",
+		"cgroup/memory/memory.stat",
+	}, names)
+
+	var manifest diagnosticManifest
+	require.Nil(t, json.Unmarshal(files["manifest.json"], &manifest))
+	assert.Equal(t, "/docker/web", manifest.Name)
+	assert.Equal(t, []string{"processes"}, manifest.Truncated)
+	var stats []*info.ContainerStats
+	require.Nil(t, json.Unmarshal(files["stats.json"], &stats))
+	assert.Equal(t, 2, len(stats))
+	var processes []v2.ProcessInfo
+	require.Nil(t, json.Unmarshal(files["processes.json"], &processes))
+	assert.Equal(t, testDiagnostics.Processes, processes)
+	// Cgroup files are archived as they were read.
+	assert.Equal(t, "cache 0\nrss 4096\n", string(files["cgroup/memory/memory.stat"]))
+}
+
+func TestDiagnosticBundleErrors(t *testing.T) {
+	mux := http.NewServeMux()
+	require.Nil(t, RegisterHandlers(mux, newDiagnosticsManager(makeDiagnosticStats(1))))
+	for url, expected := range map[string]int{
+		"/api/v2.0/debug/container/docker/db":             http.StatusInternalServerError,
+		"/api/v2.0/debug/container/docker/web?format=zip": http.StatusBadRequest,
+		"/api/v2.0/debug/containers/docker/web":           http.StatusBadRequest,
+		"/api/v2.0/debug/":                                http.StatusBadRequest,
+	} {
+		assert.Equal(t, expected, serveRequest(mux, "GET", "http://localhost:8080"+url).Code, url)
+	}
+}
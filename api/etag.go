@@ -0,0 +1,68 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+)
+
+// Writes the result as JSON like writeResult(), along with a weak ETag
+// computed from a hash of the JSON. Responds 304 without a body if the request
+// has an If-None-Match header matching it, so that pollers only detecting
+// changes need not download it again. Only for results which rarely change
+// (e.g.: specs), stats change every time.
+func writeResultWithETag(res interface{}, w http.ResponseWriter, r *http.Request) error {
+	out, err := json.Marshal(res)
+	if err != nil {
+		return fmt.Errorf("failed to marshall response %+v with error: %s", res, err)
+	}
+
+	etag := computeETag(out)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(out)
+	return nil
+}
+
+// Returns the weak ETag of the specified serialized result.
+func computeETag(out []byte) string {
+	h := fnv.New64a()
+	h.Write(out)
+	return fmt.Sprintf("W/\"%016x\"", h.Sum64())
+}
+
+// Whether the If-None-Match header (a list of ETags or "*") matches the
+// specified ETag. Uses the weak comparison, the W/ prefixes are ignored.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/google/cadvisor/manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Returns a manager serving the machine and the spec of the "/docker" container.
+func newSpecManager(machine info.MachineInfo, spec v2.ContainerSpec) *manager.ManagerMock {
+	m := &manager.ManagerMock{}
+	m.On("GetMachineInfo").Return(&machine, nil)
+	m.On("GetContainerSpec", "/docker").Return(spec, nil)
+	return m
+}
+
+var (
+	testMachine = info.MachineInfo{NumCores: 4, MemoryCapacity: 1024}
+	testSpec    = v2.ContainerSpec{HasCpu: true, Cpu: v2.CpuSpec{Limit: 1024}}
+)
+
+// Makes a request to the specified endpoint of the API, with the specified If-None-Match header if any.
+func requestWithETag(t *testing.T, api ApiVersion, requestType string, m manager.Manager, ifNoneMatch string) *httptest.ResponseRecorder {
+	r := makeHTTPRequest("http://localhost:8080/api/"+api.Version()+"/"+requestType+"/docker", t)
+	if ifNoneMatch != "" {
+		r.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	w := httptest.NewRecorder()
+	require.Nil(t, api.HandleRequest(requestType, []string{"docker"}, m, w, r))
+	return w
+}
+
+func TestSpecNotModified(t *testing.T) {
+	m := newSpecManager(testMachine, testSpec)
+	api := &version2_0{}
+	w := requestWithETag(t, api, specApi, m, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	var spec v2.ContainerSpec
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &spec))
+	assert.Equal(t, testSpec, spec)
+
+	// The ETag is stable across requests.
+	w = requestWithETag(t, api, specApi, m, "")
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+
+	w = requestWithETag(t, api, specApi, m, etag)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+	assert.Equal(t, 0, w.Body.Len())
+
+	// Any of a list of ETags, strong or weak, matches.
+	w = requestWithETag(t, api, specApi, m, `"other", `+etag[len("W/"):])
+	assert.Equal(t, http.StatusNotModified, w.Code)
+}
+
+func TestSpecChangeInvalidatesETag(t *testing.T) {
+	api := &version2_0{}
+	etag := requestWithETag(t, api, specApi, newSpecManager(testMachine, testSpec), "").Header().Get("ETag")
+
+	spec := testSpec
+	spec.Cpu.Limit = 2048
+	w := requestWithETag(t, api, specApi, newSpecManager(testMachine, spec), etag)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	var served v2.ContainerSpec
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &served))
+	assert.Equal(t, uint64(2048), served.Cpu.Limit)
+}
+
+func TestMachineNotModified(t *testing.T) {
+	m := newSpecManager(testMachine, testSpec)
+	machine := testMachine
+	machine.NumCores++
+	changed := newSpecManager(machine, testSpec)
+	for _, api := range []ApiVersion{&version1_0{}, &version2_0{}} {
+		etag := requestWithETag(t, api, machineApi, m, "").Header().Get("ETag")
+		require.NotEmpty(t, etag, api.Version())
+		w := requestWithETag(t, api, machineApi, m, etag)
+		assert.Equal(t, http.StatusNotModified, w.Code, api.Version())
+
+		w = requestWithETag(t, api, machineApi, changed, etag)
+		assert.Equal(t, http.StatusOK, w.Code, api.Version())
+	}
+}
+
+func TestEtagMatches(t *testing.T) {
+	etag := `W/"0123"`
+	assert.False(t, etagMatches("", etag))
+	assert.False(t, etagMatches(`W/"4567"`, etag))
+	assert.True(t, etagMatches(`W/"0123"`, etag))
+	assert.True(t, etagMatches(`"0123"`, etag))
+	assert.True(t, etagMatches(`"4567" , W/"0123"`, etag))
+	assert.True(t, etagMatches("*", etag))
+}
@@ -0,0 +1,146 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	httpMux "github.com/google/cadvisor/http/mux"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/manager"
+)
+
+// Name of the expvar map of the latest stats of the containers.
+const containersExpvar = "cadvisor.containers"
+
+// Path of the expvar variables, that of Go's expvar package.
+const varsPath = "/debug/vars"
+
+// Longest time the expvar map is served from its cache.
+const expvarTtl = 2 * time.Second
+
+// The latest stats of a container in the expvar map.
+type expvarStats struct {
+	CpuTotal              uint64    `json:"cpu_total_ns"`
+	MemoryWorkingSetBytes uint64    `json:"memory_working_set_bytes"`
+	RxBytes               uint64    `json:"rx_bytes"`
+	TxBytes               uint64    `json:"tx_bytes"`
+	Timestamp             time.Time `json:"timestamp"`
+}
+
+// A variable in the format of Go's expvar package whose value is a JSON object of the latest stats of the
+// containers by name. It is read from the stats cached in memory when
+// requested, at most once per TTL, so that concurrent scrapes do not each
+// read all the containers.
+type containersVar struct {
+	m     manager.Manager
+	limit int
+	ttl   time.Duration
+	now   func() time.Time
+
+	lock    sync.Mutex
+	value   string
+	expires time.Time
+}
+
+func newContainersVar(m manager.Manager, limit int, ttl time.Duration) *containersVar {
+	return &containersVar{
+		m:     m,
+		limit: limit,
+		ttl:   ttl,
+		now:   time.Now,
+	}
+}
+
+// Returns the cached JSON object, read again if older than the TTL. Scrapes
+// arriving during a read wait for it.
+func (self *containersVar) String() string {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	now := self.now()
+	if self.value == "" || !now.Before(self.expires) {
+		self.value = self.read()
+		self.expires = now.Add(self.ttl)
+	}
+	return self.value
+}
+
+func (self *containersVar) read() string {
+	page, err := getSubcontainersPage(self.m, "/", &info.ContainerInfoRequest{NumStats: 1}, nil, &pageRequest{Limit: self.limit})
+	if err != nil {
+		glog.Warningf("Failed to list the containers of the %s expvar map: %v", containersExpvar, err)
+		return "{}"
+	}
+	containers := make(map[string]expvarStats, len(page.Containers))
+	for _, cont := range page.Containers {
+		if len(cont.Stats) == 0 {
+			continue
+		}
+		stats := cont.Stats[len(cont.Stats)-1]
+		containers[cont.Name] = expvarStats{
+			CpuTotal:              stats.Cpu.Usage.Total,
+			MemoryWorkingSetBytes: stats.Memory.WorkingSet,
+			RxBytes:               stats.Network.RxBytes,
+			TxBytes:               stats.Network.TxBytes,
+			Timestamp:             stats.Timestamp,
+		}
+	}
+	out, err := json.Marshal(containers)
+	if err != nil {
+		glog.Warningf("Failed to marshal the %s expvar map: %v", containersExpvar, err)
+		return "{}"
+	}
+	return string(out)
+}
+
+// Serves the variables of cAdvisor in the format of Go's expvar package: the
+// command line, the memory statistics of the runtime and the latest stats of
+// the containers. Go's expvar package is not used, as importing it serves its
+// variables on http.DefaultServeMux.
+type varsHandler struct {
+	containers *containersVar
+}
+
+func (self *varsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cmdline, err := json.Marshal(os.Args)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	memstats, err := json.Marshal(memStats)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintf(w, "{\n%q: %s,\n%q: %s,\n%q: %s\n}\n", "cmdline", cmdline, "memstats", memstats, containersExpvar, self.containers.String())
+}
+
+// Serves the latest stats of the containers of the manager at /debug/vars.
+// This is a debug endpoint (--enable_debug_endpoints).
+func RegisterVars(mux httpMux.Mux, m manager.Manager) {
+	mux.Handle(varsPath, httpMux.SafeReads(&varsHandler{
+		containers: newContainersVar(m, *defaultPageLimit, expvarTtl),
+	}))
+}
@@ -0,0 +1,138 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var expvarTimestamp = time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// Returns a manager listing the specified containers as the subcontainers of
+// "/", each with a single stats.
+func newExpvarManager(names []string) *manager.ManagerMock {
+	m := &manager.ManagerMock{}
+	m.On("SubcontainerNames", "/", (*manager.ContainerFilter)(nil)).Return(names, nil)
+	expectExpvarContainers(m, names)
+	return m
+}
+
+// Serves the specified containers from the manager, each with a single stats.
+func expectExpvarContainers(m *manager.ManagerMock, names []string) {
+	for _, name := range names {
+		stats := &info.ContainerStats{
+			Timestamp: expvarTimestamp,
+		}
+		stats.Cpu.Usage.Total = 1000
+		stats.Memory.WorkingSet = 2048
+		stats.Network.RxBytes = 10
+		stats.Network.TxBytes = 20
+		m.On("GetContainerInfo", name, mock.Anything).Return(&info.ContainerInfo{
+			ContainerReference: info.ContainerReference{
+				Name: name,
+			},
+			Stats: []*info.ContainerStats{stats},
+		}, nil)
+	}
+}
+
+func TestContainersExpvar(t *testing.T) {
+	m := newExpvarManager(listNames(2))
+	mux := http.NewServeMux()
+	RegisterVars(mux, m)
+
+	w := serveRequest(mux, "GET", "http://localhost:8080/debug/vars")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var vars map[string]json.RawMessage
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &vars))
+	for _, name := range []string{"cmdline", "memstats"} {
+		_, ok := vars[name]
+		assert.True(t, ok, "expected the %s variable of Go's expvar package", name)
+	}
+	var containers map[string]map[string]interface{}
+	require.Nil(t, json.Unmarshal(vars[containersExpvar], &containers))
+	require.Equal(t, 3, len(containers))
+	assert.Equal(t, map[string]interface{}{
+		"cpu_total_ns":             1000.0,
+		"memory_working_set_bytes": 2048.0,
+		"rx_bytes":                 10.0,
+		"tx_bytes":                 20.0,
+		"timestamp":                expvarTimestamp.Format(time.RFC3339),
+	}, containers["/docker/00001"])
+}
+
+func TestContainersExpvarIsCached(t *testing.T) {
+	// Containers created after the first listing are listed by the next.
+	m := &manager.ManagerMock{}
+	names := listNames(2)
+	m.On("SubcontainerNames", "/", (*manager.ContainerFilter)(nil)).Return(names, nil).Once()
+	m.On("SubcontainerNames", "/", (*manager.ContainerFilter)(nil)).Return(append(names, "/docker/new"), nil)
+	expectExpvarContainers(m, append(names, "/docker/new"))
+	now := expvarTimestamp
+	v := newContainersVar(m, 100, time.Second)
+	v.now = func() time.Time {
+		return now
+	}
+
+	var wg sync.WaitGroup
+	values := make([]string, 20)
+	for i := range values {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			values[i] = v.String()
+		}(i)
+	}
+	wg.Wait()
+	// A single refresh of the concurrent scrapes.
+	m.AssertNumberOfCalls(t, "SubcontainerNames", 1)
+	for i := range values {
+		assert.Equal(t, values[0], values[i])
+	}
+
+	now = now.Add(999 * time.Millisecond)
+	assert.Equal(t, values[0], v.String())
+	m.AssertNumberOfCalls(t, "SubcontainerNames", 1)
+
+	// Containers created since are published once the TTL elapsed.
+	now = now.Add(time.Millisecond)
+	var containers map[string]expvarStats
+	require.Nil(t, json.Unmarshal([]byte(v.String()), &containers))
+	m.AssertNumberOfCalls(t, "SubcontainerNames", 2)
+	assert.Equal(t, 4, len(containers))
+}
+
+func TestContainersExpvarIsBounded(t *testing.T) {
+	m := newExpvarManager(listNames(10))
+	var containers map[string]expvarStats
+	require.Nil(t, json.Unmarshal([]byte(newContainersVar(m, 5, time.Second).String()), &containers))
+	require.Equal(t, 5, len(containers))
+	for i := 0; i < 4; i++ {
+		_, ok := containers[fmt.Sprintf("/docker/%05d", i)]
+		assert.True(t, ok, "expected the first containers by name")
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/manager"
+)
+
+// Returns the filter requested through the "name_re", "alias_re" and "label"
+// options, or nil if the request is not filtered. Labels are specified as
+// key=value and may be repeated, a container must have all of them.
+func getContainerFilter(r *http.Request) (*manager.ContainerFilter, error) {
+	values := r.URL.Query()
+	nameRe := values.Get("name_re")
+	aliasRe := values.Get("alias_re")
+	labels := values["label"]
+	if len(nameRe) == 0 && len(aliasRe) == 0 && len(labels) == 0 {
+		return nil, nil
+	}
+
+	filter := &manager.ContainerFilter{}
+	var err error
+	if len(nameRe) != 0 {
+		filter.Name, err = regexp.Compile(nameRe)
+		if err != nil {
+			return nil, badRequestError{fmt.Errorf("invalid 'name_re' option: %v", err)}
+		}
+	}
+	if len(aliasRe) != 0 {
+		filter.Alias, err = regexp.Compile(aliasRe)
+		if err != nil {
+			return nil, badRequestError{fmt.Errorf("invalid 'alias_re' option: %v", err)}
+		}
+	}
+	if len(labels) != 0 {
+		filter.Labels = make(map[string]string, len(labels))
+		for _, label := range labels {
+			kv := strings.SplitN(label, "=", 2)
+			if len(kv) != 2 || len(kv[0]) == 0 {
+				return nil, badRequestError{fmt.Errorf("malformed 'label' option %q, expected key=value", label)}
+			}
+			filter.Labels[kv[0]] = kv[1]
+		}
+	}
+	return filter, nil
+}
+
+// Returns the subcontainers of the specified container (inclusive) selected
+// by the filter. Only the selected containers are read from the manager.
+// Containers removed since they were selected are skipped.
+func getFilteredSubcontainers(m manager.Manager, containerName string, query *info.ContainerInfoRequest, filter *manager.ContainerFilter) ([]info.ContainerInfo, error) {
+	names, err := m.SubcontainerNames(containerName, filter)
+	if err != nil {
+		return nil, err
+	}
+	return getContainers(m, names, query), nil
+}
+
+// Returns the containers with the specified names, skipping those which no longer exist.
+func getContainers(m manager.Manager, names []string, query *info.ContainerInfoRequest) []info.ContainerInfo {
+	ret := make([]info.ContainerInfo, 0, len(names))
+	for _, name := range names {
+		cont, err := m.GetContainerInfo(name, query)
+		if err != nil {
+			continue
+		}
+		ret = append(ret, *cont)
+	}
+	return ret
+}
+
+// Returns the containers of the namespace selected by the filter by full name.
+func getFilteredNamespacedContainers(m manager.Manager, namespace string, query *info.ContainerInfoRequest, filter *manager.ContainerFilter) (map[string]info.ContainerInfo, error) {
+	namespacedFilter := *filter
+	namespacedFilter.Namespace = namespace
+	names, err := m.SubcontainerNames("/", &namespacedFilter)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]info.ContainerInfo, len(names))
+	for _, cont := range getContainers(m, names, query) {
+		ret[cont.Name] = cont
+	}
+	return ret, nil
+}
@@ -0,0 +1,223 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/google/cadvisor/manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// Containers with only a reference and a spec, by name. The aliased
+// containers are in the Docker namespace.
+func newLabeledContainers() map[string]*info.ContainerInfo {
+	containers := make(map[string]*info.ContainerInfo)
+	addContainer(containers, "/", "", "", nil)
+	addContainer(containers, "/system", "", "", nil)
+	addContainer(containers, "/docker/1", "docker", "web-1", map[string]string{"env": "prod", "tier": "frontend"})
+	addContainer(containers, "/docker/2", "docker", "web-2", map[string]string{"env": "dev", "tier": "frontend"})
+	addContainer(containers, "/docker/3", "docker", "db", map[string]string{"env": "prod", "tier": "backend"})
+	addContainer(containers, "/docker/4", "docker", "web-unlabeled", nil)
+	return containers
+}
+
+func addContainer(containers map[string]*info.ContainerInfo, name, namespace, alias string, labels map[string]string) {
+	cont := &info.ContainerInfo{
+		ContainerReference: info.ContainerReference{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: info.ContainerSpec{
+			Labels: labels,
+		},
+	}
+	if alias != "" {
+		cont.Aliases = []string{alias}
+	}
+	containers[name] = cont
+}
+
+// Returns a manager serving the containers in the namespaces. The
+// subcontainers of "/" are listed for each of the filters.
+func newContainersManager(containers map[string]*info.ContainerInfo, namespaces []v2.Namespace, filters ...*manager.ContainerFilter) *manager.ManagerMock {
+	names := make([]string, 0, len(containers))
+	for name := range containers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	m := &manager.ManagerMock{}
+	all := make([]*info.ContainerInfo, 0, len(names))
+	for _, name := range names {
+		cont := containers[name]
+		all = append(all, cont)
+		m.On("GetContainerInfo", name, mock.Anything).Return(cont, nil)
+		for _, alias := range cont.Aliases {
+			m.On("NamespacedContainer", cont.Namespace, alias, mock.Anything).Return(*cont, nil)
+		}
+	}
+	m.On("NamespacedContainer", mock.Anything, mock.Anything, mock.Anything).Return(info.ContainerInfo{}, fmt.Errorf("unknown container"))
+	m.On("SubcontainersInfo", "/", mock.Anything).Return(all, nil)
+
+	counted := append([]v2.Namespace{}, namespaces...)
+	for i := range counted {
+		namespaced := make(map[string]info.ContainerInfo)
+		for _, cont := range containers {
+			if cont.Namespace == counted[i].Name {
+				namespaced[cont.Name] = *cont
+			}
+		}
+		counted[i].NumContainers = len(namespaced)
+		m.On("AllNamespacedContainers", counted[i].Name, mock.Anything).Return(namespaced, nil)
+	}
+	m.On("GetNamespaces").Return(counted, nil)
+
+	for _, filter := range filters {
+		selected := []string{}
+		for _, name := range names {
+			if filter.Matches(containers[name].ContainerReference, containers[name].Spec) {
+				selected = append(selected, name)
+			}
+		}
+		m.On("SubcontainerNames", "/", filter).Return(selected, nil)
+	}
+	return m
+}
+
+// Returns a manager serving the labeled containers and the Docker namespace.
+func newLabeledManager(filters ...*manager.ContainerFilter) *manager.ManagerMock {
+	return newContainersManager(newLabeledContainers(), []v2.Namespace{{Name: "docker", MountPoint: "/docker", Factory: "docker"}}, filters...)
+}
+
+// Returns the filter the API passes to the manager for the query.
+func getQueryFilter(t *testing.T, rawQuery string) *manager.ContainerFilter {
+	filter, err := getContainerFilter(makeHTTPRequest("http://localhost:8080/api/v1.1/subcontainers/?"+rawQuery, t))
+	require.Nil(t, err)
+	return filter
+}
+
+// Like getQueryFilter, for the containers of the namespace.
+func getNamespacedQueryFilter(t *testing.T, namespace, rawQuery string) *manager.ContainerFilter {
+	filter := *getQueryFilter(t, rawQuery)
+	filter.Namespace = namespace
+	return &filter
+}
+
+// Requests the filtered subcontainers of "/" through the API and returns their names.
+func getFilteredNamesFromApi(t *testing.T, m manager.Manager, rawQuery string) []string {
+	r, err := http.NewRequest("GET", "http://localhost:8080/api/v1.1/subcontainers/?"+rawQuery, strings.NewReader(""))
+	require.Nil(t, err)
+	w := httptest.NewRecorder()
+	require.Nil(t, api1_1.HandleRequest(subcontainersApi, []string{}, m, w, r))
+	var containers []info.ContainerInfo
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &containers))
+	names := make([]string, 0, len(containers))
+	for _, cont := range containers {
+		names = append(names, cont.Name)
+	}
+	return names
+}
+
+func TestGetContainerFilter(t *testing.T) {
+	filter, err := getContainerFilter(makeHTTPRequest("http://localhost:8080/api/v1.1/subcontainers/", t))
+	require.Nil(t, err)
+	assert.Nil(t, filter)
+
+	filter, err = getContainerFilter(makeHTTPRequest("http://localhost:8080/api/v1.1/subcontainers/?name_re=%5E/docker&alias_re=web&label=env=prod&label=tier=a=b", t))
+	require.Nil(t, err)
+	require.NotNil(t, filter)
+	assert.Equal(t, "^/docker", filter.Name.String())
+	assert.Equal(t, "web", filter.Alias.String())
+	assert.Equal(t, map[string]string{"env": "prod", "tier": "a=b"}, filter.Labels)
+
+	for _, bad := range []string{"name_re=%5B", "alias_re=(", "label=env", "label==prod"} {
+		_, err = getContainerFilter(makeHTTPRequest("http://localhost:8080/api/v1.1/subcontainers/?"+bad, t))
+		assert.NotNil(t, err, "expected %q to be rejected", bad)
+		_, ok := err.(badRequestError)
+		assert.True(t, ok, "expected %q to be a bad request, got %v", bad, err)
+	}
+}
+
+func TestSubcontainersFilteredByName(t *testing.T) {
+	query := "name_re=%5E/docker/"
+	m := newLabeledManager(getQueryFilter(t, query))
+	assert.Equal(t, []string{"/docker/1", "/docker/2", "/docker/3", "/docker/4"}, getFilteredNamesFromApi(t, m, query))
+}
+
+func TestSubcontainersFilteredByAlias(t *testing.T) {
+	query := "alias_re=%5Eweb-"
+	m := newLabeledManager(getQueryFilter(t, query))
+	assert.Equal(t, []string{"/docker/1", "/docker/2", "/docker/4"}, getFilteredNamesFromApi(t, m, query))
+}
+
+func TestSubcontainersFilteredByLabelsSkipUnlabeled(t *testing.T) {
+	queries := []string{"label=env=prod", "label=env=prod&label=tier=frontend", "label=env=staging"}
+	m := newLabeledManager(getQueryFilter(t, queries[0]), getQueryFilter(t, queries[1]), getQueryFilter(t, queries[2]))
+	assert.Equal(t, []string{"/docker/1", "/docker/3"}, getFilteredNamesFromApi(t, m, queries[0]))
+	assert.Equal(t, []string{"/docker/1"}, getFilteredNamesFromApi(t, m, queries[1]))
+	assert.Empty(t, getFilteredNamesFromApi(t, m, queries[2]))
+}
+
+func TestSubcontainersWithCombinedFilters(t *testing.T) {
+	queries := []string{"name_re=/docker/&alias_re=%5Eweb-&label=env=dev", "alias_re=web&label=tier=frontend&label=env=prod", "limit=1&label=tier=frontend"}
+	m := newLabeledManager(getQueryFilter(t, queries[0]), getQueryFilter(t, queries[1]), getQueryFilter(t, queries[2]))
+	assert.Equal(t, []string{"/docker/2"}, getFilteredNamesFromApi(t, m, queries[0]))
+	assert.Equal(t, []string{"/docker/1"}, getFilteredNamesFromApi(t, m, queries[1]))
+
+	// Filters apply to paginated requests too.
+	page, _ := getSubcontainersPageFromApi(t, m, queries[2])
+	require.Equal(t, 1, len(page.Containers))
+	assert.Equal(t, "/docker/1", page.Containers[0].Name)
+	assert.NotEmpty(t, page.Continue)
+}
+
+func TestDockerContainersFiltered(t *testing.T) {
+	m := newLabeledManager(getNamespacedQueryFilter(t, dockerApi, "label=tier=frontend"))
+	r, err := http.NewRequest("GET", "http://localhost:8080/api/v1.2/docker/?label=tier=frontend", strings.NewReader(""))
+	require.Nil(t, err)
+	w := httptest.NewRecorder()
+	require.Nil(t, newVersion1_2(api1_1).HandleRequest(dockerApi, []string{}, m, w, r))
+	var containers map[string]info.ContainerInfo
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &containers))
+	names := make([]string, 0, len(containers))
+	for name := range containers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"/docker/1", "/docker/2"}, names)
+}
+
+func TestInvalidFilterIsBadRequest(t *testing.T) {
+	mux := http.NewServeMux()
+	require.Nil(t, RegisterHandlers(mux, newLabeledManager()))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/v1.1/subcontainers/?name_re=%5B")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
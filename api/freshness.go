@@ -0,0 +1,83 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Rank of the freshness states, the most recent first.
+var freshnessRanks = map[string]int{
+	info.FreshnessFresh: 0,
+	info.FreshnessStale: 1,
+	info.FreshnessDead:  2,
+}
+
+// Returns the freshness state requested through the "min_freshness" option,
+// empty if listed containers are not filtered by freshness.
+func getMinFreshness(r *http.Request) (string, error) {
+	minFreshness := r.URL.Query().Get("min_freshness")
+	if len(minFreshness) == 0 {
+		return "", nil
+	}
+	if _, ok := freshnessRanks[minFreshness]; !ok {
+		return "", badRequestError{fmt.Errorf("invalid 'min_freshness' option %q, expected %q, %q or %q", minFreshness, info.FreshnessFresh, info.FreshnessStale, info.FreshnessDead)}
+	}
+	return minFreshness, nil
+}
+
+// Whether the stats of the container are at least as fresh as minFreshness.
+// Containers of unknown freshness only meet an empty minimum.
+func freshEnough(cont *info.ContainerInfo, minFreshness string) bool {
+	if len(minFreshness) == 0 {
+		return true
+	}
+	if cont.Freshness == nil {
+		return false
+	}
+	rank, ok := freshnessRanks[cont.Freshness.State]
+	return ok && rank <= freshnessRanks[minFreshness]
+}
+
+// Returns the containers whose stats are at least as fresh as minFreshness.
+func filterFresh(containers []*info.ContainerInfo, minFreshness string) []*info.ContainerInfo {
+	if len(minFreshness) == 0 {
+		return containers
+	}
+	ret := make([]*info.ContainerInfo, 0, len(containers))
+	for _, cont := range containers {
+		if freshEnough(cont, minFreshness) {
+			ret = append(ret, cont)
+		}
+	}
+	return ret
+}
+
+// Like filterFresh, for listings of containers by value.
+func filterFreshValues(containers []info.ContainerInfo, minFreshness string) []info.ContainerInfo {
+	if len(minFreshness) == 0 {
+		return containers
+	}
+	ret := make([]info.ContainerInfo, 0, len(containers))
+	for i := range containers {
+		if freshEnough(&containers[i], minFreshness) {
+			ret = append(ret, containers[i])
+		}
+	}
+	return ret
+}
@@ -0,0 +1,105 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/google/cadvisor/manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Returns a manager serving the labeled containers with a freshness of each
+// state. The subcontainers of "/" are listed for each of the filters.
+func newFreshnessManager(filters ...*manager.ContainerFilter) *manager.ManagerMock {
+	containers := newLabeledContainers()
+	containers["/docker/1"].Freshness = &info.Freshness{State: info.FreshnessFresh}
+	containers["/docker/2"].Freshness = &info.Freshness{State: info.FreshnessStale}
+	containers["/docker/3"].Freshness = &info.Freshness{State: info.FreshnessDead}
+	// The freshness of "/docker/4" is unknown.
+	return newContainersManager(containers, []v2.Namespace{{Name: "docker", MountPoint: "/docker", Factory: "docker"}}, filters...)
+}
+
+func TestGetMinFreshness(t *testing.T) {
+	minFreshness, err := getMinFreshness(makeHTTPRequest("http://localhost:8080/api/v1.1/subcontainers/", t))
+	require.Nil(t, err)
+	assert.Equal(t, "", minFreshness)
+
+	minFreshness, err = getMinFreshness(makeHTTPRequest("http://localhost:8080/api/v1.1/subcontainers/?min_freshness=stale", t))
+	require.Nil(t, err)
+	assert.Equal(t, info.FreshnessStale, minFreshness)
+
+	_, err = getMinFreshness(makeHTTPRequest("http://localhost:8080/api/v1.1/subcontainers/?min_freshness=recent", t))
+	_, ok := err.(badRequestError)
+	assert.True(t, ok, "expected a bad request, got %v", err)
+}
+
+func TestFreshEnough(t *testing.T) {
+	fresh := &info.ContainerInfo{Freshness: &info.Freshness{State: info.FreshnessFresh}}
+	stale := &info.ContainerInfo{Freshness: &info.Freshness{State: info.FreshnessStale}}
+	dead := &info.ContainerInfo{Freshness: &info.Freshness{State: info.FreshnessDead}}
+	unknown := &info.ContainerInfo{}
+
+	for _, cont := range []*info.ContainerInfo{fresh, stale, dead, unknown} {
+		assert.True(t, freshEnough(cont, ""))
+	}
+	assert.True(t, freshEnough(fresh, info.FreshnessFresh))
+	assert.False(t, freshEnough(stale, info.FreshnessFresh))
+	assert.True(t, freshEnough(stale, info.FreshnessStale))
+	assert.False(t, freshEnough(dead, info.FreshnessStale))
+	assert.True(t, freshEnough(dead, info.FreshnessDead))
+	assert.False(t, freshEnough(unknown, info.FreshnessDead))
+}
+
+func TestSubcontainersFilteredByFreshness(t *testing.T) {
+	filtered := []string{"label=tier=frontend&min_freshness=fresh", "limit=2&name_re=/docker/&min_freshness=fresh"}
+	m := newFreshnessManager(getQueryFilter(t, filtered[0]), getQueryFilter(t, filtered[1]))
+	assert.Equal(t, []string{"/docker/1"}, getFilteredNamesFromApi(t, m, "min_freshness=fresh"))
+	assert.Equal(t, []string{"/docker/1", "/docker/2"}, getFilteredNamesFromApi(t, m, "min_freshness=stale"))
+	assert.Equal(t, []string{"/docker/1", "/docker/2", "/docker/3"}, getFilteredNamesFromApi(t, m, "min_freshness=dead"))
+
+	// Along with other filters.
+	assert.Equal(t, []string{"/docker/1"}, getFilteredNamesFromApi(t, m, filtered[0]))
+
+	// And on pages, which may then be short.
+	page, _ := getSubcontainersPageFromApi(t, m, filtered[1])
+	require.Equal(t, 1, len(page.Containers))
+	assert.Equal(t, "/docker/1", page.Containers[0].Name)
+	assert.NotEmpty(t, page.Continue)
+}
+
+func TestDockerContainersFilteredByFreshness(t *testing.T) {
+	m := newFreshnessManager()
+	r, err := http.NewRequest("GET", "http://localhost:8080/api/v1.2/docker/?min_freshness=stale", strings.NewReader(""))
+	require.Nil(t, err)
+	w := httptest.NewRecorder()
+	require.Nil(t, newVersion1_2(api1_1).HandleRequest(dockerApi, []string{}, m, w, r))
+	var containers map[string]info.ContainerInfo
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &containers))
+	names := make([]string, 0, len(containers))
+	for name := range containers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"/docker/1", "/docker/2"}, names)
+}
@@ -30,8 +30,10 @@ import (
 
 	"github.com/golang/glog"
 	"github.com/google/cadvisor/events"
+	"github.com/google/cadvisor/http/instrument"
 	httpMux "github.com/google/cadvisor/http/mux"
 	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
 	"github.com/google/cadvisor/manager"
 )
 
@@ -39,22 +41,144 @@ const (
 	apiResource = "/api/"
 )
 
+// Registers the API handler. On a read-only mux (see httpMux.ReadOnly()), only
+// the requests which leave the state of cAdvisor unchanged are served and the
+// version API reports it.
 func RegisterHandlers(mux httpMux.Mux, m manager.Manager) error {
-	apiVersions := getApiVersions()
+	apiVersions := getApiVersions(map[string]bool{
+		v2.FeatureReadOnly: httpMux.IsReadOnly(mux),
+	})
 	supportedApiVersions := make(map[string]ApiVersion, len(apiVersions))
 	for _, v := range apiVersions {
 		supportedApiVersions[v.Version()] = v
 	}
 
-	mux.HandleFunc(apiResource, func(w http.ResponseWriter, r *http.Request) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		err := handleRequest(supportedApiVersions, m, w, r)
-		if err != nil {
+		if _, ok := err.(badRequestError); ok {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else if err != nil {
 			http.Error(w, err.Error(), 500)
 		}
 	})
+	endpoint := func(r *http.Request) string {
+		return apiEndpoint(supportedApiVersions, m, r.URL.Path)
+	}
+	isSafe := func(r *http.Request) bool {
+		return isSafeRequest(m, r)
+	}
+	mux.Handle(apiResource, httpMux.Safe(instrument.DefaultRecorder.Handler(endpoint, handler), isSafe))
 	return nil
 }
 
+// Request types of all the API versions.
+var requestTypes = map[string]bool{
+	containersApi:    true,
+	subcontainersApi: true,
+	machineApi:       true,
+	dockerApi:        true,
+	summaryApi:       true,
+	statsApi:         true,
+	specApi:          true,
+	eventsApi:        true,
+	storageApi:       true,
+	attributesApi:    true,
+	versionApi:       true,
+	statusApi:        true,
+	topApi:           true,
+	requestsApi:      true,
+	snapshotApi:      true,
+	debugApi:         true,
+	deletedApi:       true,
+	namespacesApi:    true,
+}
+
+// Request types which leave the state of cAdvisor unchanged, the only ones
+// served when it is read-only. Request types must be added here explicitly to
+// be served then.
+var safeRequestTypes = map[string]bool{
+	containersApi:    true,
+	subcontainersApi: true,
+	machineApi:       true,
+	dockerApi:        true,
+	summaryApi:       true,
+	statsApi:         true,
+	specApi:          true,
+	eventsApi:        true,
+	storageApi:       true,
+	attributesApi:    true,
+	versionApi:       true,
+	statusApi:        true,
+	topApi:           true,
+	requestsApi:      true,
+	snapshotApi:      true,
+	debugApi:         true,
+	deletedApi:       true,
+	namespacesApi:    true,
+}
+
+// Whether the API request leaves the state of cAdvisor unchanged. Queries may
+// be posted, but forced collections (collect=true) change the stats kept and
+// watches of events register watchers with the manager. The containers of
+// namespaces are only read.
+func isSafeRequest(m manager.Manager, r *http.Request) bool {
+	if r.Method != "GET" && r.Method != "HEAD" && r.Method != "POST" {
+		return false
+	}
+	requestElements := apiRegexp.FindStringSubmatch(r.URL.Path)
+	if len(requestElements) == 0 || requestElements[apiRequestType] == "" {
+		// Lists of the versions and request types, or malformed requests.
+		return true
+	}
+	requestType := requestElements[apiRequestType]
+	if !safeRequestTypes[requestType] && !isNamespaceRequestType(m, requestType) {
+		return false
+	}
+	if requestType == eventsApi && isEventWatch(r) {
+		return false
+	}
+	return r.URL.Query().Get("collect") != "true"
+}
+
+// Whether the events request streams the events as they happen
+// (stream=true or no historical=true) rather than returning the past ones.
+func isEventWatch(r *http.Request) bool {
+	query := r.URL.Query()
+	if stream, err := strconv.ParseBool(query.Get("stream")); err == nil && stream {
+		return true
+	}
+	historical, err := strconv.ParseBool(query.Get("historical"))
+	return err != nil || !historical
+}
+
+// Returns the endpoint template the request is instrumented under, e.g.:
+// "/api/v2.0/stats" for "/api/v2.0/stats/docker". Unknown versions and request
+// types are grouped under "other" so that the number of endpoints is bounded by
+// that of the request types and namespaces.
+func apiEndpoint(supportedApiVersions map[string]ApiVersion, m manager.Manager, request string) string {
+	requestElements := apiRegexp.FindStringSubmatch(request)
+	if len(requestElements) == 0 {
+		return "/api"
+	}
+	version := requestElements[apiVersion]
+	if _, ok := supportedApiVersions[version]; !ok {
+		return "/api/other"
+	}
+	requestType := requestElements[apiRequestType]
+	if requestType == "" {
+		return path.Join("/api", version)
+	}
+	if !requestTypes[requestType] && !isNamespaceRequestType(m, requestType) {
+		requestType = "other"
+	}
+	return path.Join("/api", version, requestType)
+}
+
+// An error caused by a malformed request rather than by cAdvisor.
+type badRequestError struct {
+	error
+}
+
 // Captures the API version, requestType [optional], and remaining request [optional].
 var apiRegexp = regexp.MustCompile("/api/([^/]+)/?([^/]+)?(.*)")
 
@@ -106,7 +230,10 @@ func handleRequest(supportedApiVersions map[string]ApiVersion, m manager.Manager
 
 	// If no request type, list possible request types.
 	if requestType == "" {
-		requestTypes := versionHandler.SupportedRequestTypes()
+		requestTypes, err := getSupportedRequestTypes(versionHandler, m)
+		if err != nil {
+			return err
+		}
 		sort.Strings(requestTypes)
 		fmt.Fprintf(w, "Supported request types: %q", strings.Join(requestTypes, ","))
 		return nil
@@ -117,8 +244,52 @@ func handleRequest(supportedApiVersions map[string]ApiVersion, m manager.Manager
 		requestArgs = requestArgs[1:]
 	}
 
-	return versionHandler.HandleRequest(requestType, requestArgs, m, w, r)
+	// Strip the fields added since the version from its responses.
+	mask, ok := getFrozenSchema(m, version, requestType)
+	if !ok {
+		return versionHandler.HandleRequest(requestType, requestArgs, m, w, r)
+	}
+	mw := newMaskingResponseWriter(w, mask)
+	err := versionHandler.HandleRequest(requestType, requestArgs, m, mw, r)
+	if err != nil {
+		return err
+	}
+	return mw.flush()
+}
 
+// Returns the request types of the version, with the namespaces it serves.
+func getSupportedRequestTypes(versionHandler ApiVersion, m manager.Manager) ([]string, error) {
+	requestTypes := versionHandler.SupportedRequestTypes()
+	for _, requestType := range requestTypes {
+		if requestType != dockerApi {
+			continue
+		}
+		namespaces, err := getNamespaceRequestTypes(m)
+		if err != nil {
+			return nil, err
+		}
+		// Docker's is already listed.
+		return append(requestTypes, namespaces[1:]...), nil
+	}
+	return requestTypes, nil
+}
+
+// Status of the responses to requests which were rate limited (Too Many Requests).
+const statusTooManyRequests = 429
+
+// Header of the rate limited responses to forced collections carrying the age
+// of the newest stats of the container, e.g.: "1.5s". Absent if it has none.
+const newestSampleAgeHeader = "X-Cadvisor-Newest-Sample-Age"
+
+// Answers a rate limited forced collection with when to retry and the age of
+// the newest stats, which the client may use instead.
+func writeRateLimited(err *manager.RateLimitedError, w http.ResponseWriter) {
+	retryAfter := int64((err.RetryAfter + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+	if err.NewestSampleAge >= 0 {
+		w.Header().Set(newestSampleAgeHeader, err.NewestSampleAge.String())
+	}
+	http.Error(w, err.Error(), statusTooManyRequests)
 }
 
 func writeResult(res interface{}, w http.ResponseWriter) error {
@@ -133,7 +304,9 @@ func writeResult(res interface{}, w http.ResponseWriter) error {
 
 }
 
-func streamResults(results chan *events.Event, w http.ResponseWriter, r *http.Request) error {
+// Streams the events until the connection is closed or the channel is, e.g.:
+// after the notice of the events dropped by a watch which fell behind.
+func streamResults(results <-chan *events.Event, w http.ResponseWriter, r *http.Request) error {
 	cn, ok := w.(http.CloseNotifier)
 	if !ok {
 		return errors.New("could not access http.CloseNotifier")
@@ -152,7 +325,10 @@ func streamResults(results chan *events.Event, w http.ResponseWriter, r *http.Re
 		select {
 		case <-cn.CloseNotify():
 			return nil
-		case ev := <-results:
+		case ev, ok := <-results:
+			if !ok {
+				return nil
+			}
 			glog.V(3).Infof("Received event from watch channel in api: %v", ev)
 			err := enc.Encode(ev)
 			if err != nil {
@@ -163,17 +339,34 @@ func streamResults(results chan *events.Event, w http.ResponseWriter, r *http.Re
 	}
 }
 
-func getContainerInfoRequest(body io.ReadCloser) (*info.ContainerInfoRequest, error) {
+// Returns the query in the JSON body of the request. The machine's capacity can
+// also be requested with the "include_machine=true" option, recently deleted
+// containers with "include_deleted=true", and the resolution of the stats with
+// e.g. "resolution=72s".
+func getContainerInfoRequest(r *http.Request) (*info.ContainerInfoRequest, error) {
 	var query info.ContainerInfoRequest
 
 	// Default stats and samples is 64.
 	query.NumStats = 64
 
-	decoder := json.NewDecoder(body)
+	decoder := json.NewDecoder(r.Body)
 	err := decoder.Decode(&query)
 	if err != nil && err != io.EOF {
 		return nil, fmt.Errorf("unable to decode the json value: %s", err)
 	}
+	if r.URL.Query().Get("include_machine") == "true" {
+		query.IncludeMachine = true
+	}
+	if r.URL.Query().Get("include_deleted") == "true" {
+		query.IncludeDeleted = true
+	}
+	if resolution := r.URL.Query().Get("resolution"); resolution != "" {
+		d, err := time.ParseDuration(resolution)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid resolution %q", resolution)
+		}
+		query.Resolution = d
+	}
 
 	return &query, nil
 }
@@ -181,8 +374,9 @@ func getContainerInfoRequest(body io.ReadCloser) (*info.ContainerInfoRequest, er
 // The user can set any or none of the following arguments in any order
 // with any twice defined arguments being assigned the first value.
 // If the value type for the argument is wrong the field will be assumed to be
-// unassigned
-// bools: historical, subcontainers, oom_events, creation_events, deletion_events
+// unassigned, but a malformed name_glob is rejected
+// strings: name_glob (glob pattern of the absolute names of the containers, e.g.: /docker/*)
+// bools: historical, subcontainers, oom_events, creation_events, deletion_events, collection_events, time_jump_events, reattach_events, collector_restart_events, memory_pressure_events, cgroup_remount_events, cpu_hotplug_events
 // ints: max_events, start_time (unix timestamp), end_time (unix timestamp)
 // example r.URL: http://localhost:8080/api/v1.3/events?oom_events=true&historical=true&max_events=10
 func getEventRequest(r *http.Request) (*events.Request, bool, error) {
@@ -221,6 +415,49 @@ func getEventRequest(r *http.Request) (*events.Request, bool, error) {
 			query.EventType[events.TypeContainerDeletion] = newBool
 		}
 	}
+	if val, ok := urlMap["collection_events"]; ok {
+		newBool, err := strconv.ParseBool(val[0])
+		if err == nil {
+			query.EventType[events.TypeCollectionDegraded] = newBool
+			query.EventType[events.TypeCollectionRestored] = newBool
+		}
+	}
+	if val, ok := urlMap["time_jump_events"]; ok {
+		newBool, err := strconv.ParseBool(val[0])
+		if err == nil {
+			query.EventType[events.TypeTimeJump] = newBool
+		}
+	}
+	if val, ok := urlMap["reattach_events"]; ok {
+		newBool, err := strconv.ParseBool(val[0])
+		if err == nil {
+			query.EventType[events.TypeContainerReattached] = newBool
+		}
+	}
+	if val, ok := urlMap["collector_restart_events"]; ok {
+		newBool, err := strconv.ParseBool(val[0])
+		if err == nil {
+			query.EventType[events.TypeCollectorRestarted] = newBool
+		}
+	}
+	if val, ok := urlMap["memory_pressure_events"]; ok {
+		newBool, err := strconv.ParseBool(val[0])
+		if err == nil {
+			query.EventType[events.TypeMemoryPressure] = newBool
+		}
+	}
+	if val, ok := urlMap["cgroup_remount_events"]; ok {
+		newBool, err := strconv.ParseBool(val[0])
+		if err == nil {
+			query.EventType[events.TypeCgroupRemount] = newBool
+		}
+	}
+	if val, ok := urlMap["cpu_hotplug_events"]; ok {
+		newBool, err := strconv.ParseBool(val[0])
+		if err == nil {
+			query.EventType[events.TypeCpuHotplug] = newBool
+		}
+	}
 	if val, ok := urlMap["max_events"]; ok {
 		newInt, err := strconv.Atoi(val[0])
 		if err == nil {
@@ -239,6 +476,12 @@ func getEventRequest(r *http.Request) (*events.Request, bool, error) {
 			query.EndTime = newTime
 		}
 	}
+	if val, ok := urlMap["name_glob"]; ok {
+		if err := events.ValidateContainerGlob(val[0]); err != nil {
+			return nil, false, badRequestError{err}
+		}
+		query.ContainerGlob = val[0]
+	}
 
 	glog.V(2).Infof(
 		"%v was returned in api/handler.go:getEventRequest from the url rawQuery %v",
@@ -249,3 +492,22 @@ func getEventRequest(r *http.Request) (*events.Request, bool, error) {
 func getContainerName(request []string) string {
 	return path.Join("/", strings.Join(request, "/"))
 }
+
+// Returns the subcontainers of the specified container, along with the
+// pseudo-container of cAdvisor's metadata (manager.MetaContainerName) when
+// listing "/" if the request has the "include_meta=true" option. It is left
+// out by default so that it is not mistaken for a container.
+func getSubcontainersWithMeta(m manager.Manager, containerName string, query *info.ContainerInfoRequest, r *http.Request) ([]*info.ContainerInfo, error) {
+	containers, err := m.SubcontainersInfo(containerName, query)
+	if err != nil {
+		return nil, err
+	}
+	if containerName != "/" || r.URL.Query().Get("include_meta") != "true" {
+		return containers, nil
+	}
+	meta, err := m.GetContainerInfo(manager.MetaContainerName, query)
+	if err != nil {
+		return nil, err
+	}
+	return append(containers, meta), nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/cadvisor/events"
+	httpMux "github.com/google/cadvisor/http/mux"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/google/cadvisor/manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// Request types which change the state of cAdvisor, denied when it is read-only.
+var mutatingRequestTypes = map[string]bool{}
+
+// Every request type must be either safe or known to change the state of
+// cAdvisor, so that adding one requires deciding whether it is served when
+// cAdvisor is read-only.
+func TestRequestTypesArePartitioned(t *testing.T) {
+	for _, v := range getApiVersions(nil) {
+		for _, requestType := range v.SupportedRequestTypes() {
+			if safeRequestTypes[requestType] == mutatingRequestTypes[requestType] {
+				t.Errorf("Request type %q of API %s must be either in safeRequestTypes or in mutatingRequestTypes", requestType, v.Version())
+			}
+		}
+	}
+}
+
+func serveRequest(mux http.Handler, method, url string) *httptest.ResponseRecorder {
+	r, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		panic(err)
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	return w
+}
+
+func getVersion(t *testing.T, mux http.Handler) v2.VersionInfo {
+	w := serveRequest(mux, "GET", "http://localhost:8080/api/v2.0/version")
+	require.Equal(t, http.StatusOK, w.Code)
+	var version v2.VersionInfo
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &version))
+	return version
+}
+
+func TestReadOnlyApi(t *testing.T) {
+	mux := http.NewServeMux()
+	require.Nil(t, RegisterHandlers(httpMux.ReadOnly(mux), newMachineInfoManager(&info.MachineInfo{NumCores: 4})))
+
+	assert.Equal(t, map[string]bool{v2.FeatureReadOnly: true}, getVersion(t, mux).Features)
+	for _, c := range []struct {
+		method, url string
+		expected    int
+	}{
+		{"GET", "/api/", http.StatusOK},
+		{"GET", "/api/v1.3/machine", http.StatusOK},
+		{"HEAD", "/api/v1.3/machine", http.StatusOK},
+		// Queries are posted.
+		{"POST", "/api/v1.3/machine", http.StatusOK},
+		{"DELETE", "/api/v1.3/machine", http.StatusMethodNotAllowed},
+		{"POST", "/api/v1.3/containers/?collect=true", http.StatusMethodNotAllowed},
+		// Unknown request types may change the state of cAdvisor.
+		{"GET", "/api/v2.0/unknown", http.StatusMethodNotAllowed},
+	} {
+		assert.Equal(t, c.expected, serveRequest(mux, c.method, "http://localhost:8080"+c.url).Code, "%s %s", c.method, c.url)
+	}
+}
+
+// Past events are queried, but watches register with the manager.
+func TestReadOnlyApiDeniesEventWatches(t *testing.T) {
+	m := &manager.ManagerMock{}
+	m.On("GetPastEvents", mock.Anything).Return(events.EventSlice{}, nil)
+	m.On("GetDeletedContainers").Return([]v2.DeletedContainer{}, nil)
+	mux := http.NewServeMux()
+	require.Nil(t, RegisterHandlers(httpMux.ReadOnly(mux), m))
+
+	for url, expected := range map[string]int{
+		"/api/v1.3/events?oom_events=true&historical=true":             http.StatusOK,
+		"/api/v1.3/events?oom_events=true":                             http.StatusMethodNotAllowed,
+		"/api/v1.3/events?oom_events=true&historical=false":            http.StatusMethodNotAllowed,
+		"/api/v1.3/events?oom_events=true&historical=true&stream=true": http.StatusMethodNotAllowed,
+	} {
+		assert.Equal(t, expected, serveRequest(mux, "GET", "http://localhost:8080"+url).Code, url)
+	}
+	m.AssertNotCalled(t, "WatchForEvents", mock.Anything)
+}
+
+func TestVersionReportsWritableApi(t *testing.T) {
+	mux := http.NewServeMux()
+	require.Nil(t, RegisterHandlers(mux, newMachineInfoManager(&info.MachineInfo{NumCores: 4})))
+	version := getVersion(t, mux)
+	assert.Equal(t, "0.16.0", version.CadvisorVersion)
+	assert.Equal(t, map[string]bool{v2.FeatureReadOnly: false}, version.Features)
+}
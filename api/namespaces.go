@@ -0,0 +1,126 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/golang/glog"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/manager"
+)
+
+// Returns the namespaces served under their name by the versions serving
+// Docker's, in order of registration. Docker's is always served, whether its
+// factory is registered or not, as it was before the others. Namespaces named
+// after another request type are not served, the request type wins.
+func getNamespaceRequestTypes(m manager.Manager) ([]string, error) {
+	namespaces, err := m.GetNamespaces()
+	if err != nil {
+		return nil, err
+	}
+	ret := []string{dockerApi}
+	for _, ns := range namespaces {
+		if requestTypes[ns.Name] {
+			continue
+		}
+		ret = append(ret, ns.Name)
+	}
+	return ret, nil
+}
+
+// Whether the request type is the name of a namespace served by the versions
+// serving Docker's. Only looks the namespaces up for unknown request types.
+func isNamespaceRequestType(m manager.Manager, requestType string) bool {
+	if requestType == dockerApi {
+		return true
+	}
+	if requestTypes[requestType] || requestType == "" {
+		return false
+	}
+	namespaces, err := m.GetNamespaces()
+	if err != nil {
+		glog.Warningf("Failed to get the namespaces: %v", err)
+		return false
+	}
+	for _, ns := range namespaces {
+		if ns.Name == requestType {
+			return true
+		}
+	}
+	return false
+}
+
+// Serves the containers of the namespace: all of them (optionally filtered)
+// without a name, or the one known by the name within the namespace.
+func handleNamespaceRequest(namespace string, request []string, m manager.Manager, w http.ResponseWriter, r *http.Request) error {
+	glog.V(2).Infof("Api - Namespace %q(%v)", namespace, request)
+
+	// Get the query request.
+	query, err := getContainerInfoRequest(r)
+	if err != nil {
+		return err
+	}
+
+	var containers map[string]info.ContainerInfo
+	// map requests for "<namespace>/" to "<namespace>"
+	if len(request) == 1 && len(request[0]) == 0 {
+		request = request[:0]
+	}
+	switch len(request) {
+	case 0:
+		filter, err := getContainerFilter(r)
+		if err != nil {
+			return err
+		}
+		minFreshness, err := getMinFreshness(r)
+		if err != nil {
+			return err
+		}
+		if filter != nil {
+			containers, err = getFilteredNamespacedContainers(m, namespace, query, filter)
+			if err != nil {
+				return fmt.Errorf("failed to get containers of namespace %q with error: %v", namespace, err)
+			}
+		} else {
+			// Get all the containers of the namespace.
+			containers, err = m.AllNamespacedContainers(namespace, query)
+			if err != nil {
+				return fmt.Errorf("failed to get all containers of namespace %q with error: %v", namespace, err)
+			}
+		}
+		for name, cont := range containers {
+			if !freshEnough(&cont, minFreshness) {
+				delete(containers, name)
+			}
+		}
+	case 1:
+		// Get one container of the namespace.
+		var cont info.ContainerInfo
+		cont, err = m.NamespacedContainer(namespace, request[0], query)
+		if err != nil {
+			return fmt.Errorf("failed to get container %q of namespace %q with error: %v", request[0], namespace, err)
+		}
+		containers = map[string]info.ContainerInfo{
+			cont.Name: cont,
+		}
+	default:
+		return fmt.Errorf("unknown request for container of namespace %q %v", namespace, request)
+	}
+
+	// Only output the containers as JSON.
+	return writeResult(containers, w)
+}
@@ -0,0 +1,141 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	httpMux "github.com/google/cadvisor/http/mux"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/google/cadvisor/manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Returns a manager serving containers in the namespaces of the Docker and
+// LXC factories, and of a factory named after a request type. The
+// subcontainers of "/" are listed for each of the filters.
+func newNamespacedManager(filters ...*manager.ContainerFilter) *manager.ManagerMock {
+	containers := newLabeledContainers()
+	addContainer(containers, "/lxc/a", "lxc", "a", nil)
+	addContainer(containers, "/lxc/b", "lxc", "b", nil)
+	return newContainersManager(containers, []v2.Namespace{
+		{Name: "docker", MountPoint: "/docker", Factory: "docker"},
+		{Name: "lxc", MountPoint: "/lxc", Factory: "lxc"},
+		{Name: machineApi, MountPoint: "/machines", Factory: "machines"},
+	}, filters...)
+}
+
+func getNamespacesMux(t *testing.T, filters ...*manager.ContainerFilter) http.Handler {
+	mux := http.NewServeMux()
+	require.Nil(t, RegisterHandlers(mux, newNamespacedManager(filters...)))
+	return mux
+}
+
+func TestNamespacesApi(t *testing.T) {
+	w := serveRequest(getNamespacesMux(t), "GET", "http://localhost:8080/api/v2.0/namespaces")
+	require.Equal(t, http.StatusOK, w.Code)
+	var namespaces []v2.Namespace
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &namespaces))
+	assert.Equal(t, []v2.Namespace{
+		{Name: "docker", MountPoint: "/docker", Factory: "docker", NumContainers: 4},
+		{Name: "lxc", MountPoint: "/lxc", Factory: "lxc", NumContainers: 2},
+		{Name: machineApi, MountPoint: "/machines", Factory: "machines", NumContainers: 0},
+	}, namespaces)
+}
+
+func TestNamespacesAreRequestTypes(t *testing.T) {
+	mux := getNamespacesMux(t)
+	for version, expected := range map[string]string{
+		"v1.1": `Supported request types: "containers,machine,subcontainers"`,
+		"v1.2": `Supported request types: "containers,docker,lxc,machine,subcontainers"`,
+	} {
+		w := serveRequest(mux, "GET", "http://localhost:8080/api/"+version)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, expected, w.Body.String(), "version %s", version)
+	}
+}
+
+// Serves the container query, whose body is empty.
+func serveQuery(t *testing.T, mux http.Handler, url string) *httptest.ResponseRecorder {
+	r, err := http.NewRequest("GET", "http://localhost:8080"+url, strings.NewReader(""))
+	require.Nil(t, err)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	return w
+}
+
+// Requests the containers of the namespace through the API and returns their names.
+func getNamespacedNames(t *testing.T, mux http.Handler, url string) []string {
+	w := serveQuery(t, mux, url)
+	require.Equal(t, http.StatusOK, w.Code, "request %q: %s", url, w.Body.String())
+	var containers map[string]info.ContainerInfo
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &containers))
+	names := make([]string, 0, len(containers))
+	for name := range containers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestNamespacedContainersApi(t *testing.T) {
+	mux := getNamespacesMux(t, getNamespacedQueryFilter(t, "lxc", "alias_re=^a$"))
+	assert.Equal(t, []string{"/lxc/a", "/lxc/b"}, getNamespacedNames(t, mux, "/api/v1.2/lxc"))
+	assert.Equal(t, []string{"/lxc/a", "/lxc/b"}, getNamespacedNames(t, mux, "/api/v1.3/lxc/"))
+	assert.Equal(t, []string{"/lxc/b"}, getNamespacedNames(t, mux, "/api/v1.3/lxc/b"))
+	assert.Equal(t, []string{"/lxc/a"}, getNamespacedNames(t, mux, "/api/v1.3/lxc/?alias_re=^a$"))
+	assert.Equal(t, []string{"/docker/3"}, getNamespacedNames(t, mux, "/api/v1.3/docker/db"))
+
+	for _, url := range []string{
+		// Namespaces are served since v1.2.
+		"/api/v1.1/lxc",
+		"/api/v1.3/lxc/c",
+		"/api/v1.3/unknown",
+	} {
+		assert.NotEqual(t, http.StatusOK, serveQuery(t, mux, url).Code, "request %q", url)
+	}
+}
+
+func TestDockerNamespaceAlwaysServed(t *testing.T) {
+	m := newContainersManager(newLabeledContainers(), []v2.Namespace{})
+	mux := http.NewServeMux()
+	require.Nil(t, RegisterHandlers(mux, m))
+	assert.Equal(t, []string{"/docker/1"}, getNamespacedNames(t, mux, "/api/v1.3/docker/web-1"))
+}
+
+func TestNamespacesServedReadOnly(t *testing.T) {
+	mux := http.NewServeMux()
+	require.Nil(t, RegisterHandlers(httpMux.ReadOnly(mux), newNamespacedManager()))
+	assert.Equal(t, http.StatusOK, serveQuery(t, mux, "/api/v1.3/lxc").Code)
+	assert.Equal(t, http.StatusOK, serveRequest(mux, "GET", "http://localhost:8080/api/v2.0/namespaces").Code)
+}
+
+func TestNamespaceEndpointTemplates(t *testing.T) {
+	supportedApiVersions := make(map[string]ApiVersion)
+	for _, v := range getApiVersions(nil) {
+		supportedApiVersions[v.Version()] = v
+	}
+	m := newNamespacedManager()
+	assert.Equal(t, "/api/v1.3/lxc", apiEndpoint(supportedApiVersions, m, "/api/v1.3/lxc/a"))
+	assert.Equal(t, "/api/v1.3/docker", apiEndpoint(supportedApiVersions, m, "/api/v1.3/docker/a"))
+	assert.Equal(t, "/api/v1.3/other", apiEndpoint(supportedApiVersions, m, "/api/v1.3/unknown/a"))
+}
@@ -0,0 +1,113 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/manager"
+)
+
+var defaultPageLimit = flag.Int("api_page_limit", 100, "Number of containers in a page of the API when only a cursor is specified, and largest number of containers in the cadvisor.containers expvar map")
+
+// A request for one page of containers. Containers are ordered by name and
+// the page starts after the last container of the previous page, so paging
+// is stable as containers come and go: removed containers are skipped and
+// new ones show up on a later page if they sort after the cursor.
+type pageRequest struct {
+	// Maximum number of containers in the page.
+	Limit int
+
+	// Name of the last container of the previous page, empty for the first page.
+	After string
+}
+
+// Returns the page requested through the "limit" and "continue" options, or
+// nil if the request is not paginated.
+func getPageRequest(r *http.Request) (*pageRequest, error) {
+	limit := r.URL.Query().Get("limit")
+	cursor := r.URL.Query().Get("continue")
+	if len(limit) == 0 && len(cursor) == 0 {
+		return nil, nil
+	}
+	pr := &pageRequest{
+		Limit: *defaultPageLimit,
+	}
+	if len(limit) != 0 {
+		n, err := strconv.ParseUint(limit, 10, 32)
+		if err != nil || n == 0 {
+			return nil, fmt.Errorf("failed to parse 'limit' option: %v", limit)
+		}
+		pr.Limit = int(n)
+	}
+	if len(cursor) != 0 {
+		after, err := base64.URLEncoding.DecodeString(cursor)
+		if err != nil || len(after) == 0 {
+			return nil, fmt.Errorf("malformed 'continue' option: %v", cursor)
+		}
+		pr.After = string(after)
+	}
+	return pr, nil
+}
+
+// Returns the names in the requested page from the sorted names, and the
+// cursor of the next page (empty if this is the last one).
+func (self *pageRequest) page(names []string) ([]string, string) {
+	start := 0
+	if self.After != "" {
+		start = sort.SearchStrings(names, self.After)
+		if start < len(names) && names[start] == self.After {
+			start++
+		}
+	}
+	end := start + self.Limit
+	if end >= len(names) {
+		return names[start:], ""
+	}
+	return names[start:end], base64.URLEncoding.EncodeToString([]byte(names[end-1]))
+}
+
+// Returns a page of the subcontainers of the specified container (inclusive)
+// selected by the filter. Only the containers in the page are read from the
+// manager. Containers removed since they were listed are skipped.
+func getSubcontainersPage(m manager.Manager, containerName string, query *info.ContainerInfoRequest, filter *manager.ContainerFilter, pr *pageRequest) (info.ContainerInfoPage, error) {
+	names, err := m.SubcontainerNames(containerName, filter)
+	if err != nil {
+		return info.ContainerInfoPage{}, err
+	}
+	page, next := pr.page(names)
+	return info.ContainerInfoPage{
+		Containers: getContainers(m, page, query),
+		Continue:   next,
+	}, nil
+}
+
+// Returns a page of the containers with aliases in the specified namespace
+// (e.g.: Docker containers) selected by the filter, by full name. Only the
+// containers in the page are read from the manager.
+func getNamespacedContainersPage(m manager.Manager, namespace string, query *info.ContainerInfoRequest, filter *manager.ContainerFilter, pr *pageRequest) (info.ContainerInfoPage, error) {
+	namespacedFilter := manager.ContainerFilter{}
+	if filter != nil {
+		namespacedFilter = *filter
+	}
+	namespacedFilter.Namespace = namespace
+	return getSubcontainersPage(m, "/", query, &namespacedFilter, pr)
+}
@@ -0,0 +1,226 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/google/cadvisor/manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// Returns the names of the root container and of n Docker containers.
+func listNames(n int) []string {
+	names := []string{"/"}
+	for i := 0; i < n; i++ {
+		names = append(names, fmt.Sprintf("/docker/%05d", i))
+	}
+	return names
+}
+
+// Returns a manager serving the specified containers, with only a name and a
+// spec, as the subcontainers of "/".
+func newListManager(names []string) *manager.ManagerMock {
+	m := &manager.ManagerMock{}
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+	m.On("SubcontainerNames", "/", (*manager.ContainerFilter)(nil)).Return(sorted, nil)
+	for _, name := range names {
+		m.On("GetContainerInfo", name, mock.Anything).Return(&info.ContainerInfo{
+			ContainerReference: info.ContainerReference{
+				Name: name,
+			},
+			Spec: info.ContainerSpec{
+				HasCpu: true,
+			},
+		}, nil)
+	}
+	return m
+}
+
+var api1_1 = newVersion1_1(&version1_0{})
+
+// Requests a page of the subcontainers of "/" through the API and returns it along with the size of the response.
+func getSubcontainersPageFromApi(t *testing.T, m manager.Manager, rawQuery string) (info.ContainerInfoPage, int) {
+	r, err := http.NewRequest("GET", "http://localhost:8080/api/v1.1/subcontainers/?"+rawQuery, strings.NewReader(""))
+	require.Nil(t, err)
+	w := httptest.NewRecorder()
+	require.Nil(t, api1_1.HandleRequest(subcontainersApi, []string{}, m, w, r))
+	var page info.ContainerInfoPage
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &page))
+	return page, w.Body.Len()
+}
+
+func TestSubcontainersPagination(t *testing.T) {
+	const numContainers = 500
+	const limit = 10
+	m := newListManager(listNames(numContainers))
+
+	// Bound the size of a page by that of as many of the largest container.
+	largest, err := json.Marshal(info.ContainerInfo{
+		ContainerReference: info.ContainerReference{Name: "/docker/99999"},
+		Spec:               info.ContainerSpec{HasCpu: true},
+	})
+	require.Nil(t, err)
+	maxPageSize := limit*(len(largest)+1) + 100
+
+	seen := make(map[string]bool)
+	var names []string
+	cursor := ""
+	pages := 0
+	for {
+		page, size := getSubcontainersPageFromApi(t, m, fmt.Sprintf("limit=%d&continue=%s", limit, cursor))
+		pages++
+		assert.True(t, len(page.Containers) <= limit, "page %d has %d containers", pages, len(page.Containers))
+		assert.True(t, size <= maxPageSize, "page %d is %d bytes", pages, size)
+		for _, cont := range page.Containers {
+			assert.False(t, seen[cont.Name], "%q returned twice", cont.Name)
+			seen[cont.Name] = true
+			names = append(names, cont.Name)
+		}
+		if page.Continue == "" {
+			break
+		}
+		require.True(t, pages <= numContainers, "pagination did not terminate")
+		cursor = page.Continue
+	}
+
+	assert.Equal(t, numContainers+1, len(names))
+	assert.True(t, sort.StringsAreSorted(names), "containers should be ordered by name")
+	assert.Equal(t, numContainers/limit+1, pages)
+}
+
+func TestSubcontainersPaginationWithChurn(t *testing.T) {
+	names := listNames(10)
+	page, _ := getSubcontainersPageFromApi(t, newListManager(names), "limit=5")
+	require.Equal(t, 5, len(page.Containers))
+	assert.Equal(t, "/docker/00003", page.Containers[4].Name)
+
+	// A container on the next page goes away, new ones show up before and after the cursor.
+	var churned []string
+	for _, name := range names {
+		if name != "/docker/00005" {
+			churned = append(churned, name)
+		}
+	}
+	m := newListManager(append(churned, "/docker/00003a", "/docker/00001a"))
+
+	var rest []string
+	for page.Continue != "" {
+		page, _ = getSubcontainersPageFromApi(t, m, "limit=5&continue="+page.Continue)
+		for _, cont := range page.Containers {
+			rest = append(rest, cont.Name)
+		}
+	}
+	assert.Equal(t, []string{"/docker/00003a", "/docker/00004", "/docker/00006", "/docker/00007", "/docker/00008", "/docker/00009"}, rest)
+}
+
+func TestSubcontainersPaginationSkipsRemovedContainers(t *testing.T) {
+	m := &manager.ManagerMock{}
+	m.On("SubcontainerNames", "/", (*manager.ContainerFilter)(nil)).Return(listNames(3), nil)
+	for _, name := range []string{"/", "/docker/00000", "/docker/00002"} {
+		m.On("GetContainerInfo", name, mock.Anything).Return(&info.ContainerInfo{
+			ContainerReference: info.ContainerReference{Name: name},
+		}, nil)
+	}
+	// Listed but removed by the time its information is read.
+	m.On("GetContainerInfo", "/docker/00001", mock.Anything).Return((*info.ContainerInfo)(nil), fmt.Errorf("unknown container \"/docker/00001\""))
+	page, _ := getSubcontainersPageFromApi(t, m, "limit=10")
+	var names []string
+	for _, cont := range page.Containers {
+		names = append(names, cont.Name)
+	}
+	assert.Equal(t, []string{"/", "/docker/00000", "/docker/00002"}, names)
+	assert.Empty(t, page.Continue)
+}
+
+func TestGetPageRequest(t *testing.T) {
+	pr, err := getPageRequest(makeHTTPRequest("http://localhost:8080/api/v1.1/subcontainers/", t))
+	require.Nil(t, err)
+	assert.Nil(t, pr)
+
+	pr, err = getPageRequest(makeHTTPRequest("http://localhost:8080/api/v1.1/subcontainers/?continue=L2RvY2tlcg==", t))
+	require.Nil(t, err)
+	assert.Equal(t, &pageRequest{Limit: *defaultPageLimit, After: "/docker"}, pr)
+
+	for _, bad := range []string{"limit=0", "limit=-1", "limit=a", "continue=!!"} {
+		_, err = getPageRequest(makeHTTPRequest("http://localhost:8080/api/v1.1/subcontainers/?"+bad, t))
+		assert.NotNil(t, err, "expected %q to be rejected", bad)
+	}
+}
+
+func TestRecursiveStatsPagination(t *testing.T) {
+	m := newListManager(listNames(5))
+	api := newVersion2_0(newVersion1_3(newVersion1_2(api1_1)))
+	var names []string
+	cursor := ""
+	for {
+		r, err := http.NewRequest("GET", "http://localhost:8080/api/v2.0/stats/?recursive=true&count=1&limit=2&continue="+cursor, strings.NewReader(""))
+		require.Nil(t, err)
+		w := httptest.NewRecorder()
+		require.Nil(t, api.HandleRequest(statsApi, []string{}, m, w, r))
+		var page v2.StatsPage
+		require.Nil(t, json.Unmarshal(w.Body.Bytes(), &page))
+		assert.True(t, len(page.Stats) <= 2)
+		for name := range page.Stats {
+			names = append(names, name)
+		}
+		if page.Continue == "" {
+			break
+		}
+		cursor = page.Continue
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"/", "/docker/00000", "/docker/00001", "/docker/00002", "/docker/00003", "/docker/00004"}, names)
+
+	// Pagination is only supported on recursive requests.
+	r, err := http.NewRequest("GET", "http://localhost:8080/api/v2.0/stats/?limit=2", strings.NewReader(""))
+	require.Nil(t, err)
+	assert.NotNil(t, api.HandleRequest(statsApi, []string{}, m, httptest.NewRecorder(), r))
+}
+
+func TestDockerStatsPaginationOnlyReadsPage(t *testing.T) {
+	m := &manager.ManagerMock{}
+	names := []string{"/docker/a", "/docker/b", "/docker/c"}
+	m.On("SubcontainerNames", "/", &manager.ContainerFilter{Namespace: dockerApi}).Return(names, nil)
+	query := &info.ContainerInfoRequest{NumStats: 1}
+	for _, name := range names[:2] {
+		m.On("GetContainerInfo", name, query).Return(&info.ContainerInfo{
+			ContainerReference: info.ContainerReference{Name: name},
+		}, nil)
+	}
+
+	api := newVersion2_0(newVersion1_3(newVersion1_2(api1_1)))
+	r, err := http.NewRequest("GET", "http://localhost:8080/api/v2.0/stats/?type=docker&recursive=true&count=1&limit=2", strings.NewReader(""))
+	require.Nil(t, err)
+	w := httptest.NewRecorder()
+	require.Nil(t, api.HandleRequest(statsApi, []string{}, m, w, r))
+	var page v2.StatsPage
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Len(t, page.Stats, 2)
+	assert.NotEmpty(t, page.Continue)
+	// Neither all the Docker containers nor the one on the next page are read.
+	m.AssertExpectations(t)
+}
@@ -0,0 +1,343 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/cadvisor/manager"
+)
+
+// The fields of a JSON response served by a version of the API, by name.
+// Fields not in the mask are stripped, a nil mask keeps the whole field. The
+// "*" field matches the keys of JSON maps. The mask of a list applies to its
+// elements.
+type fieldMask map[string]fieldMask
+
+// Strips the fields not in the mask from the specified decoded JSON value.
+func (self fieldMask) apply(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			mask, ok := self[key]
+			if !ok {
+				mask, ok = self["*"]
+			}
+			if !ok {
+				delete(v, key)
+			} else if mask != nil {
+				v[key] = mask.apply(field)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = self.apply(v[i])
+		}
+	}
+	return value
+}
+
+// Returns a mask with the fields of both masks.
+func (self fieldMask) union(other fieldMask) fieldMask {
+	mask := make(fieldMask, len(self)+len(other))
+	for key, field := range self {
+		mask[key] = field
+	}
+	for key, field := range other {
+		mask[key] = field
+	}
+	return mask
+}
+
+// Filters the specified JSON through the mask. Numbers are kept as they were
+// so that large counters do not lose precision.
+func (self fieldMask) filter(in []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(in))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(self.apply(value))
+}
+
+// Schemas of the versions of the API whose responses are frozen, by request
+// type. Fields added since are stripped from their responses so that clients
+// written against them do not break. Versions without a schema serve all fields.
+var frozenSchemas = map[string]map[string]fieldMask{
+	"v1.0": {
+		machineApi:    machineInfoMaskV1_0,
+		containersApi: containerInfoMaskV1_0,
+	},
+	"v1.1": {
+		machineApi:       machineInfoMaskV1_1,
+		containersApi:    containerInfoMaskV1_1,
+		subcontainersApi: containerListMask(containerInfoMaskV1_1),
+	},
+	"v1.2": {
+		machineApi:       machineInfoMaskV1_2,
+		containersApi:    containerInfoMaskV1_2,
+		subcontainersApi: containerListMask(containerInfoMaskV1_2),
+		dockerApi:        fieldMask{"*": containerInfoMaskV1_2},
+	},
+}
+
+// Returns the schema the responses to the request type are frozen at in the
+// version, if any. The containers of all the namespaces are served as those of
+// Docker's.
+func getFrozenSchema(m manager.Manager, version, requestType string) (fieldMask, bool) {
+	schemas, ok := frozenSchemas[version]
+	if !ok {
+		return nil, false
+	}
+	if mask, ok := schemas[requestType]; ok {
+		return mask, true
+	}
+	if mask, ok := schemas[dockerApi]; ok && isNamespaceRequestType(m, requestType) {
+		return mask, true
+	}
+	return nil, false
+}
+
+// Subcontainers are either a list of containers or a page of them.
+func containerListMask(containerInfoMask fieldMask) fieldMask {
+	return containerInfoMask.union(fieldMask{
+		"containers": containerInfoMask,
+		"continue":   nil,
+	})
+}
+
+var (
+	machineInfoMaskV1_0 = fieldMask{
+		"num_cores":       nil,
+		"memory_capacity": nil,
+		"filesystems": fieldMask{
+			"device":   nil,
+			"capacity": nil,
+		},
+	}
+	machineInfoMaskV1_1 = machineInfoMaskV1_0.union(fieldMask{
+		"cpu_frequency_khz": nil,
+		"machine_id":        nil,
+		"topology": fieldMask{
+			"node_id": nil,
+			"memory":  nil,
+			"cores": fieldMask{
+				"core_id":    nil,
+				"thread_ids": nil,
+				"caches":     cacheMask,
+			},
+			"caches": cacheMask,
+		},
+	})
+	machineInfoMaskV1_2 = machineInfoMaskV1_1.union(fieldMask{
+		"system_uuid": nil,
+		"disk_map": fieldMask{
+			"*": fieldMask{
+				"name":      nil,
+				"major":     nil,
+				"minor":     nil,
+				"size":      nil,
+				"scheduler": nil,
+			},
+		},
+		"network_devices": fieldMask{
+			"name":        nil,
+			"mac_address": nil,
+			"speed":       nil,
+			"mtu":         nil,
+		},
+		"kernel_version":   nil,
+		"os_image":         nil,
+		"docker_version":   nil,
+		"cadvisor_version": nil,
+	})
+
+	cacheMask = fieldMask{
+		"size":  nil,
+		"type":  nil,
+		"level": nil,
+	}
+)
+
+var (
+	containerInfoMaskV1_0 = fieldMask{
+		"name":          nil,
+		"aliases":       nil,
+		"namespace":     nil,
+		"subcontainers": containerReferenceMask,
+		"spec":          containerSpecMaskV1_0,
+		"stats":         containerStatsMaskV1_0,
+	}
+	containerInfoMaskV1_1 = containerInfoMaskV1_0.union(fieldMask{
+		"spec": containerSpecMaskV1_0.union(fieldMask{
+			"has_diskio": nil,
+		}),
+		"stats": containerStatsMaskV1_0.union(fieldMask{
+			"diskio": fieldMask{
+				"io_service_bytes": perDiskStatsMask,
+				"io_serviced":      perDiskStatsMask,
+				"io_queued":        perDiskStatsMask,
+				"sectors":          perDiskStatsMask,
+				"io_service_time":  perDiskStatsMask,
+				"io_wait_time":     perDiskStatsMask,
+				"io_merged":        perDiskStatsMask,
+				"io_time":          perDiskStatsMask,
+			},
+		}),
+	})
+	containerInfoMaskV1_2 = containerInfoMaskV1_1.union(fieldMask{
+		"spec": containerInfoMaskV1_1["spec"].union(fieldMask{
+			"labels": nil,
+		}),
+		"stats": containerInfoMaskV1_1["stats"].union(fieldMask{
+			"task_stats": fieldMask{
+				"nr_sleeping":        nil,
+				"nr_running":         nil,
+				"nr_stopped":         nil,
+				"nr_uninterruptible": nil,
+				"nr_io_wait":         nil,
+			},
+		}),
+	})
+
+	containerReferenceMask = fieldMask{
+		"name":      nil,
+		"aliases":   nil,
+		"namespace": nil,
+	}
+
+	containerSpecMaskV1_0 = fieldMask{
+		"creation_time": nil,
+		"has_cpu":       nil,
+		"cpu": fieldMask{
+			"limit":     nil,
+			"max_limit": nil,
+			"mask":      nil,
+		},
+		"has_memory": nil,
+		"memory": fieldMask{
+			"limit":       nil,
+			"reservation": nil,
+			"swap_limit":  nil,
+		},
+		"has_network":    nil,
+		"has_filesystem": nil,
+	}
+
+	containerStatsMaskV1_0 = fieldMask{
+		"timestamp": nil,
+		"cpu": fieldMask{
+			"usage": fieldMask{
+				"total":         nil,
+				"per_cpu_usage": nil,
+				"user":          nil,
+				"system":        nil,
+			},
+			"load_average": nil,
+		},
+		"memory": fieldMask{
+			"usage":             nil,
+			"working_set":       nil,
+			"container_data":    memoryDataMask,
+			"hierarchical_data": memoryDataMask,
+		},
+		"network": fieldMask{
+			"rx_bytes":   nil,
+			"rx_packets": nil,
+			"rx_errors":  nil,
+			"rx_dropped": nil,
+			"tx_bytes":   nil,
+			"tx_packets": nil,
+			"tx_errors":  nil,
+			"tx_dropped": nil,
+		},
+		"filesystem": fieldMask{
+			"device":           nil,
+			"capacity":         nil,
+			"usage":            nil,
+			"reads_completed":  nil,
+			"reads_merged":     nil,
+			"sectors_read":     nil,
+			"read_time":        nil,
+			"writes_completed": nil,
+			"writes_merged":    nil,
+			"sectors_written":  nil,
+			"write_time":       nil,
+			"io_in_progress":   nil,
+			"io_time":          nil,
+			"weighted_io_time": nil,
+		},
+	}
+
+	memoryDataMask = fieldMask{
+		"pgfault":    nil,
+		"pgmajfault": nil,
+	}
+
+	perDiskStatsMask = fieldMask{
+		"major": nil,
+		"minor": nil,
+		"stats": nil,
+	}
+)
+
+// Buffers a JSON response to filter it through a mask once it is complete.
+type maskingResponseWriter struct {
+	http.ResponseWriter
+	mask   fieldMask
+	status int
+	body   bytes.Buffer
+}
+
+func newMaskingResponseWriter(w http.ResponseWriter, mask fieldMask) *maskingResponseWriter {
+	return &maskingResponseWriter{
+		ResponseWriter: w,
+		mask:           mask,
+	}
+}
+
+func (self *maskingResponseWriter) WriteHeader(status int) {
+	self.status = status
+}
+
+func (self *maskingResponseWriter) Write(b []byte) (int, error) {
+	return self.body.Write(b)
+}
+
+// Writes the filtered response to the underlying writer. Only JSON responses
+// are filtered, others are written as they are.
+func (self *maskingResponseWriter) flush() error {
+	out := self.body.Bytes()
+	if len(out) != 0 && self.Header().Get("Content-Type") == "application/json" {
+		var err error
+		out, err = self.mask.filter(out)
+		if err != nil {
+			return fmt.Errorf("failed to filter response: %v", err)
+		}
+	}
+	if self.status != 0 {
+		self.ResponseWriter.WriteHeader(self.status)
+	}
+	// Responses such as 304 Not Modified have no body.
+	if len(out) == 0 {
+		return nil
+	}
+	_, err := self.ResponseWriter.Write(out)
+	return err
+}
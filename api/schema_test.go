@@ -0,0 +1,183 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update_golden", false, "Whether to update the golden files of the API schemas instead of comparing against them")
+
+// Sets every exported field reachable from the value to a non-zero value.
+// Slices and maps get a single element.
+func populate(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(0.5)
+	case reflect.String:
+		v.SetString("value")
+	case reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+		populate(v.Elem())
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		populate(v.Index(0))
+	case reflect.Map:
+		v.Set(reflect.MakeMap(v.Type()))
+		key := reflect.New(v.Type().Key()).Elem()
+		populate(key)
+		elem := reflect.New(v.Type().Elem()).Elem()
+		populate(elem)
+		v.SetMapIndex(key, elem)
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(time.Time{}) {
+			v.Set(reflect.ValueOf(time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)))
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				populate(v.Field(i))
+			}
+		}
+	}
+}
+
+// Serializes the value through the schema of the version for the request type.
+func serializeForVersion(t *testing.T, version, requestType string, value interface{}) interface{} {
+	out, err := json.Marshal(value)
+	require.Nil(t, err)
+	if mask, ok := frozenSchemas[version][requestType]; ok {
+		out, err = mask.filter(out)
+		require.Nil(t, err)
+	}
+	var decoded interface{}
+	require.Nil(t, json.Unmarshal(out, &decoded))
+	return decoded
+}
+
+// Changes to the golden files of frozen versions break clients. Run with
+// -update_golden to regenerate them after adding fields to the current version.
+func TestSchemasMatchGoldenFiles(t *testing.T) {
+	var cinfo info.ContainerInfo
+	populate(reflect.ValueOf(&cinfo).Elem())
+	var minfo info.MachineInfo
+	populate(reflect.ValueOf(&minfo).Elem())
+
+	responses := map[string]interface{}{
+		machineApi:       minfo,
+		containersApi:    cinfo,
+		subcontainersApi: []info.ContainerInfo{cinfo},
+		dockerApi:        map[string]info.ContainerInfo{cinfo.Name: cinfo},
+	}
+	for _, v := range getApiVersions(nil) {
+		version := v.Version()
+		if !strings.HasPrefix(version, "v1.") {
+			continue
+		}
+		schema := make(map[string]interface{})
+		for _, requestType := range v.SupportedRequestTypes() {
+			if response, ok := responses[requestType]; ok {
+				schema[requestType] = serializeForVersion(t, version, requestType, response)
+			}
+		}
+		out, err := json.MarshalIndent(schema, "", "  ")
+		require.Nil(t, err)
+		out = append(out, '\n')
+
+		golden := path.Join("testdata", "schema", version+".json")
+		if *updateGolden {
+			require.Nil(t, ioutil.WriteFile(golden, out, 0644))
+			continue
+		}
+		expected, err := ioutil.ReadFile(golden)
+		require.Nil(t, err)
+		if !bytes.Equal(out, expected) {
+			t.Errorf("The schema of API %s does not match %s, got:\n%s", version, golden, out)
+		}
+	}
+}
+
+func TestFieldMaskKeepsLargeNumbers(t *testing.T) {
+	out, err := fieldMask{"usage": nil}.filter([]byte(`{"usage":18446744073709551615,"limit":1}`))
+	require.Nil(t, err)
+	assert.Equal(t, `{"usage":18446744073709551615}`, string(out))
+}
+
+func TestFrozenVersionsStripNewFields(t *testing.T) {
+	mux := http.NewServeMux()
+	m := newMachineInfoManager(&info.MachineInfo{
+		NumCores:      4,
+		KernelVersion: "3.10.0",
+	})
+	require.Nil(t, RegisterHandlers(mux, m))
+
+	get := func(url string) map[string]interface{} {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("GET", url, nil)
+		require.Nil(t, err)
+		mux.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		var fields map[string]interface{}
+		require.Nil(t, json.Unmarshal(w.Body.Bytes(), &fields))
+		return fields
+	}
+
+	fields := get("http://localhost:8080/api/v1.0/machine")
+	assert.Equal(t, float64(4), fields["num_cores"])
+	_, ok := fields["kernel_version"]
+	assert.False(t, ok)
+
+	fields = get("http://localhost:8080/api/v1.3/machine")
+	assert.Equal(t, "3.10.0", fields["kernel_version"])
+}
+
+func TestFrozenVersionsKeepNotModified(t *testing.T) {
+	mux := http.NewServeMux()
+	require.Nil(t, RegisterHandlers(mux, newMachineInfoManager(&info.MachineInfo{NumCores: 4})))
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest("GET", "http://localhost:8080/api/v1.0/machine", nil)
+	require.Nil(t, err)
+	mux.ServeHTTP(w, r)
+	etag := w.Header().Get("ETag")
+	require.NotEqual(t, "", etag)
+
+	w = httptest.NewRecorder()
+	r.Header.Set("If-None-Match", etag)
+	mux.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, 0, w.Body.Len())
+}
@@ -0,0 +1,219 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/google/cadvisor/manager"
+)
+
+const (
+	topMetricCpu     = "cpu"
+	topMetricMemory  = "memory"
+	topMetricNetwork = "network"
+)
+
+// Header of the value column of the text format for each metric.
+var topValueHeaders = map[string]string{
+	topMetricCpu:     "CPU (CORES)",
+	topMetricMemory:  "MEMORY (BYTES)",
+	topMetricNetwork: "NETWORK (BYTES/S)",
+}
+
+func getTopRequest(r *http.Request) (v2.TopRequest, error) {
+	// fill in the defaults.
+	tr := v2.TopRequest{
+		Metric: topMetricCpu,
+		Count:  10,
+	}
+	metric := r.URL.Query().Get("metric")
+	if len(metric) != 0 {
+		if _, ok := topValueHeaders[metric]; !ok {
+			return tr, fmt.Errorf("unknown 'metric' %q", metric)
+		}
+		tr.Metric = metric
+	}
+	count := r.URL.Query().Get("count")
+	if len(count) != 0 {
+		n, err := strconv.ParseUint(count, 10, 32)
+		if err != nil {
+			return tr, fmt.Errorf("failed to parse 'count' option: %v", count)
+		}
+		tr.Count = int(n)
+	}
+	if r.URL.Query().Get("exclude_root") == "true" {
+		tr.ExcludeRoot = true
+	}
+	if r.URL.Query().Get("aggregate") == "true" {
+		tr.Aggregate = true
+	}
+	return tr, nil
+}
+
+// Returns the containers under the specified container (inclusive) using the
+// most of the requested metric. Only the stats cached in memory are used.
+func getTopContainers(m manager.Manager, name string, tr v2.TopRequest) ([]v2.TopContainer, error) {
+	machineInfo, err := m.GetMachineInfo()
+	if err != nil {
+		return nil, err
+	}
+	// Rates are computed from the last two stats.
+	containers, err := m.SubcontainersInfo(name, &info.ContainerInfoRequest{NumStats: 2})
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]float64, len(containers))
+	for _, cont := range containers {
+		if value, ok := topValue(cont, tr.Metric); ok {
+			values[cont.Name] = value
+		}
+	}
+
+	top := make([]v2.TopContainer, 0, len(values))
+	for _, cont := range containers {
+		if tr.ExcludeRoot && cont.Name == name {
+			continue
+		}
+		if tr.Aggregate && cont.Name != name && parentName(cont.Name) != name {
+			continue
+		}
+		value, ok := values[cont.Name]
+		if !ok {
+			continue
+		}
+		// The cgroup usage of a container already includes that of its
+		// subcontainers, the network usage does not.
+		if tr.Aggregate && tr.Metric == topMetricNetwork && cont.Name != name {
+			for subcontainer, subvalue := range values {
+				if strings.HasPrefix(subcontainer, cont.Name+"/") {
+					value += subvalue
+				}
+			}
+		}
+		top = append(top, v2.TopContainer{
+			Name:             cont.Name,
+			Aliases:          cont.Aliases,
+			Value:            value,
+			PercentOfMachine: percentOfMachine(value, tr.Metric, machineInfo),
+		})
+	}
+
+	sort.Sort(topContainerSlice(top))
+	if len(top) > tr.Count {
+		top = top[:tr.Count]
+	}
+	return top, nil
+}
+
+// Returns the most recent value of the metric for the container and whether it has one.
+func topValue(cont *info.ContainerInfo, metric string) (float64, bool) {
+	n := len(cont.Stats)
+	if n == 0 {
+		return 0, false
+	}
+	cur := cont.Stats[n-1]
+	if metric == topMetricMemory {
+		return float64(cur.Memory.WorkingSet), cont.Spec.HasMemory && !cur.Missing(info.StatsSectionMemory)
+	}
+
+	// The remaining metrics are rates.
+	if n < 2 {
+		return 0, false
+	}
+	prev := cont.Stats[n-2]
+	elapsed := cur.Elapsed(prev)
+	if elapsed <= 0 {
+		return 0, false
+	}
+	switch metric {
+	case topMetricCpu:
+		if !cont.Spec.HasCpu || cur.Missing(info.StatsSectionCpu) || prev.Missing(info.StatsSectionCpu) || cur.Cpu.Usage.Total < prev.Cpu.Usage.Total {
+			return 0, false
+		}
+		return float64(cur.Cpu.Usage.Total-prev.Cpu.Usage.Total) / float64(elapsed.Nanoseconds()), true
+	case topMetricNetwork:
+		curBytes := cur.Network.RxBytes + cur.Network.TxBytes
+		prevBytes := prev.Network.RxBytes + prev.Network.TxBytes
+		// Containers sharing the network namespace of another would count its
+		// traffic twice.
+		if !cont.Spec.HasNetwork || cont.Spec.NetworkSharedWith != "" || cur.Missing(info.StatsSectionNetwork) || prev.Missing(info.StatsSectionNetwork) || curBytes < prevBytes {
+			return 0, false
+		}
+		return float64(curBytes-prevBytes) / elapsed.Seconds(), true
+	}
+	return 0, false
+}
+
+func percentOfMachine(value float64, metric string, machineInfo *info.MachineInfo) *float64 {
+	var capacity float64
+	switch metric {
+	case topMetricCpu:
+		capacity = float64(machineInfo.NumCores)
+	case topMetricMemory:
+		capacity = float64(machineInfo.MemoryCapacity)
+	}
+	if capacity == 0 {
+		return nil
+	}
+	percent := value / capacity * 100
+	return &percent
+}
+
+func parentName(name string) string {
+	if i := strings.LastIndex(name, "/"); i > 0 {
+		return name[:i]
+	}
+	return "/"
+}
+
+// Writes the top containers as a plain-text table.
+func writeTopText(top []v2.TopContainer, metric string, w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "text/plain")
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "NAME\tALIASES\t%s\t%% OF MACHINE\n", topValueHeaders[metric])
+	for _, cont := range top {
+		value := fmt.Sprintf("%.0f", cont.Value)
+		if metric == topMetricCpu {
+			value = fmt.Sprintf("%.2f", cont.Value)
+		}
+		percent := "-"
+		if cont.PercentOfMachine != nil {
+			percent = fmt.Sprintf("%.1f", *cont.PercentOfMachine)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", cont.Name, strings.Join(cont.Aliases, ","), value, percent)
+	}
+	return tw.Flush()
+}
+
+// Sorts by decreasing value, then by name.
+type topContainerSlice []v2.TopContainer
+
+func (self topContainerSlice) Len() int      { return len(self) }
+func (self topContainerSlice) Swap(i, j int) { self[i], self[j] = self[j], self[i] }
+func (self topContainerSlice) Less(i, j int) bool {
+	if self[i].Value != self[j].Value {
+		return self[i].Value > self[j].Value
+	}
+	return self[i].Name < self[j].Name
+}
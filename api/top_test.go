@@ -0,0 +1,182 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/google/cadvisor/manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Returns a container which used the specified cores, working set, and network bytes/s over the last second.
+func makeTopContainer(name string, cores float64, workingSet uint64, networkRate uint64, aliases ...string) *info.ContainerInfo {
+	now := time.Now()
+	prev := &info.ContainerStats{Timestamp: now.Add(-time.Second)}
+	cur := &info.ContainerStats{Timestamp: now}
+	cur.Cpu.Usage.Total = uint64(cores * float64(time.Second))
+	cur.Memory.WorkingSet = workingSet
+	cur.Network.RxBytes = networkRate
+	return &info.ContainerInfo{
+		ContainerReference: info.ContainerReference{
+			Name:    name,
+			Aliases: aliases,
+		},
+		Spec: info.ContainerSpec{
+			HasCpu:     true,
+			HasMemory:  true,
+			HasNetwork: true,
+		},
+		Stats: []*info.ContainerStats{prev, cur},
+	}
+}
+
+func topContainers() []*info.ContainerInfo {
+	return []*info.ContainerInfo{
+		makeTopContainer("/", 3, 800, 0),
+		makeTopContainer("/docker", 2, 500, 0),
+		makeTopContainer("/docker/web", 1.5, 100, 300, "web"),
+		makeTopContainer("/docker/db", 0.5, 400, 100, "db"),
+		makeTopContainer("/system", 0.25, 200, 50),
+	}
+}
+
+// Returns a manager serving the specified containers, with their last two
+// stats, on a machine with 4 cores and 1000 bytes of memory.
+func newTopManager(containers []*info.ContainerInfo) *manager.ManagerMock {
+	m := &manager.ManagerMock{}
+	m.On("GetMachineInfo").Return(&info.MachineInfo{
+		NumCores:       4,
+		MemoryCapacity: 1000,
+	}, nil)
+	for _, parent := range containers {
+		var subcontainers []*info.ContainerInfo
+		for _, cont := range containers {
+			if parent.Name == "/" || cont.Name == parent.Name || strings.HasPrefix(cont.Name, parent.Name+"/") {
+				subcontainers = append(subcontainers, cont)
+			}
+		}
+		m.On("SubcontainersInfo", parent.Name, &info.ContainerInfoRequest{NumStats: 2}).Return(subcontainers, nil)
+	}
+	return m
+}
+
+func topNames(top []v2.TopContainer) []string {
+	names := make([]string, 0, len(top))
+	for _, cont := range top {
+		names = append(names, cont.Name)
+	}
+	return names
+}
+
+func TestTopByCpu(t *testing.T) {
+	top, err := getTopContainers(newTopManager(topContainers()), "/", v2.TopRequest{Metric: topMetricCpu, Count: 3})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"/", "/docker", "/docker/web"}, topNames(top))
+	assert.Equal(t, []string{"web"}, top[2].Aliases)
+	assert.InDelta(t, 1.5, top[2].Value, 0.001)
+	require.NotNil(t, top[2].PercentOfMachine)
+	assert.InDelta(t, 37.5, *top[2].PercentOfMachine, 0.001)
+}
+
+func TestTopByMemoryExcludingRoot(t *testing.T) {
+	top, err := getTopContainers(newTopManager(topContainers()), "/", v2.TopRequest{Metric: topMetricMemory, Count: 2, ExcludeRoot: true})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"/docker", "/docker/db"}, topNames(top))
+	require.NotNil(t, top[0].PercentOfMachine)
+	assert.InDelta(t, 50, *top[0].PercentOfMachine, 0.001)
+}
+
+func TestTopByNetworkAggregated(t *testing.T) {
+	top, err := getTopContainers(newTopManager(topContainers()), "/", v2.TopRequest{Metric: topMetricNetwork, Count: 10, ExcludeRoot: true, Aggregate: true})
+	require.Nil(t, err)
+
+	// The network usage of /docker includes that of its subcontainers.
+	assert.Equal(t, []string{"/docker", "/system"}, topNames(top))
+	assert.InDelta(t, 400, top[0].Value, 0.001)
+	assert.Nil(t, top[0].PercentOfMachine)
+}
+
+func TestTopByNetworkSkipsSharedNamespaces(t *testing.T) {
+	// Reports the traffic of web's network namespace which it joined.
+	sidecar := makeTopContainer("/docker/sidecar", 0.1, 10, 300, "sidecar")
+	sidecar.Spec.NetworkSharedWith = "/docker/web"
+	m := newTopManager(append(topContainers(), sidecar))
+
+	top, err := getTopContainers(m, "/", v2.TopRequest{Metric: topMetricNetwork, Count: 10, ExcludeRoot: true, Aggregate: true})
+	require.Nil(t, err)
+	require.Equal(t, "/docker", top[0].Name)
+	assert.InDelta(t, 400, top[0].Value, 0.001)
+
+	top, err = getTopContainers(m, "/docker", v2.TopRequest{Metric: topMetricNetwork, Count: 10})
+	require.Nil(t, err)
+	assert.NotContains(t, topNames(top), "/docker/sidecar")
+}
+
+func TestTopOfSubcontainer(t *testing.T) {
+	top, err := getTopContainers(newTopManager(topContainers()), "/docker", v2.TopRequest{Metric: topMetricCpu, Count: 10, Aggregate: true})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"/docker", "/docker/web", "/docker/db"}, topNames(top))
+}
+
+func TestTopSkipsContainersWithoutRates(t *testing.T) {
+	containers := topContainers()
+	containers[1].Stats = containers[1].Stats[1:]
+	m := newTopManager(containers)
+	top, err := getTopContainers(m, "/", v2.TopRequest{Metric: topMetricCpu, Count: 10})
+	require.Nil(t, err)
+	assert.NotContains(t, topNames(top), "/docker")
+}
+
+func TestGetTopRequest(t *testing.T) {
+	tr, err := getTopRequest(makeHTTPRequest("http://localhost:8080/api/v2.0/top/?metric=memory&count=3&exclude_root=true&aggregate=true", t))
+	require.Nil(t, err)
+	assert.Equal(t, v2.TopRequest{Metric: topMetricMemory, Count: 3, ExcludeRoot: true, Aggregate: true}, tr)
+
+	tr, err = getTopRequest(makeHTTPRequest("http://localhost:8080/api/v2.0/top/", t))
+	require.Nil(t, err)
+	assert.Equal(t, v2.TopRequest{Metric: topMetricCpu, Count: 10}, tr)
+
+	_, err = getTopRequest(makeHTTPRequest("http://localhost:8080/api/v2.0/top/?metric=disk", t))
+	assert.NotNil(t, err)
+}
+
+func TestTopApi(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := makeHTTPRequest("http://localhost:8080/api/v2.0/top/?metric=memory&count=1", t)
+	require.Nil(t, (&version2_0{}).HandleRequest(topApi, []string{}, newTopManager(topContainers()), w, r))
+
+	var top []v2.TopContainer
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &top))
+	assert.Equal(t, []string{"/"}, topNames(top))
+}
+
+func TestTopApiTextFormat(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := makeHTTPRequest("http://localhost:8080/api/v2.0/top/docker?metric=cpu&format=text", t)
+	require.Nil(t, (&version2_0{}).HandleRequest(topApi, []string{"docker"}, newTopManager(topContainers()), w, r))
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	require.Equal(t, 4, len(lines))
+	assert.Equal(t, []string{"NAME", "ALIASES", "CPU", "(CORES)", "%", "OF", "MACHINE"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"/docker/web", "web", "1.50", "37.5"}, strings.Fields(lines[2]))
+}
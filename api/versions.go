@@ -17,11 +17,13 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/golang/glog"
-	"github.com/google/cadvisor/events"
+	"github.com/google/cadvisor/http/instrument"
 	info "github.com/google/cadvisor/info/v1"
 	"github.com/google/cadvisor/info/v2"
 	"github.com/google/cadvisor/manager"
@@ -39,6 +41,14 @@ const (
 	storageApi       = "storage"
 	attributesApi    = "attributes"
 	versionApi       = "version"
+	statusApi        = "status"
+	topApi           = "top"
+	requestsApi      = "requests"
+	snapshotApi      = "snapshot"
+	debugApi         = "debug"
+	deletedApi       = "deleted"
+	namespacesApi    = "namespaces"
+	diffRequest      = "diff"
 	typeName         = "name"
 	typeDocker       = "docker"
 )
@@ -55,13 +65,15 @@ type ApiVersion interface {
 	HandleRequest(requestType string, request []string, m manager.Manager, w http.ResponseWriter, r *http.Request) error
 }
 
-// Gets all supported API versions.
-func getApiVersions() []ApiVersion {
+// Gets all supported API versions. Those whose version API reports features
+// report the specified ones, by name (e.g.: v2.FeatureReadOnly).
+func getApiVersions(features map[string]bool) []ApiVersion {
 	v1_0 := &version1_0{}
 	v1_1 := newVersion1_1(v1_0)
 	v1_2 := newVersion1_2(v1_1)
 	v1_3 := newVersion1_3(v1_2)
 	v2_0 := newVersion2_0(v1_3)
+	v2_0.features = features
 
 	return []ApiVersion{v1_0, v1_1, v1_2, v1_3, v2_0}
 
@@ -91,7 +103,7 @@ func (self *version1_0) HandleRequest(requestType string, request []string, m ma
 			return err
 		}
 
-		err = writeResult(machineInfo, w)
+		err = writeResultWithETag(machineInfo, w, r)
 		if err != nil {
 			return err
 		}
@@ -99,14 +111,32 @@ func (self *version1_0) HandleRequest(requestType string, request []string, m ma
 		containerName := getContainerName(request)
 		glog.V(2).Infof("Api - Container(%s)", containerName)
 
+		// The ancestors of the container replace its information.
+		if r.URL.Query().Get("include_ancestors") == "true" {
+			ancestry, err := m.GetContainerAncestry(containerName)
+			if err != nil {
+				return fmt.Errorf("failed to get the ancestors of container %q with error: %s", containerName, err)
+			}
+			return writeResult(ancestry, w)
+		}
+
 		// Get the query request.
-		query, err := getContainerInfoRequest(r.Body)
+		query, err := getContainerInfoRequest(r)
 		if err != nil {
 			return err
 		}
 
-		// Get the container.
-		cont, err := m.GetContainerInfo(containerName, query)
+		// Get the container, collecting its stats right away if asked to.
+		var cont *info.ContainerInfo
+		if r.URL.Query().Get("collect") == "true" {
+			cont, err = m.CollectContainerInfo(containerName, query)
+			if rateLimited, ok := err.(*manager.RateLimitedError); ok {
+				writeRateLimited(rateLimited, w)
+				return nil
+			}
+		} else {
+			cont, err = m.GetContainerInfo(containerName, query)
+		}
 		if err != nil {
 			return fmt.Errorf("failed to get container %q with error: %s", containerName, err)
 		}
@@ -150,19 +180,46 @@ func (self *version1_1) HandleRequest(requestType string, request []string, m ma
 		glog.V(2).Infof("Api - Subcontainers(%s)", containerName)
 
 		// Get the query request.
-		query, err := getContainerInfoRequest(r.Body)
+		query, err := getContainerInfoRequest(r)
+		if err != nil {
+			return err
+		}
+		pr, err := getPageRequest(r)
+		if err != nil {
+			return err
+		}
+		filter, err := getContainerFilter(r)
+		if err != nil {
+			return err
+		}
+		minFreshness, err := getMinFreshness(r)
 		if err != nil {
 			return err
 		}
+		if pr != nil {
+			page, err := getSubcontainersPage(m, containerName, query, filter, pr)
+			if err != nil {
+				return fmt.Errorf("failed to get subcontainers for container %q with error: %s", containerName, err)
+			}
+			page.Containers = filterFreshValues(page.Containers, minFreshness)
+			return writeResult(page, w)
+		}
+		if filter != nil {
+			containers, err := getFilteredSubcontainers(m, containerName, query, filter)
+			if err != nil {
+				return fmt.Errorf("failed to get subcontainers for container %q with error: %s", containerName, err)
+			}
+			return writeResult(filterFreshValues(containers, minFreshness), w)
+		}
 
 		// Get the subcontainers.
-		containers, err := m.SubcontainersInfo(containerName, query)
+		containers, err := getSubcontainersWithMeta(m, containerName, query, r)
 		if err != nil {
 			return fmt.Errorf("failed to get subcontainers for container %q with error: %s", containerName, err)
 		}
 
 		// Only output the containers as JSON.
-		err = writeResult(containers, w)
+		err = writeResult(filterFresh(containers, minFreshness), w)
 		if err != nil {
 			return err
 		}
@@ -193,52 +250,13 @@ func (self *version1_2) SupportedRequestTypes() []string {
 	return append(self.baseVersion.SupportedRequestTypes(), dockerApi)
 }
 
+// Besides Docker's, v1.2 serves the namespaces of all the registered factories
+// (e.g.: /api/v1.2/<namespace>/<name>).
 func (self *version1_2) HandleRequest(requestType string, request []string, m manager.Manager, w http.ResponseWriter, r *http.Request) error {
-	switch requestType {
-	case dockerApi:
-		glog.V(2).Infof("Api - Docker(%v)", request)
-
-		// Get the query request.
-		query, err := getContainerInfoRequest(r.Body)
-		if err != nil {
-			return err
-		}
-
-		var containers map[string]info.ContainerInfo
-		// map requests for "docker/" to "docker"
-		if len(request) == 1 && len(request[0]) == 0 {
-			request = request[:0]
-		}
-		switch len(request) {
-		case 0:
-			// Get all Docker containers.
-			containers, err = m.AllDockerContainers(query)
-			if err != nil {
-				return fmt.Errorf("failed to get all Docker containers with error: %v", err)
-			}
-		case 1:
-			// Get one Docker container.
-			var cont info.ContainerInfo
-			cont, err = m.DockerContainer(request[0], query)
-			if err != nil {
-				return fmt.Errorf("failed to get Docker container %q with error: %v", request[0], err)
-			}
-			containers = map[string]info.ContainerInfo{
-				cont.Name: cont,
-			}
-		default:
-			return fmt.Errorf("unknown request for Docker container %v", request)
-		}
-
-		// Only output the containers as JSON.
-		err = writeResult(containers, w)
-		if err != nil {
-			return err
-		}
-		return nil
-	default:
-		return self.baseVersion.HandleRequest(requestType, request, m, w, r)
+	if isNamespaceRequestType(m, requestType) {
+		return handleNamespaceRequest(requestType, request, m, w, r)
 	}
+	return self.baseVersion.HandleRequest(requestType, request, m, w, r)
 }
 
 // API v1.3
@@ -275,14 +293,19 @@ func (self *version1_3) HandleRequest(requestType string, request []string, m ma
 			if err != nil {
 				return err
 			}
+			pastEvents, err = linkTombstones(m, pastEvents)
+			if err != nil {
+				return err
+			}
 			return writeResult(pastEvents, w)
 		}
-		eventsChannel := make(chan *events.Event, 10)
-		err = m.WatchForEvents(query, eventsChannel)
+		// One watch per connection, stopped once it is closed.
+		eventChannel, err := m.WatchForEvents(query)
 		if err != nil {
-			return err
+			return badRequestError{err}
 		}
-		return streamResults(eventsChannel, w, r)
+		defer m.CloseEventChannel(eventChannel.GetWatchId())
+		return streamResults(eventChannel.GetChannel(), w, r)
 	default:
 		return self.baseVersion.HandleRequest(requestType, request, m, w, r)
 	}
@@ -293,6 +316,9 @@ func (self *version1_3) HandleRequest(requestType string, request []string, m ma
 // v2.0 builds on v1.3
 type version2_0 struct {
 	baseVersion *version1_3
+
+	// Reported by the version API.
+	features map[string]bool
 }
 
 func newVersion2_0(v *version1_3) *version2_0 {
@@ -306,7 +332,7 @@ func (self *version2_0) Version() string {
 }
 
 func (self *version2_0) SupportedRequestTypes() []string {
-	return append(self.baseVersion.SupportedRequestTypes(), summaryApi)
+	return append(self.baseVersion.SupportedRequestTypes(), summaryApi, topApi, requestsApi, snapshotApi, debugApi, deletedApi, namespacesApi)
 }
 
 func (self *version2_0) HandleRequest(requestType string, request []string, m manager.Manager, w http.ResponseWriter, r *http.Request) error {
@@ -317,7 +343,24 @@ func (self *version2_0) HandleRequest(requestType string, request []string, m ma
 		if err != nil {
 			return err
 		}
-		return writeResult(versionInfo.CadvisorVersion, w)
+		return writeResult(v2.VersionInfo{
+			CadvisorVersion: versionInfo.CadvisorVersion,
+			Features:        self.features,
+		}, w)
+	case containersApi:
+		if len(request) != 1 || request[0] != diffRequest {
+			return self.baseVersion.HandleRequest(requestType, request, m, w, r)
+		}
+		since, err := getDiffRevision(r)
+		if err != nil {
+			return err
+		}
+		glog.V(2).Infof("Api - Containers diff since revision %d", since)
+		diff, err := m.GetContainerDiff(since)
+		if err != nil {
+			return err
+		}
+		return writeResult(diff, w)
 	case attributesApi:
 		glog.V(2).Info("Api - Attributes")
 
@@ -339,7 +382,7 @@ func (self *version2_0) HandleRequest(requestType string, request []string, m ma
 		if err != nil {
 			return err
 		}
-		return writeResult(machineInfo, w)
+		return writeResultWithETag(machineInfo, w, r)
 	case summaryApi:
 		containerName := getContainerName(request)
 		glog.V(2).Infof("Api - Summary(%v)", containerName)
@@ -356,6 +399,20 @@ func (self *version2_0) HandleRequest(requestType string, request []string, m ma
 		if err != nil {
 			return err
 		}
+		pr, err := getPageRequest(r)
+		if err != nil {
+			return err
+		}
+		if pr != nil && !sr.Recursive {
+			return fmt.Errorf("'limit' and 'continue' options are only supported on recursive requests")
+		}
+		filter, err := getContainerFilter(r)
+		if err != nil {
+			return err
+		}
+		if filter != nil && !sr.Recursive {
+			return badRequestError{fmt.Errorf("'name_re', 'alias_re' and 'label' options are only supported on recursive requests")}
+		}
 		glog.V(2).Infof("Api - Stats: Looking for stats for container %q, options %+v", name, sr)
 		query := info.ContainerInfoRequest{
 			NumStats: sr.Count,
@@ -368,14 +425,31 @@ func (self *version2_0) HandleRequest(requestType string, request []string, m ma
 				if err != nil {
 					return fmt.Errorf("failed to get container %q: %v", name, err)
 				}
-				contStats[name] = convertStats(cont)
+				contStats[name] = convertStats(cont, sr.IncludeDerived)
+			} else if pr != nil {
+				page, err := getSubcontainersPage(m, name, &query, filter, pr)
+				if err != nil {
+					return fmt.Errorf("failed to get subcontainers for container %q with error: %s", name, err)
+				}
+				for i := range page.Containers {
+					contStats[page.Containers[i].Name] = convertStats(&page.Containers[i], sr.IncludeDerived)
+				}
+				return writeResult(v2.StatsPage{Stats: contStats, Continue: page.Continue}, w)
+			} else if filter != nil {
+				containers, err := getFilteredSubcontainers(m, name, &query, filter)
+				if err != nil {
+					return fmt.Errorf("failed to get subcontainers for container %q with error: %s", name, err)
+				}
+				for i := range containers {
+					contStats[containers[i].Name] = convertStats(&containers[i], sr.IncludeDerived)
+				}
 			} else {
-				containers, err := m.SubcontainersInfo(name, &query)
+				containers, err := getSubcontainersWithMeta(m, name, &query, r)
 				if err != nil {
 					return fmt.Errorf("failed to get subcontainers for container %q with error: %s", name, err)
 				}
 				for _, cont := range containers {
-					contStats[cont.Name] = convertStats(cont)
+					contStats[cont.Name] = convertStats(cont, sr.IncludeDerived)
 				}
 			}
 			return writeResult(contStats, w)
@@ -386,12 +460,27 @@ func (self *version2_0) HandleRequest(requestType string, request []string, m ma
 				if sr.Recursive == false {
 					return fmt.Errorf("unknown Docker container %q", name)
 				}
-				containers, err := m.AllDockerContainers(&query)
+				if pr != nil {
+					page, err := getNamespacedContainersPage(m, dockerApi, &query, filter, pr)
+					if err != nil {
+						return fmt.Errorf("failed to get all docker containers: %v", err)
+					}
+					for i := range page.Containers {
+						contStats[page.Containers[i].Name] = convertStats(&page.Containers[i], sr.IncludeDerived)
+					}
+					return writeResult(v2.StatsPage{Stats: contStats, Continue: page.Continue}, w)
+				}
+				var containers map[string]info.ContainerInfo
+				if filter != nil {
+					containers, err = getFilteredNamespacedContainers(m, dockerApi, &query, filter)
+				} else {
+					containers, err = m.AllDockerContainers(&query)
+				}
 				if err != nil {
 					return fmt.Errorf("failed to get all docker containers: %v", err)
 				}
 				for name, cont := range containers {
-					contStats[name] = convertStats(&cont)
+					contStats[name] = convertStats(&cont, sr.IncludeDerived)
 				}
 			} else {
 				name = strings.TrimPrefix(name, "/")
@@ -399,12 +488,35 @@ func (self *version2_0) HandleRequest(requestType string, request []string, m ma
 				if err != nil {
 					return fmt.Errorf("failed to get Docker container %q with error: %v", name, err)
 				}
-				contStats[cont.Name] = convertStats(&cont)
+				contStats[cont.Name] = convertStats(&cont, sr.IncludeDerived)
 			}
 			return writeResult(contStats, w)
 		default:
 			return fmt.Errorf("unknown id type %q for container name %q", sr.IdType, name)
 		}
+	case topApi:
+		containerName := getContainerName(request)
+		tr, err := getTopRequest(r)
+		if err != nil {
+			return err
+		}
+		glog.V(2).Infof("Api - Top(%v, %+v)", containerName, tr)
+		top, err := getTopContainers(m, containerName, tr)
+		if err != nil {
+			return err
+		}
+		if r.URL.Query().Get("format") == "text" {
+			return writeTopText(top, tr.Metric, w)
+		}
+		return writeResult(top, w)
+	case statusApi:
+		containerName := getContainerName(request)
+		glog.V(2).Infof("Api - Status(%v)", containerName)
+		status, err := m.GetContainerCollectionStatus(containerName)
+		if err != nil {
+			return err
+		}
+		return writeResult(status, w)
 	case specApi:
 		containerName := getContainerName(request)
 		glog.V(2).Infof("Api - Spec(%v)", containerName)
@@ -412,7 +524,63 @@ func (self *version2_0) HandleRequest(requestType string, request []string, m ma
 		if err != nil {
 			return err
 		}
-		return writeResult(spec, w)
+		return writeResultWithETag(spec, w, r)
+	case snapshotApi:
+		containerName := getContainerName(request)
+		glog.V(2).Infof("Api - Snapshot(%v)", containerName)
+		containers, cut, err := m.GetSnapshot(containerName)
+		if err != nil {
+			return err
+		}
+		return writeResult(newSnapshot(containers, cut), w)
+	case requestsApi:
+		glog.V(2).Info("Api - Requests")
+		return writeResult(instrument.DefaultRecorder.Requests(), w)
+	case deletedApi:
+		// Lists the deleted containers without a name, or returns the one with
+		// the name: /deleted/<name>
+		if len(request) == 0 {
+			glog.V(2).Info("Api - Deleted containers")
+			deleted, err := m.GetDeletedContainers()
+			if err != nil {
+				return err
+			}
+			return writeResult(deleted, w)
+		}
+		containerName := getContainerName(request)
+		glog.V(2).Infof("Api - Deleted container(%v)", containerName)
+		deleted, err := m.GetDeletedContainer(containerName)
+		if err != nil {
+			return err
+		}
+		return writeResult(deleted, w)
+	case namespacesApi:
+		glog.V(2).Info("Api - Namespaces")
+		namespaces, err := m.GetNamespaces()
+		if err != nil {
+			return err
+		}
+		return writeResult(namespaces, w)
+	case debugApi:
+		// The only debug request is for the diagnostic bundle of a container:
+		// /debug/container/<name>
+		if len(request) == 0 || request[0] != "container" {
+			return badRequestError{fmt.Errorf("unknown debug request %q, expected debug/container/<container>", strings.Join(request, "/"))}
+		}
+		containerName := getContainerName(request[1:])
+		glog.V(2).Infof("Api - Diagnostics(%v)", containerName)
+		bundle, err := getDiagnosticBundle(m, containerName)
+		if err != nil {
+			return err
+		}
+		switch format := r.URL.Query().Get("format"); format {
+		case "", "json":
+			return writeResult(bundle, w)
+		case "tar":
+			return writeDiagnosticTar(bundle, w)
+		default:
+			return badRequestError{fmt.Errorf("unknown format %q, expected json or tar", format)}
+		}
 	case storageApi:
 		var err error
 		fi := []v2.FsInfo{}
@@ -436,9 +604,9 @@ func (self *version2_0) HandleRequest(requestType string, request []string, m ma
 	}
 }
 
-func convertStats(cont *info.ContainerInfo) []v2.ContainerStats {
+func convertStats(cont *info.ContainerInfo, includeDerived bool) []v2.ContainerStats {
 	stats := []v2.ContainerStats{}
-	for _, val := range cont.Stats {
+	for i, val := range cont.Stats {
 		stat := v2.ContainerStats{
 			Timestamp:     val.Timestamp,
 			HasCpu:        cont.Spec.HasCpu,
@@ -446,6 +614,9 @@ func convertStats(cont *info.ContainerInfo) []v2.ContainerStats {
 			HasNetwork:    cont.Spec.HasNetwork,
 			HasFilesystem: cont.Spec.HasFilesystem,
 			HasDiskIo:     cont.Spec.HasDiskIo,
+			HasPressure:   cont.Spec.HasPressure,
+			HasProcess:    cont.Spec.HasProcess,
+			HasPids:       cont.Spec.HasPids,
 		}
 		if stat.HasCpu {
 			stat.Cpu = val.Cpu
@@ -463,12 +634,55 @@ func convertStats(cont *info.ContainerInfo) []v2.ContainerStats {
 		if stat.HasDiskIo {
 			stat.DiskIo = val.DiskIo
 		}
+		stat.MachineDisks = val.MachineDisks
+		stat.SystemHealth = val.SystemHealth
+		stat.MachineStats = val.MachineStats
+		if stat.HasPressure {
+			stat.Pressure = val.Pressure
+		}
+		if stat.HasProcess {
+			stat.Process = val.Process
+		}
+		if stat.HasPids {
+			stat.Pids = val.Pids
+		}
+		stat.ProcessCpu = val.ProcessCpu
+		stat.ProcessStates = val.ProcessStates
+		stat.ContainerCounts = val.ContainerCounts
 		// TODO(rjnagal): Handle load stats.
+		if includeDerived {
+			if stat.HasMemory {
+				stat.MemoryUtilization = cont.Spec.MemoryUtilization(val)
+			}
+			if stat.HasCpu && i > 0 {
+				stat.CpuUtilization = cont.Spec.CpuUtilization(cont.Stats[i-1], val)
+				stat.CpusetUtilization = cont.Spec.CpusetUtilization(cont.Stats[i-1], val)
+				stat.PerCpuUsageRates = info.PerCpuUsageRates(cont.Stats[i-1], val)
+			}
+		}
 		stats = append(stats, stat)
 	}
 	return stats
 }
 
+// Converts the containers with their stats at the cut, if any, to a snapshot.
+func newSnapshot(containers []*info.ContainerInfo, cut time.Time) v2.Snapshot {
+	snapshot := v2.Snapshot{
+		Timestamp: cut,
+		Stats:     make(map[string]v2.ContainerStats, len(containers)),
+	}
+	for _, cont := range containers {
+		stats := convertStats(cont, false)
+		if len(stats) == 0 {
+			snapshot.NoData = append(snapshot.NoData, cont.Name)
+			continue
+		}
+		snapshot.Stats[cont.Name] = stats[len(stats)-1]
+	}
+	sort.Strings(snapshot.NoData)
+	return snapshot
+}
+
 func getStatsRequest(id string, r *http.Request) (v2.StatsRequest, error) {
 	supportedTypes := map[string]bool{
 		typeName:   true,
@@ -499,5 +713,22 @@ func getStatsRequest(id string, r *http.Request) (v2.StatsRequest, error) {
 	if recursive == "true" {
 		sr.Recursive = true
 	}
+	if r.URL.Query().Get("include_derived") == "true" {
+		sr.IncludeDerived = true
+	}
 	return sr, nil
 }
+
+// Returns the revision of the container listing requested through the "since"
+// option of a diff request.
+func getDiffRevision(r *http.Request) (uint64, error) {
+	since := r.URL.Query().Get("since")
+	if len(since) == 0 {
+		return 0, fmt.Errorf("missing 'since' option")
+	}
+	revision, err := strconv.ParseUint(since, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse 'since' option: %v", since)
+	}
+	return revision, nil
+}
@@ -15,13 +15,23 @@
 package api
 
 import (
+	"encoding/json"
 	"io"
+	"math"
 	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/google/cadvisor/manager"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // returns an http.Request pointer for an input url test string
@@ -79,3 +89,325 @@ func TestGetEventRequestDoubleArgument(t *testing.T) {
 	assert.True(t, getHistoricalEvents)
 	assert.Nil(t, err)
 }
+
+func TestGetEventRequestNameGlob(t *testing.T) {
+	r := makeHTTPRequest("http://localhost:8080/api/v1.3/events?oom_events=true&name_glob=/docker/*", t)
+	receivedQuery, _, err := getEventRequest(r)
+	require.Nil(t, err)
+	assert.Equal(t, "/docker/*", receivedQuery.ContainerGlob)
+
+	_, _, err = getEventRequest(makeHTTPRequest("http://localhost:8080/api/v1.3/events?name_glob=/docker/[", t))
+	_, ok := err.(badRequestError)
+	assert.True(t, ok, "error %v", err)
+}
+
+// Each connection streaming events has its own watch, stopped once it closes.
+func TestEventStreamsHaveOwnWatch(t *testing.T) {
+	eventManager := events.NewEventManager()
+	m := &manager.ManagerMock{}
+	mux := http.NewServeMux()
+	require.Nil(t, RegisterHandlers(mux, m))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var streams []*http.Response
+	for _, glob := range []string{"/docker/*", "/system/*"} {
+		url := server.URL + "/api/v1.3/events?oom_events=true&name_glob=" + glob
+		request, _, err := getEventRequest(makeHTTPRequest(url, t))
+		require.Nil(t, err)
+		eventChannel, err := eventManager.WatchEvents(request)
+		require.Nil(t, err)
+		m.On("WatchForEvents", request).Return(eventChannel, nil)
+		m.On("CloseEventChannel", eventChannel.GetWatchId()).Return()
+
+		resp, err := http.Get(url)
+		require.Nil(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		streams = append(streams, resp)
+	}
+
+	eventManager.AddEvent(&events.Event{ContainerName: "/system/b", EventType: events.TypeOom})
+	eventManager.AddEvent(&events.Event{ContainerName: "/docker/a", EventType: events.TypeOom})
+	for i, expected := range []string{"/docker/a", "/system/b"} {
+		var ev events.Event
+		require.Nil(t, json.NewDecoder(streams[i].Body).Decode(&ev))
+		assert.Equal(t, expected, ev.ContainerName)
+	}
+
+	for _, resp := range streams {
+		resp.Body.Close()
+	}
+	// Waits for the streams to be done.
+	server.Close()
+	m.AssertExpectations(t)
+}
+
+// Returns a manager that only serves the specified machine information and
+// version information.
+func newMachineInfoManager(machineInfo *info.MachineInfo) *manager.ManagerMock {
+	m := &manager.ManagerMock{}
+	m.On("GetMachineInfo").Return(machineInfo, nil)
+	m.On("GetVersionInfo").Return(&info.VersionInfo{CadvisorVersion: "0.16.0"}, nil)
+	m.On("GetNamespaces").Return([]v2.Namespace{}, nil)
+	return m
+}
+
+func TestMachineApiIncludesSystemIdentification(t *testing.T) {
+	m := newMachineInfoManager(&info.MachineInfo{
+		NumCores:            4,
+		KernelVersion:       "3.10.0",
+		OSImage:             "CoreOS 681.2.0",
+		DockerVersion:       "1.7.1",
+		DockerStorageDriver: "overlay",
+		CadvisorVersion:     "0.16.0",
+	})
+	for _, api := range []ApiVersion{&version1_0{}, newVersion2_0(newVersion1_3(newVersion1_2(newVersion1_1(&version1_0{}))))} {
+		w := httptest.NewRecorder()
+		err := api.HandleRequest(machineApi, []string{}, m, w, makeHTTPRequest("http://localhost:8080/api/"+api.Version()+"/machine", t))
+		assert.Nil(t, err)
+
+		var fields map[string]interface{}
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &fields))
+		assert.Equal(t, "3.10.0", fields["kernel_version"])
+		assert.Equal(t, "CoreOS 681.2.0", fields["os_image"])
+		assert.Equal(t, "1.7.1", fields["docker_version"])
+		assert.Equal(t, "overlay", fields["docker_storage_driver"])
+		assert.Equal(t, "0.16.0", fields["cadvisor_version"])
+	}
+}
+
+func TestMachineApiOmitsDockerWhenNotPresent(t *testing.T) {
+	m := newMachineInfoManager(&info.MachineInfo{
+		KernelVersion: "3.10.0",
+	})
+	w := httptest.NewRecorder()
+	err := (&version1_0{}).HandleRequest(machineApi, []string{}, m, w, makeHTTPRequest("http://localhost:8080/api/v1.0/machine", t))
+	assert.Nil(t, err)
+
+	var fields map[string]interface{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &fields))
+	assert.Equal(t, "3.10.0", fields["kernel_version"])
+	_, ok := fields["docker_version"]
+	assert.False(t, ok)
+	_, ok = fields["docker_storage_driver"]
+	assert.False(t, ok)
+}
+
+func TestGetStatsRequestIncludeDerived(t *testing.T) {
+	sr, err := getStatsRequest("/", makeHTTPRequest("http://localhost:8080/api/v2.0/stats/?include_derived=true", t))
+	assert.Nil(t, err)
+	assert.True(t, sr.IncludeDerived)
+
+	sr, err = getStatsRequest("/", makeHTTPRequest("http://localhost:8080/api/v2.0/stats/", t))
+	assert.Nil(t, err)
+	assert.False(t, sr.IncludeDerived)
+}
+
+func TestGetContainerInfoRequestIncludeMachine(t *testing.T) {
+	cases := []struct {
+		url      string
+		body     string
+		expected bool
+	}{
+		{"http://localhost:8080/api/v1.0/containers/", `{"num_stats":2}`, false},
+		{"http://localhost:8080/api/v1.0/containers/?include_machine=true", `{"num_stats":2}`, true},
+		{"http://localhost:8080/api/v1.0/containers/", `{"num_stats":2,"include_machine":true}`, true},
+	}
+	for _, c := range cases {
+		r, err := http.NewRequest("POST", c.url, strings.NewReader(c.body))
+		assert.Nil(t, err)
+		query, err := getContainerInfoRequest(r)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, query.NumStats)
+		assert.Equal(t, c.expected, query.IncludeMachine, "include_machine of %q with body %s", c.url, c.body)
+	}
+}
+
+func TestGetContainerInfoRequestResolution(t *testing.T) {
+	cases := []struct {
+		url      string
+		body     string
+		expected time.Duration
+	}{
+		{"http://localhost:8080/api/v1.3/containers/", `{"num_stats":2}`, 0},
+		{"http://localhost:8080/api/v1.3/containers/?resolution=72s", `{"num_stats":2}`, 72 * time.Second},
+		{"http://localhost:8080/api/v1.3/containers/", `{"num_stats":2,"resolution":60000000000}`, time.Minute},
+	}
+	for _, c := range cases {
+		r, err := http.NewRequest("POST", c.url, strings.NewReader(c.body))
+		assert.Nil(t, err)
+		query, err := getContainerInfoRequest(r)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, query.Resolution, "resolution of %q with body %s", c.url, c.body)
+	}
+
+	for _, resolution := range []string{"often", "-1m"} {
+		r, err := http.NewRequest("GET", "http://localhost:8080/api/v1.3/containers/?resolution="+resolution, strings.NewReader(""))
+		assert.Nil(t, err)
+		_, err = getContainerInfoRequest(r)
+		assert.NotNil(t, err, "resolution %q", resolution)
+	}
+}
+
+func TestConvertStatsIncludeDerived(t *testing.T) {
+	ct := time.Now()
+	cont := &info.ContainerInfo{
+		Spec: info.ContainerSpec{
+			HasCpu:    true,
+			Cpu:       info.CpuSpec{Mask: "0-1"},
+			HasMemory: true,
+			Memory:    info.MemorySpec{Limit: 1 << 20},
+		},
+		Stats: []*info.ContainerStats{
+			{Timestamp: ct},
+			{Timestamp: ct.Add(time.Second)},
+		},
+	}
+	cont.Stats[1].Cpu.Usage.Total = uint64(time.Second)
+	cont.Stats[0].Cpu.Usage.PerCpu = []uint64{0, 0}
+	cont.Stats[1].Cpu.Usage.PerCpu = []uint64{uint64(time.Second), 0}
+	cont.Stats[1].Memory.WorkingSet = 1 << 19
+
+	stats := convertStats(cont, false)
+	assert.Nil(t, stats[1].MemoryUtilization)
+	assert.Nil(t, stats[1].CpusetUtilization)
+
+	stats = convertStats(cont, true)
+	// No rates from the first stat point.
+	assert.Nil(t, stats[0].CpusetUtilization)
+	if assert.NotNil(t, stats[1].MemoryUtilization) {
+		assert.Equal(t, 50.0, *stats[1].MemoryUtilization)
+	}
+	if assert.NotNil(t, stats[1].CpusetUtilization) {
+		assert.Equal(t, 50.0, *stats[1].CpusetUtilization)
+	}
+	// There is no CFS quota.
+	assert.Nil(t, stats[1].CpuUtilization)
+	assert.Equal(t, []info.PerCpuUsageRate{{Cpu: 0, Cores: 1}, {Cpu: 1, Cores: 0}}, stats[1].PerCpuUsageRates)
+
+	// Unlimited memory.
+	cont.Spec.Memory.Limit = math.MaxUint64
+	stats = convertStats(cont, true)
+	assert.Nil(t, stats[1].MemoryUtilization)
+}
+
+// Returns a manager serving the root container and the metadata pseudo-container.
+func newMetaManager() *manager.ManagerMock {
+	m := &manager.ManagerMock{}
+	root := &info.ContainerInfo{ContainerReference: info.ContainerReference{Name: "/"}}
+	m.On("SubcontainersInfo", "/", mock.Anything).Return([]*info.ContainerInfo{root}, nil)
+	m.On("GetContainerInfo", manager.MetaContainerName, mock.Anything).Return(&info.ContainerInfo{
+		ContainerReference: info.ContainerReference{Name: manager.MetaContainerName},
+		Stats: []*info.ContainerStats{
+			{ContainerCounts: &info.ContainerCountStats{Tracked: 1, Created: 1}},
+		},
+	}, nil)
+	return m
+}
+
+func TestMetaContainerOnlyListedOnRequest(t *testing.T) {
+	api := newVersion2_0(newVersion1_3(newVersion1_2(newVersion1_1(&version1_0{}))))
+	for _, rawQuery := range []string{"", "include_meta=true"} {
+		w := httptest.NewRecorder()
+		r := makeHTTPRequest("http://localhost:8080/api/v2.0/stats/?recursive=true&"+rawQuery, t)
+		assert.Nil(t, api.HandleRequest(statsApi, []string{}, newMetaManager(), w, r))
+
+		var stats map[string][]v2.ContainerStats
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &stats))
+		meta, ok := stats[manager.MetaContainerName]
+		if rawQuery == "" {
+			assert.False(t, ok, "the metadata pseudo-container is only listed on request")
+			continue
+		}
+		if assert.True(t, ok, "the metadata pseudo-container is listed with include_meta") && assert.Equal(t, 1, len(meta)) {
+			assert.Equal(t, &info.ContainerCountStats{Tracked: 1, Created: 1}, meta[0].ContainerCounts)
+		}
+	}
+
+	// Also through the subcontainers of the v1 API.
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest("GET", "http://localhost:8080/api/v1.1/subcontainers/?include_meta=true", strings.NewReader(""))
+	assert.Nil(t, err)
+	assert.Nil(t, api.HandleRequest(subcontainersApi, []string{}, newMetaManager(), w, r))
+	var containers []info.ContainerInfo
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &containers))
+	assert.Equal(t, 2, len(containers))
+}
+
+func TestRateLimitedCollection(t *testing.T) {
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest("GET", "http://localhost:8080/api/v1.3/containers/docker?collect=true", strings.NewReader(""))
+	assert.Nil(t, err)
+	m := &manager.ManagerMock{}
+	m.On("CollectContainerInfo", "/docker", mock.Anything).Return((*info.ContainerInfo)(nil), &manager.RateLimitedError{
+		ContainerName:   "/docker",
+		RetryAfter:      300 * time.Millisecond,
+		NewestSampleAge: 1500 * time.Millisecond,
+	})
+	assert.Nil(t, (&version1_0{}).HandleRequest(containersApi, []string{"docker"}, m, w, r))
+	assert.Equal(t, statusTooManyRequests, w.Code)
+	// Rounded up to whole seconds.
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Equal(t, "1.5s", w.Header().Get(newestSampleAgeHeader))
+}
+
+func TestGetDiffRevision(t *testing.T) {
+	since, err := getDiffRevision(makeHTTPRequest("http://localhost:8080/api/v2.0/containers/diff?since=42", t))
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(42), since)
+
+	for _, query := range []string{"", "?since=", "?since=-1", "?since=abc"} {
+		_, err := getDiffRevision(makeHTTPRequest("http://localhost:8080/api/v2.0/containers/diff"+query, t))
+		assert.NotNil(t, err, "query %q", query)
+	}
+}
+
+func TestApiEndpointTemplates(t *testing.T) {
+	supportedApiVersions := make(map[string]ApiVersion)
+	for _, v := range getApiVersions(nil) {
+		supportedApiVersions[v.Version()] = v
+	}
+	for request, expected := range map[string]string{
+		"/api/":                          "/api",
+		"/api/v1.3":                      "/api/v1.3",
+		"/api/v1.3/containers/docker/a":  "/api/v1.3/containers",
+		"/api/v2.0/stats/system.slice/x": "/api/v2.0/stats",
+		"/api/v2.0/requests":             "/api/v2.0/requests",
+		"/api/v2.0/unknown/x":            "/api/v2.0/other",
+		"/api/v9.9/containers":           "/api/other",
+	} {
+		assert.Equal(t, expected, apiEndpoint(supportedApiVersions, newLabeledManager(), request), "request %q", request)
+	}
+}
+
+// Returns a manager whose snapshot of "/docker" has a container without stats at the cut.
+func newSnapshotManager(cut time.Time) *manager.ManagerMock {
+	sampled := &info.ContainerInfo{
+		ContainerReference: info.ContainerReference{Name: "/docker/a"},
+		Spec:               info.ContainerSpec{HasMemory: true},
+		Stats: []*info.ContainerStats{
+			{Timestamp: cut, Memory: info.MemoryStats{Usage: 1024}},
+		},
+	}
+	created := &info.ContainerInfo{
+		ContainerReference: info.ContainerReference{Name: "/docker/b"},
+		Spec:               info.ContainerSpec{HasMemory: true},
+	}
+	m := &manager.ManagerMock{}
+	m.On("GetSnapshot", "/docker").Return([]*info.ContainerInfo{sampled, created}, cut, nil)
+	return m
+}
+
+func TestSnapshot(t *testing.T) {
+	cut := time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+	w := httptest.NewRecorder()
+	r := makeHTTPRequest("http://localhost:8080/api/v2.0/snapshot/docker", t)
+	assert.Nil(t, (&version2_0{}).HandleRequest(snapshotApi, []string{"docker"}, newSnapshotManager(cut), w, r))
+
+	var snapshot v2.Snapshot
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &snapshot))
+	assert.True(t, cut.Equal(snapshot.Timestamp))
+	require.Equal(t, 1, len(snapshot.Stats))
+	assert.Equal(t, uint64(1024), snapshot.Stats["/docker/a"].Memory.Usage)
+	assert.Equal(t, []string{"/docker/b"}, snapshot.NoData)
+}
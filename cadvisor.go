@@ -12,30 +12,42 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// +build linux
+
 package main
 
 import (
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
-	_ "net/http/pprof"
+	"net/http/pprof"
 	"os"
 	"os/signal"
 	"runtime"
+	"strconv"
 	"syscall"
 
 	"github.com/golang/glog"
 	cadvisorHttp "github.com/google/cadvisor/http"
 	"github.com/google/cadvisor/manager"
+	"github.com/google/cadvisor/replay"
+	"github.com/google/cadvisor/storage/memory"
 	"github.com/google/cadvisor/utils/sysfs"
 	"github.com/google/cadvisor/version"
 )
 
 var argIp = flag.String("listen_ip", "", "IP to listen on, defaults to all IPs")
 var argPort = flag.Int("port", 8080, "port to listen")
+var argPortFile = flag.String("port_file", "", "File to write the port cAdvisor listens on to once it listens. Useful with --port=0, which picks a free port. Empty means none")
+var argListenTcp = flag.Bool("listen_tcp", true, "Whether to listen on --listen_ip and --port. Can be disabled when listening on --listen_unix_socket")
+var argUnixSocket = flag.String("listen_unix_socket", "", "Path of a unix socket to also serve on (e.g.: /var/run/cadvisor.sock). Empty means none")
+var argUnixSocketMode = flag.String("listen_unix_socket_mode", "0660", "Permissions of --listen_unix_socket, in octal")
+var argUnixSocketGroup = flag.String("listen_unix_socket_group", "", "Group (name or ID) owning --listen_unix_socket. Empty keeps cAdvisor's group")
 var maxProcs = flag.Int("max_procs", 0, "max number of CPUs that can be used simultaneously. Less than 1 for default (number of cores).")
 
-var argDbDriver = flag.String("storage_driver", "", "storage driver to use. Data is always cached shortly in memory, this controls where data is pushed besides the local cache. Empty means none. Options are: <empty> (default), bigquery, and influxdb")
+var argDbDriver = flag.String("storage_driver", "", "Comma-separated storage drivers to use. Data is always cached shortly in memory, this controls where data is pushed besides the local cache. Empty means none. Options are: <empty> (default), bigquery, file, and influxdb")
 var versionFlag = flag.Bool("version", false, "print cAdvisor version and exit")
 
 var httpAuthFile = flag.String("http_auth_file", "", "HTTP auth file for the web UI")
@@ -43,8 +55,15 @@ var httpAuthRealm = flag.String("http_auth_realm", "localhost", "HTTP auth realm
 var httpDigestFile = flag.String("http_digest_file", "", "HTTP digest file for the web UI")
 var httpDigestRealm = flag.String("http_digest_realm", "localhost", "HTTP digest file for the web UI")
 
+var readOnly = flag.Bool("read_only", false, "Whether to deny the requests which change the state of cAdvisor, such as forced collections and watches of events, with 405 Method Not Allowed. Pages, metrics and queries of the API are still served")
+
+var enableDebugEndpoints = flag.Bool("enable_debug_endpoints", false, "Whether to serve the debug endpoints: the objects tracked for leaks at /tracked and the latest stats of the containers as the cadvisor.containers expvar map, served as JSON at /debug/vars for scrapers of expvar. At most --api_page_limit containers are published, the first by name")
+
 var prometheusEndpoint = flag.String("prometheus_endpoint", "/metrics", "Endpoint to expose Prometheus metrics on")
 
+var argReplayFile = flag.String("replay_file", "", "Stats file written by the file storage driver (its stats.json) to serve instead of collecting stats, e.g.: to develop against realistic data on a machine without the containers. Nothing is collected and the requests which change the state of cAdvisor are denied. Empty collects the stats of this machine")
+var argReplaySpeed = flag.Float64("replay_speed", 0, "How many times faster than they were recorded the stats of --replay_file are replayed, starting from its oldest stats. 0 serves all of them at startup")
+
 func main() {
 	defer glog.Flush()
 	flag.Parse()
@@ -56,25 +75,46 @@ func main() {
 
 	setMaxProcs()
 
-	memoryStorage, err := NewMemoryStorage(*argDbDriver)
-	if err != nil {
-		glog.Fatalf("Failed to connect to database: %s", err)
-	}
+	var containerManager manager.Manager
+	var memoryStorage *memory.InMemoryStorage
+	var err error
+	if *argReplayFile != "" {
+		containerManager, err = newReplayManager()
+		if err != nil {
+			glog.Fatalf("Failed to replay %q: %v", *argReplayFile, err)
+		}
+	} else {
+		sysFs, err := sysfs.NewRealSysFs()
+		if err != nil {
+			glog.Fatalf("Failed to create a system interface: %s", err)
+		}
 
-	sysFs, err := sysfs.NewRealSysFs()
-	if err != nil {
-		glog.Fatalf("Failed to create a system interface: %s", err)
-	}
+		machineInfo, err := manager.GetMachineInfo(sysFs, managerConfig)
+		if err != nil {
+			glog.Fatalf("Failed to get the information of the machine: %s", err)
+		}
 
-	containerManager, err := manager.New(memoryStorage, sysFs)
-	if err != nil {
-		glog.Fatalf("Failed to create a Container Manager: %s", err)
+		memoryStorage, err = NewMemoryStorage(*argDbDriver, machineInfo)
+		if err != nil {
+			glog.Fatalf("Failed to connect to database: %s", err)
+		}
+
+		containerManager, err = manager.New(memoryStorage, sysFs, machineInfo, managerConfig)
+		if err != nil {
+			glog.Fatalf("Failed to create a Container Manager: %s", err)
+		}
 	}
 
-	mux := http.DefaultServeMux
+	// http.DefaultServeMux is not served: packages register handlers on it
+	// when imported (e.g.: expvar serves /debug/vars).
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
 
-	// Register all HTTP handlers.
-	err = cadvisorHttp.RegisterHandlers(mux, containerManager, *httpAuthFile, *httpAuthRealm, *httpDigestFile, *httpDigestRealm, *prometheusEndpoint)
+	// Register all HTTP handlers. A replay is always read-only: there is nothing to collect.
+	err = cadvisorHttp.RegisterHandlers(mux, containerManager, *httpAuthFile, *httpAuthRealm, *httpDigestFile, *httpDigestRealm, *prometheusEndpoint, *readOnly || *argReplayFile != "", *enableDebugEndpoints)
 	if err != nil {
 		glog.Fatalf("Failed to register HTTP handlers: %v", err)
 	}
@@ -84,13 +124,75 @@ func main() {
 		glog.Fatalf("Failed to start container manager: %v", err)
 	}
 
+	if !*argListenTcp && *argUnixSocket == "" {
+		glog.Fatalf("Nothing to listen on: --listen_tcp is disabled and --listen_unix_socket is not set")
+	}
+
+	var unixListener net.Listener
+	if *argUnixSocket != "" {
+		mode, err := strconv.ParseUint(*argUnixSocketMode, 8, 32)
+		if err != nil {
+			glog.Fatalf("Invalid --listen_unix_socket_mode %q: %v", *argUnixSocketMode, err)
+		}
+		unixListener, err = cadvisorHttp.ListenUnix(*argUnixSocket, os.FileMode(mode), *argUnixSocketGroup)
+		if err != nil {
+			glog.Fatalf("Failed to listen on unix socket: %v", err)
+		}
+	}
+
 	// Install signal handler.
-	installSignalHandler(containerManager)
+	installSignalHandler(containerManager, memoryStorage, *argUnixSocket)
 
-	glog.Infof("Starting cAdvisor version: %q on port %d", version.VERSION, *argPort)
+	if unixListener != nil {
+		glog.Infof("Starting cAdvisor version: %q on unix socket %q", version.VERSION, *argUnixSocket)
+		if !*argListenTcp {
+			glog.Fatal(http.Serve(unixListener, mux))
+		}
+		go func() {
+			glog.Fatal(http.Serve(unixListener, mux))
+		}()
+	}
 
 	addr := fmt.Sprintf("%s:%d", *argIp, *argPort)
-	glog.Fatal(http.ListenAndServe(addr, nil))
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		glog.Fatalf("Failed to listen on %q: %v", addr, err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	glog.Infof("Starting cAdvisor version: %q on port %d", version.VERSION, port)
+	if *argPortFile != "" {
+		if err := writePortFile(*argPortFile, port); err != nil {
+			glog.Fatalf("Failed to write port file: %v", err)
+		}
+	}
+	glog.Fatal(http.Serve(listener, nil))
+}
+
+// Returns a manager serving the stats of --replay_file, which starts no collectors.
+func newReplayManager() (manager.Manager, error) {
+	if *argDbDriver != "" {
+		return nil, fmt.Errorf("--storage_driver cannot be used with --replay_file, replayed stats are not stored")
+	}
+	recording, err := replay.LoadFile(*argReplayFile)
+	if err != nil {
+		return nil, err
+	}
+	if recording.Skipped() > 0 {
+		glog.Warningf("Skipped %d corrupt and %d unknown records of %q", recording.Corrupt, recording.Unknown, *argReplayFile)
+	}
+	glog.Infof("Replaying %d stats from %q", len(recording.Samples), *argReplayFile)
+	return replay.New(recording, *argReplaySpeed), nil
+}
+
+// Writes the port to the file. The file is renamed into place so that it is
+// never read partially written.
+func writePortFile(path string, port int) error {
+	tmpPath := path + ".tmp"
+	err := ioutil.WriteFile(tmpPath, []byte(strconv.Itoa(port)), 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
 }
 
 func setMaxProcs() {
@@ -111,7 +213,8 @@ func setMaxProcs() {
 	}
 }
 
-func installSignalHandler(containerManager manager.Manager) {
+// Stops the manager and removes the unix socket (if not empty) when cAdvisor is signaled to exit.
+func installSignalHandler(containerManager manager.Manager, memoryStorage *memory.InMemoryStorage, unixSocket string) {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, os.Kill, syscall.SIGTERM)
 
@@ -121,6 +224,16 @@ func installSignalHandler(containerManager manager.Manager) {
 		if err := containerManager.Stop(); err != nil {
 			glog.Errorf("Failed to stop container manager: %v", err)
 		}
+		if memoryStorage != nil && *argMemoryCheckpointPath != "" {
+			if err := memoryStorage.WriteCheckpointFile(*argMemoryCheckpointPath); err != nil {
+				glog.Errorf("Failed to checkpoint the stats in memory to %q: %v", *argMemoryCheckpointPath, err)
+			}
+		}
+		if unixSocket != "" {
+			if err := os.Remove(unixSocket); err != nil && !os.IsNotExist(err) {
+				glog.Errorf("Failed to remove unix socket %q: %v", unixSocket, err)
+			}
+		}
 		glog.Infof("Exiting given signal: %v", sig)
 		os.Exit(0)
 	}()
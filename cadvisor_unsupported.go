@@ -0,0 +1,40 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !linux
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/google/cadvisor/utils"
+	"github.com/google/cadvisor/version"
+)
+
+var versionFlag = flag.Bool("version", false, "print cAdvisor version and exit")
+
+// cAdvisor only collects stats on Linux. Elsewhere it builds so that the
+// repository does, but exits at startup.
+func main() {
+	flag.Parse()
+	if *versionFlag {
+		fmt.Printf("cAdvisor version %s\n", version.VERSION)
+		os.Exit(0)
+	}
+	fmt.Fprintf(os.Stderr, "cAdvisor cannot run: %v\n", utils.ErrUnsupportedPlatform)
+	os.Exit(1)
+}
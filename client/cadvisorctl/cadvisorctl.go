@@ -0,0 +1,543 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// cadvisorctl queries a cAdvisor through its REST API.
+//
+// Usage: cadvisorctl [options] <command> [command options] [arguments]
+//
+//	machine                                  Information about the machine.
+//	ls [prefix]                              Containers under the prefix, as a tree.
+//	stats [--watch] [--num N] <container>    Recent stats of the container.
+//	top [--metric cpu] [--count N] [container]
+//	                                         Containers using the most of a resource.
+//	events [--follow] [--num N] [container]  Past events, or new ones as they happen.
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/docker/pkg/units"
+	"github.com/google/cadvisor/client"
+	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+)
+
+// Options shared by all commands.
+type options struct {
+	url        string
+	unixSocket string
+	format     string
+
+	tlsCaFile     string
+	tlsCertFile   string
+	tlsKeyFile    string
+	tlsSkipVerify bool
+
+	username string
+	password string
+}
+
+type command struct {
+	usage       string
+	description string
+	run         func(c *client.Client, args []string, out *output) error
+}
+
+var commands = map[string]command{
+	"machine": {"", "Information about the machine.", machineCommand},
+	"ls":      {"[prefix]", "Containers under the prefix, as a tree.", lsCommand},
+	"stats":   {"[--watch] [--num N] <container>", "Recent stats of the container.", statsCommand},
+	"top":     {"[--metric cpu|memory|network] [--count N] [container]", "Containers using the most of a resource.", topCommand},
+	"events":  {"[--follow] [--num N] [container]", "Past events, or new ones as they happen.", eventsCommand},
+}
+
+// Number of polls of stats --watch before it returns, 0 for no limit. Only
+// bounded in tests.
+var maxWatchPolls = 0
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "cadvisorctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, w io.Writer) error {
+	var opts options
+	flags := flag.NewFlagSet("cadvisorctl", flag.ContinueOnError)
+	flags.StringVar(&opts.url, "url", "http://localhost:8080/", "URL of the cAdvisor")
+	flags.StringVar(&opts.unixSocket, "unix_socket", "", "Unix domain socket the cAdvisor serves its API on, instead of --url")
+	flags.StringVar(&opts.format, "format", "table", "Output format: table or json")
+	flags.StringVar(&opts.tlsCaFile, "tls_ca_file", "", "PEM file of the CAs to verify the cAdvisor's certificate with, instead of the system's")
+	flags.StringVar(&opts.tlsCertFile, "tls_cert_file", "", "PEM file of the client certificate to present")
+	flags.StringVar(&opts.tlsKeyFile, "tls_key_file", "", "PEM file of the key of the client certificate")
+	flags.BoolVar(&opts.tlsSkipVerify, "tls_insecure_skip_verify", false, "Do not verify the cAdvisor's certificate")
+	flags.StringVar(&opts.username, "username", "", "User to authenticate as with HTTP basic auth")
+	flags.StringVar(&opts.password, "password", "", "Password of the HTTP basic auth user")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: cadvisorctl [options] <command> [command options] [arguments]\n\nCommands:\n")
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(os.Stderr, "  %s %s\n    \t%s\n", name, commands[name].usage, commands[name].description)
+		}
+		fmt.Fprintf(os.Stderr, "\nOptions:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return fmt.Errorf("no command specified")
+	}
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		return fmt.Errorf("unknown command %q", flags.Arg(0))
+	}
+	if opts.format != "table" && opts.format != "json" {
+		return fmt.Errorf("unknown format %q, must be table or json", opts.format)
+	}
+
+	c, err := newClient(&opts)
+	if err != nil {
+		return err
+	}
+	return cmd.run(c, flags.Args()[1:], &output{w: w, json: opts.format == "json"})
+}
+
+func newClient(opts *options) (*client.Client, error) {
+	if opts.unixSocket != "" {
+		return client.NewClientWithUnixSocket(opts.unixSocket)
+	}
+	transport, err := newTransport(opts)
+	if err != nil {
+		return nil, err
+	}
+	return client.NewClientWithTransport(opts.url, transport)
+}
+
+// Returns the transport to the cAdvisor with the TLS and authentication
+// options applied.
+func newTransport(opts *options) (http.RoundTripper, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: opts.tlsSkipVerify,
+	}
+	if opts.tlsCaFile != "" {
+		pem, err := ioutil.ReadFile(opts.tlsCaFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %v", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %q", opts.tlsCaFile)
+		}
+	}
+	if opts.tlsCertFile != "" || opts.tlsKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.tlsCertFile, opts.tlsKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	var transport http.RoundTripper = &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+	}
+	if opts.username != "" {
+		transport = &basicAuthTransport{
+			transport: transport,
+			username:  opts.username,
+			password:  opts.password,
+		}
+	}
+	return transport, nil
+}
+
+// Authenticates the requests made through the underlying transport with HTTP
+// basic auth.
+type basicAuthTransport struct {
+	transport http.RoundTripper
+	username  string
+	password  string
+}
+
+func (self *basicAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Round trippers must not modify the request.
+	authReq := new(http.Request)
+	*authReq = *req
+	authReq.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		authReq.Header[k] = v
+	}
+	authReq.SetBasicAuth(self.username, self.password)
+	return self.transport.RoundTrip(authReq)
+}
+
+// Writes the results of a command either as a table or as JSON.
+type output struct {
+	w    io.Writer
+	json bool
+	// Minimum width of the columns of tables. Rows streamed in separate
+	// tables only line up if their cells fit in it.
+	minWidth int
+}
+
+// Fits the cells of streamed tables other than timestamps, which all have the
+// same width, and container names, which come last.
+const streamedColumnWidth = 20
+
+// Writes the value as indented JSON.
+func (self *output) writeJson(v interface{}) error {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(self.w, "%s\n", out)
+	return err
+}
+
+// Writes the rows as a table with the specified header, in aligned columns.
+func (self *output) writeTable(header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(self.w, self.minWidth, 8, 2, ' ', 0)
+	if header != nil {
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+	}
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// Writes the value, or its rows as a table.
+func (self *output) write(v interface{}, header []string, rows [][]string) error {
+	if self.json {
+		return self.writeJson(v)
+	}
+	return self.writeTable(header, rows)
+}
+
+func parseCommandFlags(flags *flag.FlagSet, args []string, maxArgs int) error {
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > maxArgs {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args()[maxArgs:], " "))
+	}
+	return nil
+}
+
+func machineCommand(c *client.Client, args []string, out *output) error {
+	flags := flag.NewFlagSet("machine", flag.ContinueOnError)
+	if err := parseCommandFlags(flags, args, 0); err != nil {
+		return err
+	}
+	minfo, err := c.MachineInfo()
+	if err != nil {
+		return err
+	}
+	rows := [][]string{
+		{"Machine ID", minfo.MachineID},
+		{"System UUID", minfo.SystemUUID},
+		{"Cores", fmt.Sprint(minfo.NumCores)},
+		{"CPU frequency", fmt.Sprintf("%d kHz", minfo.CpuFrequency)},
+		{"Memory", units.BytesSize(float64(minfo.MemoryCapacity))},
+		{"Kernel", minfo.KernelVersion},
+		{"OS", minfo.OSImage},
+		{"Docker", minfo.DockerVersion},
+		{"cAdvisor", minfo.CadvisorVersion},
+	}
+	for _, fs := range minfo.Filesystems {
+		rows = append(rows, []string{"Filesystem " + fs.Device, units.BytesSize(float64(fs.Capacity))})
+	}
+	return out.write(minfo, nil, rows)
+}
+
+func lsCommand(c *client.Client, args []string, out *output) error {
+	flags := flag.NewFlagSet("ls", flag.ContinueOnError)
+	if err := parseCommandFlags(flags, args, 1); err != nil {
+		return err
+	}
+	prefix := "/"
+	if flags.NArg() == 1 {
+		prefix = flags.Arg(0)
+	}
+	containers, err := c.SubcontainersInfo(prefix, &info.ContainerInfoRequest{NumStats: 0})
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(containers))
+	aliases := make(map[string][]string, len(containers))
+	for _, cont := range containers {
+		names = append(names, cont.Name)
+		aliases[cont.Name] = cont.Aliases
+	}
+	// Sorted, parents come right before their subcontainers.
+	sort.Strings(names)
+	if out.json {
+		return out.writeJson(names)
+	}
+
+	rows := make([][]string, 0, len(names))
+	base := path.Clean(prefix)
+	for _, name := range names {
+		depth := 0
+		if name != base {
+			rel := strings.TrimPrefix(strings.TrimPrefix(name, base), "/")
+			depth = strings.Count(rel, "/") + 1
+		}
+		label := name
+		if depth > 0 {
+			label = strings.Repeat("  ", depth-1) + "└─ " + path.Base(name)
+		}
+		if len(aliases[name]) > 0 {
+			label += " (" + strings.Join(aliases[name], ", ") + ")"
+		}
+		rows = append(rows, []string{label})
+	}
+	return out.writeTable(nil, rows)
+}
+
+var statsHeader = []string{"TIMESTAMP", "CPU (CORES)", "MEMORY", "WORKING SET", "RX BYTES", "TX BYTES"}
+
+// Returns the table row of the stats. The CPU usage is the rate since the
+// previous stats, if any.
+func statsRow(prev, stats *info.ContainerStats) []string {
+	cpu := "-"
+	if prev != nil {
+		elapsed := stats.Elapsed(prev)
+		if elapsed > 0 && stats.Cpu.Usage.Total >= prev.Cpu.Usage.Total {
+			cpu = fmt.Sprintf("%.3f", float64(stats.Cpu.Usage.Total-prev.Cpu.Usage.Total)/float64(elapsed))
+		}
+	}
+	return []string{
+		stats.Timestamp.Format(time.RFC3339),
+		cpu,
+		units.BytesSize(float64(stats.Memory.Usage)),
+		units.BytesSize(float64(stats.Memory.WorkingSet)),
+		fmt.Sprint(stats.Network.RxBytes),
+		fmt.Sprint(stats.Network.TxBytes),
+	}
+}
+
+func statsCommand(c *client.Client, args []string, out *output) error {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	watch := flags.Bool("watch", false, "Keep printing the new stats of the container")
+	num := flags.Int("num", 10, "Number of most recent stats to print")
+	interval := flags.Duration("interval", time.Second, "How often to poll for new stats with --watch")
+	if err := parseCommandFlags(flags, args, 1); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected the name of a container")
+	}
+	name := flags.Arg(0)
+	if *watch {
+		out.minWidth = streamedColumnWidth
+	}
+
+	var (
+		last   *info.ContainerStats
+		header = statsHeader
+	)
+	for polls := 1; ; polls++ {
+		cinfo, err := c.ContainerInfo(name, &info.ContainerInfoRequest{NumStats: *num})
+		if err != nil {
+			return err
+		}
+		var fresh []*info.ContainerStats
+		for _, stats := range cinfo.Stats {
+			if last == nil || stats.Timestamp.After(last.Timestamp) {
+				fresh = append(fresh, stats)
+			}
+		}
+		if !*watch && out.json {
+			return out.writeJson(cinfo)
+		}
+		if out.json {
+			for _, stats := range fresh {
+				if err := out.writeJson(stats); err != nil {
+					return err
+				}
+			}
+		} else {
+			rows := make([][]string, 0, len(fresh))
+			prev := last
+			for _, stats := range fresh {
+				rows = append(rows, statsRow(prev, stats))
+				prev = stats
+			}
+			if err := out.writeTable(header, rows); err != nil {
+				return err
+			}
+			header = nil
+		}
+		if len(fresh) > 0 {
+			last = fresh[len(fresh)-1]
+		}
+		if !*watch || polls == maxWatchPolls {
+			return nil
+		}
+		time.Sleep(*interval)
+	}
+}
+
+func topCommand(c *client.Client, args []string, out *output) error {
+	flags := flag.NewFlagSet("top", flag.ContinueOnError)
+	request := v2.TopRequest{}
+	flags.StringVar(&request.Metric, "metric", "cpu", "Resource to rank the containers by: cpu, memory or network")
+	flags.IntVar(&request.Count, "count", 10, "Number of containers to print")
+	flags.BoolVar(&request.Aggregate, "aggregate", false, "Only rank the immediate subcontainers, with the usage of their subcontainers rolled in")
+	if err := parseCommandFlags(flags, args, 1); err != nil {
+		return err
+	}
+	name := "/"
+	if flags.NArg() == 1 {
+		name = flags.Arg(0)
+	}
+	top, err := c.Top(name, &request)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(top))
+	for _, cont := range top {
+		var value string
+		switch request.Metric {
+		case "memory":
+			value = units.BytesSize(cont.Value)
+		case "network":
+			value = units.BytesSize(cont.Value) + "/s"
+		default:
+			value = fmt.Sprintf("%.3f", cont.Value)
+		}
+		percent := "-"
+		if cont.PercentOfMachine != nil {
+			percent = fmt.Sprintf("%.1f%%", *cont.PercentOfMachine)
+		}
+		name := cont.Name
+		if len(cont.Aliases) > 0 {
+			name += " (" + cont.Aliases[0] + ")"
+		}
+		rows = append(rows, []string{name, value, percent})
+	}
+	return out.write(top, []string{"CONTAINER", strings.ToUpper(request.Metric), "% OF MACHINE"}, rows)
+}
+
+// Names of the types of events as printed.
+var eventTypeNames = map[events.EventType]string{
+	events.TypeOom:                 "oom",
+	events.TypeContainerCreation:   "creation",
+	events.TypeContainerDeletion:   "deletion",
+	events.TypeCollectionDegraded:  "collection_degraded",
+	events.TypeCollectionRestored:  "collection_restored",
+	events.TypeTimeJump:            "time_jump",
+	events.TypeContainerReattached: "reattached",
+	events.TypeCollectorRestarted:  "collector_restarted",
+	events.TypeMemoryPressure:      "memory_pressure",
+	events.TypeCgroupRemount:       "cgroup_remount",
+	events.TypeCpuHotplug:          "cpu_hotplug",
+	events.TypeEventsDropped:       "events_dropped",
+}
+
+func eventRow(ev *events.Event) []string {
+	eventType, ok := eventTypeNames[ev.EventType]
+	if !ok {
+		eventType = fmt.Sprint(ev.EventType)
+	}
+	return []string{ev.Timestamp.Format(time.RFC3339), eventType, ev.ContainerName}
+}
+
+var eventsHeader = []string{"TIMESTAMP", "TYPE", "CONTAINER"}
+
+func eventsCommand(c *client.Client, args []string, out *output) error {
+	flags := flag.NewFlagSet("events", flag.ContinueOnError)
+	follow := flags.Bool("follow", false, "Print the new events as they happen instead of the past ones")
+	num := flags.Int("num", 0, "Number of events to print, 0 for the default of the cAdvisor (or no limit with --follow)")
+	if err := parseCommandFlags(flags, args, 1); err != nil {
+		return err
+	}
+	request := &events.Request{
+		ContainerName:        "/",
+		IncludeSubcontainers: true,
+		EventType:            make(map[events.EventType]bool, len(eventTypeNames)),
+	}
+	if flags.NArg() == 1 {
+		request.ContainerName = flags.Arg(0)
+	}
+	for eventType := range eventTypeNames {
+		request.EventType[eventType] = true
+	}
+
+	if !*follow {
+		request.MaxEventsReturned = *num
+		evs, err := c.Events(request)
+		if err != nil {
+			return err
+		}
+		rows := make([][]string, 0, len(evs))
+		for _, ev := range evs {
+			rows = append(rows, eventRow(ev))
+		}
+		return out.write(evs, eventsHeader, rows)
+	}
+
+	stream, err := c.WatchEvents(request)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	out.minWidth = streamedColumnWidth
+	if !out.json {
+		if err := out.writeTable(eventsHeader, nil); err != nil {
+			return err
+		}
+	}
+	for printed := 0; *num == 0 || printed < *num; printed++ {
+		ev, err := stream.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if out.json {
+			err = out.writeJson(ev)
+		} else {
+			err = out.writeTable(nil, [][]string{eventRow(ev)})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
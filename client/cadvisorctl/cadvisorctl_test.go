@@ -0,0 +1,243 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build linux
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/client/fake"
+	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Runs the command against the fake and returns its output.
+func runAgainst(t *testing.T, fakeCadvisor *fake.FakeCadvisor, args ...string) string {
+	var out bytes.Buffer
+	require.Nil(t, run(append([]string{"--url", fakeCadvisor.URL}, args...), &out))
+	return out.String()
+}
+
+func addContainer(t *testing.T, fakeCadvisor *fake.FakeCadvisor, name string, aliases ...string) *info.ContainerInfo {
+	start := time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+	cinfo := &info.ContainerInfo{
+		ContainerReference: info.ContainerReference{
+			Name:    name,
+			Aliases: aliases,
+		},
+		Spec: info.ContainerSpec{
+			HasCpu:    true,
+			HasMemory: true,
+		},
+	}
+	for i := 0; i < 3; i++ {
+		stats := &info.ContainerStats{Timestamp: start.Add(time.Duration(i) * time.Second)}
+		// Half a core.
+		stats.Cpu.Usage.Total = uint64(i) * uint64(500*time.Millisecond)
+		stats.Memory.Usage = 2048
+		stats.Memory.WorkingSet = 1024
+		cinfo.Stats = append(cinfo.Stats, stats)
+	}
+	require.Nil(t, fakeCadvisor.SetContainerInfo(cinfo))
+	return cinfo
+}
+
+func TestMachine(t *testing.T) {
+	fakeCadvisor := fake.NewFakeCadvisor()
+	defer fakeCadvisor.Close()
+	fakeCadvisor.SetMachineInfo(&info.MachineInfo{
+		NumCores:       4,
+		MemoryCapacity: 1 << 30,
+		MachineID:      "abc",
+	})
+
+	out := runAgainst(t, fakeCadvisor, "machine")
+	assert.Contains(t, out, "Machine ID")
+	assert.Contains(t, out, "abc")
+	assert.Contains(t, out, "1 GiB")
+
+	var minfo info.MachineInfo
+	require.Nil(t, json.Unmarshal([]byte(runAgainst(t, fakeCadvisor, "--format", "json", "machine")), &minfo))
+	assert.Equal(t, 4, minfo.NumCores)
+}
+
+func TestLs(t *testing.T) {
+	fakeCadvisor := fake.NewFakeCadvisor()
+	defer fakeCadvisor.Close()
+	addContainer(t, fakeCadvisor, "/")
+	addContainer(t, fakeCadvisor, "/docker")
+	addContainer(t, fakeCadvisor, "/docker/a", "web")
+	addContainer(t, fakeCadvisor, "/system.slice")
+
+	lines := strings.Split(strings.TrimSpace(runAgainst(t, fakeCadvisor, "ls")), "\n")
+	expected := []string{
+		"/",
+		"└─ docker",
+		"  └─ a (web)",
+		"└─ system.slice",
+	}
+	require.Equal(t, len(expected), len(lines))
+	for i := range expected {
+		assert.Equal(t, expected[i], strings.TrimRight(lines[i], " "))
+	}
+
+	var names []string
+	require.Nil(t, json.Unmarshal([]byte(runAgainst(t, fakeCadvisor, "--format", "json", "ls", "/docker")), &names))
+	assert.Equal(t, []string{"/docker", "/docker/a"}, names)
+}
+
+func TestStats(t *testing.T) {
+	fakeCadvisor := fake.NewFakeCadvisor()
+	defer fakeCadvisor.Close()
+	addContainer(t, fakeCadvisor, "/docker/a")
+
+	lines := strings.Split(strings.TrimSpace(runAgainst(t, fakeCadvisor, "stats", "--num", "2", "/docker/a")), "\n")
+	require.Equal(t, 3, len(lines))
+	assert.True(t, strings.HasPrefix(lines[0], "TIMESTAMP"))
+	// Rates need the previous stats.
+	assert.Equal(t, "-", strings.Fields(lines[1])[1])
+	assert.Equal(t, "0.500", strings.Fields(lines[2])[1])
+
+	var cinfo info.ContainerInfo
+	require.Nil(t, json.Unmarshal([]byte(runAgainst(t, fakeCadvisor, "--format", "json", "stats", "/docker/a")), &cinfo))
+	assert.Len(t, cinfo.Stats, 3)
+}
+
+func TestStatsWatchOnlyPrintsNewStats(t *testing.T) {
+	fakeCadvisor := fake.NewFakeCadvisor()
+	defer fakeCadvisor.Close()
+	addContainer(t, fakeCadvisor, "/docker/a")
+	defer func(polls int) { maxWatchPolls = polls }(maxWatchPolls)
+	maxWatchPolls = 2
+
+	out := runAgainst(t, fakeCadvisor, "stats", "--watch", "--interval", "1ms", "/docker/a")
+	// A header and the stats of the first poll, nothing new in the second.
+	assert.Equal(t, 4, strings.Count(out, "\n"), out)
+	polls := 0
+	for _, request := range fakeCadvisor.Requests() {
+		if strings.HasSuffix(request.Path, "/containers/docker/a") {
+			polls++
+		}
+	}
+	assert.Equal(t, 2, polls)
+}
+
+func TestTop(t *testing.T) {
+	fakeCadvisor := fake.NewFakeCadvisor()
+	defer fakeCadvisor.Close()
+	fakeCadvisor.SetMachineInfo(&info.MachineInfo{NumCores: 2})
+	addContainer(t, fakeCadvisor, "/")
+	addContainer(t, fakeCadvisor, "/docker", "docker")
+
+	out := runAgainst(t, fakeCadvisor, "top", "--metric", "cpu", "--count", "1", "/docker")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Equal(t, 2, len(lines))
+	assert.Equal(t, []string{"/docker", "(docker)", "0.500", "25.0%"}, strings.Fields(lines[1]))
+
+	var top []v2.TopContainer
+	require.Nil(t, json.Unmarshal([]byte(runAgainst(t, fakeCadvisor, "--format", "json", "top", "--metric", "memory")), &top))
+	require.Equal(t, 2, len(top))
+	assert.Equal(t, float64(1024), top[0].Value)
+}
+
+func TestEvents(t *testing.T) {
+	fakeCadvisor := fake.NewFakeCadvisor()
+	defer fakeCadvisor.Close()
+	require.Nil(t, fakeCadvisor.AddEvent(&events.Event{
+		ContainerName: "/docker/a",
+		Timestamp:     time.Now(),
+		EventType:     events.TypeOom,
+	}))
+
+	lines := strings.Split(strings.TrimSpace(runAgainst(t, fakeCadvisor, "events")), "\n")
+	require.Equal(t, 2, len(lines))
+	assert.Equal(t, []string{"oom", "/docker/a"}, strings.Fields(lines[1])[1:])
+}
+
+func TestEventsFollow(t *testing.T) {
+	fakeCadvisor := fake.NewFakeCadvisor()
+	defer fakeCadvisor.Close()
+
+	done := make(chan string)
+	go func() {
+		var out bytes.Buffer
+		err := run([]string{"--url", fakeCadvisor.URL, "--format", "json", "events", "--follow", "--num", "1"}, &out)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- out.String()
+	}()
+	// Only events added once the watch started are streamed.
+	for {
+		require.Nil(t, fakeCadvisor.AddEvent(&events.Event{
+			ContainerName: "/docker/a",
+			Timestamp:     time.Now(),
+			EventType:     events.TypeContainerDeletion,
+		}))
+		select {
+		case out := <-done:
+			var ev events.Event
+			require.Nil(t, json.Unmarshal([]byte(out), &ev))
+			assert.Equal(t, events.TypeContainerDeletion, ev.EventType)
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	fakeCadvisor := fake.NewFakeCadvisor()
+	defer fakeCadvisor.Close()
+	// Only serves authenticated requests.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "admin" || password != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		r.URL.Scheme = "http"
+		r.URL.Host = strings.TrimPrefix(fakeCadvisor.URL, "http://")
+		r.RequestURI = ""
+		resp, err := http.DefaultTransport.RoundTrip(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	assert.NotNil(t, run([]string{"--url", server.URL, "machine"}, &out))
+	assert.Nil(t, run([]string{"--url", server.URL, "--username", "admin", "--password", "secret", "machine"}, &out))
+}
+
+func TestUnknownCommand(t *testing.T) {
+	var out bytes.Buffer
+	assert.NotNil(t, run([]string{"frobnicate"}, &out))
+	assert.NotNil(t, run([]string{"--format", "csv", "machine"}, &out))
+}
@@ -19,73 +19,468 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/google/cadvisor/events"
 	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
 )
 
 // Client represents the base URL for a cAdvisor client.
 type Client struct {
-	baseUrl string
+	url        string
+	v2BaseUrl  string
+	httpClient *http.Client
+
+	// Base URL of the v1 API version negotiated with cAdvisor, empty until the
+	// first request. Protected by baseUrlLock.
+	baseUrlLock sync.Mutex
+	baseUrl     string
+
+	// Last ETag returned for each URL requested conditionally, protected by etagsLock.
+	etagsLock sync.Mutex
+	etags     map[string]string
 }
 
 // NewClient returns a new client with the specified base URL.
 func NewClient(url string) (*Client, error) {
+	return NewClientWithTransport(url, http.DefaultTransport)
+}
+
+// NewClientWithUnixSocket returns a new client to a cAdvisor serving its API
+// on the Unix domain socket at the specified path.
+func NewClientWithUnixSocket(socketPath string) (*Client, error) {
+	transport := &http.Transport{
+		Dial: func(network, addr string) (net.Conn, error) {
+			return net.Dial("unix", socketPath)
+		},
+	}
+	// The host is ignored since all connections are made to the socket.
+	return NewClientWithTransport("http://unix/", transport)
+}
+
+// NewClientWithTransport returns a new client with the specified base URL
+// which makes its requests through the specified transport.
+// e.g.: NewClientWithTransport(url, NewTracingTransport(http.DefaultTransport, log.Printf))
+func NewClientWithTransport(url string, transport http.RoundTripper) (*Client, error) {
+	if err := checkUrl(url); err != nil {
+		return nil, err
+	}
 	if !strings.HasSuffix(url, "/") {
 		url += "/"
 	}
 
 	return &Client{
-		baseUrl: fmt.Sprintf("%sapi/v1.2/", url),
+		url:       url,
+		v2BaseUrl: fmt.Sprintf("%sapi/v2.0/", url),
+		httpClient: &http.Client{
+			Transport: transport,
+		},
+		etags: make(map[string]string),
 	}, nil
 }
 
+// Checks that the URL of the cAdvisor is valid. IPv6 addresses must be
+// bracketed, e.g.: http://[::1]:8080/, so that they are not mistaken for
+// their port.
+func checkUrl(u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return fmt.Errorf("invalid cAdvisor URL %q: %v", u, err)
+	}
+	if strings.Count(parsed.Host, ":") > 1 && !strings.HasPrefix(parsed.Host, "[") {
+		return fmt.Errorf("invalid cAdvisor URL %q: IPv6 addresses must be in brackets, e.g.: http://[::1]:8080/", u)
+	}
+	return nil
+}
+
 // MachineInfo returns the JSON machine information for this client.
 // A non-nil error result indicates a problem with obtaining
 // the JSON machine information data.
-func (self *Client) MachineInfo() (minfo *info.MachineInfo, err error) {
-	u := self.machineInfoUrl()
+func (self *Client) MachineInfo() (*info.MachineInfo, error) {
+	return self.MachineInfoCtx(background)
+}
+
+// MachineInfoCtx is MachineInfo with its requests bounded by the context.
+func (self *Client) MachineInfoCtx(ctx Context) (minfo *info.MachineInfo, err error) {
+	u, err := self.machineInfoUrl(ctx)
+	if err != nil {
+		return
+	}
 	ret := new(info.MachineInfo)
-	if err = self.httpGetJsonData(ret, nil, u, "machine info"); err != nil {
+	if err = self.httpGetJsonData(ctx, ret, nil, u, "machine info"); err != nil {
 		return
 	}
 	minfo = ret
 	return
 }
 
+// MachineInfoIfChanged returns whether the machine information changed since
+// the last call, and the new machine information if it did. The first call
+// always returns it.
+func (self *Client) MachineInfoIfChanged() (bool, *info.MachineInfo, error) {
+	return self.MachineInfoIfChangedCtx(background)
+}
+
+// MachineInfoIfChangedCtx is MachineInfoIfChanged with its requests bounded by the context.
+func (self *Client) MachineInfoIfChangedCtx(ctx Context) (changed bool, minfo *info.MachineInfo, err error) {
+	u, err := self.machineInfoUrl(ctx)
+	if err != nil {
+		return
+	}
+	ret := new(info.MachineInfo)
+	changed, err = self.httpGetJsonDataIfChanged(ctx, ret, u, "machine info")
+	if err != nil || !changed {
+		return
+	}
+	minfo = ret
+	return
+}
+
+// ContainerSpecIfChanged returns whether the spec of the specified container
+// changed since the last call for the container, and the new spec if it did.
+// The first call always returns it.
+func (self *Client) ContainerSpecIfChanged(name string) (bool, *v2.ContainerSpec, error) {
+	return self.ContainerSpecIfChangedCtx(background, name)
+}
+
+// ContainerSpecIfChangedCtx is ContainerSpecIfChanged with its request bounded by the context.
+func (self *Client) ContainerSpecIfChangedCtx(ctx Context, name string) (bool, *v2.ContainerSpec, error) {
+	u := self.specUrl(name)
+	ret := new(v2.ContainerSpec)
+	changed, err := self.httpGetJsonDataIfChanged(ctx, ret, u, fmt.Sprintf("spec of %q", name))
+	if err != nil || !changed {
+		return false, nil, err
+	}
+	return true, ret, nil
+}
+
 // ContainerInfo returns the JSON container information for the specified
 // container and request.
-func (self *Client) ContainerInfo(name string, query *info.ContainerInfoRequest) (cinfo *info.ContainerInfo, err error) {
-	u := self.containerInfoUrl(name)
+func (self *Client) ContainerInfo(name string, query *info.ContainerInfoRequest) (*info.ContainerInfo, error) {
+	return self.ContainerInfoCtx(background, name, query)
+}
+
+// ContainerInfoCtx is ContainerInfo with its requests bounded by the context.
+func (self *Client) ContainerInfoCtx(ctx Context, name string, query *info.ContainerInfoRequest) (cinfo *info.ContainerInfo, err error) {
+	u, err := self.containerInfoUrl(ctx, name)
+	if err != nil {
+		return
+	}
 	ret := new(info.ContainerInfo)
-	if err = self.httpGetJsonData(ret, query, u, fmt.Sprintf("container info for %q", name)); err != nil {
+	if err = self.httpGetJsonData(ctx, ret, query, u, fmt.Sprintf("container info for %q", name)); err != nil {
 		return
 	}
 	cinfo = ret
 	return
 }
 
+// Returns the ancestors of the specified container from the root, followed by
+// the container itself, each with its spec and latest stats. Intermediate
+// cgroups cAdvisor does not track are listed without them.
+func (self *Client) AncestryInfo(name string) ([]info.ContainerAncestor, error) {
+	return self.AncestryInfoCtx(background, name)
+}
+
+// AncestryInfoCtx is AncestryInfo with its requests bounded by the context.
+func (self *Client) AncestryInfoCtx(ctx Context, name string) ([]info.ContainerAncestor, error) {
+	u, err := self.containerInfoUrl(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	var ancestry []info.ContainerAncestor
+	err = self.httpGetJsonData(ctx, &ancestry, nil, u+"?include_ancestors=true", fmt.Sprintf("ancestry info for %q", name))
+	if err != nil {
+		return nil, err
+	}
+	return ancestry, nil
+}
+
+// Error returned by CollectNow when the stats of the container were already
+// collected on request less than a second ago.
+type RateLimitedError struct {
+	// URL of the rejected request.
+	Url string
+
+	// Time until the stats of the container may be collected on request again.
+	RetryAfter time.Duration
+
+	// Age of the newest stats cAdvisor collected on its own, which
+	// ContainerInfo returns. Negative if it has none yet.
+	NewestSampleAge time.Duration
+}
+
+func (self *RateLimitedError) Error() string {
+	return fmt.Sprintf("request %q was rate limited, retry in %v", self.Url, self.RetryAfter)
+}
+
+// CollectNow has cAdvisor collect the stats of the specified container right
+// away instead of waiting for its next housekeeping, and returns the container
+// information with only those stats. Collections are limited to one per second
+// per container, more frequent ones fail with a *RateLimitedError.
+func (self *Client) CollectNow(name string, query *info.ContainerInfoRequest) (*info.ContainerInfo, error) {
+	return self.CollectNowCtx(background, name, query)
+}
+
+// CollectNowCtx is CollectNow with its requests bounded by the context.
+func (self *Client) CollectNowCtx(ctx Context, name string, query *info.ContainerInfoRequest) (*info.ContainerInfo, error) {
+	u, err := self.containerInfoUrl(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	u += "?collect=true"
+	infoName := fmt.Sprintf("collected container info for %q", name)
+	var req *http.Request
+	if query != nil {
+		data, err := json.Marshal(query)
+		if err != nil {
+			return nil, fmt.Errorf("unable to marshal data: %v", err)
+		}
+		req, err = http.NewRequest("POST", u, bytes.NewBuffer(data))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	} else {
+		req, err = http.NewRequest("GET", u, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to make request for %q to %q: %v", infoName, u, err)
+	}
+	resp, body, err := self.do(ctx, req)
+	if IsCanceled(err) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to get %q from %q: %v", infoName, u, err)
+	}
+	if resp.StatusCode == statusTooManyRequests {
+		return nil, newRateLimitedError(u, resp.Header)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request %q failed with error: %q", u, strings.TrimSpace(string(body)))
+	}
+	ret := new(info.ContainerInfo)
+	if err = json.Unmarshal(body, ret); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal %q (Body: %q) from %q with error: %v", infoName, string(body), u, err)
+	}
+	return ret, nil
+}
+
+// Status of the responses to rate limited requests (Too Many Requests).
+const statusTooManyRequests = 429
+
+// Parses the headers of a rate limited response. Retry-After is in seconds,
+// the age of the newest stats a duration such as "1.5s".
+func newRateLimitedError(url string, header http.Header) *RateLimitedError {
+	err := &RateLimitedError{
+		Url:             url,
+		NewestSampleAge: -1,
+	}
+	if seconds, parseErr := strconv.ParseInt(header.Get("Retry-After"), 10, 64); parseErr == nil && seconds > 0 {
+		err.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	if age, parseErr := time.ParseDuration(header.Get("X-Cadvisor-Newest-Sample-Age")); parseErr == nil {
+		err.NewestSampleAge = age
+	}
+	return err
+}
+
 // Returns the information about all subcontainers (recursive) of the specified container (including itself).
 func (self *Client) SubcontainersInfo(name string, query *info.ContainerInfoRequest) ([]info.ContainerInfo, error) {
+	return self.SubcontainersInfoCtx(background, name, query)
+}
+
+// SubcontainersInfoCtx is SubcontainersInfo with its requests bounded by the context.
+func (self *Client) SubcontainersInfoCtx(ctx Context, name string, query *info.ContainerInfoRequest) ([]info.ContainerInfo, error) {
 	var response []info.ContainerInfo
-	url := self.subcontainersInfoUrl(name)
-	err := self.httpGetJsonData(&response, query, url, fmt.Sprintf("subcontainers container info for %q", name))
+	url, err := self.subcontainersInfoUrl(ctx, name)
 	if err != nil {
 		return []info.ContainerInfo{}, err
+	}
+	err = self.httpGetJsonData(ctx, &response, query, url, fmt.Sprintf("subcontainers container info for %q", name))
+	if err != nil {
+		return []info.ContainerInfo{}, err
+
+	}
+	return response, nil
+}
+
+// Selects containers by name, alias and Docker labels. Empty fields select
+// all containers, the others must all match.
+type ContainerFilter struct {
+	// RE2 regular expression matched against the absolute name of the container.
+	NameRegexp string
+
+	// RE2 regular expression matched against the aliases of the container.
+	AliasRegexp string
+
+	// Labels the container must have with the specified values.
+	Labels map[string]string
+}
+
+// Returns the query parameters of the filter.
+func (self *ContainerFilter) values() url.Values {
+	params := url.Values{}
+	if self.NameRegexp != "" {
+		params.Set("name_re", self.NameRegexp)
+	}
+	if self.AliasRegexp != "" {
+		params.Set("alias_re", self.AliasRegexp)
+	}
+	keys := make([]string, 0, len(self.Labels))
+	for key := range self.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		params.Add("label", key+"="+self.Labels[key])
+	}
+	return params
+}
+
+// Returns the information about the subcontainers (recursive) of the
+// specified container (including itself) selected by the filter.
+func (self *Client) FilteredSubcontainersInfo(name string, query *info.ContainerInfoRequest, filter *ContainerFilter) ([]info.ContainerInfo, error) {
+	return self.FilteredSubcontainersInfoCtx(background, name, query, filter)
+}
+
+// FilteredSubcontainersInfoCtx is FilteredSubcontainersInfo with its requests bounded by the context.
+func (self *Client) FilteredSubcontainersInfoCtx(ctx Context, name string, query *info.ContainerInfoRequest, filter *ContainerFilter) ([]info.ContainerInfo, error) {
+	var response []info.ContainerInfo
+	u, err := self.subcontainersInfoUrl(ctx, name)
+	if err != nil {
+		return []info.ContainerInfo{}, err
+	}
+	u += "?" + filter.values().Encode()
+	err = self.httpGetJsonData(ctx, &response, query, u, fmt.Sprintf("filtered subcontainers container info for %q", name))
+	if err != nil {
+		return []info.ContainerInfo{}, err
+	}
+	return response, nil
+}
 
+// Returns a page of at most limit subcontainers (recursive) of the specified
+// container (including itself), ordered by name. The page starts after the
+// page with the specified cursor, an empty cursor starts from the first page.
+func (self *Client) SubcontainersInfoPage(name string, query *info.ContainerInfoRequest, limit int, cursor string) (info.ContainerInfoPage, error) {
+	return self.SubcontainersInfoPageCtx(background, name, query, limit, cursor)
+}
+
+// SubcontainersInfoPageCtx is SubcontainersInfoPage with its requests bounded by the context.
+func (self *Client) SubcontainersInfoPageCtx(ctx Context, name string, query *info.ContainerInfoRequest, limit int, cursor string) (info.ContainerInfoPage, error) {
+	var response info.ContainerInfoPage
+	params := url.Values{}
+	params.Set("limit", strconv.Itoa(limit))
+	if cursor != "" {
+		params.Set("continue", cursor)
+	}
+	u, err := self.subcontainersInfoUrl(ctx, name)
+	if err != nil {
+		return info.ContainerInfoPage{}, err
+	}
+	u += "?" + params.Encode()
+	err = self.httpGetJsonData(ctx, &response, query, u, fmt.Sprintf("subcontainers container info page for %q", name))
+	if err != nil {
+		return info.ContainerInfoPage{}, err
 	}
 	return response, nil
 }
 
+// Iterates over the subcontainers of a container, requesting one page at a time.
+//
+//	it := client.SubcontainersIterator("/", query, 100)
+//	for it.Next() {
+//		cont := it.Container()
+//	}
+//	err := it.Err()
+type SubcontainersIterator struct {
+	client *Client
+	ctx    Context
+	name   string
+	query  *info.ContainerInfoRequest
+	limit  int
+
+	page    []info.ContainerInfo
+	current info.ContainerInfo
+	cursor  string
+	started bool
+	err     error
+}
+
+// Returns an iterator over the subcontainers (recursive) of the specified
+// container (including itself) which requests pages of at most limit
+// containers.
+func (self *Client) SubcontainersIterator(name string, query *info.ContainerInfoRequest, limit int) *SubcontainersIterator {
+	return self.SubcontainersIteratorCtx(background, name, query, limit)
+}
+
+// SubcontainersIteratorCtx is SubcontainersIterator with the requests of
+// every page bounded by the context.
+func (self *Client) SubcontainersIteratorCtx(ctx Context, name string, query *info.ContainerInfoRequest, limit int) *SubcontainersIterator {
+	return &SubcontainersIterator{
+		client: self,
+		ctx:    ctx,
+		name:   name,
+		query:  query,
+		limit:  limit,
+	}
+}
+
+// Advances to the next container, requesting the next page if needed. Returns
+// false when there are no more containers or a request failed.
+func (self *SubcontainersIterator) Next() bool {
+	for len(self.page) == 0 {
+		if self.err != nil || (self.started && self.cursor == "") {
+			return false
+		}
+		page, err := self.client.SubcontainersInfoPageCtx(self.ctx, self.name, self.query, self.limit, self.cursor)
+		if err != nil {
+			self.err = err
+			return false
+		}
+		self.started = true
+		self.page = page.Containers
+		self.cursor = page.Continue
+	}
+	self.current = self.page[0]
+	self.page = self.page[1:]
+	return true
+}
+
+// Returns the current container.
+func (self *SubcontainersIterator) Container() info.ContainerInfo {
+	return self.current
+}
+
+// Returns the error that stopped the iteration, if any.
+func (self *SubcontainersIterator) Err() error {
+	return self.err
+}
+
 // Returns the JSON container information for the specified
 // Docker container and request.
-func (self *Client) DockerContainer(name string, query *info.ContainerInfoRequest) (cinfo info.ContainerInfo, err error) {
-	u := self.dockerInfoUrl(name)
+func (self *Client) DockerContainer(name string, query *info.ContainerInfoRequest) (info.ContainerInfo, error) {
+	return self.DockerContainerCtx(background, name, query)
+}
+
+// DockerContainerCtx is DockerContainer with its requests bounded by the context.
+func (self *Client) DockerContainerCtx(ctx Context, name string, query *info.ContainerInfoRequest) (cinfo info.ContainerInfo, err error) {
+	u, err := self.dockerInfoUrl(ctx, name)
+	if err != nil {
+		return
+	}
 	ret := make(map[string]info.ContainerInfo)
-	if err = self.httpGetJsonData(&ret, query, u, fmt.Sprintf("Docker container info for %q", name)); err != nil {
+	if err = self.httpGetJsonData(ctx, &ret, query, u, fmt.Sprintf("Docker container info for %q", name)); err != nil {
 		return
 	}
 	if len(ret) != 1 {
@@ -99,10 +494,18 @@ func (self *Client) DockerContainer(name string, query *info.ContainerInfoReques
 }
 
 // Returns the JSON container information for all Docker containers.
-func (self *Client) AllDockerContainers(query *info.ContainerInfoRequest) (cinfo []info.ContainerInfo, err error) {
-	u := self.dockerInfoUrl("/")
+func (self *Client) AllDockerContainers(query *info.ContainerInfoRequest) ([]info.ContainerInfo, error) {
+	return self.AllDockerContainersCtx(background, query)
+}
+
+// AllDockerContainersCtx is AllDockerContainers with its requests bounded by the context.
+func (self *Client) AllDockerContainersCtx(ctx Context, query *info.ContainerInfoRequest) (cinfo []info.ContainerInfo, err error) {
+	u, err := self.dockerInfoUrl(ctx, "/")
+	if err != nil {
+		return
+	}
 	ret := make(map[string]info.ContainerInfo)
-	if err = self.httpGetJsonData(&ret, query, u, "all Docker containers info"); err != nil {
+	if err = self.httpGetJsonData(ctx, &ret, query, u, "all Docker containers info"); err != nil {
 		return
 	}
 	cinfo = make([]info.ContainerInfo, 0, len(ret))
@@ -112,24 +515,423 @@ func (self *Client) AllDockerContainers(query *info.ContainerInfoRequest) (cinfo
 	return
 }
 
-func (self *Client) machineInfoUrl() string {
-	return self.baseUrl + path.Join("machine")
+// Returns the JSON container information for the Docker containers selected by the filter.
+func (self *Client) FilteredDockerContainers(query *info.ContainerInfoRequest, filter *ContainerFilter) ([]info.ContainerInfo, error) {
+	return self.FilteredDockerContainersCtx(background, query, filter)
+}
+
+// FilteredDockerContainersCtx is FilteredDockerContainers with its requests bounded by the context.
+func (self *Client) FilteredDockerContainersCtx(ctx Context, query *info.ContainerInfoRequest, filter *ContainerFilter) ([]info.ContainerInfo, error) {
+	u, err := self.dockerInfoUrl(ctx, "/")
+	if err != nil {
+		return nil, err
+	}
+	u += "?" + filter.values().Encode()
+	ret := make(map[string]info.ContainerInfo)
+	if err := self.httpGetJsonData(ctx, &ret, query, u, "filtered Docker containers info"); err != nil {
+		return nil, err
+	}
+	cinfo := make([]info.ContainerInfo, 0, len(ret))
+	for _, cont := range ret {
+		cinfo = append(cinfo, cont)
+	}
+	return cinfo, nil
+}
+
+// Returns the containers under the specified container (inclusive) using the
+// most of the requested metric, most recent usage first.
+func (self *Client) Top(name string, request *v2.TopRequest) ([]v2.TopContainer, error) {
+	return self.TopCtx(background, name, request)
+}
+
+// TopCtx is Top with its request bounded by the context.
+func (self *Client) TopCtx(ctx Context, name string, request *v2.TopRequest) ([]v2.TopContainer, error) {
+	var response []v2.TopContainer
+	u := self.topUrl(name, request)
+	if err := self.httpGetJsonData(ctx, &response, nil, u, fmt.Sprintf("top containers of %q", name)); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// ContainersDiff returns the changes to the containers since the specified
+// revision of the container listing. The revision of the result is the one to
+// pass next. If the changes are no longer known (ResyncRequired is set), the
+// containers must be listed again.
+func (self *Client) ContainersDiff(since uint64) (v2.ContainerDiff, error) {
+	return self.ContainersDiffCtx(background, since)
+}
+
+// ContainersDiffCtx is ContainersDiff with its request bounded by the context.
+func (self *Client) ContainersDiffCtx(ctx Context, since uint64) (v2.ContainerDiff, error) {
+	var diff v2.ContainerDiff
+	u := self.containersDiffUrl(since)
+	if err := self.httpGetJsonData(ctx, &diff, nil, u, "containers diff"); err != nil {
+		return v2.ContainerDiff{}, err
+	}
+	return diff, nil
+}
+
+// Snapshot returns the stats of the specified container and its subcontainers
+// at a single instant, the cut: for each container, its most recent stats no
+// newer than the cut. The cut is the time of the oldest of the latest stats of
+// the containers, so that their stats can be summed consistently. Containers
+// without stats that old are listed in NoData.
+func (self *Client) Snapshot(name string) (v2.Snapshot, error) {
+	return self.SnapshotCtx(background, name)
+}
+
+// SnapshotCtx is Snapshot with its request bounded by the context.
+func (self *Client) SnapshotCtx(ctx Context, name string) (v2.Snapshot, error) {
+	var snapshot v2.Snapshot
+	u := self.snapshotUrl(name)
+	if err := self.httpGetJsonData(ctx, &snapshot, nil, u, fmt.Sprintf("snapshot of %q", name)); err != nil {
+		return v2.Snapshot{}, err
+	}
+	return snapshot, nil
+}
+
+// CollectionStatus returns the status of the collection of the stats of the
+// specified container, e.g.: whether it is degraded.
+func (self *Client) CollectionStatus(name string) (v2.CollectionStatus, error) {
+	return self.CollectionStatusCtx(background, name)
 }
 
-func (self *Client) containerInfoUrl(name string) string {
-	return self.baseUrl + path.Join("containers", name)
+// CollectionStatusCtx is CollectionStatus with its request bounded by the context.
+func (self *Client) CollectionStatusCtx(ctx Context, name string) (v2.CollectionStatus, error) {
+	var status v2.CollectionStatus
+	u := self.v2BaseUrl + containerPath("status", name)
+	if err := self.httpGetJsonData(ctx, &status, nil, u, fmt.Sprintf("collection status of %q", name)); err != nil {
+		return v2.CollectionStatus{}, err
+	}
+	return status, nil
 }
 
-func (self *Client) subcontainersInfoUrl(name string) string {
-	return self.baseUrl + path.Join("subcontainers", name)
+// DumpDiagnostics returns everything needed to diagnose how the specified
+// container is monitored: its spec, stats of the last minutes, events,
+// processes, collection status and the contents of some of its cgroup files.
+func (self *Client) DumpDiagnostics(name string) (v2.DiagnosticBundle, error) {
+	return self.DumpDiagnosticsCtx(background, name)
+}
+
+// DumpDiagnosticsCtx is DumpDiagnostics with its request bounded by the context.
+func (self *Client) DumpDiagnosticsCtx(ctx Context, name string) (v2.DiagnosticBundle, error) {
+	var bundle v2.DiagnosticBundle
+	u := self.v2BaseUrl + containerPath("debug/container", name)
+	if err := self.httpGetJsonData(ctx, &bundle, nil, u, fmt.Sprintf("diagnostics of %q", name)); err != nil {
+		return v2.DiagnosticBundle{}, err
+	}
+	return bundle, nil
 }
 
-func (self *Client) dockerInfoUrl(name string) string {
-	return self.baseUrl + path.Join("docker", name)
+// DeletedContainers returns the containers deleted recently as they were last
+// known, the most recently deleted first, without their stats.
+func (self *Client) DeletedContainers() ([]v2.DeletedContainer, error) {
+	return self.DeletedContainersCtx(background)
 }
 
-func (self *Client) httpGetJsonData(data, postData interface{}, url, infoName string) error {
-	var resp *http.Response
+// DeletedContainersCtx is DeletedContainers with its request bounded by the context.
+func (self *Client) DeletedContainersCtx(ctx Context) ([]v2.DeletedContainer, error) {
+	var deleted []v2.DeletedContainer
+	if err := self.httpGetJsonData(ctx, &deleted, nil, self.v2BaseUrl+"deleted", "deleted containers"); err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// DeletedContainer returns the specified recently deleted container as it was
+// last known, with its last stats.
+func (self *Client) DeletedContainer(name string) (v2.DeletedContainer, error) {
+	return self.DeletedContainerCtx(background, name)
+}
+
+// DeletedContainerCtx is DeletedContainer with its request bounded by the context.
+func (self *Client) DeletedContainerCtx(ctx Context, name string) (v2.DeletedContainer, error) {
+	var deleted v2.DeletedContainer
+	u := self.v2BaseUrl + containerPath("deleted", name)
+	if err := self.httpGetJsonData(ctx, &deleted, nil, u, fmt.Sprintf("deleted container %q", name)); err != nil {
+		return v2.DeletedContainer{}, err
+	}
+	return deleted, nil
+}
+
+// VersionInfo returns the version of cAdvisor and its optional behaviors,
+// e.g.: whether it is read-only (v2.FeatureReadOnly), in which case forced
+// collections and watches of events fail.
+func (self *Client) VersionInfo() (v2.VersionInfo, error) {
+	return self.VersionInfoCtx(background)
+}
+
+// VersionInfoCtx is VersionInfo with its request bounded by the context.
+func (self *Client) VersionInfoCtx(ctx Context) (v2.VersionInfo, error) {
+	var version v2.VersionInfo
+	if err := self.httpGetJsonData(ctx, &version, nil, self.v2BaseUrl+"version", "version"); err != nil {
+		return v2.VersionInfo{}, err
+	}
+	return version, nil
+}
+
+// Events returns the past events which satisfy the request, oldest first.
+func (self *Client) Events(request *events.Request) ([]*events.Event, error) {
+	return self.EventsCtx(background, request)
+}
+
+// EventsCtx is Events with its request bounded by the context.
+func (self *Client) EventsCtx(ctx Context, request *events.Request) ([]*events.Event, error) {
+	var evs []*events.Event
+	u := self.eventsUrl(request, true)
+	if err := self.httpGetJsonData(ctx, &evs, nil, u, "events"); err != nil {
+		return nil, err
+	}
+	return evs, nil
+}
+
+// EventStream is a stream of the events satisfying a request, as they happen.
+// Close() *must* be called.
+type EventStream struct {
+	url     string
+	ctx     Context
+	body    io.ReadCloser
+	decoder *json.Decoder
+
+	// Closed by Close() to stop waiting for the context to be done.
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// Next returns the next event, blocking until there is one. Returns io.EOF
+// once the stream ends, and a *CanceledError once its context is done.
+func (self *EventStream) Next() (*events.Event, error) {
+	var ev events.Event
+	if err := self.decoder.Decode(&ev); err != nil {
+		if ctxErr := self.ctx.Err(); ctxErr != nil {
+			return nil, &CanceledError{self.url, ctxErr}
+		}
+		return nil, err
+	}
+	return &ev, nil
+}
+
+// Close stops the stream. Pending calls to Next() return an error.
+func (self *EventStream) Close() error {
+	self.closeOnce.Do(func() {
+		close(self.closed)
+	})
+	return self.body.Close()
+}
+
+// WatchEvents streams the events which satisfy the request as they happen.
+func (self *Client) WatchEvents(request *events.Request) (*EventStream, error) {
+	return self.WatchEventsCtx(background, request)
+}
+
+// WatchEventsCtx is WatchEvents with the stream bounded by the context: it is
+// closed once the context is done.
+func (self *Client) WatchEventsCtx(ctx Context, request *events.Request) (*EventStream, error) {
+	u := self.eventsUrl(request, false)
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to make request to watch events from %q: %v", u, err)
+	}
+	resp, err := self.start(ctx, req)
+	if IsCanceled(err) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to watch events from %q: %v", u, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("request %q failed with error: %q", u, strings.TrimSpace(string(body)))
+	}
+	stream := &EventStream{
+		url:     u,
+		ctx:     ctx,
+		body:    resp.Body,
+		decoder: json.NewDecoder(resp.Body),
+		closed:  make(chan struct{}),
+	}
+	if done := ctx.Done(); done != nil {
+		go func() {
+			select {
+			case <-done:
+				stream.Close()
+			case <-stream.closed:
+			}
+		}()
+	}
+	return stream, nil
+}
+
+// Names of the query parameters selecting each type of event.
+var eventTypeParams = map[events.EventType]string{
+	events.TypeOom:                 "oom_events",
+	events.TypeContainerCreation:   "creation_events",
+	events.TypeContainerDeletion:   "deletion_events",
+	events.TypeCollectionDegraded:  "collection_events",
+	events.TypeCollectionRestored:  "collection_events",
+	events.TypeTimeJump:            "time_jump_events",
+	events.TypeContainerReattached: "reattach_events",
+	events.TypeCollectorRestarted:  "collector_restart_events",
+	events.TypeMemoryPressure:      "memory_pressure_events",
+	events.TypeCgroupRemount:       "cgroup_remount_events",
+	events.TypeCpuHotplug:          "cpu_hotplug_events",
+}
+
+// Returns the escaped path of the specified resource of a container. Names are
+// escaped here so that callers pass raw names, which may contain spaces, percent
+// signs or unicode (e.g.: "/system.slice/user@1000.service").
+func containerPath(resource, name string) string {
+	u := url.URL{Path: path.Join(resource, name)}
+	return u.String()
+}
+
+// Versions of the v1 API known to the client, most recent first.
+var apiVersions = []string{"v1.3", "v1.2", "v1.1", "v1.0"}
+
+// Returns the base URL of the most recent v1 API version supported by both
+// the client and cAdvisor. The versions cAdvisor supports are requested once.
+func (self *Client) apiBaseUrl(ctx Context) (string, error) {
+	self.baseUrlLock.Lock()
+	defer self.baseUrlLock.Unlock()
+	if self.baseUrl != "" {
+		return self.baseUrl, nil
+	}
+
+	u := self.url + "api/"
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to make request for the supported API versions to %q: %v", u, err)
+	}
+	resp, body, err := self.do(ctx, req)
+	if IsCanceled(err) {
+		return "", err
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to get the supported API versions from %q: %v", u, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("request %q failed with error: %q", u, strings.TrimSpace(string(body)))
+	}
+	version, err := negotiateApiVersion(string(body))
+	if err != nil {
+		return "", fmt.Errorf("unable to negotiate the API version with %q: %v", u, err)
+	}
+	self.baseUrl = fmt.Sprintf("%sapi/%s/", self.url, version)
+	return self.baseUrl, nil
+}
+
+// Returns the most recent of the client's API versions in the list of
+// versions supported by cAdvisor, e.g.: "Supported API versions: v1.0,v1.1,v2.0"
+func negotiateApiVersion(supported string) (string, error) {
+	i := strings.Index(supported, ":")
+	if i < 0 {
+		return "", fmt.Errorf("malformed list of API versions %q", supported)
+	}
+	versions := make(map[string]bool)
+	for _, version := range strings.Split(supported[i+1:], ",") {
+		versions[strings.TrimSpace(version)] = true
+	}
+	for _, version := range apiVersions {
+		if versions[version] {
+			return version, nil
+		}
+	}
+	return "", fmt.Errorf("no API version in common with %q", supported)
+}
+
+func (self *Client) machineInfoUrl(ctx Context) (string, error) {
+	baseUrl, err := self.apiBaseUrl(ctx)
+	if err != nil {
+		return "", err
+	}
+	return baseUrl + path.Join("machine"), nil
+}
+
+func (self *Client) containerInfoUrl(ctx Context, name string) (string, error) {
+	baseUrl, err := self.apiBaseUrl(ctx)
+	if err != nil {
+		return "", err
+	}
+	return baseUrl + containerPath("containers", name), nil
+}
+
+func (self *Client) subcontainersInfoUrl(ctx Context, name string) (string, error) {
+	baseUrl, err := self.apiBaseUrl(ctx)
+	if err != nil {
+		return "", err
+	}
+	return baseUrl + containerPath("subcontainers", name), nil
+}
+
+func (self *Client) dockerInfoUrl(ctx Context, name string) (string, error) {
+	baseUrl, err := self.apiBaseUrl(ctx)
+	if err != nil {
+		return "", err
+	}
+	return baseUrl + containerPath("docker", name), nil
+}
+
+func (self *Client) specUrl(name string) string {
+	return self.v2BaseUrl + containerPath("spec", name)
+}
+
+func (self *Client) containersDiffUrl(since uint64) string {
+	return self.v2BaseUrl + "containers/diff?since=" + strconv.FormatUint(since, 10)
+}
+
+func (self *Client) snapshotUrl(name string) string {
+	return self.v2BaseUrl + containerPath("snapshot", name)
+}
+
+func (self *Client) eventsUrl(request *events.Request, historical bool) string {
+	query := url.Values{}
+	if historical {
+		query.Set("historical", "true")
+	}
+	for eventType, wanted := range request.EventType {
+		if param, ok := eventTypeParams[eventType]; ok && wanted {
+			query.Set(param, "true")
+		}
+	}
+	if request.IncludeSubcontainers {
+		query.Set("subcontainers", "true")
+	}
+	if request.MaxEventsReturned != 0 {
+		query.Set("max_events", strconv.Itoa(request.MaxEventsReturned))
+	}
+	if !request.StartTime.IsZero() {
+		query.Set("start_time", request.StartTime.Format(time.RFC3339))
+	}
+	if !request.EndTime.IsZero() {
+		query.Set("end_time", request.EndTime.Format(time.RFC3339))
+	}
+	if request.ContainerGlob != "" {
+		query.Set("name_glob", request.ContainerGlob)
+	}
+	return self.v2BaseUrl + containerPath("events", request.ContainerName) + "?" + query.Encode()
+}
+
+func (self *Client) topUrl(name string, request *v2.TopRequest) string {
+	query := url.Values{}
+	if request.Metric != "" {
+		query.Set("metric", request.Metric)
+	}
+	if request.Count > 0 {
+		query.Set("count", strconv.Itoa(request.Count))
+	}
+	if request.ExcludeRoot {
+		query.Set("exclude_root", "true")
+	}
+	if request.Aggregate {
+		query.Set("aggregate", "true")
+	}
+	return self.v2BaseUrl + containerPath("top", name) + "?" + query.Encode()
+}
+
+func (self *Client) httpGetJsonData(ctx Context, data, postData interface{}, url, infoName string) error {
+	var req *http.Request
 	var err error
 
 	if postData != nil {
@@ -137,21 +939,22 @@ func (self *Client) httpGetJsonData(data, postData interface{}, url, infoName st
 		if err != nil {
 			return fmt.Errorf("unable to marshal data: %v", err)
 		}
-		resp, err = http.Post(url, "application/json", bytes.NewBuffer(data))
+		req, err = http.NewRequest("POST", url, bytes.NewBuffer(data))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
 	} else {
-		resp, err = http.Get(url)
+		req, err = http.NewRequest("GET", url, nil)
 	}
 	if err != nil {
-		return fmt.Errorf("unable to get %q from %q: %v", infoName, url, err)
+		return fmt.Errorf("unable to make request for %q to %q: %v", infoName, url, err)
 	}
-	if resp == nil {
-		return fmt.Errorf("received empty response for %q from %q", infoName, url)
+	resp, body, err := self.do(ctx, req)
+	if IsCanceled(err) {
+		return err
 	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		err = fmt.Errorf("unable to read all %q from %q: %v", infoName, url, err)
-		return err
+		return fmt.Errorf("unable to get %q from %q: %v", infoName, url, err)
 	}
 	if resp.StatusCode != 200 {
 		return fmt.Errorf("request %q failed with error: %q", url, strings.TrimSpace(string(body)))
@@ -162,3 +965,43 @@ func (self *Client) httpGetJsonData(data, postData interface{}, url, infoName st
 	}
 	return nil
 }
+
+// Gets the JSON data at the specified URL unless it did not change since the
+// last call for the URL, according to its ETag. Returns whether it changed,
+// data is only populated if it did.
+func (self *Client) httpGetJsonDataIfChanged(ctx Context, data interface{}, url, infoName string) (bool, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return false, fmt.Errorf("unable to make request for %q to %q: %v", infoName, url, err)
+	}
+	self.etagsLock.Lock()
+	etag, ok := self.etags[url]
+	self.etagsLock.Unlock()
+	if ok {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, body, err := self.do(ctx, req)
+	if IsCanceled(err) {
+		return false, err
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to get %q from %q: %v", infoName, url, err)
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return false, nil
+	}
+	if resp.StatusCode != 200 {
+		return false, fmt.Errorf("request %q failed with error: %q", url, strings.TrimSpace(string(body)))
+	}
+	if err = json.Unmarshal(body, data); err != nil {
+		return false, fmt.Errorf("unable to unmarshal %q (Body: %q) from %q with error: %v", infoName, string(body), url, err)
+	}
+	self.etagsLock.Lock()
+	defer self.etagsLock.Unlock()
+	if newEtag := resp.Header.Get("ETag"); newEtag != "" {
+		self.etags[url] = newEtag
+	} else {
+		delete(self.etags, url)
+	}
+	return true, nil
+}
@@ -12,68 +12,61 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// +build linux
+
 package client
 
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
-	"net/http/httptest"
+	"os"
 	"path"
 	"reflect"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/cadvisor/client/fake"
+	"github.com/google/cadvisor/events"
+	cadvisorHttp "github.com/google/cadvisor/http"
 	info "github.com/google/cadvisor/info/v1"
 	itest "github.com/google/cadvisor/info/v1/test"
-	"github.com/kr/pretty"
+	"github.com/google/cadvisor/info/v2"
 )
 
-func testGetJsonData(
-	expected interface{},
-	f func() (interface{}, error),
-) error {
-	reply, err := f()
-	if err != nil {
-		return fmt.Errorf("unable to retrieve data: %v", err)
-	}
-	if !reflect.DeepEqual(reply, expected) {
-		return pretty.Errorf("retrieved wrong data: %# v != %# v", reply, expected)
-	}
-	return nil
-}
-
-func cadvisorTestClient(path string, expectedPostObj *info.ContainerInfoRequest, replyObj interface{}, t *testing.T) (*Client, *httptest.Server, error) {
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == path {
-			if expectedPostObj != nil {
-				expectedPostObjEmpty := new(info.ContainerInfoRequest)
-				decoder := json.NewDecoder(r.Body)
-				if err := decoder.Decode(expectedPostObjEmpty); err != nil {
-					t.Errorf("Received invalid object: %v", err)
-				}
-				if expectedPostObj.NumStats != expectedPostObjEmpty.NumStats ||
-					expectedPostObj.Start.Unix() != expectedPostObjEmpty.Start.Unix() ||
-					expectedPostObj.End.Unix() != expectedPostObjEmpty.End.Unix() {
-					t.Errorf("Received unexpected object: %+v, expected: %+v", expectedPostObjEmpty, expectedPostObj)
-				}
-			}
-			encoder := json.NewEncoder(w)
-			encoder.Encode(replyObj)
-		} else if r.URL.Path == "/api/v1.2/machine" {
-			fmt.Fprint(w, `{"num_cores":8,"memory_capacity":31625871360, "disk_map":["8:0":{"name":"sda","major":8,"minor":0,"size":10737418240}]}`)
-		} else {
-			w.WriteHeader(http.StatusNotFound)
-			fmt.Fprintf(w, "Page not found.")
-		}
-	}))
-	client, err := NewClient(ts.URL)
+// Returns a fake cAdvisor and a client to it. The fake must be closed.
+func cadvisorTestClient(t *testing.T) (*Client, *fake.FakeCadvisor) {
+	fakeCadvisor := fake.NewFakeCadvisor()
+	client, err := NewClient(fakeCadvisor.URL)
 	if err != nil {
-		ts.Close()
-		return nil, nil, err
+		fakeCadvisor.Close()
+		t.Fatalf("unable to get a client %v", err)
+	}
+	return client, fakeCadvisor
+}
+
+// Checks that the last request received by the fake was for the specified path and carried the query.
+func checkLastRequest(t *testing.T, fakeCadvisor *fake.FakeCadvisor, path string, query *info.ContainerInfoRequest) {
+	requests := fakeCadvisor.Requests()
+	if len(requests) == 0 {
+		t.Fatalf("no requests received")
+	}
+	request := requests[len(requests)-1]
+	if request.Path != path {
+		t.Errorf("Received request for %q, expected %q", request.Path, path)
+	}
+	received := new(info.ContainerInfoRequest)
+	if err := json.Unmarshal(request.Body, received); err != nil {
+		t.Fatalf("Received invalid object: %v", err)
+	}
+	if query.NumStats != received.NumStats ||
+		query.Start.Unix() != received.Start.Unix() ||
+		query.End.Unix() != received.End.Unix() {
+		t.Errorf("Received unexpected object: %+v, expected: %+v", received, query)
 	}
-	return client, ts, err
 }
 
 // TestGetMachineInfo performs one test to check if MachineInfo()
@@ -91,11 +84,9 @@ func TestGetMachineinfo(t *testing.T) {
 			},
 		},
 	}
-	client, server, err := cadvisorTestClient("/api/v1.2/machine", nil, minfo, t)
-	if err != nil {
-		t.Fatalf("unable to get a client %v", err)
-	}
-	defer server.Close()
+	client, fakeCadvisor := cadvisorTestClient(t)
+	defer fakeCadvisor.Close()
+	fakeCadvisor.SetMachineInfo(minfo)
 	returned, err := client.MachineInfo()
 	if err != nil {
 		t.Fatal(err)
@@ -113,11 +104,11 @@ func TestGetContainerInfo(t *testing.T) {
 	}
 	containerName := "/some/container"
 	cinfo := itest.GenerateRandomContainerInfo(containerName, 4, query, 1*time.Second)
-	client, server, err := cadvisorTestClient(fmt.Sprintf("/api/v1.2/containers%v", containerName), query, cinfo, t)
-	if err != nil {
-		t.Fatalf("unable to get a client %v", err)
+	client, fakeCadvisor := cadvisorTestClient(t)
+	defer fakeCadvisor.Close()
+	if err := fakeCadvisor.SetContainerInfo(cinfo); err != nil {
+		t.Fatal(err)
 	}
-	defer server.Close()
 	returned, err := client.ContainerInfo(containerName, query)
 	if err != nil {
 		t.Fatal(err)
@@ -126,28 +117,114 @@ func TestGetContainerInfo(t *testing.T) {
 	if !returned.Eq(cinfo) {
 		t.Error("received unexpected ContainerInfo")
 	}
+	checkLastRequest(t, fakeCadvisor, fmt.Sprintf("/api/v1.3/containers%v", containerName), query)
 }
 
-// Test a request failing
-func TestRequestFails(t *testing.T) {
-	errorText := "there was an error"
-	// Setup a server that simply fails.
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		http.Error(w, errorText, 500)
-	}))
-	client, err := NewClient(ts.URL)
+func TestAncestryInfo(t *testing.T) {
+	query := &info.ContainerInfoRequest{
+		NumStats: 3,
+	}
+	client, fakeCadvisor := cadvisorTestClient(t)
+	defer fakeCadvisor.Close()
+	// "/a" is not tracked.
+	containers := map[string]*info.ContainerInfo{}
+	for _, name := range []string{"/", "/a/b", "/a/b/c"} {
+		containers[name] = itest.GenerateRandomContainerInfo(name, 4, query, 1*time.Second)
+		if err := fakeCadvisor.SetContainerInfo(containers[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ancestry, err := client.AncestryInfo("/a/b/c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, ancestor := range ancestry {
+		names = append(names, ancestor.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"/", "/a", "/a/b", "/a/b/c"}) {
+		t.Fatalf("received ancestors %v, expected /, /a, /a/b then /a/b/c", names)
+	}
+	if untracked := ancestry[1]; untracked.Tracked || untracked.Spec != nil || untracked.Stats != nil {
+		t.Errorf("received %+v, expected /a untracked without spec or stats", untracked)
+	}
+	for _, ancestor := range []info.ContainerAncestor{ancestry[0], ancestry[2], ancestry[3]} {
+		expected := containers[ancestor.Name]
+		if !ancestor.Tracked || ancestor.Spec == nil || ancestor.Stats == nil {
+			t.Errorf("received %+v, expected %q tracked with its spec and stats", ancestor, ancestor.Name)
+			continue
+		}
+		if !ancestor.Spec.Eq(&expected.Spec) {
+			t.Errorf("received spec %+v for %q, expected %+v", ancestor.Spec, ancestor.Name, expected.Spec)
+		}
+		if latest := expected.Stats[len(expected.Stats)-1]; !ancestor.Stats.Timestamp.Equal(latest.Timestamp) {
+			t.Errorf("received stats at %v for %q, expected the latest at %v", ancestor.Stats.Timestamp, ancestor.Name, latest.Timestamp)
+		}
+	}
+	requests := fakeCadvisor.Requests()
+	if request := requests[len(requests)-1]; request.Path != "/api/v1.3/containers/a/b/c" || request.RawQuery != "include_ancestors=true" {
+		t.Errorf("received request %+v, expected one for the ancestors of /a/b/c", request)
+	}
+
+	// An untracked container has no ancestry.
+	if _, err := client.AncestryInfo("/a"); err == nil {
+		t.Errorf("expected an error for a container which is not tracked")
+	}
+}
+
+func TestCollectNow(t *testing.T) {
+	query := &info.ContainerInfoRequest{
+		NumStats: 3,
+	}
+	containerName := "/some/container"
+	cinfo := itest.GenerateRandomContainerInfo(containerName, 4, query, 1*time.Second)
+	client, fakeCadvisor := cadvisorTestClient(t)
+	defer fakeCadvisor.Close()
+	if err := fakeCadvisor.SetContainerInfo(cinfo); err != nil {
+		t.Fatal(err)
+	}
+	returned, err := client.CollectNow(containerName, query)
 	if err != nil {
-		ts.Close()
 		t.Fatal(err)
 	}
-	defer ts.Close()
+	if len(returned.Stats) != 1 {
+		t.Fatalf("received %d stats, expected only the collected ones", len(returned.Stats))
+	}
+	if !returned.Stats[0].Eq(cinfo.Stats[len(cinfo.Stats)-1]) {
+		t.Error("received unexpected stats")
+	}
+	checkLastRequest(t, fakeCadvisor, fmt.Sprintf("/api/v1.3/containers%v", containerName), query)
+}
+
+func TestRateLimitedErrorFromHeaders(t *testing.T) {
+	header := http.Header{}
+	header.Set("Retry-After", "1")
+	header.Set("X-Cadvisor-Newest-Sample-Age", "1.5s")
+	err := newRateLimitedError("http://localhost/api/v1.3/containers/?collect=true", header)
+	if err.RetryAfter != time.Second || err.NewestSampleAge != 1500*time.Millisecond {
+		t.Errorf("unexpected error from headers %v: %+v", header, err)
+	}
+
+	// Without stats yet, the age is negative.
+	err = newRateLimitedError("http://localhost/api/v1.3/containers/?collect=true", http.Header{})
+	if err.NewestSampleAge >= 0 {
+		t.Errorf("expected a negative age without the header, got %v", err.NewestSampleAge)
+	}
+}
+
+// Test a request failing
+func TestRequestFails(t *testing.T) {
+	client, fakeCadvisor := cadvisorTestClient(t)
+	defer fakeCadvisor.Close()
+	fakeCadvisor.InjectError("/api/v1.3/containers", http.StatusInternalServerError)
 
-	_, err = client.ContainerInfo("/", &info.ContainerInfoRequest{NumStats: 3})
+	_, err := client.ContainerInfo("/", &info.ContainerInfoRequest{NumStats: 3})
 	if err == nil {
 		t.Fatalf("Expected non-nil error")
 	}
-	expectedError := fmt.Sprintf("request failed with error: %q", errorText)
-	if strings.Contains(err.Error(), expectedError) {
+	expectedError := http.StatusText(http.StatusInternalServerError)
+	if !strings.Contains(err.Error(), expectedError) {
 		t.Fatalf("Expected error %q but received %q", expectedError, err)
 	}
 }
@@ -160,23 +237,21 @@ func TestGetSubcontainersInfo(t *testing.T) {
 	cinfo := itest.GenerateRandomContainerInfo(containerName, 4, query, 1*time.Second)
 	cinfo1 := itest.GenerateRandomContainerInfo(path.Join(containerName, "sub1"), 4, query, 1*time.Second)
 	cinfo2 := itest.GenerateRandomContainerInfo(path.Join(containerName, "sub2"), 4, query, 1*time.Second)
-	response := []info.ContainerInfo{
-		*cinfo,
-		*cinfo1,
-		*cinfo2,
-	}
-	client, server, err := cadvisorTestClient(fmt.Sprintf("/api/v1.2/subcontainers%v", containerName), query, response, t)
-	if err != nil {
-		t.Fatalf("unable to get a client %v", err)
+	other := itest.GenerateRandomContainerInfo("/other", 4, query, 1*time.Second)
+	client, fakeCadvisor := cadvisorTestClient(t)
+	defer fakeCadvisor.Close()
+	for _, c := range []*info.ContainerInfo{cinfo, cinfo1, cinfo2, other} {
+		if err := fakeCadvisor.SetContainerInfo(c); err != nil {
+			t.Fatal(err)
+		}
 	}
-	defer server.Close()
 	returned, err := client.SubcontainersInfo(containerName, query)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	if len(returned) != 3 {
-		t.Errorf("unexpected number of results: got %d, expected 3", len(returned))
+		t.Fatalf("unexpected number of results: got %d, expected 3", len(returned))
 	}
 	if !returned[0].Eq(cinfo) {
 		t.Error("received unexpected ContainerInfo")
@@ -187,4 +262,725 @@ func TestGetSubcontainersInfo(t *testing.T) {
 	if !returned[2].Eq(cinfo2) {
 		t.Error("received unexpected ContainerInfo")
 	}
+	checkLastRequest(t, fakeCadvisor, fmt.Sprintf("/api/v1.3/subcontainers%v", containerName), query)
+}
+
+func TestSubcontainersIterator(t *testing.T) {
+	query := &info.ContainerInfoRequest{
+		NumStats: 1,
+	}
+	client, fakeCadvisor := cadvisorTestClient(t)
+	defer fakeCadvisor.Close()
+	var expected []string
+	for i := 0; i < 25; i++ {
+		name := fmt.Sprintf("/some/container/%02d", i)
+		if err := fakeCadvisor.SetContainerInfo(itest.GenerateRandomContainerInfo(name, 1, query, 1*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		expected = append(expected, name)
+	}
+	if err := fakeCadvisor.SetContainerInfo(itest.GenerateRandomContainerInfo("/some/container", 1, query, 1*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	expected = append([]string{"/some/container"}, expected...)
+
+	var names []string
+	it := client.SubcontainersIterator("/some/container", query, 10)
+	for it.Next() {
+		names = append(names, it.Container().Name)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected containers %v, got %v", expected, names)
+	}
+
+	// The 26 containers are requested in 3 pages.
+	pages := 0
+	for _, request := range fakeCadvisor.Requests() {
+		if request.Path == "/api/v1.3/subcontainers/some/container" {
+			pages++
+			if !strings.Contains(request.RawQuery, "limit=10") {
+				t.Errorf("expected a limit of 10, got query %q", request.RawQuery)
+			}
+		}
+	}
+	if pages != 3 {
+		t.Errorf("expected 3 pages, got %d", pages)
+	}
+	checkLastRequest(t, fakeCadvisor, "/api/v1.3/subcontainers/some/container", query)
+}
+
+func TestSubcontainersIteratorReportsErrors(t *testing.T) {
+	client, fakeCadvisor := cadvisorTestClient(t)
+	defer fakeCadvisor.Close()
+
+	it := client.SubcontainersIterator("/unknown", &info.ContainerInfoRequest{}, 10)
+	if it.Next() {
+		t.Errorf("expected no containers, got %+v", it.Container())
+	}
+	if it.Err() == nil {
+		t.Errorf("expected the failed request to be reported")
+	}
+}
+
+func TestContainerFilterValues(t *testing.T) {
+	filter := &ContainerFilter{
+		NameRegexp:  "^/docker/",
+		AliasRegexp: "^web-",
+		Labels:      map[string]string{"tier": "frontend", "env": "prod"},
+	}
+	expected := "alias_re=%5Eweb-&label=env%3Dprod&label=tier%3Dfrontend&name_re=%5E%2Fdocker%2F"
+	if encoded := filter.values().Encode(); encoded != expected {
+		t.Errorf("filter encoded as %q, expected %q", encoded, expected)
+	}
+	if encoded := (&ContainerFilter{}).values().Encode(); encoded != "" {
+		t.Errorf("empty filter encoded as %q", encoded)
+	}
+}
+
+func TestFilteredSubcontainersInfo(t *testing.T) {
+	query := &info.ContainerInfoRequest{
+		NumStats: 1,
+	}
+	client, fakeCadvisor := cadvisorTestClient(t)
+	defer fakeCadvisor.Close()
+	for i, labels := range []map[string]string{
+		{"env": "prod", "tier": "frontend"},
+		{"env": "dev", "tier": "frontend"},
+		{"env": "prod", "tier": "backend"},
+		nil,
+	} {
+		cinfo := itest.GenerateRandomContainerInfo(fmt.Sprintf("/some/container/%d", i), 1, query, 1*time.Second)
+		cinfo.Spec.Labels = labels
+		if err := fakeCadvisor.SetContainerInfo(cinfo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fakeCadvisor.SetContainerInfo(itest.GenerateRandomContainerInfo("/some/container", 1, query, 1*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	returned, err := client.FilteredSubcontainersInfo("/some/container", query, &ContainerFilter{
+		NameRegexp: "/[0-9]$",
+		Labels:     map[string]string{"env": "prod"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, cont := range returned {
+		names = append(names, cont.Name)
+	}
+	expected := []string{"/some/container/0", "/some/container/2"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected containers %v, got %v", expected, names)
+	}
+	checkLastRequest(t, fakeCadvisor, "/api/v1.3/subcontainers/some/container", query)
+
+	_, err = client.FilteredSubcontainersInfo("/some/container", query, &ContainerFilter{NameRegexp: "["})
+	if err == nil {
+		t.Errorf("expected an invalid regular expression to be rejected")
+	}
+}
+
+// Names are escaped by the client so that callers can pass raw names.
+func TestContainerNamesWithSpecialCharacters(t *testing.T) {
+	query := &info.ContainerInfoRequest{
+		NumStats: 3,
+	}
+	parent := "/system.slice/user@1000.service"
+	names := []string{
+		parent,
+		path.Join(parent, "with space"),
+		path.Join(parent, "100% done"),
+		path.Join(parent, "what?#now"),
+		path.Join(parent, "ünïcødé"),
+	}
+	client, fakeCadvisor := cadvisorTestClient(t)
+	defer fakeCadvisor.Close()
+	cinfos := make(map[string]*info.ContainerInfo, len(names))
+	for _, name := range names {
+		cinfos[name] = itest.GenerateRandomContainerInfo(name, 4, query, 1*time.Second)
+		if err := fakeCadvisor.SetContainerInfo(cinfos[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range names {
+		returned, err := client.ContainerInfo(name, query)
+		if err != nil {
+			t.Errorf("Failed to get %q: %v", name, err)
+			continue
+		}
+		if !returned.Eq(cinfos[name]) {
+			t.Errorf("Received unexpected ContainerInfo for %q: %+v", name, returned)
+		}
+		checkLastRequest(t, fakeCadvisor, "/api/v1.3/containers"+name, query)
+	}
+
+	returned, err := client.SubcontainersInfo(parent, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(returned) != len(names) {
+		t.Fatalf("unexpected number of results: got %d, expected %d", len(returned), len(names))
+	}
+	for _, cinfo := range returned {
+		if expected, ok := cinfos[cinfo.Name]; !ok || !cinfo.Eq(expected) {
+			t.Errorf("Received unexpected ContainerInfo for %q", cinfo.Name)
+		}
+	}
+}
+
+func TestContainerPath(t *testing.T) {
+	testCases := map[string]string{
+		"/":                               "containers",
+		"/docker/abcdef":                  "containers/docker/abcdef",
+		"/system.slice/user@1000.service": "containers/system.slice/user@1000.service",
+		"/with space":                     "containers/with%20space",
+		"/100%":                           "containers/100%25",
+		"/what?#now":                      "containers/what%3F%23now",
+	}
+	for name, expected := range testCases {
+		if actual := containerPath("containers", name); actual != expected {
+			t.Errorf("Path of %q is %q, expected %q", name, actual, expected)
+		}
+	}
+}
+
+func TestDockerContainer(t *testing.T) {
+	query := &info.ContainerInfoRequest{
+		NumStats: 3,
+	}
+	cinfo := itest.GenerateRandomContainerInfo("/docker/abcdef", 4, query, 1*time.Second)
+	cinfo.Aliases = []string{"abcdef", "web"}
+	cinfo.Namespace = "docker"
+	client, fakeCadvisor := cadvisorTestClient(t)
+	defer fakeCadvisor.Close()
+	if err := fakeCadvisor.SetContainerInfo(cinfo); err != nil {
+		t.Fatal(err)
+	}
+
+	returned, err := client.DockerContainer("web", query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !returned.Eq(cinfo) {
+		t.Error("received unexpected ContainerInfo")
+	}
+
+	all, err := client.AllDockerContainers(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || !all[0].Eq(cinfo) {
+		t.Errorf("received unexpected Docker containers: %+v", all)
+	}
+}
+
+func TestClientOverUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cadvisor-client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socketPath := path.Join(dir, "cadvisor.sock")
+
+	// Serve the fake's API on the socket.
+	fakeCadvisor := fake.NewFakeCadvisor()
+	defer fakeCadvisor.Close()
+	fakeCadvisor.SetMachineInfo(&info.MachineInfo{NumCores: 8})
+	listener, err := cadvisorHttp.ListenUnix(socketPath, 0600, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go http.Serve(listener, fakeCadvisor.Config.Handler)
+
+	client, err := NewClientWithUnixSocket(socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	minfo, err := client.MachineInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if minfo.NumCores != 8 {
+		t.Errorf("received %d cores, expected 8", minfo.NumCores)
+	}
+	requests := fakeCadvisor.Requests()
+	if len(requests) != 2 || requests[0].Path != "/api/" || requests[1].Path != "/api/v1.3/machine" {
+		t.Errorf("expected the API versions and machine requests, received %+v", requests)
+	}
+}
+
+func TestTop(t *testing.T) {
+	client, fakeCadvisor := cadvisorTestClient(t)
+	defer fakeCadvisor.Close()
+	fakeCadvisor.SetMachineInfo(&info.MachineInfo{NumCores: 2, MemoryCapacity: 1 << 30})
+	for _, name := range []string{"/", "/docker", "/docker/web"} {
+		cinfo := itest.GenerateRandomContainerInfo(name, 2, &info.ContainerInfoRequest{NumStats: 2}, time.Second)
+		if err := fakeCadvisor.SetContainerInfo(cinfo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	top, err := client.Top("/docker", &v2.TopRequest{Metric: "memory", Count: 1, ExcludeRoot: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 1 || top[0].Name != "/docker/web" {
+		t.Errorf("received unexpected top containers: %+v", top)
+	}
+	requests := fakeCadvisor.Requests()
+	request := requests[len(requests)-1]
+	if request.Path != "/api/v2.0/top/docker" {
+		t.Errorf("received request for %q, expected the top of /docker", request.Path)
+	}
+}
+
+func TestContainersDiff(t *testing.T) {
+	client, fakeCadvisor := cadvisorTestClient(t)
+	defer fakeCadvisor.Close()
+	cinfo := itest.GenerateRandomContainerInfo("/docker/a", 1, &info.ContainerInfoRequest{NumStats: 1}, time.Second)
+	if err := fakeCadvisor.SetContainerInfo(cinfo); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := client.ContainersDiff(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.ResyncRequired || diff.Revision != 1 {
+		t.Errorf("expected a resync at revision 1, got %+v", diff)
+	}
+	diff, err = client.ContainersDiff(diff.Revision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.ResyncRequired || diff.Revision != 1 {
+		t.Errorf("expected no changes since revision 1, got %+v", diff)
+	}
+	requests := fakeCadvisor.Requests()
+	request := requests[len(requests)-1]
+	if request.Path != "/api/v2.0/containers/diff" || request.RawQuery != "since=1" {
+		t.Errorf("received request for %q?%s, expected the containers diff since revision 1", request.Path, request.RawQuery)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	client, fakeCadvisor := cadvisorTestClient(t)
+	defer fakeCadvisor.Close()
+	start := time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+	// Containers sampled every 10s, each with its own offset.
+	for name, offsets := range map[string][]int{
+		"/":          {1, 11, 21},
+		"/system":    {2, 12, 22},
+		"/docker":    {4, 14, 24},
+		"/docker/a":  {8, 18},
+		"/docker/b":  {25},
+		"/docker/bc": {3, 13, 23},
+	} {
+		cinfo := &info.ContainerInfo{
+			ContainerReference: info.ContainerReference{Name: name},
+			Spec:               info.ContainerSpec{HasCpu: true},
+		}
+		for _, offset := range offsets {
+			cinfo.Stats = append(cinfo.Stats, &info.ContainerStats{Timestamp: start.Add(time.Duration(offset) * time.Second)})
+		}
+		if err := fakeCadvisor.SetContainerInfo(cinfo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"/", "/docker"} {
+		snapshot, err := client.Snapshot(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// /docker/a was last sampled the longest ago.
+		cut := start.Add(18 * time.Second)
+		if !snapshot.Timestamp.Equal(cut) {
+			t.Errorf("expected the cut of %q at %v, got %v", name, cut, snapshot.Timestamp)
+		}
+		expected := map[string]int{"/docker": 14, "/docker/a": 18, "/docker/bc": 13}
+		if name == "/" {
+			expected["/"] = 11
+			expected["/system"] = 12
+		}
+		if len(snapshot.Stats) != len(expected) {
+			t.Errorf("expected the stats of %d containers under %q, got %+v", len(expected), name, snapshot.Stats)
+		}
+		for container, offset := range expected {
+			if stats, ok := snapshot.Stats[container]; !ok || !stats.Timestamp.Equal(start.Add(time.Duration(offset)*time.Second)) {
+				t.Errorf("expected the stats of %q at offset %ds, got %+v", container, offset, stats)
+			}
+		}
+		if !reflect.DeepEqual(snapshot.NoData, []string{"/docker/b"}) {
+			t.Errorf("expected no data for /docker/b only, got %v", snapshot.NoData)
+		}
+	}
+	requests := fakeCadvisor.Requests()
+	request := requests[len(requests)-1]
+	if request.Path != "/api/v2.0/snapshot/docker" {
+		t.Errorf("received request for %q, expected the snapshot of /docker", request.Path)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	client, fakeCadvisor := cadvisorTestClient(t)
+	defer fakeCadvisor.Close()
+	now := time.Now()
+	for i, eventType := range []events.EventType{events.TypeContainerCreation, events.TypeOom} {
+		err := fakeCadvisor.AddEvent(&events.Event{
+			ContainerName: "/docker/a",
+			Timestamp:     now.Add(time.Duration(i) * time.Second),
+			EventType:     eventType,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	request := events.NewRequest()
+	request.EventType[events.TypeOom] = true
+	request.MaxEventsReturned = 10
+	evs, err := client.Events(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evs) != 1 || evs[0].EventType != events.TypeOom || evs[0].ContainerName != "/docker/a" {
+		t.Errorf("received unexpected events: %+v", evs)
+	}
+	requests := fakeCadvisor.Requests()
+	last := requests[len(requests)-1]
+	if last.Path != "/api/v2.0/events" || last.RawQuery != "historical=true&max_events=10&oom_events=true" {
+		t.Errorf("received request for %q?%s, expected the past OOM events", last.Path, last.RawQuery)
+	}
+}
+
+func TestWatchEvents(t *testing.T) {
+	client, fakeCadvisor := cadvisorTestClient(t)
+	defer fakeCadvisor.Close()
+
+	request := events.NewRequest()
+	request.EventType[events.TypeContainerDeletion] = true
+	stream, err := client.WatchEvents(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	for _, eventType := range []events.EventType{events.TypeOom, events.TypeContainerDeletion} {
+		err := fakeCadvisor.AddEvent(&events.Event{
+			ContainerName: "/docker/a",
+			Timestamp:     time.Now(),
+			EventType:     eventType,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	ev, err := stream.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.EventType != events.TypeContainerDeletion || ev.ContainerName != "/docker/a" {
+		t.Errorf("received unexpected event: %+v", ev)
+	}
+}
+
+func TestMachineInfoIfChanged(t *testing.T) {
+	client, fakeCadvisor := cadvisorTestClient(t)
+	defer fakeCadvisor.Close()
+	minfo := &info.MachineInfo{NumCores: 2, MemoryCapacity: 1 << 30}
+	fakeCadvisor.SetMachineInfo(minfo)
+
+	changed, returned, err := client.MachineInfoIfChanged()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || !reflect.DeepEqual(returned, minfo) {
+		t.Errorf("expected the machine info at the first request, got changed=%v %+v", changed, returned)
+	}
+	changed, returned, err = client.MachineInfoIfChanged()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed || returned != nil {
+		t.Errorf("expected the machine info to be unchanged, got changed=%v %+v", changed, returned)
+	}
+
+	minfo = &info.MachineInfo{NumCores: 4, MemoryCapacity: 1 << 30}
+	fakeCadvisor.SetMachineInfo(minfo)
+	changed, returned, err = client.MachineInfoIfChanged()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || !reflect.DeepEqual(returned, minfo) {
+		t.Errorf("expected the new machine info, got changed=%v %+v", changed, returned)
+	}
+}
+
+func TestContainerSpecIfChanged(t *testing.T) {
+	client, fakeCadvisor := cadvisorTestClient(t)
+	defer fakeCadvisor.Close()
+	for _, name := range []string{"/docker/a", "/docker/b"} {
+		cinfo := itest.GenerateRandomContainerInfo(name, 2, &info.ContainerInfoRequest{NumStats: 1}, time.Second)
+		if err := fakeCadvisor.SetContainerInfo(cinfo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// ETags are kept per container.
+	for _, name := range []string{"/docker/a", "/docker/b"} {
+		changed, spec, err := client.ContainerSpecIfChanged(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !changed || spec == nil {
+			t.Errorf("expected the spec of %q at the first request, got changed=%v %+v", name, changed, spec)
+		}
+	}
+	changed, spec, err := client.ContainerSpecIfChanged("/docker/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed || spec != nil {
+		t.Errorf("expected the spec to be unchanged, got changed=%v %+v", changed, spec)
+	}
+	requests := fakeCadvisor.Requests()
+	if path := requests[len(requests)-1].Path; path != "/api/v2.0/spec/docker/a" {
+		t.Errorf("received request for %q, expected the spec of /docker/a", path)
+	}
+
+	cinfo := itest.GenerateRandomContainerInfo("/docker/a", 4, &info.ContainerInfoRequest{NumStats: 1}, time.Second)
+	if err := fakeCadvisor.SetContainerInfo(cinfo); err != nil {
+		t.Fatal(err)
+	}
+	changed, spec, err = client.ContainerSpecIfChanged("/docker/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || spec == nil {
+		t.Errorf("expected the new spec, got changed=%v %+v", changed, spec)
+	}
+}
+
+func TestNegotiateApiVersion(t *testing.T) {
+	testCases := []struct {
+		supported string
+		expected  string
+	}{
+		{"Supported API versions: v1.0,v1.1,v1.2,v1.3,v2.0", "v1.3"},
+		{"Supported API versions: v1.0,v1.1,v1.2,v2.0", "v1.2"},
+		{"Supported API versions: v1.0", "v1.0"},
+		{"Supported API versions: v1.0,v1.4", "v1.0"},
+	}
+	for _, c := range testCases {
+		version, err := negotiateApiVersion(c.supported)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", c.supported, err)
+		} else if version != c.expected {
+			t.Errorf("negotiated %q for %q, expected %q", version, c.supported, c.expected)
+		}
+	}
+
+	for _, bad := range []string{"", "v1.3", "Supported API versions: v2.0,v1.4"} {
+		if _, err := negotiateApiVersion(bad); err == nil {
+			t.Errorf("expected error negotiating with %q", bad)
+		}
+	}
+}
+
+func TestApiVersionIsNegotiatedOnce(t *testing.T) {
+	client, fakeCadvisor := cadvisorTestClient(t)
+	defer fakeCadvisor.Close()
+	fakeCadvisor.InjectError("/api/", http.StatusInternalServerError)
+	if _, err := client.MachineInfo(); err == nil {
+		t.Fatalf("expected error when the API versions are unavailable")
+	}
+
+	fakeCadvisor.InjectError("/api/", 0)
+	fakeCadvisor.ClearRequests()
+	for i := 0; i < 2; i++ {
+		if _, err := client.MachineInfo(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var paths []string
+	for _, request := range fakeCadvisor.Requests() {
+		paths = append(paths, request.Path)
+	}
+	expected := []string{"/api/", "/api/v1.3/machine", "/api/v1.3/machine"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("received requests for %v, expected %v", paths, expected)
+	}
+}
+
+func TestNewClientUrls(t *testing.T) {
+	for _, u := range []string{
+		"http://localhost:8080/",
+		"http://10.240.0.2:8080",
+		"http://[::1]:8080/",
+		"https://[2001:db8::2]:8080/",
+	} {
+		if _, err := NewClient(u); err != nil {
+			t.Errorf("unexpected error for %q: %v", u, err)
+		}
+	}
+	for _, u := range []string{"http://::1:8080/", "http://2001:db8::2/", "http://%zz/"} {
+		if _, err := NewClient(u); err == nil {
+			t.Errorf("expected an error for %q", u)
+		}
+	}
+}
+
+func TestClientOverIpv6(t *testing.T) {
+	l, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("no IPv6 loopback address on this host: %v", err)
+	}
+	fakeCadvisor := fake.NewFakeCadvisorWithListener(l)
+	defer fakeCadvisor.Close()
+	fakeCadvisor.SetMachineInfo(&info.MachineInfo{NumCores: 4})
+	if !strings.HasPrefix(fakeCadvisor.URL, "http://[::1]:") {
+		t.Fatalf("expected the fake to serve on [::1], serving at %q", fakeCadvisor.URL)
+	}
+	client, err := NewClient(fakeCadvisor.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	machineInfo, err := client.MachineInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if machineInfo.NumCores != 4 {
+		t.Errorf("received %d cores, expected 4", machineInfo.NumCores)
+	}
+}
+
+func TestVersionInfo(t *testing.T) {
+	client, fakeCadvisor := cadvisorTestClient(t)
+	defer fakeCadvisor.Close()
+	version, err := client.VersionInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if readOnly, ok := version.Features[v2.FeatureReadOnly]; !ok || readOnly {
+		t.Errorf("the fake cAdvisor reported features %v, expected it not to be read-only", version.Features)
+	}
+	requests := fakeCadvisor.Requests()
+	if path := requests[len(requests)-1].Path; path != "/api/v2.0/version" {
+		t.Errorf("received request for %q, expected the version", path)
+	}
+}
+
+func TestDeletedContainers(t *testing.T) {
+	client, fakeCadvisor := cadvisorTestClient(t)
+	defer fakeCadvisor.Close()
+	deletionTime := time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+	fakeCadvisor.AddDeletedContainer(v2.DeletedContainer{
+		Name:         "/docker/old",
+		DeletionTime: deletionTime,
+	})
+	fakeCadvisor.AddDeletedContainer(v2.DeletedContainer{
+		Name:         "/docker/oom",
+		DeletionTime: deletionTime.Add(time.Minute),
+		Stats:        []*info.ContainerStats{{Timestamp: deletionTime}},
+	})
+
+	deleted, err := client.DeletedContainers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 2 || deleted[0].Name != "/docker/oom" || deleted[1].Name != "/docker/old" {
+		t.Fatalf("received deleted containers %+v, expected /docker/oom then /docker/old", deleted)
+	}
+	if len(deleted[0].Stats) != 0 {
+		t.Errorf("received stats %+v when listing the deleted containers, expected none", deleted[0].Stats)
+	}
+
+	oom, err := client.DeletedContainer("/docker/oom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !oom.DeletionTime.Equal(deletionTime.Add(time.Minute)) || len(oom.Stats) != 1 {
+		t.Errorf("received deleted container %+v, expected /docker/oom with its stats", oom)
+	}
+	if _, err := client.DeletedContainer("/docker/unknown"); err == nil {
+		t.Errorf("expected an error for a container which was not deleted")
+	}
+}
+
+func TestCollectionStatus(t *testing.T) {
+	client, fakeCadvisor := cadvisorTestClient(t)
+	defer fakeCadvisor.Close()
+	if err := fakeCadvisor.SetContainerInfo(&info.ContainerInfo{ContainerReference: info.ContainerReference{Name: "/docker/web"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.CollectionStatus("/docker/web"); err != nil {
+		t.Fatal(err)
+	}
+	requests := fakeCadvisor.Requests()
+	if len(requests) == 0 || requests[len(requests)-1].Path != "/api/v2.0/status/docker/web" {
+		t.Errorf("received requests %+v, expected the last one for /api/v2.0/status/docker/web", requests)
+	}
+	if _, err := client.CollectionStatus("/docker/unknown"); err == nil {
+		t.Errorf("expected an error for an unknown container")
+	}
+}
+
+func TestDumpDiagnostics(t *testing.T) {
+	client, fakeCadvisor := cadvisorTestClient(t)
+	defer fakeCadvisor.Close()
+	cinfo := itest.GenerateRandomContainerInfo("/docker/web", 2, &info.ContainerInfoRequest{NumStats: 3}, time.Second)
+	for i, stats := range cinfo.Stats {
+		// Within the last minutes.
+		stats.Timestamp = time.Now().Add(time.Duration(i-len(cinfo.Stats)) * time.Second)
+	}
+	if err := fakeCadvisor.SetContainerInfo(cinfo); err != nil {
+		t.Fatal(err)
+	}
+	diagnostics := v2.ContainerDiagnostics{
+		Processes:   []v2.ProcessInfo{{Pid: 42, Name: "nginx", State: "S"}},
+		CgroupFiles: map[string]string{"memory/memory.limit_in_bytes": "1048576\n"},
+	}
+	fakeCadvisor.SetContainerDiagnostics("/docker/web", diagnostics)
+
+	bundle, err := client.DumpDiagnostics("/docker/web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bundle.Name != "/docker/web" || len(bundle.Stats) != 3 {
+		t.Errorf("received unexpected bundle %+v, expected the 3 stats of /docker/web", bundle)
+	}
+	if !reflect.DeepEqual(bundle.ContainerDiagnostics, diagnostics) {
+		t.Errorf("received diagnostics %+v, expected %+v", bundle.ContainerDiagnostics, diagnostics)
+	}
+	requests := fakeCadvisor.Requests()
+	if path := requests[len(requests)-1].Path; path != "/api/v2.0/debug/container/docker/web" {
+		t.Errorf("received request for %q, expected the diagnostics of /docker/web", path)
+	}
+}
+
+func TestClientWithTracingTransport(t *testing.T) {
+	fakeCadvisor := fake.NewFakeCadvisor()
+	defer fakeCadvisor.Close()
+	fakeCadvisor.SetMachineInfo(&info.MachineInfo{NumCores: 2})
+	log := new(traceLog)
+	client, err := NewClientWithTransport(fakeCadvisor.URL, NewTracingTransport(nil, log.Logf))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.MachineInfo(); err != nil {
+		t.Fatal(err)
+	}
+	if len(log.lines) != 2 || !strings.Contains(log.lines[1], "/api/v1.3/machine") {
+		t.Errorf("Expected traces of the API versions and machine requests, found %q", log.lines)
+	}
 }
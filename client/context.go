@@ -0,0 +1,202 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Context bounds the requests made by a method of the client: they are
+// aborted once Done is closed, e.g.: when the caller cancels them or the
+// deadline passes. context.Context satisfies it, so do the contexts of
+// golang.org/x/net/context. Callers without either can use NewContext.
+type Context interface {
+	// The time at which Done is closed, ok is false if there is none.
+	Deadline() (deadline time.Time, ok bool)
+
+	// Closed when the requests are to be aborted. Nil if they never are.
+	Done() <-chan struct{}
+
+	// Why Done was closed (e.g.: Canceled or DeadlineExceeded), nil while it is not.
+	Err() error
+}
+
+var (
+	// Err of the contexts of NewContext which were canceled.
+	Canceled = errors.New("context canceled")
+
+	// Err of the contexts of NewContext whose deadline passed.
+	DeadlineExceeded = errors.New("context deadline exceeded")
+)
+
+// The context of the methods without one, which is never done.
+type backgroundContext struct{}
+
+func (backgroundContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (backgroundContext) Done() <-chan struct{}       { return nil }
+func (backgroundContext) Err() error                  { return nil }
+
+var background Context = backgroundContext{}
+
+type cancelContext struct {
+	deadline time.Time
+	done     chan struct{}
+	timer    *time.Timer
+
+	// Protects err.
+	lock sync.Mutex
+	err  error
+}
+
+// NewContext returns a Context which is done when cancel is called or the
+// deadline passes, whichever comes first. A zero deadline is none. cancel
+// must be called once the context is no longer used.
+func NewContext(deadline time.Time) (ctx Context, cancel func()) {
+	c := &cancelContext{
+		deadline: deadline,
+		done:     make(chan struct{}),
+	}
+	if !deadline.IsZero() {
+		c.timer = time.AfterFunc(deadline.Sub(time.Now()), func() {
+			c.finish(DeadlineExceeded)
+		})
+	}
+	return c, func() {
+		c.finish(Canceled)
+	}
+}
+
+// Closes done with the specified error, unless it already was.
+func (self *cancelContext) finish(err error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.err != nil {
+		return
+	}
+	self.err = err
+	close(self.done)
+	if self.timer != nil {
+		self.timer.Stop()
+	}
+}
+
+func (self *cancelContext) Deadline() (time.Time, bool) {
+	return self.deadline, !self.deadline.IsZero()
+}
+
+func (self *cancelContext) Done() <-chan struct{} {
+	return self.done
+}
+
+func (self *cancelContext) Err() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.err
+}
+
+// CanceledError is returned by the methods of the client whose context was
+// done before their requests completed.
+type CanceledError struct {
+	// URL of the aborted request.
+	Url string
+
+	// Err of the context, e.g.: Canceled or DeadlineExceeded.
+	Err error
+}
+
+func (self *CanceledError) Error() string {
+	return fmt.Sprintf("request %q aborted: %v", self.Url, self.Err)
+}
+
+// Returns whether the error is that of a request aborted by its context.
+func IsCanceled(err error) bool {
+	_, ok := err.(*CanceledError)
+	return ok
+}
+
+// Transports able to abort a request in progress, e.g.: http.Transport.
+type requestCanceler interface {
+	CancelRequest(req *http.Request)
+}
+
+// Result of a request made on behalf of do.
+type response struct {
+	resp *http.Response
+	body []byte
+	err  error
+}
+
+// Makes the request and reads the whole response body, unless the context is
+// done first. The request is then aborted if the transport supports it, and
+// left to complete in the background otherwise.
+func (self *Client) do(ctx Context, req *http.Request) (*http.Response, []byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, &CanceledError{req.URL.String(), err}
+	}
+	result := make(chan response, 1)
+	go func() {
+		resp, err := self.httpClient.Do(req)
+		if err != nil {
+			result <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		result <- response{resp, body, err}
+	}()
+	select {
+	case r := <-result:
+		return r.resp, r.body, r.err
+	case <-ctx.Done():
+		if canceler, ok := self.httpClient.Transport.(requestCanceler); ok {
+			canceler.CancelRequest(req)
+		}
+		return nil, nil, &CanceledError{req.URL.String(), ctx.Err()}
+	}
+}
+
+// Sends the request and returns once the response headers are received,
+// unless the context is done first. The response body is closed when the
+// context is done.
+func (self *Client) start(ctx Context, req *http.Request) (*http.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &CanceledError{req.URL.String(), err}
+	}
+	result := make(chan response, 1)
+	go func() {
+		resp, err := self.httpClient.Do(req)
+		result <- response{resp: resp, err: err}
+	}()
+	select {
+	case r := <-result:
+		return r.resp, r.err
+	case <-ctx.Done():
+		if canceler, ok := self.httpClient.Transport.(requestCanceler); ok {
+			canceler.CancelRequest(req)
+		}
+		go func() {
+			// Release the response if it arrives anyway.
+			if r := <-result; r.err == nil {
+				r.resp.Body.Close()
+			}
+		}()
+		return nil, &CanceledError{req.URL.String(), ctx.Err()}
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// A cAdvisor which lists its API versions but never answers the other
+// requests, or stops sending their body after the headers if stallBody is
+// set. Requests end when their connection is closed or the server is.
+type slowCadvisor struct {
+	*httptest.Server
+	stallBody bool
+
+	// Receives the path of each request which was aborted by the client.
+	aborted chan string
+	// Closed to end the pending requests.
+	release chan struct{}
+}
+
+func newSlowCadvisor(stallBody bool) *slowCadvisor {
+	self := &slowCadvisor{
+		stallBody: stallBody,
+		aborted:   make(chan string, 10),
+		release:   make(chan struct{}),
+	}
+	self.Server = httptest.NewServer(http.HandlerFunc(self.serve))
+	return self
+}
+
+func (self *slowCadvisor) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/" {
+		fmt.Fprint(w, "Supported API versions: v1.0,v1.1,v1.2,v1.3,v2.0")
+		return
+	}
+	// The closing of the connection is only noticed once the body was read.
+	ioutil.ReadAll(r.Body)
+	if self.stallBody {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "[")
+		w.(http.Flusher).Flush()
+	}
+	select {
+	case <-w.(http.CloseNotifier).CloseNotify():
+		self.aborted <- r.URL.Path
+	case <-self.release:
+	}
+}
+
+func (self *slowCadvisor) Close() {
+	close(self.release)
+	self.Server.Close()
+}
+
+// Checks that the error is that of a request aborted by a context done for the specified reason.
+func checkCanceled(t *testing.T, err error, reason error) {
+	if !IsCanceled(err) {
+		t.Fatalf("Expected the request to be canceled, got %v", err)
+	}
+	if err.(*CanceledError).Err != reason {
+		t.Errorf("Request was canceled because of %v, expected %v", err.(*CanceledError).Err, reason)
+	}
+}
+
+func checkAborted(t *testing.T, cadvisor *slowCadvisor, path string) {
+	select {
+	case aborted := <-cadvisor.aborted:
+		if aborted != path {
+			t.Errorf("Request for %q was aborted, expected %q", aborted, path)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("The connection of the request for %q was not closed", path)
+	}
+}
+
+func testCancelMidRequest(t *testing.T, stallBody bool, transport http.RoundTripper) {
+	cadvisor := newSlowCadvisor(stallBody)
+	defer cadvisor.Close()
+	client, err := NewClientWithTransport(cadvisor.URL, transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := NewContext(time.Time{})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	_, err = client.ContainerInfoCtx(ctx, "/", &info.ContainerInfoRequest{NumStats: 1})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Canceled request returned after %v", elapsed)
+	}
+	checkCanceled(t, err, Canceled)
+	checkAborted(t, cadvisor, "/api/v1.3/containers")
+}
+
+func TestCancelWaitingForHeaders(t *testing.T) {
+	testCancelMidRequest(t, false, &http.Transport{})
+}
+
+func TestCancelWaitingForBody(t *testing.T) {
+	testCancelMidRequest(t, true, &http.Transport{})
+}
+
+func TestCancelThroughTracingTransport(t *testing.T) {
+	testCancelMidRequest(t, false, NewTracingTransport(&http.Transport{}, t.Logf))
+}
+
+func TestContextDeadline(t *testing.T) {
+	cadvisor := newSlowCadvisor(false)
+	defer cadvisor.Close()
+	client, err := NewClientWithTransport(cadvisor.URL, &http.Transport{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := NewContext(time.Now().Add(100 * time.Millisecond))
+	defer cancel()
+	start := time.Now()
+	_, err = client.EventsCtx(ctx, &events.Request{ContainerName: "/"})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Request past its deadline returned after %v", elapsed)
+	}
+	checkCanceled(t, err, DeadlineExceeded)
+	checkAborted(t, cadvisor, "/api/v2.0/events")
+
+	// Canceling once the deadline passed does not change why the context is done.
+	cancel()
+	if ctx.Err() != DeadlineExceeded {
+		t.Errorf("Context is done because of %v, expected %v", ctx.Err(), DeadlineExceeded)
+	}
+}
+
+func TestCanceledContextSendsNoRequest(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := NewContext(time.Time{})
+	cancel()
+	_, err = client.MachineInfoCtx(ctx)
+	checkCanceled(t, err, Canceled)
+	if requests != 0 {
+		t.Errorf("Received %d requests, expected none", requests)
+	}
+}
+
+func TestCancelWatchEvents(t *testing.T) {
+	cadvisor := newSlowCadvisor(true)
+	defer cadvisor.Close()
+	client, err := NewClientWithTransport(cadvisor.URL, &http.Transport{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := NewContext(time.Time{})
+	stream, err := client.WatchEventsCtx(ctx, &events.Request{ContainerName: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	_, err = stream.Next()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Canceled stream returned after %v", elapsed)
+	}
+	checkCanceled(t, err, Canceled)
+}
@@ -0,0 +1,586 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build linux
+
+// Package fake provides a fake cAdvisor server for testing code that talks
+// to cAdvisor's API. Requests are served by cAdvisor's own API handlers from
+// programmable in-memory data.
+//
+// Typical use:
+//
+//	fakeCadvisor := fake.NewFakeCadvisor()
+//	defer fakeCadvisor.Close()
+//	fakeCadvisor.SetMachineInfo(&info.MachineInfo{NumCores: 4})
+//	cadvisorClient, err := client.NewClient(fakeCadvisor.URL)
+package fake
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/cadvisor/api"
+	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/google/cadvisor/manager"
+	"github.com/google/cadvisor/storage/memory"
+)
+
+// Maximum number of stats kept for each container.
+const maxStatsPerContainer = 1024
+
+// A request received by the fake.
+type Request struct {
+	Method string
+	// Path of the request, e.g.: /api/v1.3/machine
+	Path     string
+	RawQuery string
+	Body     []byte
+}
+
+// A fake cAdvisor serving the API on an httptest.Server. The server's URL can be
+// given to a cAdvisor client. Close() *must* be called. Class is thread-safe.
+type FakeCadvisor struct {
+	*httptest.Server
+
+	lock sync.Mutex
+	// Status codes returned for requests to a path instead of serving them.
+	errors   map[string]int
+	requests []Request
+
+	manager *fakeManager
+}
+
+// Starts a fake cAdvisor with no containers and an empty machine.
+func NewFakeCadvisor() *FakeCadvisor {
+	return NewFakeCadvisorWithListener(nil)
+}
+
+// Starts a fake cAdvisor serving on the specified listener, e.g.: one on the
+// IPv6 loopback address, or on a local address if nil.
+func NewFakeCadvisorWithListener(l net.Listener) *FakeCadvisor {
+	self := &FakeCadvisor{
+		errors: make(map[string]int),
+		manager: &fakeManager{
+			containers:  make(map[string]*info.ContainerInfo),
+			stats:       make(map[string]*memory.InMemoryStorage),
+			machineInfo: &info.MachineInfo{},
+			versionInfo: &info.VersionInfo{},
+			events:      events.NewEventManager(),
+			diagnostics: make(map[string]v2.ContainerDiagnostics),
+			deleted:     make(map[string]v2.DeletedContainer),
+		},
+	}
+	mux := http.NewServeMux()
+	// Serve with the same handlers as the real cAdvisor so the wire format is the same.
+	err := api.RegisterHandlers(mux, self.manager)
+	if err != nil {
+		panic(fmt.Sprintf("failed to register API handlers: %v", err))
+	}
+	self.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if code, ok := self.record(r); ok {
+			http.Error(w, http.StatusText(code), code)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	if l != nil {
+		self.Server.Listener.Close()
+		self.Server.Listener = l
+	}
+	self.Server.Start()
+	return self
+}
+
+// Records the request and returns the status code to fail it with, if any.
+func (self *FakeCadvisor) record(r *http.Request) (int, bool) {
+	req := Request{
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		RawQuery: r.URL.RawQuery,
+	}
+	if r.Body != nil {
+		body, err := ioutil.ReadAll(r.Body)
+		if err == nil {
+			req.Body = body
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.requests = append(self.requests, req)
+	code, ok := self.errors[r.URL.Path]
+	return code, ok
+}
+
+// Sets the information of a container, replacing any previous information and stats for it.
+// Its stats are served according to the query of each request.
+func (self *FakeCadvisor) SetContainerInfo(cinfo *info.ContainerInfo) error {
+	return self.manager.setContainerInfo(cinfo)
+}
+
+// Adds an event, which is sent to the watchers whose request it satisfies.
+func (self *FakeCadvisor) AddEvent(e *events.Event) error {
+	return self.manager.events.AddEvent(e)
+}
+
+// Sets the information of the machine.
+func (self *FakeCadvisor) SetMachineInfo(minfo *info.MachineInfo) {
+	self.manager.lock.Lock()
+	defer self.manager.lock.Unlock()
+	self.manager.machineInfo = minfo
+}
+
+// Sets the processes and cgroup files reported in the diagnostics of the
+// container. Containers without any report none.
+func (self *FakeCadvisor) SetContainerDiagnostics(containerName string, diagnostics v2.ContainerDiagnostics) {
+	self.manager.lock.Lock()
+	defer self.manager.lock.Unlock()
+	self.manager.diagnostics[containerName] = diagnostics
+}
+
+// Adds a deleted container, replacing any previous one with the same name. It
+// is only served by the deleted containers API, not as a container.
+func (self *FakeCadvisor) AddDeletedContainer(deleted v2.DeletedContainer) {
+	self.manager.lock.Lock()
+	defer self.manager.lock.Unlock()
+	self.manager.deleted[deleted.Name] = deleted
+}
+
+// Sets the version information of the machine.
+func (self *FakeCadvisor) SetVersionInfo(vinfo *info.VersionInfo) {
+	self.manager.lock.Lock()
+	defer self.manager.lock.Unlock()
+	self.manager.versionInfo = vinfo
+}
+
+// Makes requests to the specified path (e.g.: /api/v1.3/machine) fail with the
+// specified HTTP status code. A code of 0 serves the path normally again.
+func (self *FakeCadvisor) InjectError(path string, code int) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if code == 0 {
+		delete(self.errors, path)
+	} else {
+		self.errors[path] = code
+	}
+}
+
+// Returns the requests received so far, in the order they were received.
+func (self *FakeCadvisor) Requests() []Request {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	requests := make([]Request, len(self.requests))
+	copy(requests, self.requests)
+	return requests
+}
+
+// Forgets the requests received so far.
+func (self *FakeCadvisor) ClearRequests() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.requests = nil
+}
+
+// Implementation of manager.Manager backed by in-memory data.
+type fakeManager struct {
+	lock sync.RWMutex
+	// Containers by name, without their stats.
+	containers map[string]*info.ContainerInfo
+	// Stats of each container by name. Kept in the same storage as the real
+	// cAdvisor so that queries select the same stats.
+	stats       map[string]*memory.InMemoryStorage
+	machineInfo *info.MachineInfo
+	versionInfo *info.VersionInfo
+	// Revision of the container listing, bumped by each change to the containers.
+	revision uint64
+	// Events added to the fake, served as past events and to watchers.
+	events events.EventManager
+	// Processes and cgroup files of each container by name.
+	diagnostics map[string]v2.ContainerDiagnostics
+	// Deleted containers by name.
+	deleted map[string]v2.DeletedContainer
+}
+
+func (self *fakeManager) setContainerInfo(cinfo *info.ContainerInfo) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	delete(self.stats, cinfo.Name)
+	if len(cinfo.Stats) > 0 {
+		memoryStorage := memory.New(maxStatsPerContainer, nil)
+		for _, stats := range cinfo.Stats {
+			err := memoryStorage.AddStats(cinfo.ContainerReference, stats)
+			if err != nil {
+				return err
+			}
+		}
+		self.stats[cinfo.Name] = memoryStorage
+	}
+	stored := *cinfo
+	stored.Stats = nil
+	self.containers[cinfo.Name] = &stored
+	self.revision++
+	return nil
+}
+
+func (self *fakeManager) Start() error {
+	return nil
+}
+
+func (self *fakeManager) Stop() error {
+	return nil
+}
+
+func (self *fakeManager) GetContainerInfo(containerName string, query *info.ContainerInfoRequest) (*info.ContainerInfo, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	cinfo, ok := self.containers[containerName]
+	if !ok {
+		return nil, fmt.Errorf("unknown container %q", containerName)
+	}
+	return self.withStats(cinfo, query)
+}
+
+func (self *fakeManager) GetContainerAncestry(containerName string) ([]info.ContainerAncestor, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return manager.GetAncestry(containerName, func(name string) (*info.ContainerInfo, error) {
+		cinfo, ok := self.containers[name]
+		if !ok {
+			return nil, nil
+		}
+		return self.withStats(cinfo, &info.ContainerInfoRequest{NumStats: 1})
+	})
+}
+
+// The fake has nothing to collect: returns the container information with its
+// newest stats.
+func (self *fakeManager) CollectContainerInfo(containerName string, query *info.ContainerInfoRequest) (*info.ContainerInfo, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	cinfo, ok := self.containers[containerName]
+	if !ok {
+		return nil, fmt.Errorf("unknown container %q", containerName)
+	}
+	return self.withStats(cinfo, &info.ContainerInfoRequest{NumStats: 1})
+}
+
+// Returns a copy of the container information with the stats that match the query.
+func (self *fakeManager) withStats(cinfo *info.ContainerInfo, query *info.ContainerInfoRequest) (*info.ContainerInfo, error) {
+	var stats []*info.ContainerStats
+	if memoryStorage, ok := self.stats[cinfo.Name]; ok {
+		var err error
+		stats, err = memoryStorage.RecentStats(cinfo.Name, query.Start, query.End, query.NumStats)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ret := *cinfo
+	ret.Stats = stats
+	return &ret, nil
+}
+
+func (self *fakeManager) SubcontainersInfo(containerName string, query *info.ContainerInfoRequest) ([]*info.ContainerInfo, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	names, err := self.subcontainerNames(containerName, nil)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*info.ContainerInfo, 0, len(names))
+	for _, name := range names {
+		cinfo, err := self.withStats(self.containers[name], query)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, cinfo)
+	}
+	return ret, nil
+}
+
+func (self *fakeManager) GetSnapshot(containerName string) ([]*info.ContainerInfo, time.Time, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	names, err := self.subcontainerNames(containerName, nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var cut time.Time
+	for _, name := range names {
+		latest := self.latestStats(name, time.Time{})
+		if latest != nil && (cut.IsZero() || latest.Timestamp.Before(cut)) {
+			cut = latest.Timestamp
+		}
+	}
+	ret := make([]*info.ContainerInfo, 0, len(names))
+	for _, name := range names {
+		cinfo := *self.containers[name]
+		cinfo.Stats = nil
+		if stats := self.latestStats(name, cut); stats != nil {
+			cinfo.Stats = []*info.ContainerStats{stats}
+		}
+		ret = append(ret, &cinfo)
+	}
+	return ret, cut, nil
+}
+
+// Returns the most recent stats of the container no newer than the end, if
+// any. The lock must be held.
+func (self *fakeManager) latestStats(name string, end time.Time) *info.ContainerStats {
+	memoryStorage, ok := self.stats[name]
+	if !ok {
+		return nil
+	}
+	stats, err := memoryStorage.RecentStats(name, time.Time{}, end, 1)
+	if err != nil || len(stats) == 0 {
+		return nil
+	}
+	return stats[0]
+}
+
+func (self *fakeManager) SubcontainerNames(containerName string, filter *manager.ContainerFilter) ([]string, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.subcontainerNames(containerName, filter)
+}
+
+// Returns the sorted names of the container and its subcontainers selected by
+// the filter. The lock must be held.
+func (self *fakeManager) subcontainerNames(containerName string, filter *manager.ContainerFilter) ([]string, error) {
+	if _, ok := self.containers[containerName]; !ok {
+		return nil, fmt.Errorf("unknown container %q", containerName)
+	}
+
+	names := make([]string, 0, len(self.containers))
+	for name, cinfo := range self.containers {
+		if containerName != "/" && name != containerName && !strings.HasPrefix(name, containerName+"/") {
+			continue
+		}
+		if filter.Matches(cinfo.ContainerReference, cinfo.Spec) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (self *fakeManager) AllDockerContainers(query *info.ContainerInfoRequest) (map[string]info.ContainerInfo, error) {
+	return self.AllNamespacedContainers("docker", query)
+}
+
+func (self *fakeManager) DockerContainer(dockerName string, query *info.ContainerInfoRequest) (info.ContainerInfo, error) {
+	return self.NamespacedContainer("docker", dockerName, query)
+}
+
+func (self *fakeManager) AllNamespacedContainers(namespace string, query *info.ContainerInfoRequest) (map[string]info.ContainerInfo, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	ret := make(map[string]info.ContainerInfo)
+	for name, cinfo := range self.containers {
+		if cinfo.Namespace != namespace {
+			continue
+		}
+		withStats, err := self.withStats(cinfo, query)
+		if err != nil {
+			return nil, err
+		}
+		ret[name] = *withStats
+	}
+	return ret, nil
+}
+
+func (self *fakeManager) NamespacedContainer(namespace string, name string, query *info.ContainerInfoRequest) (info.ContainerInfo, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	for _, cinfo := range self.containers {
+		if cinfo.Namespace != namespace {
+			continue
+		}
+		for _, alias := range cinfo.Aliases {
+			if alias == name {
+				withStats, err := self.withStats(cinfo, query)
+				if err != nil {
+					return info.ContainerInfo{}, err
+				}
+				return *withStats, nil
+			}
+		}
+	}
+	if namespace == "docker" {
+		return info.ContainerInfo{}, fmt.Errorf("unable to find Docker container %q", name)
+	}
+	return info.ContainerInfo{}, fmt.Errorf("unable to find container %q in namespace %q", name, namespace)
+}
+
+// There are no factories behind the fake manager: the namespaces are those
+// of its containers, mounted where the first of them (by name) lives.
+func (self *fakeManager) GetNamespaces() ([]v2.Namespace, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	names := make([]string, 0, len(self.containers))
+	for name := range self.containers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ret := []v2.Namespace{}
+	index := make(map[string]int)
+	for _, name := range names {
+		ns := self.containers[name].Namespace
+		if ns == "" {
+			continue
+		}
+		i, ok := index[ns]
+		if !ok {
+			i = len(ret)
+			index[ns] = i
+			ret = append(ret, v2.Namespace{
+				Name:       ns,
+				MountPoint: path.Dir(name),
+				Factory:    "fake",
+			})
+		}
+		ret[i].NumContainers++
+	}
+	return ret, nil
+}
+
+func (self *fakeManager) GetContainerSpec(containerName string) (v2.ContainerSpec, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	cinfo, ok := self.containers[containerName]
+	if !ok {
+		return v2.ContainerSpec{}, fmt.Errorf("unknown container %q", containerName)
+	}
+	return v2.ContainerSpecFromV1(&cinfo.Spec, cinfo.ContainerReference), nil
+}
+
+func (self *fakeManager) GetContainerDerivedStats(containerName string) (v2.DerivedStats, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	if _, ok := self.containers[containerName]; !ok {
+		return v2.DerivedStats{}, fmt.Errorf("unknown container %q", containerName)
+	}
+	return v2.DerivedStats{}, nil
+}
+
+func (self *fakeManager) GetContainerCollectionStatus(containerName string) (v2.CollectionStatus, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	if _, ok := self.containers[containerName]; !ok {
+		return v2.CollectionStatus{}, fmt.Errorf("unknown container %q", containerName)
+	}
+	return v2.CollectionStatus{}, nil
+}
+
+func (self *fakeManager) GetContainerDiagnostics(containerName string) (v2.ContainerDiagnostics, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	if _, ok := self.containers[containerName]; !ok {
+		return v2.ContainerDiagnostics{}, fmt.Errorf("unknown container %q", containerName)
+	}
+	if diagnostics, ok := self.diagnostics[containerName]; ok {
+		return diagnostics, nil
+	}
+	return v2.ContainerDiagnostics{
+		Processes:   []v2.ProcessInfo{},
+		CgroupFiles: map[string]string{},
+	}, nil
+}
+
+func (self *fakeManager) GetDeletedContainers() ([]v2.DeletedContainer, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	ret := make([]v2.DeletedContainer, 0, len(self.deleted))
+	for _, deleted := range self.deleted {
+		deleted.Stats = nil
+		ret = append(ret, deleted)
+	}
+	sort.Sort(deletedContainersByDeletion(ret))
+	return ret, nil
+}
+
+func (self *fakeManager) GetDeletedContainer(containerName string) (v2.DeletedContainer, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	deleted, ok := self.deleted[containerName]
+	if !ok {
+		return v2.DeletedContainer{}, fmt.Errorf("unknown deleted container %q", containerName)
+	}
+	return deleted, nil
+}
+
+// Sorts by deletion time, the most recent first.
+type deletedContainersByDeletion []v2.DeletedContainer
+
+func (self deletedContainersByDeletion) Len() int      { return len(self) }
+func (self deletedContainersByDeletion) Swap(i, j int) { self[i], self[j] = self[j], self[i] }
+func (self deletedContainersByDeletion) Less(i, j int) bool {
+	return self[i].DeletionTime.After(self[j].DeletionTime)
+}
+
+func (self *fakeManager) GetMachineInfo() (*info.MachineInfo, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.machineInfo, nil
+}
+
+func (self *fakeManager) GetVersionInfo() (*info.VersionInfo, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.versionInfo, nil
+}
+
+func (self *fakeManager) GetFsInfo(label string) ([]v2.FsInfo, error) {
+	return []v2.FsInfo{}, nil
+}
+
+func (self *fakeManager) WatchForEvents(request *events.Request) (*events.EventChannel, error) {
+	return self.events.WatchEvents(request)
+}
+
+func (self *fakeManager) CloseEventChannel(watchId int) {
+	self.events.StopWatch(watchId)
+}
+
+func (self *fakeManager) GetFailedContainers() ([]v2.FailedContainer, error) {
+	return []v2.FailedContainer{}, nil
+}
+
+// The fake does not keep the changes to the containers: there are none since
+// the current revision and all others require a resync.
+func (self *fakeManager) GetContainerDiff(since uint64) (v2.ContainerDiff, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return v2.ContainerDiff{
+		Revision:       self.revision,
+		ResyncRequired: since != self.revision,
+	}, nil
+}
+
+func (self *fakeManager) GetPastEvents(request *events.Request) (events.EventSlice, error) {
+	return self.events.GetEvents(request)
+}
@@ -0,0 +1,127 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"go/build"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Packages vendored by projects which build on any platform, and the main
+// package, which builds everywhere but only collects stats on Linux. Their
+// tests must build on any platform too, those needing Linux (e.g.: to run the
+// fake cAdvisor of client/fake) have a build constraint.
+var portablePackages = []string{
+	"github.com/google/cadvisor",
+	"github.com/google/cadvisor/client",
+	"github.com/google/cadvisor/client/cadvisorctl",
+	"github.com/google/cadvisor/events",
+	"github.com/google/cadvisor/info/v1",
+	"github.com/google/cadvisor/info/v2",
+}
+
+// Packages that only build on Linux, by prefix.
+var linuxOnlyPackages = []string{
+	"code.google.com/p/go.exp/inotify",
+	"github.com/docker/libcontainer",
+	"github.com/google/cadvisor/container",
+	"github.com/google/cadvisor/manager",
+	"github.com/google/cadvisor/utils/cpuload",
+	"github.com/google/cadvisor/utils/procfs",
+	"github.com/google/cadvisor/utils/sysfs",
+}
+
+var otherPlatforms = []string{"darwin", "windows"}
+
+func isLinuxOnly(importPath string) bool {
+	for _, prefix := range linuxOnlyPackages {
+		if importPath == prefix || strings.HasPrefix(importPath, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Walks the non-test imports of the package on the platform, calling visit
+// with each package not in the standard library and the package importing it.
+func walkImports(t *testing.T, ctxt *build.Context, importPath, importer string, seen map[string]bool, visit func(importPath, importer string)) {
+	if seen[importPath] {
+		return
+	}
+	seen[importPath] = true
+	pkg, err := ctxt.Import(importPath, "", 0)
+	require.Nil(t, err, "%s (imported by %q) does not build on %s", importPath, importer, ctxt.GOOS)
+	if pkg.Goroot {
+		return
+	}
+	visit(importPath, importer)
+	for _, imported := range pkg.Imports {
+		if imported == "C" {
+			t.Errorf("%s uses cgo, which is not available when cross-compiling to %s", importPath, ctxt.GOOS)
+			continue
+		}
+		walkImports(t, ctxt, imported, importPath, seen, visit)
+	}
+}
+
+// Returns the imports of the tests of the package on the platform.
+func testImports(t *testing.T, ctxt *build.Context, importPath string) []string {
+	pkg, err := ctxt.Import(importPath, "", 0)
+	require.Nil(t, err, "%s does not build on %s", importPath, ctxt.GOOS)
+	return append(append([]string{}, pkg.TestImports...), pkg.XTestImports...)
+}
+
+func TestPortablePackagesHaveNoLinuxOnlyImports(t *testing.T) {
+	for _, goos := range otherPlatforms {
+		ctxt := build.Default
+		ctxt.GOOS = goos
+		ctxt.CgoEnabled = false
+		seen := make(map[string]bool)
+		for _, importPath := range portablePackages {
+			visit := func(imported, importer string) {
+				assert.False(t, isLinuxOnly(imported), "%s imports the Linux-only package %s on %s", importer, imported, goos)
+			}
+			walkImports(t, &ctxt, importPath, "", seen, visit)
+			// Tests are built when vetting or testing the package.
+			for _, imported := range testImports(t, &ctxt, importPath) {
+				walkImports(t, &ctxt, imported, importPath+" (tests)", seen, visit)
+			}
+		}
+	}
+}
+
+func TestPortablePackagesBuildOnAllPlatforms(t *testing.T) {
+	if testing.Short() {
+		t.Skip("cross-compiling is slow")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("the go tool is not available")
+	}
+	for _, goos := range otherPlatforms {
+		// Building several packages discards the results.
+		args := append([]string{"build"}, portablePackages...)
+		cmd := exec.Command(goTool, args...)
+		cmd.Env = append(os.Environ(), "GOOS="+goos, "CGO_ENABLED=0")
+		out, err := cmd.CombinedOutput()
+		assert.Nil(t, err, "failed to build the portable packages on %s: %s", goos, out)
+	}
+}
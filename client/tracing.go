@@ -0,0 +1,229 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Number of bytes of each request and response body that are logged by default.
+const defaultMaxTracedBodyBytes = 1024
+
+// Headers whose values are never logged.
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// TracingTransport is an http.RoundTripper which logs the method, URL,
+// headers, status, latency, and (truncated) bodies of every exchange.
+// Credentials in the headers are redacted.
+type TracingTransport struct {
+	// Transport that makes the requests.
+	Transport http.RoundTripper
+
+	// Receives the trace of each exchange, e.g.: testing.T's Logf.
+	Logf func(format string, args ...interface{})
+
+	// Maximum number of bytes logged from each body.
+	MaxBodyBytes int
+
+	// Copies of the requests in progress which were sent in their place, by
+	// request. Protected by lock.
+	lock   sync.Mutex
+	copies map[*http.Request]*http.Request
+}
+
+// NewTracingTransport returns a TracingTransport which makes its requests
+// through transport (http.DefaultTransport if nil) and logs them to logf.
+func NewTracingTransport(transport http.RoundTripper, logf func(format string, args ...interface{})) *TracingTransport {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &TracingTransport{
+		Transport:    transport,
+		Logf:         logf,
+		MaxBodyBytes: defaultMaxTracedBodyBytes,
+	}
+}
+
+func (self *TracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		// Don't modify the caller's request.
+		tracedReq := *req
+		tracedReq.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+		self.setCopy(req, &tracedReq)
+		defer self.setCopy(req, nil)
+		req = &tracedReq
+	}
+
+	start := time.Now()
+	resp, err := self.Transport.RoundTrip(req)
+	latency := time.Since(start)
+	if err != nil {
+		self.Logf("%s %s failed after %v: %v\nRequest headers: %s\nRequest body: %s", req.Method, req.URL, latency, err, formatHeaders(req.Header), self.truncate(reqBody))
+		return nil, err
+	}
+
+	// The response is logged once its body is read to the end or closed, it
+	// is not read up front so that streamed responses (e.g.: event watches)
+	// reach the caller as they arrive.
+	resp.Body = &tracedBody{
+		body:      resp.Body,
+		transport: self,
+		req:       req,
+		reqBody:   reqBody,
+		resp:      resp,
+		start:     start,
+		latency:   latency,
+	}
+	return resp, nil
+}
+
+// Body of a traced response. Keeps the first MaxBodyBytes read and logs the
+// exchange when the body reaches its end or is closed, whichever is first.
+type tracedBody struct {
+	body      io.ReadCloser
+	transport *TracingTransport
+	req       *http.Request
+	reqBody   []byte
+	resp      *http.Response
+
+	// Start of the request and time until the response headers were received.
+	start   time.Time
+	latency time.Duration
+
+	// Beginning of the body and number of bytes read, protected by lock.
+	lock   sync.Mutex
+	head   []byte
+	size   int
+	logged bool
+}
+
+func (self *tracedBody) Read(p []byte) (int, error) {
+	n, err := self.body.Read(p)
+	self.lock.Lock()
+	if room := self.transport.MaxBodyBytes - len(self.head); room > 0 {
+		if room > n {
+			room = n
+		}
+		self.head = append(self.head, p[:room]...)
+	}
+	self.size += n
+	self.lock.Unlock()
+	if err == io.EOF {
+		self.log()
+	}
+	return n, err
+}
+
+func (self *tracedBody) Close() error {
+	err := self.body.Close()
+	self.log()
+	return err
+}
+
+// Logs the exchange unless it was already.
+func (self *tracedBody) log() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.logged {
+		return
+	}
+	self.logged = true
+	t := self.transport
+	t.Logf("%s %s -> %s in %v, %d bytes of body read in %v\nRequest headers: %s\nRequest body: %s\nResponse headers: %s\nResponse body: %s", self.req.Method, self.req.URL, self.resp.Status, self.latency, self.size, time.Since(self.start), formatHeaders(self.req.Header), t.truncate(self.reqBody), formatHeaders(self.resp.Header), t.formatBody(self.head, self.size))
+}
+
+// Records the copy of the request sent in its place, nil once it completed.
+func (self *TracingTransport) setCopy(req, sent *http.Request) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if sent == nil {
+		delete(self.copies, req)
+		return
+	}
+	if self.copies == nil {
+		self.copies = make(map[*http.Request]*http.Request)
+	}
+	self.copies[req] = sent
+}
+
+// Aborts a request in progress if the underlying transport can, so that the
+// contexts of the client abort the requests it traces.
+func (self *TracingTransport) CancelRequest(req *http.Request) {
+	canceler, ok := self.Transport.(requestCanceler)
+	if !ok {
+		return
+	}
+	self.lock.Lock()
+	if sent, ok := self.copies[req]; ok {
+		req = sent
+	}
+	self.lock.Unlock()
+	canceler.CancelRequest(req)
+}
+
+// Returns the body as a string of at most MaxBodyBytes.
+func (self *TracingTransport) truncate(body []byte) string {
+	if len(body) <= self.MaxBodyBytes {
+		return string(body)
+	}
+	return self.formatBody(body[:self.MaxBodyBytes], len(body))
+}
+
+// Returns the beginning of a body of the specified size as a string.
+func (self *TracingTransport) formatBody(head []byte, size int) string {
+	if size <= len(head) {
+		return string(head)
+	}
+	return fmt.Sprintf("%s... (%d more bytes)", head, size-len(head))
+}
+
+// Returns the headers sorted by name with credentials redacted.
+func formatHeaders(headers http.Header) string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	formatted := make([]string, 0, len(names))
+	for _, name := range names {
+		value := strings.Join(headers[name], ", ")
+		if redactedHeaders[http.CanonicalHeaderKey(name)] {
+			value = "REDACTED"
+		}
+		formatted = append(formatted, fmt.Sprintf("%s: %s", name, value))
+	}
+	return "[" + strings.Join(formatted, "; ") + "]"
+}
@@ -0,0 +1,151 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Records everything logged by a TracingTransport.
+type traceLog struct {
+	lines []string
+}
+
+func (self *traceLog) Logf(format string, args ...interface{}) {
+	self.lines = append(self.lines, fmt.Sprintf(format, args...))
+}
+
+func (self *traceLog) String() string {
+	return strings.Join(self.lines, "\n")
+}
+
+func newTracingTestServer(responseBody string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=server-secret")
+		fmt.Fprint(w, responseBody)
+	}))
+}
+
+func TestTracingTransportRedactsCredentials(t *testing.T) {
+	server := newTracingTestServer("ok")
+	defer server.Close()
+	log := new(traceLog)
+	httpClient := &http.Client{Transport: NewTracingTransport(nil, log.Logf)}
+
+	req, err := http.NewRequest("GET", server.URL+"/api", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer client-secret")
+	req.Header.Set("Proxy-Authorization", "Basic proxy-secret")
+	req.Header.Set("Cookie", "session=cookie-secret")
+	req.Header.Set("X-Request-Id", "1234")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	trace := log.String()
+	for _, secret := range []string{"client-secret", "proxy-secret", "cookie-secret", "server-secret"} {
+		if strings.Contains(trace, secret) {
+			t.Errorf("Trace contains credential %q: %s", secret, trace)
+		}
+	}
+	for _, expected := range []string{"GET " + server.URL + "/api", "200 OK", "Authorization: REDACTED", "Set-Cookie: REDACTED", "X-Request-Id: 1234"} {
+		if !strings.Contains(trace, expected) {
+			t.Errorf("Trace does not contain %q: %s", expected, trace)
+		}
+	}
+}
+
+func TestTracingTransportTruncatesBodies(t *testing.T) {
+	server := newTracingTestServer(strings.Repeat("a", 100))
+	defer server.Close()
+	log := new(traceLog)
+	transport := NewTracingTransport(nil, log.Logf)
+	transport.MaxBodyBytes = 10
+	httpClient := &http.Client{Transport: transport}
+
+	resp, err := httpClient.Post(server.URL, "text/plain", strings.NewReader(strings.Repeat("b", 20)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The caller still receives the full response.
+	if len(body) != 100 {
+		t.Errorf("Received a %d byte body, expected 100 bytes", len(body))
+	}
+	trace := log.String()
+	for _, expected := range []string{strings.Repeat("a", 10) + "... (90 more bytes)", strings.Repeat("b", 10) + "... (10 more bytes)"} {
+		if !strings.Contains(trace, expected) {
+			t.Errorf("Trace does not contain %q: %s", expected, trace)
+		}
+	}
+}
+
+func TestTracingTransportStreamsResponses(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "first event")
+		w.(http.Flusher).Flush()
+		<-release
+		fmt.Fprintln(w, "second event")
+	}))
+	defer server.Close()
+	defer close(release)
+	log := new(traceLog)
+	httpClient := &http.Client{Transport: NewTracingTransport(nil, log.Logf)}
+
+	resp, err := httpClient.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	lines := make(chan string)
+	go func() {
+		line, _ := bufio.NewReader(resp.Body).ReadString('\n')
+		lines <- line
+	}()
+	// The first event arrives while the response is still being written.
+	select {
+	case line := <-lines:
+		if line != "first event\n" {
+			t.Errorf("Expected the first event, got %q", line)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for the first event of the streamed response")
+	}
+	if len(log.lines) != 0 {
+		t.Errorf("Expected no trace before the response ends, found %q", log.lines)
+	}
+
+	resp.Body.Close()
+	if len(log.lines) != 1 || !strings.Contains(log.lines[0], "first event") {
+		t.Errorf("Expected the trace of the response once closed, found %q", log.lines)
+	}
+}
@@ -16,7 +16,14 @@
 // defines an interface for container operation handlers.
 package container
 
-import info "github.com/google/cadvisor/info/v1"
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/utils/timing"
+)
 
 // ListType describes whether listing should be just for a
 // specific container or performed recursively.
@@ -52,7 +59,9 @@ type ContainerHandler interface {
 	// Returns container's isolation spec.
 	GetSpec() (info.ContainerSpec, error)
 
-	// Returns the current stats values of the container.
+	// Returns the current stats values of the container. When only some
+	// sections of the stats could be collected, returns them along with a
+	// *PartialStatsError describing the sections that failed.
 	GetStats() (*info.ContainerStats, error)
 
 	// Returns the subcontainers of this container.
@@ -61,7 +70,8 @@ type ContainerHandler interface {
 	// Returns the threads inside this container.
 	ListThreads(listType ListType) ([]int, error)
 
-	// Returns the processes inside this container.
+	// Returns the processes inside this container. Empty (not nil) if it has
+	// none, which is not an error. Nil if its processes are not known.
 	ListProcesses(listType ListType) ([]int, error)
 
 	// Registers a channel to listen for events affecting subcontainers (recursively).
@@ -75,4 +85,73 @@ type ContainerHandler interface {
 
 	// Returns whether the container still exists.
 	Exists() bool
+
+	// Enables or disables the collectors that are expensive to run (e.g.: du
+	// for filesystem usage). Collectors are enabled by default.
+	SetExpensiveCollectorsEnabled(enabled bool)
+}
+
+// Implemented by handlers which time the sections of their stats collection.
+type TimedStatsHandler interface {
+	// Same as GetStats(), recording how long each section of the collection
+	// (e.g.: the cgroup read) takes in sections.
+	GetStatsTimed(sections *timing.Sections) (*info.ContainerStats, error)
+}
+
+// Implemented by handlers whose paths depend on where the cgroup hierarchies
+// are mounted.
+type CgroupPathsResolver interface {
+	// Re-resolves the cgroup paths of the container against the cgroup
+	// hierarchies as currently mounted, after they were remounted.
+	ResolveCgroupPaths()
+}
+
+// Implemented by handlers holding resources (e.g.: open namespaces) which must
+// be released once the container is no longer monitored.
+type Cleaner interface {
+	// Releases the resources of the handler, which is not used afterwards.
+	Cleanup()
+}
+
+// Error returned along with stats missing the sections that failed to be collected.
+type PartialStatsError struct {
+	// Errors by failed section (e.g.: info.StatsSectionMemory).
+	Failures map[string]error
+}
+
+func NewPartialStatsError() *PartialStatsError {
+	return &PartialStatsError{
+		Failures: make(map[string]error),
+	}
+}
+
+// Records that the specified section failed to be collected.
+func (self *PartialStatsError) Add(section string, err error) {
+	self.Failures[section] = err
+}
+
+// Returns the sorted failed sections.
+func (self *PartialStatsError) Sections() []string {
+	sections := make([]string, 0, len(self.Failures))
+	for section := range self.Failures {
+		sections = append(sections, section)
+	}
+	sort.Strings(sections)
+	return sections
+}
+
+// Returns the error if any section failed, nil otherwise.
+func (self *PartialStatsError) OrNil() error {
+	if len(self.Failures) == 0 {
+		return nil
+	}
+	return self
+}
+
+func (self *PartialStatsError) Error() string {
+	errs := make([]string, 0, len(self.Failures))
+	for _, section := range self.Sections() {
+		errs = append(errs, fmt.Sprintf("%s: %v", section, self.Failures[section]))
+	}
+	return fmt.Sprintf("failed to collect %d sections of the stats: %s", len(errs), strings.Join(errs, "; "))
 }
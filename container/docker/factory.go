@@ -15,14 +15,15 @@
 package docker
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"path"
 	"regexp"
 	"strconv"
 	"strings"
 
-	"github.com/docker/libcontainer/cgroups"
 	"github.com/docker/libcontainer/cgroups/systemd"
 	"github.com/fsouza/go-dockerclient"
 	"github.com/golang/glog"
@@ -31,6 +32,7 @@ import (
 	"github.com/google/cadvisor/fs"
 	info "github.com/google/cadvisor/info/v1"
 	"github.com/google/cadvisor/utils"
+	"github.com/google/cadvisor/utils/cgroups"
 )
 
 var ArgDockerEndpoint = flag.String("docker", "unix:///var/run/docker.sock", "docker endpoint")
@@ -48,7 +50,7 @@ func init() {
 	useSystemd = systemd.UseSystemd()
 	if !useSystemd {
 		// Second attempt at checking for systemd, check for a "name=systemd" cgroup.
-		mnt, err := cgroups.FindCgroupMountpoint("cpu")
+		mnt, err := cgroups.FindMountpoint("cpu")
 		if err == nil {
 			// systemd presence does not mean systemd controls cgroups.
 			// If system.slice cgroup exists, then systemd is taking control.
@@ -70,13 +72,13 @@ func RootDir() string {
 type dockerFactory struct {
 	machineInfoFactory info.MachineInfoFactory
 
-	// Whether docker is running with AUFS storage driver.
-	usesAufsDriver bool
+	// Storage driver used by Docker (e.g.: "aufs").
+	storageDriver string
 
 	client *docker.Client
 
 	// Information about the mounted cgroup subsystems.
-	cgroupSubsystems libcontainer.CgroupSubsystems
+	cgroupMounts *libcontainer.CgroupMounts
 
 	// Information about mounted filesystems.
 	fsInfo fs.FsInfo
@@ -97,8 +99,8 @@ func (self *dockerFactory) NewContainerHandler(name string) (handler container.C
 		self.machineInfoFactory,
 		self.fsInfo,
 		*dockerRootDir,
-		self.usesAufsDriver,
-		&self.cgroupSubsystems,
+		self.storageDriver,
+		self.cgroupMounts,
 	)
 	return
 }
@@ -116,26 +118,114 @@ func ContainerNameToDockerId(name string) string {
 	return id
 }
 
-// Returns a full container name for the specified Docker ID.
+// Cgroup under which the Docker daemon places the containers that do not set
+// their own with --cgroup-parent. Detected from the daemon on registration.
+var daemonCgroupParent string
+
+// Returns the cgroup under which the Docker daemon places the containers that
+// do not set their own with --cgroup-parent.
+func CgroupParent() string {
+	if daemonCgroupParent != "" {
+		return daemonCgroupParent
+	}
+	return defaultCgroupParent()
+}
+
+func defaultCgroupParent() string {
+	if useSystemd {
+		return "/system.slice"
+	}
+	return "/docker"
+}
+
+// Returns the daemon-level cgroup parent reported by "docker info", or the
+// default one if the daemon does not report it.
+func detectCgroupParent(information *docker.Env) string {
+	parent := information.Get("CgroupParent")
+	if parent == "" {
+		return defaultCgroupParent()
+	}
+	return path.Join("/", parent)
+}
+
+// Returns the cgroup parent the specified container was started with, read
+// from its libcontainer config. Empty if it can't be read.
+func containerCgroupParent(dockerId string) string {
+	out, err := ioutil.ReadFile(path.Join(DockerStateDir(), dockerId, "container.json"))
+	if err != nil {
+		return ""
+	}
+	var config struct {
+		Cgroups *struct {
+			Parent string `json:"parent"`
+		} `json:"cgroups"`
+	}
+	if err := json.Unmarshal(out, &config); err != nil || config.Cgroups == nil || config.Cgroups.Parent == "" {
+		return ""
+	}
+	return path.Join("/", config.Cgroups.Parent)
+}
+
+// Returns a full container name for the specified Docker ID. Containers
+// started with --cgroup-parent are under that cgroup.
 func FullContainerName(dockerId string) string {
+	parent := containerCgroupParent(dockerId)
+	if parent == "" {
+		parent = CgroupParent()
+	}
 	// Add the full container name.
 	if useSystemd {
-		return path.Join("/system.slice", fmt.Sprintf("docker-%s.scope", dockerId))
+		return path.Join(parent, fmt.Sprintf("docker-%s.scope", dockerId))
 	} else {
-		return path.Join("/docker", dockerId)
+		return path.Join(parent, dockerId)
+	}
+}
+
+// Returns whether the string is a full Docker ID: 64 lowercase hexadecimal digits.
+func isDockerId(id string) bool {
+	if len(id) != 64 {
+		return false
+	}
+	for _, c := range id {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns whether the cgroup is one of the absolute parents, which may
+// themselves be nested (e.g.: "/docker" matches "/machine/docker").
+func underCgroupParent(cgroup string, parents ...string) bool {
+	for _, parent := range parents {
+		if parent == "/" || parent != "" && strings.HasSuffix(cgroup, parent) {
+			return true
+		}
 	}
+	return false
 }
 
-// Docker handles all containers under /docker
+// Docker handles the containers named after a Docker ID (its cgroup) under the
+// daemon's cgroup parent or the one the container was started with.
 func (self *dockerFactory) CanHandle(name string) (bool, error) {
-	// Check if the container is known to docker and it is active.
 	id := ContainerNameToDockerId(name)
+	if !isDockerId(id) {
+		return false, nil
+	}
+	if !underCgroupParent(path.Dir(name), CgroupParent(), defaultCgroupParent(), containerCgroupParent(id)) {
+		return false, nil
+	}
 
+	// Check if the container is known to docker and it is active.
 	// We assume that if Inspect fails then the container is not known to docker.
-	ctnr, err := self.client.InspectContainer(id)
+	ctnr, err := inspectContainer(self.client, id)
 	if err != nil || !ctnr.State.Running {
 		return false, fmt.Errorf("error inspecting container: %v", err)
 	}
+	if ctnr.ID != id {
+		// Another container, whose name is the ID.
+		return false, nil
+	}
 
 	return true, nil
 }
@@ -160,7 +250,7 @@ func parseDockerVersion(full_version_string string) ([]int, error) {
 }
 
 // Register root container before running this function!
-func Register(factory info.MachineInfoFactory, fsInfo fs.FsInfo) error {
+func Register(factory info.MachineInfoFactory, fsInfo fs.FsInfo, cgroupMounts *libcontainer.CgroupMounts) error {
 	client, err := docker.NewClient(*ArgDockerEndpoint)
 	if err != nil {
 		return fmt.Errorf("unable to communicate with docker daemon: %v", err)
@@ -199,31 +289,27 @@ func Register(factory info.MachineInfoFactory, fsInfo fs.FsInfo) error {
 		return fmt.Errorf("docker found, but not using native exec driver")
 	}
 
-	usesAufsDriver := false
-	for _, val := range *information {
-		if strings.Contains(val, "Driver=") && strings.Contains(val, "aufs") {
-			usesAufsDriver = true
-			break
-		}
-	}
-
 	if useSystemd {
 		glog.Infof("System is using systemd")
 	}
+	daemonCgroupParent = detectCgroupParent(information)
+	glog.Infof("Docker containers are under cgroup %q unless started with --cgroup-parent", daemonCgroupParent)
 
-	cgroupSubsystems, err := libcontainer.GetCgroupSubsystems()
-	if err != nil {
-		return fmt.Errorf("failed to get cgroup subsystems: %v", err)
+	if cgroupMounts == nil {
+		return fmt.Errorf("failed to get cgroup subsystems")
 	}
 
 	glog.Infof("Registering Docker factory")
 	f := &dockerFactory{
 		machineInfoFactory: factory,
 		client:             client,
-		usesAufsDriver:     usesAufsDriver,
-		cgroupSubsystems:   cgroupSubsystems,
+		storageDriver:      information.Get("Driver"),
+		cgroupMounts:       cgroupMounts,
 		fsInfo:             fsInfo,
 	}
-	container.RegisterContainerHandlerFactory(f)
+	container.RegisterNamespacedContainerHandlerFactory(f, container.Namespace{
+		Name:       DockerNamespace,
+		MountPoint: daemonCgroupParent,
+	})
 	return nil
 }
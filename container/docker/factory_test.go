@@ -0,0 +1,76 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testDockerId = strings.Repeat("0123456789abcdef", 4)
+
+func TestIsDockerId(t *testing.T) {
+	assert.True(t, isDockerId(testDockerId))
+	assert.False(t, isDockerId(testDockerId[1:]))
+	assert.False(t, isDockerId(strings.ToUpper(testDockerId)))
+	assert.False(t, isDockerId("web"))
+	assert.False(t, isDockerId(""))
+}
+
+func TestUnderCgroupParent(t *testing.T) {
+	assert.True(t, underCgroupParent("/docker", "/docker"))
+	assert.True(t, underCgroupParent("/machine/docker", "/docker"))
+	assert.True(t, underCgroupParent("/custom-parent", "/docker", "", "/custom-parent"))
+	assert.True(t, underCgroupParent("/", "/"))
+	assert.False(t, underCgroupParent("/mydocker", "/docker"))
+	assert.False(t, underCgroupParent("/docker/nested", "/docker"))
+	assert.False(t, underCgroupParent("/docker", ""))
+}
+
+func TestDetectCgroupParent(t *testing.T) {
+	information := &docker.Env{"Driver=aufs", "CgroupParent=custom-parent"}
+	assert.Equal(t, "/custom-parent", detectCgroupParent(information))
+
+	information = &docker.Env{"Driver=aufs"}
+	assert.Equal(t, defaultCgroupParent(), detectCgroupParent(information))
+}
+
+func TestContainerCgroupParent(t *testing.T) {
+	root, err := ioutil.TempDir("", "docker_root")
+	require.Nil(t, err)
+	defer os.RemoveAll(root)
+	oldRoot := *dockerRootDir
+	*dockerRootDir = root
+	defer func() {
+		*dockerRootDir = oldRoot
+	}()
+
+	// No config.
+	assert.Equal(t, "", containerCgroupParent(testDockerId))
+
+	stateDir := path.Join(DockerStateDir(), testDockerId)
+	require.Nil(t, os.MkdirAll(stateDir, 0755))
+	config := `{"hostname":"web","cgroups":{"name":"` + testDockerId + `","parent":"custom-parent"}}`
+	require.Nil(t, ioutil.WriteFile(path.Join(stateDir, "container.json"), []byte(config), 0644))
+	assert.Equal(t, "/custom-parent", containerCgroupParent(testDockerId))
+	assert.Equal(t, "/custom-parent", path.Dir(FullContainerName(testDockerId)))
+}
@@ -23,27 +23,44 @@ import (
 	"os"
 	"path"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/docker/libcontainer"
 	"github.com/docker/libcontainer/cgroups"
 	cgroup_fs "github.com/docker/libcontainer/cgroups/fs"
 	"github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
 	"github.com/google/cadvisor/container"
 	containerLibcontainer "github.com/google/cadvisor/container/libcontainer"
 	"github.com/google/cadvisor/fs"
 	info "github.com/google/cadvisor/info/v1"
 	"github.com/google/cadvisor/utils"
+	"github.com/google/cadvisor/utils/netns"
+	"github.com/google/cadvisor/utils/procfs"
+	"github.com/google/cadvisor/utils/quota"
+	"github.com/google/cadvisor/utils/sem"
+	"github.com/google/cadvisor/utils/timing"
 )
 
 // Relative path from Docker root to the libcontainer per-container state.
 const pathToLibcontainerState = "execdriver/native"
 
+// CFS period used by the kernel when none is set (in microseconds).
+const defaultCfsPeriod = 100000
+
 // Path to aufs dir where all the files exist.
 // aufs/layers is ignored here since it does not hold a lot of data.
 // aufs/mnt contains the mount points used to compose the rootfs. Hence it is also ignored.
 var pathToAufsDir = "aufs/diff"
 
+// Reads the usage of a project quota, replaced in tests.
+var getQuotaUsage = quota.GetUsage
+
+// Waits for the turn of an expensive collector, replaced in tests.
+var acquireExpensive = sem.AcquireExpensive
+
 type dockerContainerHandler struct {
 	client             *docker.Client
 	name               string
@@ -62,16 +79,53 @@ type dockerContainerHandler struct {
 	libcontainerPidPath string
 
 	// Absolute path to the cgroup hierarchies of this container.
-	// (e.g.: "cpu" -> "/sys/fs/cgroup/cpu/test")
+	// (e.g.: "cpu" -> "/sys/fs/cgroup/cpu/test"). Protected by pathsLock,
+	// replaced when the hierarchies are remounted.
 	cgroupPaths map[string]string
+	pathsLock   sync.RWMutex
+
+	// Sections of the stats which apply to the container, probed from its
+	// cgroup paths. See ProbeCapabilities. Protected by pathsLock.
+	capabilities map[string]error
+
+	// Where the cgroup hierarchies are mounted.
+	cgroupMounts *containerLibcontainer.CgroupMounts
+
+	cgroup        cgroups.Cgroup
+	storageDriver string
+	fsInfo        fs.FsInfo
 
-	cgroup         cgroups.Cgroup
-	usesAufsDriver bool
-	fsInfo         fs.FsInfo
-	storageDirs    []string
+	// Directories holding the files written by the container. Empty if not
+	// known for the storage driver.
+	storageDirs []string
+
+	// Size limit of the container's filesystem in bytes, 0 if unlimited.
+	fsLimit uint64
 
 	// Time at which this container was created.
 	creationTime time.Time
+
+	// Docker labels of the container.
+	labels map[string]string
+
+	// Full name of the container whose network namespace this container
+	// joined, empty if it has its own.
+	networkSharedWith string
+
+	// Reads the network stats from inside the network namespace of the
+	// container, nil if it joined that of another.
+	network *netns.Collector
+
+	// Whether to skip the collectors that are expensive to run, non-zero to
+	// skip them. Accessed atomically: it is set by housekeeping while stats
+	// may be collected by other goroutines (e.g.: forced collections).
+	skipExpensiveCollectors int32
+
+	// Usage of the storage dirs measured by the last run of du, reported
+	// when du is skipped. Protected by duLock.
+	lastDuUsage    uint64
+	hasLastDuUsage bool
+	duLock         sync.Mutex
 }
 
 func DockerStateDir() string {
@@ -84,14 +138,11 @@ func newDockerContainerHandler(
 	machineInfoFactory info.MachineInfoFactory,
 	fsInfo fs.FsInfo,
 	dockerRootDir string,
-	usesAufsDriver bool,
-	cgroupSubsystems *containerLibcontainer.CgroupSubsystems,
+	storageDriver string,
+	cgroupMounts *containerLibcontainer.CgroupMounts,
 ) (container.ContainerHandler, error) {
 	// Create the cgroup paths.
-	cgroupPaths := make(map[string]string, len(cgroupSubsystems.MountPoints))
-	for key, val := range cgroupSubsystems.MountPoints {
-		cgroupPaths[key] = path.Join(val, name)
-	}
+	cgroupPaths := cgroupMounts.CgroupPaths(name)
 
 	id := ContainerNameToDockerId(name)
 	stateDir := DockerStateDir()
@@ -104,21 +155,43 @@ func newDockerContainerHandler(
 		libcontainerStatePath:  path.Join(stateDir, id, "state.json"),
 		libcontainerPidPath:    path.Join(stateDir, id, "pid"),
 		cgroupPaths:            cgroupPaths,
+		capabilities:           containerLibcontainer.ProbeCapabilities(name, cgroupPaths),
+		cgroupMounts:           cgroupMounts,
 		cgroup: cgroups.Cgroup{
 			Parent: "/",
 			Name:   name,
 		},
-		usesAufsDriver: usesAufsDriver,
-		fsInfo:         fsInfo,
+		storageDriver: storageDriver,
+		fsInfo:        fsInfo,
+		storageDirs:   storageDirs(dockerRootDir, storageDriver, id),
+	}
+	fsLimit, err := storageSizeLimit(dockerRootDir, storageDriver, id)
+	if err != nil {
+		glog.Warningf("Failed to get the storage size limit of container %q: %v", name, err)
 	}
-	handler.storageDirs = append(handler.storageDirs, path.Join(dockerRootDir, pathToAufsDir, id))
+	handler.fsLimit = fsLimit
 
 	// We assume that if Inspect fails then the container is not known to docker.
-	ctnr, err := client.InspectContainer(id)
+	ctnr, err := inspectContainer(client, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to inspect container %q: %v", id, err)
 	}
 	handler.creationTime = ctnr.Created
+	if ctnr.Config != nil {
+		handler.labels = ctnr.Config.Labels
+	}
+
+	// Containers joining the network namespace of another (--net=container:<name>)
+	// report the network stats of that namespace, those are only attributed to
+	// its owner.
+	if ctnr.HostConfig != nil {
+		if ownerRef, ok := networkModeContainer(ctnr.HostConfig.NetworkMode); ok {
+			handler.networkSharedWith = networkOwner(client, ownerRef, id)
+		}
+	}
+	if handler.networkSharedWith == "" {
+		handler.network = netns.NewCollector()
+	}
 
 	// Add the name and bare ID as aliases of the container.
 	handler.aliases = append(handler.aliases, strings.TrimPrefix(ctnr.Name, "/"))
@@ -127,6 +200,39 @@ func newDockerContainerHandler(
 	return handler, nil
 }
 
+// Inspects the container, giving up if the daemon does not answer in time.
+func inspectContainer(client *docker.Client, id string) (*docker.Container, error) {
+	var ctnr *docker.Container
+	err := container.RunWithTimeout(fmt.Sprintf("inspect of Docker container %q", id), func() error {
+		var err error
+		ctnr, err = client.InspectContainer(id)
+		return err
+	})
+	return ctnr, err
+}
+
+// Returns the name of the container, referenced by name or ID, whose network
+// namespace the specified container joined. The owner may have exited or be
+// racing away, in which case the reference is returned as it is rather than
+// failing to monitor the container which joined it.
+func networkOwner(client *docker.Client, ownerRef string, id string) string {
+	owner, err := inspectContainer(client, ownerRef)
+	if err != nil {
+		glog.Warningf("Failed to inspect container %q whose network namespace container %q joined: %v", ownerRef, id, err)
+		return ownerRef
+	}
+	return FullContainerName(owner.ID)
+}
+
+// Returns the name or ID of the container in a "container:<name|id>" network mode.
+func networkModeContainer(networkMode string) (string, bool) {
+	const prefix = "container:"
+	if !strings.HasPrefix(networkMode, prefix) || len(networkMode) == len(prefix) {
+		return "", false
+	}
+	return networkMode[len(prefix):], true
+}
+
 func (self *dockerContainerHandler) ContainerReference() (info.ContainerReference, error) {
 	return info.ContainerReference{
 		Name:      self.name,
@@ -135,6 +241,32 @@ func (self *dockerContainerHandler) ContainerReference() (info.ContainerReferenc
 	}, nil
 }
 
+// Returns the paths of the cgroup hierarchies of this container. The returned
+// map must not be modified.
+func (self *dockerContainerHandler) getCgroupPaths() map[string]string {
+	self.pathsLock.RLock()
+	defer self.pathsLock.RUnlock()
+	return self.cgroupPaths
+}
+
+// Returns the sections of the stats which apply to the container. The
+// returned map must not be modified.
+func (self *dockerContainerHandler) getCapabilities() map[string]error {
+	self.pathsLock.RLock()
+	defer self.pathsLock.RUnlock()
+	return self.capabilities
+}
+
+func (self *dockerContainerHandler) ResolveCgroupPaths() {
+	cgroupPaths := self.cgroupMounts.CgroupPaths(self.name)
+	capabilities := containerLibcontainer.ProbeCapabilities(self.name, cgroupPaths)
+
+	self.pathsLock.Lock()
+	defer self.pathsLock.Unlock()
+	self.cgroupPaths = cgroupPaths
+	self.capabilities = capabilities
+}
+
 // TODO(vmarmol): Switch to getting this from libcontainer once we have a solid API.
 func (self *dockerContainerHandler) readLibcontainerConfig() (*libcontainer.Config, error) {
 	out, err := ioutil.ReadFile(self.libcontainerConfigPath)
@@ -198,7 +330,7 @@ func (self *dockerContainerHandler) readLibcontainerState() (state *libcontainer
 
 	// Create cgroup paths if they don't exist. This is since older Docker clients don't write it.
 	if len(state.CgroupPaths) == 0 {
-		state.CgroupPaths = self.cgroupPaths
+		state.CgroupPaths = self.getCgroupPaths()
 	}
 
 	return
@@ -206,26 +338,26 @@ func (self *dockerContainerHandler) readLibcontainerState() (state *libcontainer
 
 func libcontainerConfigToContainerSpec(config *libcontainer.Config, mi *info.MachineInfo) info.ContainerSpec {
 	var spec info.ContainerSpec
-	spec.HasMemory = true
 	spec.Memory.Limit = math.MaxUint64
-	spec.Memory.SwapLimit = math.MaxUint64
 	if config.Cgroups.Memory > 0 {
 		spec.Memory.Limit = uint64(config.Cgroups.Memory)
 	}
-	if config.Cgroups.MemorySwap > 0 {
-		spec.Memory.SwapLimit = uint64(config.Cgroups.MemorySwap)
-	}
 
 	// Get CPU info
-	spec.HasCpu = true
 	spec.Cpu.Limit = 1024
 	if config.Cgroups.CpuShares != 0 {
 		spec.Cpu.Limit = uint64(config.Cgroups.CpuShares)
 	}
 	spec.Cpu.Mask = utils.FixCpuMask(config.Cgroups.CpusetCpus, mi.NumCores)
+	if config.Cgroups.CpuQuota > 0 {
+		spec.Cpu.Quota = uint64(config.Cgroups.CpuQuota)
+		spec.Cpu.Period = defaultCfsPeriod
+		if config.Cgroups.CpuPeriod > 0 {
+			spec.Cpu.Period = uint64(config.Cgroups.CpuPeriod)
+		}
+	}
 
 	spec.HasNetwork = true
-	spec.HasDiskIo = true
 
 	return spec
 }
@@ -241,17 +373,62 @@ func (self *dockerContainerHandler) GetSpec() (info.ContainerSpec, error) {
 	}
 
 	spec := libcontainerConfigToContainerSpec(libcontainerConfig, mi)
+	// The config has the limits of all resources, which of them the container
+	// has depends on the cgroups found when the handler was created.
+	capabilities := self.getCapabilities()
+	_, spec.HasCpu = capabilities[info.StatsSectionCpu]
+	_, spec.HasMemory = capabilities[info.StatsSectionMemory]
+	_, spec.HasDiskIo = capabilities[info.StatsSectionDiskIo]
 	spec.CreationTime = self.creationTime
-	if self.usesAufsDriver {
+	spec.Labels = self.labels
+	spec.CgroupParent = path.Dir(self.name)
+	if self.networkSharedWith != "" {
+		spec.HasNetwork = false
+		spec.NetworkSharedWith = self.networkSharedWith
+	}
+
+	// The effective swap and OOM killer settings are only in the cgroup.
+	cgroupPaths := self.getCgroupPaths()
+	if memoryRoot, ok := cgroupPaths["memory"]; ok {
+		containerLibcontainer.GetMemorySpec(memoryRoot, &spec.Memory)
+	}
+	// So is the pids limit (--pids-limit), which the libcontainer config predates.
+	if pidsRoot, ok := cgroupPaths["pids"]; ok {
+		containerLibcontainer.GetPidsSpec(pidsRoot, &spec)
+	}
+	// And the I/O weights and throttle limits (--blkio-weight, --device-write-bps).
+	if blkioRoot, ok := cgroupPaths["blkio"]; ok {
+		spec.Blkio = containerLibcontainer.GetBlkioSpec(blkioRoot)
+	}
+	if len(self.storageDirs) != 0 {
 		spec.HasFilesystem = true
 	}
+	if self.fsLimit != 0 {
+		spec.Fs = &info.FsSpec{Limit: self.fsLimit}
+	}
+	spec.HasPressure = containerLibcontainer.HasPressure(cgroupPaths, false)
 
-	return spec, err
+	// Get the limits of the init process.
+	state, err := self.readLibcontainerState()
+	if err == nil && state.InitPid != 0 {
+		spec.ProcessLimits, err = procfs.GetProcessLimits(state.InitPid)
+		if err != nil {
+			glog.V(4).Infof("Failed to get limits of init process %d of container %q: %v", state.InitPid, self.name, err)
+		}
+		// Containers sharing the namespace of another report its identity,
+		// their init is in it too.
+		spec.Network, err = netns.GetNetworkSpec(state.InitPid)
+		if err != nil {
+			glog.V(4).Infof("Failed to get the network namespace of init process %d of container %q: %v", state.InitPid, self.name, err)
+		}
+	}
+
+	return spec, nil
 }
 
 func (self *dockerContainerHandler) getFsStats(stats *info.ContainerStats) error {
-	// No support for non-aufs storage drivers.
-	if !self.usesAufsDriver {
+	// No support for storage drivers whose files are not known.
+	if len(self.storageDirs) == 0 {
 		return nil
 	}
 
@@ -275,39 +452,131 @@ func (self *dockerContainerHandler) getFsStats(stats *info.ContainerStats) error
 		}
 	}
 
+	if self.fsLimit != 0 {
+		limit = self.fsLimit
+	}
+
 	fsStat := info.FsStats{Device: deviceInfo.Device, Limit: limit}
 
-	var usage uint64 = 0
+	// Where the container's files are under a project quota, the filesystem
+	// keeps their usage which is cheap to read.
+	usage, err := self.getQuotaUsage(deviceInfo.Device)
+	if err == nil {
+		fsStat.Usage = usage.Used
+		fsStat.UsageMethod = info.FsUsageQuota
+		if usage.Limit != 0 {
+			fsStat.Limit = usage.Limit
+		}
+		stats.Filesystem = append(stats.Filesystem, fsStat)
+		return nil
+	}
+	if err != quota.ErrNoQuota {
+		glog.V(4).Infof("Failed to read the project quota of container %q, falling back to du: %v", self.name, err)
+	}
+
+	// Otherwise it is computed with du which is expensive.
+	if atomic.LoadInt32(&self.skipExpensiveCollectors) != 0 {
+		return nil
+	}
+
+	self.duLock.Lock()
+	defer self.duLock.Unlock()
+	release, err := acquireExpensive(info.CollectorDu, sem.DuWeight)
+	if err != nil {
+		// Too many expensive collectors are running, the usage measured last
+		// is reported instead.
+		stats.StaleCollectors = append(stats.StaleCollectors, info.CollectorDu)
+		if self.hasLastDuUsage {
+			fsStat.Usage = self.lastDuUsage
+			fsStat.UsageMethod = info.FsUsageDu
+			stats.Filesystem = append(stats.Filesystem, fsStat)
+		}
+		return nil
+	}
+	defer release()
+
 	for _, dir := range self.storageDirs {
 		// TODO(Vishh): Add support for external mounts.
 		dirUsage, err := self.fsInfo.GetDirUsage(dir)
 		if err != nil {
 			return err
 		}
-		usage += dirUsage
+		fsStat.Usage += dirUsage
 	}
-	fsStat.Usage = usage
+	self.lastDuUsage = fsStat.Usage
+	self.hasLastDuUsage = true
+	fsStat.UsageMethod = info.FsUsageDu
 	stats.Filesystem = append(stats.Filesystem, fsStat)
 
 	return nil
 }
 
-func (self *dockerContainerHandler) GetStats() (stats *info.ContainerStats, err error) {
+// Returns the usage of the project quotas of the storage dirs, which are on
+// device. Returns quota.ErrNoQuota if any of them is not under a quota.
+func (self *dockerContainerHandler) getQuotaUsage(device string) (quota.Usage, error) {
+	var total quota.Usage
+	for _, dir := range self.storageDirs {
+		usage, err := getQuotaUsage(device, dir)
+		if err != nil {
+			return quota.Usage{}, err
+		}
+		total.Used += usage.Used
+		total.Limit += usage.Limit
+	}
+	return total, nil
+}
+
+func (self *dockerContainerHandler) GetStats() (*info.ContainerStats, error) {
+	return self.GetStatsTimed(timing.NewSections())
+}
+
+func (self *dockerContainerHandler) GetStatsTimed(sections *timing.Sections) (stats *info.ContainerStats, err error) {
+	endStateRead := sections.Time("libcontainer state read")
 	state, err := self.readLibcontainerState()
+	endStateRead()
 	if err != nil {
 		return nil, err
 	}
 
-	stats, err = containerLibcontainer.GetStats(self.cgroupPaths, state)
+	cgroupPaths := self.getCgroupPaths()
+	endCgroupRead := sections.Time("cgroup read")
+	stats, partial := containerLibcontainer.GetStats(cgroupPaths, state)
+	endCgroupRead()
+
+	endProcessCount := sections.Time("process count")
+	stats.ProcessCount = containerLibcontainer.CountProcesses(&self.cgroup)
+	endProcessCount()
+
+	if self.networkSharedWith != "" {
+		stats.Network = info.NetworkStats{}
+	} else if self.network != nil && state.InitPid != 0 {
+		// The stats of the host side of the veth of the container only
+		// cover one interface, prefer those of all its interfaces.
+		endNetworkRead := sections.Time("network read")
+		network, err := self.network.GetStats(state.InitPid)
+		endNetworkRead()
+		if err == nil {
+			stats.Network = network
+			delete(partial.Failures, info.StatsSectionNetwork)
+		} else {
+			glog.V(4).Infof("Failed to read the network stats of container %q from its namespace, using those of its veth: %v", self.name, err)
+		}
+	}
+
+	endPressureRead := sections.Time("pressure read")
+	stats.Pressure, err = containerLibcontainer.GetPressure(cgroupPaths, false)
+	endPressureRead()
 	if err != nil {
-		return stats, err
+		partial.Add(info.StatsSectionPressure, container.NewReadError(err))
 	}
+
+	defer sections.Time("filesystem read")()
 	err = self.getFsStats(stats)
 	if err != nil {
-		return stats, err
+		partial.Add(info.StatsSectionFilesystem, err)
 	}
 
-	return stats, nil
+	return stats, partial.OrNil()
 }
 
 func (self *dockerContainerHandler) ListContainers(listType container.ListType) ([]info.ContainerReference, error) {
@@ -317,7 +586,12 @@ func (self *dockerContainerHandler) ListContainers(listType container.ListType)
 	opt := docker.ListContainersOptions{
 		All: true,
 	}
-	containers, err := self.client.ListContainers(opt)
+	var containers []docker.APIContainers
+	err := container.RunWithTimeout("listing of the Docker containers", func() error {
+		var err error
+		containers, err = self.client.ListContainers(opt)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -340,7 +614,7 @@ func (self *dockerContainerHandler) ListContainers(listType container.ListType)
 }
 
 func (self *dockerContainerHandler) GetCgroupPath(resource string) (string, error) {
-	path, ok := self.cgroupPaths[resource]
+	path, ok := self.getCgroupPaths()[resource]
 	if !ok {
 		return "", fmt.Errorf("could not find path for resource %q for container %q\n", resource, self.name)
 	}
@@ -364,6 +638,24 @@ func (self *dockerContainerHandler) StopWatchingSubcontainers() error {
 	return nil
 }
 
+// Closes the network namespace kept open by the network stats collector.
+func (self *dockerContainerHandler) Cleanup() {
+	if self.network == nil {
+		return
+	}
+	if err := self.network.Close(); err != nil {
+		glog.Warningf("Failed to close the network namespace of container %q: %v", self.name, err)
+	}
+}
+
+func (self *dockerContainerHandler) SetExpensiveCollectorsEnabled(enabled bool) {
+	var skip int32
+	if !enabled {
+		skip = 1
+	}
+	atomic.StoreInt32(&self.skipExpensiveCollectors, skip)
+}
+
 func (self *dockerContainerHandler) Exists() bool {
 	// We consider the container existing if both libcontainer config and state files exist.
 	return utils.FileExists(self.libcontainerConfigPath) && utils.FileExists(self.libcontainerStatePath)
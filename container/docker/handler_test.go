@@ -0,0 +1,76 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/google/cadvisor/container"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Returns a client to a Docker daemon which never answers, until the returned
+// function is called.
+func wedgedDaemon(t *testing.T) (*docker.Client, func()) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	client, err := docker.NewClient(server.URL)
+	require.Nil(t, err)
+	return client, func() {
+		close(release)
+		server.Close()
+	}
+}
+
+func TestDaemonCallsTimeOut(t *testing.T) {
+	oldTimeout := *container.HandlerOpTimeout
+	*container.HandlerOpTimeout = 50 * time.Millisecond
+	defer func() {
+		*container.HandlerOpTimeout = oldTimeout
+	}()
+	client, release := wedgedDaemon(t)
+	defer release()
+
+	start := time.Now()
+	_, err := inspectContainer(client, testDockerId)
+	assert.True(t, container.IsTimeout(err), "inspect should time out, got %v", err)
+
+	handler := &dockerContainerHandler{
+		name:   "/docker",
+		client: client,
+	}
+	_, err = handler.ListContainers(container.ListSelf)
+	assert.True(t, container.IsTimeout(err), "listing should time out, got %v", err)
+	assert.True(t, time.Since(start) < 2*time.Second, "calls to a wedged daemon took %v", time.Since(start))
+}
+
+func TestNetworkOwnerThatCannotBeInspected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such container", http.StatusNotFound)
+	}))
+	defer server.Close()
+	client, err := docker.NewClient(server.URL)
+	require.Nil(t, err)
+
+	// The owner exited, the reference of the network mode is kept.
+	assert.Equal(t, "web", networkOwner(client, "web", testDockerId))
+}
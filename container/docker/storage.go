@@ -0,0 +1,94 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/docker/docker/pkg/units"
+)
+
+// Storage drivers of which cAdvisor knows where the containers' files are.
+const (
+	aufsStorageDriver         = "aufs"
+	overlayStorageDriver      = "overlay"
+	devicemapperStorageDriver = "devicemapper"
+)
+
+// Returns the directories holding the files written by the container, whose
+// usage is reported as that of its filesystem. Nil if they are not known for
+// the storage driver.
+func storageDirs(dockerRootDir, storageDriver, id string) []string {
+	switch storageDriver {
+	case aufsStorageDriver:
+		return []string{path.Join(dockerRootDir, pathToAufsDir, id)}
+	case overlayStorageDriver:
+		// The lower dirs are the image layers, shared with other containers.
+		return []string{path.Join(dockerRootDir, storageDriver, id, "upper")}
+	}
+	// The devicemapper devices are only mounted while the container runs.
+	return nil
+}
+
+// Returns the size limit of the container's filesystem in bytes, 0 if it is
+// unlimited. It is set with --storage-opt size=<size>, otherwise
+// devicemapper devices are limited to the base size of the daemon.
+func storageSizeLimit(dockerRootDir, storageDriver, id string) (uint64, error) {
+	// The client does not know the StorageOpt field, so it is read from the
+	// container's host config in the daemon's state.
+	hostConfigPath := path.Join(dockerRootDir, "containers", id, "hostconfig.json")
+	out, err := ioutil.ReadFile(hostConfigPath)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	if err == nil {
+		var hostConfig struct {
+			StorageOpt map[string]string
+		}
+		if err := json.Unmarshal(out, &hostConfig); err != nil {
+			return 0, fmt.Errorf("failed to parse host config at %q: %v", hostConfigPath, err)
+		}
+		if size, ok := hostConfig.StorageOpt["size"]; ok {
+			limit, err := units.RAMInBytes(size)
+			if err != nil || limit < 0 {
+				return 0, fmt.Errorf("invalid storage size %q in host config at %q", size, hostConfigPath)
+			}
+			return uint64(limit), nil
+		}
+	}
+
+	if storageDriver != devicemapperStorageDriver {
+		return 0, nil
+	}
+	metadataPath := path.Join(dockerRootDir, storageDriver, "metadata", id)
+	out, err = ioutil.ReadFile(metadataPath)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var metadata struct {
+		Size uint64 `json:"size"`
+	}
+	if err := json.Unmarshal(out, &metadata); err != nil {
+		return 0, fmt.Errorf("failed to parse devicemapper metadata at %q: %v", metadataPath, err)
+	}
+	return metadata.Size, nil
+}
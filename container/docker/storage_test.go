@@ -0,0 +1,248 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/fs"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/utils/quota"
+	"github.com/google/cadvisor/utils/sem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageDirs(t *testing.T) {
+	assert.Equal(t, []string{"/var/lib/docker/aufs/diff/" + testDockerId}, storageDirs("/var/lib/docker", "aufs", testDockerId))
+	assert.Equal(t, []string{"/var/lib/docker/overlay/" + testDockerId + "/upper"}, storageDirs("/var/lib/docker", "overlay", testDockerId))
+	assert.Nil(t, storageDirs("/var/lib/docker", "devicemapper", testDockerId))
+	assert.Nil(t, storageDirs("/var/lib/docker", "vfs", testDockerId))
+}
+
+func writeStateFile(t *testing.T, root, file, contents string) {
+	require.Nil(t, os.MkdirAll(path.Join(root, path.Dir(file)), 0755))
+	require.Nil(t, ioutil.WriteFile(path.Join(root, file), []byte(contents), 0644))
+}
+
+func TestStorageSizeLimit(t *testing.T) {
+	root, err := ioutil.TempDir("", "docker_root")
+	require.Nil(t, err)
+	defer os.RemoveAll(root)
+
+	// No state.
+	limit, err := storageSizeLimit(root, "devicemapper", testDockerId)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(0), limit)
+
+	// Base size of the devicemapper devices.
+	writeStateFile(t, root, "devicemapper/metadata/"+testDockerId, `{"device_id":12,"size":10737418240,"transaction_id":30,"initialized":false}`)
+	limit, err = storageSizeLimit(root, "devicemapper", testDockerId)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(10<<30), limit)
+
+	// Which does not apply to other drivers.
+	limit, err = storageSizeLimit(root, "overlay", testDockerId)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(0), limit)
+
+	// Unless set with --storage-opt.
+	writeStateFile(t, root, "containers/"+testDockerId+"/hostconfig.json", `{"Memory":0,"StorageOpt":null}`)
+	limit, err = storageSizeLimit(root, "devicemapper", testDockerId)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(10<<30), limit)
+
+	writeStateFile(t, root, "containers/"+testDockerId+"/hostconfig.json", `{"Memory":0,"StorageOpt":{"size":"2G"}}`)
+	limit, err = storageSizeLimit(root, "devicemapper", testDockerId)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(2<<30), limit)
+	limit, err = storageSizeLimit(root, "overlay", testDockerId)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(2<<30), limit)
+
+	writeStateFile(t, root, "containers/"+testDockerId+"/hostconfig.json", `{"StorageOpt":{"size":"lots"}}`)
+	_, err = storageSizeLimit(root, "overlay", testDockerId)
+	assert.NotNil(t, err)
+}
+
+type fakeFsInfo struct {
+	fs.FsInfo
+	device   string
+	dirUsage map[string]uint64
+	duCalls  int
+}
+
+func (self *fakeFsInfo) GetDirFsDevice(dir string) (*fs.DeviceInfo, error) {
+	return &fs.DeviceInfo{Device: self.device}, nil
+}
+
+func (self *fakeFsInfo) GetDirUsage(dir string) (uint64, error) {
+	self.duCalls++
+	usage, ok := self.dirUsage[dir]
+	if !ok {
+		return 0, errors.New("du failed")
+	}
+	return usage, nil
+}
+
+type fakeMachineInfoFactory struct {
+	machineInfo info.MachineInfo
+}
+
+func (self *fakeMachineInfoFactory) GetMachineInfo() (*info.MachineInfo, error) {
+	return &self.machineInfo, nil
+}
+
+func (self *fakeMachineInfoFactory) GetVersionInfo() (*info.VersionInfo, error) {
+	return &info.VersionInfo{}, nil
+}
+
+// Replaces the reads of the project quotas with ones returning the given usage
+// per dir, ErrNoQuota for the others.
+func fakeQuotas(quotas map[string]quota.Usage) func() {
+	oldGetQuotaUsage := getQuotaUsage
+	getQuotaUsage = func(device, dir string) (quota.Usage, error) {
+		usage, ok := quotas[dir]
+		if !ok {
+			return quota.Usage{}, quota.ErrNoQuota
+		}
+		return usage, nil
+	}
+	return func() {
+		getQuotaUsage = oldGetQuotaUsage
+	}
+}
+
+func newFsTestHandler(fsLimit uint64) (*dockerContainerHandler, *fakeFsInfo) {
+	dir := "/var/lib/docker/overlay/" + testDockerId + "/upper"
+	fsInfo := &fakeFsInfo{
+		device:   "/dev/sda1",
+		dirUsage: map[string]uint64{dir: 3 << 20},
+	}
+	handler := &dockerContainerHandler{
+		name:        "/docker/" + testDockerId,
+		fsInfo:      fsInfo,
+		storageDirs: []string{dir},
+		fsLimit:     fsLimit,
+		machineInfoFactory: &fakeMachineInfoFactory{
+			machineInfo: info.MachineInfo{
+				Filesystems: []info.FsInfo{{Device: "/dev/sda1", Capacity: 100 << 30}},
+			},
+		},
+	}
+	return handler, fsInfo
+}
+
+func TestGetFsStatsWithDu(t *testing.T) {
+	defer fakeQuotas(nil)()
+	handler, fsInfo := newFsTestHandler(0)
+
+	var stats info.ContainerStats
+	require.Nil(t, handler.getFsStats(&stats))
+	require.Equal(t, 1, len(stats.Filesystem))
+	assert.Equal(t, info.FsStats{Device: "/dev/sda1", Limit: 100 << 30, Usage: 3 << 20, UsageMethod: info.FsUsageDu}, stats.Filesystem[0])
+	assert.Equal(t, 1, fsInfo.duCalls)
+
+	// The size limit of the container applies.
+	handler, _ = newFsTestHandler(10 << 30)
+	stats = info.ContainerStats{}
+	require.Nil(t, handler.getFsStats(&stats))
+	require.Equal(t, 1, len(stats.Filesystem))
+	assert.Equal(t, uint64(10<<30), stats.Filesystem[0].Limit)
+
+	// du is not run when expensive collectors are skipped.
+	handler, fsInfo = newFsTestHandler(0)
+	handler.SetExpensiveCollectorsEnabled(false)
+	stats = info.ContainerStats{}
+	require.Nil(t, handler.getFsStats(&stats))
+	assert.Empty(t, stats.Filesystem)
+	assert.Equal(t, 0, fsInfo.duCalls)
+}
+
+// Replaces the turns of the expensive collectors with ones failing while busy
+// is set.
+func fakeExpensiveTurns(busy *bool) func() {
+	oldAcquireExpensive := acquireExpensive
+	acquireExpensive = func(collector string, weight int64) (func(), error) {
+		if *busy {
+			return nil, &sem.DeadlineError{Weight: weight, Deadline: time.Second}
+		}
+		return func() {}, nil
+	}
+	return func() {
+		acquireExpensive = oldAcquireExpensive
+	}
+}
+
+func TestGetFsStatsWithDuSkipped(t *testing.T) {
+	defer fakeQuotas(nil)()
+	busy := true
+	defer fakeExpensiveTurns(&busy)()
+	handler, fsInfo := newFsTestHandler(0)
+
+	// Without a previous run, there is no usage to report.
+	var stats info.ContainerStats
+	require.Nil(t, handler.getFsStats(&stats))
+	assert.Empty(t, stats.Filesystem)
+	assert.Equal(t, []string{info.CollectorDu}, stats.StaleCollectors)
+	assert.Equal(t, 0, fsInfo.duCalls)
+
+	busy = false
+	stats = info.ContainerStats{}
+	require.Nil(t, handler.getFsStats(&stats))
+	assert.Empty(t, stats.StaleCollectors)
+	assert.Equal(t, 1, fsInfo.duCalls)
+
+	// The usage of the last run is reported as stale.
+	busy = true
+	fsInfo.dirUsage[handler.storageDirs[0]] = 5 << 20
+	stats = info.ContainerStats{}
+	require.Nil(t, handler.getFsStats(&stats))
+	require.Equal(t, 1, len(stats.Filesystem))
+	assert.Equal(t, uint64(3<<20), stats.Filesystem[0].Usage)
+	assert.Equal(t, []string{info.CollectorDu}, stats.StaleCollectors)
+	assert.Equal(t, 1, fsInfo.duCalls)
+}
+
+func TestGetFsStatsWithQuota(t *testing.T) {
+	handler, fsInfo := newFsTestHandler(10 << 30)
+	defer fakeQuotas(map[string]quota.Usage{
+		handler.storageDirs[0]: {Used: 5 << 20, Limit: 2 << 30},
+	})()
+
+	// The quota is read even if expensive collectors are skipped, and its
+	// limit is the effective one.
+	handler.SetExpensiveCollectorsEnabled(false)
+	var stats info.ContainerStats
+	require.Nil(t, handler.getFsStats(&stats))
+	require.Equal(t, 1, len(stats.Filesystem))
+	assert.Equal(t, info.FsStats{Device: "/dev/sda1", Limit: 2 << 30, Usage: 5 << 20, UsageMethod: info.FsUsageQuota}, stats.Filesystem[0])
+	assert.Equal(t, 0, fsInfo.duCalls)
+}
+
+func TestGetFsStatsNoStorageDirs(t *testing.T) {
+	handler, fsInfo := newFsTestHandler(10 << 30)
+	handler.storageDirs = nil
+
+	var stats info.ContainerStats
+	require.Nil(t, handler.getFsStats(&stats))
+	assert.Empty(t, stats.Filesystem)
+	assert.Equal(t, 0, fsInfo.duCalls)
+}
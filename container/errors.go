@@ -0,0 +1,94 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"os"
+	"syscall"
+)
+
+// Classes of the errors reading the files of a container (e.g.: its cgroup
+// files), by what they say of the cause.
+const (
+	// Access was denied (EACCES, EPERM): a misconfiguration which will not go
+	// away by itself.
+	ErrorClassPermission = "permission"
+	// The file or the container is gone (ENOENT, ENODEV, ESRCH): usually the
+	// container racing away while it was read, which is benign.
+	ErrorClassGone = "gone"
+	// The read failed (EIO): a real problem of the machine.
+	ErrorClassIo = "io"
+	// Any other error, including those which do not carry an errno.
+	ErrorClassOther = "other"
+)
+
+// An error reading a file of a container, along with its class.
+type ReadError struct {
+	// One of the ErrorClass constants.
+	Class string
+	Err   error
+}
+
+func (self *ReadError) Error() string {
+	return self.Err.Error()
+}
+
+// Wraps the error of a read into a *ReadError classified by its errno. Nil
+// and errors which already are *ReadErrors are returned as they are.
+func NewReadError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*ReadError); ok {
+		return err
+	}
+	return &ReadError{
+		Class: classifyErrno(err),
+		Err:   err,
+	}
+}
+
+// Returns the class of the error: that of a *ReadError, otherwise that of
+// the errno it carries, if any.
+func ClassifyError(err error) string {
+	if readErr, ok := err.(*ReadError); ok {
+		return readErr.Class
+	}
+	return classifyErrno(err)
+}
+
+func classifyErrno(err error) string {
+	switch e := err.(type) {
+	case *os.PathError:
+		err = e.Err
+	case *os.SyscallError:
+		err = e.Err
+	case *os.LinkError:
+		err = e.Err
+	}
+	errno, ok := err.(syscall.Errno)
+	if !ok {
+		return ErrorClassOther
+	}
+	switch errno {
+	case syscall.EACCES, syscall.EPERM:
+		return ErrorClassPermission
+	case syscall.ENOENT, syscall.ENODEV, syscall.ESRCH:
+		return ErrorClassGone
+	case syscall.EIO:
+		return ErrorClassIo
+	}
+	return ErrorClassOther
+}
@@ -0,0 +1,60 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyError(t *testing.T) {
+	testCases := []struct {
+		err   error
+		class string
+	}{
+		{&os.PathError{Op: "open", Path: "memory.stat", Err: syscall.EACCES}, ErrorClassPermission},
+		{&os.PathError{Op: "open", Path: "memory.stat", Err: syscall.EPERM}, ErrorClassPermission},
+		{&os.PathError{Op: "open", Path: "memory.stat", Err: syscall.ENOENT}, ErrorClassGone},
+		{&os.PathError{Op: "read", Path: "memory.stat", Err: syscall.ENODEV}, ErrorClassGone},
+		{os.NewSyscallError("kill", syscall.ESRCH), ErrorClassGone},
+		{&os.PathError{Op: "read", Path: "memory.stat", Err: syscall.EIO}, ErrorClassIo},
+		{syscall.EIO, ErrorClassIo},
+		{&os.PathError{Op: "read", Path: "memory.stat", Err: syscall.EINVAL}, ErrorClassOther},
+		{errors.New("unable to parse memory.stat"), ErrorClassOther},
+		{&ReadError{Class: ErrorClassIo, Err: errors.New("failed")}, ErrorClassIo},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.class, ClassifyError(tc.err), "%v", tc.err)
+		wrapped := NewReadError(tc.err)
+		assert.Equal(t, tc.class, ClassifyError(wrapped), "%v", tc.err)
+		assert.Equal(t, tc.err.Error(), wrapped.Error())
+	}
+}
+
+func TestNewReadError(t *testing.T) {
+	assert.Nil(t, NewReadError(nil))
+
+	err := NewReadError(&os.PathError{Op: "open", Path: "memory.stat", Err: syscall.EACCES})
+	readErr, ok := err.(*ReadError)
+	if assert.True(t, ok, "expected a *ReadError, got %T", err) {
+		assert.Equal(t, ErrorClassPermission, readErr.Class)
+	}
+	// Wrapping again keeps the error.
+	assert.True(t, NewReadError(err) == err)
+}
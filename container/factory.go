@@ -32,10 +32,31 @@ type ContainerHandlerFactory interface {
 	String() string
 }
 
+// A namespace in which containers are also known by their aliases (e.g.:
+// the Docker containers by their ID or name).
+type Namespace struct {
+	// Name of the namespace, as in the references of its containers.
+	Name string
+
+	// Container under which those of the namespace are in the container
+	// tree (e.g.: "/docker").
+	MountPoint string
+}
+
+// A namespace and the factory serving its containers.
+type RegisteredNamespace struct {
+	Namespace
+
+	// Name of the factory.
+	Factory string
+}
+
 // TODO(vmarmol): Consider not making this global.
-// Global list of factories.
+// Global list of factories, and of the namespaces they serve in order of
+// registration.
 var (
 	factories     []ContainerHandlerFactory
+	namespaces    []RegisteredNamespace
 	factoriesLock sync.RWMutex
 )
 
@@ -48,6 +69,27 @@ func RegisterContainerHandlerFactory(factory ContainerHandlerFactory) {
 	factories = append(factories, factory)
 }
 
+// Register a ContainerHandlerFactory whose containers are in the specified
+// namespace. See RegisterContainerHandlerFactory for the order of registration.
+func RegisterNamespacedContainerHandlerFactory(factory ContainerHandlerFactory, namespace Namespace) {
+	factoriesLock.Lock()
+	defer factoriesLock.Unlock()
+
+	factories = append(factories, factory)
+	namespaces = append(namespaces, RegisteredNamespace{
+		Namespace: namespace,
+		Factory:   factory.String(),
+	})
+}
+
+// Returns the namespaces of the registered factories, in order of registration.
+func Namespaces() []RegisteredNamespace {
+	factoriesLock.RLock()
+	defer factoriesLock.RUnlock()
+
+	return append([]RegisteredNamespace(nil), namespaces...)
+}
+
 // Returns whether there are any container handler factories registered.
 func HasFactories() bool {
 	factoriesLock.Lock()
@@ -84,4 +126,5 @@ func ClearContainerHandlerFactories() {
 	defer factoriesLock.Unlock()
 
 	factories = make([]ContainerHandlerFactory, 0, 4)
+	namespaces = nil
 }
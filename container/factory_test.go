@@ -17,6 +17,7 @@ package container
 import (
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -120,3 +121,21 @@ func TestNewContainerHandler_NoneMatch(t *testing.T) {
 		t.Error("Expected NewContainerHandler to fail")
 	}
 }
+
+func TestNamespaces(t *testing.T) {
+	ClearContainerHandlerFactories()
+	assert.Empty(t, Namespaces())
+
+	RegisterNamespacedContainerHandlerFactory(&mockContainerHandlerFactory{Name: "lxc"}, Namespace{Name: "lxc", MountPoint: "/lxc"})
+	RegisterContainerHandlerFactory(&mockContainerHandlerFactory{Name: "raw"})
+	RegisterNamespacedContainerHandlerFactory(&mockContainerHandlerFactory{Name: "docker"}, Namespace{Name: "docker", MountPoint: "/docker"})
+
+	assert.Equal(t, []RegisteredNamespace{
+		{Namespace: Namespace{Name: "lxc", MountPoint: "/lxc"}, Factory: "lxc"},
+		{Namespace: Namespace{Name: "docker", MountPoint: "/docker"}, Factory: "docker"},
+	}, Namespaces())
+	assert.True(t, HasFactories())
+
+	ClearContainerHandlerFactories()
+	assert.Empty(t, Namespaces())
+}
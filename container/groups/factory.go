@@ -0,0 +1,65 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package groups
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/container"
+)
+
+type groupsFactory struct {
+	// Label whose value groups the containers.
+	label string
+
+	source Source
+}
+
+func (self *groupsFactory) String() string {
+	return "groups"
+}
+
+func (self *groupsFactory) NewContainerHandler(name string) (container.ContainerHandler, error) {
+	return newGroupsHandler(name, self.label, self.source)
+}
+
+// The groups factory can handle Root and any container directly under it.
+func (self *groupsFactory) CanHandle(name string) (bool, error) {
+	if name == Root {
+		return true, nil
+	}
+	_, ok := groupValue(name)
+	return ok, nil
+}
+
+// Registers the factory of the containers of the groups of the containers
+// with the label. It must be registered before the factories of cgroup
+// containers, which can handle any name.
+func Register(label string, source Source) error {
+	if label == "" {
+		return fmt.Errorf("no label to group the containers by")
+	}
+
+	glog.Infof("Registering groups factory, grouping containers by label %q", label)
+	container.RegisterNamespacedContainerHandlerFactory(&groupsFactory{
+		label:  label,
+		source: source,
+	}, container.Namespace{
+		Name:       GroupsNamespace,
+		MountPoint: Root,
+	})
+	return nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package groups reports the containers which share the value of a label
+// (e.g.: the containers of a Kubernetes pod) as a single container under
+// /groups, whose usage is the sum of theirs.
+package groups
+
+import (
+	"path"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Root of the containers of the groups.
+const Root = "/groups"
+
+// Namespace of the aliases of the containers of the groups, the value of the label.
+const GroupsNamespace = "groups"
+
+// A container which is a member of a group.
+type Member struct {
+	Name string
+	Spec info.ContainerSpec
+}
+
+// The containers the groups are made of and their stats, from the manager.
+type Source interface {
+	// Returns the containers with the label by its value, along with their
+	// last known spec.
+	ListMembers(label string) map[string][]Member
+
+	// Returns the most recent stats of the containers at a single instant,
+	// the cut. Containers without stats that old are left out.
+	Snapshot(names []string) (time.Time, map[string]*info.ContainerStats)
+}
+
+// Returns the name of the container of the group with the value of the
+// label, false if the value cannot be part of a container name.
+func GroupName(value string) (string, bool) {
+	if value == "" || value == "." || value == ".." || path.Base(value) != value {
+		return "", false
+	}
+	return path.Join(Root, value), true
+}
+
+// Returns the value of the label of the group of the container, if it is one.
+func groupValue(name string) (string, bool) {
+	if path.Dir(name) != Root {
+		return "", false
+	}
+	return path.Base(name), true
+}
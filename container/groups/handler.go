@@ -0,0 +1,291 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Handler for the containers of groups, backed by the stats of their members.
+package groups
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/cadvisor/container"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+type groupsHandler struct {
+	// Absolute name of the container.
+	name string
+
+	// Value of the label of the group. Unset for Root, which has none.
+	value string
+
+	// Label whose value groups the containers.
+	label string
+
+	source Source
+
+	// Cut of the last stats returned, protected by lock. The stats of a
+	// group only change when all its members have newer stats.
+	lastCut time.Time
+	lock    sync.Mutex
+}
+
+func newGroupsHandler(name, label string, source Source) (container.ContainerHandler, error) {
+	self := &groupsHandler{
+		name:   name,
+		label:  label,
+		source: source,
+	}
+	if name != Root {
+		var ok bool
+		self.value, ok = groupValue(name)
+		if !ok {
+			return nil, fmt.Errorf("%q is not the container of a group", name)
+		}
+	}
+	return self, nil
+}
+
+func (self *groupsHandler) isRoot() bool {
+	return self.name == Root
+}
+
+// Returns the members of the group, an error if it has none.
+func (self *groupsHandler) members() ([]Member, error) {
+	members := self.source.ListMembers(self.label)[self.value]
+	if len(members) == 0 {
+		return nil, fmt.Errorf("no container has label %q with value %q", self.label, self.value)
+	}
+	return members, nil
+}
+
+func (self *groupsHandler) ContainerReference() (info.ContainerReference, error) {
+	if self.isRoot() {
+		return info.ContainerReference{Name: self.name}, nil
+	}
+	return info.ContainerReference{
+		Name:      self.name,
+		Aliases:   []string{self.value},
+		Namespace: GroupsNamespace,
+	}, nil
+}
+
+// The spec of a group has the resources of any of its members, limited by
+// the largest of their limits. It was created with its oldest member.
+func (self *groupsHandler) GetSpec() (info.ContainerSpec, error) {
+	var spec info.ContainerSpec
+	if self.isRoot() {
+		return spec, nil
+	}
+	members, err := self.members()
+	if err != nil {
+		return spec, err
+	}
+	spec.Labels = map[string]string{self.label: self.value}
+	for _, member := range members {
+		s := member.Spec
+		if spec.CreationTime.IsZero() || s.CreationTime.Before(spec.CreationTime) {
+			spec.CreationTime = s.CreationTime
+		}
+		if s.HasCpu {
+			spec.HasCpu = true
+			spec.Cpu.Limit = maxUint64(spec.Cpu.Limit, s.Cpu.Limit)
+			spec.Cpu.MaxLimit = maxUint64(spec.Cpu.MaxLimit, s.Cpu.MaxLimit)
+		}
+		if s.HasMemory {
+			spec.HasMemory = true
+			spec.Memory.Limit = maxUint64(spec.Memory.Limit, s.Memory.Limit)
+			spec.Memory.Reservation = maxUint64(spec.Memory.Reservation, s.Memory.Reservation)
+		}
+		spec.HasNetwork = spec.HasNetwork || s.HasNetwork
+		spec.HasPids = spec.HasPids || s.HasPids
+	}
+	return spec, nil
+}
+
+func maxUint64(a, b uint64) uint64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// The stats of a group are the sum of the stats of its members at the cut of
+// their latest stats, so that all are from the same instant. No stats are
+// returned until the members have stats newer than those last returned.
+func (self *groupsHandler) GetStats() (*info.ContainerStats, error) {
+	if self.isRoot() {
+		return &info.ContainerStats{
+			Timestamp: time.Now(),
+		}, nil
+	}
+	members, err := self.members()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(members))
+	for _, member := range members {
+		names = append(names, member.Name)
+	}
+	cut, stats := self.source.Snapshot(names)
+	if len(stats) == 0 {
+		return nil, fmt.Errorf("no stats of the members of group %q", self.value)
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if !cut.After(self.lastCut) {
+		return nil, nil
+	}
+	self.lastCut = cut
+
+	sum, missing := sumStats(cut, stats)
+	if len(missing) == 0 {
+		return sum, nil
+	}
+	partial := container.NewPartialStatsError()
+	for section, member := range missing {
+		partial.Add(section, fmt.Errorf("missing from the stats of member %q", member))
+	}
+	return sum, partial
+}
+
+// Sums the stats of the members at the cut. Returns the sections missing from
+// the stats of a member, along with the member.
+func sumStats(cut time.Time, stats map[string]*info.ContainerStats) (*info.ContainerStats, map[string]string) {
+	ret := &info.ContainerStats{
+		Timestamp: cut,
+	}
+	missing := make(map[string]string)
+	for name, s := range stats {
+		for _, section := range s.PartialFailure {
+			missing[section] = name
+		}
+		// The monotonic time of the cut is that of the member sampled at the cut.
+		if s.Timestamp.Equal(cut) {
+			ret.MonotonicTimestamp = s.MonotonicTimestamp
+		}
+
+		usage := &ret.Cpu.Usage
+		usage.Total += s.Cpu.Usage.Total
+		usage.User += s.Cpu.Usage.User
+		usage.System += s.Cpu.Usage.System
+		for len(usage.PerCpu) < len(s.Cpu.Usage.PerCpu) {
+			usage.PerCpu = append(usage.PerCpu, 0)
+		}
+		for i, value := range s.Cpu.Usage.PerCpu {
+			usage.PerCpu[i] += value
+		}
+		ret.Cpu.LoadAverage += s.Cpu.LoadAverage
+
+		ret.Memory.Usage += s.Memory.Usage
+		ret.Memory.WorkingSet += s.Memory.WorkingSet
+		ret.Memory.Swap += s.Memory.Swap
+		addMemoryData(&ret.Memory.ContainerData, s.Memory.ContainerData)
+		addMemoryData(&ret.Memory.HierarchicalData, s.Memory.HierarchicalData)
+
+		// Members sharing the network of another have no network stats of
+		// their own, the traffic is only counted once.
+		ret.Network.RxBytes += s.Network.RxBytes
+		ret.Network.RxPackets += s.Network.RxPackets
+		ret.Network.RxErrors += s.Network.RxErrors
+		ret.Network.RxDropped += s.Network.RxDropped
+		ret.Network.TxBytes += s.Network.TxBytes
+		ret.Network.TxPackets += s.Network.TxPackets
+		ret.Network.TxErrors += s.Network.TxErrors
+		ret.Network.TxDropped += s.Network.TxDropped
+
+		ret.TaskStats.NrSleeping += s.TaskStats.NrSleeping
+		ret.TaskStats.NrRunning += s.TaskStats.NrRunning
+		ret.TaskStats.NrStopped += s.TaskStats.NrStopped
+		ret.TaskStats.NrUninterruptible += s.TaskStats.NrUninterruptible
+		ret.TaskStats.NrIoWait += s.TaskStats.NrIoWait
+
+		if s.Pids != nil {
+			if ret.Pids == nil {
+				ret.Pids = &info.PidsStats{}
+			}
+			ret.Pids.Current += s.Pids.Current
+			ret.Pids.LimitHits += s.Pids.LimitHits
+		}
+	}
+	return ret, missing
+}
+
+func addMemoryData(sum *info.MemoryStatsMemoryData, data info.MemoryStatsMemoryData) {
+	sum.Pgfault += data.Pgfault
+	sum.Pgmajfault += data.Pgmajfault
+	sum.Pgpgin += data.Pgpgin
+	sum.Pgpgout += data.Pgpgout
+	sum.PgscanDirect += data.PgscanDirect
+	sum.PgscanKswapd += data.PgscanKswapd
+	sum.Pgsteal += data.Pgsteal
+}
+
+// Root lists the groups with at least one member, which have no subcontainers.
+func (self *groupsHandler) ListContainers(listType container.ListType) ([]info.ContainerReference, error) {
+	if !self.isRoot() {
+		return nil, nil
+	}
+	var ret []info.ContainerReference
+	for value, members := range self.source.ListMembers(self.label) {
+		name, ok := GroupName(value)
+		if !ok || len(members) == 0 {
+			continue
+		}
+		ret = append(ret, info.ContainerReference{
+			Name:      name,
+			Aliases:   []string{value},
+			Namespace: GroupsNamespace,
+		})
+	}
+	sort.Sort(info.ContainerReferenceSlice(ret))
+	return ret, nil
+}
+
+// The threads and processes are those of the members.
+func (self *groupsHandler) ListThreads(listType container.ListType) ([]int, error) {
+	return nil, nil
+}
+
+func (self *groupsHandler) ListProcesses(listType container.ListType) ([]int, error) {
+	return nil, nil
+}
+
+// Groups are detected by listing them, there are no events to watch.
+func (self *groupsHandler) WatchSubcontainers(events chan container.SubcontainerEvent) error {
+	return nil
+}
+
+func (self *groupsHandler) StopWatchingSubcontainers() error {
+	return nil
+}
+
+func (self *groupsHandler) GetCgroupPath(resource string) (string, error) {
+	return "", fmt.Errorf("group containers are not cgroups")
+}
+
+func (self *groupsHandler) Exists() bool {
+	if self.isRoot() {
+		return true
+	}
+	_, err := self.members()
+	return err == nil
+}
+
+// The stats of a group are summed from those already collected.
+func (self *groupsHandler) SetExpensiveCollectorsEnabled(enabled bool) {
+}
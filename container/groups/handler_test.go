@@ -0,0 +1,223 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package groups
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/container"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const podLabel = "io.kubernetes.pod.name"
+
+// The members of the groups, with their stats in memory storage.
+type fakeSource struct {
+	members map[string][]Member
+	storage *memory.InMemoryStorage
+}
+
+func (self *fakeSource) ListMembers(label string) map[string][]Member {
+	if label != podLabel {
+		return nil
+	}
+	return self.members
+}
+
+func (self *fakeSource) Snapshot(names []string) (time.Time, map[string]*info.ContainerStats) {
+	return self.storage.Snapshot(names)
+}
+
+func (self *fakeSource) addStats(t *testing.T, name string, stats *info.ContainerStats) {
+	require.Nil(t, self.storage.AddStats(info.ContainerReference{Name: name}, stats))
+}
+
+var podStart = time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func memberSpec(created time.Duration, memoryLimit uint64) info.ContainerSpec {
+	return info.ContainerSpec{
+		CreationTime: podStart.Add(created),
+		Labels:       map[string]string{podLabel: "web"},
+		HasCpu:       true,
+		Cpu:          info.CpuSpec{Limit: 1024},
+		HasMemory:    true,
+		Memory:       info.MemorySpec{Limit: memoryLimit},
+		HasNetwork:   true,
+	}
+}
+
+func memberStats(at time.Duration, cpu uint64, perCpu []uint64, memory uint64, rxBytes uint64) *info.ContainerStats {
+	stats := &info.ContainerStats{
+		Timestamp: podStart.Add(at),
+	}
+	stats.Cpu.Usage.Total = cpu
+	stats.Cpu.Usage.PerCpu = perCpu
+	stats.Memory.Usage = memory
+	stats.Memory.WorkingSet = memory / 2
+	stats.Network.RxBytes = rxBytes
+	return stats
+}
+
+// A pod of three containers: the pause container holding the network, the
+// application and a sidecar sharing it.
+func newFakePod() *fakeSource {
+	return &fakeSource{
+		members: map[string][]Member{
+			"web": {
+				{Name: "/docker/pause", Spec: memberSpec(0, 1<<20)},
+				{Name: "/docker/app", Spec: memberSpec(time.Second, 512<<20)},
+				{Name: "/docker/sidecar", Spec: memberSpec(2*time.Second, 64<<20)},
+			},
+		},
+		storage: memory.New(60, nil),
+	}
+}
+
+func newTestHandler(t *testing.T, name string, source Source) container.ContainerHandler {
+	handler, err := newGroupsHandler(name, podLabel, source)
+	require.Nil(t, err)
+	return handler
+}
+
+func TestGroupsFactoryCanHandle(t *testing.T) {
+	factory := &groupsFactory{label: podLabel, source: newFakePod()}
+	for name, expected := range map[string]bool{
+		"/groups":         true,
+		"/groups/web":     true,
+		"/groups/web/sub": false,
+		"/docker":         false,
+		"/":               false,
+	} {
+		canHandle, err := factory.CanHandle(name)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, canHandle, name)
+	}
+}
+
+func TestGroupName(t *testing.T) {
+	name, ok := GroupName("web")
+	assert.True(t, ok)
+	assert.Equal(t, "/groups/web", name)
+	for _, value := range []string{"", ".", "..", "a/b"} {
+		_, ok := GroupName(value)
+		assert.False(t, ok, value)
+	}
+}
+
+func TestGroupsRootListsGroups(t *testing.T) {
+	pod := newFakePod()
+	pod.members["db"] = []Member{{Name: "/docker/db"}}
+	pod.members["not/a/name"] = []Member{{Name: "/docker/other"}}
+	root := newTestHandler(t, Root, pod)
+	subcontainers, err := root.ListContainers(container.ListRecursive)
+	require.Nil(t, err)
+	assert.Equal(t, []info.ContainerReference{
+		{Name: "/groups/db", Aliases: []string{"db"}, Namespace: GroupsNamespace},
+		{Name: "/groups/web", Aliases: []string{"web"}, Namespace: GroupsNamespace},
+	}, subcontainers)
+}
+
+func TestGroupSpec(t *testing.T) {
+	handler := newTestHandler(t, "/groups/web", newFakePod())
+	assert.True(t, handler.Exists())
+	spec, err := handler.GetSpec()
+	require.Nil(t, err)
+	assert.Equal(t, podStart, spec.CreationTime)
+	assert.Equal(t, map[string]string{podLabel: "web"}, spec.Labels)
+	assert.True(t, spec.HasCpu)
+	assert.True(t, spec.HasMemory)
+	assert.True(t, spec.HasNetwork)
+	assert.Equal(t, uint64(1024), spec.Cpu.Limit)
+	// The capacity of the group is the largest limit of its members.
+	assert.Equal(t, uint64(512<<20), spec.Memory.Limit)
+}
+
+func TestGroupStatsSumMembers(t *testing.T) {
+	pod := newFakePod()
+	pod.addStats(t, "/docker/pause", memberStats(time.Second, 10, []uint64{5, 5}, 1000, 300))
+	pod.addStats(t, "/docker/app", memberStats(time.Second, 200, []uint64{150, 50}, 50000, 0))
+	pod.addStats(t, "/docker/sidecar", memberStats(time.Second, 30, []uint64{30}, 4000, 0))
+
+	stats, err := newTestHandler(t, "/groups/web", pod).GetStats()
+	require.Nil(t, err)
+	assert.Equal(t, podStart.Add(time.Second), stats.Timestamp)
+	assert.Equal(t, uint64(240), stats.Cpu.Usage.Total)
+	assert.Equal(t, []uint64{185, 55}, stats.Cpu.Usage.PerCpu)
+	assert.Equal(t, uint64(55000), stats.Memory.Usage)
+	assert.Equal(t, uint64(27500), stats.Memory.WorkingSet)
+	// Only the pause container has network stats, the others share its network.
+	assert.Equal(t, uint64(300), stats.Network.RxBytes)
+}
+
+func TestGroupStatsAtConsistentCut(t *testing.T) {
+	pod := newFakePod()
+	pod.addStats(t, "/docker/pause", memberStats(time.Second, 10, nil, 1000, 0))
+	pod.addStats(t, "/docker/app", memberStats(time.Second, 200, nil, 50000, 0))
+	pod.addStats(t, "/docker/app", memberStats(2*time.Second, 300, nil, 60000, 0))
+	pod.addStats(t, "/docker/sidecar", memberStats(time.Second, 30, nil, 4000, 0))
+	pod.addStats(t, "/docker/sidecar", memberStats(3*time.Second, 40, nil, 5000, 0))
+
+	// The pause container was last sampled at 1s, the group is summed as of then.
+	handler := newTestHandler(t, "/groups/web", pod)
+	stats, err := handler.GetStats()
+	require.Nil(t, err)
+	assert.Equal(t, podStart.Add(time.Second), stats.Timestamp)
+	assert.Equal(t, uint64(240), stats.Cpu.Usage.Total)
+	assert.Equal(t, uint64(55000), stats.Memory.Usage)
+
+	// Nothing new until every member has newer stats.
+	stats, err = handler.GetStats()
+	assert.Nil(t, err)
+	assert.Nil(t, stats)
+
+	pod.addStats(t, "/docker/pause", memberStats(3*time.Second, 20, nil, 1000, 0))
+	stats, err = handler.GetStats()
+	require.Nil(t, err)
+	assert.Equal(t, podStart.Add(2*time.Second), stats.Timestamp)
+	assert.Equal(t, uint64(340), stats.Cpu.Usage.Total)
+}
+
+func TestGroupStatsReportMissingSections(t *testing.T) {
+	pod := newFakePod()
+	pod.addStats(t, "/docker/pause", memberStats(time.Second, 10, nil, 1000, 0))
+	app := memberStats(time.Second, 200, nil, 0, 0)
+	app.PartialFailure = []string{info.StatsSectionMemory}
+	pod.addStats(t, "/docker/app", app)
+
+	stats, err := newTestHandler(t, "/groups/web", pod).GetStats()
+	require.NotNil(t, stats)
+	partial, ok := err.(*container.PartialStatsError)
+	require.True(t, ok)
+	assert.Equal(t, []string{info.StatsSectionMemory}, partial.Sections())
+	assert.Equal(t, uint64(210), stats.Cpu.Usage.Total)
+}
+
+func TestGroupWithoutMembers(t *testing.T) {
+	pod := newFakePod()
+	handler := newTestHandler(t, "/groups/gone", pod)
+	assert.False(t, handler.Exists())
+	_, err := handler.GetSpec()
+	assert.NotNil(t, err)
+	_, err = handler.GetStats()
+	assert.NotNil(t, err)
+
+	// Members without stats yet.
+	_, err = newTestHandler(t, "/groups/web", pod).GetStats()
+	assert.NotNil(t, err)
+}
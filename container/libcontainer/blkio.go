@@ -0,0 +1,191 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/docker/libcontainer/cgroups"
+	"github.com/golang/glog"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Directory of the block devices of the host, by "major:minor".
+var sysDevBlock = "/sys/dev/block"
+
+// Gets the I/O weights and throttle limits of the blkio cgroup at blkioRoot.
+// Files that do not exist (e.g.: blkio.weight without the CFQ scheduler) are
+// left unset. Returns nil if the cgroup does not exist.
+func GetBlkioSpec(blkioRoot string) *info.BlkioSpec {
+	if _, err := os.Stat(blkioRoot); err != nil {
+		return nil
+	}
+	spec := &info.BlkioSpec{
+		Devices: make(map[string]info.BlkioDeviceSpec),
+	}
+	if weight, ok := readUint64(blkioRoot, "blkio.weight"); ok {
+		spec.Weight = weight
+	}
+
+	devices := []struct {
+		file string
+		set  func(*info.BlkioDeviceSpec, uint64)
+	}{
+		{"blkio.weight_device", func(d *info.BlkioDeviceSpec, v uint64) { d.Weight = v }},
+		{"blkio.throttle.read_bps_device", func(d *info.BlkioDeviceSpec, v uint64) { d.ReadBps = v }},
+		{"blkio.throttle.write_bps_device", func(d *info.BlkioDeviceSpec, v uint64) { d.WriteBps = v }},
+		{"blkio.throttle.read_iops_device", func(d *info.BlkioDeviceSpec, v uint64) { d.ReadIops = v }},
+		{"blkio.throttle.write_iops_device", func(d *info.BlkioDeviceSpec, v uint64) { d.WriteIops = v }},
+	}
+	for _, d := range devices {
+		deviceFile := path.Join(blkioRoot, d.file)
+		out, err := ioutil.ReadFile(deviceFile)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				glog.Errorf("Failed to read %q: %v", deviceFile, err)
+			}
+			continue
+		}
+		values, err := parseBlkioDeviceFile(string(out))
+		if err != nil {
+			glog.Errorf("Failed to parse %q: %v", deviceFile, err)
+			continue
+		}
+		for device, value := range values {
+			deviceSpec := spec.Devices[device]
+			d.set(&deviceSpec, value)
+			spec.Devices[device] = deviceSpec
+		}
+	}
+
+	for device, deviceSpec := range spec.Devices {
+		if _, err := os.Stat(path.Join(sysDevBlock, device)); os.IsNotExist(err) {
+			deviceSpec.Absent = true
+			spec.Devices[device] = deviceSpec
+		}
+	}
+	if len(spec.Devices) == 0 {
+		spec.Devices = nil
+	}
+	return spec
+}
+
+// Parses the "major:minor value" lines of the per-device blkio files. Lines
+// of other keys (e.g.: "default 500" in blkio.weight_device of newer kernels)
+// are skipped.
+func parseBlkioDeviceFile(contents string) (map[string]uint64, error) {
+	values := make(map[string]uint64)
+	for _, line := range strings.Split(contents, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid line %q", line)
+		}
+		if _, _, err := parseDevice(fields[0]); err != nil {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value in line %q: %v", line, err)
+		}
+		values[fields[0]] = value
+	}
+	return values, nil
+}
+
+// Parses a "major:minor" device number.
+func parseDevice(device string) (uint64, uint64, error) {
+	parts := strings.Split(device, ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid device %q", device)
+	}
+	major, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid device %q: %v", device, err)
+	}
+	minor, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid device %q: %v", device, err)
+	}
+	return major, minor, nil
+}
+
+// Fills in the stats of the throttling policy of the blkio cgroup at
+// blkioRoot. libcontainer only reads them when the CFQ stats are missing,
+// while they are the only ones to compare with the throttle limits.
+func GetThrottleStats(blkioRoot string, stats *info.DiskIoStats) error {
+	var err error
+	stats.ThrottleIoServiceBytes, err = readBlkioStats(blkioRoot, "blkio.throttle.io_service_bytes")
+	if err != nil {
+		return err
+	}
+	stats.ThrottleIoServiced, err = readBlkioStats(blkioRoot, "blkio.throttle.io_serviced")
+	return err
+}
+
+// Reads the per-device stats of the specified blkio file. Returns nil if the
+// file does not exist.
+func readBlkioStats(dirpath, file string) ([]info.PerDiskStats, error) {
+	statsFile := path.Join(dirpath, file)
+	out, err := ioutil.ReadFile(statsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	entries, err := parseBlkioStats(string(out))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %v", statsFile, err)
+	}
+	return DiskStatsCopy(entries), nil
+}
+
+// Parses the "major:minor op value" lines of the blkio stats files. The
+// closing "Total value" line sums all devices and is skipped.
+func parseBlkioStats(contents string) ([]cgroups.BlkioStatEntry, error) {
+	var entries []cgroups.BlkioStatEntry
+	for _, line := range strings.Split(contents, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] == "Total" {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid line %q", line)
+		}
+		major, minor, err := parseDevice(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		value, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value in line %q: %v", line, err)
+		}
+		entries = append(entries, cgroups.BlkioStatEntry{
+			Major: major,
+			Minor: minor,
+			Op:    fields[1],
+			Value: value,
+		})
+	}
+	return entries, nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+func TestParseBlkioDeviceFile(t *testing.T) {
+	values, err := parseBlkioDeviceFile("default 500\n8:0 300\n8:16  200\n\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]uint64{
+		"8:0":  300,
+		"8:16": 200,
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+
+	if _, err := parseBlkioDeviceFile("8:0\n"); err == nil {
+		t.Error("Expected an error parsing a line without a value")
+	}
+	if _, err := parseBlkioDeviceFile("8:0 fast\n"); err == nil {
+		t.Error("Expected an error parsing an invalid value")
+	}
+}
+
+// Sets the block devices of the host to the specified ones for the duration of a test.
+func fakeBlockDevices(t *testing.T, devices ...string) func() {
+	dir, err := ioutil.TempDir("", "blkio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, device := range devices {
+		if err := os.Mkdir(path.Join(dir, device), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	orig := sysDevBlock
+	sysDevBlock = dir
+	return func() {
+		sysDevBlock = orig
+		os.RemoveAll(dir)
+	}
+}
+
+func TestGetBlkioSpec(t *testing.T) {
+	defer fakeBlockDevices(t, "8:0")()
+
+	spec := GetBlkioSpec("testdata/blkio-throttled")
+	expected := &info.BlkioSpec{
+		Weight: 500,
+		Devices: map[string]info.BlkioDeviceSpec{
+			"8:0": {
+				Weight:   300,
+				WriteBps: 1048576,
+				ReadIops: 100,
+			},
+			"253:99": {
+				WriteBps: 2097152,
+				Absent:   true,
+			},
+		},
+	}
+	if !reflect.DeepEqual(spec, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, spec)
+	}
+}
+
+func TestGetBlkioSpecWithoutLimits(t *testing.T) {
+	defer fakeBlockDevices(t)()
+
+	// The cgroup of another subsystem has none of the blkio files.
+	spec := GetBlkioSpec("testdata/pids-unlimited")
+	if spec == nil || spec.Weight != 0 || spec.Devices != nil {
+		t.Errorf("Expected an empty spec, got %+v", spec)
+	}
+
+	if spec := GetBlkioSpec("testdata/does-not-exist"); spec != nil {
+		t.Errorf("Expected no spec, got %+v", spec)
+	}
+}
+
+func TestGetThrottleStats(t *testing.T) {
+	var stats info.DiskIoStats
+	if err := GetThrottleStats("testdata/blkio-throttled", &stats); err != nil {
+		t.Fatal(err)
+	}
+	expectedBytes := []info.PerDiskStats{
+		{
+			Major: 8,
+			Minor: 0,
+			Stats: map[string]uint64{
+				"Read":  4096,
+				"Write": 10485760,
+				"Sync":  10485760,
+				"Async": 4096,
+				"Total": 10489856,
+			},
+		},
+	}
+	if !reflect.DeepEqual(stats.ThrottleIoServiceBytes, expectedBytes) {
+		t.Errorf("Expected %+v, got %+v", expectedBytes, stats.ThrottleIoServiceBytes)
+	}
+	if len(stats.ThrottleIoServiced) != 1 || stats.ThrottleIoServiced[0].Stats["Write"] != 10 {
+		t.Errorf("Expected 10 writes to 8:0, got %+v", stats.ThrottleIoServiced)
+	}
+}
+
+func TestGetThrottleStatsMissingFiles(t *testing.T) {
+	var stats info.DiskIoStats
+	if err := GetThrottleStats("testdata/pids-unlimited", &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.ThrottleIoServiceBytes != nil || stats.ThrottleIoServiced != nil {
+		t.Errorf("Expected no throttle stats, got %+v", stats)
+	}
+}
+
+func TestParseBlkioStatsInvalid(t *testing.T) {
+	if _, err := parseBlkioStats("8:0 Read\n"); err == nil {
+		t.Error("Expected an error parsing a line without a value")
+	}
+	if _, err := parseBlkioStats("sda Read 1\n"); err == nil {
+		t.Error("Expected an error parsing an invalid device")
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/golang/glog"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// A file of a cgroup subsystem whose stats are collected into a section.
+type capabilityProbe struct {
+	subsystem string
+	file      string
+}
+
+// Files read to find whether the stats of each section can be collected, by section.
+var capabilityProbes = map[string]capabilityProbe{
+	info.StatsSectionCpu:    {"cpuacct", "cpuacct.usage"},
+	info.StatsSectionMemory: {"memory", "memory.usage_in_bytes"},
+	info.StatsSectionDiskIo: {"blkio", "blkio.io_service_bytes_recursive"},
+}
+
+// Probes which sections of the stats can be collected from the cgroups of a
+// container by reading a file of each subsystem. Sections whose subsystem is
+// not mounted, or does not have the container or the file, are not applicable
+// and absent from the returned map. The others map to nil if the file could
+// be read and to the error reading it otherwise: the container has the
+// resource but its stats cannot be collected, which is logged.
+func ProbeCapabilities(name string, cgroupPaths map[string]string) map[string]error {
+	capabilities := make(map[string]error, len(capabilityProbes))
+	for section, probe := range capabilityProbes {
+		dir, ok := cgroupPaths[probe.subsystem]
+		if !ok {
+			continue
+		}
+		_, err := ioutil.ReadFile(path.Join(dir, probe.file))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			glog.Warningf("The %s stats of container %q cannot be collected: %v", section, name, err)
+		}
+		capabilities[section] = err
+	}
+	return capabilities
+}
@@ -0,0 +1,72 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+func TestProbeCapabilities(t *testing.T) {
+	dir, err := ioutil.TempDir("", "capabilities")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cpuacct := path.Join(dir, "cpuacct")
+	memory := path.Join(dir, "memory")
+	blkio := path.Join(dir, "blkio")
+	for _, d := range []string{cpuacct, memory, blkio} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The CPU usage can be read.
+	if err := ioutil.WriteFile(path.Join(cpuacct, "cpuacct.usage"), []byte("1234\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The memory usage cannot be read.
+	if err := os.Mkdir(path.Join(memory, "memory.usage_in_bytes"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// The blkio hierarchy does not provide the stats.
+
+	capabilities := ProbeCapabilities("/test", map[string]string{
+		"cpuacct": cpuacct,
+		"memory":  memory,
+		"blkio":   blkio,
+	})
+	if err, ok := capabilities[info.StatsSectionCpu]; !ok || err != nil {
+		t.Errorf("Expected the CPU stats to be readable, got %v (applicable: %v)", err, ok)
+	}
+	if err, ok := capabilities[info.StatsSectionMemory]; !ok || err == nil {
+		t.Errorf("Expected the memory stats to be applicable but unreadable, got %v (applicable: %v)", err, ok)
+	}
+	if err, ok := capabilities[info.StatsSectionDiskIo]; ok {
+		t.Errorf("Expected the disk I/O stats not to be applicable, got %v", err)
+	}
+
+	// Subsystems which are not mounted or do not have the container.
+	capabilities = ProbeCapabilities("/test", map[string]string{
+		"cpuacct": path.Join(dir, "missing"),
+	})
+	if len(capabilities) != 0 {
+		t.Errorf("Expected no applicable sections, got %v", capabilities)
+	}
+}
@@ -22,9 +22,18 @@ import (
 	"github.com/docker/libcontainer/cgroups"
 	cgroupfs "github.com/docker/libcontainer/cgroups/fs"
 	"github.com/docker/libcontainer/network"
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/container"
 	info "github.com/google/cadvisor/info/v1"
+	cgroupsutil "github.com/google/cadvisor/utils/cgroups"
+	"github.com/google/cadvisor/utils/clock"
+	"github.com/google/cadvisor/utils/sysfs"
+	"github.com/google/cadvisor/utils/sysinfo"
 )
 
+// Where the online CPUs are read from when collecting the CPU stats.
+var cpuSysFs, _ = sysfs.NewRealSysFs()
+
 type CgroupSubsystems struct {
 	// Cgroup subsystem mounts.
 	// e.g.: "/sys/fs/cgroup/cpu" -> ["cpu", "cpuacct"]
@@ -38,7 +47,7 @@ type CgroupSubsystems struct {
 // Get information about the cgroup subsystems.
 func GetCgroupSubsystems() (CgroupSubsystems, error) {
 	// Get all cgroup mounts.
-	allCgroups, err := cgroups.GetCgroupMounts()
+	allCgroups, err := cgroupsutil.GetMounts()
 	if err != nil {
 		return CgroupSubsystems{}, err
 	}
@@ -47,15 +56,13 @@ func GetCgroupSubsystems() (CgroupSubsystems, error) {
 	}
 
 	// Trim the mounts to only the subsystems we care about.
-	supportedCgroups := make([]cgroups.Mount, 0, len(allCgroups))
-	mountPoints := make(map[string]string, len(allCgroups))
-	for _, mount := range allCgroups {
-		for _, subsystem := range mount.Subsystems {
-			if _, ok := supportedSubsystems[subsystem]; ok {
-				supportedCgroups = append(supportedCgroups, mount)
-				mountPoints[subsystem] = mount.Mountpoint
-			}
-		}
+	mountPoints, mounts := cgroupsutil.GetMountpoints(allCgroups, supportedSubsystems)
+	supportedCgroups := make([]cgroups.Mount, 0, len(mounts))
+	for _, mount := range mounts {
+		supportedCgroups = append(supportedCgroups, cgroups.Mount{
+			Mountpoint: mount.Mountpoint,
+			Subsystems: mount.Subsystems,
+		})
 	}
 
 	return CgroupSubsystems{
@@ -71,25 +78,109 @@ var supportedSubsystems map[string]struct{} = map[string]struct{}{
 	"memory":  {},
 	"cpuset":  {},
 	"blkio":   {},
+	"pids":    {},
+}
+
+// Reads the stats of a cgroup subsystem.
+type cgroupStatsReader interface {
+	GetStats(path string, stats *cgroups.Stats) error
+}
+
+// Reader of the stats of a cgroup subsystem and the section of the stats it fills.
+type cgroupStatsSource struct {
+	reader  cgroupStatsReader
+	section string
+}
+
+// Sources of the stats of the cgroup subsystems we get stats from.
+var cgroupStatsReaders = map[string]cgroupStatsSource{
+	"cpu":     {&cgroupfs.CpuGroup{}, info.StatsSectionCpu},
+	"cpuacct": {&cgroupfs.CpuacctGroup{}, info.StatsSectionCpu},
+	"memory":  {&cgroupfs.MemoryGroup{}, info.StatsSectionMemory},
+	"blkio":   {&cgroupfs.BlkioGroup{}, info.StatsSectionDiskIo},
 }
 
-// Get stats of the specified container
-func GetStats(cgroupPaths map[string]string, state *libcontainer.State) (*info.ContainerStats, error) {
+// Get stats of the specified container. Each subsystem is read independently
+// so that the failure of one (e.g.: the container exited while it was read)
+// does not lose the others. The returned error lists the failed sections and
+// is never nil, use its OrNil().
+func GetStats(cgroupPaths map[string]string, state *libcontainer.State) (*info.ContainerStats, *container.PartialStatsError) {
 	// TODO(vmarmol): Use libcontainer's Stats() in the new API when that is ready.
-	stats := &libcontainer.ContainerStats{}
+	partial := container.NewPartialStatsError()
+	stats := &libcontainer.ContainerStats{
+		CgroupStats: cgroups.NewStats(),
+	}
+	memoryRead := false
+	cpuacctRead := false
+	for subsystem, path := range cgroupPaths {
+		r, ok := cgroupStatsReaders[subsystem]
+		if !ok || !cgroups.PathExists(path) {
+			continue
+		}
+		if err := r.reader.GetStats(path, stats.CgroupStats); err != nil {
+			partial.Add(r.section, container.NewReadError(err))
+		} else if subsystem == "memory" {
+			memoryRead = true
+		} else if subsystem == "cpuacct" {
+			cpuacctRead = true
+		}
+	}
 
 	var err error
-	stats.CgroupStats, err = cgroupfs.GetStats(cgroupPaths)
+	stats.NetworkStats, err = network.GetStats(&state.NetworkState)
 	if err != nil {
-		return &info.ContainerStats{}, err
+		stats.NetworkStats = nil
+		partial.Add(info.StatsSectionNetwork, err)
 	}
 
-	stats.NetworkStats, err = network.GetStats(&state.NetworkState)
+	ret := toContainerStats(stats)
+	if stats.NetworkStats != nil {
+		ret.Network.Interfaces = []info.InterfaceStats{ret.Network.OfInterface(interfaceName(&state.NetworkState))}
+	}
+	if cpuacctRead {
+		// The per-CPU usage lists every possible CPU, so it is indexed by CPU
+		// id. Which of them were online tells consumers which usage is live.
+		ret.Cpu.Usage.OnlineCpus, err = sysinfo.GetOnlineCpus(cpuSysFs)
+		if err != nil {
+			ret.Cpu.Usage.OnlineCpus = nil
+		}
+	}
+	if memoryRead {
+		ret.Memory.Swap = GetSwapUsage(cgroupPaths["memory"], ret.Memory.Usage)
+	}
+	if blkioRoot, ok := cgroupPaths["blkio"]; ok && cgroups.PathExists(blkioRoot) {
+		if err := GetThrottleStats(blkioRoot, &ret.DiskIo); err != nil {
+			partial.Add(info.StatsSectionDiskIo, container.NewReadError(err))
+		}
+	}
+	if pidsRoot, ok := cgroupPaths["pids"]; ok {
+		ret.Pids, err = GetPids(pidsRoot)
+		if err != nil {
+			partial.Add(info.StatsSectionPids, container.NewReadError(err))
+		}
+	}
+	return ret, partial
+}
+
+// Returns the number of processes in the cgroup, zero if it is empty. Nil if
+// they cannot be listed, e.g.: the cgroup was just removed.
+func CountProcesses(cgroup *cgroups.Cgroup) *uint64 {
+	pids, err := cgroupfs.GetPids(cgroup)
 	if err != nil {
-		return &info.ContainerStats{}, err
+		glog.V(4).Infof("Failed to list the processes of cgroup %q: %v", cgroup.Name, err)
+		return nil
 	}
+	count := uint64(len(pids))
+	return &count
+}
 
-	return toContainerStats(stats), nil
+// Returns the name of the interface the network stats are read from, as seen
+// from inside the container if known.
+func interfaceName(state *network.NetworkState) string {
+	if state.VethChild != "" {
+		return state.VethChild
+	}
+	return state.VethHost
 }
 
 func DiskStatsCopy(blkio_stats []cgroups.BlkioStatEntry) (stat []info.PerDiskStats) {
@@ -133,11 +224,31 @@ func DiskStatsCopy(blkio_stats []cgroups.BlkioStatEntry) (stat []info.PerDiskSta
 	return
 }
 
+// Convert the memory.stat counters with the specified key prefix to info.MemoryStatsMemoryData.
+func toMemoryData(stats map[string]uint64, prefix string) info.MemoryStatsMemoryData {
+	data := info.MemoryStatsMemoryData{
+		Pgfault:      stats[prefix+"pgfault"],
+		Pgmajfault:   stats[prefix+"pgmajfault"],
+		Pgpgin:       stats[prefix+"pgpgin"],
+		Pgpgout:      stats[prefix+"pgpgout"],
+		PgscanDirect: stats[prefix+"pgscan_direct"],
+		PgscanKswapd: stats[prefix+"pgscan_kswapd"],
+	}
+	// Some kernels only break the reclaimed pages down by reclaimer.
+	if pgsteal, ok := stats[prefix+"pgsteal"]; ok {
+		data.Pgsteal = pgsteal
+	} else {
+		data.Pgsteal = stats[prefix+"pgsteal_direct"] + stats[prefix+"pgsteal_kswapd"]
+	}
+	return data
+}
+
 // Convert libcontainer stats to info.ContainerStats.
 func toContainerStats(libcontainerStats *libcontainer.ContainerStats) *info.ContainerStats {
 	s := libcontainerStats.CgroupStats
 	ret := new(info.ContainerStats)
 	ret.Timestamp = time.Now()
+	ret.MonotonicTimestamp = clock.RealClock.Monotonic()
 
 	if s != nil {
 		ret.Cpu.Usage.User = s.CpuStats.CpuUsage.UsageInUsermode
@@ -161,24 +272,31 @@ func toContainerStats(libcontainerStats *libcontainer.ContainerStats) *info.Cont
 		ret.DiskIo.IoTime = DiskStatsCopy(s.BlkioStats.IoTimeRecursive)
 
 		ret.Memory.Usage = s.MemoryStats.Usage
-		if v, ok := s.MemoryStats.Stats["pgfault"]; ok {
-			ret.Memory.ContainerData.Pgfault = v
-			ret.Memory.HierarchicalData.Pgfault = v
-		}
-		if v, ok := s.MemoryStats.Stats["pgmajfault"]; ok {
-			ret.Memory.ContainerData.Pgmajfault = v
-			ret.Memory.HierarchicalData.Pgmajfault = v
-		}
+		// Keys without a prefix are local to the cgroup, those prefixed with total_ include its descendants.
+		ret.Memory.ContainerData = toMemoryData(s.MemoryStats.Stats, "")
+		ret.Memory.HierarchicalData = toMemoryData(s.MemoryStats.Stats, "total_")
 		if v, ok := s.MemoryStats.Stats["total_inactive_anon"]; ok {
-			ret.Memory.WorkingSet = ret.Memory.Usage - v
-			if v, ok := s.MemoryStats.Stats["total_active_file"]; ok {
-				ret.Memory.WorkingSet -= v
+			// With swap accounting, the usage excludes the swapped out pages
+			// the stats may still count, the working set is then clamped at
+			// zero rather than wrapping around.
+			inactive := v + s.MemoryStats.Stats["total_active_file"]
+			if inactive < ret.Memory.Usage {
+				ret.Memory.WorkingSet = ret.Memory.Usage - inactive
 			}
 		}
 	}
-	// TODO(vishh): Perform a deep copy or alias libcontainer network stats.
 	if libcontainerStats.NetworkStats != nil {
-		ret.Network = *(*info.NetworkStats)(libcontainerStats.NetworkStats)
+		n := libcontainerStats.NetworkStats
+		ret.Network = info.NetworkStats{
+			RxBytes:   n.RxBytes,
+			RxPackets: n.RxPackets,
+			RxErrors:  n.RxErrors,
+			RxDropped: n.RxDropped,
+			TxBytes:   n.TxBytes,
+			TxPackets: n.TxPackets,
+			TxErrors:  n.TxErrors,
+			TxDropped: n.TxDropped,
+		}
 	}
 
 	return ret
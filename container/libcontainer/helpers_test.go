@@ -0,0 +1,273 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/libcontainer"
+	"github.com/docker/libcontainer/cgroups"
+	cgroupfs "github.com/docker/libcontainer/cgroups/fs"
+	"github.com/docker/libcontainer/network"
+	"github.com/docker/libcontainer/system"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+func TestToContainerStatsMemoryData(t *testing.T) {
+	stats := &libcontainer.ContainerStats{
+		CgroupStats: &cgroups.Stats{
+			MemoryStats: cgroups.MemoryStats{
+				Usage: 1000,
+				Stats: map[string]uint64{
+					"pgfault":             10,
+					"pgmajfault":          1,
+					"pgpgin":              20,
+					"pgpgout":             5,
+					"total_pgfault":       100,
+					"total_pgmajfault":    11,
+					"total_pgpgin":        200,
+					"total_pgpgout":       50,
+					"pgscan_direct":       30,
+					"pgscan_kswapd":       40,
+					"pgsteal_direct":      25,
+					"pgsteal_kswapd":      35,
+					"total_pgscan_direct": 300,
+					"total_pgscan_kswapd": 400,
+					"total_pgsteal":       650,
+				},
+			},
+		},
+	}
+
+	ret := toContainerStats(stats)
+	expectedContainer := info.MemoryStatsMemoryData{
+		Pgfault:      10,
+		Pgmajfault:   1,
+		Pgpgin:       20,
+		Pgpgout:      5,
+		PgscanDirect: 30,
+		PgscanKswapd: 40,
+		Pgsteal:      60,
+	}
+	if !reflect.DeepEqual(ret.Memory.ContainerData, expectedContainer) {
+		t.Errorf("container memory data is %+v, expected %+v", ret.Memory.ContainerData, expectedContainer)
+	}
+	expectedHierarchical := info.MemoryStatsMemoryData{
+		Pgfault:      100,
+		Pgmajfault:   11,
+		Pgpgin:       200,
+		Pgpgout:      50,
+		PgscanDirect: 300,
+		PgscanKswapd: 400,
+		Pgsteal:      650,
+	}
+	if !reflect.DeepEqual(ret.Memory.HierarchicalData, expectedHierarchical) {
+		t.Errorf("hierarchical memory data is %+v, expected %+v", ret.Memory.HierarchicalData, expectedHierarchical)
+	}
+}
+
+func TestToContainerStatsWorkingSet(t *testing.T) {
+	stats := &libcontainer.ContainerStats{CgroupStats: cgroups.NewStats()}
+	stats.CgroupStats.MemoryStats.Usage = 100 << 20
+	stats.CgroupStats.MemoryStats.Stats = map[string]uint64{
+		"total_inactive_anon": 30 << 20,
+		"total_active_file":   20 << 20,
+	}
+	if ws := toContainerStats(stats).Memory.WorkingSet; ws != 50<<20 {
+		t.Errorf("working set is %d, expected %d", ws, 50<<20)
+	}
+
+	// A heavily swapped container has more inactive pages than its usage,
+	// which excludes the swapped ones.
+	stats.CgroupStats.MemoryStats.Stats["total_inactive_anon"] = 150 << 20
+	if ws := toContainerStats(stats).Memory.WorkingSet; ws != 0 {
+		t.Errorf("working set is %d, expected it clamped at 0", ws)
+	}
+}
+
+// Reads the user and system ticks from the cpuacct.stat fixture.
+func readCpuacctStatTicks(t *testing.T) (uint64, uint64) {
+	contents, err := ioutil.ReadFile("testdata/cpuacct/cpuacct.stat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := strings.Fields(string(contents))
+	if len(fields) != 4 {
+		t.Fatalf("Malformed cpuacct.stat fixture %q", contents)
+	}
+	user, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	system, err := strconv.ParseUint(fields[3], 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user, system
+}
+
+func TestToContainerStatsCpuUsageUnits(t *testing.T) {
+	// The ticks of cpuacct.stat are converted to nanoseconds by libcontainer,
+	// the unit of cpuacct.usage.
+	cgroupStats, err := cgroupfs.GetStats(map[string]string{"cpuacct": "testdata/cpuacct"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage := toContainerStats(&libcontainer.ContainerStats{CgroupStats: cgroupStats}).Cpu.Usage
+
+	userTicks, systemTicks := readCpuacctStatTicks(t)
+	nanosecondsPerTick := uint64(time.Second) / uint64(system.GetClockTicks())
+	expected := info.CpuUsage{
+		Total:  4012345678,
+		PerCpu: []uint64{2500000000, 1512345678},
+		User:   userTicks * nanosecondsPerTick,
+		System: systemTicks * nanosecondsPerTick,
+	}
+	if !reflect.DeepEqual(usage, expected) {
+		t.Errorf("CPU usage is %+v, expected %+v", usage, expected)
+	}
+}
+
+type failingStatsReader struct{}
+
+func (failingStatsReader) GetStats(path string, stats *cgroups.Stats) error {
+	return fmt.Errorf("failed to read %q", path)
+}
+
+func TestGetStatsKeepsSectionsThatWereRead(t *testing.T) {
+	defer func(readers map[string]cgroupStatsSource) {
+		cgroupStatsReaders = readers
+	}(cgroupStatsReaders)
+	cgroupStatsReaders = map[string]cgroupStatsSource{
+		"cpuacct": cgroupStatsReaders["cpuacct"],
+		"memory":  {failingStatsReader{}, info.StatsSectionMemory},
+	}
+
+	cgroupPaths := map[string]string{
+		"cpuacct": "testdata/cpuacct",
+		"memory":  "testdata/memory-swap",
+	}
+	state := &libcontainer.State{
+		NetworkState: network.NetworkState{
+			VethHost: "cadvisor-test-missing-veth",
+		},
+	}
+	stats, partial := GetStats(cgroupPaths, state)
+	if stats == nil {
+		t.Fatal("Expected the stats that were read")
+	}
+	if stats.Cpu.Usage.Total != 4012345678 {
+		t.Errorf("Total CPU usage is %d, expected 4012345678", stats.Cpu.Usage.Total)
+	}
+	expected := []string{info.StatsSectionMemory, info.StatsSectionNetwork}
+	if !reflect.DeepEqual(partial.Sections(), expected) {
+		t.Errorf("Failed sections are %v, expected %v", partial.Sections(), expected)
+	}
+	if partial.OrNil() == nil {
+		t.Error("Expected an error for the failed sections")
+	}
+}
+
+func TestGetStatsWithoutFailures(t *testing.T) {
+	stats, partial := GetStats(map[string]string{"cpuacct": "testdata/cpuacct"}, &libcontainer.State{})
+	if err := partial.OrNil(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if stats.Cpu.Usage.Total != 4012345678 {
+		t.Errorf("Total CPU usage is %d, expected 4012345678", stats.Cpu.Usage.Total)
+	}
+}
+
+func TestGetSwapUsage(t *testing.T) {
+	if swap := GetSwapUsage("testdata/memory-swap", 100<<20); swap != 50<<20 {
+		t.Errorf("swap usage is %d, expected %d", swap, 50<<20)
+	}
+	// The memory usage grew after memory.memsw.usage_in_bytes was read.
+	if swap := GetSwapUsage("testdata/memory-swap", 200<<20); swap != 0 {
+		t.Errorf("swap usage is %d, expected 0", swap)
+	}
+	if swap := GetSwapUsage("testdata/memory-noswap", 100<<20); swap != 0 {
+		t.Errorf("swap usage without swap accounting is %d, expected 0", swap)
+	}
+}
+
+func TestParseOomControl(t *testing.T) {
+	contents, err := ioutil.ReadFile("testdata/memory-noswap/memory.oom_control")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oomControl, err := parseOomControl(string(contents))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]uint64{
+		"oom_kill_disable": 1,
+		"under_oom":        1,
+		"oom_kill":         3,
+	}
+	if !reflect.DeepEqual(oomControl, expected) {
+		t.Errorf("Expected %v, got %v", expected, oomControl)
+	}
+}
+
+func TestParseOomControlMalformed(t *testing.T) {
+	for _, contents := range []string{"oom_kill_disable\n", "oom_kill_disable yes\n", "oom_kill_disable 0 1\n"} {
+		if _, err := parseOomControl(contents); err == nil {
+			t.Errorf("Expected an error parsing %q", contents)
+		}
+	}
+}
+
+func TestGetMemorySpec(t *testing.T) {
+	var spec info.MemorySpec
+	GetMemorySpec("testdata/memory-swap", &spec)
+	if spec.SwapLimit == nil || *spec.SwapLimit != 2<<30 {
+		t.Errorf("Expected a swap limit of %d, got %v", 2<<30, spec.SwapLimit)
+	}
+	if spec.Swappiness == nil || *spec.Swappiness != 60 {
+		t.Errorf("Expected a swappiness of 60, got %v", spec.Swappiness)
+	}
+	if spec.OomKillDisable == nil || *spec.OomKillDisable {
+		t.Errorf("Expected the OOM killer to be enabled, got %v", spec.OomKillDisable)
+	}
+}
+
+func TestGetMemorySpecWithoutSwapAccounting(t *testing.T) {
+	var spec info.MemorySpec
+	GetMemorySpec("testdata/memory-noswap", &spec)
+	if spec.SwapLimit != nil {
+		t.Errorf("Expected no swap limit, got %d", *spec.SwapLimit)
+	}
+	if spec.Swappiness == nil || *spec.Swappiness != 0 {
+		t.Errorf("Expected a swappiness of 0, got %v", spec.Swappiness)
+	}
+	if spec.OomKillDisable == nil || !*spec.OomKillDisable {
+		t.Errorf("Expected the OOM killer to be disabled, got %v", spec.OomKillDisable)
+	}
+}
+
+func TestGetMemorySpecMissingCgroup(t *testing.T) {
+	var spec info.MemorySpec
+	GetMemorySpec("testdata/does-not-exist", &spec)
+	if !reflect.DeepEqual(spec, info.MemorySpec{}) {
+		t.Errorf("Expected an empty spec, got %+v", spec)
+	}
+}
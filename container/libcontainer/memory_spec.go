@@ -0,0 +1,115 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Fills the swap limit, swappiness and OOM killer setting of the spec from
+// the memory cgroup at memoryRoot. Settings whose file does not exist (e.g.:
+// memory.memsw.limit_in_bytes without swap accounting) are left nil.
+func GetMemorySpec(memoryRoot string, spec *info.MemorySpec) {
+	if swapLimit, ok := readUint64(memoryRoot, "memory.memsw.limit_in_bytes"); ok {
+		spec.SwapLimit = &swapLimit
+	}
+	if swappiness, ok := readUint64(memoryRoot, "memory.swappiness"); ok {
+		spec.Swappiness = &swappiness
+	}
+
+	oomControlFile := path.Join(memoryRoot, "memory.oom_control")
+	out, err := ioutil.ReadFile(oomControlFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			glog.Errorf("Failed to read %q: %v", oomControlFile, err)
+		}
+		return
+	}
+	oomControl, err := parseOomControl(string(out))
+	if err != nil {
+		glog.Errorf("Failed to parse %q: %v", oomControlFile, err)
+		return
+	}
+	if disabled, ok := oomControl["oom_kill_disable"]; ok {
+		oomKillDisable := disabled != 0
+		spec.OomKillDisable = &oomKillDisable
+	}
+}
+
+// Returns the swap used by the memory cgroup at memoryRoot, whose memory usage
+// is specified: memory.memsw.usage_in_bytes counts both memory and swap. Zero
+// without swap accounting.
+func GetSwapUsage(memoryRoot string, usage uint64) uint64 {
+	memswUsage, ok := readUint64(memoryRoot, "memory.memsw.usage_in_bytes")
+	// The files are not read atomically, the memory usage may have grown since.
+	if !ok || memswUsage < usage {
+		return 0
+	}
+	return memswUsage - usage
+}
+
+// Reads the single number in the specified cgroup file. Returns false if the
+// file does not exist or cannot be parsed.
+func readUint64(dirpath, file string) (uint64, bool) {
+	cgroupFile := path.Join(dirpath, file)
+	out, err := ioutil.ReadFile(cgroupFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			glog.Errorf("Failed to read %q: %v", cgroupFile, err)
+		}
+		return 0, false
+	}
+	val, err := strconv.ParseUint(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		glog.Errorf("Failed to parse %q: %v", cgroupFile, err)
+		return 0, false
+	}
+	return val, true
+}
+
+// Parses the contents of memory.oom_control, which has a key and a value per
+// line (e.g.: "oom_kill_disable 0").
+func parseOomControl(contents string) (map[string]uint64, error) {
+	ret := make(map[string]uint64)
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("malformed line %q", line)
+		}
+		val, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed value in line %q: %v", line, err)
+		}
+		ret[fields[0]] = val
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
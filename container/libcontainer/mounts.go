@@ -0,0 +1,101 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"path"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// The cgroup subsystems of the machine, shared by the factories and their
+// handlers. Re-resolved when the cgroup hierarchies are remounted (e.g.: by a
+// configuration management tool, or when the Docker daemon restarts with
+// another cgroup driver).
+type CgroupMounts struct {
+	// Resolves the current cgroup subsystems.
+	resolve func() (CgroupSubsystems, error)
+
+	// The cgroup subsystems as last resolved, protected by lock. Replaced
+	// rather than modified.
+	subsystems CgroupSubsystems
+	lock       sync.RWMutex
+}
+
+// Resolves the cgroup subsystems with the specified function (e.g.:
+// GetCgroupSubsystems).
+func NewCgroupMounts(resolve func() (CgroupSubsystems, error)) (*CgroupMounts, error) {
+	subsystems, err := resolve()
+	if err != nil {
+		return nil, err
+	}
+	return &CgroupMounts{
+		resolve:    resolve,
+		subsystems: subsystems,
+	}, nil
+}
+
+// Returns the cgroup subsystems as last resolved. They must not be modified.
+func (self *CgroupMounts) Subsystems() CgroupSubsystems {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.subsystems
+}
+
+// Returns the paths of the cgroup hierarchies of the specified container, by
+// subsystem (e.g.: "cpu" -> "/sys/fs/cgroup/cpu/test").
+func (self *CgroupMounts) CgroupPaths(name string) map[string]string {
+	subsystems := self.Subsystems()
+	cgroupPaths := make(map[string]string, len(subsystems.MountPoints))
+	for key, val := range subsystems.MountPoints {
+		cgroupPaths[key] = path.Join(val, name)
+	}
+	return cgroupPaths
+}
+
+// Re-resolves the cgroup subsystems. Returns the sorted subsystems whose mount
+// point changed, were mounted, or were unmounted since they were last resolved.
+func (self *CgroupMounts) Refresh() ([]string, error) {
+	subsystems, err := self.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	changed := changedMountPoints(self.subsystems.MountPoints, subsystems.MountPoints)
+	if len(changed) != 0 || !reflect.DeepEqual(self.subsystems.Mounts, subsystems.Mounts) {
+		self.subsystems = subsystems
+	}
+	return changed, nil
+}
+
+// Returns the sorted subsystems whose mount point differs between the two.
+func changedMountPoints(previous, current map[string]string) []string {
+	var changed []string
+	for subsystem, mountPoint := range previous {
+		if current[subsystem] != mountPoint {
+			changed = append(changed, subsystem)
+		}
+	}
+	for subsystem := range current {
+		if _, ok := previous[subsystem]; !ok {
+			changed = append(changed, subsystem)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
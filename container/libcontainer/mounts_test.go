@@ -0,0 +1,102 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/libcontainer/cgroups"
+)
+
+// Resolver of the cgroup subsystems which returns whatever is set as mounted.
+type fakeMountTable struct {
+	mountPoints map[string]string
+}
+
+func (self *fakeMountTable) resolve() (CgroupSubsystems, error) {
+	subsystems := CgroupSubsystems{
+		MountPoints: make(map[string]string, len(self.mountPoints)),
+	}
+	for subsystem, mountPoint := range self.mountPoints {
+		subsystems.MountPoints[subsystem] = mountPoint
+		subsystems.Mounts = append(subsystems.Mounts, cgroups.Mount{
+			Mountpoint: mountPoint,
+			Subsystems: []string{subsystem},
+		})
+	}
+	return subsystems, nil
+}
+
+func TestCgroupMountsRefresh(t *testing.T) {
+	table := &fakeMountTable{
+		mountPoints: map[string]string{
+			"cpu":    "/sys/fs/cgroup/cpu",
+			"memory": "/sys/fs/cgroup/memory",
+		},
+	}
+	mounts, err := NewCgroupMounts(table.resolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"cpu":    "/sys/fs/cgroup/cpu/test",
+		"memory": "/sys/fs/cgroup/memory/test",
+	}
+	if paths := mounts.CgroupPaths("/test"); !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected paths %v, got %v", expected, paths)
+	}
+
+	changed, err := mounts.Refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 0 {
+		t.Errorf("Expected no changes without a remount, got %v", changed)
+	}
+
+	table.mountPoints = map[string]string{
+		"cpu":    "/sys/fs/cgroup/cpu",
+		"memory": "/cgroup/memory",
+		"pids":   "/cgroup/pids",
+	}
+	changed, err = mounts.Refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(changed, []string{"memory", "pids"}) {
+		t.Errorf("Expected memory and pids to change, got %v", changed)
+	}
+	expected = map[string]string{
+		"cpu":    "/sys/fs/cgroup/cpu/test",
+		"memory": "/cgroup/memory/test",
+		"pids":   "/cgroup/pids/test",
+	}
+	if paths := mounts.CgroupPaths("/test"); !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected paths %v after the remount, got %v", expected, paths)
+	}
+
+	delete(table.mountPoints, "pids")
+	changed, err = mounts.Refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(changed, []string{"pids"}) {
+		t.Errorf("Expected pids to be unmounted, got %v", changed)
+	}
+	if _, ok := mounts.Subsystems().MountPoints["pids"]; ok {
+		t.Errorf("Expected pids to no longer be mounted")
+	}
+}
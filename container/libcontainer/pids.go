@@ -0,0 +1,107 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Value of pids.max when the number of processes is not limited.
+const pidsUnlimited = "max"
+
+// Fills in the pids limit of the container from its pids cgroup. Containers
+// without a pids.current (e.g.: the root of the hierarchy) are not in a pids
+// cgroup and are left as they are.
+func GetPidsSpec(pidsRoot string, spec *info.ContainerSpec) {
+	if _, err := os.Stat(path.Join(pidsRoot, "pids.current")); err != nil {
+		return
+	}
+	spec.HasPids = true
+
+	limitFile := path.Join(pidsRoot, "pids.max")
+	out, err := ioutil.ReadFile(limitFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			glog.Errorf("Failed to read %q: %v", limitFile, err)
+		}
+		return
+	}
+	limit, err := parsePidsLimit(string(out))
+	if err != nil {
+		glog.Errorf("Failed to parse %q: %v", limitFile, err)
+		return
+	}
+	spec.PidsLimit = limit
+}
+
+// Parses the contents of pids.max, which is either a number or "max" when
+// there is no limit. Returns nil if there is no limit.
+func parsePidsLimit(contents string) (*uint64, error) {
+	value := strings.TrimSpace(contents)
+	if value == pidsUnlimited {
+		return nil, nil
+	}
+	limit, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &limit, nil
+}
+
+// Gets the number of processes in the pids cgroup of the container and how
+// many times it hit its limit. Returns nil if the container is not in a pids
+// cgroup.
+func GetPids(pidsRoot string) (*info.PidsStats, error) {
+	currentFile := path.Join(pidsRoot, "pids.current")
+	out, err := ioutil.ReadFile(currentFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	current, err := strconv.ParseUint(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %v", currentFile, err)
+	}
+	stats := &info.PidsStats{
+		Current: current,
+	}
+
+	// Kernels older than 4.3 have no pids.events, the limit hits are unknown.
+	eventsFile := path.Join(pidsRoot, "pids.events")
+	out, err = ioutil.ReadFile(eventsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return stats, nil
+		}
+		return nil, err
+	}
+	// Same "key value" lines as memory.oom_control.
+	events, err := parseOomControl(string(out))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %v", eventsFile, err)
+	}
+	stats.LimitHits = events[pidsUnlimited]
+	return stats, nil
+}
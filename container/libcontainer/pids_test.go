@@ -0,0 +1,106 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"reflect"
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+func TestParsePidsLimit(t *testing.T) {
+	limit, err := parsePidsLimit("50\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limit == nil || *limit != 50 {
+		t.Errorf("Expected a limit of 50, got %v", limit)
+	}
+
+	limit, err = parsePidsLimit("max\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limit != nil {
+		t.Errorf("Expected no limit, got %d", *limit)
+	}
+
+	if _, err := parsePidsLimit("-1\n"); err == nil {
+		t.Error("Expected an error parsing a negative limit")
+	}
+}
+
+func TestGetPidsSpec(t *testing.T) {
+	var spec info.ContainerSpec
+	GetPidsSpec("testdata/pids-limited", &spec)
+	if !spec.HasPids {
+		t.Error("Expected the container to have pids")
+	}
+	if spec.PidsLimit == nil || *spec.PidsLimit != 50 {
+		t.Errorf("Expected a pids limit of 50, got %v", spec.PidsLimit)
+	}
+}
+
+func TestGetPidsSpecUnlimited(t *testing.T) {
+	var spec info.ContainerSpec
+	GetPidsSpec("testdata/pids-unlimited", &spec)
+	if !spec.HasPids {
+		t.Error("Expected the container to have pids")
+	}
+	if spec.PidsLimit != nil {
+		t.Errorf("Expected no pids limit, got %d", *spec.PidsLimit)
+	}
+}
+
+func TestGetPidsSpecMissingCgroup(t *testing.T) {
+	var spec info.ContainerSpec
+	GetPidsSpec("testdata/does-not-exist", &spec)
+	if spec.HasPids || spec.PidsLimit != nil {
+		t.Errorf("Expected no pids, got %+v", spec)
+	}
+}
+
+func TestGetPids(t *testing.T) {
+	stats, err := GetPids("testdata/pids-limited")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &info.PidsStats{Current: 12, LimitHits: 3}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("pids are %+v, expected %+v", stats, expected)
+	}
+}
+
+func TestGetPidsWithoutEvents(t *testing.T) {
+	stats, err := GetPids("testdata/pids-unlimited")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &info.PidsStats{Current: 7}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("pids are %+v, expected %+v", stats, expected)
+	}
+}
+
+func TestGetPidsMissingCgroup(t *testing.T) {
+	stats, err := GetPids("testdata/does-not-exist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != nil {
+		t.Errorf("Expected no pids, got %+v", stats)
+	}
+}
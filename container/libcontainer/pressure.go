@@ -0,0 +1,193 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Directory with the pressure stall information of the whole machine.
+var procPressureDir = "/proc/pressure"
+
+// A resource with pressure stall information.
+type pressureResource struct {
+	// Cgroup subsystem whose hierarchy has the pressure file of containers.
+	subsystem string
+
+	// Name of the pressure file in the cgroup of containers.
+	cgroupFile string
+
+	// Name of the pressure file of the machine in procPressureDir.
+	procFile string
+}
+
+var (
+	cpuPressure    = pressureResource{"cpu", "cpu.pressure", "cpu"}
+	memoryPressure = pressureResource{"memory", "memory.pressure", "memory"}
+	ioPressure     = pressureResource{"blkio", "io.pressure", "io"}
+)
+
+// Returns the path of the pressure file of the resource. The root container
+// reads the pressure of the whole machine. Returns false if the container
+// has no cgroup for the resource.
+func (self pressureResource) path(cgroupPaths map[string]string, isRoot bool) (string, bool) {
+	if isRoot {
+		return path.Join(procPressureDir, self.procFile), true
+	}
+	dir, ok := cgroupPaths[self.subsystem]
+	if !ok {
+		return "", false
+	}
+	return path.Join(dir, self.cgroupFile), true
+}
+
+// Returns whether pressure stall information is available for any resource of the container.
+func HasPressure(cgroupPaths map[string]string, isRoot bool) bool {
+	for _, resource := range []pressureResource{cpuPressure, memoryPressure, ioPressure} {
+		file, ok := resource.path(cgroupPaths, isRoot)
+		if !ok {
+			continue
+		}
+		if _, err := os.Stat(file); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Gets the pressure stall information of the container. The root container
+// gets that of the whole machine. Resources without a pressure file (e.g.:
+// on kernels without PSI) are left nil, returns nil if there are none.
+func GetPressure(cgroupPaths map[string]string, isRoot bool) (*info.PressureStats, error) {
+	var err error
+	stats := &info.PressureStats{}
+	stats.Cpu, err = readPressure(cpuPressure, cgroupPaths, isRoot)
+	if err != nil {
+		return nil, err
+	}
+	stats.Memory, err = readPressure(memoryPressure, cgroupPaths, isRoot)
+	if err != nil {
+		return nil, err
+	}
+	stats.Io, err = readPressure(ioPressure, cgroupPaths, isRoot)
+	if err != nil {
+		return nil, err
+	}
+	if stats.Cpu == nil && stats.Memory == nil && stats.Io == nil {
+		return nil, nil
+	}
+	return stats, nil
+}
+
+// Reads the pressure file of the resource. Returns nil if it does not exist.
+func readPressure(resource pressureResource, cgroupPaths map[string]string, isRoot bool) (*info.ResourcePressure, error) {
+	file, ok := resource.path(cgroupPaths, isRoot)
+	if !ok {
+		return nil, nil
+	}
+	out, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	pressure, err := parsePressure(string(out))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %v", file, err)
+	}
+	return pressure, nil
+}
+
+// Parses the contents of a pressure file, which has a "some" line and
+// optionally a "full" line, e.g.:
+// some avg10=0.12 avg60=0.34 avg300=0.56 total=123456
+// Totals are reported in microseconds.
+func parsePressure(contents string) (*info.ResourcePressure, error) {
+	var ret info.ResourcePressure
+	foundSome := false
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		data, err := parsePressureData(fields[1:])
+		if err != nil {
+			return nil, fmt.Errorf("malformed line %q: %v", line, err)
+		}
+		switch fields[0] {
+		case "some":
+			ret.Some = data
+			foundSome = true
+		case "full":
+			ret.Full = &data
+		default:
+			return nil, fmt.Errorf("unknown line %q", line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !foundSome {
+		return nil, fmt.Errorf("missing \"some\" line")
+	}
+	return &ret, nil
+}
+
+// Parses the key=value fields of a pressure line.
+func parsePressureData(fields []string) (info.PressureData, error) {
+	var data info.PressureData
+	found := 0
+	for _, field := range fields {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return data, fmt.Errorf("malformed field %q", field)
+		}
+		var err error
+		switch kv[0] {
+		case "avg10":
+			data.Avg10, err = strconv.ParseFloat(kv[1], 64)
+		case "avg60":
+			data.Avg60, err = strconv.ParseFloat(kv[1], 64)
+		case "avg300":
+			data.Avg300, err = strconv.ParseFloat(kv[1], 64)
+		case "total":
+			var total uint64
+			total, err = strconv.ParseUint(kv[1], 10, 64)
+			data.Total = total * 1000
+		default:
+			// Ignore fields added by newer kernels.
+			continue
+		}
+		if err != nil {
+			return data, fmt.Errorf("malformed field %q: %v", field, err)
+		}
+		found++
+	}
+	if found != 4 {
+		return data, fmt.Errorf("expected avg10, avg60, avg300 and total, found %d of them", found)
+	}
+	return data, nil
+}
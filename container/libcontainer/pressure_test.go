@@ -0,0 +1,138 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"reflect"
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+func TestParsePressureSomeAndFull(t *testing.T) {
+	pressure, err := parsePressure("some avg10=3.25 avg60=2.50 avg300=1.75 total=987654\nfull avg10=0.50 avg60=0.25 avg300=0.05 total=4321\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &info.ResourcePressure{
+		Some: info.PressureData{Avg10: 3.25, Avg60: 2.50, Avg300: 1.75, Total: 987654000},
+		Full: &info.PressureData{Avg10: 0.50, Avg60: 0.25, Avg300: 0.05, Total: 4321000},
+	}
+	if !reflect.DeepEqual(pressure, expected) {
+		t.Errorf("pressure is %+v, expected %+v", pressure, expected)
+	}
+}
+
+func TestParsePressureSomeOnly(t *testing.T) {
+	pressure, err := parsePressure("some avg10=1.50 avg60=0.75 avg300=0.25 total=123456\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &info.ResourcePressure{
+		Some: info.PressureData{Avg10: 1.50, Avg60: 0.75, Avg300: 0.25, Total: 123456000},
+	}
+	if !reflect.DeepEqual(pressure, expected) {
+		t.Errorf("pressure is %+v, expected %+v", pressure, expected)
+	}
+}
+
+func TestParsePressureMalformed(t *testing.T) {
+	for _, contents := range []string{
+		"",
+		"full avg10=0.50 avg60=0.25 avg300=0.05 total=4321\n",
+		"some avg10=abc avg60=0.25 avg300=0.05 total=4321\n",
+		"some avg10=0.50 avg60=0.25 total=4321\n",
+		"some avg10 avg60=0.25 avg300=0.05 total=4321\n",
+		"partial avg10=0.50 avg60=0.25 avg300=0.05 total=4321\n",
+	} {
+		if _, err := parsePressure(contents); err == nil {
+			t.Errorf("expected an error parsing %q", contents)
+		}
+	}
+}
+
+func setProcPressureDir(dir string) func() {
+	old := procPressureDir
+	procPressureDir = dir
+	return func() {
+		procPressureDir = old
+	}
+}
+
+func TestGetPressureOfRoot(t *testing.T) {
+	defer setProcPressureDir("testdata/pressure/proc")()
+
+	if !HasPressure(nil, true) {
+		t.Errorf("expected the root container to have pressure")
+	}
+	pressure, err := GetPressure(nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pressure == nil || pressure.Cpu == nil || pressure.Memory == nil || pressure.Io == nil {
+		t.Fatalf("expected pressure of all resources, got %+v", pressure)
+	}
+	if pressure.Cpu.Full != nil {
+		t.Errorf("expected no full CPU pressure, got %+v", pressure.Cpu.Full)
+	}
+	if pressure.Memory.Full == nil || pressure.Memory.Full.Total != 1000000 {
+		t.Errorf("unexpected full memory pressure %+v", pressure.Memory.Full)
+	}
+}
+
+func TestGetPressureOfContainer(t *testing.T) {
+	cgroupPaths := map[string]string{
+		"cpu":    "testdata/pressure/cgroup/cpu",
+		"memory": "testdata/pressure/cgroup/memory",
+		"blkio":  "testdata/pressure/cgroup/blkio",
+	}
+	if !HasPressure(cgroupPaths, false) {
+		t.Errorf("expected the container to have pressure")
+	}
+	pressure, err := GetPressure(cgroupPaths, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pressure == nil || pressure.Cpu == nil || pressure.Memory == nil {
+		t.Fatalf("expected CPU and memory pressure, got %+v", pressure)
+	}
+	if pressure.Cpu.Some.Avg10 != 3.25 {
+		t.Errorf("CPU pressure is %+v, expected avg10 of 3.25", pressure.Cpu.Some)
+	}
+	// There is no io.pressure.
+	if pressure.Io != nil {
+		t.Errorf("expected no IO pressure, got %+v", pressure.Io)
+	}
+}
+
+func TestGetPressureWithoutPsi(t *testing.T) {
+	defer setProcPressureDir("testdata/pressure/missing")()
+
+	cgroupPaths := map[string]string{
+		"cpu": "testdata/pressure/missing",
+	}
+	for _, isRoot := range []bool{true, false} {
+		if HasPressure(cgroupPaths, isRoot) {
+			t.Errorf("expected no pressure (root: %v)", isRoot)
+		}
+		pressure, err := GetPressure(cgroupPaths, isRoot)
+		if err != nil {
+			t.Errorf("expected no error without pressure files (root: %v), got %v", isRoot, err)
+		}
+		if pressure != nil {
+			t.Errorf("expected nil pressure (root: %v), got %+v", isRoot, pressure)
+		}
+	}
+}
@@ -95,9 +95,18 @@ func (self *MockContainerHandler) GetCgroupPath(path string) (string, error) {
 	return args.Get(0).(string), args.Error(1)
 }
 
+func (self *MockContainerHandler) SetExpensiveCollectorsEnabled(enabled bool) {
+	self.Called(enabled)
+}
+
+// Factory of mock handlers named after their container. The handlers are
+// prepared by PrepareContainerHandlerFunc if set. WrapContainerHandlerFunc, if
+// set, returns the handler created instead, e.g.: to override some of the
+// methods of the mock or to fail the creation.
 type FactoryForMockContainerHandler struct {
 	Name                        string
 	PrepareContainerHandlerFunc func(name string, handler *MockContainerHandler)
+	WrapContainerHandlerFunc    func(name string, handler *MockContainerHandler) (ContainerHandler, error)
 }
 
 func (self *FactoryForMockContainerHandler) String() string {
@@ -105,13 +114,16 @@ func (self *FactoryForMockContainerHandler) String() string {
 }
 
 func (self *FactoryForMockContainerHandler) NewContainerHandler(name string) (ContainerHandler, error) {
-	handler := &MockContainerHandler{}
+	handler := NewMockContainerHandler(name)
 	if self.PrepareContainerHandlerFunc != nil {
 		self.PrepareContainerHandlerFunc(name, handler)
 	}
+	if self.WrapContainerHandlerFunc != nil {
+		return self.WrapContainerHandlerFunc(name, handler)
+	}
 	return handler, nil
 }
 
-func (self *FactoryForMockContainerHandler) CanHandle(name string) bool {
-	return true
+func (self *FactoryForMockContainerHandler) CanHandle(name string) (bool, error) {
+	return true, nil
 }
@@ -29,7 +29,7 @@ type rawFactory struct {
 	machineInfoFactory info.MachineInfoFactory
 
 	// Information about the cgroup subsystems.
-	cgroupSubsystems *libcontainer.CgroupSubsystems
+	cgroupMounts *libcontainer.CgroupMounts
 
 	// Information about mounted filesystems.
 	fsInfo fs.FsInfo
@@ -40,7 +40,7 @@ func (self *rawFactory) String() string {
 }
 
 func (self *rawFactory) NewContainerHandler(name string) (container.ContainerHandler, error) {
-	return newRawContainerHandler(name, self.cgroupSubsystems, self.machineInfoFactory, self.fsInfo)
+	return newRawContainerHandler(name, self.cgroupMounts, self.machineInfoFactory, self.fsInfo)
 }
 
 // The raw factory can handle any container.
@@ -48,12 +48,11 @@ func (self *rawFactory) CanHandle(name string) (bool, error) {
 	return true, nil
 }
 
-func Register(machineInfoFactory info.MachineInfoFactory, fsInfo fs.FsInfo) error {
-	cgroupSubsystems, err := libcontainer.GetCgroupSubsystems()
-	if err != nil {
-		return fmt.Errorf("failed to get cgroup subsystems: %v", err)
+func Register(machineInfoFactory info.MachineInfoFactory, fsInfo fs.FsInfo, cgroupMounts *libcontainer.CgroupMounts) error {
+	if cgroupMounts == nil {
+		return fmt.Errorf("failed to get cgroup subsystems")
 	}
-	if len(cgroupSubsystems.Mounts) == 0 {
+	if len(cgroupMounts.Subsystems().Mounts) == 0 {
 		return fmt.Errorf("failed to find supported cgroup mounts for the raw factory")
 	}
 
@@ -61,7 +60,7 @@ func Register(machineInfoFactory info.MachineInfoFactory, fsInfo fs.FsInfo) erro
 	factory := &rawFactory{
 		machineInfoFactory: machineInfoFactory,
 		fsInfo:             fsInfo,
-		cgroupSubsystems:   &cgroupSubsystems,
+		cgroupMounts:       cgroupMounts,
 	}
 	container.RegisterContainerHandlerFactory(factory)
 	return nil
@@ -22,6 +22,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"code.google.com/p/go.exp/inotify"
@@ -35,14 +36,18 @@ import (
 	"github.com/google/cadvisor/fs"
 	info "github.com/google/cadvisor/info/v1"
 	"github.com/google/cadvisor/utils"
-	"github.com/google/cadvisor/utils/sysinfo"
+	"github.com/google/cadvisor/utils/diskstats"
+	"github.com/google/cadvisor/utils/hwmon"
+	"github.com/google/cadvisor/utils/netdev"
+	"github.com/google/cadvisor/utils/procfs"
+	"github.com/google/cadvisor/utils/timing"
 )
 
 type rawContainerHandler struct {
 	// Name of the container for this handler.
 	name               string
 	cgroup             *cgroups.Cgroup
-	cgroupSubsystems   *libcontainer.CgroupSubsystems
+	cgroupMounts       *libcontainer.CgroupMounts
 	machineInfoFactory info.MachineInfoFactory
 
 	// Inotify event watcher.
@@ -58,25 +63,37 @@ type rawContainerHandler struct {
 	cgroupWatches map[string]struct{}
 
 	// Absolute path to the cgroup hierarchies of this container.
-	// (e.g.: "cpu" -> "/sys/fs/cgroup/cpu/test")
+	// (e.g.: "cpu" -> "/sys/fs/cgroup/cpu/test"). Protected by pathsLock,
+	// replaced when the hierarchies are remounted.
 	cgroupPaths map[string]string
 
-	// Equivalent libcontainer state for this container.
+	// Equivalent libcontainer state for this container. Its cgroup paths
+	// are protected by pathsLock.
 	libcontainerState dockerlibcontainer.State
 
+	// Sections of the stats which apply to the container, probed from its
+	// cgroup paths. See libcontainer.ProbeCapabilities. Protected by pathsLock.
+	capabilities map[string]error
+
+	pathsLock sync.RWMutex
+
 	// Whether this container has network isolation enabled.
 	hasNetwork bool
 
+	// Collector of the traffic of the machine's interfaces. Only set for root.
+	rootNetwork *netdev.Collector
+
+	// Sensors of the machine, only for root. Nil if hardware monitoring is
+	// disabled or unavailable.
+	hardware *hwmon.Collector
+
 	fsInfo         fs.FsInfo
 	externalMounts []mount
 }
 
-func newRawContainerHandler(name string, cgroupSubsystems *libcontainer.CgroupSubsystems, machineInfoFactory info.MachineInfoFactory, fsInfo fs.FsInfo) (container.ContainerHandler, error) {
+func newRawContainerHandler(name string, cgroupMounts *libcontainer.CgroupMounts, machineInfoFactory info.MachineInfoFactory, fsInfo fs.FsInfo) (container.ContainerHandler, error) {
 	// Create the cgroup paths.
-	cgroupPaths := make(map[string]string, len(cgroupSubsystems.MountPoints))
-	for key, val := range cgroupSubsystems.MountPoints {
-		cgroupPaths[key] = path.Join(val, name)
-	}
+	cgroupPaths := cgroupMounts.CgroupPaths(name)
 
 	cHints, err := getContainerHintsFromFile(*argContainerHints)
 	if err != nil {
@@ -102,21 +119,33 @@ func newRawContainerHandler(name string, cgroupSubsystems *libcontainer.CgroupSu
 		}
 	}
 
+	capabilities := libcontainer.ProbeCapabilities(name, cgroupPaths)
+
+	var rootNetwork *netdev.Collector
+	var hardware *hwmon.Collector
+	if name == "/" {
+		rootNetwork = netdev.NewCollector()
+		hardware = hwmon.NewCollector()
+	}
+
 	return &rawContainerHandler{
 		name: name,
 		cgroup: &cgroups.Cgroup{
 			Parent: "/",
 			Name:   name,
 		},
-		cgroupSubsystems:   cgroupSubsystems,
+		cgroupMounts:       cgroupMounts,
 		machineInfoFactory: machineInfoFactory,
 		stopWatcher:        make(chan error),
 		watches:            make(map[string]struct{}),
 		cgroupWatches:      make(map[string]struct{}),
 		cgroupPaths:        cgroupPaths,
 		libcontainerState:  libcontainerState,
+		capabilities:       capabilities,
 		fsInfo:             fsInfo,
 		hasNetwork:         hasNetwork,
+		rootNetwork:        rootNetwork,
+		hardware:           hardware,
 		externalMounts:     externalMounts,
 	}, nil
 }
@@ -128,6 +157,33 @@ func (self *rawContainerHandler) ContainerReference() (info.ContainerReference,
 	}, nil
 }
 
+// Returns the paths of the cgroup hierarchies of this container. The returned
+// map must not be modified.
+func (self *rawContainerHandler) getCgroupPaths() map[string]string {
+	self.pathsLock.RLock()
+	defer self.pathsLock.RUnlock()
+	return self.cgroupPaths
+}
+
+// Returns the sections of the stats which apply to the container. The
+// returned map must not be modified.
+func (self *rawContainerHandler) getCapabilities() map[string]error {
+	self.pathsLock.RLock()
+	defer self.pathsLock.RUnlock()
+	return self.capabilities
+}
+
+func (self *rawContainerHandler) ResolveCgroupPaths() {
+	cgroupPaths := self.cgroupMounts.CgroupPaths(self.name)
+	capabilities := libcontainer.ProbeCapabilities(self.name, cgroupPaths)
+
+	self.pathsLock.Lock()
+	defer self.pathsLock.Unlock()
+	self.cgroupPaths = cgroupPaths
+	self.libcontainerState.CgroupPaths = cgroupPaths
+	self.capabilities = capabilities
+}
+
 func readString(dirpath string, file string) string {
 	cgroupFile := path.Join(dirpath, file)
 
@@ -160,27 +216,16 @@ func readInt64(dirpath string, file string) uint64 {
 	return val
 }
 
-func (self *rawContainerHandler) GetRootNetworkDevices() ([]info.NetInfo, error) {
-	nd := []info.NetInfo{}
-	if self.name == "/" {
-		mi, err := self.machineInfoFactory.GetMachineInfo()
-		if err != nil {
-			return nd, err
-		}
-		return mi.NetworkDevices, nil
-	}
-	return nd, nil
-}
-
 func (self *rawContainerHandler) GetSpec() (info.ContainerSpec, error) {
 	var spec info.ContainerSpec
+	cgroupPaths := self.getCgroupPaths()
 
 	// The raw driver assumes unified hierarchy containers.
 
 	// Get the lowest creation time from all hierarchies as the container creation time.
 	now := time.Now()
 	lowestTime := now
-	for _, cgroupPath := range self.cgroupPaths {
+	for _, cgroupPath := range cgroupPaths {
 		// The modified time of the cgroup directory is when the container was created.
 		fi, err := os.Stat(cgroupPath)
 		if err == nil && fi.ModTime().Before(lowestTime) {
@@ -197,58 +242,89 @@ func (self *rawContainerHandler) GetSpec() (info.ContainerSpec, error) {
 		return spec, err
 	}
 
+	// Which resources the container has was probed when the handler was
+	// created, their limits are read here.
+	capabilities := self.getCapabilities()
+
 	// CPU.
-	cpuRoot, ok := self.cgroupPaths["cpu"]
+	_, spec.HasCpu = capabilities[info.StatsSectionCpu]
+	cpuRoot, ok := cgroupPaths["cpu"]
 	if ok {
 		if utils.FileExists(cpuRoot) {
-			spec.HasCpu = true
 			spec.Cpu.Limit = readInt64(cpuRoot, "cpu.shares")
+			// The quota is -1 when there is none.
+			if quota, err := strconv.ParseInt(readString(cpuRoot, "cpu.cfs_quota_us"), 10, 64); err == nil && quota > 0 {
+				spec.Cpu.Quota = uint64(quota)
+				spec.Cpu.Period = readInt64(cpuRoot, "cpu.cfs_period_us")
+			}
 		}
 	}
 
 	// Cpu Mask.
 	// This will fail for non-unified hierarchies. We'll return the whole machine mask in that case.
-	cpusetRoot, ok := self.cgroupPaths["cpuset"]
+	cpusetRoot, ok := cgroupPaths["cpuset"]
 	if ok {
 		if utils.FileExists(cpusetRoot) {
-			spec.HasCpu = true
 			mask := readString(cpusetRoot, "cpuset.cpus")
 			spec.Cpu.Mask = utils.FixCpuMask(mask, mi.NumCores)
 		}
 	}
 
 	// Memory.
-	memoryRoot, ok := self.cgroupPaths["memory"]
+	_, spec.HasMemory = capabilities[info.StatsSectionMemory]
+	memoryRoot, ok := cgroupPaths["memory"]
 	if ok {
 		if utils.FileExists(memoryRoot) {
-			spec.HasMemory = true
 			spec.Memory.Limit = readInt64(memoryRoot, "memory.limit_in_bytes")
-			spec.Memory.SwapLimit = readInt64(memoryRoot, "memory.memsw.limit_in_bytes")
+			libcontainer.GetMemorySpec(memoryRoot, &spec.Memory)
 		}
 	}
 
+	// Pids.
+	if pidsRoot, ok := cgroupPaths["pids"]; ok {
+		libcontainer.GetPidsSpec(pidsRoot, &spec)
+	}
+
 	// Fs.
 	if self.name == "/" || self.externalMounts != nil {
 		spec.HasFilesystem = true
 	}
 
-	//Network
-	spec.HasNetwork = self.hasNetwork
+	// Network, of the whole machine for root.
+	spec.HasNetwork = self.hasNetwork || self.rootNetwork != nil
 
 	// DiskIo.
-	if blkioRoot, ok := self.cgroupPaths["blkio"]; ok && utils.FileExists(blkioRoot) {
-		spec.HasDiskIo = true
+	_, spec.HasDiskIo = capabilities[info.StatsSectionDiskIo]
+	if blkioRoot, ok := cgroupPaths["blkio"]; ok {
+		spec.Blkio = libcontainer.GetBlkioSpec(blkioRoot)
 	}
 
-	// Check physical network devices for root container.
-	nd, err := self.GetRootNetworkDevices()
-	if err != nil {
-		return spec, err
+	// Pressure, of the whole machine for root.
+	spec.HasPressure = libcontainer.HasPressure(cgroupPaths, self.name == "/")
+
+	spec.ProcessLimits = self.getProcessLimits()
+	return spec, nil
+}
+
+// Gets the limits of the init process (the lowest PID) of the container. Returns nil if there is none.
+func (self *rawContainerHandler) getProcessLimits() *info.ProcessLimits {
+	pids, err := cgroup_fs.GetPids(self.cgroup)
+	if err != nil || len(pids) == 0 {
+		return nil
 	}
-	if len(nd) != 0 {
-		spec.HasNetwork = true
+	initPid := pids[0]
+	for _, pid := range pids {
+		if pid < initPid {
+			initPid = pid
+		}
 	}
-	return spec, nil
+	limits, err := procfs.GetProcessLimits(initPid)
+	if err != nil {
+		// The process may have exited since we listed it.
+		glog.V(4).Infof("raw driver: Failed to get limits of process %d in container %q: %v", initPid, self.name, err)
+		return nil
+	}
+	return limits
 }
 
 func (self *rawContainerHandler) getFsStats(stats *info.ContainerStats) error {
@@ -311,34 +387,75 @@ func (self *rawContainerHandler) getFsStats(stats *info.ContainerStats) error {
 }
 
 func (self *rawContainerHandler) GetStats() (*info.ContainerStats, error) {
-	stats, err := libcontainer.GetStats(self.cgroupPaths, &self.libcontainerState)
+	return self.GetStatsTimed(timing.NewSections())
+}
+
+func (self *rawContainerHandler) GetStatsTimed(sections *timing.Sections) (*info.ContainerStats, error) {
+	self.pathsLock.RLock()
+	state := self.libcontainerState
+	self.pathsLock.RUnlock()
+
+	endCgroupRead := sections.Time("cgroup read")
+	stats, partial := libcontainer.GetStats(state.CgroupPaths, &state)
+	endCgroupRead()
+
+	endProcessCount := sections.Time("process count")
+	stats.ProcessCount = libcontainer.CountProcesses(self.cgroup)
+	endProcessCount()
+
+	var err error
+	endPressureRead := sections.Time("pressure read")
+	stats.Pressure, err = libcontainer.GetPressure(state.CgroupPaths, self.name == "/")
+	endPressureRead()
 	if err != nil {
-		return stats, err
+		partial.Add(info.StatsSectionPressure, container.NewReadError(err))
 	}
 
+	endFsRead := sections.Time("filesystem read")
 	err = self.getFsStats(stats)
+	endFsRead()
 	if err != nil {
-		return stats, err
+		partial.Add(info.StatsSectionFilesystem, err)
 	}
 
-	// Fill in network stats for root.
-	nd, err := self.GetRootNetworkDevices()
-	if err != nil {
-		return stats, err
+	// Fill in the disk stats of the machine for root.
+	if self.name == "/" {
+		endDiskRead := sections.Time("disk stats read")
+		stats.MachineDisks, err = diskstats.GetMachineDiskStats()
+		endDiskRead()
+		if err != nil {
+			partial.Add(info.StatsSectionMachineDisks, err)
+		}
+
+		endHealthRead := sections.Time("system health read")
+		stats.SystemHealth, err = procfs.GetSystemHealth()
+		endHealthRead()
+		if err != nil {
+			partial.Add(info.StatsSectionSystemHealth, err)
+		}
+	}
+	if self.hardware != nil {
+		endHardwareRead := sections.Time("hardware read")
+		stats.MachineStats, err = self.hardware.GetStats()
+		endHardwareRead()
+		if err != nil {
+			partial.Add(info.StatsSectionMachineStats, err)
+		}
 	}
-	if len(nd) != 0 {
-		// ContainerStats only reports stat for one network device.
-		// TODO(rjnagal): Handle multiple physical network devices.
-		stats.Network, err = sysinfo.GetNetworkStats(nd[0].Name)
+
+	// Fill in the network stats of the machine's interfaces for root.
+	if self.rootNetwork != nil {
+		defer sections.Time("network read")()
+		stats.Network, err = self.rootNetwork.GetStats()
 		if err != nil {
-			return stats, err
+			partial.Add(info.StatsSectionNetwork, err)
 		}
 	}
-	return stats, nil
+	return stats, partial.OrNil()
 }
 
 func (self *rawContainerHandler) GetCgroupPath(resource string) (string, error) {
-	path, ok := self.cgroupPaths[resource]
+	path, ok := self.getCgroupPaths()[resource]
 	if !ok {
 		return "", fmt.Errorf("could not find path for resource %q for container %q\n", resource, self.name)
 	}
@@ -375,12 +492,19 @@ func listDirectories(dirpath string, parent string, recursive bool, output map[s
 }
 
 func (self *rawContainerHandler) ListContainers(listType container.ListType) ([]info.ContainerReference, error) {
+	// The hierarchies may be on filesystems which hang (e.g.: network filesystems).
 	containers := make(map[string]struct{})
-	for _, cgroupPath := range self.cgroupPaths {
-		err := listDirectories(cgroupPath, self.name, listType == container.ListRecursive, containers)
-		if err != nil {
-			return nil, err
+	err := container.RunWithTimeout(fmt.Sprintf("walk of the cgroups of %q", self.name), func() error {
+		for _, cgroupPath := range self.getCgroupPaths() {
+			err := listDirectories(cgroupPath, self.name, listType == container.ListRecursive, containers)
+			if err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Make into container references.
@@ -447,7 +571,7 @@ func (self *rawContainerHandler) processEvent(event *inotify.Event, events chan
 
 	// Derive the container name from the path name.
 	var containerName string
-	for _, mount := range self.cgroupSubsystems.Mounts {
+	for _, mount := range self.cgroupMounts.Subsystems().Mounts {
 		mountLocation := path.Clean(mount.Mountpoint) + "/"
 		if strings.HasPrefix(event.Name, mountLocation) {
 			containerName = event.Name[len(mountLocation)-1:]
@@ -512,7 +636,7 @@ func (self *rawContainerHandler) WatchSubcontainers(events chan container.Subcon
 	}
 
 	// Watch this container (all its cgroups) and all subdirectories.
-	for _, cgroupPath := range self.cgroupPaths {
+	for _, cgroupPath := range self.getCgroupPaths() {
 		err := self.watchDirectory(cgroupPath, self.name)
 		if err != nil {
 			return err
@@ -554,9 +678,13 @@ func (self *rawContainerHandler) StopWatchingSubcontainers() error {
 	return <-self.stopWatcher
 }
 
+func (self *rawContainerHandler) SetExpensiveCollectorsEnabled(enabled bool) {
+	// No-op for the raw driver, none of its collectors are expensive.
+}
+
 func (self *rawContainerHandler) Exists() bool {
 	// If any cgroup exists, the container is still alive.
-	for _, cgroupPath := range self.cgroupPaths {
+	for _, cgroupPath := range self.getCgroupPaths() {
 		if utils.FileExists(cgroupPath) {
 			return true
 		}
@@ -0,0 +1,107 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raw
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/container/libcontainer"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveCgroupPathsAfterRemount(t *testing.T) {
+	dir, err := ioutil.TempDir("", "raw-remount")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	oldMount := path.Join(dir, "old", "memory")
+	newMount := path.Join(dir, "new", "memory")
+	require.Nil(t, os.MkdirAll(path.Join(oldMount, "test"), 0755))
+	require.Nil(t, os.MkdirAll(path.Join(newMount, "test"), 0755))
+
+	mountPoint := oldMount
+	mounts, err := libcontainer.NewCgroupMounts(func() (libcontainer.CgroupSubsystems, error) {
+		return libcontainer.CgroupSubsystems{
+			MountPoints: map[string]string{"memory": mountPoint},
+		}, nil
+	})
+	require.Nil(t, err)
+	handler, err := newRawContainerHandler("/test", mounts, nil, nil)
+	require.Nil(t, err)
+	cgroupPath, err := handler.GetCgroupPath("memory")
+	require.Nil(t, err)
+	assert.Equal(t, path.Join(oldMount, "test"), cgroupPath)
+
+	// The hierarchy is remounted elsewhere.
+	require.Nil(t, os.RemoveAll(path.Join(dir, "old")))
+	assert.False(t, handler.Exists())
+	mountPoint = newMount
+	changed, err := mounts.Refresh()
+	require.Nil(t, err)
+	assert.Equal(t, []string{"memory"}, changed)
+
+	resolver, ok := handler.(container.CgroupPathsResolver)
+	require.True(t, ok)
+	resolver.ResolveCgroupPaths()
+	cgroupPath, err = handler.GetCgroupPath("memory")
+	require.Nil(t, err)
+	assert.Equal(t, path.Join(newMount, "test"), cgroupPath)
+	assert.True(t, handler.Exists())
+}
+
+type fakeMachineInfoFactory struct{}
+
+func (self fakeMachineInfoFactory) GetMachineInfo() (*info.MachineInfo, error) {
+	return &info.MachineInfo{NumCores: 4}, nil
+}
+
+func (self fakeMachineInfoFactory) GetVersionInfo() (*info.VersionInfo, error) {
+	return &info.VersionInfo{}, nil
+}
+
+func TestGetSpecReportsProbedCapabilities(t *testing.T) {
+	dir, err := ioutil.TempDir("", "raw-capabilities")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	mountPoints := map[string]string{
+		"cpuacct": path.Join(dir, "cpuacct"),
+		"memory":  path.Join(dir, "memory"),
+		"blkio":   path.Join(dir, "blkio"),
+	}
+	// The CPU stats are readable, those of memory are not and the container
+	// is not in the blkio hierarchy.
+	require.Nil(t, os.MkdirAll(path.Join(mountPoints["cpuacct"], "test"), 0755))
+	require.Nil(t, ioutil.WriteFile(path.Join(mountPoints["cpuacct"], "test", "cpuacct.usage"), []byte("1234\n"), 0644))
+	require.Nil(t, os.MkdirAll(path.Join(mountPoints["memory"], "test", "memory.usage_in_bytes"), 0755))
+	require.Nil(t, os.MkdirAll(mountPoints["blkio"], 0755))
+
+	mounts, err := libcontainer.NewCgroupMounts(func() (libcontainer.CgroupSubsystems, error) {
+		return libcontainer.CgroupSubsystems{MountPoints: mountPoints}, nil
+	})
+	require.Nil(t, err)
+	handler, err := newRawContainerHandler("/test", mounts, fakeMachineInfoFactory{}, nil)
+	require.Nil(t, err)
+	spec, err := handler.GetSpec()
+	require.Nil(t, err)
+	assert.True(t, spec.HasCpu)
+	// Unreadable stats are reported as failures of a resource the container has.
+	assert.True(t, spec.HasMemory)
+	assert.False(t, spec.HasDiskIo)
+}
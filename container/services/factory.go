@@ -0,0 +1,93 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/container"
+)
+
+type servicesFactory struct {
+	restarts *restartTracker
+}
+
+func (self *servicesFactory) String() string {
+	return "services"
+}
+
+func (self *servicesFactory) NewContainerHandler(name string) (container.ContainerHandler, error) {
+	return newServicesHandler(name, self.restarts)
+}
+
+// The services factory can handle Root and the containers of the known services under it.
+func (self *servicesFactory) CanHandle(name string) (bool, error) {
+	if name == Root {
+		return true, nil
+	}
+	_, ok := getService(name)
+	return ok, nil
+}
+
+// Registers the factory of the containers of the system services. It must be
+// registered before the factories of cgroup containers, which can handle any name.
+func Register() error {
+	if _, err := readBootTime(); err != nil {
+		return fmt.Errorf("failed to read the proc filesystem: %v", err)
+	}
+
+	glog.Infof("Registering system services factory")
+	container.RegisterNamespacedContainerHandlerFactory(&servicesFactory{
+		restarts: newRestartTracker(),
+	}, container.Namespace{
+		Name:       ServicesNamespace,
+		MountPoint: Root,
+	})
+	return nil
+}
+
+// Detects the restarts of the services from the changes of their PID. Shared
+// by all handlers so that restarts are counted even when a service was not
+// running long enough for its container to be destroyed and created again.
+type restartTracker struct {
+	lock sync.Mutex
+
+	// Last PID seen of each service by name.
+	pids map[string]int
+
+	// Number of restarts of each service by name.
+	restarts map[string]uint64
+}
+
+func newRestartTracker() *restartTracker {
+	return &restartTracker{
+		pids:     make(map[string]int),
+		restarts: make(map[string]uint64),
+	}
+}
+
+// Records the current PID of the service and returns how many times it restarted.
+func (self *restartTracker) observe(name string, pid int) uint64 {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if last, ok := self.pids[name]; ok && last != pid {
+		self.restarts[name]++
+		glog.Infof("Service %q restarted: PID changed from %d to %d", name, last, pid)
+	}
+	self.pids[name] = pid
+	return self.restarts[name]
+}
@@ -0,0 +1,207 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Handler for the containers of system services, backed by the proc filesystem.
+package services
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/cadvisor/container"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/utils/procfs"
+)
+
+type servicesHandler struct {
+	// Absolute name of the container.
+	name string
+
+	// Service of the container. Unset for Root, which has none.
+	service service
+
+	// Restarts of the services.
+	restarts *restartTracker
+}
+
+func newServicesHandler(name string, restarts *restartTracker) (container.ContainerHandler, error) {
+	self := &servicesHandler{
+		name:     name,
+		restarts: restarts,
+	}
+	if name != Root {
+		var ok bool
+		self.service, ok = getService(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown system service %q", name)
+		}
+	}
+	return self, nil
+}
+
+func (self *servicesHandler) isRoot() bool {
+	return self.name == Root
+}
+
+// Returns the PID of the service, an error if it is not running.
+func (self *servicesHandler) getPid() (int, error) {
+	pid, err := self.service.findPid()
+	if err != nil {
+		return 0, err
+	}
+	if pid == 0 {
+		return 0, fmt.Errorf("system service %q is not running", self.service.name)
+	}
+	return pid, nil
+}
+
+func (self *servicesHandler) ContainerReference() (info.ContainerReference, error) {
+	if self.isRoot() {
+		return info.ContainerReference{Name: self.name}, nil
+	}
+	return info.ContainerReference{
+		Name:      self.name,
+		Aliases:   []string{self.service.name},
+		Namespace: ServicesNamespace,
+	}, nil
+}
+
+func (self *servicesHandler) GetSpec() (info.ContainerSpec, error) {
+	var spec info.ContainerSpec
+	if self.isRoot() {
+		return spec, nil
+	}
+	pid, err := self.getPid()
+	if err != nil {
+		return spec, err
+	}
+	stat, err := procfs.ReadProcessStat(pid)
+	if err != nil {
+		return spec, err
+	}
+	bootTime, err := readBootTime()
+	if err != nil {
+		return spec, err
+	}
+	spec.CreationTime = bootTime.Add(procfs.JiffiesToDuration(stat.StartTime))
+	spec.HasCpu = true
+	spec.HasMemory = true
+	spec.HasProcess = true
+	return spec, nil
+}
+
+func (self *servicesHandler) GetStats() (*info.ContainerStats, error) {
+	stats := &info.ContainerStats{
+		Timestamp: time.Now(),
+	}
+	if self.isRoot() {
+		return stats, nil
+	}
+	pid, err := self.getPid()
+	if err != nil {
+		return nil, err
+	}
+	stat, err := procfs.ReadProcessStat(pid)
+	if err != nil {
+		return nil, err
+	}
+	stats.Cpu.Usage.User = uint64(procfs.JiffiesToDuration(stat.UserTime))
+	stats.Cpu.Usage.System = uint64(procfs.JiffiesToDuration(stat.SystemTime))
+	stats.Cpu.Usage.Total = stats.Cpu.Usage.User + stats.Cpu.Usage.System
+	stats.Memory.Usage = stat.Rss * uint64(os.Getpagesize())
+	stats.Memory.WorkingSet = stats.Memory.Usage
+
+	// A service is its main process.
+	processCount := uint64(1)
+	stats.ProcessCount = &processCount
+	stats.Process = &info.ProcessStats{
+		Pid:      pid,
+		Restarts: self.restarts.observe(self.service.name, pid),
+	}
+	stats.Process.FdCount, stats.Process.SocketCount, err = countFds(pid)
+	if err != nil {
+		partial := container.NewPartialStatsError()
+		partial.Add(info.StatsSectionProcess, err)
+		return stats, partial
+	}
+	return stats, nil
+}
+
+// Root lists the services which are running, which have no subcontainers.
+func (self *servicesHandler) ListContainers(listType container.ListType) ([]info.ContainerReference, error) {
+	if !self.isRoot() {
+		return nil, nil
+	}
+	var ret []info.ContainerReference
+	for _, s := range knownServices {
+		pid, err := s.findPid()
+		if err != nil {
+			return nil, err
+		}
+		if pid != 0 {
+			ret = append(ret, info.ContainerReference{
+				Name:      Root + "/" + s.name,
+				Aliases:   []string{s.name},
+				Namespace: ServicesNamespace,
+			})
+		}
+	}
+	return ret, nil
+}
+
+func (self *servicesHandler) ListThreads(listType container.ListType) ([]int, error) {
+	// Threads are not reported for services.
+	return nil, nil
+}
+
+func (self *servicesHandler) ListProcesses(listType container.ListType) ([]int, error) {
+	if self.isRoot() {
+		return nil, nil
+	}
+	pid, err := self.service.findPid()
+	if err != nil {
+		return nil, err
+	}
+	// The service stopped since it was listed: it has no processes.
+	if pid == 0 {
+		return []int{}, nil
+	}
+	return []int{pid}, nil
+}
+
+// Services are detected by listing them, there are no events to watch.
+func (self *servicesHandler) WatchSubcontainers(events chan container.SubcontainerEvent) error {
+	return nil
+}
+
+func (self *servicesHandler) StopWatchingSubcontainers() error {
+	return nil
+}
+
+func (self *servicesHandler) GetCgroupPath(resource string) (string, error) {
+	return "", fmt.Errorf("system service containers are not cgroups")
+}
+
+func (self *servicesHandler) Exists() bool {
+	if self.isRoot() {
+		return true
+	}
+	pid, err := self.service.findPid()
+	return err == nil && pid != 0
+}
+
+// Collecting the stats of a process is cheap, there are no expensive collectors.
+func (self *servicesHandler) SetExpensiveCollectorsEnabled(enabled bool) {
+}
@@ -0,0 +1,187 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/container"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/utils/procfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestHandler(t *testing.T, name string) container.ContainerHandler {
+	handler, err := newServicesHandler(name, newRestartTracker())
+	require.Nil(t, err)
+	return handler
+}
+
+func TestServicesFactoryCanHandle(t *testing.T) {
+	factory := &servicesFactory{restarts: newRestartTracker()}
+	for name, expected := range map[string]bool{
+		"/services":            true,
+		"/services/docker":     true,
+		"/services/cadvisor":   true,
+		"/services/unknown":    false,
+		"/services/docker/sub": false,
+		"/docker":              false,
+		"/":                    false,
+	} {
+		canHandle, err := factory.CanHandle(name)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, canHandle, name)
+	}
+}
+
+func TestServicesRootListsRunningServices(t *testing.T) {
+	defer procfs.SetRoot("testdata/proc")()
+	root := newTestHandler(t, Root)
+	subcontainers, err := root.ListContainers(container.ListRecursive)
+	require.Nil(t, err)
+	assert.Equal(t, []info.ContainerReference{
+		{Name: "/services/docker", Aliases: []string{"docker"}, Namespace: ServicesNamespace},
+		{Name: "/services/cadvisor", Aliases: []string{"cadvisor"}, Namespace: ServicesNamespace},
+	}, subcontainers)
+
+	// Without a Docker daemon only cAdvisor is listed.
+	tmp := fakeProcDir(t, map[string]string{"self": "100", "100": "testdata/proc/100", "stat": "testdata/proc/stat"})
+	defer os.RemoveAll(tmp)
+	defer procfs.SetRoot(tmp)()
+	subcontainers, err = root.ListContainers(container.ListSelf)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(subcontainers))
+	assert.Equal(t, "/services/cadvisor", subcontainers[0].Name)
+
+	docker := newTestHandler(t, "/services/docker")
+	assert.False(t, docker.Exists())
+	_, err = docker.GetStats()
+	assert.NotNil(t, err)
+	// A stopped service has no processes, which is not an error.
+	processes, err := docker.ListProcesses(container.ListSelf)
+	require.Nil(t, err)
+	assert.Equal(t, []int{}, processes)
+}
+
+func TestServicesHandlerSpecAndStats(t *testing.T) {
+	defer procfs.SetRoot("testdata/proc")()
+	handler := newTestHandler(t, "/services/docker")
+
+	ref, err := handler.ContainerReference()
+	require.Nil(t, err)
+	assert.Equal(t, info.ContainerReference{Name: "/services/docker", Aliases: []string{"docker"}, Namespace: ServicesNamespace}, ref)
+	assert.True(t, handler.Exists())
+
+	spec, err := handler.GetSpec()
+	require.Nil(t, err)
+	assert.True(t, spec.HasCpu)
+	assert.True(t, spec.HasMemory)
+	assert.True(t, spec.HasProcess)
+	assert.False(t, spec.HasNetwork)
+	assert.Equal(t, time.Unix(1430000000, 0).Add(procfs.JiffiesToDuration(200)), spec.CreationTime)
+
+	stats, err := handler.GetStats()
+	require.Nil(t, err)
+	assert.Equal(t, uint64(procfs.JiffiesToDuration(1000)), stats.Cpu.Usage.User)
+	assert.Equal(t, uint64(procfs.JiffiesToDuration(500)), stats.Cpu.Usage.System)
+	assert.Equal(t, stats.Cpu.Usage.User+stats.Cpu.Usage.System, stats.Cpu.Usage.Total)
+	assert.Equal(t, uint64(20000*os.Getpagesize()), stats.Memory.Usage)
+	assert.Equal(t, stats.Memory.Usage, stats.Memory.WorkingSet)
+	assert.Equal(t, &info.ProcessStats{Pid: 200, FdCount: 5, SocketCount: 3}, stats.Process)
+	require.NotNil(t, stats.ProcessCount)
+	assert.Equal(t, uint64(1), *stats.ProcessCount)
+
+	processes, err := handler.ListProcesses(container.ListSelf)
+	require.Nil(t, err)
+	assert.Equal(t, []int{200}, processes)
+	_, err = handler.GetCgroupPath("cpu")
+	assert.NotNil(t, err)
+}
+
+func TestServicesHandlerWithoutFds(t *testing.T) {
+	tmp := fakeProcDir(t, map[string]string{"self": "100", "stat": "testdata/proc/stat", "100/stat": "testdata/proc/100/stat"})
+	defer os.RemoveAll(tmp)
+	defer procfs.SetRoot(tmp)()
+	handler := newTestHandler(t, "/services/cadvisor")
+
+	stats, err := handler.GetStats()
+	partial, ok := err.(*container.PartialStatsError)
+	require.True(t, ok, "expected a partial stats error, got %v", err)
+	assert.Equal(t, []string{info.StatsSectionProcess}, partial.Sections())
+	assert.True(t, stats.Cpu.Usage.Total > 0)
+	assert.Equal(t, 100, stats.Process.Pid)
+}
+
+func TestServicesHandlerDetectsRestarts(t *testing.T) {
+	tmp := fakeProcDir(t, map[string]string{"self": "100", "100": "testdata/proc/100", "101": "testdata/proc/100", "stat": "testdata/proc/stat"})
+	defer os.RemoveAll(tmp)
+	defer procfs.SetRoot(tmp)()
+	restarts := newRestartTracker()
+	handler, err := newServicesHandler("/services/cadvisor", restarts)
+	require.Nil(t, err)
+
+	stats, err := handler.GetStats()
+	require.Nil(t, err)
+	assert.Equal(t, uint64(0), stats.Process.Restarts)
+
+	// The PID changed.
+	require.Nil(t, os.Remove(filepath.Join(tmp, "self")))
+	require.Nil(t, os.Symlink("101", filepath.Join(tmp, "self")))
+	stats, err = handler.GetStats()
+	require.Nil(t, err)
+	assert.Equal(t, 101, stats.Process.Pid)
+	assert.Equal(t, uint64(1), stats.Process.Restarts)
+
+	// Restarts are kept across handlers of the same service.
+	handler, err = newServicesHandler("/services/cadvisor", restarts)
+	require.Nil(t, err)
+	stats, err = handler.GetStats()
+	require.Nil(t, err)
+	assert.Equal(t, uint64(1), stats.Process.Restarts)
+}
+
+func TestRestartTracker(t *testing.T) {
+	tracker := newRestartTracker()
+	assert.Equal(t, uint64(0), tracker.observe("docker", 200))
+	assert.Equal(t, uint64(0), tracker.observe("docker", 200))
+	assert.Equal(t, uint64(0), tracker.observe("cadvisor", 100))
+	assert.Equal(t, uint64(1), tracker.observe("docker", 201))
+	assert.Equal(t, uint64(2), tracker.observe("docker", 200))
+	assert.Equal(t, uint64(0), tracker.observe("cadvisor", 100))
+}
+
+// Makes a fake proc filesystem in a temporary directory. Entries link to the
+// specified files or directories, "self" links to the specified PID.
+func fakeProcDir(t *testing.T, entries map[string]string) string {
+	dir, err := ioutil.TempDir("", "services")
+	require.Nil(t, err)
+	for name, source := range entries {
+		dest := filepath.Join(dir, name)
+		require.Nil(t, os.MkdirAll(filepath.Dir(dest), 0755))
+		if name == "self" {
+			require.Nil(t, os.Symlink(source, dest))
+			continue
+		}
+		abs, err := filepath.Abs(source)
+		require.Nil(t, err)
+		require.Nil(t, os.Symlink(abs, dest))
+	}
+	return dir
+}
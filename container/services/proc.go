@@ -0,0 +1,103 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/cadvisor/utils/procfs"
+)
+
+// Returns the time at which the machine booted, from /proc/stat.
+func readBootTime() (time.Time, error) {
+	out, err := ioutil.ReadFile(procfs.Path("stat"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "btime" {
+			continue
+		}
+		btime, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("malformed boot time %q: %v", line, err)
+		}
+		return time.Unix(btime, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("boot time not found in %q", procfs.Path("stat"))
+}
+
+// Returns the number of open file descriptors of the specified process and
+// how many of them are sockets.
+func countFds(pid int) (fds uint64, sockets uint64, err error) {
+	fdDir := procfs.ProcessPath(pid, "fd")
+	entries, err := ioutil.ReadDir(fdDir)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, entry := range entries {
+		fds++
+		target, err := os.Readlink(path.Join(fdDir, entry.Name()))
+		if err != nil {
+			// Closed since we listed it.
+			continue
+		}
+		if strings.HasPrefix(target, "socket:") {
+			sockets++
+		}
+	}
+	return fds, sockets, nil
+}
+
+// Returns the command line arguments of the specified process.
+func readCmdline(pid int) ([]string, error) {
+	out, err := ioutil.ReadFile(procfs.ProcessPath(pid, "cmdline"))
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimRight(string(out), "\x00"), "\x00"), nil
+}
+
+// Returns the PIDs of all processes, in increasing order.
+func listPids() ([]int, error) {
+	entries, err := ioutil.ReadDir(procfs.Path())
+	if err != nil {
+		return nil, err
+	}
+	pids := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+	return pids, nil
+}
+
+// Returns whether the specified process exists.
+func processExists(pid int) bool {
+	_, err := os.Stat(procfs.ProcessPath(pid))
+	return err == nil
+}
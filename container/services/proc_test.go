@@ -0,0 +1,84 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/utils/procfs"
+)
+
+func TestReadBootTime(t *testing.T) {
+	defer procfs.SetRoot("testdata/proc")()
+	bootTime, err := readBootTime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bootTime.Equal(time.Unix(1430000000, 0)) {
+		t.Errorf("Boot time is %v, expected %v", bootTime, time.Unix(1430000000, 0))
+	}
+}
+
+func TestCountFds(t *testing.T) {
+	defer procfs.SetRoot("testdata/proc")()
+	fds, sockets, err := countFds(200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fds != 5 || sockets != 3 {
+		t.Errorf("Counted %d fds and %d sockets, expected 5 and 3", fds, sockets)
+	}
+
+	if _, _, err := countFds(999); err == nil {
+		t.Error("Expected an error counting the fds of a process that does not exist")
+	}
+}
+
+func TestListPids(t *testing.T) {
+	defer procfs.SetRoot("testdata/proc")()
+	pids, err := listPids()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pids, []int{100, 150, 200}) {
+		t.Errorf("Listed PIDs %v, expected [100 150 200]", pids)
+	}
+}
+
+func TestFindServices(t *testing.T) {
+	testCases := []struct {
+		procDir  string
+		service  func() (int, error)
+		name     string
+		expected int
+	}{
+		{"testdata/proc", findDockerDaemon, "dockerd", 200},
+		{"testdata/legacy-docker/proc", findDockerDaemon, "docker -d", 310},
+		{"testdata/proc", findSelf, "self", 100},
+		{"testdata", findDockerDaemon, "no Docker daemon", 0},
+	}
+	for _, tc := range testCases {
+		restore := procfs.SetRoot(tc.procDir)
+		pid, err := tc.service()
+		restore()
+		if err != nil {
+			t.Errorf("Failed to find %s: %v", tc.name, err)
+		} else if pid != tc.expected {
+			t.Errorf("Found %s at PID %d, expected %d", tc.name, pid, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package services monitors system services (e.g.: the Docker daemon) as
+// containers made of a single process, under /services.
+package services
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/google/cadvisor/utils/procfs"
+)
+
+// Root of the containers of the system services.
+const Root = "/services"
+
+// Namespace of the aliases of the containers of the system services.
+const ServicesNamespace = "services"
+
+// A system service, monitored as the container Root/<name>.
+type service struct {
+	name string
+
+	// Finds the PID of the process of the service. Returns 0 if it is not running.
+	findPid func() (int, error)
+}
+
+// The monitored system services.
+var knownServices = []service{
+	{
+		name:    "docker",
+		findPid: findDockerDaemon,
+	},
+	{
+		name:    "cadvisor",
+		findPid: findSelf,
+	},
+}
+
+// Returns the service of the specified container, if it is one.
+func getService(name string) (service, bool) {
+	if path.Dir(name) != Root {
+		return service{}, false
+	}
+	for _, s := range knownServices {
+		if s.name == path.Base(name) {
+			return s, true
+		}
+	}
+	return service{}, false
+}
+
+// Returns the PID of the Docker daemon, the oldest process running "dockerd"
+// or "docker -d"/"docker daemon".
+func findDockerDaemon() (int, error) {
+	pids, err := listPids()
+	if err != nil {
+		return 0, err
+	}
+	for _, pid := range pids {
+		stat, err := procfs.ReadProcessStat(pid)
+		if err != nil {
+			// Exited since we listed it.
+			continue
+		}
+		switch stat.Comm {
+		case "dockerd":
+			return pid, nil
+		case "docker":
+			args, err := readCmdline(pid)
+			if err != nil {
+				continue
+			}
+			for _, arg := range args[1:] {
+				if arg == "-d" || arg == "--daemon" || arg == "daemon" {
+					return pid, nil
+				}
+			}
+		}
+	}
+	return 0, nil
+}
+
+// Returns the PID of cAdvisor, as seen through the proc filesystem.
+func findSelf() (int, error) {
+	target, err := os.Readlink(procfs.Path("self"))
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(path.Base(target)))
+	if err != nil {
+		return 0, fmt.Errorf("malformed %q link %q: %v", procfs.Path("self"), target, err)
+	}
+	return pid, nil
+}
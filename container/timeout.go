@@ -0,0 +1,162 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"flag"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var HandlerOpTimeout = flag.Duration("handler_op_timeout", 10*time.Second, "Time after which the operations of container handlers which can hang (e.g.: requests to the Docker daemon, walks of the cgroup directories) are abandoned. Zero disables the timeout")
+var maxAbandonedOps = flag.Int("handler_max_abandoned_ops", 100, "Largest number of timed out handler operations left running in the background. Further operations fail right away until some of them return")
+
+// Error returned for a handler operation that did not complete in time.
+type TimeoutError struct {
+	// The operation, e.g.: inspect of Docker container "abc".
+	Op string
+
+	Timeout time.Duration
+
+	// Whether the operation was not even attempted because too many timed
+	// out operations are still running.
+	TooManyAbandoned bool
+}
+
+func (self *TimeoutError) Error() string {
+	if self.TooManyAbandoned {
+		return fmt.Sprintf("%s not attempted: %d timed out operations are still running", self.Op, *maxAbandonedOps)
+	}
+	return fmt.Sprintf("%s timed out after %v", self.Op, self.Timeout)
+}
+
+// Returns whether the error is a timeout of a handler operation, or partial
+// stats missing a section because of one.
+func IsTimeout(err error) bool {
+	switch e := err.(type) {
+	case *TimeoutError:
+		return true
+	case *PartialStatsError:
+		for _, failure := range e.Failures {
+			if IsTimeout(failure) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Timed out operations which are still running.
+var (
+	abandonedLock sync.Mutex
+	abandonedOps  int
+)
+
+// Returns the number of timed out operations which are still running.
+func AbandonedOps() int {
+	abandonedLock.Lock()
+	defer abandonedLock.Unlock()
+	return abandonedOps
+}
+
+var (
+	timeoutsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "cadvisor",
+		Subsystem: "handler",
+		Name:      "op_timeouts_total",
+		Help:      "Number of operations of the container handlers which timed out or were not attempted because too many are still running.",
+	})
+	abandonedOpsGauge = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "cadvisor",
+		Subsystem: "handler",
+		Name:      "abandoned_ops",
+		Help:      "Number of timed out operations of the container handlers which are still running.",
+	}, func() float64 {
+		return float64(AbandonedOps())
+	})
+)
+
+// The Prometheus metrics of the timeouts of handler operations.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{timeoutsTotal, abandonedOpsGauge}
+}
+
+// Runs the operation, which cannot be cancelled, giving up on it after
+// --handler_op_timeout. The operation is left running in the background until
+// it returns, its results must not be used once it timed out. To not pile up
+// goroutines on a wedged dependency, operations fail right away while
+// --handler_max_abandoned_ops timed out operations are still running.
+func RunWithTimeout(op string, fn func() error) error {
+	timeout := *HandlerOpTimeout
+	if timeout <= 0 {
+		return fn()
+	}
+	abandonedLock.Lock()
+	tooMany := abandonedOps >= *maxAbandonedOps
+	abandonedLock.Unlock()
+	if tooMany {
+		timeoutsTotal.Inc()
+		return &TimeoutError{
+			Op:               op,
+			Timeout:          timeout,
+			TooManyAbandoned: true,
+		}
+	}
+
+	var (
+		lock      sync.Mutex
+		finished  bool
+		abandoned bool
+	)
+	done := make(chan error, 1)
+	go func() {
+		err := fn()
+		lock.Lock()
+		finished = true
+		if abandoned {
+			abandonedLock.Lock()
+			abandonedOps--
+			abandonedLock.Unlock()
+		}
+		lock.Unlock()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if finished {
+		// Returned while timing out.
+		return <-done
+	}
+	abandoned = true
+	abandonedLock.Lock()
+	abandonedOps++
+	abandonedLock.Unlock()
+	timeoutsTotal.Inc()
+	glog.Warningf("Abandoned %s after %v", op, timeout)
+	return &TimeoutError{
+		Op:      op,
+		Timeout: timeout,
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withTimeout(timeout time.Duration, maxAbandoned int) func() {
+	oldTimeout, oldMaxAbandoned := *HandlerOpTimeout, *maxAbandonedOps
+	*HandlerOpTimeout, *maxAbandonedOps = timeout, maxAbandoned
+	return func() {
+		*HandlerOpTimeout, *maxAbandonedOps = oldTimeout, oldMaxAbandoned
+	}
+}
+
+func TestRunWithTimeoutReturnsResult(t *testing.T) {
+	defer withTimeout(time.Second, 10)()
+	assert.Nil(t, RunWithTimeout("op", func() error { return nil }))
+	err := errors.New("failed")
+	assert.Equal(t, err, RunWithTimeout("op", func() error { return err }))
+}
+
+func TestRunWithTimeoutAbandonsHangingOperations(t *testing.T) {
+	defer withTimeout(10*time.Millisecond, 10)()
+	release := make(chan struct{})
+	start := time.Now()
+	err := RunWithTimeout("op", func() error {
+		<-release
+		return nil
+	})
+	assert.True(t, time.Since(start) < time.Second, "should give up after the timeout")
+	require.NotNil(t, err)
+	assert.True(t, IsTimeout(err))
+	assert.Equal(t, 1, AbandonedOps())
+
+	// No longer abandoned once it returns.
+	close(release)
+	for i := 0; i < 100 && AbandonedOps() != 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, 0, AbandonedOps())
+}
+
+func TestRunWithTimeoutCapsAbandonedOperations(t *testing.T) {
+	defer withTimeout(time.Millisecond, 2)()
+	release := make(chan struct{})
+	defer close(release)
+	hang := func() error {
+		<-release
+		return nil
+	}
+	for i := 0; i < 2; i++ {
+		assert.True(t, IsTimeout(RunWithTimeout("op", hang)))
+	}
+
+	// Not even attempted.
+	attempted := false
+	err := RunWithTimeout("op", func() error {
+		attempted = true
+		return nil
+	})
+	require.NotNil(t, err)
+	assert.True(t, err.(*TimeoutError).TooManyAbandoned)
+	assert.False(t, attempted)
+	assert.Equal(t, 2, AbandonedOps())
+}
+
+func TestIsTimeout(t *testing.T) {
+	assert.False(t, IsTimeout(nil))
+	assert.False(t, IsTimeout(errors.New("failed")))
+
+	partial := NewPartialStatsError()
+	partial.Add("memory", errors.New("failed"))
+	assert.False(t, IsTimeout(partial))
+	partial.Add("network", &TimeoutError{Op: "op", Timeout: time.Second})
+	assert.True(t, IsTimeout(partial))
+}
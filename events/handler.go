@@ -16,18 +16,30 @@ package events
 
 import (
 	"errors"
+	"flag"
+	"fmt"
+	"path"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/golang/glog"
 )
 
+var watchBufferSize = flag.Int("event_watch_buffer", 100, "Number of events buffered for each watch of events (e.g.: each connection streaming them). Events are dropped for a watch this far behind")
+var watchMaxDropped = flag.Int("event_watch_max_dropped", 10, "Number of events a watch of events may drop because it fell behind before it is stopped, with a final notice of the events it dropped")
+
 // EventManager is implemented by Events. It provides two ways to monitor
 // events and one way to add events
 type EventManager interface {
 	// Watch checks if events fed to it by the caller of AddEvent satisfy the
-	// request and if so sends the event back to the caller on outChannel
-	WatchEvents(outChannel chan *Event, request *Request) error
+	// request and if so sends the event back to the caller on the returned
+	// EventChannel. StopWatch() must be called once the caller is done.
+	WatchEvents(request *Request) (*EventChannel, error)
+	// Stops the watch with the given id and closes its channel.
+	StopWatch(watchId int)
 	// GetEvents() returns a slice of all events detected that have passed
 	// the *Request object parameters to the caller
 	GetEvents(request *Request) (EventSlice, error)
@@ -43,11 +55,13 @@ type events struct {
 	// eventlist holds the complete set of events found over an
 	// EventManager events instantiation.
 	eventlist EventSlice
-	// the slice of watch pointers allows the EventManager access to channels
+	// the map of watch pointers by id allows the EventManager access to channels
 	// linked to different calls of WatchEvents. When new events are found that
 	// satisfy the request of a given watch object in watchers, the event
 	// is sent over the channel to that caller of WatchEvents
-	watchers []*watch
+	watchers map[int]*watch
+	// id of the next watch
+	nextWatchId int
 	// lock that blocks eventlist from being accessed until a writer releases it
 	eventsLock sync.RWMutex
 	// lock that blocks watchers from being accessed until a writer releases it
@@ -64,11 +78,53 @@ type watch struct {
 	// request specifies all the parameters that events sent through the
 	// channel must satisfy. Specified by the creator of the watch object
 	request *Request
-	// a channel created by the caller through which events satisfying the
-	// request are sent to the caller
+	// the channel through which events satisfying the request are sent to
+	// the caller
+	eventChannel *EventChannel
+}
+
+// The channel of the events of a watch, buffering at most --event_watch_buffer
+// of them. The events are never blocked on: they are dropped while the buffer
+// is full, and once --event_watch_max_dropped were, the watch is stopped and a
+// final event of type TypeEventsDropped is sent before the channel is closed.
+type EventChannel struct {
+	// Number of events dropped, accessed atomically.
+	dropped uint64
+	watchId int
+	// One more than the buffer, for the notice of the dropped events.
 	channel chan *Event
 }
 
+func newEventChannel(watchId int) *EventChannel {
+	return &EventChannel{
+		watchId: watchId,
+		channel: make(chan *Event, *watchBufferSize+1),
+	}
+}
+
+// The events of the watch. Closed once the watch is stopped.
+func (self *EventChannel) GetChannel() <-chan *Event {
+	return self.channel
+}
+
+// The id of the watch, to pass to StopWatch().
+func (self *EventChannel) GetWatchId() int {
+	return self.watchId
+}
+
+// Number of events which satisfied the request of the watch but were dropped
+// because it fell behind.
+func (self *EventChannel) Dropped() uint64 {
+	return atomic.LoadUint64(&self.dropped)
+}
+
+// Data of the TypeEventsDropped notice ending the events of a watch which fell
+// behind.
+type EventsDroppedData struct {
+	// Number of events which satisfied the request but were not sent.
+	Dropped uint64
+}
+
 // typedef of a slice of Event pointers
 type EventSlice []*Event
 
@@ -85,6 +141,9 @@ type Event struct {
 	// the original event object and all of its extraneous data, ex. an
 	// OomInstance
 	EventData EventDataInterface
+	// the API path of the container as it was last known, when it was
+	// deleted since the event. Only set on the events served by the API
+	Tombstone string `json:",omitempty"`
 }
 
 // Request holds a set of parameters by which Event objects may be screened.
@@ -110,6 +169,11 @@ type Request struct {
 	// if IncludeSubcontainers is false, only events occurring in the specific
 	// container, and not the subcontainers, will be returned
 	IncludeSubcontainers bool
+	// if set, only events of the containers whose absolute name matches the
+	// glob pattern (see path.Match, e.g.: "/docker/*") are returned, or of
+	// their subcontainers if IncludeSubcontainers is set. Applies on top of
+	// ContainerName
+	ContainerGlob string
 }
 
 // EventType is an enumerated type which lists the categories under which
@@ -120,8 +184,31 @@ const (
 	TypeOom EventType = iota
 	TypeContainerCreation
 	TypeContainerDeletion
+	TypeCollectionDegraded
+	TypeCollectionRestored
+	TypeTimeJump
+	TypeContainerReattached
+	TypeCollectorRestarted
+	TypeMemoryPressure
+	TypeCgroupRemount
+	TypeCpuHotplug
+	// Sent to a watch which fell behind right before it is stopped, whatever
+	// the types of events requested. Never stored.
+	TypeEventsDropped
+
+	// The number of event types rather than a type, new types go above.
+	numTypes
 )
 
+// Returns all the event types, e.g.: to request the events of any type.
+func AllTypes() []EventType {
+	types := make([]EventType, 0, numTypes)
+	for t := EventType(0); t < numTypes; t++ {
+		types = append(types, t)
+	}
+	return types
+}
+
 // a general interface which populates the Event field EventData. The actual
 // object, such as an OomInstance, is set as an Event's EventData
 type EventDataInterface interface {
@@ -131,7 +218,7 @@ type EventDataInterface interface {
 func NewEventManager() *events {
 	return &events{
 		eventlist: make(EventSlice, 0),
-		watchers:  []*watch{},
+		watchers:  make(map[int]*watch),
 	}
 }
 
@@ -144,10 +231,31 @@ func NewRequest() *Request {
 }
 
 // returns a pointer to an initialized watch object
-func newWatch(request *Request, outChannel chan *Event) *watch {
+func newWatch(request *Request, eventChannel *EventChannel) *watch {
 	return &watch{
-		request: request,
-		channel: outChannel,
+		request:      request,
+		eventChannel: eventChannel,
+	}
+}
+
+// Returns an error if the glob pattern of container names is malformed.
+func ValidateContainerGlob(pattern string) error {
+	if _, err := path.Match(pattern, "/"); err != nil {
+		return fmt.Errorf("invalid container glob %q: %v", pattern, err)
+	}
+	return nil
+}
+
+// Whether the container, or one of its parents if the request includes the
+// subcontainers, matches the glob pattern of the request.
+func matchesContainerGlob(request *Request, containerName string) bool {
+	for name := containerName; ; name = path.Dir(name) {
+		if matched, _ := path.Match(request.ContainerGlob, name); matched {
+			return true
+		}
+		if !request.IncludeSubcontainers || name == "/" || name == "." || name == "" {
+			return false
+		}
 	}
 }
 
@@ -205,6 +313,9 @@ func checkIfEventSatisfiesRequest(request *Request, event *Event) bool {
 	if request.EventType[event.EventType] != true {
 		return false
 	}
+	if request.ContainerGlob != "" && !matchesContainerGlob(request, event.ContainerName) {
+		return false
+	}
 	if request.ContainerName != "" {
 		return checkIfIsSubcontainer(request, event)
 	}
@@ -217,33 +328,66 @@ func checkIfEventSatisfiesRequest(request *Request, event *Event) bool {
 // and StartTime/EndTime are specified in the request object, then only
 // up to the most recent MaxEventsReturned events in that time range are returned.
 func (self *events) GetEvents(request *Request) (EventSlice, error) {
-	returnEventList := EventSlice{}
+	if err := ValidateContainerGlob(request.ContainerGlob); err != nil {
+		return nil, err
+	}
 	self.eventsLock.RLock()
 	defer self.eventsLock.RUnlock()
-	for _, e := range self.eventlist {
+	return FilterEvents(request, self.eventlist), nil
+}
+
+// Returns the events which satisfy the request, sorted chronologically. At
+// most the most recent MaxEventsReturned events are returned. Used by the
+// stores of events other than the EventManager, e.g.: storage drivers.
+func FilterEvents(request *Request, eventList EventSlice) EventSlice {
+	returnEventList := EventSlice{}
+	for _, e := range eventList {
 		if checkIfEventSatisfiesRequest(request, e) {
 			returnEventList = append(returnEventList, e)
 		}
 	}
-	returnEventList = getMaxEventsReturned(request, returnEventList)
-	return returnEventList, nil
+	return getMaxEventsReturned(request, returnEventList)
 }
 
-// method of Events object that maintains an *Event channel passed by the user.
+// method of Events object that returns an *EventChannel to the user.
 // When an event is added by AddEvents that satisfies the parameters in the passed
 // Request object it is fed to the channel. The StartTime and EndTime of the watch
 // request should be uninitialized because the purpose is to watch indefinitely
 // for events that will happen in the future
-func (self *events) WatchEvents(outChannel chan *Event, request *Request) error {
+func (self *events) WatchEvents(request *Request) (*EventChannel, error) {
 	if !request.StartTime.IsZero() || !request.EndTime.IsZero() {
-		return errors.New(
+		return nil, errors.New(
 			"for a call to watch, request.StartTime and request.EndTime must be uninitialized")
 	}
-	newWatcher := newWatch(request, outChannel)
+	if err := ValidateContainerGlob(request.ContainerGlob); err != nil {
+		return nil, err
+	}
 	self.watcherLock.Lock()
 	defer self.watcherLock.Unlock()
-	self.watchers = append(self.watchers, newWatcher)
-	return nil
+	eventChannel := newEventChannel(self.nextWatchId)
+	self.nextWatchId++
+	self.watchers[eventChannel.watchId] = newWatch(request, eventChannel)
+	return eventChannel, nil
+}
+
+// Stops the watch and closes its channel. Stopping a watch which was already
+// stopped, e.g.: because it fell behind, is a no-op.
+func (self *events) StopWatch(watchId int) {
+	self.watcherLock.Lock()
+	defer self.watcherLock.Unlock()
+	watcher, ok := self.watchers[watchId]
+	if !ok {
+		return
+	}
+	delete(self.watchers, watchId)
+	close(watcher.eventChannel.channel)
+}
+
+// Returns the number of watches which were not stopped.
+func (self *events) numWatches() int {
+	self.watcherLock.RLock()
+	defer self.watcherLock.RUnlock()
+	return len(self.watchers)
 }
 
 // helper function to update the event manager's eventlist
@@ -253,17 +397,40 @@ func (self *events) updateEventList(e *Event) {
 	self.eventlist = append(self.eventlist, e)
 }
 
-func (self *events) findValidWatchers(e *Event) []*watch {
-	watchesToSend := make([]*watch, 0)
-	self.watcherLock.RLock()
-	defer self.watcherLock.RUnlock()
-	for _, watcher := range self.watchers {
-		watchRequest := watcher.request
-		if checkIfEventSatisfiesRequest(watchRequest, e) {
-			watchesToSend = append(watchesToSend, watcher)
+// Sends the event to the watches whose request it satisfies, without blocking.
+// A watch whose buffer is full drops the event. Once it dropped too many, it is
+// stopped: it is sent the notice of the dropped events in the slot kept for
+// it, then its channel is closed.
+func (self *events) sendToWatchers(e *Event) {
+	self.watcherLock.Lock()
+	defer self.watcherLock.Unlock()
+	for id, watcher := range self.watchers {
+		if !checkIfEventSatisfiesRequest(watcher.request, e) {
+			continue
+		}
+		eventChannel := watcher.eventChannel
+		// Only this sends to the channel, so its length only decreases until
+		// the event is sent.
+		if len(eventChannel.channel) < cap(eventChannel.channel)-1 {
+			eventChannel.channel <- e
+			continue
 		}
+		dropped := atomic.AddUint64(&eventChannel.dropped, 1)
+		if dropped < uint64(*watchMaxDropped) {
+			continue
+		}
+		glog.Warningf("Stopping watch %d of events which dropped %d events", id, dropped)
+		eventChannel.channel <- &Event{
+			ContainerName: e.ContainerName,
+			Timestamp:     time.Now(),
+			EventType:     TypeEventsDropped,
+			EventData: &EventsDroppedData{
+				Dropped: dropped,
+			},
+		}
+		delete(self.watchers, id)
+		close(eventChannel.channel)
 	}
-	return watchesToSend
 }
 
 // method of Events object that adds the argument Event object to the
@@ -271,9 +438,6 @@ func (self *events) findValidWatchers(e *Event) []*watch {
 // held by the manager if it satisfies the request keys of the channels
 func (self *events) AddEvent(e *Event) error {
 	self.updateEventList(e)
-	watchesToSend := self.findValidWatchers(e)
-	for _, watchObject := range watchesToSend {
-		watchObject.channel <- e
-	}
+	self.sendToWatchers(e)
 	return nil
 }
@@ -15,6 +15,7 @@
 package events
 
 import (
+	"sync"
 	"testing"
 	"time"
 )
@@ -103,34 +104,23 @@ func TestCheckIfIsSubcontainer(t *testing.T) {
 func TestWatchEventsDetectsNewEvents(t *testing.T) {
 	myEventHolder, myRequest, fakeEvent, fakeEvent2 := initializeScenario(t)
 	myRequest.EventType[TypeOom] = true
-	outChannel := make(chan *Event, 10)
-	myEventHolder.WatchEvents(outChannel, myRequest)
+	eventChannel, err := myEventHolder.WatchEvents(myRequest)
+	if err != nil {
+		t.Fatalf("Failed to watch events: %v", err)
+	}
+	defer myEventHolder.StopWatch(eventChannel.GetWatchId())
 
 	myEventHolder.AddEvent(fakeEvent)
 	myEventHolder.AddEvent(fakeEvent2)
 
-	startTime := time.Now()
-	go func() {
-		time.Sleep(5 * time.Second)
-		if time.Since(startTime) > (5 * time.Second) {
-			t.Errorf("Took too long to receive all the events")
-			close(outChannel)
-		}
-	}()
-
-	eventsFound := 0
-	go func() {
-		for event := range outChannel {
-			eventsFound += 1
-			if eventsFound == 1 {
-				ensureProperEventReturned(t, fakeEvent, event)
-			} else if eventsFound == 2 {
-				ensureProperEventReturned(t, fakeEvent2, event)
-				close(outChannel)
-				break
-			}
+	for _, expectedEvent := range []*Event{fakeEvent, fakeEvent2} {
+		select {
+		case event := <-eventChannel.GetChannel():
+			ensureProperEventReturned(t, expectedEvent, event)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Took too long to receive all the events")
 		}
-	}()
+	}
 }
 
 func TestAddEventAddsEventsToEventManager(t *testing.T) {
@@ -188,3 +178,162 @@ func TestGetEventsForNoTypeRequested(t *testing.T) {
 	}
 	checkNumberOfEvents(t, 0, receivedEvents.Len())
 }
+
+func TestContainerGlob(t *testing.T) {
+	myRequest := NewRequest()
+	myRequest.EventType[TypeOom] = true
+	myRequest.ContainerGlob = "/docker/*"
+
+	for name, expected := range map[string]bool{
+		"/docker/abc":     true,
+		"/docker":         false,
+		"/docker/abc/def": false,
+		"/system/abc":     false,
+	} {
+		if checkIfEventSatisfiesRequest(myRequest, makeEvent(time.Now(), name)) != expected {
+			t.Errorf("expected the match of %q against %q to be %v", name, myRequest.ContainerGlob, expected)
+		}
+	}
+
+	// The subcontainers of the matching containers also match.
+	myRequest.IncludeSubcontainers = true
+	if !checkIfEventSatisfiesRequest(myRequest, makeEvent(time.Now(), "/docker/abc/def")) {
+		t.Errorf("expected the subcontainer to match %q", myRequest.ContainerGlob)
+	}
+	if checkIfEventSatisfiesRequest(myRequest, makeEvent(time.Now(), "/docker")) {
+		t.Errorf("expected the parent not to match %q", myRequest.ContainerGlob)
+	}
+
+	// Both the name and the glob apply.
+	myRequest.ContainerName = "/docker/abc"
+	if checkIfEventSatisfiesRequest(myRequest, makeEvent(time.Now(), "/docker/xyz")) {
+		t.Errorf("expected a container other than %q not to match", myRequest.ContainerName)
+	}
+}
+
+func TestInvalidContainerGlob(t *testing.T) {
+	myEventHolder, myRequest, _, _ := initializeScenario(t)
+	myRequest.ContainerGlob = "/docker/["
+	if _, err := myEventHolder.WatchEvents(myRequest); err == nil {
+		t.Errorf("expected watching with glob %q to fail", myRequest.ContainerGlob)
+	}
+	if _, err := myEventHolder.GetEvents(myRequest); err == nil {
+		t.Errorf("expected getting events with glob %q to fail", myRequest.ContainerGlob)
+	}
+}
+
+func TestStopWatch(t *testing.T) {
+	myEventHolder, myRequest, fakeEvent, _ := initializeScenario(t)
+	myRequest.EventType[TypeOom] = true
+	eventChannel, err := myEventHolder.WatchEvents(myRequest)
+	if err != nil {
+		t.Fatalf("Failed to watch events: %v", err)
+	}
+	myEventHolder.StopWatch(eventChannel.GetWatchId())
+	// Stopping twice is a no-op.
+	myEventHolder.StopWatch(eventChannel.GetWatchId())
+
+	myEventHolder.AddEvent(fakeEvent)
+	if _, ok := <-eventChannel.GetChannel(); ok {
+		t.Errorf("expected the channel of the stopped watch to be closed")
+	}
+	checkNumberOfEvents(t, 0, myEventHolder.numWatches())
+}
+
+// Returns the most events buffered by the watches other than the excluded one.
+func (self *events) maxBuffered(excluded int) int {
+	self.watcherLock.RLock()
+	defer self.watcherLock.RUnlock()
+	max := 0
+	for id, watcher := range self.watchers {
+		if n := len(watcher.eventChannel.channel); id != excluded && n > max {
+			max = n
+		}
+	}
+	return max
+}
+
+// Sets the buffer of the watches and the events they may drop, returns a
+// function restoring them.
+func setWatchLimits(bufferSize, maxDropped int) func() {
+	oldBufferSize, oldMaxDropped := *watchBufferSize, *watchMaxDropped
+	*watchBufferSize, *watchMaxDropped = bufferSize, maxDropped
+	return func() {
+		*watchBufferSize, *watchMaxDropped = oldBufferSize, oldMaxDropped
+	}
+}
+
+// A watch which does not keep up drops events and is eventually stopped with
+// a notice, without holding back the dispatch of events to the others.
+func TestSlowWatcherIsIsolated(t *testing.T) {
+	const (
+		numWatchers = 30
+		numEvents   = 200
+		bufferSize  = 5
+		maxDropped  = 3
+	)
+	defer setWatchLimits(bufferSize, maxDropped)()
+	myEventHolder, myRequest, _, _ := initializeScenario(t)
+	myRequest.EventType[TypeOom] = true
+
+	slow, err := myEventHolder.WatchEvents(myRequest)
+	if err != nil {
+		t.Fatalf("Failed to watch events: %v", err)
+	}
+	var wg sync.WaitGroup
+	received := make([]int, numWatchers)
+	watchIds := make([]int, 0, numWatchers)
+	for i := 0; i < numWatchers; i++ {
+		eventChannel, err := myEventHolder.WatchEvents(myRequest)
+		if err != nil {
+			t.Fatalf("Failed to watch events: %v", err)
+		}
+		watchIds = append(watchIds, eventChannel.GetWatchId())
+		wg.Add(1)
+		go func(i int, eventChannel *EventChannel) {
+			defer wg.Done()
+			for event := range eventChannel.GetChannel() {
+				if event.EventType == TypeEventsDropped {
+					t.Errorf("watch %d unexpectedly dropped events", i)
+				}
+				received[i]++
+			}
+		}(i, eventChannel)
+	}
+
+	// Each event waits for the fast watches to take it, so that only the
+	// slow one falls behind.
+	for i := 0; i < numEvents; i++ {
+		myEventHolder.AddEvent(makeEvent(time.Now(), "/"))
+		for j := 0; j < 1000; j++ {
+			if myEventHolder.maxBuffered(slow.GetWatchId()) == 0 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	for _, id := range watchIds {
+		myEventHolder.StopWatch(id)
+	}
+	wg.Wait()
+	for i, n := range received {
+		if n != numEvents {
+			t.Errorf("watch %d received %d events, expected %d", i, n, numEvents)
+		}
+	}
+
+	// The slow watch got its buffer, then the notice.
+	var slowEvents []*Event
+	for event := range slow.GetChannel() {
+		slowEvents = append(slowEvents, event)
+	}
+	checkNumberOfEvents(t, bufferSize+1, len(slowEvents))
+	notice := slowEvents[bufferSize]
+	if notice.EventType != TypeEventsDropped {
+		t.Fatalf("expected the last event to be a notice of the dropped events, got %+v", notice)
+	}
+	if notice.EventData.(*EventsDroppedData).Dropped != maxDropped || slow.Dropped() != maxDropped {
+		t.Errorf("expected %d dropped events, notice has %+v and the channel %d", maxDropped, notice.EventData, slow.Dropped())
+	}
+	checkNumberOfEvents(t, 0, myEventHolder.numWatches())
+}
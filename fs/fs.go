@@ -17,12 +17,6 @@
 // Provides Filesystem Stats
 package fs
 
-/*
- extern int getBytesFree(const char *path, unsigned long long *bytes);
- extern int getBytesTotal(const char *path, unsigned long long *bytes);
-*/
-import "C"
-
 import (
 	"bufio"
 	"fmt"
@@ -34,7 +28,6 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
-	"unsafe"
 
 	"github.com/docker/docker/pkg/mount"
 	"github.com/golang/glog"
@@ -287,18 +280,13 @@ func (self *RealFsInfo) GetDirUsage(dir string) (uint64, error) {
 	return usageInKb * 1024, nil
 }
 
+// Returns the total and free bytes of the filesystem mounted at path. The
+// blocks are counted in fragments, as statvfs(3) does.
 func getVfsStats(path string) (total uint64, free uint64, err error) {
-	_p0, err := syscall.BytePtrFromString(path)
-	if err != nil {
-		return 0, 0, err
-	}
-	res, err := C.getBytesFree((*C.char)(unsafe.Pointer(_p0)), (*_Ctype_ulonglong)(unsafe.Pointer(&free)))
-	if res != 0 {
-		return 0, 0, err
-	}
-	res, err = C.getBytesTotal((*C.char)(unsafe.Pointer(_p0)), (*_Ctype_ulonglong)(unsafe.Pointer(&total)))
-	if res != 0 {
+	var s syscall.Statfs_t
+	if err = syscall.Statfs(path, &s); err != nil {
 		return 0, 0, err
 	}
-	return total, free, nil
+	frsize := uint64(s.Frsize)
+	return frsize * s.Blocks, frsize * s.Bfree, nil
 }
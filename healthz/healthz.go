@@ -15,18 +15,50 @@
 package healthz
 
 import (
+	"flag"
+	"fmt"
 	"net/http"
 
+	"github.com/google/cadvisor/http/instrument"
 	httpMux "github.com/google/cadvisor/http/mux"
 )
 
-func handleHealthz(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("ok"))
+var maxApiErrorRatio = flag.Float64("healthz_max_api_error_ratio", 0, "Fraction of the API requests of the last minute which may fail with a server error (5xx) before /healthz reports cAdvisor as unhealthy, so that it gets restarted. Zero disables the check")
+var minApiRequests = flag.Uint64("healthz_min_api_requests", 10, "Number of API requests needed in the last minute for --healthz_max_api_error_ratio to apply")
+
+// Returns the handler of /healthz, unhealthy while too many of the requests
+// recently served by the recorder failed.
+func newHealthzHandler(recorder *instrument.Recorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := checkApiErrors(recorder); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	}
+}
+
+// Fails if the ratio of the recent requests which failed with a server error
+// exceeds --healthz_max_api_error_ratio.
+func checkApiErrors(recorder *instrument.Recorder) error {
+	if *maxApiErrorRatio <= 0 {
+		return nil
+	}
+	requests, serverErrors := recorder.RecentRequests()
+	if requests == 0 || requests < *minApiRequests {
+		return nil
+	}
+	ratio := float64(serverErrors) / float64(requests)
+	if ratio > *maxApiErrorRatio {
+		return fmt.Errorf("%d of the %d API requests of the last minute failed with a server error, more than the maximum ratio of %v", serverErrors, requests, *maxApiErrorRatio)
+	}
+	return nil
 }
 
-// Register simple HTTP /healthz handler to return "ok".
+// Register simple HTTP /healthz handler to return "ok", or an error when the
+// API is failing.
 func RegisterHandler(mux httpMux.Mux) error {
-	mux.HandleFunc("/healthz", handleHealthz)
+	mux.Handle("/healthz", httpMux.SafeReads(newHealthzHandler(instrument.DefaultRecorder)))
 	return nil
 }
@@ -0,0 +1,83 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package healthz
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/http/instrument"
+	"github.com/google/cadvisor/utils/clock/fakeclock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthzFailsOnApiErrorSpikes(t *testing.T) {
+	defer func(ratio float64, min uint64) {
+		*maxApiErrorRatio, *minApiRequests = ratio, min
+	}(*maxApiErrorRatio, *minApiRequests)
+	*maxApiErrorRatio, *minApiRequests = 0.5, 4
+
+	clock := fakeclock.NewFakeClock(time.Unix(1000, 0))
+	recorder := instrument.NewRecorder(clock)
+	fail := false
+	api := recorder.Handler(func(r *http.Request) string { return "/api/v2.0/stats" }, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, "failed", http.StatusInternalServerError)
+		}
+	}))
+	serveApi := func(n int) {
+		for i := 0; i < n; i++ {
+			r, err := http.NewRequest("GET", "http://localhost/api/v2.0/stats", nil)
+			require.Nil(t, err)
+			api.ServeHTTP(httptest.NewRecorder(), r)
+		}
+	}
+	healthz := func() int {
+		r, err := http.NewRequest("GET", "http://localhost/healthz", nil)
+		require.Nil(t, err)
+		w := httptest.NewRecorder()
+		newHealthzHandler(recorder)(w, r)
+		return w.Code
+	}
+
+	// Too few requests to judge.
+	fail = true
+	serveApi(3)
+	assert.Equal(t, http.StatusOK, healthz())
+
+	// 3 of 5 failed.
+	fail = false
+	serveApi(2)
+	assert.Equal(t, http.StatusServiceUnavailable, healthz())
+
+	// 3 of 6 failed.
+	serveApi(1)
+	assert.Equal(t, http.StatusOK, healthz())
+
+	// The failures of over a minute ago are forgotten.
+	fail = true
+	serveApi(10)
+	assert.Equal(t, http.StatusServiceUnavailable, healthz())
+	clock.Step(time.Minute)
+	assert.Equal(t, http.StatusOK, healthz())
+
+	// Disabled.
+	serveApi(10)
+	*maxApiErrorRatio = 0
+	assert.Equal(t, http.StatusOK, healthz())
+}
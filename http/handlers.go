@@ -17,41 +17,71 @@ package http
 import (
 	"fmt"
 	"net/http"
+	"sync"
 
 	auth "github.com/abbot/go-http-auth"
 	"github.com/golang/glog"
 	"github.com/google/cadvisor/api"
+	"github.com/google/cadvisor/container"
 	"github.com/google/cadvisor/healthz"
+	"github.com/google/cadvisor/http/instrument"
 	httpMux "github.com/google/cadvisor/http/mux"
 	"github.com/google/cadvisor/manager"
 	"github.com/google/cadvisor/metrics"
 	"github.com/google/cadvisor/pages"
 	"github.com/google/cadvisor/pages/static"
+	"github.com/google/cadvisor/storage"
+	"github.com/google/cadvisor/utils/leak"
+	"github.com/google/cadvisor/utils/sem"
 	"github.com/google/cadvisor/validate"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-func RegisterHandlers(mux httpMux.Mux, containerManager manager.Manager, httpAuthFile, httpAuthRealm, httpDigestFile, httpDigestRealm, prometheusEndpoint string) error {
+// Registers the handlers of all the pages and APIs of cAdvisor. When readOnly
+// is set, only the requests marked safe are served, see httpMux.ReadOnly().
+// The debug endpoints are only served when enableDebugEndpoints is set.
+func RegisterHandlers(mux httpMux.Mux, containerManager manager.Manager, httpAuthFile, httpAuthRealm, httpDigestFile, httpDigestRealm, prometheusEndpoint string, readOnly, enableDebugEndpoints bool) error {
+	if readOnly {
+		mux = httpMux.ReadOnly(mux)
+	}
+
 	// Basic health handler.
 	if err := healthz.RegisterHandler(mux); err != nil {
 		return fmt.Errorf("failed to register healthz handler: %s", err)
 	}
 
+	// Leak tracking handler.
+	if enableDebugEndpoints {
+		if err := leak.RegisterHandler(mux); err != nil {
+			return fmt.Errorf("failed to register leak tracking handler: %s", err)
+		}
+	}
+
+	// Storage driver requests handler.
+	if err := storage.RegisterHandler(mux); err != nil {
+		return fmt.Errorf("failed to register storage requests handler: %s", err)
+	}
+
 	// Validation/Debug handler.
-	mux.HandleFunc(validate.ValidatePage, func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle(validate.ValidatePage, httpMux.SafeReads(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		err := validate.HandleRequest(w, containerManager)
 		if err != nil {
 			fmt.Fprintf(w, "%s", err)
 		}
-	})
+	})))
 
 	// Register API handler.
 	if err := api.RegisterHandlers(mux, containerManager); err != nil {
 		return fmt.Errorf("failed to register API handlers: %s", err)
 	}
 
+	// Expvar handler.
+	if enableDebugEndpoints {
+		api.RegisterVars(mux, containerManager)
+	}
+
 	// Redirect / to containers page.
-	mux.Handle("/", http.RedirectHandler(pages.ContainersPage, http.StatusTemporaryRedirect))
+	mux.Handle("/", httpMux.SafeReads(http.RedirectHandler(pages.ContainersPage, http.StatusTemporaryRedirect)))
 
 	var authenticated bool = false
 
@@ -60,7 +90,7 @@ func RegisterHandlers(mux httpMux.Mux, containerManager manager.Manager, httpAut
 		glog.Infof("Using auth file %s", httpAuthFile)
 		secrets := auth.HtpasswdFileProvider(httpAuthFile)
 		authenticator := auth.NewBasicAuthenticator(httpAuthRealm, secrets)
-		mux.HandleFunc(static.StaticResource, authenticator.Wrap(staticHandler))
+		mux.Handle(static.StaticResource, httpMux.SafeReads(authenticator.Wrap(staticHandler)))
 		if err := pages.RegisterHandlersBasic(mux, containerManager, authenticator); err != nil {
 			return fmt.Errorf("failed to register pages auth handlers: %s", err)
 		}
@@ -70,7 +100,7 @@ func RegisterHandlers(mux httpMux.Mux, containerManager manager.Manager, httpAut
 		glog.Infof("Using digest file %s", httpDigestFile)
 		secrets := auth.HtdigestFileProvider(httpDigestFile)
 		authenticator := auth.NewDigestAuthenticator(httpDigestRealm, secrets)
-		mux.HandleFunc(static.StaticResource, authenticator.Wrap(staticHandler))
+		mux.Handle(static.StaticResource, httpMux.SafeReads(authenticator.Wrap(staticHandler)))
 		if err := pages.RegisterHandlersDigest(mux, containerManager, authenticator); err != nil {
 			fmt.Errorf("failed to register pages digest handlers: %s", err)
 		}
@@ -79,19 +109,37 @@ func RegisterHandlers(mux httpMux.Mux, containerManager manager.Manager, httpAut
 
 	// Change handler based on authenticator initalization
 	if !authenticated {
-		mux.HandleFunc(static.StaticResource, staticHandlerNoAuth)
+		mux.Handle(static.StaticResource, httpMux.SafeReads(http.HandlerFunc(staticHandlerNoAuth)))
 		if err := pages.RegisterHandlersBasic(mux, containerManager, nil); err != nil {
 			return fmt.Errorf("failed to register pages handlers: %s", err)
 		}
 	}
 
-	collector := metrics.NewPrometheusCollector(containerManager)
-	prometheus.MustRegister(collector)
-	http.Handle(prometheusEndpoint, prometheus.Handler())
+	registerCollectorsOnce.Do(func() {
+		collector := metrics.NewPrometheusCollector(containerManager)
+		prometheus.MustRegister(collector)
+		for _, storageCollector := range storage.Collectors() {
+			prometheus.MustRegister(storageCollector)
+		}
+		for _, handlerCollector := range container.Collectors() {
+			prometheus.MustRegister(handlerCollector)
+		}
+		for _, requestCollector := range instrument.Collectors() {
+			prometheus.MustRegister(requestCollector)
+		}
+		for _, expensiveCollector := range sem.Collectors() {
+			prometheus.MustRegister(expensiveCollector)
+		}
+	})
+	mux.Handle(prometheusEndpoint, httpMux.SafeReads(prometheus.Handler()))
 
 	return nil
 }
 
+// The Prometheus collectors are global, so only those of the manager of the
+// first call to RegisterHandlers() are registered.
+var registerCollectorsOnce sync.Once
+
 func staticHandlerNoAuth(w http.ResponseWriter, r *http.Request) {
 	err := static.HandleRequest(w, r.URL)
 	if err != nil {
@@ -0,0 +1,170 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Manager with a single container.
+type singleContainerManager struct {
+	manager.Manager
+	cont info.ContainerInfo
+}
+
+func (self *singleContainerManager) GetContainerInfo(name string, query *info.ContainerInfoRequest) (*info.ContainerInfo, error) {
+	if name != self.cont.Name {
+		return nil, fmt.Errorf("unknown container %q", name)
+	}
+	return &self.cont, nil
+}
+
+func (self *singleContainerManager) SubcontainersInfo(containerName string, query *info.ContainerInfoRequest) ([]*info.ContainerInfo, error) {
+	return []*info.ContainerInfo{&self.cont}, nil
+}
+
+// Writes an htpasswd file with the specified user and password.
+func htpasswdFile(t *testing.T, user, password string) (string, func()) {
+	f, err := ioutil.TempFile("", "cadvisor-htpasswd")
+	require.Nil(t, err)
+	defer f.Close()
+	hash := sha1.Sum([]byte(password))
+	_, err = fmt.Fprintf(f, "%s:{SHA}%s\n", user, base64.StdEncoding.EncodeToString(hash[:]))
+	require.Nil(t, err)
+	return f.Name(), func() {
+		os.Remove(f.Name())
+	}
+}
+
+func TestRegisterHandlersRoutes(t *testing.T) {
+	authFile, cleanup := htpasswdFile(t, "admin", "secret")
+	defer cleanup()
+	m := &singleContainerManager{
+		cont: info.ContainerInfo{
+			ContainerReference: info.ContainerReference{Name: "/"},
+			Spec:               info.ContainerSpec{HasCpu: true},
+			Stats:              []*info.ContainerStats{{}},
+		},
+	}
+	mux := http.NewServeMux()
+	require.Nil(t, RegisterHandlers(mux, m, authFile, "cadvisor", "", "", "/metrics", false, false))
+
+	get := func(path string, authenticate bool) *httptest.ResponseRecorder {
+		r, err := http.NewRequest("GET", "http://localhost:8080"+path, nil)
+		require.Nil(t, err)
+		if authenticate {
+			r.SetBasicAuth("admin", "secret")
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	// The pages and their assets require authentication.
+	for _, path := range []string{"/live/", "/live/graphs/", "/static/live.js", "/static/live.css"} {
+		assert.Equal(t, http.StatusUnauthorized, get(path, false).Code, "unauthenticated request to %s", path)
+		w := get(path, true)
+		assert.Equal(t, http.StatusOK, w.Code, "authenticated request to %s", path)
+		assert.NotEqual(t, 0, w.Body.Len(), "empty response to %s", path)
+	}
+
+	// The API is served by the API handler, not by the pages.
+	w := get("/api/v2.0/stats/", true)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(w.Body.String(), "{"), "expected stats, got %s", w.Body.String())
+}
+
+// Records the patterns registered on it.
+type recordingMux struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func (self *recordingMux) Handle(pattern string, handler http.Handler) {
+	self.patterns = append(self.patterns, pattern)
+	self.ServeMux.Handle(pattern, handler)
+}
+
+func (self *recordingMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	self.Handle(pattern, http.HandlerFunc(handler))
+}
+
+func TestRegisterHandlersReadOnly(t *testing.T) {
+	m := &singleContainerManager{
+		cont: info.ContainerInfo{
+			ContainerReference: info.ContainerReference{Name: "/"},
+			Spec:               info.ContainerSpec{HasCpu: true},
+			Stats:              []*info.ContainerStats{{}},
+		},
+	}
+	mux := &recordingMux{ServeMux: http.NewServeMux()}
+	require.Nil(t, RegisterHandlers(mux, m, "", "", "", "", "/metrics", true, false))
+	require.NotEmpty(t, mux.patterns)
+
+	serve := func(method, path string) int {
+		r, err := http.NewRequest(method, "http://localhost:8080"+path, nil)
+		require.Nil(t, err)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// Only the API accepts posts, which are queries.
+	for _, pattern := range mux.patterns {
+		expected := http.StatusMethodNotAllowed
+		if pattern == "/api/" {
+			expected = http.StatusOK
+		}
+		assert.Equal(t, expected, serve("POST", pattern), "POST %s", pattern)
+		assert.Equal(t, http.StatusMethodNotAllowed, serve("DELETE", pattern), "DELETE %s", pattern)
+	}
+	for _, path := range []string{"/", "/healthz", "/live/", "/static/live.js", "/api/v2.0/stats/"} {
+		assert.NotEqual(t, http.StatusMethodNotAllowed, serve("GET", path), "GET %s", path)
+	}
+	assert.Equal(t, http.StatusMethodNotAllowed, serve("GET", "/api/v1.3/containers/?collect=true"))
+}
+
+func TestDebugEndpointsAreOnlyServedWhenEnabled(t *testing.T) {
+	m := &singleContainerManager{
+		cont: info.ContainerInfo{
+			ContainerReference: info.ContainerReference{Name: "/"},
+		},
+	}
+	for _, enabled := range []bool{false, true} {
+		mux := &recordingMux{ServeMux: http.NewServeMux()}
+		require.Nil(t, RegisterHandlers(mux, m, "", "", "", "", "/metrics", false, enabled))
+		registered := make(map[string]bool, len(mux.patterns))
+		for _, pattern := range mux.patterns {
+			registered[pattern] = true
+		}
+		for _, pattern := range []string{"/tracked", "/debug/vars"} {
+			assert.Equal(t, enabled, registered[pattern], "registration of %s with debug endpoints enabled: %v", pattern, enabled)
+		}
+	}
+}
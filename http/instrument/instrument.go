@@ -0,0 +1,259 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package instrument records the requests served by the HTTP endpoints of
+// cAdvisor: their number, status and latency by endpoint.
+package instrument
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/cadvisor/utils/clock"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Upper bounds of the latency buckets, in seconds.
+var latencyBuckets = prometheus.DefBuckets
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cadvisor",
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of HTTP requests served, by endpoint and class of status code (e.g.: 2xx).",
+	}, []string{"endpoint", "code"})
+	requestSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "cadvisor",
+		Subsystem: "http",
+		Name:      "request_seconds",
+		Help:      "Latency of the HTTP requests served, by endpoint, in seconds.",
+		Buckets:   latencyBuckets,
+	}, []string{"endpoint"})
+)
+
+// The Prometheus metrics of the requests served.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{requestsTotal, requestSeconds}
+}
+
+// Window over which the recent requests are counted.
+const recentWindow = time.Minute
+
+// Requests served by an endpoint.
+type EndpointStats struct {
+	// Number of requests, including failed ones.
+	Count uint64 `json:"count"`
+
+	// Number of requests by class of status code (e.g.: "2xx", "5xx").
+	Codes map[string]uint64 `json:"codes"`
+
+	// Total time taken by the requests.
+	Latency time.Duration `json:"latency"`
+
+	// Number of requests which took at most the upper bound of each bucket
+	// (see LatencyBuckets), the buckets are cumulative.
+	LatencyCounts []uint64 `json:"latency_counts"`
+}
+
+// Requests served by all the endpoints of a recorder.
+type Requests struct {
+	// Upper bounds of the latency buckets of the LatencyCounts of the endpoints.
+	LatencyBuckets []time.Duration `json:"latency_buckets"`
+
+	// Requests served by endpoint template (e.g.: "/api/v2.0/stats").
+	Endpoints map[string]EndpointStats `json:"endpoints"`
+
+	// Number of requests served and of those that failed with a server error
+	// (5xx) in the last minute, over all endpoints.
+	RecentRequests     uint64 `json:"recent_requests"`
+	RecentServerErrors uint64 `json:"recent_server_errors"`
+}
+
+// Upper bounds of the latency buckets of EndpointStats.LatencyCounts.
+func LatencyBuckets() []time.Duration {
+	ret := make([]time.Duration, len(latencyBuckets))
+	for i, bound := range latencyBuckets {
+		ret[i] = time.Duration(bound * float64(time.Second))
+	}
+	return ret
+}
+
+// Requests served during a second.
+type secondCounts struct {
+	// Second of the monotonic clock the counts are of.
+	second       int64
+	requests     uint64
+	serverErrors uint64
+}
+
+// Records the requests served by the handlers it instruments. Class is thread-safe.
+type Recorder struct {
+	clock clock.Clock
+
+	lock      sync.Mutex
+	endpoints map[string]*EndpointStats
+
+	// Counts of the seconds of the recent window, indexed by second modulo
+	// the length of the window.
+	recent [int(recentWindow / time.Second)]secondCounts
+}
+
+func NewRecorder(clock clock.Clock) *Recorder {
+	return &Recorder{
+		clock:     clock,
+		endpoints: make(map[string]*EndpointStats),
+	}
+}
+
+// Recorder of the requests served by cAdvisor's API.
+var DefaultRecorder = NewRecorder(clock.RealClock)
+
+// Returns the handler, recording the requests it serves. Requests are grouped
+// by the endpoint template endpoint returns for them (e.g.: "/api/v2.0/stats"
+// rather than the full path) so that the number of endpoints stays small.
+func (self *Recorder) Handler(endpoint func(r *http.Request) string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := self.clock.Monotonic()
+		sw := &statusWriter{ResponseWriter: w}
+		handler.ServeHTTP(sw, r)
+		self.record(endpoint(r), sw.status, self.clock.Monotonic()-start)
+	})
+}
+
+// Records a request to the endpoint.
+func (self *Recorder) record(endpoint string, status int, latency time.Duration) {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	code := statusClass(status)
+	requestsTotal.WithLabelValues(endpoint, code).Inc()
+	requestSeconds.WithLabelValues(endpoint).Observe(latency.Seconds())
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	stats, ok := self.endpoints[endpoint]
+	if !ok {
+		stats = &EndpointStats{
+			Codes:         make(map[string]uint64),
+			LatencyCounts: make([]uint64, len(latencyBuckets)),
+		}
+		self.endpoints[endpoint] = stats
+	}
+	stats.Count++
+	stats.Codes[code]++
+	stats.Latency += latency
+	for i, bound := range latencyBuckets {
+		if latency.Seconds() <= bound {
+			stats.LatencyCounts[i]++
+		}
+	}
+
+	counts := self.secondLocked()
+	counts.requests++
+	if status >= 500 {
+		counts.serverErrors++
+	}
+}
+
+// Returns the counts of the current second, reset if they were of an older one.
+func (self *Recorder) secondLocked() *secondCounts {
+	second := int64(self.clock.Monotonic() / time.Second)
+	counts := &self.recent[second%int64(len(self.recent))]
+	if counts.second != second {
+		*counts = secondCounts{second: second}
+	}
+	return counts
+}
+
+// Returns the number of requests served in the last minute and how many of
+// those failed with a server error (5xx).
+func (self *Recorder) RecentRequests() (requests uint64, serverErrors uint64) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	second := int64(self.clock.Monotonic() / time.Second)
+	for _, counts := range self.recent {
+		if second-counts.second < int64(len(self.recent)) {
+			requests += counts.requests
+			serverErrors += counts.serverErrors
+		}
+	}
+	return requests, serverErrors
+}
+
+// Returns the requests served so far.
+func (self *Recorder) Requests() Requests {
+	requests, serverErrors := self.RecentRequests()
+	ret := Requests{
+		LatencyBuckets:     LatencyBuckets(),
+		Endpoints:          make(map[string]EndpointStats),
+		RecentRequests:     requests,
+		RecentServerErrors: serverErrors,
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for endpoint, stats := range self.endpoints {
+		copied := *stats
+		copied.LatencyCounts = append([]uint64(nil), stats.LatencyCounts...)
+		copied.Codes = make(map[string]uint64, len(stats.Codes))
+		for code, count := range stats.Codes {
+			copied.Codes[code] = count
+		}
+		ret.Endpoints[endpoint] = copied
+	}
+	return ret
+}
+
+// Returns the class of the HTTP status code, e.g.: "5xx".
+func statusClass(status int) string {
+	return fmt.Sprintf("%dxx", status/100)
+}
+
+// Remembers the status code written by a handler. Streaming handlers (e.g.:
+// of events) need the Flusher and CloseNotifier of the underlying writer.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (self *statusWriter) WriteHeader(status int) {
+	if self.status == 0 {
+		self.status = status
+	}
+	self.ResponseWriter.WriteHeader(status)
+}
+
+func (self *statusWriter) Write(b []byte) (int, error) {
+	if self.status == 0 {
+		self.status = http.StatusOK
+	}
+	return self.ResponseWriter.Write(b)
+}
+
+func (self *statusWriter) Flush() {
+	if flusher, ok := self.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Never notifies if the underlying writer cannot.
+func (self *statusWriter) CloseNotify() <-chan bool {
+	if notifier, ok := self.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return make(chan bool)
+}
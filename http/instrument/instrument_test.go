@@ -0,0 +1,125 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instrument
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/utils/clock/fakeclock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Handler answering with the status in the "status" query parameter after
+// the latency in the "latency" one, as measured by the clock.
+func syntheticHandler(clock *fakeclock.FakeClock) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		latency, err := time.ParseDuration(r.URL.Query().Get("latency"))
+		if err == nil {
+			clock.Step(latency)
+		}
+		switch r.URL.Query().Get("status") {
+		case "500":
+			http.Error(w, "failed", http.StatusInternalServerError)
+		case "404":
+			http.NotFound(w, r)
+		default:
+			w.Write([]byte("ok"))
+		}
+	})
+}
+
+func serve(t *testing.T, handler http.Handler, url string) int {
+	r, err := http.NewRequest("GET", url, nil)
+	require.Nil(t, err)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestRecorderCountsByEndpoint(t *testing.T) {
+	clock := fakeclock.NewFakeClock(time.Unix(1000, 0))
+	recorder := NewRecorder(clock)
+	endpoint := func(r *http.Request) string {
+		return r.URL.Path
+	}
+	handler := recorder.Handler(endpoint, syntheticHandler(clock))
+
+	assert.Equal(t, http.StatusOK, serve(t, handler, "http://localhost/a?latency=3ms"))
+	assert.Equal(t, http.StatusOK, serve(t, handler, "http://localhost/a?latency=30ms"))
+	assert.Equal(t, http.StatusInternalServerError, serve(t, handler, "http://localhost/a?latency=2s&status=500"))
+	assert.Equal(t, http.StatusNotFound, serve(t, handler, "http://localhost/b?status=404"))
+
+	requests := recorder.Requests()
+	require.Equal(t, 2, len(requests.Endpoints))
+	a := requests.Endpoints["/a"]
+	assert.Equal(t, uint64(3), a.Count)
+	assert.Equal(t, map[string]uint64{"2xx": 2, "5xx": 1}, a.Codes)
+	assert.Equal(t, 2033*time.Millisecond, a.Latency)
+
+	// The buckets are cumulative.
+	require.Equal(t, len(requests.LatencyBuckets), len(a.LatencyCounts))
+	for i, bound := range requests.LatencyBuckets {
+		var expected uint64
+		switch {
+		case bound >= 2*time.Second:
+			expected = 3
+		case bound >= 30*time.Millisecond:
+			expected = 2
+		case bound >= 3*time.Millisecond:
+			expected = 1
+		}
+		assert.Equal(t, expected, a.LatencyCounts[i], "bucket %v", bound)
+	}
+
+	b := requests.Endpoints["/b"]
+	assert.Equal(t, uint64(1), b.Count)
+	assert.Equal(t, map[string]uint64{"4xx": 1}, b.Codes)
+	assert.Equal(t, uint64(4), requests.RecentRequests)
+	assert.Equal(t, uint64(1), requests.RecentServerErrors)
+}
+
+func TestRecorderRecentRequestsExpire(t *testing.T) {
+	clock := fakeclock.NewFakeClock(time.Unix(1000, 0))
+	recorder := NewRecorder(clock)
+	handler := recorder.Handler(func(r *http.Request) string { return "/" }, syntheticHandler(clock))
+
+	serve(t, handler, "http://localhost/?status=500")
+	clock.Step(30 * time.Second)
+	serve(t, handler, "http://localhost/")
+	serve(t, handler, "http://localhost/")
+	requests, serverErrors := recorder.RecentRequests()
+	assert.Equal(t, uint64(3), requests)
+	assert.Equal(t, uint64(1), serverErrors)
+
+	// The failed request is over a minute old.
+	clock.Step(31 * time.Second)
+	requests, serverErrors = recorder.RecentRequests()
+	assert.Equal(t, uint64(2), requests)
+	assert.Equal(t, uint64(0), serverErrors)
+
+	// Requests in the same slot of a later minute replace the old counts.
+	clock.Step(29 * time.Second)
+	serve(t, handler, "http://localhost/?status=500")
+	requests, serverErrors = recorder.RecentRequests()
+	assert.Equal(t, uint64(1), requests)
+	assert.Equal(t, uint64(1), serverErrors)
+
+	// Counted since the recorder was created.
+	assert.Equal(t, uint64(4), recorder.Requests().Endpoints["/"].Count)
+}
@@ -0,0 +1,101 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mux
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// A handler which tells the requests that leave the state of cAdvisor
+// unchanged. Only those are served by a read-only mux, see ReadOnly().
+type SafeHandler interface {
+	http.Handler
+
+	// Whether serving the request leaves the state of cAdvisor unchanged.
+	IsSafe(r *http.Request) bool
+}
+
+type safeHandler struct {
+	http.Handler
+	isSafe func(r *http.Request) bool
+}
+
+func (self safeHandler) IsSafe(r *http.Request) bool {
+	return self.isSafe(r)
+}
+
+// Marks the requests of the handler which isSafe returns true for as leaving
+// the state of cAdvisor unchanged.
+func Safe(handler http.Handler, isSafe func(r *http.Request) bool) SafeHandler {
+	return safeHandler{
+		Handler: handler,
+		isSafe:  isSafe,
+	}
+}
+
+// Marks the GET and HEAD requests of the handler as leaving the state of
+// cAdvisor unchanged, e.g.: for pages.
+func SafeReads(handler http.Handler) SafeHandler {
+	return Safe(handler, IsRead)
+}
+
+// Whether the request is a GET or a HEAD.
+func IsRead(r *http.Request) bool {
+	return r.Method == "GET" || r.Method == "HEAD"
+}
+
+// Registers handlers on an underlying mux so that they only serve the requests
+// they mark as safe, see SafeHandler. Other requests, including all those of
+// handlers which do not implement SafeHandler, are denied with 405 Method Not
+// Allowed. New handlers are thus denied until they are marked safe.
+type readOnlyMux struct {
+	Mux
+}
+
+// Returns a mux which registers handlers on the specified one and only lets
+// them serve safe requests.
+func ReadOnly(mux Mux) Mux {
+	if IsReadOnly(mux) {
+		return mux
+	}
+	return readOnlyMux{mux}
+}
+
+// Whether the mux only serves safe requests, see ReadOnly().
+func IsReadOnly(mux Mux) bool {
+	_, ok := mux.(readOnlyMux)
+	return ok
+}
+
+func (self readOnlyMux) Handle(pattern string, handler http.Handler) {
+	safe, ok := handler.(SafeHandler)
+	if !ok {
+		safe = Safe(handler, func(r *http.Request) bool {
+			return false
+		})
+	}
+	self.Mux.Handle(pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !safe.IsSafe(r) {
+			http.Error(w, fmt.Sprintf("%s %s is not allowed: cAdvisor is read-only", r.Method, r.URL.RequestURI()), http.StatusMethodNotAllowed)
+			return
+		}
+		safe.ServeHTTP(w, r)
+	}))
+}
+
+func (self readOnlyMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	self.Handle(pattern, http.HandlerFunc(handler))
+}
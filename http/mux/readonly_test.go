@@ -0,0 +1,81 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func serve(mux Mux, method, url string) int {
+	r, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		panic(err)
+	}
+	w := httptest.NewRecorder()
+	handler, _ := mux.Handler(r)
+	handler.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestReadOnlyServesOnlySafeRequests(t *testing.T) {
+	mux := ReadOnly(http.NewServeMux())
+	mux.HandleFunc("/unmarked", okHandler)
+	mux.Handle("/page", SafeReads(http.HandlerFunc(okHandler)))
+	mux.Handle("/query", Safe(http.HandlerFunc(okHandler), func(r *http.Request) bool {
+		return r.URL.Query().Get("write") != "true"
+	}))
+
+	for _, c := range []struct {
+		method, url string
+		expected    int
+	}{
+		// Handlers not marked safe are denied.
+		{"GET", "/unmarked", http.StatusMethodNotAllowed},
+		{"POST", "/unmarked", http.StatusMethodNotAllowed},
+		{"GET", "/page", http.StatusOK},
+		{"HEAD", "/page", http.StatusOK},
+		{"POST", "/page", http.StatusMethodNotAllowed},
+		{"DELETE", "/page", http.StatusMethodNotAllowed},
+		{"POST", "/query", http.StatusOK},
+		{"GET", "/query?write=true", http.StatusMethodNotAllowed},
+	} {
+		assert.Equal(t, c.expected, serve(mux, c.method, "http://localhost:8080"+c.url), "%s %s", c.method, c.url)
+	}
+}
+
+func TestReadOnlyServesAllRequestsWhenDisabled(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/unmarked", okHandler)
+	mux.Handle("/page", SafeReads(http.HandlerFunc(okHandler)))
+	assert.Equal(t, http.StatusOK, serve(mux, "POST", "http://localhost:8080/unmarked"))
+	assert.Equal(t, http.StatusOK, serve(mux, "POST", "http://localhost:8080/page"))
+}
+
+func TestIsReadOnly(t *testing.T) {
+	mux := http.NewServeMux()
+	assert.False(t, IsReadOnly(mux))
+	readOnly := ReadOnly(mux)
+	assert.True(t, IsReadOnly(readOnly))
+	// Wrapping twice would check requests twice.
+	assert.Equal(t, readOnly, ReadOnly(readOnly))
+}
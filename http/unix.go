@@ -0,0 +1,101 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// File listing the groups of the machine.
+var groupFile = "/etc/group"
+
+// Listens on a Unix domain socket at the specified path. A socket left behind
+// at the path (e.g.: by a previous cAdvisor that did not exit cleanly) is
+// removed. The socket is given the specified permissions and, if group is not
+// empty, is owned by that group (a name or a numeric ID).
+func ListenUnix(path string, mode os.FileMode, group string) (net.Listener, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on unix socket %q: %v", path, err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set the permissions of unix socket %q: %v", path, err)
+	}
+	if group != "" {
+		gid, err := lookupGroup(group)
+		if err != nil {
+			listener.Close()
+			return nil, err
+		}
+		if err := os.Chown(path, -1, gid); err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("failed to set the group of unix socket %q to %q: %v", path, group, err)
+		}
+	}
+	return listener, nil
+}
+
+// Removes the socket at the specified path, if any. Refuses to remove anything other than a socket.
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%q exists and is not a unix socket", path)
+	}
+	return os.Remove(path)
+}
+
+// Returns the ID of the specified group. The group may be specified by name or ID.
+func lookupGroup(group string) (int, error) {
+	if gid, err := strconv.Atoi(group); err == nil {
+		return gid, nil
+	}
+	file, err := os.Open(groupFile)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up group %q: %v", group, err)
+	}
+	defer file.Close()
+
+	// Format: <name>:<password>:<gid>:<members>
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) < 3 || fields[0] != group {
+			continue
+		}
+		gid, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return 0, fmt.Errorf("invalid ID %q for group %q in %q", fields[2], group, groupFile)
+		}
+		return gid, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to look up group %q: %v", group, err)
+	}
+	return 0, fmt.Errorf("unknown group %q", group)
+}
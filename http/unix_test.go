@@ -0,0 +1,124 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+)
+
+func tempSocketPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "cadvisor-socket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path.Join(dir, "cadvisor.sock"), func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestListenUnixSetsPermissions(t *testing.T) {
+	socketPath, cleanup := tempSocketPath(t)
+	defer cleanup()
+
+	listener, err := ListenUnix(socketPath, 0600, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	fi, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		t.Errorf("%q is not a socket: %v", socketPath, fi.Mode())
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("socket has permissions %v, expected 0600", fi.Mode().Perm())
+	}
+}
+
+func TestListenUnixRemovesStaleSocket(t *testing.T) {
+	socketPath, cleanup := tempSocketPath(t)
+	defer cleanup()
+
+	// Leave a socket behind as if its server had died.
+	fd, err := syscall.Socket(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrUnix{Name: socketPath}); err != nil {
+		t.Fatal(err)
+	}
+	syscall.Close(fd)
+
+	listener, err := ListenUnix(socketPath, 0660, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener.Close()
+}
+
+func TestListenUnixKeepsOtherFiles(t *testing.T) {
+	socketPath, cleanup := tempSocketPath(t)
+	defer cleanup()
+	if err := ioutil.WriteFile(socketPath, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if listener, err := ListenUnix(socketPath, 0660, ""); err == nil {
+		listener.Close()
+		t.Fatalf("expected an error listening over a regular file")
+	}
+	if _, err := os.Stat(socketPath); err != nil {
+		t.Errorf("regular file was removed: %v", err)
+	}
+}
+
+func TestLookupGroup(t *testing.T) {
+	oldGroupFile := groupFile
+	defer func() {
+		groupFile = oldGroupFile
+	}()
+	file, err := ioutil.TempFile("", "group")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("root:x:0:\ndocker:x:999:alice,bob\n")
+	file.Close()
+	groupFile = file.Name()
+
+	cases := map[string]int{
+		"docker": 999,
+		"root":   0,
+		"1234":   1234,
+	}
+	for group, expected := range cases {
+		gid, err := lookupGroup(group)
+		if err != nil {
+			t.Errorf("failed to look up group %q: %v", group, err)
+		} else if gid != expected {
+			t.Errorf("expected group %q to have ID %d, found %d", group, expected, gid)
+		}
+	}
+	if _, err := lookupGroup("unknown"); err == nil {
+		t.Errorf("expected an error looking up an unknown group")
+	}
+}
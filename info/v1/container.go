@@ -23,6 +23,13 @@ type CpuSpec struct {
 	Limit    uint64 `json:"limit"`
 	MaxLimit uint64 `json:"max_limit"`
 	Mask     string `json:"mask,omitempty"`
+
+	// CPU time the container may use every Period under its CFS quota. Zero if there is no quota.
+	// Units: microseconds.
+	Quota uint64 `json:"quota,omitempty"`
+	// CFS period over which Quota is enforced.
+	// Units: microseconds.
+	Period uint64 `json:"period,omitempty"`
 }
 
 type MemorySpec struct {
@@ -34,15 +41,53 @@ type MemorySpec struct {
 	// Units: bytes.
 	Reservation uint64 `json:"reservation,omitempty"`
 
-	// The amount of swap space requested. Default is unlimited (-1).
+	// The amount of memory plus swap space allowed. Nil if unknown (e.g.: swap
+	// accounting is not enabled).
 	// Units: bytes.
-	SwapLimit uint64 `json:"swap_limit,omitempty"`
+	SwapLimit *uint64 `json:"swap_limit,omitempty"`
+
+	// Tendency of the kernel to swap out the memory of the container, from 0 to 100.
+	// Nil if unknown.
+	Swappiness *uint64 `json:"swappiness,omitempty"`
+
+	// Whether the OOM killer is disabled for the container. Nil if unknown.
+	OomKillDisable *bool `json:"oom_kill_disable,omitempty"`
+}
+
+// Soft and hard values of a resource limit. Unlimited is math.MaxUint64.
+type ResourceLimit struct {
+	Soft uint64 `json:"soft"`
+	Hard uint64 `json:"hard"`
+}
+
+// Limits applied to the init process of a container.
+type ProcessLimits struct {
+	// OOM score adjustment [-1000, 1000]. Higher values make the process
+	// more likely to be OOM-killed.
+	OomScoreAdj int `json:"oom_score_adj"`
+
+	// Max number of open file descriptors.
+	OpenFiles ResourceLimit `json:"open_files"`
+
+	// Max number of processes.
+	Processes ResourceLimit `json:"processes"`
+
+	// Max amount of memory that can be locked.
+	// Units: bytes.
+	LockedMemory ResourceLimit `json:"locked_memory"`
 }
 
 type ContainerSpec struct {
 	// Time at which the container was created.
 	CreationTime time.Time `json:"creation_time,omitempty"`
 
+	// Labels of the container (e.g.: Docker labels).
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// The Has* fields tell whether the container has a resource, probed when
+	// cAdvisor starts monitoring it. False means the stats of the resource
+	// are not applicable. Stats that are applicable but fail to be collected
+	// are reported in the collection status of the container instead.
 	HasCpu bool    `json:"has_cpu"`
 	Cpu    CpuSpec `json:"cpu,omitempty"`
 
@@ -51,10 +96,92 @@ type ContainerSpec struct {
 
 	HasNetwork bool `json:"has_network"`
 
+	// Name of the container whose network namespace this container joined
+	// (e.g.: Docker's --net=container:<name>). Network stats are only reported
+	// on that container. Its Docker name or ID as in the network mode if it
+	// could not be inspected (e.g.: it exited). Empty if the container owns
+	// its network namespace.
+	NetworkSharedWith string `json:"network_shared_with,omitempty"`
+
+	// Identity of the network namespace of the container, that of the owner
+	// of the namespace if it is shared. Nil if unknown.
+	Network *NetworkSpec `json:"network,omitempty"`
+
+	// Cgroup under which the container was placed (e.g.: "/docker", or the
+	// one set with Docker's --cgroup-parent). Empty if unknown.
+	CgroupParent string `json:"cgroup_parent,omitempty"`
+
 	HasFilesystem bool `json:"has_filesystem"`
 
+	// Size limit of the container's writable layer (e.g.: Docker's
+	// --storage-opt size=10G). Nil if unlimited or unknown.
+	Fs *FsSpec `json:"fs,omitempty"`
+
 	// HasDiskIo when true, indicates that DiskIo stats will be available.
 	HasDiskIo bool `json:"has_diskio"`
+
+	// Limits of the container's init process. Nil if there is no init process.
+	ProcessLimits *ProcessLimits `json:"process_limits,omitempty"`
+
+	// HasPressure when true, indicates that pressure stall information is
+	// available for at least one resource (requires a kernel with PSI).
+	HasPressure bool `json:"has_pressure"`
+
+	// HasProcess when true, indicates that the container is a single process
+	// (e.g.: a system service) whose process stats are available.
+	HasProcess bool `json:"has_process"`
+
+	// HasPids when true, indicates that the container is in a pids cgroup and
+	// its pids stats are available.
+	HasPids bool `json:"has_pids"`
+
+	// Maximum number of processes and threads in the container (e.g.: Docker's
+	// --pids-limit). Nil if unlimited.
+	PidsLimit *uint64 `json:"pids_limit,omitempty"`
+
+	// Block I/O weights and throttle limits of the container (e.g.: Docker's
+	// --blkio-weight and --device-write-bps). Nil if not in a blkio cgroup.
+	Blkio *BlkioSpec `json:"blkio,omitempty"`
+}
+
+// Identity of a network namespace, which changes when the container restarts.
+type NetworkSpec struct {
+	// Inode of the network namespace (as in the "net:[<inode>]" target of
+	// /proc/<pid>/ns/net), which identifies it.
+	NamespaceInode uint64 `json:"namespace_inode"`
+
+	// Host side of the veth pairs of the namespace (e.g.: "veth1a2b3c4").
+	// Empty if it is that of the host.
+	HostVeths []string `json:"host_veths,omitempty"`
+}
+
+type FsSpec struct {
+	// Maximum number of bytes the container can write to its filesystem.
+	Limit uint64 `json:"limit"`
+}
+
+type BlkioSpec struct {
+	// Default proportional weight of the container's I/O, from blkio.weight.
+	// Zero if the CFQ scheduler is not enabled.
+	Weight uint64 `json:"weight,omitempty"`
+
+	// Weights and limits set on specific devices, keyed by "major:minor".
+	Devices map[string]BlkioDeviceSpec `json:"devices,omitempty"`
+}
+
+// Weight and throttle limits of a block device. Zero values are not set.
+type BlkioDeviceSpec struct {
+	Weight uint64 `json:"weight,omitempty"`
+
+	// Limits in bytes and I/O operations per second.
+	ReadBps   uint64 `json:"read_bps,omitempty"`
+	WriteBps  uint64 `json:"write_bps,omitempty"`
+	ReadIops  uint64 `json:"read_iops,omitempty"`
+	WriteIops uint64 `json:"write_iops,omitempty"`
+
+	// Whether the device is configured in the cgroup but not present on the
+	// host, in which case its limits have no effect.
+	Absent bool `json:"absent,omitempty"`
 }
 
 // Container reference contains enough information to uniquely identify a container
@@ -91,12 +218,28 @@ type ContainerInfoRequest struct {
 	// End time for which to query information.
 	// If ommitted, current time is assumed.
 	End time.Time `json:"end,omitempty"`
+
+	// Whether to include the capacity of the machine, and the share of it
+	// the container may use, in the response.
+	IncludeMachine bool `json:"include_machine,omitempty"`
+
+	// Whether to return a recently deleted container with the name, as it
+	// was last known, if no current container has it.
+	IncludeDeleted bool `json:"include_deleted,omitempty"`
+
+	// Period over which to aggregate the stats. If set, the stats are
+	// returned as buckets of about that period rather than one by one, and
+	// NumStats bounds the number of buckets.
+	Resolution time.Duration `json:"resolution,omitempty"`
 }
 
 func (self *ContainerInfoRequest) Equals(other ContainerInfoRequest) bool {
 	return self.NumStats == other.NumStats &&
 		self.Start.Equal(other.Start) &&
-		self.End.Equal(other.End)
+		self.End.Equal(other.End) &&
+		self.IncludeMachine == other.IncludeMachine &&
+		self.IncludeDeleted == other.IncludeDeleted &&
+		self.Resolution == other.Resolution
 }
 
 type ContainerInfo struct {
@@ -110,6 +253,101 @@ type ContainerInfo struct {
 
 	// Historical statistics gathered from the container.
 	Stats []*ContainerStats `json:"stats,omitempty"`
+
+	// Capacity of the machine when the response was made. Only set if
+	// requested with ContainerInfoRequest.IncludeMachine.
+	MachineCapacity *MachineCapacity `json:"machine_capacity,omitempty"`
+
+	// Share of the machine's capacity the container may use, limited by its
+	// cpuset and memory limit. Only set along with MachineCapacity.
+	EffectiveCapacity *MachineCapacity `json:"effective_capacity,omitempty"`
+
+	// Resolution the stats were aggregated at, which may be coarser than
+	// the requested one. Only set if requested with
+	// ContainerInfoRequest.Resolution, in which case the stats are returned
+	// in Buckets.
+	Resolution time.Duration `json:"resolution,omitempty"`
+
+	// Stats aggregated by period of the resolution, oldest first.
+	Buckets []StatsBucket `json:"buckets,omitempty"`
+
+	// How recent the newest stats of the container are, as of the response.
+	Freshness *Freshness `json:"freshness,omitempty"`
+}
+
+// States of the freshness of a container's stats, from the most to the least recent.
+const (
+	// The stats are as recent as the housekeeping interval allows.
+	FreshnessFresh = "fresh"
+
+	// Housekeepings of the container were missed or are running late.
+	FreshnessStale = "stale"
+
+	// The container is no longer housekept (e.g.: it was deleted), or has
+	// not been for long.
+	FreshnessDead = "dead"
+)
+
+type Freshness struct {
+	// One of the Freshness* states.
+	State string `json:"state"`
+
+	// Age of the newest stats of the container. Negative if it has none.
+	NewestSampleAge time.Duration `json:"newest_sample_age"`
+
+	// Interval between the housekeepings of the container the age was
+	// compared with. Zero for deleted containers.
+	HousekeepingInterval time.Duration `json:"housekeeping_interval,omitempty"`
+}
+
+// Stats of a container aggregated over a period.
+type StatsBucket struct {
+	// Start of the period.
+	Timestamp time.Time `json:"timestamp"`
+
+	// Number of stats aggregated.
+	Samples uint64 `json:"samples"`
+
+	// CPU time used during the period, in nanoseconds.
+	CpuUsage uint64 `json:"cpu_usage"`
+
+	// Memory usage and working set during the period, in bytes.
+	MemoryUsage GaugeStats `json:"memory_usage"`
+	WorkingSet  GaugeStats `json:"working_set"`
+
+	// Bytes received and transmitted during the period.
+	RxBytes uint64 `json:"rx_bytes"`
+	TxBytes uint64 `json:"tx_bytes"`
+}
+
+// Values of a gauge during a period.
+type GaugeStats struct {
+	Mean uint64 `json:"mean"`
+	Max  uint64 `json:"max"`
+}
+
+// A page of containers, returned when containers are listed with a limit.
+type ContainerInfoPage struct {
+	Containers []ContainerInfo `json:"containers"`
+
+	// Opaque cursor to request the next page with. Empty on the last page.
+	Continue string `json:"continue,omitempty"`
+}
+
+// A container or one of its ancestors, returned in order from the root when a
+// container is requested with its ancestors.
+type ContainerAncestor struct {
+	// Absolute name of the container, e.g.: /system.slice
+	Name string `json:"name"`
+
+	// Whether cAdvisor tracks a container of that name. An intermediate
+	// cgroup it does not track has neither spec nor stats.
+	Tracked bool `json:"tracked"`
+
+	Spec *ContainerSpec `json:"spec,omitempty"`
+
+	// The latest stats of the container, if it has any.
+	Stats *ContainerStats `json:"stats,omitempty"`
 }
 
 // TODO(vmarmol): Refactor to not need this equality comparison.
@@ -139,6 +377,9 @@ func (self *ContainerInfo) Eq(b *ContainerInfo) bool {
 	if !self.Spec.Eq(&b.Spec) {
 		return false
 	}
+	if !reflect.DeepEqual(self.MachineCapacity, b.MachineCapacity) || !reflect.DeepEqual(self.EffectiveCapacity, b.EffectiveCapacity) {
+		return false
+	}
 
 	for i, expectedStats := range b.Stats {
 		selfStats := self.Stats[i]
@@ -157,6 +398,9 @@ func (self *ContainerSpec) Eq(b *ContainerSpec) bool {
 		return false
 	}
 
+	if !reflect.DeepEqual(self.Labels, b.Labels) {
+		return false
+	}
 	if self.HasCpu != b.HasCpu {
 		return false
 	}
@@ -172,12 +416,42 @@ func (self *ContainerSpec) Eq(b *ContainerSpec) bool {
 	if self.HasNetwork != b.HasNetwork {
 		return false
 	}
+	if self.NetworkSharedWith != b.NetworkSharedWith {
+		return false
+	}
+	if !reflect.DeepEqual(self.Network, b.Network) {
+		return false
+	}
+	if self.CgroupParent != b.CgroupParent {
+		return false
+	}
 	if self.HasFilesystem != b.HasFilesystem {
 		return false
 	}
+	if !reflect.DeepEqual(self.Fs, b.Fs) {
+		return false
+	}
 	if self.HasDiskIo != b.HasDiskIo {
 		return false
 	}
+	if !reflect.DeepEqual(self.ProcessLimits, b.ProcessLimits) {
+		return false
+	}
+	if self.HasPressure != b.HasPressure {
+		return false
+	}
+	if self.HasProcess != b.HasProcess {
+		return false
+	}
+	if self.HasPids != b.HasPids {
+		return false
+	}
+	if !reflect.DeepEqual(self.PidsLimit, b.PidsLimit) {
+		return false
+	}
+	if !reflect.DeepEqual(self.Blkio, b.Blkio) {
+		return false
+	}
 	return true
 }
 
@@ -234,16 +508,26 @@ type LoadStats struct {
 	NrIoWait uint64 `json:"nr_io_wait"`
 }
 
+// Unit of all the fields of CpuUsage. Sources reporting CPU time in other
+// units (e.g.: USER_HZ ticks in cpuacct.stat) are converted when the stats
+// are collected.
+const CpuUsageUnit = time.Nanosecond
+
 // CPU usage time statistics.
 type CpuUsage struct {
 	// Total CPU usage.
 	// Units: nanoseconds
 	Total uint64 `json:"total"`
 
-	// Per CPU/core usage of the container.
+	// Per CPU/core usage of the container, indexed by CPU id. CPUs which are
+	// offline keep the usage they had when they went offline.
 	// Unit: nanoseconds.
 	PerCpu []uint64 `json:"per_cpu_usage,omitempty"`
 
+	// Sorted ids of the CPUs which were online when the stats were collected.
+	// Nil if they are not known.
+	OnlineCpus []int `json:"online_cpus,omitempty"`
+
 	// Time spent in user space.
 	// Unit: nanoseconds
 	User uint64 `json:"user"`
@@ -261,6 +545,34 @@ type CpuStats struct {
 	// Load is smoothed over the last 10 seconds. Instantaneous value can be read
 	// from LoadStats.NrRunning.
 	LoadAverage int32 `json:"load_average"`
+
+	// Context switches of the processes of the container, from the latest
+	// CPU sampling. Nil unless CPU sampling is enabled.
+	ContextSwitches *ContextSwitchStats `json:"context_switches,omitempty"`
+}
+
+// Context switches of the processes of a container, from the
+// /proc/<pid>/status of each process. Only the main thread of each process is
+// counted.
+type ContextSwitchStats struct {
+	// Number of times the processes gave up the CPU, e.g.: to wait for I/O.
+	// Only counts the processes alive at the sampling: decreases when
+	// processes exit.
+	Voluntary uint64 `json:"voluntary"`
+
+	// Number of times the processes were preempted, e.g.: because their time
+	// slice ran out. Many while the CPU usage is moderate hint at contention
+	// for the CPU. Only counts the processes alive at the sampling.
+	Involuntary uint64 `json:"involuntary"`
+
+	// Time since the previous sampling. Zero at the first sampling.
+	Interval time.Duration `json:"interval,omitempty"`
+
+	// Context switches since the previous sampling, of the processes alive
+	// at both samplings or started since. Unlike the cumulative counts, they
+	// are not lowered by the processes which exited.
+	IntervalVoluntary   uint64 `json:"interval_voluntary,omitempty"`
+	IntervalInvoluntary uint64 `json:"interval_involuntary,omitempty"`
 }
 
 type PerDiskStats struct {
@@ -278,6 +590,35 @@ type DiskIoStats struct {
 	IoWaitTime     []PerDiskStats `json:"io_wait_time,omitempty"`
 	IoMerged       []PerDiskStats `json:"io_merged,omitempty"`
 	IoTime         []PerDiskStats `json:"io_time,omitempty"`
+
+	// Bytes and operations as counted by the throttling policy, whichever the
+	// I/O scheduler. Unlike the stats above, they only include the I/O of the
+	// cgroup itself and are what the throttle limits of the spec apply to.
+	ThrottleIoServiceBytes []PerDiskStats `json:"throttle_io_service_bytes,omitempty"`
+	ThrottleIoServiced     []PerDiskStats `json:"throttle_io_serviced,omitempty"`
+}
+
+// Statistics of a block device of the machine, from /proc/diskstats. The fields
+// are described in FsStats.
+type MachineDiskStats struct {
+	// Same keys as the per-container DiskIoStats.
+	Major uint64 `json:"major"`
+	Minor uint64 `json:"minor"`
+
+	// Name of the device, e.g.: sda.
+	Device string `json:"device"`
+
+	ReadsCompleted  uint64 `json:"reads_completed"`
+	ReadsMerged     uint64 `json:"reads_merged"`
+	SectorsRead     uint64 `json:"sectors_read"`
+	ReadTime        uint64 `json:"read_time"`
+	WritesCompleted uint64 `json:"writes_completed"`
+	WritesMerged    uint64 `json:"writes_merged"`
+	SectorsWritten  uint64 `json:"sectors_written"`
+	WriteTime       uint64 `json:"write_time"`
+	IoInProgress    uint64 `json:"io_in_progress"`
+	IoTime          uint64 `json:"io_time"`
+	WeightedIoTime  uint64 `json:"weighted_io_time"`
 }
 
 type MemoryStats struct {
@@ -291,13 +632,30 @@ type MemoryStats struct {
 	// Units: Bytes.
 	WorkingSet uint64 `json:"working_set"`
 
+	// Swap usage, not included in "usage". Zero without swap accounting.
+	// Units: Bytes.
+	Swap uint64 `json:"swap"`
+
 	ContainerData    MemoryStatsMemoryData `json:"container_data,omitempty"`
 	HierarchicalData MemoryStatsMemoryData `json:"hierarchical_data,omitempty"`
 }
 
 type MemoryStatsMemoryData struct {
-	Pgfault    uint64 `json:"pgfault"`
+	// Cumulative count of page faults.
+	Pgfault uint64 `json:"pgfault"`
+	// Cumulative count of major page faults (i.e.: those that required I/O).
 	Pgmajfault uint64 `json:"pgmajfault"`
+	// Cumulative count of pages charged to the cgroup (paged in).
+	Pgpgin uint64 `json:"pgpgin"`
+	// Cumulative count of pages uncharged from the cgroup (paged out).
+	Pgpgout uint64 `json:"pgpgout"`
+	// Cumulative count of pages scanned for reclaim by the allocating processes
+	// themselves (direct reclaim) and by kswapd. Zero if the kernel does not
+	// report them.
+	PgscanDirect uint64 `json:"pgscan_direct"`
+	PgscanKswapd uint64 `json:"pgscan_kswapd"`
+	// Cumulative count of pages reclaimed. Zero if the kernel does not report it.
+	Pgsteal uint64 `json:"pgsteal"`
 }
 
 type NetworkStats struct {
@@ -317,8 +675,51 @@ type NetworkStats struct {
 	TxErrors uint64 `json:"tx_errors"`
 	// Cumulative count of packets dropped while transmitting.
 	TxDropped uint64 `json:"tx_dropped"`
+	// Statistics of each of the interfaces the above are summed over, when known.
+	Interfaces []InterfaceStats `json:"interfaces,omitempty"`
+}
+
+// Cumulative statistics of a single network interface, same as NetworkStats.
+// Counters restart from zero when the interface is recreated.
+type InterfaceStats struct {
+	// The name of the interface (e.g.: eth0).
+	Name      string `json:"name"`
+	RxBytes   uint64 `json:"rx_bytes"`
+	RxPackets uint64 `json:"rx_packets"`
+	RxErrors  uint64 `json:"rx_errors"`
+	RxDropped uint64 `json:"rx_dropped"`
+	TxBytes   uint64 `json:"tx_bytes"`
+	TxPackets uint64 `json:"tx_packets"`
+	TxErrors  uint64 `json:"tx_errors"`
+	TxDropped uint64 `json:"tx_dropped"`
 }
 
+// Returns the statistics as those of the specified interface.
+func (self *NetworkStats) OfInterface(name string) InterfaceStats {
+	return InterfaceStats{
+		Name:      name,
+		RxBytes:   self.RxBytes,
+		RxPackets: self.RxPackets,
+		RxErrors:  self.RxErrors,
+		RxDropped: self.RxDropped,
+		TxBytes:   self.TxBytes,
+		TxPackets: self.TxPackets,
+		TxErrors:  self.TxErrors,
+		TxDropped: self.TxDropped,
+	}
+}
+
+// Methods by which the usage of a container's filesystem is measured.
+const (
+	// Scan of the container's files with du. Runs periodically, so the usage
+	// may be stale, and files still open after being deleted are missed.
+	FsUsageDu = "du"
+
+	// Read from the project quota of the container's files, which the
+	// filesystem keeps up to date.
+	FsUsageQuota = "quota"
+)
+
 type FsStats struct {
 	// The block device name associated with the filesystem.
 	Device string `json:"device,omitempty"`
@@ -329,6 +730,10 @@ type FsStats struct {
 	// Number of bytes that is consumed by the container on this filesystem.
 	Usage uint64 `json:"usage"`
 
+	// How Usage was measured, one of the FsUsage* methods. Empty when read
+	// from the counters of the filesystem itself.
+	UsageMethod string `json:"usage_method,omitempty"`
+
 	// Number of reads completed
 	// This is the total number of reads completed successfully.
 	ReadsCompleted uint64 `json:"reads_completed"`
@@ -384,6 +789,185 @@ type FsStats struct {
 	WeightedIoTime uint64 `json:"weighted_io_time"`
 }
 
+// Pressure stall information of tasks waiting on a resource.
+type PressureData struct {
+	// Percentage of time stalled, averaged over the last 10, 60 and 300 seconds.
+	Avg10  float64 `json:"avg10"`
+	Avg60  float64 `json:"avg60"`
+	Avg300 float64 `json:"avg300"`
+
+	// Total time stalled, in nanoseconds.
+	Total uint64 `json:"total"`
+}
+
+// Pressure stall information of a resource.
+type ResourcePressure struct {
+	// Time during which some tasks were stalled on the resource.
+	Some PressureData `json:"some"`
+
+	// Time during which all non-idle tasks were stalled on the resource. Nil
+	// if not reported (e.g.: for CPU before Linux 5.13).
+	Full *PressureData `json:"full,omitempty"`
+}
+
+// Pressure stall information (PSI). Resources without PSI are nil.
+type PressureStats struct {
+	Cpu    *ResourcePressure `json:"cpu,omitempty"`
+	Memory *ResourcePressure `json:"memory,omitempty"`
+	Io     *ResourcePressure `json:"io,omitempty"`
+}
+
+// Stats of the process of a container which is a single process (e.g.: a system service).
+type ProcessStats struct {
+	// PID of the process.
+	Pid int `json:"pid"`
+
+	// Number of open file descriptors, including sockets.
+	FdCount uint64 `json:"fd_count"`
+
+	// Number of open sockets.
+	SocketCount uint64 `json:"socket_count"`
+
+	// Number of times the process restarted (i.e.: its PID changed) since
+	// cAdvisor started.
+	Restarts uint64 `json:"restarts"`
+}
+
+// CPU used by the processes of a container with the same name.
+type ProcessCpuUsage struct {
+	// Name of the executable of the processes.
+	Name string `json:"name"`
+
+	// Number of processes with the name which used CPU.
+	Processes int `json:"processes"`
+
+	// CPU time used since the previous sample.
+	// Units: nanoseconds.
+	User   uint64 `json:"user"`
+	System uint64 `json:"system"`
+	Total  uint64 `json:"total"`
+}
+
+// Breakdown of the CPU used by a container by process name, sampled from the
+// processes of the container.
+type ProcessCpuStats struct {
+	// Time elapsed between the two samples the usage was computed from.
+	Interval time.Duration `json:"interval"`
+
+	// The process names which used the most CPU, most first.
+	Top []ProcessCpuUsage `json:"top"`
+
+	// Whether the container had more processes than are sampled, the
+	// processes which were not sampled are not accounted for.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Processes of a container in states that indicate a problem, sampled from
+// the processes of the container.
+type ProcessStateStats struct {
+	// Number of processes in uninterruptible sleep (D state), usually waiting
+	// for I/O. Many of them, or one for long, suggest hung I/O.
+	NrUninterruptible uint64 `json:"nr_uninterruptible"`
+
+	// Number of zombie processes, which exited but were not reaped by their parent.
+	NrZombie uint64 `json:"nr_zombie"`
+
+	// Name of the oldest process in uninterruptible sleep. Empty if there is none.
+	OldestUninterruptible string `json:"oldest_uninterruptible,omitempty"`
+
+	// Whether the container had more processes than are sampled, the
+	// processes which were not sampled are not counted.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Counts of the containers tracked by cAdvisor. Only in the stats of the
+// pseudo-container of cAdvisor's own metadata (/.cadvisor-meta).
+type ContainerCountStats struct {
+	// Number of containers currently tracked.
+	Tracked uint64 `json:"tracked"`
+
+	// Number of containers created and deleted since cAdvisor started.
+	Created uint64 `json:"created"`
+	Deleted uint64 `json:"deleted"`
+
+	// Containers created or deleted per minute since the previous sample.
+	ChurnPerMinute float64 `json:"churn_per_minute"`
+
+	// Latencies of the recent containers created after cAdvisor started:
+	// from their creation to their discovery by cAdvisor, and to their first
+	// stats. Nil until such a container was discovered (resp. sampled).
+	DiscoveryLatency  *LatencyPercentiles `json:"discovery_latency,omitempty"`
+	FirstStatsLatency *LatencyPercentiles `json:"first_stats_latency,omitempty"`
+}
+
+// Percentiles of a latency over recent samples.
+type LatencyPercentiles struct {
+	// Number of samples the percentiles are computed over.
+	Count uint64 `json:"count"`
+
+	P50 time.Duration `json:"p50"`
+	P95 time.Duration `json:"p95"`
+	Max time.Duration `json:"max"`
+}
+
+// Usage of a machine-wide kernel table against its limit.
+type KernelTableUsage struct {
+	Used uint64 `json:"used"`
+	Max  uint64 `json:"max"`
+}
+
+// Health gauges of the machine from /proc and /proc/sys. Each gauge is nil
+// when the kernel does not expose it (e.g.: conntrack is not loaded).
+type SystemHealthStats struct {
+	// Bits of entropy available in the kernel's entropy pool.
+	EntropyAvailable *uint64 `json:"entropy_available,omitempty"`
+
+	// Allocated file handles against fs.file-max.
+	FileHandles *KernelTableUsage `json:"file_handles,omitempty"`
+
+	// Tracked connections against net.netfilter.nf_conntrack_max.
+	Conntrack *KernelTableUsage `json:"conntrack,omitempty"`
+
+	// Tasks (processes and threads) against kernel.pid_max.
+	Pids *KernelTableUsage `json:"pids,omitempty"`
+}
+
+// Temperature of a thermal zone of the machine.
+type ThermalZoneStats struct {
+	// Name of the zone (e.g.: thermal_zone0).
+	Zone string `json:"zone"`
+	// What the zone measures (e.g.: x86_pkg_temp).
+	Type string `json:"type,omitempty"`
+	// In degrees Celsius.
+	Temperature float64 `json:"temperature"`
+}
+
+// Power drawn by a RAPL domain of the machine since the previous stat point.
+type PowerDomainStats struct {
+	// Name of the domain (e.g.: intel-rapl:0).
+	Domain string `json:"domain"`
+	// What the domain covers (e.g.: package-0, dram).
+	Name string `json:"name,omitempty"`
+	// In watts.
+	Power float64 `json:"power"`
+}
+
+// Hardware sensors of the machine.
+type MachineStats struct {
+	Thermal []ThermalZoneStats `json:"thermal,omitempty"`
+	Power   []PowerDomainStats `json:"power,omitempty"`
+}
+
+// Processes and threads in the pids cgroup of a container.
+type PidsStats struct {
+	// Number of processes and threads in the container.
+	Current uint64 `json:"current"`
+
+	// Number of times a fork or clone failed because the container was at its
+	// pids limit. Cumulative.
+	LimitHits uint64 `json:"limit_hits"`
+}
+
 type ContainerStats struct {
 	// The time of this stat point.
 	Timestamp time.Time    `json:"timestamp"`
@@ -397,6 +981,137 @@ type ContainerStats struct {
 
 	// Task load stats
 	TaskStats LoadStats `json:"task_stats,omitempty"`
+
+	// Statistics of the block devices of the machine. Only set for the root container.
+	MachineDisks []MachineDiskStats `json:"machine_disks,omitempty"`
+
+	// Health gauges of the machine. Only set for the root container.
+	SystemHealth *SystemHealthStats `json:"system_health,omitempty"`
+
+	// Temperature and power of the machine. Only set for the root container
+	// when hardware monitoring is enabled and the machine has sensors.
+	MachineStats *MachineStats `json:"machine_stats,omitempty"`
+
+	// Pressure stall information. Nil if not available.
+	Pressure *PressureStats `json:"pressure,omitempty"`
+
+	// Processes and threads of the container. Nil unless the spec has pids.
+	Pids *PidsStats `json:"pids,omitempty"`
+
+	// Stats of the process of the container. Nil unless the spec has a process.
+	Process *ProcessStats `json:"process,omitempty"`
+
+	// CPU used by the processes of the container by process name, from the
+	// latest sampling. Nil unless CPU sampling is enabled.
+	ProcessCpu *ProcessCpuStats `json:"process_cpu,omitempty"`
+
+	// Processes of the container in uninterruptible sleep and zombies, from the
+	// latest sampling. Nil unless CPU sampling is enabled.
+	ProcessStates *ProcessStateStats `json:"process_states,omitempty"`
+
+	// Number of processes in the container itself, not in its subcontainers.
+	// Zero for an empty cgroup. Nil if the processes are not known (e.g.: for
+	// groups of containers).
+	ProcessCount *uint64 `json:"process_count,omitempty"`
+
+	// Counts of the containers tracked by cAdvisor. Nil but for the
+	// pseudo-container of cAdvisor's own metadata.
+	ContainerCounts *ContainerCountStats `json:"container_counts,omitempty"`
+
+	// Monotonic time of this stat point. Unlike Timestamp, it is not affected by
+	// changes to the wall clock and does not advance while the machine is suspended.
+	// Only meaningful when compared to that of another stat point from the same machine.
+	MonotonicTimestamp time.Duration `json:"monotonic_timestamp,omitempty"`
+
+	// Whether the wall clock jumped (e.g.: it was set or the machine was suspended)
+	// since the previous stat point. Rates computed across this point may be off.
+	TimeJump bool `json:"time_jump,omitempty"`
+
+	// Whether the stat point was restored from a checkpoint of a previous cAdvisor
+	// (e.g.: across an upgrade). Rates computed between it and the next stat
+	// point that was not restored span the restart and are unknown.
+	Restored bool `json:"restored,omitempty"`
+
+	// Sections of the stat point that failed to be collected (e.g.: "memory"),
+	// their values are missing. Empty if all sections were collected.
+	PartialFailure []string `json:"partial_failure,omitempty"`
+
+	// Expensive collectors (e.g.: "du") skipped for this stat point because
+	// too many were running. Their values, if any, are those of their last
+	// run and may be stale.
+	StaleCollectors []string `json:"stale_collectors,omitempty"`
+}
+
+// Expensive collectors, which are skipped when too many of them are running
+// (see --max_concurrent_expensive_ops).
+const (
+	CollectorDu          = "du"
+	CollectorCpuSampling = "cpu_sampling"
+)
+
+// Sections of the stats of a container which are collected independently.
+const (
+	StatsSectionCpu          = "cpu"
+	StatsSectionMemory       = "memory"
+	StatsSectionDiskIo       = "diskio"
+	StatsSectionNetwork      = "network"
+	StatsSectionFilesystem   = "filesystem"
+	StatsSectionPressure     = "pressure"
+	StatsSectionMachineDisks = "machine_disks"
+	StatsSectionProcess      = "process"
+	StatsSectionSystemHealth = "system_health"
+	StatsSectionMachineStats = "machine_stats"
+	StatsSectionPids         = "pids"
+)
+
+// Returns whether the specified section failed to be collected.
+func (a *ContainerStats) Missing(section string) bool {
+	for _, missing := range a.PartialFailure {
+		if missing == section {
+			return true
+		}
+	}
+	return false
+}
+
+// Marks the specified section as missing and clears whatever part of it was collected.
+func (a *ContainerStats) MarkMissing(section string) {
+	switch section {
+	case StatsSectionCpu:
+		a.Cpu = CpuStats{}
+	case StatsSectionMemory:
+		a.Memory = MemoryStats{}
+	case StatsSectionDiskIo:
+		a.DiskIo = DiskIoStats{}
+	case StatsSectionNetwork:
+		a.Network = NetworkStats{}
+	case StatsSectionFilesystem:
+		a.Filesystem = nil
+	case StatsSectionPressure:
+		a.Pressure = nil
+	case StatsSectionMachineDisks:
+		a.MachineDisks = nil
+	case StatsSectionProcess:
+		a.Process = nil
+	case StatsSectionSystemHealth:
+		a.SystemHealth = nil
+	case StatsSectionMachineStats:
+		a.MachineStats = nil
+	case StatsSectionPids:
+		a.Pids = nil
+	}
+	if !a.Missing(section) {
+		a.PartialFailure = append(a.PartialFailure, section)
+	}
+}
+
+// Time elapsed since the specified earlier stat point. The monotonic timestamps are
+// used when both stat points have them so that wall-clock jumps are not included.
+func (a *ContainerStats) Elapsed(previous *ContainerStats) time.Duration {
+	if a.MonotonicTimestamp != 0 && previous.MonotonicTimestamp != 0 {
+		return a.MonotonicTimestamp - previous.MonotonicTimestamp
+	}
+	return a.Timestamp.Sub(previous.Timestamp)
 }
 
 func timeEq(t1, t2 time.Time, tolerance time.Duration) bool {
@@ -439,7 +1154,7 @@ func (a *ContainerStats) Eq(b *ContainerStats) bool {
 // Checks equality of the stats values.
 func (a *ContainerStats) StatsEq(b *ContainerStats) bool {
 	// TODO(vmarmol): Consider using this through reflection.
-	if !reflect.DeepEqual(a.Cpu, b.Cpu) {
+	if !cpuEq(&a.Cpu, &b.Cpu) {
 		return false
 	}
 	if !reflect.DeepEqual(a.Memory, b.Memory) {
@@ -454,9 +1169,64 @@ func (a *ContainerStats) StatsEq(b *ContainerStats) bool {
 	if !reflect.DeepEqual(a.Filesystem, b.Filesystem) {
 		return false
 	}
+	if !reflect.DeepEqual(a.MachineDisks, b.MachineDisks) {
+		return false
+	}
+	if !reflect.DeepEqual(a.SystemHealth, b.SystemHealth) {
+		return false
+	}
+	if !reflect.DeepEqual(a.Pressure, b.Pressure) {
+		return false
+	}
+	if !reflect.DeepEqual(a.Process, b.Process) {
+		return false
+	}
+	if !processCpuEq(a.ProcessCpu, b.ProcessCpu) {
+		return false
+	}
+	if !reflect.DeepEqual(a.ProcessStates, b.ProcessStates) {
+		return false
+	}
+	if !reflect.DeepEqual(a.ProcessCount, b.ProcessCount) {
+		return false
+	}
+	if !reflect.DeepEqual(a.ContainerCounts, b.ContainerCounts) {
+		return false
+	}
+	if !reflect.DeepEqual(a.PartialFailure, b.PartialFailure) {
+		return false
+	}
+	if !reflect.DeepEqual(a.StaleCollectors, b.StaleCollectors) {
+		return false
+	}
 	return true
 }
 
+// Compares the CPU used by the processes regardless of the interval it was
+// sampled over, which differs slightly between any two samples. Otherwise the
+// samples of a container whose processes are idle, or which has none, would
+// never be equal.
+func processCpuEq(a, b *ProcessCpuStats) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Truncated == b.Truncated && reflect.DeepEqual(a.Top, b.Top)
+}
+
+// Whether the CPU stats are equal, ignoring the time between the samplings of
+// the context switches.
+func cpuEq(a, b *CpuStats) bool {
+	if !reflect.DeepEqual(a.Usage, b.Usage) || a.LoadAverage != b.LoadAverage {
+		return false
+	}
+	if a.ContextSwitches == nil || b.ContextSwitches == nil {
+		return a.ContextSwitches == b.ContextSwitches
+	}
+	x, y := *a.ContextSwitches, *b.ContextSwitches
+	x.Interval, y.Interval = 0, 0
+	return x == y
+}
+
 // Saturate CPU usage to 0.
 func calculateCpuUsage(prev, cur uint64) uint64 {
 	if prev > cur {
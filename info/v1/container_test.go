@@ -15,6 +15,7 @@
 package v1
 
 import (
+	"reflect"
 	"testing"
 	"time"
 )
@@ -77,3 +78,84 @@ func createStats(cpuUsage, memUsage uint64, timestamp time.Time) *ContainerStats
 	stats.Timestamp = timestamp
 	return stats
 }
+
+func TestStatsElapsed(t *testing.T) {
+	ct := time.Now()
+	previous := &ContainerStats{Timestamp: ct}
+	latest := &ContainerStats{Timestamp: ct.Add(time.Hour)}
+	if elapsed := latest.Elapsed(previous); elapsed != time.Hour {
+		t.Errorf("elapsed is %v; should be %v", elapsed, time.Hour)
+	}
+
+	// The wall clock jumped by an hour, only a second actually elapsed.
+	previous.MonotonicTimestamp = 10 * time.Second
+	latest.MonotonicTimestamp = 11 * time.Second
+	if elapsed := latest.Elapsed(previous); elapsed != time.Second {
+		t.Errorf("elapsed is %v; should be %v", elapsed, time.Second)
+	}
+}
+
+func TestStatsMarkMissing(t *testing.T) {
+	stats := &ContainerStats{}
+	stats.Cpu.Usage.Total = 100
+	stats.Memory.Usage = 200
+	stats.Filesystem = []FsStats{{Device: "sda1"}}
+
+	stats.MarkMissing(StatsSectionMemory)
+	stats.MarkMissing(StatsSectionFilesystem)
+	stats.MarkMissing(StatsSectionMemory)
+	if !reflect.DeepEqual(stats.PartialFailure, []string{StatsSectionMemory, StatsSectionFilesystem}) {
+		t.Errorf("unexpected missing sections %v", stats.PartialFailure)
+	}
+	if !stats.Missing(StatsSectionMemory) || !stats.Missing(StatsSectionFilesystem) || stats.Missing(StatsSectionCpu) {
+		t.Errorf("unexpected missing sections %v", stats.PartialFailure)
+	}
+	if stats.Memory.Usage != 0 || stats.Filesystem != nil {
+		t.Errorf("expected the missing sections to be cleared, got memory %+v and filesystem %+v", stats.Memory, stats.Filesystem)
+	}
+	if stats.Cpu.Usage.Total != 100 {
+		t.Errorf("expected the CPU stats to be kept, got %+v", stats.Cpu)
+	}
+}
+
+func TestStatsEqProcesses(t *testing.T) {
+	none, one := uint64(0), uint64(1)
+	a := &ContainerStats{
+		ProcessCount: &none,
+		ProcessCpu:   &ProcessCpuStats{Interval: time.Second, Top: []ProcessCpuUsage{}},
+	}
+	b := &ContainerStats{
+		ProcessCount: &none,
+		ProcessCpu:   &ProcessCpuStats{Interval: time.Second + time.Millisecond, Top: []ProcessCpuUsage{}},
+	}
+	if !a.StatsEq(b) {
+		t.Errorf("expected stats sampled over different intervals to be equal")
+	}
+	b.ProcessCount = &one
+	if a.StatsEq(b) {
+		t.Errorf("expected stats with different process counts to differ")
+	}
+	b.ProcessCount = &none
+	b.ProcessCpu.Top = []ProcessCpuUsage{{Name: "sleep", Processes: 1, Total: 1}}
+	if a.StatsEq(b) {
+		t.Errorf("expected stats with different process CPU usage to differ")
+	}
+}
+
+func TestStatsEqContextSwitches(t *testing.T) {
+	a := &ContainerStats{}
+	a.Cpu.ContextSwitches = &ContextSwitchStats{Voluntary: 10, Interval: time.Second}
+	b := &ContainerStats{}
+	b.Cpu.ContextSwitches = &ContextSwitchStats{Voluntary: 10, Interval: time.Second + time.Millisecond}
+	if !a.StatsEq(b) {
+		t.Errorf("expected context switches sampled over different intervals to be equal")
+	}
+	b.Cpu.ContextSwitches.IntervalInvoluntary = 1
+	if a.StatsEq(b) {
+		t.Errorf("expected stats with different context switches to differ")
+	}
+	b.Cpu.ContextSwitches = nil
+	if a.StatsEq(b) {
+		t.Errorf("expected stats without context switches to differ")
+	}
+}
@@ -14,6 +14,8 @@
 
 package v1
 
+import "time"
+
 type FsInfo struct {
 	// Block device associated with the filesystem.
 	Device string `json:"device"`
@@ -140,6 +142,45 @@ type MachineInfo struct {
 	// Machine Topology
 	// Describes cpu/memory layout and hierarchy.
 	Topology []Node `json:"topology"`
+
+	// Kernel release of the host, as reported by uname.
+	KernelVersion string `json:"kernel_version"`
+
+	// OS image (PRETTY_NAME from /etc/os-release) of the cAdvisor container, or of the host if running on the host directly.
+	OSImage string `json:"os_image"`
+
+	// Docker version. Empty if Docker is not present.
+	DockerVersion string `json:"docker_version,omitempty"`
+
+	// Docker storage driver (e.g.: aufs, devicemapper). Empty if Docker is not present.
+	DockerStorageDriver string `json:"docker_storage_driver,omitempty"`
+
+	// cAdvisor version.
+	CadvisorVersion string `json:"cadvisor_version"`
+
+	// Offsets of the clock of the machine from the sources of time it is
+	// compared with, e.g.: the storage backend. Empty if it is not compared.
+	ClockOffsets []ClockOffset `json:"clock_offsets,omitempty"`
+}
+
+// Offset of the clock of the machine from a source of time.
+type ClockOffset struct {
+	// Name of the source, e.g.: ntp or influxdb.
+	Source string `json:"source"`
+
+	// Time of the source minus that of the machine. Positive if the clock of
+	// the machine is behind. Zero if never measured.
+	Offset time.Duration `json:"offset"`
+
+	// Whether the offset is above the threshold of cAdvisor.
+	Skewed bool `json:"skewed"`
+
+	// Time of the last measurement.
+	Timestamp time.Time `json:"timestamp"`
+
+	// Error of the last measurement, if it failed. The offset is then that
+	// of the last successful measurement.
+	Error string `json:"error,omitempty"`
 }
 
 type VersionInfo struct {
@@ -160,3 +201,28 @@ type MachineInfoFactory interface {
 	GetMachineInfo() (*MachineInfo, error)
 	GetVersionInfo() (*VersionInfo, error)
 }
+
+// Summary of the capacity of a machine, or of the share of it a container may
+// use.
+type MachineCapacity struct {
+	// Number of cores.
+	NumCores int `json:"num_cores"`
+
+	// Memory in bytes.
+	MemoryBytes int64 `json:"memory_bytes"`
+
+	// Total speed of the network devices in MBits/s.
+	NetworkSpeed int64 `json:"network_speed"`
+}
+
+// Returns the capacity of the machine.
+func (self *MachineInfo) Capacity() MachineCapacity {
+	capacity := MachineCapacity{
+		NumCores:    self.NumCores,
+		MemoryBytes: self.MemoryCapacity,
+	}
+	for _, device := range self.NetworkDevices {
+		capacity.NetworkSpeed += device.Speed
+	}
+	return capacity
+}
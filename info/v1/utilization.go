@@ -0,0 +1,213 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Memory limits at or above this are unlimited. The kernel reports an
+// unlimited memory cgroup as the largest page-aligned int64 and Docker
+// reports it as math.MaxUint64.
+const unlimitedMemoryThreshold uint64 = 1 << 62
+
+// Returns the memory limit of the container and whether it has one. There is
+// no effective limit when the limit is unset (0) or the unlimited sentinel.
+func (self *ContainerSpec) MemoryLimit() (uint64, bool) {
+	if !self.HasMemory || self.Memory.Limit == 0 || self.Memory.Limit >= unlimitedMemoryThreshold {
+		return 0, false
+	}
+	return self.Memory.Limit, true
+}
+
+// Returns the number of cores the container may use under its CFS quota and whether it has a quota.
+func (self *ContainerSpec) CpuQuotaCores() (float64, bool) {
+	if !self.HasCpu || self.Cpu.Quota == 0 || self.Cpu.Period == 0 {
+		return 0, false
+	}
+	return float64(self.Cpu.Quota) / float64(self.Cpu.Period), true
+}
+
+// Returns the number of cores the container is allowed to run on and whether they are known.
+func (self *ContainerSpec) CpusetCores() (int, bool) {
+	if !self.HasCpu || self.Cpu.Mask == "" {
+		return 0, false
+	}
+	cores, err := ParseCpuList(self.Cpu.Mask)
+	if err != nil {
+		return 0, false
+	}
+	return len(cores), true
+}
+
+// Parses a list of CPU ids in the kernel's format: comma-separated ids and
+// ranges of ids, e.g.: "0-3,8". Returns the ids in the order listed.
+func ParseCpuList(list string) ([]int, error) {
+	list = strings.TrimSpace(list)
+	if list == "" {
+		return []int{}, nil
+	}
+	var cpus []int
+	for _, part := range strings.Split(list, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid CPU list %q: %v", list, err)
+		}
+		last := first
+		if len(bounds) == 2 {
+			last, err = strconv.Atoi(bounds[1])
+			if err != nil || last < first {
+				return nil, fmt.Errorf("invalid CPU range %q in %q", part, list)
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
+
+// Returns the share of the machine's capacity the container may use: the
+// cores of its cpuset and memory up to its limit.
+func (self *ContainerSpec) EffectiveCapacity(machine MachineCapacity) MachineCapacity {
+	capacity := machine
+	if cores, ok := self.CpusetCores(); ok && cores < capacity.NumCores {
+		capacity.NumCores = cores
+	}
+	if limit, ok := self.MemoryLimit(); ok && limit < uint64(capacity.MemoryBytes) {
+		capacity.MemoryBytes = int64(limit)
+	}
+	return capacity
+}
+
+// Returns the container's working set as a percentage of its memory limit.
+// Nil if the container has no memory limit or the stats miss its memory usage.
+func (self *ContainerSpec) MemoryUtilization(stats *ContainerStats) *float64 {
+	limit, ok := self.MemoryLimit()
+	if !ok || stats.Missing(StatsSectionMemory) {
+		return nil
+	}
+	utilization := percent(float64(stats.Memory.WorkingSet), float64(limit))
+	return &utilization
+}
+
+// Returns the CPU used between the two stat points as a percentage of the
+// container's CFS quota. Nil if the container has no quota.
+func (self *ContainerSpec) CpuUtilization(prev, cur *ContainerStats) *float64 {
+	cores, ok := self.CpuQuotaCores()
+	if !ok {
+		return nil
+	}
+	return cpuUtilization(prev, cur, cores)
+}
+
+// Returns the CPU used between the two stat points as a percentage of the
+// cores the container is allowed to run on. Nil if those are not known.
+func (self *ContainerSpec) CpusetUtilization(prev, cur *ContainerStats) *float64 {
+	cores, ok := self.CpusetCores()
+	if !ok || cores == 0 {
+		return nil
+	}
+	return cpuUtilization(prev, cur, float64(cores))
+}
+
+// Returns the cores used between the two stat points as a percentage of the
+// specified cores. Nil if no time elapsed between them, they span a restart
+// or either misses its CPU stats.
+func cpuUtilization(prev, cur *ContainerStats, cores float64) *float64 {
+	if prev.Restored && !cur.Restored {
+		return nil
+	}
+	if prev.Missing(StatsSectionCpu) || cur.Missing(StatsSectionCpu) {
+		return nil
+	}
+	elapsed := cur.Elapsed(prev)
+	if elapsed <= 0 {
+		return nil
+	}
+	used := float64(calculateCpuUsage(prev.Cpu.Usage.Total, cur.Cpu.Usage.Total)) / float64(elapsed.Nanoseconds())
+	utilization := percent(used, cores)
+	return &utilization
+}
+
+// Cores used by a container on a single CPU between two stat points.
+type PerCpuUsageRate struct {
+	// Id of the CPU.
+	Cpu int `json:"cpu"`
+	// Share of the CPU's time used, between 0 and 1.
+	Cores float64 `json:"cores"`
+}
+
+// Returns the cores used on each CPU between the two stat points, sorted by
+// CPU id. CPUs that went offline or came online between the stat points are
+// skipped: their time is only partially accounted for in the interval. So are
+// CPUs whose usage went backwards. Nil under the same conditions as the CPU
+// utilization, or if the per-CPU usage of the stat points cannot be lined up.
+func PerCpuUsageRates(prev, cur *ContainerStats) []PerCpuUsageRate {
+	if prev.Restored && !cur.Restored {
+		return nil
+	}
+	if prev.Missing(StatsSectionCpu) || cur.Missing(StatsSectionCpu) {
+		return nil
+	}
+	elapsed := cur.Elapsed(prev)
+	if elapsed <= 0 {
+		return nil
+	}
+	prevUsage := prev.Cpu.Usage.PerCpu
+	curUsage := cur.Cpu.Usage.PerCpu
+	// The per-CPU usage is indexed by CPU id, unless it was collected without
+	// the set of online CPUs, in which case a change in its length means the
+	// positions no longer refer to the same CPUs.
+	tracked := prev.Cpu.Usage.OnlineCpus != nil && cur.Cpu.Usage.OnlineCpus != nil
+	if !tracked && len(prevUsage) != len(curUsage) {
+		return nil
+	}
+	var prevOnline, curOnline map[int]bool
+	if tracked {
+		prevOnline = cpuSet(prev.Cpu.Usage.OnlineCpus)
+		curOnline = cpuSet(cur.Cpu.Usage.OnlineCpus)
+	}
+
+	rates := []PerCpuUsageRate{}
+	for cpu := 0; cpu < len(prevUsage) && cpu < len(curUsage); cpu++ {
+		if tracked && (!prevOnline[cpu] || !curOnline[cpu]) {
+			continue
+		}
+		if curUsage[cpu] < prevUsage[cpu] {
+			continue
+		}
+		rates = append(rates, PerCpuUsageRate{
+			Cpu:   cpu,
+			Cores: float64(curUsage[cpu]-prevUsage[cpu]) / float64(elapsed.Nanoseconds()),
+		})
+	}
+	return rates
+}
+
+func cpuSet(cpus []int) map[int]bool {
+	set := make(map[int]bool, len(cpus))
+	for _, cpu := range cpus {
+		set[cpu] = true
+	}
+	return set
+}
+
+func percent(part, whole float64) float64 {
+	return part / whole * 100
+}
@@ -0,0 +1,276 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMemoryLimit(t *testing.T) {
+	cases := []struct {
+		name     string
+		limit    uint64
+		hasLimit bool
+	}{
+		{"unset", 0, false},
+		{"docker unlimited", math.MaxUint64, false},
+		{"cgroup unlimited", 9223372036854771712, false},
+		{"tiny", 1, true},
+		{"1GiB", 1 << 30, true},
+	}
+	for _, c := range cases {
+		spec := ContainerSpec{HasMemory: true, Memory: MemorySpec{Limit: c.limit}}
+		limit, ok := spec.MemoryLimit()
+		if ok != c.hasLimit {
+			t.Errorf("%s: expected limit present to be %v, found %v", c.name, c.hasLimit, ok)
+		}
+		if ok && limit != c.limit {
+			t.Errorf("%s: expected limit %d, found %d", c.name, c.limit, limit)
+		}
+	}
+}
+
+func TestMemoryUtilization(t *testing.T) {
+	stats := &ContainerStats{}
+	stats.Memory.WorkingSet = 2048
+
+	unlimited := ContainerSpec{HasMemory: true, Memory: MemorySpec{Limit: math.MaxUint64}}
+	if utilization := unlimited.MemoryUtilization(stats); utilization != nil {
+		t.Errorf("expected no utilization without a limit, found %v", *utilization)
+	}
+
+	// The working set can exceed tiny limits.
+	tiny := ContainerSpec{HasMemory: true, Memory: MemorySpec{Limit: 1024}}
+	if utilization := tiny.MemoryUtilization(stats); utilization == nil || *utilization != 200 {
+		t.Errorf("expected 200%% utilization, found %v", utilization)
+	}
+
+	limited := ContainerSpec{HasMemory: true, Memory: MemorySpec{Limit: 8192}}
+	if utilization := limited.MemoryUtilization(stats); utilization == nil || *utilization != 25 {
+		t.Errorf("expected 25%% utilization, found %v", utilization)
+	}
+}
+
+func TestCpusetCores(t *testing.T) {
+	cases := map[string]int{
+		"0":       1,
+		"0-3":     4,
+		"0-3,8":   5,
+		"0,2,4-5": 4,
+	}
+	for mask, expected := range cases {
+		spec := ContainerSpec{HasCpu: true, Cpu: CpuSpec{Mask: mask}}
+		cores, ok := spec.CpusetCores()
+		if !ok || cores != expected {
+			t.Errorf("expected %d cores in mask %q, found %d (ok: %v)", expected, mask, cores, ok)
+		}
+	}
+	for _, mask := range []string{"", "a-b", "3-1"} {
+		spec := ContainerSpec{HasCpu: true, Cpu: CpuSpec{Mask: mask}}
+		if cores, ok := spec.CpusetCores(); ok {
+			t.Errorf("expected no cores from mask %q, found %d", mask, cores)
+		}
+	}
+}
+
+func TestParseCpuList(t *testing.T) {
+	cases := map[string][]int{
+		"":          {},
+		"0\n":       {0},
+		"0-3":       {0, 1, 2, 3},
+		"0,2,4-5":   {0, 2, 4, 5},
+		"0-1,6-7\n": {0, 1, 6, 7},
+	}
+	for list, expected := range cases {
+		cpus, err := ParseCpuList(list)
+		if err != nil || !reflect.DeepEqual(cpus, expected) {
+			t.Errorf("expected CPUs %v in list %q, found %v (err: %v)", expected, list, cpus, err)
+		}
+	}
+	for _, list := range []string{"a", "1-a", "3-1", "0,,1"} {
+		if cpus, err := ParseCpuList(list); err == nil {
+			t.Errorf("expected an error parsing list %q, found %v", list, cpus)
+		}
+	}
+}
+
+func TestEffectiveCapacity(t *testing.T) {
+	machine := MachineCapacity{NumCores: 8, MemoryBytes: 16 << 30, NetworkSpeed: 1000}
+	cases := []struct {
+		spec     ContainerSpec
+		expected MachineCapacity
+	}{
+		// No restrictions.
+		{ContainerSpec{}, machine},
+		{ContainerSpec{HasCpu: true, HasMemory: true, Memory: MemorySpec{Limit: math.MaxUint64}}, machine},
+		// Restricted to a cpuset and a memory limit.
+		{
+			ContainerSpec{HasCpu: true, Cpu: CpuSpec{Mask: "0-1,4"}, HasMemory: true, Memory: MemorySpec{Limit: 1 << 30}},
+			MachineCapacity{NumCores: 3, MemoryBytes: 1 << 30, NetworkSpeed: 1000},
+		},
+		// Masks and limits beyond the machine are capped by it.
+		{
+			ContainerSpec{HasCpu: true, Cpu: CpuSpec{Mask: "0-15"}, HasMemory: true, Memory: MemorySpec{Limit: 32 << 30}},
+			machine,
+		},
+	}
+	for _, c := range cases {
+		if capacity := c.spec.EffectiveCapacity(machine); capacity != c.expected {
+			t.Errorf("expected capacity %+v for spec %+v, found %+v", c.expected, c.spec, capacity)
+		}
+	}
+}
+
+func TestMachineCapacity(t *testing.T) {
+	machine := MachineInfo{
+		NumCores:       4,
+		MemoryCapacity: 8 << 30,
+		NetworkDevices: []NetInfo{{Name: "eth0", Speed: 1000}, {Name: "eth1", Speed: 10000}},
+	}
+	expected := MachineCapacity{NumCores: 4, MemoryBytes: 8 << 30, NetworkSpeed: 11000}
+	if capacity := machine.Capacity(); capacity != expected {
+		t.Errorf("expected capacity %+v, found %+v", expected, capacity)
+	}
+}
+
+func TestCpuUtilization(t *testing.T) {
+	ct := time.Now()
+	// One core used over a second.
+	prev := createStats(0, 0, ct)
+	cur := createStats(uint64(time.Second), 0, ct.Add(time.Second))
+
+	// Quota of 2 cores on 4 allowed cores.
+	spec := ContainerSpec{HasCpu: true, Cpu: CpuSpec{Mask: "0-3", Quota: 200000, Period: 100000}}
+	if utilization := spec.CpuUtilization(prev, cur); utilization == nil || *utilization != 50 {
+		t.Errorf("expected 50%% of the quota used, found %v", utilization)
+	}
+	if utilization := spec.CpusetUtilization(prev, cur); utilization == nil || *utilization != 25 {
+		t.Errorf("expected 25%% of the cpuset used, found %v", utilization)
+	}
+
+	// No time elapsed.
+	if utilization := spec.CpuUtilization(prev, prev); utilization != nil {
+		t.Errorf("expected no utilization without elapsed time, found %v", *utilization)
+	}
+
+	// Across a restart.
+	prev.Restored = true
+	if utilization := spec.CpuUtilization(prev, cur); utilization != nil {
+		t.Errorf("expected no utilization across a restart, found %v", *utilization)
+	}
+	cur.Restored = true
+	if utilization := spec.CpuUtilization(prev, cur); utilization == nil || *utilization != 50 {
+		t.Errorf("expected 50%% of the quota used between restored stats, found %v", utilization)
+	}
+}
+
+func TestCpuUtilizationWithoutQuota(t *testing.T) {
+	ct := time.Now()
+	prev := createStats(0, 0, ct)
+	cur := createStats(uint64(time.Second), 0, ct.Add(time.Second))
+
+	spec := ContainerSpec{HasCpu: true, Cpu: CpuSpec{Mask: "0-1"}}
+	if utilization := spec.CpuUtilization(prev, cur); utilization != nil {
+		t.Errorf("expected no quota utilization without a quota, found %v", *utilization)
+	}
+	if utilization := spec.CpusetUtilization(prev, cur); utilization == nil || *utilization != 50 {
+		t.Errorf("expected 50%% of the cpuset used, found %v", utilization)
+	}
+}
+
+func TestUtilizationSkipsMissingSections(t *testing.T) {
+	ct := time.Now()
+	prev := createStats(0, 0, ct)
+	cur := createStats(uint64(time.Second), 0, ct.Add(time.Second))
+	cur.Memory.WorkingSet = 2048
+	spec := ContainerSpec{HasCpu: true, Cpu: CpuSpec{Mask: "0-3", Quota: 200000, Period: 100000}, HasMemory: true, Memory: MemorySpec{Limit: 8192}}
+
+	cur.MarkMissing(StatsSectionMemory)
+	if utilization := spec.MemoryUtilization(cur); utilization != nil {
+		t.Errorf("expected no memory utilization without memory stats, found %v", *utilization)
+	}
+	if utilization := spec.CpuUtilization(prev, cur); utilization == nil || *utilization != 50 {
+		t.Errorf("expected 50%% of the quota used with only memory missing, found %v", utilization)
+	}
+
+	prev.MarkMissing(StatsSectionCpu)
+	if utilization := spec.CpuUtilization(prev, cur); utilization != nil {
+		t.Errorf("expected no CPU utilization from stats missing CPU, found %v", *utilization)
+	}
+	if utilization := spec.CpusetUtilization(prev, cur); utilization != nil {
+		t.Errorf("expected no cpuset utilization from stats missing CPU, found %v", *utilization)
+	}
+}
+
+// Stats of a container at the specified time, with the specified usage by CPU
+// id and online CPUs.
+func perCpuStats(ts time.Time, perCpu []uint64, online []int) *ContainerStats {
+	stats := &ContainerStats{Timestamp: ts}
+	stats.Cpu.Usage.PerCpu = perCpu
+	stats.Cpu.Usage.OnlineCpus = online
+	return stats
+}
+
+func TestPerCpuUsageRatesAcrossHotplug(t *testing.T) {
+	ct := time.Now()
+	s := uint64(time.Second)
+	samples := []*ContainerStats{
+		perCpuStats(ct, []uint64{0, 0, 0, 0}, []int{0, 1, 2, 3}),
+		// CPU 2 goes offline: it keeps its usage.
+		perCpuStats(ct.Add(time.Second), []uint64{s, s / 2, s / 4, s}, []int{0, 1, 3}),
+		perCpuStats(ct.Add(2*time.Second), []uint64{2 * s, s, s / 4, 2 * s}, []int{0, 1, 3}),
+		// CPU 2 comes back online with its usage reset.
+		perCpuStats(ct.Add(3*time.Second), []uint64{3 * s, 3 * s / 2, s / 8, 3 * s}, []int{0, 1, 2, 3}),
+		perCpuStats(ct.Add(4*time.Second), []uint64{4 * s, 2 * s, s/8 + s/2, 4 * s}, []int{0, 1, 2, 3}),
+	}
+	expected := [][]PerCpuUsageRate{
+		{{0, 1}, {1, 0.5}, {3, 1}},
+		{{0, 1}, {1, 0.5}, {3, 1}},
+		{{0, 1}, {1, 0.5}, {3, 1}},
+		{{0, 1}, {1, 0.5}, {2, 0.5}, {3, 1}},
+	}
+	for i := 1; i < len(samples); i++ {
+		rates := PerCpuUsageRates(samples[i-1], samples[i])
+		if !reflect.DeepEqual(rates, expected[i-1]) {
+			t.Errorf("interval %d: expected rates %v, found %v", i, expected[i-1], rates)
+		}
+		for _, rate := range rates {
+			if rate.Cores < 0 {
+				t.Errorf("interval %d: negative rate on CPU %d: %v", i, rate.Cpu, rate.Cores)
+			}
+		}
+	}
+}
+
+func TestPerCpuUsageRatesWithoutOnlineCpus(t *testing.T) {
+	ct := time.Now()
+	s := uint64(time.Second)
+	prev := perCpuStats(ct, []uint64{0, s}, nil)
+	cur := perCpuStats(ct.Add(time.Second), []uint64{s, s / 2}, nil)
+	// Usage going backwards is skipped.
+	expected := []PerCpuUsageRate{{0, 1}}
+	if rates := PerCpuUsageRates(prev, cur); !reflect.DeepEqual(rates, expected) {
+		t.Errorf("expected rates %v, found %v", expected, rates)
+	}
+
+	// Positions cannot be lined up once the number of CPUs changed.
+	cur.Cpu.Usage.PerCpu = []uint64{s}
+	if rates := PerCpuUsageRates(prev, cur); rates != nil {
+		t.Errorf("expected no rates when the number of CPUs changed, found %v", rates)
+	}
+}
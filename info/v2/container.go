@@ -19,6 +19,7 @@ import (
 
 	// TODO(rjnagal): Remove dependency after moving all stats structs from v1.
 	// using v1 now for easy conversion.
+	"github.com/google/cadvisor/events"
 	"github.com/google/cadvisor/info/v1"
 )
 
@@ -42,9 +43,17 @@ type MemorySpec struct {
 	// Units: bytes.
 	Reservation uint64 `json:"reservation,omitempty"`
 
-	// The amount of swap space requested. Default is unlimited (-1).
+	// The amount of memory plus swap space allowed. Nil if unknown (e.g.: swap
+	// accounting is not enabled).
 	// Units: bytes.
-	SwapLimit uint64 `json:"swap_limit,omitempty"`
+	SwapLimit *uint64 `json:"swap_limit,omitempty"`
+
+	// Tendency of the kernel to swap out the memory of the container, from 0 to 100.
+	// Nil if unknown.
+	Swappiness *uint64 `json:"swappiness,omitempty"`
+
+	// Whether the OOM killer is disabled for the container. Nil if unknown.
+	OomKillDisable *bool `json:"oom_kill_disable,omitempty"`
 }
 
 type ContainerSpec struct {
@@ -59,11 +68,73 @@ type ContainerSpec struct {
 	// An example of a namespace is "docker" for Docker containers.
 	Namespace string `json:"namespace,omitempty"`
 
+	// Labels of the container (e.g.: Docker labels).
+	Labels map[string]string `json:"labels,omitempty"`
+
 	HasCpu bool    `json:"has_cpu"`
 	Cpu    CpuSpec `json:"cpu,omitempty"`
 
 	HasMemory bool       `json:"has_memory"`
 	Memory    MemorySpec `json:"memory,omitempty"`
+
+	// Name of the container whose network namespace this container shares.
+	// Its network stats are reported there.
+	NetworkSharedWith string `json:"network_shared_with,omitempty"`
+
+	// Cgroup under which the container was placed. Empty if unknown.
+	CgroupParent string `json:"cgroup_parent,omitempty"`
+
+	// Limits of the container's init process. Nil if there is no init process.
+	ProcessLimits *v1.ProcessLimits `json:"process_limits,omitempty"`
+
+	// Whether the container is in a pids cgroup, and the maximum number of
+	// processes and threads in it. The limit is nil if unlimited.
+	HasPids   bool    `json:"has_pids"`
+	PidsLimit *uint64 `json:"pids_limit,omitempty"`
+
+	// Block I/O weights and throttle limits. Nil if unknown.
+	Blkio *v1.BlkioSpec `json:"blkio,omitempty"`
+
+	// Size limit of the container's filesystem. Nil if unlimited or unknown.
+	Fs *v1.FsSpec `json:"fs,omitempty"`
+
+	// Identity of the container's network namespace. Nil if unknown.
+	Network *v1.NetworkSpec `json:"network,omitempty"`
+}
+
+// Converts a v1 container spec to a v2 container spec for the container with the specified reference.
+func ContainerSpecFromV1(specV1 *v1.ContainerSpec, ref v1.ContainerReference) ContainerSpec {
+	specV2 := ContainerSpec{
+		CreationTime: specV1.CreationTime,
+		Labels:       specV1.Labels,
+		HasCpu:       specV1.HasCpu,
+		HasMemory:    specV1.HasMemory,
+	}
+	if specV1.HasCpu {
+		specV2.Cpu.Limit = specV1.Cpu.Limit
+		specV2.Cpu.MaxLimit = specV1.Cpu.MaxLimit
+		specV2.Cpu.Mask = specV1.Cpu.Mask
+	}
+	if specV1.HasMemory {
+		specV2.Memory.Limit = specV1.Memory.Limit
+		specV2.Memory.Reservation = specV1.Memory.Reservation
+		specV2.Memory.SwapLimit = specV1.Memory.SwapLimit
+		specV2.Memory.Swappiness = specV1.Memory.Swappiness
+		specV2.Memory.OomKillDisable = specV1.Memory.OomKillDisable
+	}
+	specV2.NetworkSharedWith = specV1.NetworkSharedWith
+	specV2.CgroupParent = specV1.CgroupParent
+	specV2.ProcessLimits = specV1.ProcessLimits
+	specV2.Blkio = specV1.Blkio
+	specV2.Fs = specV1.Fs
+	specV2.Network = specV1.Network
+	if specV1.HasPids {
+		specV2.HasPids = true
+		specV2.PidsLimit = specV1.PidsLimit
+	}
+	specV2.Aliases = ref.Aliases
+	specV2.Namespace = ref.Namespace
+	return specV2
 }
 
 type ContainerStats struct {
@@ -87,6 +158,40 @@ type ContainerStats struct {
 	// Task load statistics
 	HasLoad bool         `json:"has_load"`
 	Load    v1.LoadStats `json:"load_stats,omitempty"`
+	// Statistics of the block devices of the machine, only for the root container.
+	MachineDisks []v1.MachineDiskStats `json:"machine_disks,omitempty"`
+	// Health gauges of the machine (entropy, file handles, conntrack, pids), only for the root container.
+	SystemHealth *v1.SystemHealthStats `json:"system_health,omitempty"`
+	// Temperature and power of the machine, only for the root container.
+	MachineStats *v1.MachineStats `json:"machine_stats,omitempty"`
+	// Pressure stall information
+	HasPressure bool              `json:"has_pressure"`
+	Pressure    *v1.PressureStats `json:"pressure,omitempty"`
+	// Stats of the process of single-process containers (e.g.: system services)
+	HasProcess bool             `json:"has_process"`
+	Process    *v1.ProcessStats `json:"process,omitempty"`
+	// Processes and threads in the pids cgroup of the container
+	HasPids bool          `json:"has_pids"`
+	Pids    *v1.PidsStats `json:"pids,omitempty"`
+	// CPU used by the processes of the container by process name, only set when CPU sampling is enabled
+	ProcessCpu *v1.ProcessCpuStats `json:"process_cpu,omitempty"`
+	// Processes in uninterruptible sleep and zombies, only set when CPU sampling is enabled
+	ProcessStates *v1.ProcessStateStats `json:"process_states,omitempty"`
+	// Counts of the containers tracked by cAdvisor, only set for its metadata pseudo-container
+	ContainerCounts *v1.ContainerCountStats `json:"container_counts,omitempty"`
+
+	// Utilization of the container's limits, only set when derived stats are requested.
+	// Working set as a percentage of the memory limit. Nil if there is no memory limit.
+	MemoryUtilization *float64 `json:"memory_utilization,omitempty"`
+	// CPU used since the previous stat point as a percentage of the CFS quota.
+	// Nil if there is no quota or this is the first stat point.
+	CpuUtilization *float64 `json:"cpu_utilization,omitempty"`
+	// CPU used since the previous stat point as a percentage of the cores in the
+	// container's cpuset. Nil if this is the first stat point.
+	CpusetUtilization *float64 `json:"cpuset_utilization,omitempty"`
+	// Cores used on each CPU since the previous stat point. CPUs which went
+	// offline or came online since then are left out.
+	PerCpuUsageRates []v1.PerCpuUsageRate `json:"per_cpu_usage_rates,omitempty"`
 }
 
 type Percentiles struct {
@@ -118,6 +223,46 @@ type InstantUsage struct {
 	Cpu uint64 `json:"cpu"`
 	// Memory usage in bytes.
 	Memory uint64 `json:"memory"`
+	// Utilization of the block devices of the machine, only for the root container.
+	Disks []DiskUtilization `json:"disks,omitempty"`
+	// Error and drop rates of the network interfaces of the container.
+	Network []InterfaceIssueRates `json:"network,omitempty"`
+	// Context switch rates of the processes of the container between their
+	// two latest CPU samplings. Nil unless CPU sampling is enabled.
+	ContextSwitches *ContextSwitchRates `json:"context_switches,omitempty"`
+	// Memory scanned for reclaim per second relative to the memory usage, see
+	// summary.ThrashingScore. Zero if the kernel does not report reclaim.
+	ThrashingScore float64 `json:"thrashing_score"`
+}
+
+// Rates of the voluntary and involuntary context switches of the processes of
+// a container, per second.
+type ContextSwitchRates struct {
+	Voluntary   float64 `json:"voluntary"`
+	Involuntary float64 `json:"involuntary"`
+}
+
+// Rates of the errors and drops of a network interface since the previous
+// sample, per second.
+type InterfaceIssueRates struct {
+	// The name of the interface (e.g.: eth0).
+	Name      string  `json:"name"`
+	RxErrors  float64 `json:"rx_errors"`
+	RxDropped float64 `json:"rx_dropped"`
+	TxErrors  float64 `json:"tx_errors"`
+	TxDropped float64 `json:"tx_dropped"`
+}
+
+// Utilization of a block device of the machine since the previous sample.
+type DiskUtilization struct {
+	// Same keys as the per-container disk I/O stats.
+	Major  uint64 `json:"major"`
+	Minor  uint64 `json:"minor"`
+	Device string `json:"device"`
+	// Percentage of the time the device had I/O in progress.
+	Utilization float64 `json:"utilization"`
+	// Average number of I/Os in progress, including those queued.
+	AverageQueueLength float64 `json:"average_queue_length"`
 }
 
 type DerivedStats struct {
@@ -131,6 +276,15 @@ type DerivedStats struct {
 	HourUsage Usage `json:"hour_usage"`
 	// Percentile in last day.
 	DayUsage Usage `json:"day_usage"`
+	// Latest CPU rate of the container relative to its fair share of its
+	// parent's rate, the share being the container's CPU shares over the total
+	// shares of its siblings. Below 1 when the container gets less than its
+	// share. Omitted for the root container and containers without CPU
+	// isolation.
+	FairShareRatio *float64 `json:"fair_share_ratio,omitempty"`
+	// Whether any of the rates in LatestUsage.Network is above
+	// --network_issue_rate_threshold.
+	HasNetworkIssues bool `json:"has_network_issues"`
 }
 
 type FsInfo struct {
@@ -150,6 +304,154 @@ type FsInfo struct {
 	Labels []string `json:"labels"`
 }
 
+// Status of the stats collection for a container.
+type CollectionStatus struct {
+	// State of the housekeeping of the container: "new", "running", or
+	// "stopped".
+	State string `json:"state,omitempty"`
+
+	// Whether collection is degraded because housekeeping has been taking
+	// longer than the housekeeping interval. While degraded, the container is
+	// housekept less often and expensive collectors (e.g.: du) are disabled.
+	Degraded bool `json:"degraded"`
+
+	// Time at which collection was last degraded.
+	DegradedSince time.Time `json:"degraded_since,omitempty"`
+
+	// Number of consecutive housekeepings that took longer than the housekeeping interval.
+	ConsecutiveOverruns int `json:"consecutive_overruns"`
+
+	// Total number of housekeepings that took longer than the housekeeping interval.
+	TotalOverruns uint64 `json:"total_overruns"`
+
+	// Duration of the last housekeeping.
+	LastHousekeepingDuration time.Duration `json:"last_housekeeping_duration"`
+
+	// Interval currently used between housekeepings.
+	HousekeepingInterval time.Duration `json:"housekeeping_interval"`
+
+	// Time since which the container has been missing (e.g.: while the Docker
+	// daemon restarts). Zero if it is not missing.
+	MissingSince time.Time `json:"missing_since,omitempty"`
+
+	// Number of times the housekeeping of the container was stuck and
+	// restarted by the watchdog.
+	HousekeepingRestarts int `json:"housekeeping_restarts,omitempty"`
+
+	// Number of operations of the container's handler that timed out (e.g.:
+	// requests to a wedged Docker daemon). A housekeeping with a timeout
+	// counts as overrunning the housekeeping interval.
+	Timeouts uint64 `json:"timeouts,omitempty"`
+
+	// Number of housekeepings that failed to collect each section of the
+	// stats (e.g.: "memory"). The stats of those housekeepings are stored
+	// without the failed sections.
+	SectionFailures map[string]uint64 `json:"section_failures,omitempty"`
+
+	// Errors of the last housekeeping by section, for the sections the spec
+	// says the container has. Sections the container does not have (e.g.:
+	// "network" without network isolation) are not applicable rather than
+	// failing. Empty once all sections are collected again.
+	CollectionErrors map[string]string `json:"collection_errors,omitempty"`
+
+	// Breakdown of the last housekeeping by section (e.g.: cgroup read, storage write), in order.
+	LastHousekeepingSections []HousekeepingSection `json:"last_housekeeping_sections,omitempty"`
+
+	// Number of self checks of the stats against the cgroup files (see
+	// --self_check), and of the discrepancies they found by field (e.g.:
+	// "memory.usage").
+	SelfChecks             uint64            `json:"self_checks,omitempty"`
+	SelfCheckDiscrepancies map[string]uint64 `json:"self_check_discrepancies,omitempty"`
+
+	// Number of errors reading the stats by class: "permission" (access
+	// denied), "gone" (e.g.: the container exited while it was read), "io"
+	// and "other".
+	ReadErrors map[string]uint64 `json:"read_errors,omitempty"`
+
+	// Sections of the stats whose reads were denied (e.g.: "memory"). They
+	// are no longer collected nor part of the spec of the container.
+	DeniedSections []string `json:"denied_sections,omitempty"`
+
+	// Time from the creation of the container (e.g.: the mtime of its cgroup
+	// or its creation time in Docker) to its discovery by cAdvisor, and to
+	// its first stats. Only measured for containers created after cAdvisor
+	// started.
+	DiscoveryLatency  time.Duration `json:"discovery_latency,omitempty"`
+	FirstStatsLatency time.Duration `json:"first_stats_latency,omitempty"`
+}
+
+// A section of a housekeeping and how long it took.
+type HousekeepingSection struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+}
+
+// A namespace of containers (e.g.: "docker"), served under its name by the API.
+type Namespace struct {
+	// Name of the namespace. Its containers are also known by their aliases
+	// within it (e.g.: /api/v1.3/docker/<alias>).
+	Name string `json:"name"`
+
+	// Container under which those of the namespace are in the container
+	// tree (e.g.: "/docker").
+	MountPoint string `json:"mount_point"`
+
+	// Name of the factory serving the containers of the namespace.
+	Factory string `json:"factory"`
+
+	// Number of containers of the namespace currently monitored.
+	NumContainers int `json:"num_containers"`
+}
+
+// A container that cAdvisor failed to start monitoring.
+type FailedContainer struct {
+	// Absolute name of the container.
+	Name string `json:"name"`
+
+	// Number of failed attempts to start monitoring the container.
+	Attempts int `json:"attempts"`
+
+	// Error of the last attempt.
+	LastError string `json:"last_error"`
+
+	// Time of the last attempt.
+	LastAttempt time.Time `json:"last_attempt"`
+
+	// Time of the next attempt. Zero if no more attempts will be made until the container is detected again.
+	NextRetry time.Time `json:"next_retry,omitempty"`
+}
+
+// Sorts by container name.
+type FailedContainerSlice []FailedContainer
+
+func (self FailedContainerSlice) Len() int           { return len(self) }
+func (self FailedContainerSlice) Swap(i, j int)      { self[i], self[j] = self[j], self[i] }
+func (self FailedContainerSlice) Less(i, j int) bool { return self[i].Name < self[j].Name }
+
+// Changes to the containers tracked by cAdvisor since a revision of the
+// container listing. Containers added and then removed since that revision are
+// left out.
+type ContainerDiff struct {
+	// Current revision of the container listing, to pass as the revision of
+	// the next request.
+	Revision uint64 `json:"revision"`
+
+	// Whether the changes since the requested revision are no longer known
+	// (e.g.: too old, or from a previous run of cAdvisor). The containers must
+	// then be listed again, after getting the current revision.
+	ResyncRequired bool `json:"resync_required,omitempty"`
+
+	// Containers added, sorted by name.
+	Added []v1.ContainerReference `json:"added,omitempty"`
+
+	// Absolute names of the containers removed, sorted.
+	Removed []string `json:"removed,omitempty"`
+
+	// Absolute names of the containers whose spec changed, or which were
+	// replaced by a container of the same name, sorted.
+	Changed []string `json:"changed,omitempty"`
+}
+
 type StatsRequest struct {
 	// Type of container identifier specified - "name", "dockerid", dockeralias"
 	IdType string `json:"type"`
@@ -157,4 +459,145 @@ type StatsRequest struct {
 	Count int `json:"count"`
 	// Whether to include stats for child subcontainers.
 	Recursive bool `json:"recursive"`
+	// Whether to include stats derived from the container's limits, e.g.: utilization.
+	IncludeDerived bool `json:"include_derived"`
+}
+
+// A page of the stats of containers, returned when stats are requested
+// recursively with a limit.
+type StatsPage struct {
+	// Stats of each container in the page, keyed by container name.
+	Stats map[string][]ContainerStats `json:"stats"`
+
+	// Opaque cursor to request the next page with. Empty on the last page.
+	Continue string `json:"continue,omitempty"`
+}
+
+type TopRequest struct {
+	// Metric to rank containers by: "cpu", "memory", or "network".
+	Metric string `json:"metric"`
+	// Number of containers to return.
+	Count int `json:"count"`
+	// Whether to leave out the container the request is for (the root container by default).
+	ExcludeRoot bool `json:"exclude_root"`
+	// Whether to only rank the immediate children of the container the request
+	// is for, with the usage of their own subcontainers rolled into theirs.
+	Aggregate bool `json:"aggregate"`
+}
+
+// A container's most recent usage of the resource it was ranked by.
+type TopContainer struct {
+	// Absolute name of the container.
+	Name string `json:"name"`
+
+	// Other names by which the container is known.
+	Aliases []string `json:"aliases,omitempty"`
+
+	// Cpu: cores used. Memory: working set in bytes. Network: bytes received
+	// and transmitted per second.
+	Value float64 `json:"value"`
+
+	// Value as a percentage of the machine's capacity. Nil if the capacity is
+	// not known (e.g.: for network).
+	PercentOfMachine *float64 `json:"percent_of_machine,omitempty"`
+}
+
+// A container whose memory is thrashing: the kernel kept scanning its pages
+// for some to reclaim, typically because it is at its memory limit.
+type MemoryPressure struct {
+	// Memory usage and limit of the container.
+	// Units: bytes.
+	Usage uint64 `json:"usage"`
+	Limit uint64 `json:"limit"`
+
+	// Latest thrashing score of the container.
+	ThrashingScore float64 `json:"thrashing_score"`
+
+	// How long the thrashing score has been above the threshold.
+	Duration time.Duration `json:"duration"`
+}
+
+// The stats of containers as of a single instant, the cut, so that they can be
+// summed consistently although each container is sampled at its own time.
+type Snapshot struct {
+	// The cut: the time of the oldest of the latest stats of the containers.
+	Timestamp time.Time `json:"timestamp"`
+
+	// The most recent stats of each container no newer than the cut, by
+	// absolute container name.
+	Stats map[string]ContainerStats `json:"stats"`
+
+	// Absolute names of the containers without stats as old as the cut (e.g.:
+	// created since), sorted.
+	NoData []string `json:"no_data,omitempty"`
+}
+
+// A process in a container.
+type ProcessInfo struct {
+	Pid int `json:"pid"`
+
+	// Name of the executable.
+	Name string `json:"name"`
+
+	// State of the process, e.g.: "R" (running), "S" (sleeping), "D"
+	// (uninterruptible sleep) or "Z" (zombie).
+	State string `json:"state"`
+}
+
+// What cAdvisor reads of a container from the kernel, for diagnostics.
+type ContainerDiagnostics struct {
+	// Processes in the container, excluding those of its subcontainers,
+	// sorted by PID.
+	Processes []ProcessInfo `json:"processes"`
+
+	// Contents of an allowlist of the cgroup files of the container, by path
+	// relative to the cgroup mount point, e.g.: "memory/memory.stat".
+	CgroupFiles map[string]string `json:"cgroup_files"`
+
+	// Sections cut to their size bound, e.g.: "processes" or
+	// "cgroup_files/memory/memory.stat".
+	Truncated []string `json:"truncated,omitempty"`
+
+	// Errors of the sections which could not be gathered, by section.
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// Everything needed to diagnose how a container is monitored, gathered at
+// once. Only the container's own data is included, nothing host-wide.
+type DiagnosticBundle struct {
+	// Name of the container.
+	Name string `json:"name"`
+
+	// Time the bundle was gathered.
+	Timestamp time.Time `json:"timestamp"`
+
+	Spec ContainerSpec `json:"spec"`
+
+	// Most recent stats of the container, oldest first.
+	Stats []*v1.ContainerStats `json:"stats"`
+
+	// Most recent events of the container, oldest first.
+	Events []*events.Event `json:"events"`
+
+	CollectionStatus CollectionStatus `json:"collection_status"`
+
+	ContainerDiagnostics
+}
+
+// A deleted container as it was last known to cAdvisor.
+type DeletedContainer struct {
+	// Absolute name of the container.
+	Name string `json:"name"`
+
+	Spec ContainerSpec `json:"spec"`
+
+	// Status of the collection of the container when it was deleted.
+	CollectionStatus CollectionStatus `json:"collection_status"`
+
+	// Time cAdvisor noticed the container was deleted.
+	DeletionTime time.Time `json:"deletion_time"`
+
+	// Last stats of the container, oldest first. Not set when listing the
+	// deleted containers.
+	Stats []*v1.ContainerStats `json:"stats,omitempty"`
 }
@@ -15,6 +15,8 @@
 package v2
 
 import (
+	"time"
+
 	// TODO(rjnagal): Move structs from v1.
 	"github.com/google/cadvisor/info/v1"
 )
@@ -78,3 +80,55 @@ func GetAttributes(mi *v1.MachineInfo, vi *v1.VersionInfo) Attributes {
 		Topology:           mi.Topology,
 	}
 }
+
+// A jump of the machine's wall clock relative to its monotonic clock, e.g.: the
+// clock was set or the machine resumed from being suspended.
+type TimeJump struct {
+	// Wall-clock time elapsed between the two samples around the jump.
+	WallClockElapsed time.Duration `json:"wall_clock_elapsed"`
+
+	// Monotonic time elapsed between the two samples around the jump.
+	MonotonicElapsed time.Duration `json:"monotonic_elapsed"`
+}
+
+// A remount of the machine's cgroup hierarchies (e.g.: by a configuration
+// management tool), after which the cgroup paths of the containers were
+// re-resolved.
+type CgroupRemount struct {
+	// Sorted subsystems whose mount point changed.
+	Subsystems []string `json:"subsystems"`
+
+	// Mount points of the changed subsystems before and after the remount, by
+	// subsystem. Subsystems which were not mounted are absent.
+	PreviousMountPoints map[string]string `json:"previous_mount_points,omitempty"`
+	CurrentMountPoints  map[string]string `json:"current_mount_points,omitempty"`
+}
+
+// CPUs of the machine went offline or came online. The core count of the
+// machine info was updated to the online CPUs.
+type CpuHotplug struct {
+	// Sorted ids of the CPUs which came online.
+	Online []int `json:"online,omitempty"`
+
+	// Sorted ids of the CPUs which went offline.
+	Offline []int `json:"offline,omitempty"`
+
+	// Number of cores online after the change.
+	NumCores int `json:"num_cores"`
+}
+
+// Names of the optional behaviors of cAdvisor in VersionInfo.Features.
+const (
+	// Requests which change the state of cAdvisor, such as forced collections
+	// and watches of events, are denied (--read_only).
+	FeatureReadOnly = "read_only"
+)
+
+// Version of a cAdvisor and its optional behaviors, which clients may adapt to.
+type VersionInfo struct {
+	// cAdvisor version.
+	CadvisorVersion string `json:"cadvisor_version"`
+
+	// Whether each optional behavior is enabled, by name (e.g.: FeatureReadOnly).
+	Features map[string]bool `json:"features"`
+}
@@ -0,0 +1,105 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Outcomes of a test.
+const (
+	TestPassed  = "pass"
+	TestFailed  = "fail"
+	TestSkipped = "skip"
+)
+
+// The outcome of an integration test, one per line of a results file.
+type TestResult struct {
+	// Name of the test, qualified by its package, e.g.: api.TestDockerContainerById.
+	Test string `json:"test"`
+
+	// When the test started and how long it took.
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+
+	// One of TestPassed, TestFailed or TestSkipped.
+	Result string `json:"result"`
+
+	// Host the test ran against.
+	Host string `json:"host"`
+
+	// Version of the cAdvisor tested. Empty if it could not be reached.
+	CadvisorVersion string `json:"cadvisor_version,omitempty"`
+}
+
+// Serializes the appends of the tests of this process. Those of other
+// processes (e.g.: the test binaries of other packages) rely on O_APPEND.
+var resultsLock sync.Mutex
+
+// Appends the result as a line of the results file at path, which is created
+// if needed. The line is written at once so that the results of tests running
+// concurrently, in this process or others, do not interleave.
+func AppendTestResult(path string, result TestResult) error {
+	out, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	out = append(out, '\n')
+
+	resultsLock.Lock()
+	defer resultsLock.Unlock()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(out)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Reads the results of the results file at path, in the order they were appended.
+func ReadTestResults(path string) ([]TestResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var results []TestResult
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var result TestResult
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			return nil, fmt.Errorf("invalid result on line %d of %q: %v", line, path, err)
+		}
+		results = append(results, result)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
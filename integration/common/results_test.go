@@ -0,0 +1,119 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func tempResultsFile(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path.Join(dir, "results.jsonl"), func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestAppendTestResult(t *testing.T) {
+	file, cleanup := tempResultsFile(t)
+	defer cleanup()
+
+	start := time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+	expected := []TestResult{
+		{
+			Test:            "api.TestDockerContainerById",
+			Start:           start,
+			Duration:        3 * time.Second,
+			Result:          TestPassed,
+			Host:            "cadvisor-test-0",
+			CadvisorVersion: "0.18.0",
+		},
+		{
+			Test:     "api.TestRawContainer",
+			Start:    start.Add(3 * time.Second),
+			Duration: time.Second,
+			Result:   TestFailed,
+			Host:     "cadvisor-test-0",
+		},
+	}
+	for _, result := range expected {
+		if err := AppendTestResult(file, result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	results, err := ReadTestResults(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected results %+v, got %+v", expected, results)
+	}
+}
+
+func TestAppendTestResultConcurrently(t *testing.T) {
+	file, cleanup := tempResultsFile(t)
+	defer cleanup()
+
+	const numTests = 50
+	var wg sync.WaitGroup
+	for i := 0; i < numTests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := AppendTestResult(file, TestResult{
+				Test:   fmt.Sprintf("api.Test%d", i),
+				Result: TestPassed,
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// A partial line would fail to parse.
+	results, err := ReadTestResults(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, result := range results {
+		seen[result.Test] = true
+	}
+	if len(results) != numTests || len(seen) != numTests {
+		t.Errorf("Expected %d distinct results, got %+v", numTests, results)
+	}
+}
+
+func TestReadTestResultsInvalidLine(t *testing.T) {
+	file, cleanup := tempResultsFile(t)
+	defer cleanup()
+
+	if err := ioutil.WriteFile(file, []byte("{\"test\":\"api.TestFoo\"}\n{\"test\":\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadTestResults(file); err == nil {
+		t.Error("Expected an error reading a partial line")
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framework
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+var cadvisorBinary = flag.String("cadvisor_binary", "", "cAdvisor binary to start on this host for each test. Empty tests the cAdvisor already running at --host and --port")
+var cadvisorStartTimeout = flag.Duration("cadvisor_start_timeout", 30*time.Second, "Time to wait for the cAdvisor started from --cadvisor_binary to become healthy")
+
+// Time cAdvisor is given to exit cleanly when restarted.
+var cadvisorStopTimeout = 10 * time.Second
+
+// A cAdvisor started by the framework.
+type cadvisorProcess struct {
+	// Command line cAdvisor is started with, without the port.
+	command []string
+
+	cmd *exec.Cmd
+
+	// Temporary directory holding the port file and the log.
+	dir     string
+	logPath string
+
+	// Port cAdvisor listens on, kept across restarts.
+	port int
+
+	// Closed when the process exits.
+	exited chan struct{}
+}
+
+// Instantiates a Framework testing a cAdvisor started with the specified
+// command line and the cAdvisor arguments of the settings. The cAdvisor is
+// killed on Cleanup().
+func newFrameworkWithCadvisor(t *testing.T, command []string, settings []FrameworkSetting) *realFramework {
+	fm := newFramework(t, HostnameInfo{
+		Host: localhost(),
+	}, settings)
+	proc := startCadvisor(t, append(append([]string{}, command...), fm.settings.cadvisorArgs...))
+	fm.hostname.Port = proc.port
+	fm.cadvisorProcess = proc
+	fm.cleanups = append(fm.cleanups, func() {
+		proc.stop(t)
+	})
+	return fm
+}
+
+// Starts cAdvisor on a port of its choosing with stats only kept in memory,
+// and waits for it to be healthy.
+func startCadvisor(t *testing.T, command []string) *cadvisorProcess {
+	dir, err := ioutil.TempDir("", "cadvisor-framework")
+	if err != nil {
+		t.Fatalf("Failed to create a directory for cAdvisor: %v", err)
+	}
+	proc := &cadvisorProcess{
+		command: command,
+		dir:     dir,
+		logPath: path.Join(dir, "cadvisor.log"),
+	}
+	err = proc.start()
+	if err != nil {
+		proc.stop(t)
+		t.Fatalf("cAdvisor %q did not come up: %v\ncAdvisor log:\n%s", command[0], err, proc.log())
+	}
+	return proc
+}
+
+// Starts the cAdvisor process and waits for it to be healthy. The first start
+// binds port 0 so that the kernel picks a free port, which cAdvisor reports in
+// the port file. Restarts reuse that port. The log is appended to.
+func (self *cadvisorProcess) start() error {
+	logFile, err := os.OpenFile(self.logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open the cAdvisor log: %v", err)
+	}
+	defer logFile.Close()
+
+	portFile := path.Join(self.dir, "port")
+	args := append(append([]string{}, self.command[1:]...), "--port="+strconv.Itoa(self.port), "--port_file="+portFile, "--logtostderr", "--monitor_system_services", "--enable_cpu_sampling", "--cpu_sampling_interval=1s", "--memory_pressure_duration=5s")
+	self.cmd = exec.Command(self.command[0], args...)
+	self.cmd.Stdout = logFile
+	self.cmd.Stderr = logFile
+	err = self.cmd.Start()
+	if err != nil {
+		self.cmd = nil
+		return fmt.Errorf("failed to start cAdvisor: %v", err)
+	}
+	exited := make(chan struct{})
+	self.exited = exited
+	cmd := self.cmd
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	return self.waitForHealthy(portFile, *cadvisorStartTimeout)
+}
+
+// Stops cAdvisor with SIGTERM, so that it shuts down cleanly (e.g.: writes its
+// checkpoint), and starts it again on the same port. It is killed if it does
+// not exit within cadvisorStopTimeout.
+func (self *cadvisorProcess) restart(t *testing.T) {
+	self.cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-self.exited:
+	case <-time.After(cadvisorStopTimeout):
+		t.Logf("cAdvisor did not exit within %v of SIGTERM, killing it", cadvisorStopTimeout)
+		self.cmd.Process.Kill()
+		<-self.exited
+	}
+	err := self.start()
+	if err != nil {
+		t.Fatalf("cAdvisor %q did not come back up: %v\ncAdvisor log:\n%s", self.command[0], err, self.log())
+	}
+}
+
+// Waits for cAdvisor to report its port and for /healthz to succeed on it.
+func (self *cadvisorProcess) waitForHealthy(portFile string, timeout time.Duration) error {
+	endTime := time.Now().Add(timeout)
+	for {
+		select {
+		case <-self.exited:
+			return fmt.Errorf("cAdvisor exited: %v", self.cmd.ProcessState)
+		case <-time.After(100 * time.Millisecond):
+		}
+		if self.port == 0 {
+			if contents, err := ioutil.ReadFile(portFile); err == nil {
+				port, err := strconv.Atoi(strings.TrimSpace(string(contents)))
+				if err != nil {
+					return fmt.Errorf("malformed port file %q: %v", portFile, err)
+				}
+				self.port = port
+			}
+		}
+		if self.port != 0 {
+			resp, err := http.Get(fmt.Sprintf("http://localhost:%d/healthz", self.port))
+			if err == nil {
+				resp.Body.Close()
+				if resp.StatusCode == http.StatusOK {
+					return nil
+				}
+			}
+		}
+		if time.Now().After(endTime) {
+			return fmt.Errorf("timed out after %v", timeout)
+		}
+	}
+}
+
+// Returns what cAdvisor logged so far.
+func (self *cadvisorProcess) log() string {
+	contents, err := ioutil.ReadFile(self.logPath)
+	if err != nil {
+		return fmt.Sprintf("<failed to read %q: %v>", self.logPath, err)
+	}
+	return string(contents)
+}
+
+// Kills cAdvisor and removes its files. Its log is added to the output of
+// failed tests.
+func (self *cadvisorProcess) stop(t *testing.T) {
+	if self.cmd != nil {
+		self.cmd.Process.Kill()
+		<-self.exited
+	}
+	if t.Failed() {
+		t.Logf("cAdvisor log:\n%s", self.log())
+	}
+	os.RemoveAll(self.dir)
+}
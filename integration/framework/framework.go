@@ -18,16 +18,24 @@ import (
 	"bytes"
 	"flag"
 	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
 	"os/exec"
+	"runtime"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/cadvisor/client"
+	"github.com/google/cadvisor/client/fake"
 	"github.com/google/cadvisor/integration/common"
 )
 
-var host = flag.String("host", "localhost", "Address of the host being tested")
+var host = flag.String("host", "localhost", "Address of the host being tested: a hostname, GCE instance name, or IP address. IPv6 addresses may be bracketed, e.g.: [::1]")
+var hostIpv6 = flag.Bool("host_ipv6", false, "Whether to test localhost over its IPv6 loopback address, ::1")
 var port = flag.Int("port", 8080, "Port of the application on the host being tested")
 
 // Integration test framework.
@@ -35,6 +43,10 @@ type Framework interface {
 	// Clean the framework state.
 	Cleanup()
 
+	// Registers a function to call on Cleanup(), e.g.: to undo changes the
+	// test makes on the host. Functions are called in registration order.
+	AddCleanup(cleanup func())
+
 	// The testing.T used by the framework and the current test.
 	T() *testing.T
 
@@ -49,6 +61,50 @@ type Framework interface {
 
 	// Returns the cAdvisor actions for the test framework.
 	Cadvisor() CadvisorActions
+
+	// Skips the test unless commands can be run as root on the host, i.e.: the
+	// tests run as root or have passwordless sudo.
+	RequiresRoot()
+}
+
+// A setting of a Framework, see New().
+type FrameworkSetting func(*frameworkSettings)
+
+type frameworkSettings struct {
+	// Whether to log all HTTP exchanges with cAdvisor.
+	traceHTTP bool
+
+	// How long the test may run before it is failed, no limit if zero.
+	testTimeout time.Duration
+
+	// Additional arguments of the cAdvisor started by the framework.
+	cadvisorArgs []string
+}
+
+// Logs the method, URL, status, latency, and bodies of all HTTP exchanges
+// with cAdvisor to the test log. Off by default.
+func TraceHTTP(trace bool) FrameworkSetting {
+	return func(settings *frameworkSettings) {
+		settings.traceHTTP = trace
+	}
+}
+
+// Fails the test, dumping the stacks of all goroutines, if it has not been
+// cleaned up within timeout of its creation. Useful for finding where a test
+// hangs. No timeout by default.
+func TestTimeout(timeout time.Duration) FrameworkSetting {
+	return func(settings *frameworkSettings) {
+		settings.testTimeout = timeout
+	}
+}
+
+// Starts the cAdvisor of --cadvisor_binary with the specified additional
+// arguments, e.g.: CadvisorArgs("--allow_dynamic_housekeeping=false"). Ignored
+// when testing a cAdvisor which is already running.
+func CadvisorArgs(args ...string) FrameworkSetting {
+	return func(settings *frameworkSettings) {
+		settings.cadvisorArgs = append(settings.cadvisorArgs, args...)
+	}
 }
 
 // Instantiates a Framework. Cleanup *must* be called. Class is thread-compatible.
@@ -57,20 +113,28 @@ type Framework interface {
 // Typical use:
 //
 // func TestFoo(t *testing.T) {
-// 	fm := framework.New(t)
+// 	fm := framework.New(t, framework.TestTimeout(time.Minute))
 // 	defer fm.Cleanup()
 //      ... actual test ...
 // }
-func New(t *testing.T) Framework {
+func New(t *testing.T, settings ...FrameworkSetting) Framework {
 	// All integration tests are large.
 	if testing.Short() {
 		t.Skip("Skipping framework test in short mode")
 	}
 
-	// Try to see if non-localhost hosts are GCE instances.
+	if *cadvisorBinary != "" {
+		return newFrameworkWithCadvisor(t, []string{*cadvisorBinary}, settings)
+	}
+
+	// Try to see if non-localhost hosts are GCE instances. IP addresses are
+	// reached directly.
 	var gceInstanceName string
-	hostname := *host
-	if hostname != "localhost" {
+	hostname := trimBrackets(*host)
+	if hostname == "localhost" {
+		hostname = localhost()
+	}
+	if !isLocalhost(hostname) && net.ParseIP(hostname) == nil {
 		gceInstanceName = hostname
 		gceIp, err := common.GetGceIp(hostname)
 		if err == nil {
@@ -78,15 +142,45 @@ func New(t *testing.T) Framework {
 		}
 	}
 
+	return newFramework(t, HostnameInfo{
+		Host:            hostname,
+		Port:            *port,
+		GceInstanceName: gceInstanceName,
+	}, settings)
+}
+
+// Instantiates a Framework whose cAdvisor is the specified fake rather than
+// the host being tested. Used to test the framework itself.
+func NewWithFake(t *testing.T, fakeCadvisor *fake.FakeCadvisor, settings ...FrameworkSetting) Framework {
+	serverUrl, err := url.Parse(fakeCadvisor.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse the fake cAdvisor's URL %q: %v", fakeCadvisor.URL, err)
+	}
+	host, portStr, err := net.SplitHostPort(serverUrl.Host)
+	if err != nil {
+		t.Fatalf("Failed to parse the fake cAdvisor's address %q: %v", serverUrl.Host, err)
+	}
+	fakePort, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatalf("Failed to parse the fake cAdvisor's port %q: %v", portStr, err)
+	}
+	return newFramework(t, HostnameInfo{
+		Host: host,
+		Port: fakePort,
+	}, settings)
+}
+
+func newFramework(t *testing.T, hostname HostnameInfo, settings []FrameworkSetting) *realFramework {
 	fm := &realFramework{
-		hostname: HostnameInfo{
-			Host:            hostname,
-			Port:            *port,
-			GceInstanceName: gceInstanceName,
-		},
+		hostname: hostname,
 		t:        t,
+		testName: callingTestName(),
+		start:    time.Now(),
 		cleanups: make([]func(), 0),
 	}
+	for _, setting := range settings {
+		setting(&fm.settings)
+	}
 	fm.shellActions = shellActions{
 		fm: fm,
 	}
@@ -94,9 +188,30 @@ func New(t *testing.T) Framework {
 		fm: fm,
 	}
 
+	fm.probeRoot()
+
+	if fm.settings.testTimeout > 0 {
+		timeout := fm.settings.testTimeout
+		timer := time.AfterFunc(timeout, func() {
+			testTimedOut(t, timeout)
+		})
+		fm.cleanups = append(fm.cleanups, func() {
+			timer.Stop()
+		})
+	}
+
 	return fm
 }
 
+// Called when a test exceeds its TestTimeout. The test is blocked elsewhere so
+// it can't be failed normally: dump all goroutines and abort the test binary.
+var testTimedOut = func(t *testing.T, timeout time.Duration) {
+	buf := make([]byte, 1<<20)
+	n := runtime.Stack(buf, true)
+	fmt.Fprintf(os.Stderr, "Test timed out after %v, goroutines:\n%s\n", timeout, buf[:n])
+	panic(fmt.Sprintf("test timed out after %v", timeout))
+}
+
 type DockerActions interface {
 	// Run the no-op pause Docker container and return its ID.
 	RunPause() string
@@ -111,28 +226,70 @@ type DockerActions interface {
 	// Run(DockerRunArgs{Image: "busybox"}, "ping", "www.google.com")
 	//   -> docker run busybox ping www.google.com
 	Run(args DockerRunArgs, cmd ...string) string
+
+	// Builds the image named name from the Dockerfile and assets in the local
+	// contextDir and makes it available on the host. Returns the image. The
+	// image is tagged with a hash of contextDir's content and only built once
+	// per content. Images are removed by CleanupTestImages() at the end of the
+	// run.
+	BuildTestImage(name, contextDir string) string
+
+	// Returns the image of the workload in integration/workload, building it
+	// if needed.
+	WorkloadImage() string
+
+	// Runs the workload with the specified arguments in a Docker container and
+	// returns its ID. e.g.: RunWorkload("--cpu_workers=1", "--memory_mb=64").
+	RunWorkload(args ...string) string
 }
 
 type ShellActions interface {
 	// Runs a specified command and arguments. Returns the stdout and stderr.
 	Run(cmd string, args ...string) (string, string)
+
+	// Runs a specified command and arguments as root, with sudo unless
+	// already root. Fails the test if sudo needs a password. Returns the
+	// stdout and stderr.
+	RunCommandAsRoot(cmd string, args ...string) (string, string)
+
+	// Copies the local file src to dst on the host.
+	Push(src, dst string)
 }
 
 type CadvisorActions interface {
 	// Returns a cAdvisor client to the machine being tested.
 	Client() *client.Client
+
+	// Restarts cAdvisor and waits for it to be healthy again. cAdvisor is
+	// stopped cleanly and comes back on the same port. Only possible when the
+	// framework started cAdvisor (--cadvisor_binary), the test is skipped
+	// otherwise.
+	Restart()
 }
 
 type realFramework struct {
 	hostname       HostnameInfo
 	t              *testing.T
+	settings       frameworkSettings
 	cadvisorClient *client.Client
 
 	shellActions  shellActions
 	dockerActions dockerActions
 
+	// The cAdvisor started by the framework, nil when testing a running one.
+	cadvisorProcess *cadvisorProcess
+
+	// Whether commands run on the host as root, and otherwise whether they
+	// can through passwordless sudo. Probed at creation.
+	isRoot  bool
+	canSudo bool
+
 	// Cleanup functions to call on Cleanup()
 	cleanups []func()
+
+	// The test using the framework and when it created it, for --results_file.
+	testName string
+	start    time.Time
 }
 
 type shellActions struct {
@@ -149,9 +306,49 @@ type HostnameInfo struct {
 	GceInstanceName string
 }
 
-// Returns: http://<host>:<port>/
+// Returns: http://<host>:<port>/, with IPv6 addresses in brackets, e.g.:
+// http://[::1]:8080/
 func (self HostnameInfo) FullHostname() string {
-	return fmt.Sprintf("http://%s:%d/", self.Host, self.Port)
+	return fmt.Sprintf("http://%s/", net.JoinHostPort(self.Host, strconv.Itoa(self.Port)))
+}
+
+// Whether the host being tested is the one running the tests.
+func (self HostnameInfo) IsLocal() bool {
+	return isLocalhost(self.Host)
+}
+
+// Returns the address to test localhost at: ::1 with --host_ipv6.
+func localhost() string {
+	if *hostIpv6 {
+		return "::1"
+	}
+	return "localhost"
+}
+
+// Strips the brackets around an IPv6 address, e.g.: [::1] -> ::1.
+func trimBrackets(host string) string {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		return host[1 : len(host)-1]
+	}
+	return host
+}
+
+func isLocalhost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Whether this host has an IPv6 loopback address to serve and connect on.
+func Ipv6LoopbackAvailable() bool {
+	l, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		return false
+	}
+	l.Close()
+	return true
 }
 
 func (self *realFramework) T() *testing.T {
@@ -174,17 +371,28 @@ func (self *realFramework) Cadvisor() CadvisorActions {
 	return self
 }
 
-// Call all cleanup functions.
+// Call all cleanup functions, then record the result of the test.
 func (self *realFramework) Cleanup() {
+	// Asked before the cleanups stop the cAdvisor started by the framework.
+	version := self.cadvisorVersion()
 	for _, cleanupFunc := range self.cleanups {
 		cleanupFunc()
 	}
+	self.recordResult(version)
+}
+
+func (self *realFramework) AddCleanup(cleanup func()) {
+	self.cleanups = append(self.cleanups, cleanup)
 }
 
 // Gets a client to the cAdvisor being tested.
 func (self *realFramework) Client() *client.Client {
 	if self.cadvisorClient == nil {
-		cadvisorClient, err := client.NewClient(self.Hostname().FullHostname())
+		transport := http.DefaultTransport
+		if self.settings.traceHTTP {
+			transport = client.NewTracingTransport(transport, self.t.Logf)
+		}
+		cadvisorClient, err := client.NewClientWithTransport(self.Hostname().FullHostname(), transport)
 		if err != nil {
 			self.t.Fatalf("Failed to instantiate the cAdvisor client: %v", err)
 		}
@@ -193,6 +401,31 @@ func (self *realFramework) Client() *client.Client {
 	return self.cadvisorClient
 }
 
+// Probes whether commands can be run as root on the host.
+func (self *realFramework) probeRoot() {
+	stdout, _, err := runCommand(self.hostname, "id", "-u")
+	if err == nil && strings.TrimSpace(stdout) == "0" {
+		self.isRoot = true
+		return
+	}
+	// -n fails instead of prompting for a password.
+	_, _, err = runCommand(self.hostname, "sudo", "-n", "true")
+	self.canSudo = err == nil
+}
+
+func (self *realFramework) RequiresRoot() {
+	if !self.isRoot && !self.canSudo {
+		self.t.Skipf("Skipping test which needs root: not root on %q and passwordless sudo is not available", self.hostname.Host)
+	}
+}
+
+func (self *realFramework) Restart() {
+	if self.cadvisorProcess == nil {
+		self.t.Skip("Restarting cAdvisor needs the framework to start it, see --cadvisor_binary")
+	}
+	self.cadvisorProcess.restart(self.t)
+}
+
 func (self dockerActions) RunPause() string {
 	return self.Run(DockerRunArgs{
 		Image: "kubernetes/pause",
@@ -237,24 +470,95 @@ func (self dockerActions) Run(args DockerRunArgs, cmd ...string) string {
 }
 
 func (self shellActions) Run(command string, args ...string) (string, string) {
+	stdout, stderr, err := runCommand(self.fm.Hostname(), command, args...)
+	if err != nil {
+		self.fm.T().Fatalf("Failed to run %q %v in %q with error: %q. Stdout: %q, Stderr: %s", command, args, self.fm.Hostname().Host, err, stdout, stderr)
+		return "", ""
+	}
+	return stdout, stderr
+}
+
+func (self shellActions) RunCommandAsRoot(command string, args ...string) (string, string) {
+	if self.fm.isRoot {
+		return self.Run(command, args...)
+	}
+	if !self.fm.canSudo {
+		self.fm.T().Fatalf("Failed to run %q %v as root in %q: passwordless sudo required, \"sudo -n true\" failed", command, args, self.fm.Hostname().Host)
+		return "", ""
+	}
+	return self.Run("sudo", append([]string{"-n", command}, args...)...)
+}
+
+func (self shellActions) Push(src, dst string) {
 	var cmd *exec.Cmd
-	if self.fm.Hostname().Host == "localhost" {
+	if self.fm.Hostname().IsLocal() {
+		cmd = exec.Command("cp", src, dst)
+	} else {
+		args := pushCommand(self.fm.Hostname(), src, dst)
+		cmd = exec.Command(args[0], args[1:]...)
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		self.fm.T().Fatalf("Failed to copy %q to %q in %q with error: %q. Output: %q", src, dst, self.fm.Hostname().Host, err, output)
+	}
+}
+
+// Runs the commands of the framework on the host. Variable for testing.
+var runCommand = runShellCommand
+
+// Runs the command on the host. Returns the stdout and stderr.
+func runShellCommand(hostname HostnameInfo, command string, args ...string) (string, string, error) {
+	var cmd *exec.Cmd
+	if hostname.IsLocal() {
 		// Just run locally.
 		cmd = exec.Command(command, args...)
 	} else {
 		// We must SSH to the remote machine and run the command.
-		cmd = exec.Command("gcutil", append([]string{"ssh", self.fm.Hostname().GceInstanceName, command}, args...)...)
+		sshArgs := sshCommand(hostname, command, args...)
+		cmd = exec.Command(sshArgs[0], sshArgs[1:]...)
 	}
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 	err := cmd.Run()
-	if err != nil {
-		self.fm.T().Fatalf("Failed to run %q %v in %q with error: %q. Stdout: %q, Stderr: %s", command, args, self.fm.Hostname().Host, err, stdout.String(), stderr.String())
-		return "", ""
+	return stdout.String(), stderr.String(), err
+}
+
+// Returns the command line running the command on the remote host over SSH:
+// through gcutil for GCE instances, ssh for the others. The remote shell
+// splits the command line again, so the arguments are quoted.
+func sshCommand(hostname HostnameInfo, command string, args ...string) []string {
+	var cmd []string
+	if hostname.GceInstanceName != "" {
+		cmd = []string{"gcutil", "ssh", hostname.GceInstanceName, command}
+	} else {
+		// ssh takes IPv6 addresses without brackets.
+		cmd = []string{"ssh", trimBrackets(hostname.Host), command}
+	}
+	for _, arg := range args {
+		cmd = append(cmd, ShellQuote(arg))
 	}
-	return stdout.String(), stderr.String()
+	return cmd
+}
+
+// Returns the command line copying the local file src to dst on the remote
+// host: through gcutil for GCE instances, scp for the others.
+func pushCommand(hostname HostnameInfo, src, dst string) []string {
+	if hostname.GceInstanceName != "" {
+		return []string{"gcutil", "push", hostname.GceInstanceName, src, dst}
+	}
+	// scp needs IPv6 addresses in brackets to tell them from the path.
+	host := trimBrackets(hostname.Host)
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	return []string{"scp", src, host + ":" + dst}
+}
+
+// Quotes the argument for a POSIX shell, e.g.: it's -> 'it'\''s'.
+func ShellQuote(arg string) string {
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
 }
 
 // Runs retryFunc until no error is returned. After dur time the last error is returned.
@@ -270,3 +574,24 @@ func RetryForDuration(retryFunc func() error, dur time.Duration) error {
 	}
 	return err
 }
+
+// Runs retryFunc until no error is returned, for at most dur. Each attempt is
+// passed a context done at the end of dur so that the requests it makes
+// through the client do not outlast it. Returns the error of the last
+// attempt, or that of the one before if the last was cut short.
+func RetryWithContext(retryFunc func(ctx client.Context) error, dur time.Duration) error {
+	waitUntil := time.Now().Add(dur)
+	var err error
+	for time.Now().Before(waitUntil) {
+		ctx, cancel := client.NewContext(waitUntil)
+		attemptErr := retryFunc(ctx)
+		cancel()
+		if attemptErr == nil {
+			return nil
+		}
+		if err == nil || !client.IsCanceled(attemptErr) {
+			err = attemptErr
+		}
+	}
+	return err
+}
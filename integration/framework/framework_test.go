@@ -0,0 +1,551 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framework
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/client"
+	"github.com/google/cadvisor/client/fake"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/integration/common"
+)
+
+func TestFrameworkWithFakeCadvisor(t *testing.T) {
+	fakeCadvisor := fake.NewFakeCadvisor()
+	defer fakeCadvisor.Close()
+	fakeCadvisor.SetMachineInfo(&info.MachineInfo{NumCores: 4})
+
+	fm := NewWithFake(t, fakeCadvisor)
+	defer fm.Cleanup()
+
+	if fm.Hostname().FullHostname() != fakeCadvisor.URL+"/" {
+		t.Errorf("Framework is testing %q, expected the fake at %q", fm.Hostname().FullHostname(), fakeCadvisor.URL)
+	}
+	machineInfo, err := fm.Cadvisor().Client().MachineInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if machineInfo.NumCores != 4 {
+		t.Errorf("Received %d cores from the fake, expected 4", machineInfo.NumCores)
+	}
+	// The client first negotiates the API version.
+	if len(fakeCadvisor.Requests()) != 2 {
+		t.Errorf("Expected the fake to receive 2 requests, received %+v", fakeCadvisor.Requests())
+	}
+}
+
+func TestFrameworkCallsCleanups(t *testing.T) {
+	fakeCadvisor := fake.NewFakeCadvisor()
+	defer fakeCadvisor.Close()
+
+	fm := NewWithFake(t, fakeCadvisor)
+	calls := []string{}
+	fm.AddCleanup(func() {
+		calls = append(calls, "first")
+	})
+	fm.AddCleanup(func() {
+		calls = append(calls, "second")
+	})
+	if len(calls) != 0 {
+		t.Errorf("Expected no cleanups to be called before Cleanup(), got %v", calls)
+	}
+	fm.Cleanup()
+	if !reflect.DeepEqual(calls, []string{"first", "second"}) {
+		t.Errorf("Expected the cleanups to be called in registration order, got %v", calls)
+	}
+}
+
+func TestFrameworkRecordsResult(t *testing.T) {
+	fakeCadvisor := fake.NewFakeCadvisor()
+	defer fakeCadvisor.Close()
+	fakeCadvisor.SetMachineInfo(&info.MachineInfo{CadvisorVersion: "0.18.0"})
+
+	dir, err := ioutil.TempDir("", "results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := path.Join(dir, "results.jsonl")
+	oldResultsFile := *resultsFile
+	*resultsFile = file
+	defer func() {
+		*resultsFile = oldResultsFile
+	}()
+
+	fm := NewWithFake(t, fakeCadvisor)
+	fm.Cleanup()
+
+	results, err := common.ReadTestResults(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected a result, got %+v", results)
+	}
+	result := results[0]
+	if result.Test != "framework.TestFrameworkRecordsResult" {
+		t.Errorf("Expected the result of framework.TestFrameworkRecordsResult, got %q", result.Test)
+	}
+	if result.Result != common.TestPassed || result.CadvisorVersion != "0.18.0" || result.Host != fm.Hostname().Host {
+		t.Errorf("Expected a pass against version 0.18.0 on %q, got %+v", fm.Hostname().Host, result)
+	}
+	if result.Start.IsZero() || result.Duration <= 0 {
+		t.Errorf("Expected the timing of the test, got %+v", result)
+	}
+}
+
+// Replaces testTimedOut for the duration of a test. Returns a channel which
+// receives each timeout.
+func fakeTestTimedOut() (chan time.Duration, func()) {
+	timeouts := make(chan time.Duration, 1)
+	original := testTimedOut
+	testTimedOut = func(_ *testing.T, timeout time.Duration) {
+		timeouts <- timeout
+	}
+	return timeouts, func() {
+		testTimedOut = original
+	}
+}
+
+func TestFrameworkTestTimeout(t *testing.T) {
+	timeouts, restore := fakeTestTimedOut()
+	defer restore()
+	fakeCadvisor := fake.NewFakeCadvisor()
+	defer fakeCadvisor.Close()
+
+	fm := NewWithFake(t, fakeCadvisor, TestTimeout(10*time.Millisecond))
+	defer fm.Cleanup()
+
+	select {
+	case timeout := <-timeouts:
+		if timeout != 10*time.Millisecond {
+			t.Errorf("Timed out after %v, expected 10ms", timeout)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Test did not time out")
+	}
+}
+
+func TestFrameworkTestTimeoutStoppedByCleanup(t *testing.T) {
+	timeouts, restore := fakeTestTimedOut()
+	defer restore()
+	fakeCadvisor := fake.NewFakeCadvisor()
+	defer fakeCadvisor.Close()
+
+	fm := NewWithFake(t, fakeCadvisor, TestTimeout(50*time.Millisecond))
+	fm.Cleanup()
+
+	select {
+	case <-timeouts:
+		t.Errorf("Test timed out after Cleanup()")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestFrameworkTraceHTTP(t *testing.T) {
+	fakeCadvisor := fake.NewFakeCadvisor()
+	defer fakeCadvisor.Close()
+
+	fm := NewWithFake(t, fakeCadvisor, TraceHTTP(true))
+	defer fm.Cleanup()
+
+	// Tracing must not interfere with the requests.
+	if _, err := fm.Cadvisor().Client().MachineInfo(); err != nil {
+		t.Fatal(err)
+	}
+	// The client first negotiates the API version.
+	if len(fakeCadvisor.Requests()) != 2 {
+		t.Errorf("Expected the fake to receive 2 requests, received %+v", fakeCadvisor.Requests())
+	}
+}
+
+// Attempts are cut short at the end of the duration and their cancellation
+// does not hide the error of the previous attempt.
+func TestRetryWithContextBoundsAttempts(t *testing.T) {
+	attempts := 0
+	start := time.Now()
+	err := RetryWithContext(func(ctx client.Context) error {
+		attempts++
+		if attempts == 1 {
+			return fmt.Errorf("not ready")
+		}
+		<-ctx.Done()
+		return &client.CanceledError{Url: "http://cadvisor/", Err: ctx.Err()}
+	}, 100*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Retried for %v, expected at most 100ms", elapsed)
+	}
+	if err == nil || err.Error() != "not ready" {
+		t.Errorf("Expected the error of the first attempt, got %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("Made %d attempts, expected 2", attempts)
+	}
+}
+
+func TestRetryWithContextSucceeds(t *testing.T) {
+	attempts := 0
+	err := RetryWithContext(func(ctx client.Context) error {
+		if deadline, ok := ctx.Deadline(); !ok || deadline.After(time.Now().Add(time.Minute)) {
+			t.Errorf("Attempt has a deadline of %v (%v), expected within a minute", deadline, ok)
+		}
+		attempts++
+		if attempts < 3 {
+			return fmt.Errorf("not ready")
+		}
+		return nil
+	}, time.Minute)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Made %d attempts, expected 3", attempts)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	for _, arg := range []string{"", "simple", "with spaces", "it's", "$HOME `id` \"quoted\" \\"} {
+		out, err := exec.Command("sh", "-c", "printf %s "+ShellQuote(arg)).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != arg {
+			t.Errorf("Quoting %q was read back by the shell as %q", arg, string(out))
+		}
+	}
+}
+
+// Not a test: serves /healthz like cAdvisor, its PID at /pid and its --greeting at /greeting when run by TestFrameworkStartsCadvisor.
+func TestHelperCadvisor(t *testing.T) {
+	if os.Getenv("FRAMEWORK_HELPER_CADVISOR") != "1" {
+		return
+	}
+	args := flag.Args()
+	flags := flag.NewFlagSet("cadvisor", flag.ContinueOnError)
+	port := flags.Int("port", 8080, "")
+	portFile := flags.String("port_file", "", "")
+	flags.Bool("logtostderr", false, "")
+	flags.Bool("monitor_system_services", false, "")
+	flags.Bool("enable_cpu_sampling", false, "")
+	flags.Duration("cpu_sampling_interval", 0, "")
+	flags.Duration("memory_pressure_duration", 0, "")
+	greeting := flags.String("greeting", "", "")
+	if err := flags.Parse(args); err != nil {
+		os.Exit(2)
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", *port))
+	if err != nil {
+		os.Exit(1)
+	}
+	actualPort := listener.Addr().(*net.TCPAddr).Port
+	if err := ioutil.WriteFile(*portFile, []byte(strconv.Itoa(actualPort)), 0644); err != nil {
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "helper cAdvisor listening on port %d\n", actualPort)
+	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	})
+	http.HandleFunc("/pid", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, os.Getpid())
+	})
+	http.HandleFunc("/greeting", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, *greeting)
+	})
+	http.Serve(listener, nil)
+	os.Exit(0)
+}
+
+func TestFrameworkStartsCadvisor(t *testing.T) {
+	os.Setenv("FRAMEWORK_HELPER_CADVISOR", "1")
+	defer os.Setenv("FRAMEWORK_HELPER_CADVISOR", "")
+
+	fm := newFrameworkWithCadvisor(t, []string{os.Args[0], "-test.run=TestHelperCadvisor", "--"}, nil)
+	if fm.Hostname().Host != "localhost" || fm.Hostname().Port == 0 {
+		t.Errorf("Framework is testing %+v, expected the started cAdvisor on localhost", fm.Hostname())
+	}
+	healthz := fm.Hostname().FullHostname() + "healthz"
+	resp, err := http.Get(healthz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the started cAdvisor to be healthy, got status %d", resp.StatusCode)
+	}
+
+	// Cleanup kills cAdvisor.
+	fm.Cleanup()
+	if resp, err := http.Get(healthz); err == nil {
+		resp.Body.Close()
+		t.Errorf("Expected cAdvisor to be killed on Cleanup()")
+	}
+}
+
+// Returns the body of a GET of the URL.
+func getBody(t *testing.T, url string) string {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestFrameworkRestartsCadvisor(t *testing.T) {
+	os.Setenv("FRAMEWORK_HELPER_CADVISOR", "1")
+	defer os.Setenv("FRAMEWORK_HELPER_CADVISOR", "")
+
+	fm := newFrameworkWithCadvisor(t, []string{os.Args[0], "-test.run=TestHelperCadvisor", "--"}, []FrameworkSetting{CadvisorArgs("--greeting=hello")})
+	defer fm.Cleanup()
+	hostname := fm.Hostname()
+	if greeting := getBody(t, hostname.FullHostname()+"greeting"); greeting != "hello" {
+		t.Errorf("Expected cAdvisor to be started with the greeting argument, got %q", greeting)
+	}
+	pid := getBody(t, hostname.FullHostname()+"pid")
+
+	fm.Cadvisor().Restart()
+	if fm.Hostname() != hostname {
+		t.Errorf("Framework is testing %+v after the restart, expected %+v", fm.Hostname(), hostname)
+	}
+	if newPid := getBody(t, hostname.FullHostname()+"pid"); newPid == pid {
+		t.Errorf("Expected a new cAdvisor process after the restart, still PID %s", pid)
+	}
+	if greeting := getBody(t, hostname.FullHostname()+"greeting"); greeting != "hello" {
+		t.Errorf("Expected cAdvisor to be restarted with the greeting argument, got %q", greeting)
+	}
+}
+
+func TestContextHash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "context-hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFile := func(name, content string) {
+		if err := ioutil.WriteFile(path.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile("Dockerfile", "FROM scratch\n")
+	first, err := contextHash(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := contextHash(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Errorf("Hash of an unchanged context changed from %q to %q", first, second)
+	}
+
+	// Adding or changing a file changes the hash.
+	writeFile("asset", "1")
+	withAsset, err := contextHash(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile("asset", "2")
+	changedAsset, err := contextHash(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if withAsset == first || changedAsset == withAsset {
+		t.Errorf("Expected the hash to change with the content, got %q, %q, and %q", first, withAsset, changedAsset)
+	}
+
+	if _, err := contextHash(path.Join(dir, "missing")); err == nil {
+		t.Errorf("Expected an error hashing a missing context")
+	}
+}
+
+func TestTestImageTag(t *testing.T) {
+	tag := testImageTag("workload", "0123456789abcdef0123")
+	if tag != "cadvisor-test/workload:0123456789ab" {
+		t.Errorf("Unexpected tag %q", tag)
+	}
+}
+
+// Replaces the command runner for the duration of a test. The commands run
+// are recorded, "id -u" outputs uid and "sudo -n true" succeeds if canSudo.
+func fakeRunCommand(uid string, canSudo bool) (*[]string, func()) {
+	var commands []string
+	oldRunCommand := runCommand
+	runCommand = func(hostname HostnameInfo, command string, args ...string) (string, string, error) {
+		commandLine := strings.Join(append([]string{command}, args...), " ")
+		switch commandLine {
+		case "id -u":
+			return uid + "\n", "", nil
+		case "sudo -n true":
+			if !canSudo {
+				return "", "sudo: a password is required\n", fmt.Errorf("exit status 1")
+			}
+			return "", "", nil
+		}
+		commands = append(commands, commandLine)
+		return "", "", nil
+	}
+	return &commands, func() {
+		runCommand = oldRunCommand
+	}
+}
+
+func TestRunCommandAsRootWhenRoot(t *testing.T) {
+	commands, restore := fakeRunCommand("0", false)
+	defer restore()
+
+	fm := newFramework(t, HostnameInfo{Host: "localhost"}, nil)
+	defer fm.Cleanup()
+	fm.RequiresRoot()
+	fm.Shell().RunCommandAsRoot("mkdir", "-p", "/sys/fs/cgroup/cpu/test")
+	if !reflect.DeepEqual(*commands, []string{"mkdir -p /sys/fs/cgroup/cpu/test"}) {
+		t.Errorf("Expected the command to run without sudo as root, ran %q", *commands)
+	}
+}
+
+func TestRunCommandAsRootWithSudo(t *testing.T) {
+	commands, restore := fakeRunCommand("1000", true)
+	defer restore()
+
+	fm := newFramework(t, HostnameInfo{Host: "localhost"}, nil)
+	defer fm.Cleanup()
+	fm.RequiresRoot()
+	fm.Shell().RunCommandAsRoot("mkdir", "-p", "/sys/fs/cgroup/cpu/test")
+	if !reflect.DeepEqual(*commands, []string{"sudo -n mkdir -p /sys/fs/cgroup/cpu/test"}) {
+		t.Errorf("Expected the command to run with non-interactive sudo, ran %q", *commands)
+	}
+}
+
+func TestProbeRootWithoutSudo(t *testing.T) {
+	_, restore := fakeRunCommand("1000", false)
+	defer restore()
+
+	fm := newFramework(t, HostnameInfo{Host: "localhost"}, nil)
+	defer fm.Cleanup()
+	if fm.isRoot || fm.canSudo {
+		t.Errorf("Expected no root access without passwordless sudo, probed root=%v sudo=%v", fm.isRoot, fm.canSudo)
+	}
+}
+
+func TestFullHostname(t *testing.T) {
+	for host, expected := range map[string]string{
+		"localhost":     "http://localhost:8080/",
+		"cadvisor-test": "http://cadvisor-test:8080/",
+		"10.240.0.2":    "http://10.240.0.2:8080/",
+		"::1":           "http://[::1]:8080/",
+		"2001:db8::2":   "http://[2001:db8::2]:8080/",
+	} {
+		hostname := HostnameInfo{Host: host, Port: 8080}
+		if hostname.FullHostname() != expected {
+			t.Errorf("Expected %q for host %q, got %q", expected, host, hostname.FullHostname())
+		}
+	}
+}
+
+func TestIsLocal(t *testing.T) {
+	for host, expected := range map[string]bool{
+		"localhost":     true,
+		"127.0.0.1":     true,
+		"::1":           true,
+		"cadvisor-test": false,
+		"10.240.0.2":    false,
+		"2001:db8::2":   false,
+	} {
+		if (HostnameInfo{Host: host}).IsLocal() != expected {
+			t.Errorf("Expected host %q to be local: %v", host, expected)
+		}
+	}
+}
+
+func TestRemoteCommands(t *testing.T) {
+	for _, test := range []struct {
+		hostname HostnameInfo
+		ssh      []string
+		push     []string
+	}{
+		{
+			hostname: HostnameInfo{Host: "10.240.0.2", GceInstanceName: "cadvisor-test"},
+			ssh:      []string{"gcutil", "ssh", "cadvisor-test", "ls", "'/tmp'"},
+			push:     []string{"gcutil", "push", "cadvisor-test", "image.tar", "/tmp"},
+		},
+		{
+			hostname: HostnameInfo{Host: "10.240.0.2"},
+			ssh:      []string{"ssh", "10.240.0.2", "ls", "'/tmp'"},
+			push:     []string{"scp", "image.tar", "10.240.0.2:/tmp"},
+		},
+		{
+			hostname: HostnameInfo{Host: "2001:db8::2"},
+			ssh:      []string{"ssh", "2001:db8::2", "ls", "'/tmp'"},
+			push:     []string{"scp", "image.tar", "[2001:db8::2]:/tmp"},
+		},
+		{
+			hostname: HostnameInfo{Host: "[2001:db8::2]"},
+			ssh:      []string{"ssh", "2001:db8::2", "ls", "'/tmp'"},
+			push:     []string{"scp", "image.tar", "[2001:db8::2]:/tmp"},
+		},
+	} {
+		if ssh := sshCommand(test.hostname, "ls", "/tmp"); !reflect.DeepEqual(ssh, test.ssh) {
+			t.Errorf("Expected %q to SSH to %+v, got %q", test.ssh, test.hostname, ssh)
+		}
+		if push := pushCommand(test.hostname, "image.tar", "/tmp"); !reflect.DeepEqual(push, test.push) {
+			t.Errorf("Expected %q to copy to %+v, got %q", test.push, test.hostname, push)
+		}
+	}
+}
+
+func TestFrameworkWithFakeCadvisorOverIpv6(t *testing.T) {
+	if !Ipv6LoopbackAvailable() {
+		t.Skip("No IPv6 loopback address on this host")
+	}
+	l, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fakeCadvisor := fake.NewFakeCadvisorWithListener(l)
+	defer fakeCadvisor.Close()
+	fakeCadvisor.SetMachineInfo(&info.MachineInfo{NumCores: 4})
+
+	fm := NewWithFake(t, fakeCadvisor)
+	defer fm.Cleanup()
+
+	if fm.Hostname().Host != "::1" || !strings.HasPrefix(fm.Hostname().FullHostname(), "http://[::1]:") {
+		t.Errorf("Framework is testing %q, expected the fake on [::1]", fm.Hostname().FullHostname())
+	}
+	machineInfo, err := fm.Cadvisor().Client().MachineInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if machineInfo.NumCores != 4 {
+		t.Errorf("Received %d cores from the fake, expected 4", machineInfo.NumCores)
+	}
+}
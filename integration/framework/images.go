@@ -0,0 +1,225 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framework
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var keepTestImages = flag.Bool("keep_test_images", false, "Whether to keep the Docker images built by the tests at the end of the run, later runs then skip building them")
+
+// Repository of the images built by BuildTestImage().
+const testImageRepository = "cadvisor-test/"
+
+// Test images available on the hosts during this run.
+var testImages = struct {
+	sync.Mutex
+
+	// Whether the image is available, keyed by "<host> <image>".
+	available map[string]bool
+
+	// Removes the images built during this run, called by CleanupTestImages().
+	cleanups []func()
+}{
+	available: make(map[string]bool),
+}
+
+// Removes the images built by BuildTestImage() during this run, unless
+// --keep_test_images is set. Must be called once all tests finished, e.g.: in
+// the TestMain of the test package.
+func CleanupTestImages() {
+	testImages.Lock()
+	defer testImages.Unlock()
+	if !*keepTestImages {
+		for _, cleanup := range testImages.cleanups {
+			cleanup()
+		}
+	}
+	testImages.cleanups = nil
+	testImages.available = make(map[string]bool)
+}
+
+// Returns a hash of the names, modes, and contents of all the files in dir.
+func contextHash(dir string) (string, error) {
+	hash := sha256.New()
+	err := filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%s %v\n", relPath, info.Mode())
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		file, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(hash, file)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Returns the tag of the test image name built from a context with the hash.
+func testImageTag(name, hash string) string {
+	return fmt.Sprintf("%s%s:%s", testImageRepository, name, hash[:12])
+}
+
+func (self dockerActions) BuildTestImage(name, contextDir string) string {
+	hash, err := contextHash(contextDir)
+	if err != nil {
+		self.fm.T().Fatalf("Failed to hash the context %q of test image %q: %v", contextDir, name, err)
+	}
+	image := testImageTag(name, hash)
+	hostname := self.fm.Hostname()
+	key := hostname.Host + " " + image
+
+	// Tests wanting the same image wait for its build.
+	testImages.Lock()
+	defer testImages.Unlock()
+	if testImages.available[key] {
+		return image
+	}
+
+	if !self.hasImage(image) {
+		self.buildLocalImage(image, contextDir)
+		if !hostname.IsLocal() {
+			// The context is on this machine, load the image built here on the host.
+			self.pushImage(image)
+			testImages.cleanups = append(testImages.cleanups, func() {
+				removeImage(hostname, image)
+			})
+		}
+	}
+	testImages.available[key] = true
+	return image
+}
+
+// Returns whether the image exists on the host.
+func (self dockerActions) hasImage(image string) bool {
+	output, _ := self.fm.Shell().Run("sudo", "docker", "images", "-q", image)
+	return strings.TrimSpace(output) != ""
+}
+
+// Builds the image on this machine unless it is already there. testImages
+// must be locked.
+func (self dockerActions) buildLocalImage(image, contextDir string) {
+	local := HostnameInfo{Host: "localhost"}
+	key := local.Host + " " + image
+	if testImages.available[key] {
+		return
+	}
+	output, err := exec.Command("sudo", "docker", "images", "-q", image).Output()
+	if err != nil {
+		self.fm.T().Fatalf("Failed to list the local images: %v", err)
+	}
+	if strings.TrimSpace(string(output)) == "" {
+		output, err = exec.Command("sudo", "docker", "build", "-t", image, contextDir).CombinedOutput()
+		if err != nil {
+			self.fm.T().Fatalf("Failed to build test image %q from %q with error: %v. Output: %s", image, contextDir, err, output)
+		}
+		testImages.cleanups = append(testImages.cleanups, func() {
+			removeImage(local, image)
+		})
+	}
+	testImages.available[key] = true
+}
+
+// Copies the local image to the host with docker save and load.
+func (self dockerActions) pushImage(image string) {
+	dir, err := ioutil.TempDir("", "cadvisor-test-image")
+	if err != nil {
+		self.fm.T().Fatalf("Failed to create a temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	archive := path.Join(dir, "image.tar")
+	output, err := exec.Command("sudo", "docker", "save", "-o", archive, image).CombinedOutput()
+	if err != nil {
+		self.fm.T().Fatalf("Failed to save test image %q with error: %v. Output: %s", image, err, output)
+	}
+	// The archive is written by root.
+	output, err = exec.Command("sudo", "chmod", "a+r", archive).CombinedOutput()
+	if err != nil {
+		self.fm.T().Fatalf("Failed to make %q readable with error: %v. Output: %s", archive, err, output)
+	}
+
+	remoteArchive := fmt.Sprintf("/tmp/%s.tar", strings.Replace(strings.Replace(image, "/", "-", -1), ":", "-", -1))
+	self.fm.Shell().Push(archive, remoteArchive)
+	defer self.fm.Shell().Run("rm", "-f", remoteArchive)
+	self.fm.Shell().Run("sudo", "docker", "load", "-i", remoteArchive)
+}
+
+// Removes the image from the host. Only reports failures since it runs after
+// the tests.
+func removeImage(hostname HostnameInfo, image string) {
+	stdout, stderr, err := runShellCommand(hostname, "sudo", "docker", "rmi", image)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to remove test image %q from %q with error: %v. Stdout: %q, Stderr: %q\n", image, hostname.Host, err, stdout, stderr)
+	}
+}
+
+// Dockerfile of the workload image, the statically-linked workload is its
+// only content.
+const workloadDockerfile = `FROM scratch
+ADD workload /workload
+ENTRYPOINT ["/workload"]
+`
+
+const workloadPackage = "github.com/google/cadvisor/integration/workload"
+
+func (self dockerActions) WorkloadImage() string {
+	dir, err := ioutil.TempDir("", "cadvisor-workload")
+	if err != nil {
+		self.fm.T().Fatalf("Failed to create a temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(path.Join(dir, "Dockerfile"), []byte(workloadDockerfile), 0644)
+	if err != nil {
+		self.fm.T().Fatalf("Failed to write the workload's Dockerfile: %v", err)
+	}
+	cmd := exec.Command("go", "build", "-installsuffix", "cgo", "-o", path.Join(dir, "workload"), workloadPackage)
+	cmd.Env = append(os.Environ(), "CGO_ENABLED=0", "GOOS=linux")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		self.fm.T().Fatalf("Failed to build %q with error: %v. Output: %s", workloadPackage, err, output)
+	}
+	return self.BuildTestImage("workload", dir)
+}
+
+func (self dockerActions) RunWorkload(args ...string) string {
+	return self.Run(DockerRunArgs{
+		Image: self.WorkloadImage(),
+	}, args...)
+}
@@ -0,0 +1,90 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framework
+
+import (
+	"flag"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/google/cadvisor/integration/common"
+)
+
+var resultsFile = flag.String("results_file", "", "File to append the name, duration, outcome, host and cAdvisor version of each test to, as JSON lines. Empty does not record results")
+
+// Returns the name of the test using the framework, qualified by its package,
+// e.g.: api.TestDockerContainerById. Found on the stack since testing.T does
+// not expose it.
+func callingTestName() string {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(2, pcs)
+	for _, pc := range pcs[:n] {
+		f := runtime.FuncForPC(pc)
+		if f == nil {
+			continue
+		}
+		// e.g.: github.com/google/cadvisor/integration/tests/api.TestFoo.func1
+		name := f.Name()
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			name = name[i+1:]
+		}
+		parts := strings.SplitN(name, ".", 3)
+		if len(parts) >= 2 && strings.HasPrefix(parts[1], "Test") {
+			return parts[0] + "." + parts[1]
+		}
+	}
+	return "unknown"
+}
+
+// Returns the version of the cAdvisor tested, for --results_file. Best
+// effort: cAdvisor may be why the test failed.
+func (self *realFramework) cadvisorVersion() string {
+	if *resultsFile == "" {
+		return ""
+	}
+	machineInfo, err := self.Client().MachineInfo()
+	if err != nil {
+		return ""
+	}
+	return machineInfo.CadvisorVersion
+}
+
+// Appends the result of the test against the specified version of cAdvisor to
+// --results_file, if set. Called once the test cleaned up.
+func (self *realFramework) recordResult(version string) {
+	if *resultsFile == "" {
+		return
+	}
+	result := common.TestResult{
+		Test:            self.testName,
+		Start:           self.start,
+		Duration:        time.Since(self.start),
+		Result:          common.TestPassed,
+		Host:            self.hostname.Host,
+		CadvisorVersion: version,
+	}
+	if self.hostname.GceInstanceName != "" {
+		result.Host = self.hostname.GceInstanceName
+	}
+	if self.t.Failed() {
+		result.Result = common.TestFailed
+	} else if self.t.Skipped() {
+		result.Result = common.TestSkipped
+	}
+	if err := common.AppendTestResult(*resultsFile, result); err != nil {
+		self.t.Errorf("Failed to record the result of %s in %q: %v", self.testName, *resultsFile, err)
+	}
+}
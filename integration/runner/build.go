@@ -0,0 +1,183 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"runtime"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+var buildTargets = flag.String("build_targets", nativePlatform.String(), "Comma-separated list of <os>/<arch> platforms to build cAdvisor for")
+var buildImages = flag.Bool("build_images", false, "Whether to build and save a Docker image for each of the built platforms")
+
+// Platform a cAdvisor binary is built for.
+type Platform struct {
+	Os   string
+	Arch string
+}
+
+// The platform we are running on.
+var nativePlatform = Platform{
+	Os:   runtime.GOOS,
+	Arch: runtime.GOARCH,
+}
+
+// Returns: <os>/<arch>
+func (self Platform) String() string {
+	return fmt.Sprintf("%s/%s", self.Os, self.Arch)
+}
+
+// Returns: cadvisor-<os>-<arch>
+func (self Platform) BinaryName() string {
+	return fmt.Sprintf("%s-%s-%s", cadvisorBinary, self.Os, self.Arch)
+}
+
+// Returns: google/cadvisor:canary-<os>-<arch>
+func (self Platform) ImageName() string {
+	return fmt.Sprintf("google/cadvisor:canary-%s-%s", self.Os, self.Arch)
+}
+
+// Busybox images for each of the Linux architectures, keyed by GOARCH.
+var baseImages = map[string]string{
+	"386":     "i386/busybox",
+	"amd64":   "amd64/busybox",
+	"arm":     "arm32v7/busybox",
+	"arm64":   "arm64v8/busybox",
+	"ppc64le": "ppc64le/busybox",
+	"s390x":   "s390x/busybox",
+}
+
+// Returns the image the cAdvisor image of the platform is built from.
+func (self Platform) BaseImage() (string, error) {
+	image, ok := baseImages[self.Arch]
+	if self.Os != "linux" || !ok {
+		return "", fmt.Errorf("no base image for platform %s", self)
+	}
+	return image, nil
+}
+
+// Parses a comma-separated list of <os>/<arch> platforms.
+func ParsePlatforms(targets string) ([]Platform, error) {
+	platforms := []Platform{}
+	for _, target := range strings.Split(targets, ",") {
+		target = strings.TrimSpace(target)
+		if target == "" {
+			continue
+		}
+		parts := strings.Split(target, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("malformed platform %q, expected <os>/<arch>", target)
+		}
+		platforms = append(platforms, Platform{
+			Os:   parts[0],
+			Arch: parts[1],
+		})
+	}
+	if len(platforms) == 0 {
+		return nil, fmt.Errorf("no platforms specified in %q", targets)
+	}
+	return platforms, nil
+}
+
+// Runs a command with the extra environment variables in env. Variable for testing.
+var runCommand = func(env []string, cmd string, args ...string) error {
+	command := exec.Command(cmd, args...)
+	command.Env = append(os.Environ(), env...)
+	output, err := command.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("command %q %q (env: %v) failed with error: %v and output: %q", cmd, args, env, err, output)
+	}
+	return nil
+}
+
+// Builds a statically-linked cAdvisor binary for each of the platforms. The
+// binaries are named cadvisor-<os>-<arch>. cgo is disabled so that the
+// binaries are static and cross-compiling needs no C toolchain.
+func BuildCadvisor(platforms []Platform) error {
+	for _, platform := range platforms {
+		glog.Infof("Building cAdvisor for %s...", platform)
+		env := []string{
+			"GOOS=" + platform.Os,
+			"GOARCH=" + platform.Arch,
+			"CGO_ENABLED=0",
+		}
+		err := runCommand(env, "godep", "go", "build", "-a",
+			"-installsuffix", "cgo",
+			"-o", platform.BinaryName(),
+			"github.com/google/cadvisor")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const dockerfile = `FROM %s
+ADD cadvisor /usr/bin/cadvisor
+EXPOSE 8080
+ENTRYPOINT ["/usr/bin/cadvisor"]
+`
+
+// Builds a Docker image from a minimal base with the cAdvisor binary of the
+// platform and saves it to cadvisor-<os>-<arch>.tar. The binary must have
+// been built by BuildCadvisor. Busybox for the architecture of the platform
+// is used as the base since cAdvisor runs du for filesystem usage.
+func BuildImage(platform Platform) error {
+	baseImage, err := platform.BaseImage()
+	if err != nil {
+		return err
+	}
+	dir, err := ioutil.TempDir("", "cadvisor-image")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(path.Join(dir, "Dockerfile"), []byte(fmt.Sprintf(dockerfile, baseImage)), 0644)
+	if err != nil {
+		return err
+	}
+	err = runCommand(nil, "cp", platform.BinaryName(), path.Join(dir, cadvisorBinary))
+	if err != nil {
+		return err
+	}
+
+	glog.Infof("Building Docker image %q...", platform.ImageName())
+	err = runCommand(nil, "docker", "build", "-t", platform.ImageName(), dir)
+	if err != nil {
+		return err
+	}
+
+	// Check that the binary runs in the image before saving it. We can only
+	// run images built for the platform we are on.
+	if platform == nativePlatform {
+		err = runCommand(nil, "docker", "run", "--rm", platform.ImageName(), "--version")
+		if err != nil {
+			return fmt.Errorf("image %q failed validation: %v", platform.ImageName(), err)
+		}
+	} else {
+		glog.Warningf("Not validating image %q since it is not for the native platform %s", platform.ImageName(), nativePlatform)
+	}
+
+	return runCommand(nil, "docker", "save", "-o", platform.BinaryName()+".tar", platform.ImageName())
+}
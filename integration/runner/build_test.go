@@ -0,0 +1,191 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type invocation struct {
+	env  []string
+	args []string
+}
+
+// Replaces runCommand with one that records the invocations. Returns the recorded invocations and a function to restore runCommand.
+func stubRunCommand() (*[]invocation, func()) {
+	oldRunCommand := runCommand
+	invocations := []invocation{}
+	runCommand = func(env []string, cmd string, args ...string) error {
+		invocations = append(invocations, invocation{
+			env:  env,
+			args: append([]string{cmd}, args...),
+		})
+		return nil
+	}
+	return &invocations, func() {
+		runCommand = oldRunCommand
+	}
+}
+
+func TestParsePlatforms(t *testing.T) {
+	platforms, err := ParsePlatforms("linux/amd64, linux/arm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Platform{{"linux", "amd64"}, {"linux", "arm"}}
+	if !reflect.DeepEqual(platforms, expected) {
+		t.Errorf("expected platforms %v, got %v", expected, platforms)
+	}
+
+	for _, bad := range []string{"", "linux", "linux/", "linux/amd64/extra"} {
+		if _, err := ParsePlatforms(bad); err == nil {
+			t.Errorf("expected error parsing %q", bad)
+		}
+	}
+}
+
+func TestBuildCadvisor(t *testing.T) {
+	invocations, restore := stubRunCommand()
+	defer restore()
+
+	err := BuildCadvisor([]Platform{{"linux", "amd64"}, {"linux", "arm"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*invocations) != 2 {
+		t.Fatalf("expected 2 builds, got %d: %v", len(*invocations), *invocations)
+	}
+	for i, arch := range []string{"amd64", "arm"} {
+		inv := (*invocations)[i]
+		expectedEnv := []string{"GOOS=linux", "GOARCH=" + arch, "CGO_ENABLED=0"}
+		if !reflect.DeepEqual(inv.env, expectedEnv) {
+			t.Errorf("expected env %v, got %v", expectedEnv, inv.env)
+		}
+		cmd := strings.Join(inv.args, " ")
+		if !strings.HasPrefix(cmd, "godep go build") {
+			t.Errorf("expected a godep build, got %q", cmd)
+		}
+		if strings.Contains(cmd, "-extldflags") {
+			t.Errorf("did not expect the external linker to be used, got %q", cmd)
+		}
+		if !strings.Contains(cmd, "-o cadvisor-linux-"+arch) {
+			t.Errorf("expected output cadvisor-linux-%s, got %q", arch, cmd)
+		}
+	}
+}
+
+func TestBuildImage(t *testing.T) {
+	invocations, restore := stubRunCommand()
+	defer restore()
+
+	err := BuildImage(nativePlatform)
+	if err != nil {
+		t.Fatal(err)
+	}
+	image := nativePlatform.ImageName()
+	expected := []string{
+		"cp " + nativePlatform.BinaryName(),
+		"docker build -t " + image,
+		"docker run --rm " + image + " --version",
+		fmt.Sprintf("docker save -o %s.tar %s", nativePlatform.BinaryName(), image),
+	}
+	if len(*invocations) != len(expected) {
+		t.Fatalf("expected %d commands, got %d: %v", len(expected), len(*invocations), *invocations)
+	}
+	for i, prefix := range expected {
+		cmd := strings.Join((*invocations)[i].args, " ")
+		if !strings.HasPrefix(cmd, prefix) {
+			t.Errorf("expected command %d to start with %q, got %q", i, prefix, cmd)
+		}
+	}
+}
+
+func TestBuildImageUsesBaseImageOfPlatform(t *testing.T) {
+	oldRunCommand := runCommand
+	defer func() {
+		runCommand = oldRunCommand
+	}()
+	// The Dockerfile is removed once the image is built, read it during the build.
+	dockerfile := ""
+	runCommand = func(env []string, cmd string, args ...string) error {
+		if cmd == "docker" && args[0] == "build" {
+			contents, err := ioutil.ReadFile(path.Join(args[len(args)-1], "Dockerfile"))
+			if err != nil {
+				return err
+			}
+			dockerfile = string(contents)
+		}
+		return nil
+	}
+
+	err := BuildImage(Platform{"linux", "arm64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(dockerfile, "FROM arm64v8/busybox\n") {
+		t.Errorf("expected the image to be built from arm64v8/busybox, got Dockerfile %q", dockerfile)
+	}
+
+	for _, unknown := range []Platform{{"linux", "mips"}, {"darwin", "amd64"}} {
+		if err := BuildImage(unknown); err == nil {
+			t.Errorf("expected an error building an image for %s", unknown)
+		}
+	}
+}
+
+func TestBuildImageSkipsValidationForForeignPlatforms(t *testing.T) {
+	invocations, restore := stubRunCommand()
+	defer restore()
+
+	foreign := Platform{"linux", "s390x"}
+	if foreign == nativePlatform {
+		foreign.Arch = "arm64"
+	}
+	err := BuildImage(foreign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, inv := range *invocations {
+		if inv.args[0] == "docker" && inv.args[1] == "run" {
+			t.Errorf("did not expect image for %s to be run", foreign)
+		}
+	}
+	last := strings.Join((*invocations)[len(*invocations)-1].args, " ")
+	if last != fmt.Sprintf("docker save -o %s.tar %s", foreign.BinaryName(), foreign.ImageName()) {
+		t.Errorf("expected image to be saved last, got %q", last)
+	}
+}
+
+func TestBuildFailureIsReported(t *testing.T) {
+	oldRunCommand := runCommand
+	defer func() {
+		runCommand = oldRunCommand
+	}()
+	runCommand = func(env []string, cmd string, args ...string) error {
+		return fmt.Errorf("build failed")
+	}
+
+	if err := BuildCadvisor([]Platform{nativePlatform}); err == nil {
+		t.Errorf("expected build failure to be reported")
+	}
+	if err := BuildImage(nativePlatform); err == nil {
+		t.Errorf("expected image failure to be reported")
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+var createInstances = flag.Int("create_instances", 0, "Number of fresh GCE instances to create, test on, and delete instead of testing on the specified hosts")
+var instanceImage = flag.String("instance_image", "debian-7-backports", "Image to create the test instances from")
+var zone = flag.String("zone", "us-central1-f", "GCE zone to create the test instances in")
+var keepOnFailure = flag.Bool("keep_on_failure", false, "Whether to keep created instances around for debugging when their tests fail")
+var artifactsDir = flag.String("artifacts_dir", "_artifacts", "Directory to collect the logs of created instances in")
+var sshTimeout = flag.Duration("ssh_timeout", 5*time.Minute, "Time to wait for SSH to become available on created instances")
+
+// How often to check whether SSH is available on a new instance. Variable for testing.
+var sshPollInterval = 5 * time.Second
+
+// Logs collected from created instances once their tests finish.
+var artifactPaths = []string{
+	"/var/log/syslog",
+	"/var/log/docker.log",
+}
+
+// Installs Docker through the official script when it is not already present.
+const installDockerCommand = "which docker || (curl -sSL https://get.docker.com/ | sudo sh)"
+
+// Runs the tests against a host. Variable for testing.
+var pushAndRunTests = PushAndRunTests
+
+// Remote operations on GCE instances.
+type Transport interface {
+	// Creates the instance from the specified image and waits for it to be running.
+	CreateInstance(name, image string) error
+
+	// Deletes the instance along with its boot disk.
+	DeleteInstance(name string) error
+
+	// Runs the command on the host.
+	Ssh(host string, args ...string) error
+
+	// Copies the local file src to dst on the host.
+	Push(host, src, dst string) error
+
+	// Copies the file src on the host to the local dst.
+	Pull(host, src, dst string) error
+}
+
+// Transport backed by the gcutil tool. Instances are looked up in the
+// specified zone, or in any zone if none is specified.
+type gcutilTransport struct {
+	zone string
+}
+
+func (self gcutilTransport) withZone(args ...string) []string {
+	if self.zone == "" {
+		return args
+	}
+	return append(args, "--zone="+self.zone)
+}
+
+func (self gcutilTransport) CreateInstance(name, image string) error {
+	return RunCommand("gcutil", self.withZone("addinstance", name, "--image="+image, "--auto_delete_boot_disk", "--wait_until_running")...)
+}
+
+func (self gcutilTransport) DeleteInstance(name string) error {
+	return RunCommand("gcutil", self.withZone("deleteinstance", name, "--force", "--delete_boot_pd")...)
+}
+
+func (self gcutilTransport) Ssh(host string, args ...string) error {
+	return RunCommand("gcutil", append(self.withZone("ssh", host), args...)...)
+}
+
+func (self gcutilTransport) Push(host, src, dst string) error {
+	return RunCommand("gcutil", self.withZone("push", host, src, dst)...)
+}
+
+func (self gcutilTransport) Pull(host, src, dst string) error {
+	return RunCommand("gcutil", self.withZone("pull", host, src, dst)...)
+}
+
+// Returns the names of count instances for this run. Names include the start
+// time of the run and the runner's PID so parallel runs do not collide.
+func InstanceNames(runStart time.Time, count int) []string {
+	names := make([]string, 0, count)
+	runId := fmt.Sprintf("%s-%d", runStart.UTC().Format("20060102-150405"), os.Getpid())
+	for i := 0; i < count; i++ {
+		names = append(names, fmt.Sprintf("cadvisor-test-%s-%d", runId, i))
+	}
+	return names
+}
+
+// Creates the instance, prepares it, runs the tests on it, and deletes it.
+// The instance is deleted no matter which step fails unless keep_on_failure
+// is set, in which case it is only deleted if the tests pass.
+func RunOnNewInstance(transport Transport, name, testDir, binary string) (err error) {
+	defer func() {
+		if err != nil && *keepOnFailure {
+			glog.Warningf("Keeping instance %q after failure: %v", name, err)
+			return
+		}
+		glog.Infof("Deleting instance %q...", name)
+		deleteErr := transport.DeleteInstance(name)
+		if deleteErr != nil {
+			glog.Errorf("Failed to delete instance %q: %v", name, deleteErr)
+			if err == nil {
+				err = deleteErr
+			}
+		}
+	}()
+
+	glog.Infof("Creating instance %q from image %q...", name, *instanceImage)
+	err = transport.CreateInstance(name, *instanceImage)
+	if err != nil {
+		return err
+	}
+	err = waitForSsh(transport, name)
+	if err != nil {
+		return err
+	}
+	glog.Infof("Installing Docker on %q...", name)
+	err = transport.Ssh(name, installDockerCommand)
+	if err != nil {
+		return err
+	}
+
+	err = pushAndRunTests(transport, name, testDir, binary)
+	collectArtifacts(transport, name)
+	return err
+}
+
+// Waits for the instance to accept SSH connections.
+func waitForSsh(transport Transport, name string) error {
+	endTime := time.Now().Add(*sshTimeout)
+	for {
+		err := transport.Ssh(name, "true")
+		if err == nil {
+			return nil
+		}
+		if !time.Now().Before(endTime) {
+			return fmt.Errorf("timed out waiting for SSH on instance %q: %v", name, err)
+		}
+		time.Sleep(sshPollInterval)
+	}
+}
+
+// Copies the artifacts from the instance to <artifacts_dir>/<instance>.
+// Failures are logged since missing logs should not fail the run.
+func collectArtifacts(transport Transport, name string) {
+	dir := path.Join(*artifactsDir, name)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		glog.Errorf("Failed to create artifacts directory %q: %v", dir, err)
+		return
+	}
+	for _, artifact := range artifactPaths {
+		err := transport.Pull(name, artifact, path.Join(dir, path.Base(artifact)))
+		if err != nil {
+			glog.Warningf("Failed to collect %q from instance %q: %v", artifact, name, err)
+		}
+	}
+}
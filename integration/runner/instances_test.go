@@ -0,0 +1,228 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Replaces runCommand with one that records the invocations and fails those starting with any of the prefixes. Also stubs the test run and points the artifacts to a temporary directory. Returns the recorded invocations and a function to restore everything.
+func stubInstanceCommands(t *testing.T, testsErr error, failingPrefixes ...string) (*[]string, func()) {
+	oldRunCommand := runCommand
+	oldPushAndRunTests := pushAndRunTests
+	oldArtifactsDir := *artifactsDir
+	oldSshPollInterval := sshPollInterval
+	dir, err := ioutil.TempDir("", "runner-artifacts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	*artifactsDir = dir
+	sshPollInterval = time.Millisecond
+
+	commands := []string{}
+	runCommand = func(env []string, cmd string, args ...string) error {
+		command := strings.Join(append([]string{cmd}, args...), " ")
+		commands = append(commands, command)
+		for _, prefix := range failingPrefixes {
+			if strings.HasPrefix(command, prefix) {
+				return fmt.Errorf("%q failed", command)
+			}
+		}
+		return nil
+	}
+	pushAndRunTests = func(transport Transport, host, testDir, binary string) error {
+		commands = append(commands, "tests "+host)
+		return testsErr
+	}
+	return &commands, func() {
+		runCommand = oldRunCommand
+		pushAndRunTests = oldPushAndRunTests
+		*artifactsDir = oldArtifactsDir
+		sshPollInterval = oldSshPollInterval
+		os.RemoveAll(dir)
+	}
+}
+
+// Returns the index of the first command starting with prefix, or -1.
+func commandIndex(commands []string, prefix string) int {
+	for i, command := range commands {
+		if strings.HasPrefix(command, prefix) {
+			return i
+		}
+	}
+	return -1
+}
+
+func setKeepOnFailure(keep bool) func() {
+	old := *keepOnFailure
+	*keepOnFailure = keep
+	return func() {
+		*keepOnFailure = old
+	}
+}
+
+var testTransport = gcutilTransport{zone: "test-zone"}
+
+func TestInstanceNames(t *testing.T) {
+	start := time.Date(2015, 3, 4, 5, 6, 7, 0, time.UTC)
+	names := InstanceNames(start, 2)
+	if len(names) != 2 {
+		t.Fatalf("expected 2 names, got %v", names)
+	}
+	prefix := fmt.Sprintf("cadvisor-test-20150304-050607-%d-", os.Getpid())
+	for i, name := range names {
+		if name != fmt.Sprintf("%s%d", prefix, i) {
+			t.Errorf("expected name %d to be %s%d, got %q", i, prefix, i, name)
+		}
+	}
+}
+
+func TestRunOnNewInstance(t *testing.T) {
+	commands, restore := stubInstanceCommands(t, nil)
+	defer restore()
+
+	err := RunOnNewInstance(testTransport, "instance", "/tmp/test", "cadvisor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"gcutil addinstance instance --image=" + *instanceImage,
+		"gcutil ssh instance --zone=test-zone true",
+		"gcutil ssh instance --zone=test-zone " + installDockerCommand,
+		"tests instance",
+		"gcutil pull instance /var/log/syslog",
+		"gcutil deleteinstance instance",
+	}
+	last := -1
+	for _, prefix := range expected {
+		i := commandIndex(*commands, prefix)
+		if i <= last {
+			t.Fatalf("expected %q after the previous steps, got commands %v", prefix, *commands)
+		}
+		last = i
+	}
+	if last != len(*commands)-1 {
+		t.Errorf("expected the instance to be deleted last, got commands %v", *commands)
+	}
+	if !strings.Contains((*commands)[0], "--zone=test-zone") {
+		t.Errorf("expected the instance to be created in the zone, got %q", (*commands)[0])
+	}
+}
+
+func TestRunOnNewInstanceDeletesOnFailure(t *testing.T) {
+	defer setKeepOnFailure(false)()
+	for _, failing := range []string{"gcutil addinstance", "gcutil ssh instance --zone=test-zone " + installDockerCommand} {
+		commands, restore := stubInstanceCommands(t, nil, failing)
+		err := RunOnNewInstance(testTransport, "instance", "/tmp/test", "cadvisor")
+		if err == nil {
+			t.Errorf("expected failure of %q to be reported", failing)
+		}
+		if commandIndex(*commands, "tests") != -1 {
+			t.Errorf("expected tests not to run after %q failed, got commands %v", failing, *commands)
+		}
+		if commandIndex(*commands, "gcutil deleteinstance instance") != len(*commands)-1 {
+			t.Errorf("expected the instance to be deleted after %q failed, got commands %v", failing, *commands)
+		}
+		restore()
+	}
+
+	commands, restore := stubInstanceCommands(t, fmt.Errorf("tests failed"))
+	defer restore()
+	err := RunOnNewInstance(testTransport, "instance", "/tmp/test", "cadvisor")
+	if err == nil || err.Error() != "tests failed" {
+		t.Errorf("expected the test failure to be reported, got %v", err)
+	}
+	if commandIndex(*commands, "gcutil pull") == -1 {
+		t.Errorf("expected artifacts to be collected after the tests failed, got commands %v", *commands)
+	}
+	if commandIndex(*commands, "gcutil deleteinstance instance") != len(*commands)-1 {
+		t.Errorf("expected the instance to be deleted after the tests failed, got commands %v", *commands)
+	}
+}
+
+func TestRunOnNewInstanceKeepOnFailure(t *testing.T) {
+	defer setKeepOnFailure(true)()
+
+	commands, restore := stubInstanceCommands(t, fmt.Errorf("tests failed"))
+	defer restore()
+	err := RunOnNewInstance(testTransport, "instance", "/tmp/test", "cadvisor")
+	if err == nil {
+		t.Errorf("expected the test failure to be reported")
+	}
+	if commandIndex(*commands, "gcutil deleteinstance") != -1 {
+		t.Errorf("expected the failed instance to be kept, got commands %v", *commands)
+	}
+
+	// Instances that pass are still deleted.
+	*commands = (*commands)[:0]
+	pushAndRunTests = func(transport Transport, host, testDir, binary string) error {
+		return nil
+	}
+	err = RunOnNewInstance(testTransport, "instance", "/tmp/test", "cadvisor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commandIndex(*commands, "gcutil deleteinstance instance") != len(*commands)-1 {
+		t.Errorf("expected the passing instance to be deleted, got commands %v", *commands)
+	}
+}
+
+func TestRunOnNewInstanceReportsDeleteFailure(t *testing.T) {
+	_, restore := stubInstanceCommands(t, nil, "gcutil deleteinstance")
+	defer restore()
+
+	err := RunOnNewInstance(testTransport, "instance", "/tmp/test", "cadvisor")
+	if err == nil {
+		t.Errorf("expected failure to delete the instance to be reported")
+	}
+}
+
+func TestWaitForSshRetries(t *testing.T) {
+	_, restore := stubInstanceCommands(t, nil)
+	defer restore()
+	attempts := 0
+	runCommand = func(env []string, cmd string, args ...string) error {
+		attempts++
+		if attempts < 3 {
+			return fmt.Errorf("connection refused")
+		}
+		return nil
+	}
+
+	err := waitForSsh(testTransport, "instance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+
+	oldSshTimeout := *sshTimeout
+	defer func() {
+		*sshTimeout = oldSshTimeout
+	}()
+	*sshTimeout = 10 * time.Millisecond
+	runCommand = func(env []string, cmd string, args ...string) error {
+		return fmt.Errorf("connection refused")
+	}
+	if err := waitForSsh(testTransport, "instance"); err == nil {
+		t.Errorf("expected timeout waiting for SSH")
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/integration/common"
+)
+
+// Number of the slowest tests listed by the summary of a run.
+const slowestTestsShown = 10
+
+// Returns the absolute path of the file the tests against the host record
+// their results in. The tests run in the directory of their package.
+func resultsPath(host string) (string, error) {
+	return filepath.Abs(path.Join(*artifactsDir, host, "results.jsonl"))
+}
+
+// Reads and concatenates the results files of the specified hosts. Hosts
+// whose tests did not get to run have no results file and are skipped.
+func MergeResults(files []string) ([]common.TestResult, error) {
+	var results []common.TestResult
+	for _, file := range files {
+		hostResults, err := common.ReadTestResults(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		results = append(results, hostResults...)
+	}
+	return results, nil
+}
+
+type byDuration []common.TestResult
+
+func (self byDuration) Len() int           { return len(self) }
+func (self byDuration) Swap(i, j int)      { self[i], self[j] = self[j], self[i] }
+func (self byDuration) Less(i, j int) bool { return self[i].Duration > self[j].Duration }
+
+// Summarizes the results of a run: the number of tests by outcome, the
+// slowest tests and the failed ones, with the host they ran against.
+func SummarizeResults(results []common.TestResult) string {
+	counts := make(map[string]int)
+	var failed []common.TestResult
+	for _, result := range results {
+		counts[result.Result]++
+		if result.Result == common.TestFailed {
+			failed = append(failed, result)
+		}
+	}
+
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "%d tests: %d passed, %d failed, %d skipped\n", len(results), counts[common.TestPassed], counts[common.TestFailed], counts[common.TestSkipped])
+	if len(results) == 0 {
+		return buffer.String()
+	}
+
+	slowest := append([]common.TestResult(nil), results...)
+	sort.Stable(byDuration(slowest))
+	if len(slowest) > slowestTestsShown {
+		slowest = slowest[:slowestTestsShown]
+	}
+	fmt.Fprintf(&buffer, "\nSlowest tests:\n")
+	tw := tabwriter.NewWriter(&buffer, 0, 8, 2, ' ', 0)
+	for _, result := range slowest {
+		fmt.Fprintf(tw, "%v\t%s\t%s\t%s\n", result.Duration, result.Test, result.Host, result.Result)
+	}
+	tw.Flush()
+
+	if len(failed) != 0 {
+		fmt.Fprintf(&buffer, "\nFailed tests:\n")
+		tw = tabwriter.NewWriter(&buffer, 0, 8, 2, ' ', 0)
+		for _, result := range failed {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", result.Test, result.Host, result.CadvisorVersion)
+		}
+		tw.Flush()
+	}
+	return buffer.String()
+}
+
+// Merges the results files of the hosts into <artifacts_dir>/results.jsonl
+// and logs their summary. Failures are logged since the results are only
+// informative.
+func reportResults(hosts []string) {
+	files := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		file, err := resultsPath(host)
+		if err != nil {
+			glog.Errorf("Failed to locate the results of host %q: %v", host, err)
+			continue
+		}
+		files = append(files, file)
+	}
+	results, err := MergeResults(files)
+	if err != nil {
+		glog.Errorf("Failed to merge the test results: %v", err)
+		return
+	}
+	merged := path.Join(*artifactsDir, "results.jsonl")
+	os.Remove(merged)
+	for _, result := range results {
+		if err := common.AppendTestResult(merged, result); err != nil {
+			glog.Errorf("Failed to write the merged test results to %q: %v", merged, err)
+			break
+		}
+	}
+	glog.Infof("Test results, merged in %q:\n%s", merged, SummarizeResults(results))
+}
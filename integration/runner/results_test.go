@@ -0,0 +1,117 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/integration/common"
+)
+
+func TestMergeResults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := []string{path.Join(dir, "a.jsonl"), path.Join(dir, "missing.jsonl"), path.Join(dir, "b.jsonl")}
+	for _, result := range []struct {
+		file string
+		host string
+	}{
+		{files[0], "a"},
+		{files[0], "a"},
+		{files[2], "b"},
+	} {
+		if err := common.AppendTestResult(result.file, common.TestResult{Test: "api.TestFoo", Host: result.host}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := MergeResults(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := make([]string, len(results))
+	for i, result := range results {
+		hosts[i] = result.Host
+	}
+	if strings.Join(hosts, ",") != "a,a,b" {
+		t.Errorf("Expected the results of hosts a,a,b, got %v", hosts)
+	}
+}
+
+func TestMergeResultsInvalidFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := path.Join(dir, "a.jsonl")
+	if err := ioutil.WriteFile(file, []byte("not json\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MergeResults([]string{file}); err == nil {
+		t.Error("Expected an error merging an invalid results file")
+	}
+}
+
+func TestSummarizeResults(t *testing.T) {
+	var results []common.TestResult
+	for i := 0; i < slowestTestsShown+2; i++ {
+		results = append(results, common.TestResult{
+			Test:     "api.TestFast",
+			Duration: time.Duration(i) * time.Millisecond,
+			Result:   common.TestPassed,
+			Host:     "a",
+		})
+	}
+	results = append(results,
+		common.TestResult{Test: "api.TestSlow", Duration: time.Minute, Result: common.TestFailed, Host: "b", CadvisorVersion: "0.18.0"},
+		common.TestResult{Test: "api.TestSkipped", Result: common.TestSkipped, Host: "a"},
+	)
+
+	summary := SummarizeResults(results)
+	if !strings.HasPrefix(summary, "14 tests: 12 passed, 1 failed, 1 skipped\n") {
+		t.Errorf("Expected the counts first, got %q", summary)
+	}
+	sections := strings.Split(summary, "\nFailed tests:\n")
+	if len(sections) != 2 {
+		t.Fatalf("Expected a listing of the failed tests, got %q", summary)
+	}
+	slowest := strings.Split(strings.TrimSpace(strings.SplitN(sections[0], "Slowest tests:\n", 2)[1]), "\n")
+	if len(slowest) != slowestTestsShown {
+		t.Errorf("Expected %d slowest tests, got %q", slowestTestsShown, slowest)
+	}
+	if !strings.HasPrefix(slowest[0], "1m0s") || !strings.Contains(slowest[0], "api.TestSlow") {
+		t.Errorf("Expected the slowest test first, got %q", slowest[0])
+	}
+	if failed := strings.Fields(sections[1]); strings.Join(failed, " ") != "api.TestSlow b 0.18.0" {
+		t.Errorf("Expected the failed test with its host and version, got %q", sections[1])
+	}
+}
+
+func TestSummarizeNoResults(t *testing.T) {
+	if summary := SummarizeResults(nil); summary != "0 tests: 0 passed, 0 failed, 0 skipped\n" {
+		t.Errorf("Unexpected summary %q", summary)
+	}
+}
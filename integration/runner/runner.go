@@ -19,9 +19,9 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
-	"os/exec"
 	"path"
 	"strconv"
 	"strings"
@@ -38,28 +38,23 @@ var cadvisorTimeout = flag.Duration("cadvisor_timeout", 15*time.Second, "Time to
 var port = flag.Int("port", 8080, "Port in which to start cAdvisor in the remote host")
 
 func RunCommand(cmd string, args ...string) error {
-	output, err := exec.Command(cmd, args...).CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("command %q %q failed with error: %v and output: %q", cmd, args, err, output)
-	}
-
-	return nil
+	return runCommand(nil, cmd, args...)
 }
 
-func PushAndRunTests(host, testDir string) error {
+func PushAndRunTests(transport Transport, host, testDir, binary string) error {
 	// Push binary.
 	glog.Infof("Pushing cAdvisor binary to %q...", host)
-	err := RunCommand("gcutil", "ssh", host, "mkdir", "-p", testDir)
+	err := transport.Ssh(host, "mkdir", "-p", testDir)
 	if err != nil {
 		return err
 	}
 	defer func() {
-		err := RunCommand("gcutil", "ssh", host, "rm", "-rf", testDir)
+		err := transport.Ssh(host, "rm", "-rf", testDir)
 		if err != nil {
 			glog.Error(err)
 		}
 	}()
-	err = RunCommand("gcutil", "push", host, cadvisorBinary, testDir)
+	err = transport.Push(host, binary, testDir)
 	if err != nil {
 		return err
 	}
@@ -70,13 +65,13 @@ func PushAndRunTests(host, testDir string) error {
 	portStr := strconv.Itoa(*port)
 	errChan := make(chan error)
 	go func() {
-		err = RunCommand("gcutil", "ssh", host, "sudo", path.Join(testDir, cadvisorBinary), "--port", portStr, "--logtostderr")
+		err = transport.Ssh(host, "sudo", path.Join(testDir, binary), "--port", portStr, "--logtostderr", "--monitor_system_services", "--enable_cpu_sampling", "--cpu_sampling_interval=1s", "--memory_pressure_duration=5s", "--self_check", "--self_check_interval=5s", "--enable_debug_endpoints", "--leak_tracking_sample_rate=100")
 		if err != nil {
 			errChan <- err
 		}
 	}()
 	defer func() {
-		err := RunCommand("gcutil", "ssh", host, "sudo", "pkill", cadvisorBinary)
+		err := transport.Ssh(host, "sudo", "pkill", cadvisorBinary)
 		if err != nil {
 			glog.Error(err)
 		}
@@ -97,7 +92,7 @@ func PushAndRunTests(host, testDir string) error {
 			return err
 		case <-time.After(500 * time.Millisecond):
 			// Stop waiting when cAdvisor is healthy..
-			resp, err := http.Get(fmt.Sprintf("http://%s:%s/healthz", ipAddress, portStr))
+			resp, err := http.Get(fmt.Sprintf("http://%s/healthz", net.JoinHostPort(ipAddress, portStr)))
 			if err == nil && resp.StatusCode == http.StatusOK {
 				done = true
 				break
@@ -108,9 +103,18 @@ func PushAndRunTests(host, testDir string) error {
 		return fmt.Errorf("timed out waiting for cAdvisor to come up at host %q", host)
 	}
 
-	// Run the tests.
+	// Run the tests, recording their results for the summary of the run.
+	results, err := resultsPath(host)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(path.Dir(results), 0755)
+	if err != nil {
+		return err
+	}
+	os.Remove(results)
 	glog.Infof("Running integration tests targeting %q...", host)
-	err = RunCommand("godep", "go", "test", "github.com/google/cadvisor/integration/tests/...", "--host", host, "--port", portStr)
+	err = RunCommand("godep", "go", "test", "github.com/google/cadvisor/integration/tests/...", "--host", host, "--port", portStr, "--results_file", results)
 	if err != nil {
 		return err
 	}
@@ -126,23 +130,51 @@ func Run() error {
 	defer glog.Flush()
 
 	hosts := flag.Args()
+	if *createInstances > 0 {
+		hosts = InstanceNames(start, *createInstances)
+	}
 	testDir := fmt.Sprintf("/tmp/cadvisor-%d", os.Getpid())
 	glog.Infof("Running integration tests on host(s) %q", strings.Join(hosts, ","))
 
-	// Build cAdvisor.
-	glog.Infof("Building cAdvisor...")
-	err := RunCommand("godep", "go", "build", "github.com/google/cadvisor")
+	// Build cAdvisor. Tests run against the binary for the native platform.
+	platforms, err := ParsePlatforms(*buildTargets)
+	if err != nil {
+		return err
+	}
+	hasNative := false
+	for _, platform := range platforms {
+		hasNative = hasNative || platform == nativePlatform
+	}
+	if !hasNative {
+		platforms = append(platforms, nativePlatform)
+	}
+	err = BuildCadvisor(platforms)
 	if err != nil {
 		return err
 	}
 	defer func() {
-		err := RunCommand("rm", cadvisorBinary)
-		if err != nil {
-			glog.Error(err)
+		for _, platform := range platforms {
+			err := RunCommand("rm", platform.BinaryName())
+			if err != nil {
+				glog.Error(err)
+			}
 		}
 	}()
+	if *buildImages {
+		for _, platform := range platforms {
+			err = BuildImage(platform)
+			if err != nil {
+				return err
+			}
+		}
+	}
 
-	// Run test on all hosts in parallel.
+	// Run test on all hosts in parallel. Created instances use their zone,
+	// existing hosts are looked up.
+	transport := gcutilTransport{}
+	if *createInstances > 0 {
+		transport.zone = *zone
+	}
 	var wg sync.WaitGroup
 	allErrors := make([]error, 0)
 	var allErrorsLock sync.Mutex
@@ -150,7 +182,12 @@ func Run() error {
 		wg.Add(1)
 		go func(host string) {
 			defer wg.Done()
-			err := PushAndRunTests(host, testDir)
+			var err error
+			if *createInstances > 0 {
+				err = RunOnNewInstance(transport, host, testDir, nativePlatform.BinaryName())
+			} else {
+				err = PushAndRunTests(transport, host, testDir, nativePlatform.BinaryName())
+			}
 			if err != nil {
 				func() {
 					allErrorsLock.Lock()
@@ -161,6 +198,7 @@ func Run() error {
 		}(host)
 	}
 	wg.Wait()
+	reportResults(hosts)
 
 	if len(allErrors) != 0 {
 		var buffer bytes.Buffer
@@ -180,8 +218,8 @@ func main() {
 	flag.Parse()
 
 	// Check usage.
-	if len(flag.Args()) == 0 {
-		glog.Fatalf("USAGE: runner <hosts to test>")
+	if len(flag.Args()) == 0 && *createInstances == 0 {
+		glog.Fatalf("USAGE: runner <hosts to test> or runner -create_instances=<number of instances>")
 	}
 
 	// Run the tests.
@@ -0,0 +1,143 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/integration/framework"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The CPU usage of a container is attributed to the names of its processes
+// (cAdvisor runs with --enable_cpu_sampling).
+func TestProcessCpuSampling(t *testing.T) {
+	fm := framework.New(t)
+	defer fm.Cleanup()
+
+	name := fmt.Sprintf("/test-cpu-sampling-%d", os.Getpid())
+	containers := makeSleepContainers(fm, name)
+	defer containers.Cleanup()
+	containers.RunBusyLoop(name, "busy-one")
+	containers.RunBusyLoop(name, "busy-two")
+
+	err := framework.RetryForDuration(func() error {
+		containerInfo, err := fm.Cadvisor().Client().ContainerInfo(name, &info.ContainerInfoRequest{NumStats: 1})
+		if err != nil {
+			return err
+		}
+		if len(containerInfo.Stats) != 1 || containerInfo.Stats[0].ProcessCpu == nil {
+			return fmt.Errorf("no process CPU usage returned for %q", name)
+		}
+		usage := make(map[string]uint64)
+		for _, process := range containerInfo.Stats[0].ProcessCpu.Top {
+			usage[process.Name] = process.Total
+		}
+		for _, process := range []string{"busy-one", "busy-two"} {
+			if usage[process] == 0 {
+				return fmt.Errorf("no CPU usage attributed to %q in %q: %+v", process, name, containerInfo.Stats[0].ProcessCpu)
+			}
+		}
+		return nil
+	}, 30*time.Second)
+	require.NoError(t, err, "Timed out waiting for the CPU usage of the busy loops")
+}
+
+// Zombies of a container are counted by the same sampling.
+func TestProcessStatesCountZombies(t *testing.T) {
+	fm := framework.New(t)
+	defer fm.Cleanup()
+
+	name := fmt.Sprintf("/test-process-states-%d", os.Getpid())
+	containers := makeSleepContainers(fm, name)
+	defer containers.Cleanup()
+	containers.RunZombie(name)
+
+	err := framework.RetryForDuration(func() error {
+		containerInfo, err := fm.Cadvisor().Client().ContainerInfo(name, &info.ContainerInfoRequest{NumStats: 1})
+		if err != nil {
+			return err
+		}
+		if len(containerInfo.Stats) != 1 || containerInfo.Stats[0].ProcessStates == nil {
+			return fmt.Errorf("no process states returned for %q", name)
+		}
+		if states := containerInfo.Stats[0].ProcessStates; states.NrZombie != 1 {
+			return fmt.Errorf("expected 1 zombie in %q, found %+v", name, states)
+		}
+		return nil
+	}, 30*time.Second)
+	require.NoError(t, err, "Timed out waiting for the zombie to be counted")
+}
+
+// Returns the involuntary context switches per second of the processes of the
+// container between its two latest CPU samplings.
+func involuntarySwitchRate(fm framework.Framework, name string) (float64, error) {
+	containerInfo, err := fm.Cadvisor().Client().ContainerInfo(name, &info.ContainerInfoRequest{NumStats: 1})
+	if err != nil {
+		return 0, err
+	}
+	if len(containerInfo.Stats) != 1 || containerInfo.Stats[0].Cpu.ContextSwitches == nil {
+		return 0, fmt.Errorf("no context switches returned for %q", name)
+	}
+	switches := containerInfo.Stats[0].Cpu.ContextSwitches
+	if switches.Interval <= 0 {
+		return 0, fmt.Errorf("only one sampling of the context switches of %q", name)
+	}
+	return float64(switches.IntervalInvoluntary) / switches.Interval.Seconds(), nil
+}
+
+// Processes contending for a CPU are preempted more often than a process
+// alone on its CPU, which the context switches of their containers show.
+func TestContextSwitchesShowContention(t *testing.T) {
+	fm := framework.New(t)
+	defer fm.Cleanup()
+
+	machineInfo, err := fm.Cadvisor().Client().MachineInfo()
+	require.NoError(t, err)
+	if machineInfo.NumCores < 2 {
+		t.Skipf("Needs 2 cores to keep the containers apart, the machine has %d", machineInfo.NumCores)
+	}
+	root := fmt.Sprintf("/test-context-switches-%d", os.Getpid())
+	uncontended := root + "/uncontended"
+	contended := root + "/contended"
+	containers := makeSleepContainers(fm, uncontended, contended)
+	defer containers.Cleanup()
+	containers.RunPinnedBusyLoop(uncontended, "alone", machineInfo.NumCores-1)
+	for i := 0; i < 4; i++ {
+		containers.RunPinnedBusyLoop(contended, "crowded", 0)
+	}
+
+	var uncontendedRate, contendedRate float64
+	err = framework.RetryForDuration(func() error {
+		var err error
+		if uncontendedRate, err = involuntarySwitchRate(fm, uncontended); err != nil {
+			return err
+		}
+		if contendedRate, err = involuntarySwitchRate(fm, contended); err != nil {
+			return err
+		}
+		if contendedRate <= uncontendedRate {
+			return fmt.Errorf("%q has %v involuntary context switches per second, no more than the %v of %q", contended, contendedRate, uncontendedRate, uncontended)
+		}
+		return nil
+	}, 30*time.Second)
+	// Other processes may run on the CPU of the uncontended container.
+	assert.NoError(t, err, "Expected more involuntary context switches in the contended container")
+}
@@ -18,9 +18,11 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/cadvisor/client"
 	info "github.com/google/cadvisor/info/v1"
 	"github.com/google/cadvisor/integration/framework"
 	"github.com/stretchr/testify/assert"
@@ -37,8 +39,8 @@ func sanityCheck(alias string, containerInfo info.ContainerInfo, t *testing.T) {
 
 // Waits up to 5s for a container with the specified alias to appear.
 func waitForContainer(alias string, fm framework.Framework) {
-	err := framework.RetryForDuration(func() error {
-		ret, err := fm.Cadvisor().Client().DockerContainer(alias, &info.ContainerInfoRequest{
+	err := framework.RetryWithContext(func(ctx client.Context) error {
+		ret, err := fm.Cadvisor().Client().DockerContainerCtx(ctx, alias, &info.ContainerInfoRequest{
 			NumStats: 1,
 		})
 		if err != nil {
@@ -170,13 +172,15 @@ func TestDockerContainerSpec(t *testing.T) {
 
 	cpuShares := uint64(2048)
 	cpuMask := "0"
-	memoryLimit := uint64(1 << 30) // 1GB
+	memoryLimit := uint64(1 << 30)     // 1GB
+	memorySwapLimit := uint64(2 << 30) // 2GB
 	containerId := fm.Docker().Run(framework.DockerRunArgs{
 		Image: "kubernetes/pause",
 		Args: []string{
 			"--cpu-shares", strconv.FormatUint(cpuShares, 10),
 			"--cpuset", cpuMask,
 			"--memory", strconv.FormatUint(memoryLimit, 10),
+			"--memory-swap", strconv.FormatUint(memorySwapLimit, 10),
 		},
 	})
 
@@ -197,10 +201,149 @@ func TestDockerContainerSpec(t *testing.T) {
 	assert.Equal(containerInfo.Spec.Cpu.Mask, cpuMask, "Cpu mask should be %q, but is %q", cpuMask, containerInfo.Spec.Cpu.Mask)
 	assert.True(containerInfo.Spec.HasMemory, "Memory should be isolated")
 	assert.Equal(containerInfo.Spec.Memory.Limit, memoryLimit, "Container should have memory limit of %d, has %d", memoryLimit, containerInfo.Spec.Memory.Limit)
+	// The swap limit is only known when swap accounting is enabled.
+	if containerInfo.Spec.Memory.SwapLimit != nil {
+		assert.Equal(*containerInfo.Spec.Memory.SwapLimit, memorySwapLimit, "Container should have memory+swap limit of %d, has %d", memorySwapLimit, *containerInfo.Spec.Memory.SwapLimit)
+	}
+	assert.NotNil(containerInfo.Spec.Memory.Swappiness, "Container should have a swappiness")
+	if assert.NotNil(containerInfo.Spec.Memory.OomKillDisable, "Container should have an OOM killer setting") {
+		assert.False(*containerInfo.Spec.Memory.OomKillDisable, "Container should not have the OOM killer disabled")
+	}
 	assert.True(containerInfo.Spec.HasNetwork, "Network should be isolated")
 	assert.True(containerInfo.Spec.HasDiskIo, "Blkio should be isolated")
 }
 
+// Check the limits of the container's init process.
+func TestDockerContainerProcessLimits(t *testing.T) {
+	fm := framework.New(t)
+	defer fm.Cleanup()
+
+	openFiles := uint64(512)
+	containerId := fm.Docker().Run(framework.DockerRunArgs{
+		Image: "kubernetes/pause",
+		Args: []string{
+			"--ulimit", fmt.Sprintf("nofile=%d:%d", openFiles, openFiles),
+		},
+	})
+
+	// Wait for the container to show up.
+	waitForContainer(containerId, fm)
+
+	request := &info.ContainerInfoRequest{
+		NumStats: 1,
+	}
+	containerInfo, err := fm.Cadvisor().Client().DockerContainer(containerId, request)
+	require.NoError(t, err)
+	sanityCheck(containerId, containerInfo, t)
+
+	limits := containerInfo.Spec.ProcessLimits
+	require.NotNil(t, limits, "Process limits should be reported")
+	assert.Equal(t, openFiles, limits.OpenFiles.Soft, "Soft open files limit should be %d, is %d", openFiles, limits.OpenFiles.Soft)
+	assert.Equal(t, openFiles, limits.OpenFiles.Hard, "Hard open files limit should be %d, is %d", openFiles, limits.OpenFiles.Hard)
+}
+
+// Check the pids limit and usage of the container.
+func TestDockerContainerPidsLimit(t *testing.T) {
+	fm := framework.New(t)
+	defer fm.Cleanup()
+
+	pidsLimit := uint64(50)
+	containerId := fm.Docker().Run(framework.DockerRunArgs{
+		Image: "kubernetes/pause",
+		Args: []string{
+			"--pids-limit", fmt.Sprintf("%d", pidsLimit),
+		},
+	})
+
+	// Wait for the container to show up.
+	waitForContainer(containerId, fm)
+
+	request := &info.ContainerInfoRequest{
+		NumStats: 1,
+	}
+	containerInfo, err := fm.Cadvisor().Client().DockerContainer(containerId, request)
+	require.NoError(t, err)
+	sanityCheck(containerId, containerInfo, t)
+
+	if !containerInfo.Spec.HasPids {
+		t.Skip("The pids cgroup is not mounted on this machine")
+	}
+	require.NotNil(t, containerInfo.Spec.PidsLimit, "Pids limit should be reported")
+	assert.Equal(t, pidsLimit, *containerInfo.Spec.PidsLimit, "Pids limit should be %d, is %d", pidsLimit, *containerInfo.Spec.PidsLimit)
+
+	pids := containerInfo.Stats[0].Pids
+	require.NotNil(t, pids, "Pids stats should be reported")
+	assert.True(t, pids.Current >= 1, "Pids should count at least the pause process, are %d", pids.Current)
+	assert.True(t, pids.Current <= pidsLimit, "Pids should be within the limit of %d, are %d", pidsLimit, pids.Current)
+}
+
+// Check the write throttle limit of the container on a disk of the machine.
+func TestDockerContainerBlkioThrottle(t *testing.T) {
+	fm := framework.New(t)
+	defer fm.Cleanup()
+
+	machineInfo, err := fm.Cadvisor().Client().MachineInfo()
+	require.NoError(t, err)
+	var disk *info.DiskInfo
+	for _, d := range machineInfo.DiskMap {
+		disk = &d
+		break
+	}
+	if disk == nil {
+		t.Skip("The machine has no disks")
+	}
+
+	writeBps := uint64(1024 * 1024)
+	containerId := fm.Docker().Run(framework.DockerRunArgs{
+		Image: "kubernetes/pause",
+		Args: []string{
+			"--device-write-bps", fmt.Sprintf("/dev/%s:1mb", disk.Name),
+		},
+	})
+
+	// Wait for the container to show up.
+	waitForContainer(containerId, fm)
+
+	request := &info.ContainerInfoRequest{
+		NumStats: 1,
+	}
+	containerInfo, err := fm.Cadvisor().Client().DockerContainer(containerId, request)
+	require.NoError(t, err)
+	sanityCheck(containerId, containerInfo, t)
+
+	blkio := containerInfo.Spec.Blkio
+	require.NotNil(t, blkio, "Blkio spec should be reported")
+	device := fmt.Sprintf("%d:%d", disk.Major, disk.Minor)
+	limits, ok := blkio.Devices[device]
+	require.True(t, ok, "Limits of device %s should be reported, got %+v", device, blkio.Devices)
+	assert.Equal(t, writeBps, limits.WriteBps, "Write limit should be %d, is %d", writeBps, limits.WriteBps)
+	assert.False(t, limits.Absent, "Device %s should be present", device)
+}
+
+// Check the size limit of the container's filesystem, which devicemapper
+// devices always have.
+func TestDockerContainerFsLimit(t *testing.T) {
+	fm := framework.New(t)
+	defer fm.Cleanup()
+
+	out, _ := fm.Shell().Run("sudo", "docker", "info")
+	if !strings.Contains(out, "Storage Driver: devicemapper") {
+		t.Skip("Docker is not using the devicemapper storage driver")
+	}
+
+	containerId := fm.Docker().RunPause()
+
+	// Wait for the container to show up.
+	waitForContainer(containerId, fm)
+
+	containerInfo, err := fm.Cadvisor().Client().DockerContainer(containerId, &info.ContainerInfoRequest{})
+	require.NoError(t, err)
+	sanityCheck(containerId, containerInfo, t)
+
+	require.NotNil(t, containerInfo.Spec.Fs, "Filesystem size limit should be reported")
+	assert.True(t, containerInfo.Spec.Fs.Limit > 0, "Filesystem size limit should be positive")
+}
+
 // Check the CPU ContainerStats.
 func TestDockerContainerCpuStats(t *testing.T) {
 	fm := framework.New(t)
@@ -220,7 +363,7 @@ func TestDockerContainerCpuStats(t *testing.T) {
 	sanityCheck(containerId, containerInfo, t)
 
 	// Checks for CpuStats.
-	checkCpuStats(t, containerInfo.Stats[0].Cpu)
+	checkCpuStats(t, containerInfo.Stats[0])
 }
 
 // Check the memory ContainerStats.
@@ -240,7 +383,7 @@ func TestDockerContainerMemoryStats(t *testing.T) {
 	sanityCheck(containerId, containerInfo, t)
 
 	// Checks for MemoryStats.
-	checkMemoryStats(t, containerInfo.Stats[0].Memory)
+	checkMemoryStats(t, containerInfo.Stats[0])
 }
 
 // Check the network ContainerStats.
@@ -265,3 +408,106 @@ func TestDockerContainerNetworkStats(t *testing.T) {
 	assert.NotEqual(t, 0, stat.Network.TxPackets, "Network tx packets should not bet zero")
 	// TODO(vmarmol): Can probably do a better test with two containers pinging each other.
 }
+
+// Check that the network stats of a shared network namespace are only reported on its owner.
+func TestDockerContainerSharedNetworkStats(t *testing.T) {
+	fm := framework.New(t)
+	defer fm.Cleanup()
+
+	// Generate traffic from a container joined to the pause container's network namespace.
+	ownerId := fm.Docker().RunPause()
+	joinedId := fm.Docker().Run(framework.DockerRunArgs{
+		Image: "busybox",
+		Args:  []string{"--net", "container:" + ownerId},
+	}, "ping", "www.google.com")
+	waitForContainer(ownerId, fm)
+	waitForContainer(joinedId, fm)
+
+	request := &info.ContainerInfoRequest{
+		NumStats: 1,
+	}
+	var owner info.ContainerInfo
+	err := framework.RetryForDuration(func() error {
+		var err error
+		owner, err = fm.Cadvisor().Client().DockerContainer(ownerId, request)
+		if err != nil {
+			return err
+		}
+		if len(owner.Stats) != 1 || owner.Stats[0].Network.TxBytes == 0 {
+			return fmt.Errorf("no network traffic reported for container %q", ownerId)
+		}
+		return nil
+	}, 10*time.Second)
+	require.NoError(t, err)
+	sanityCheck(ownerId, owner, t)
+	assert.True(t, owner.Spec.HasNetwork, "Owner of the network namespace should report network stats")
+	assert.Empty(t, owner.Spec.NetworkSharedWith, "Owner of the network namespace should not share it")
+
+	joined, err := fm.Cadvisor().Client().DockerContainer(joinedId, request)
+	require.NoError(t, err)
+	sanityCheck(joinedId, joined, t)
+	assert.False(t, joined.Spec.HasNetwork, "Container sharing a network namespace should not report network stats")
+	assert.Equal(t, owner.Name, joined.Spec.NetworkSharedWith, "Container should reference the owner of its network namespace")
+	assert.Equal(t, info.NetworkStats{}, joined.Stats[0].Network, "Container sharing a network namespace should have no network stats")
+	assert.Equal(t, owner.Spec.Network, joined.Spec.Network, "Container sharing a network namespace should report the identity of the owner's")
+}
+
+// Check that the host side of the veth pair of a container is the interface
+// whose index is the peer of the container's eth0.
+func TestDockerContainerNetworkIdentity(t *testing.T) {
+	fm := framework.New(t)
+	defer fm.Cleanup()
+
+	containerId := fm.Docker().RunBusybox("ping", "www.google.com")
+	waitForContainer(containerId, fm)
+
+	containerInfo, err := fm.Cadvisor().Client().DockerContainer(containerId, &info.ContainerInfoRequest{})
+	require.NoError(t, err)
+	sanityCheck(containerId, containerInfo, t)
+	network := containerInfo.Spec.Network
+	require.NotNil(t, network, "Network namespace identity should be reported")
+	assert.True(t, network.NamespaceInode != 0, "Network namespace inode should be reported")
+	require.Equal(t, 1, len(network.HostVeths), "Container should have one veth pair, has %v", network.HostVeths)
+
+	out, _ := fm.Shell().Run("sudo", "docker", "exec", containerId, "cat", "/sys/class/net/eth0/iflink")
+	peerIndex := strings.TrimSpace(out)
+	out, _ = fm.Shell().Run("ip", "-o", "link", "show")
+	var peerName string
+	for _, line := range strings.Split(out, "\n") {
+		// e.g.: "7: veth1a2b3c4@if6: <BROADCAST,MULTICAST,UP,LOWER_UP> ..."
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == peerIndex+":" {
+			peerName = strings.SplitN(strings.TrimSuffix(fields[1], ":"), "@", 2)[0]
+			break
+		}
+	}
+	assert.Equal(t, peerName, network.HostVeths[0], "Host veth should be the interface with index %s", peerIndex)
+}
+
+// A Docker container outside of /docker, under the cgroup set with --cgroup-parent.
+func TestDockerContainerWithCgroupParent(t *testing.T) {
+	fm := framework.New(t)
+	defer fm.Cleanup()
+
+	cgroupParent := fmt.Sprintf("/custom-parent-%d", os.Getpid())
+	containerId := fm.Docker().Run(framework.DockerRunArgs{
+		Image: "busybox",
+		Args: []string{
+			"--cgroup-parent", cgroupParent,
+		},
+	}, "ping", "www.google.com")
+	waitForContainer(containerId, fm)
+
+	request := &info.ContainerInfoRequest{
+		NumStats: 1,
+	}
+	containerInfo, err := fm.Cadvisor().Client().DockerContainer(containerId, request)
+	require.NoError(t, err)
+	sanityCheck(containerId, containerInfo, t)
+
+	assert.Equal(t, "docker", containerInfo.Namespace)
+	assert.Equal(t, cgroupParent, containerInfo.Spec.CgroupParent)
+	assert.True(t, strings.HasPrefix(containerInfo.Name, cgroupParent+"/"), "Container %q should be under %q", containerInfo.Name, cgroupParent)
+	checkCpuStats(t, containerInfo.Stats[0])
+	checkMemoryStats(t, containerInfo.Stats[0])
+}
@@ -0,0 +1,115 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/integration/framework"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	emptyHousekeepingInterval    = time.Second
+	emptyMaxHousekeepingInterval = 8 * time.Second
+)
+
+// Waits up to the timeout for the newest stats of the container to report the
+// number of processes, and returns the container with them.
+func waitForProcessCount(fm framework.Framework, name string, count uint64, timeout time.Duration) *info.ContainerInfo {
+	var containerInfo *info.ContainerInfo
+	err := framework.RetryForDuration(func() error {
+		var err error
+		containerInfo, err = fm.Cadvisor().Client().ContainerInfo(name, &info.ContainerInfoRequest{NumStats: 1})
+		if err != nil {
+			return err
+		}
+		if len(containerInfo.Stats) == 0 {
+			return fmt.Errorf("no stats of %q yet", name)
+		}
+		stats := containerInfo.Stats[0]
+		if stats.ProcessCount == nil {
+			return fmt.Errorf("the stats of %q do not report its processes", name)
+		}
+		if *stats.ProcessCount != count {
+			return fmt.Errorf("%q has %d processes, expected %d", name, *stats.ProcessCount, count)
+		}
+		return nil
+	}, timeout)
+	require.NoError(fm.T(), err, "Timed out waiting for %q to have %d processes", name, count)
+	return containerInfo
+}
+
+// Waits up to the timeout for the housekeeping interval of the container to be the expected one.
+func waitForHousekeepingInterval(fm framework.Framework, name string, expected time.Duration, timeout time.Duration) {
+	err := framework.RetryForDuration(func() error {
+		status, err := fm.Cadvisor().Client().CollectionStatus(name)
+		if err != nil {
+			return err
+		}
+		if status.HousekeepingInterval != expected {
+			return fmt.Errorf("housekeeping interval of %q is %v, expected %v", name, status.HousekeepingInterval, expected)
+		}
+		return nil
+	}, timeout)
+	require.NoError(fm.T(), err, "Timed out waiting for the housekeeping interval of %q", name)
+}
+
+// A cgroup without processes is a container like any other: it has stats of
+// zero usage rather than failing, and is housekept as rarely as allowed until
+// a process is added to it.
+func TestRawContainerWithoutProcesses(t *testing.T) {
+	fm := framework.New(t, framework.CadvisorArgs(
+		"--housekeeping_interval="+emptyHousekeepingInterval.String(),
+		"--max_housekeeping_interval="+emptyMaxHousekeepingInterval.String(),
+	))
+	defer fm.Cleanup()
+
+	root := fmt.Sprintf("/test-raw-empty-%d", os.Getpid())
+	name := root + "/empty"
+	containers := makeEmptyContainers(fm, name)
+	defer containers.Cleanup()
+
+	waitForHierarchy(fm, root, map[string][]string{
+		root: {name},
+		name: {},
+	})
+
+	containerInfo := waitForProcessCount(fm, name, 0, 10*time.Second)
+	assert.True(t, containerInfo.Spec.HasCpu, "Spec of %q should have CPU", name)
+	assert.True(t, containerInfo.Spec.HasMemory, "Spec of %q should have memory", name)
+	assert.False(t, containerInfo.Spec.CreationTime.IsZero(), "Spec of %q should have a creation time", name)
+	assert.Nil(t, containerInfo.Spec.ProcessLimits, "%q has no process to read the limits of", name)
+	stats := containerInfo.Stats[0]
+	assert.Empty(t, stats.PartialFailure, "Stats of %q should be complete", name)
+	assert.Equal(t, uint64(0), stats.Cpu.Usage.Total, "CPU usage of %q", name)
+	checkProbedSections(t, containerInfo)
+
+	// Nothing changes in an empty container.
+	waitForHousekeepingInterval(fm, name, emptyMaxHousekeepingInterval, 20*time.Second)
+	status, err := fm.Cadvisor().Client().CollectionStatus(name)
+	require.NoError(t, err)
+	assert.Empty(t, status.CollectionErrors, "Collection of %q should not fail", name)
+
+	// Until a process is added to it.
+	containers.AddSleep(name)
+	waitForProcessCount(fm, name, 1, emptyMaxHousekeepingInterval+5*time.Second)
+	waitForHousekeepingInterval(fm, name, emptyHousekeepingInterval, 5*time.Second)
+}
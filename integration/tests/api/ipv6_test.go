@@ -0,0 +1,57 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"testing"
+
+	"github.com/google/cadvisor/client"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/integration/framework"
+)
+
+// cAdvisor listens on all the addresses of the host unless --listen_ip is set,
+// so a local cAdvisor is also reachable over the IPv6 loopback address.
+func TestApiOverIpv6Loopback(t *testing.T) {
+	fm := framework.New(t)
+	defer fm.Cleanup()
+	if !fm.Hostname().IsLocal() {
+		t.Skipf("The IPv6 loopback address of remote host %q is not reachable", fm.Hostname().Host)
+	}
+	if !framework.Ipv6LoopbackAvailable() {
+		t.Skip("No IPv6 loopback address on this host")
+	}
+
+	hostname := fm.Hostname()
+	hostname.Host = "::1"
+	c, err := client.NewClient(hostname.FullHostname())
+	if err != nil {
+		t.Fatal(err)
+	}
+	machineInfo, err := c.MachineInfo()
+	if err != nil {
+		t.Fatalf("Failed to get the machine information over %q: %v", hostname.FullHostname(), err)
+	}
+	if machineInfo.NumCores <= 0 {
+		t.Errorf("Machine info has unexpected number of cores: %v", machineInfo.NumCores)
+	}
+	containerInfo, err := c.ContainerInfo("/", &info.ContainerInfoRequest{NumStats: 1})
+	if err != nil {
+		t.Fatalf("Failed to get the root container over %q: %v", hostname.FullHostname(), err)
+	}
+	if len(containerInfo.Stats) != 1 {
+		t.Errorf("Expected 1 stats of the root container, got %d", len(containerInfo.Stats))
+	}
+}
@@ -15,8 +15,11 @@
 package api
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
+	info "github.com/google/cadvisor/info/v1"
 	"github.com/google/cadvisor/integration/framework"
 )
 
@@ -48,3 +51,150 @@ func TestMachineInformationIsReturned(t *testing.T) {
 		}
 	}
 }
+
+func TestMachineDiskStatsAreReturned(t *testing.T) {
+	fm := framework.New(t)
+	defer fm.Cleanup()
+
+	containerInfo, err := fm.Cadvisor().Client().ContainerInfo("/", &info.ContainerInfoRequest{NumStats: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containerInfo.Stats) != 1 {
+		t.Fatalf("Expected 1 stat point for the root container, found %d", len(containerInfo.Stats))
+	}
+	disks := containerInfo.Stats[0].MachineDisks
+	if len(disks) == 0 {
+		t.Errorf("Expected the root container to have disk stats, found none")
+	}
+	for _, disk := range disks {
+		if disk.Device == "" {
+			t.Errorf("Expected a non-empty device name in: %+v", disk)
+		}
+	}
+}
+
+func TestRootPressureStatsAreReturned(t *testing.T) {
+	fm := framework.New(t)
+	defer fm.Cleanup()
+
+	containerInfo, err := fm.Cadvisor().Client().ContainerInfo("/", &info.ContainerInfoRequest{NumStats: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !containerInfo.Spec.HasPressure {
+		t.Skip("Pressure stall information is not available on this kernel")
+	}
+	if len(containerInfo.Stats) != 1 {
+		t.Fatalf("Expected 1 stat point for the root container, found %d", len(containerInfo.Stats))
+	}
+	pressure := containerInfo.Stats[0].Pressure
+	if pressure == nil || pressure.Cpu == nil {
+		t.Fatalf("Expected the root container to have CPU pressure, found %+v", pressure)
+	}
+	for _, avg := range []float64{pressure.Cpu.Some.Avg10, pressure.Cpu.Some.Avg60, pressure.Cpu.Some.Avg300} {
+		if avg < 0 || avg > 100 {
+			t.Errorf("Expected CPU pressure averages to be percentages, found %+v", pressure.Cpu.Some)
+		}
+	}
+}
+
+func TestSystemHealthIsOnlyReturnedForRoot(t *testing.T) {
+	fm := framework.New(t)
+	defer fm.Cleanup()
+
+	containerInfo, err := fm.Cadvisor().Client().ContainerInfo("/", &info.ContainerInfoRequest{NumStats: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containerInfo.Stats) != 1 {
+		t.Fatalf("Expected 1 stat point for the root container, found %d", len(containerInfo.Stats))
+	}
+	health := containerInfo.Stats[0].SystemHealth
+	if health == nil {
+		t.Fatalf("Expected the root container to have system health stats, found none")
+	}
+	if health.FileHandles != nil && health.FileHandles.Used > health.FileHandles.Max {
+		t.Errorf("Expected allocated file handles to be at most the maximum, found %+v", health.FileHandles)
+	}
+	if health.Pids != nil && health.Pids.Used == 0 {
+		t.Errorf("Expected a non-zero number of tasks, found %+v", health.Pids)
+	}
+
+	containerId := fm.Docker().RunPause()
+	waitForContainer(containerId, fm)
+	dockerInfo, err := fm.Cadvisor().Client().DockerContainer(containerId, &info.ContainerInfoRequest{NumStats: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stat := range dockerInfo.Stats {
+		if stat.SystemHealth != nil {
+			t.Errorf("Expected no system health stats for container %q, found %+v", containerId, stat.SystemHealth)
+		}
+	}
+}
+
+// The root container reports the traffic of the machine's interfaces.
+func TestRootNetworkStatsIncrease(t *testing.T) {
+	fm := framework.New(t)
+	defer fm.Cleanup()
+
+	getRootNetwork := func() info.NetworkStats {
+		containerInfo, err := fm.Cadvisor().Client().ContainerInfo("/", &info.ContainerInfoRequest{NumStats: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !containerInfo.Spec.HasNetwork {
+			t.Fatalf("Expected the root container to have network stats")
+		}
+		if len(containerInfo.Stats) != 1 {
+			t.Fatalf("Expected 1 stat point for the root container, found %d", len(containerInfo.Stats))
+		}
+		return containerInfo.Stats[0].Network
+	}
+	before := getRootNetwork()
+
+	containerId := fm.Docker().RunBusybox("sh", "-c", "while true; do wget -q -O /dev/null http://www.google.com; sleep 1; done")
+	waitForContainer(containerId, fm)
+
+	err := framework.RetryForDuration(func() error {
+		after := getRootNetwork()
+		if after.RxBytes <= before.RxBytes || after.TxBytes <= before.TxBytes {
+			return fmt.Errorf("root network stats did not increase from %+v to %+v", before, after)
+		}
+		return nil
+	}, 30*time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// The root container reports the machine's sensors when cAdvisor runs with
+// --enable_hardware_monitoring on a machine which has them.
+func TestRootMachineStats(t *testing.T) {
+	fm := framework.New(t)
+	defer fm.Cleanup()
+
+	containerInfo, err := fm.Cadvisor().Client().ContainerInfo("/", &info.ContainerInfoRequest{NumStats: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var machineStats *info.MachineStats
+	for _, stat := range containerInfo.Stats {
+		machineStats = stat.MachineStats
+	}
+	if machineStats == nil {
+		t.Skip("Hardware monitoring is disabled or the machine has no sensors")
+	}
+	for _, zone := range machineStats.Thermal {
+		// Sanity bounds, in degrees Celsius.
+		if zone.Temperature < -50 || zone.Temperature > 150 {
+			t.Errorf("Thermal zone %+v has an unexpected temperature", zone)
+		}
+	}
+	for _, domain := range machineStats.Power {
+		if domain.Power < 0 {
+			t.Errorf("RAPL domain %+v has a negative power", domain)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/integration/framework"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A container reading through a file several times the size of its memory
+// limit keeps the kernel reclaiming its page cache, which is reported as
+// memory pressure (cAdvisor runs with --memory_pressure_duration=5s).
+func TestDockerContainerMemoryPressure(t *testing.T) {
+	fm := framework.New(t)
+	defer fm.Cleanup()
+
+	containerId := fm.Docker().Run(framework.DockerRunArgs{
+		Image: "busybox",
+		Args:  []string{"--memory=32m"},
+	}, "sh", "-c", "dd if=/dev/zero of=/tmp/data bs=1M count=128 && while true; do cat /tmp/data > /dev/null; done")
+	waitForContainer(containerId, fm)
+
+	containerInfo, err := fm.Cadvisor().Client().DockerContainer(containerId, &info.ContainerInfoRequest{NumStats: 1})
+	require.NoError(t, err)
+	sanityCheck(containerId, containerInfo, t)
+
+	request := events.NewRequest()
+	request.ContainerName = containerInfo.Name
+	request.EventType[events.TypeMemoryPressure] = true
+	var evs []*events.Event
+	err = framework.RetryForDuration(func() error {
+		// Kernels without reclaim counters in memory.stat never report pressure.
+		latest, err := fm.Cadvisor().Client().DockerContainer(containerId, &info.ContainerInfoRequest{NumStats: 1})
+		if err != nil {
+			return err
+		}
+		if len(latest.Stats) == 1 {
+			data := latest.Stats[0].Memory.HierarchicalData
+			if data.Pgpgout > 0 && data.PgscanDirect == 0 && data.PgscanKswapd == 0 {
+				t.Skip("The kernel does not report the pages scanned for reclaim")
+			}
+		}
+
+		evs, err = fm.Cadvisor().Client().Events(request)
+		if err != nil {
+			return err
+		}
+		if len(evs) == 0 {
+			return fmt.Errorf("no memory pressure event for %q", containerInfo.Name)
+		}
+		return nil
+	}, 60*time.Second)
+	require.NoError(t, err, "Timed out waiting for a memory pressure event")
+
+	pressure, ok := evs[0].EventData.(map[string]interface{})
+	require.True(t, ok, "Unexpected event data %+v", evs[0].EventData)
+	assert.Equal(t, float64(32<<20), pressure["limit"])
+	assert.True(t, pressure["usage"].(float64) > 0, "Usage should be reported: %+v", pressure)
+	assert.True(t, pressure["thrashing_score"].(float64) > 1, "Thrashing score should be above the threshold: %+v", pressure)
+}
@@ -0,0 +1,261 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/google/cadvisor/integration/framework"
+	"github.com/google/cadvisor/utils/cgroups"
+)
+
+// Raw containers made for a test, see makeSleepContainers().
+type sleepContainers struct {
+	fm framework.Framework
+
+	// Cgroup hierarchies the containers are made in.
+	hierarchies []hierarchy
+
+	// Containers which still exist.
+	paths []string
+
+	// PID of the sleep process in each leaf container.
+	pids map[string]string
+
+	// PIDs of the other processes started in each container, see RunBusyLoop()
+	// and RunZombie().
+	busyPids map[string][]string
+
+	// Copies of the shell the busy loops run as.
+	busyBinaries []string
+}
+
+// Makes raw containers with the specified (possibly nested) names in all
+// cgroup hierarchies, e.g.: "/test", "/test/a", "/test/a/b". Parents need not
+// be listed. A sleep process is placed only in the leaves, so parents only
+// hold subcontainers. Cleanup() removes them all, it is also called by
+// fm.Cleanup(). Skips the test unless it can run commands as root.
+func makeSleepContainers(fm framework.Framework, names ...string) *sleepContainers {
+	return makeRawContainers(fm, true, names...)
+}
+
+// Makes raw containers as makeSleepContainers() does, but leaves the leaves
+// empty too: they are created without any process. See AddSleep().
+func makeEmptyContainers(fm framework.Framework, names ...string) *sleepContainers {
+	return makeRawContainers(fm, false, names...)
+}
+
+func makeRawContainers(fm framework.Framework, sleep bool, names ...string) *sleepContainers {
+	fm.RequiresRoot()
+	self := &sleepContainers{
+		fm:       fm,
+		pids:     make(map[string]string),
+		busyPids: make(map[string][]string),
+	}
+	mounts, _ := fm.Shell().Run("cat", "/proc/mounts")
+	hierarchies, err := getHierarchies(mounts)
+	if err != nil {
+		fm.T().Fatalf("Failed to get the cgroup hierarchies of the host: %v", err)
+	}
+	if len(hierarchies) == 0 {
+		fm.T().Fatalf("No cgroup hierarchies are mounted")
+	}
+	self.hierarchies = hierarchies
+
+	// Include all the parents.
+	all := make(map[string]bool)
+	for _, name := range names {
+		for ; name != "/"; name = path.Dir(name) {
+			all[name] = true
+		}
+	}
+	for name := range all {
+		self.paths = append(self.paths, name)
+	}
+	// Parents sort before their children.
+	sort.Strings(self.paths)
+
+	// Registered before anything is made so that a failure half way through
+	// does not leak cgroups or processes.
+	fm.AddCleanup(self.Cleanup)
+	for _, name := range self.paths {
+		for _, hierarchy := range self.hierarchies {
+			dir := path.Join(hierarchy.mountpoint, name)
+			fm.Shell().RunCommandAsRoot("mkdir", "-p", dir)
+			if !hierarchy.cpuset {
+				continue
+			}
+			// New cpusets have no CPUs or memory nodes, so no process can be
+			// moved to them until they are given their parent's.
+			for _, file := range []string{"cpuset.cpus", "cpuset.mems"} {
+				fm.Shell().RunCommandAsRoot("sh", "-c", fmt.Sprintf("cat %s > %s", framework.ShellQuote(path.Join(path.Dir(dir), file)), framework.ShellQuote(path.Join(dir, file))))
+			}
+		}
+	}
+	if !sleep {
+		return self
+	}
+	for _, name := range self.paths {
+		if self.isLeaf(name) {
+			self.AddSleep(name)
+		}
+	}
+	return self
+}
+
+// A mounted cgroup hierarchy.
+type hierarchy struct {
+	mountpoint string
+
+	// Whether the cpuset subsystem is attached to the hierarchy.
+	cpuset bool
+}
+
+// Returns the cgroup hierarchies in the specified /proc/mounts contents of the
+// host, each at only one of its mountpoints.
+func getHierarchies(procMounts string) ([]hierarchy, error) {
+	mounts, err := cgroups.ParseMounts(strings.NewReader(procMounts))
+	if err != nil {
+		return nil, err
+	}
+	subsystems := make(map[string]struct{})
+	for _, mount := range mounts {
+		for _, subsystem := range mount.Subsystems {
+			subsystems[subsystem] = struct{}{}
+		}
+	}
+	_, used := cgroups.GetMountpoints(mounts, subsystems)
+	hierarchies := make([]hierarchy, 0, len(used))
+	for _, mount := range used {
+		h := hierarchy{
+			mountpoint: mount.Mountpoint,
+		}
+		for _, subsystem := range mount.Subsystems {
+			if subsystem == "cpuset" {
+				h.cpuset = true
+			}
+		}
+		hierarchies = append(hierarchies, h)
+	}
+	return hierarchies, nil
+}
+
+// Places a sleep process in the specified leaf container, e.g.: one made
+// empty. It is killed when the container is removed.
+func (self *sleepContainers) AddSleep(name string) {
+	if _, ok := self.pids[name]; ok {
+		self.fm.T().Fatalf("Container %q already has a sleep process", name)
+	}
+	pid, _ := self.fm.Shell().RunCommandAsRoot("sh", "-c", "sleep 100000 >/dev/null 2>&1 & echo $!")
+	pid = strings.TrimSpace(pid)
+	self.addProcess(name, pid)
+	self.pids[name] = pid
+}
+
+// Moves the specified process to the specified container in all hierarchies.
+func (self *sleepContainers) addProcess(name, pid string) {
+	for _, hierarchy := range self.hierarchies {
+		self.fm.Shell().RunCommandAsRoot("sh", "-c", fmt.Sprintf("echo %s > %s", pid, framework.ShellQuote(path.Join(hierarchy.mountpoint, name, "tasks"))))
+	}
+}
+
+// Runs a busy loop in the specified leaf container as a process named
+// processName (a copy of the shell). It is killed with the container.
+func (self *sleepContainers) RunBusyLoop(name, processName string) {
+	self.runBusyLoop(name, processName, "")
+}
+
+// Runs a busy loop as RunBusyLoop() does, only allowed to run on the
+// specified CPU. Several of them on the same CPU contend for it.
+func (self *sleepContainers) RunPinnedBusyLoop(name, processName string, cpu int) {
+	self.runBusyLoop(name, processName, fmt.Sprintf("taskset -c %d ", cpu))
+}
+
+// Runs the busy loop, its command prefixed with the specified one.
+func (self *sleepContainers) runBusyLoop(name, processName, prefix string) {
+	binary := path.Join("/tmp", processName)
+	self.fm.Shell().RunCommandAsRoot("cp", "/bin/sh", binary)
+	self.busyBinaries = append(self.busyBinaries, binary)
+	pid, _ := self.fm.Shell().RunCommandAsRoot("sh", "-c", fmt.Sprintf("%s%s -c 'while :; do :; done' >/dev/null 2>&1 & echo $!", prefix, binary))
+	pid = strings.TrimSpace(pid)
+	self.addProcess(name, pid)
+	self.busyPids[name] = append(self.busyPids[name], pid)
+}
+
+// Leaves a zombie in the specified leaf container: a process whose child
+// exits and is never reaped. Both are in the container since the child is
+// forked once the parent was moved to it. The zombie is reaped when the
+// container is removed.
+func (self *sleepContainers) RunZombie(name string) {
+	// The parent waits to be moved to all hierarchies, then becomes a sleep,
+	// which never waits for its children.
+	script := fmt.Sprintf("while ! grep -q %s /proc/$$/cgroup; do sleep 0.1; done; sleep 1; sleep 1 & exec sleep 100000", framework.ShellQuote(":"+name+"$"))
+	pid, _ := self.fm.Shell().RunCommandAsRoot("sh", "-c", fmt.Sprintf("sh -c %s >/dev/null 2>&1 & echo $!", framework.ShellQuote(script)))
+	pid = strings.TrimSpace(pid)
+	self.addProcess(name, pid)
+	self.busyPids[name] = append(self.busyPids[name], pid)
+}
+
+// Whether the container has no subcontainers.
+func (self *sleepContainers) isLeaf(name string) bool {
+	for _, other := range self.paths {
+		if strings.HasPrefix(other, name+"/") {
+			return false
+		}
+	}
+	return true
+}
+
+// Removes the specified container, killing its sleep process. It must not have subcontainers.
+func (self *sleepContainers) Remove(name string) {
+	if !self.isLeaf(name) {
+		self.fm.T().Fatalf("Container %q has subcontainers and cannot be removed", name)
+	}
+	if pid, ok := self.pids[name]; ok {
+		// The cgroups are busy until the process exits.
+		self.fm.Shell().RunCommandAsRoot("sh", "-c", fmt.Sprintf("kill %s; while kill -0 %s 2>/dev/null; do sleep 0.1; done", pid, pid))
+		delete(self.pids, name)
+	}
+	for _, pid := range self.busyPids[name] {
+		self.fm.Shell().RunCommandAsRoot("sh", "-c", fmt.Sprintf("kill %s; while kill -0 %s 2>/dev/null; do sleep 0.1; done", pid, pid))
+	}
+	delete(self.busyPids, name)
+	for _, hierarchy := range self.hierarchies {
+		// The container may not have been made in all hierarchies if making
+		// it failed.
+		dir := framework.ShellQuote(path.Join(hierarchy.mountpoint, name))
+		self.fm.Shell().RunCommandAsRoot("sh", "-c", fmt.Sprintf("if [ -d %s ]; then rmdir %s; fi", dir, dir))
+	}
+	for i := range self.paths {
+		if self.paths[i] == name {
+			self.paths = append(self.paths[:i], self.paths[i+1:]...)
+			break
+		}
+	}
+}
+
+// Removes all the remaining containers, deepest first.
+func (self *sleepContainers) Cleanup() {
+	for len(self.paths) > 0 {
+		self.Remove(self.paths[len(self.paths)-1])
+	}
+	for _, binary := range self.busyBinaries {
+		self.fm.Shell().RunCommandAsRoot("rm", "-f", binary)
+	}
+	self.busyBinaries = nil
+}
@@ -0,0 +1,214 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/integration/framework"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Returns the names of the container's subcontainers, sorted.
+func subcontainerNames(containerInfo info.ContainerInfo) []string {
+	names := make([]string, 0, len(containerInfo.Subcontainers))
+	for _, subcontainer := range containerInfo.Subcontainers {
+		names = append(names, subcontainer.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns the subcontainers of each container in the tree rooted at the specified container.
+func getHierarchy(fm framework.Framework, name string) (map[string][]string, error) {
+	containers, err := fm.Cadvisor().Client().SubcontainersInfo(name, &info.ContainerInfoRequest{NumStats: 0})
+	if err != nil {
+		return nil, err
+	}
+	hierarchy := make(map[string][]string, len(containers))
+	for _, containerInfo := range containers {
+		hierarchy[containerInfo.Name] = subcontainerNames(containerInfo)
+	}
+	return hierarchy, nil
+}
+
+// Waits up to 10s for the tree rooted at the specified container to be the expected one.
+func waitForHierarchy(fm framework.Framework, name string, expected map[string][]string) {
+	var hierarchy map[string][]string
+	err := framework.RetryForDuration(func() error {
+		var err error
+		hierarchy, err = getHierarchy(fm, name)
+		if err != nil {
+			return err
+		}
+		if !assert.ObjectsAreEqual(expected, hierarchy) {
+			return fmt.Errorf("hierarchy of %q is %v, expected %v", name, hierarchy, expected)
+		}
+		return nil
+	}, 10*time.Second)
+	require.NoError(fm.T(), err, "Timed out waiting for the hierarchy of %q", name)
+}
+
+// Nested raw containers are listed under their parent only.
+func TestRawSubcontainerHierarchy(t *testing.T) {
+	fm := framework.New(t)
+	defer fm.Cleanup()
+
+	root := fmt.Sprintf("/test-raw-hierarchy-%d", os.Getpid())
+	a, b, c := root+"/a", root+"/a/b", root+"/c"
+	containers := makeSleepContainers(fm, b, c)
+	defer containers.Cleanup()
+
+	waitForHierarchy(fm, root, map[string][]string{
+		root: {a, c},
+		a:    {b},
+		b:    {},
+		c:    {},
+	})
+
+	// Only the direct children are subcontainers.
+	containerInfo, err := fm.Cadvisor().Client().ContainerInfo(root, &info.ContainerInfoRequest{NumStats: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{a, c}, subcontainerNames(*containerInfo))
+	containerInfo, err = fm.Cadvisor().Client().ContainerInfo(c, &info.ContainerInfoRequest{NumStats: 1})
+	require.NoError(t, err)
+	require.NotEmpty(t, containerInfo.Stats, "Stats of %q should be returned", c)
+	checkProbedSections(t, containerInfo)
+
+	// Removed containers disappear from both views.
+	containers.Remove(b)
+	waitForHierarchy(fm, root, map[string][]string{
+		root: {a, c},
+		a:    {},
+		c:    {},
+	})
+	containerInfo, err = fm.Cadvisor().Client().ContainerInfo(a, &info.ContainerInfoRequest{NumStats: 1})
+	require.NoError(t, err)
+	assert.Empty(t, containerInfo.Subcontainers)
+}
+
+// The ancestors of a nested raw container are listed from the root, each with
+// its spec and latest stats.
+func TestRawContainerAncestry(t *testing.T) {
+	fm := framework.New(t)
+	defer fm.Cleanup()
+
+	root := fmt.Sprintf("/test-raw-ancestry-%d", os.Getpid())
+	a, b := root+"/a", root+"/a/b"
+	containers := makeSleepContainers(fm, b)
+	defer containers.Cleanup()
+
+	waitForHierarchy(fm, root, map[string][]string{
+		root: {a},
+		a:    {b},
+		b:    {},
+	})
+
+	var ancestry []info.ContainerAncestor
+	err := framework.RetryForDuration(func() error {
+		var err error
+		ancestry, err = fm.Cadvisor().Client().AncestryInfo(b)
+		if err != nil {
+			return err
+		}
+		for _, ancestor := range ancestry {
+			if ancestor.Tracked && ancestor.Stats == nil {
+				return fmt.Errorf("%q has no stats yet", ancestor.Name)
+			}
+		}
+		return nil
+	}, 10*time.Second)
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(ancestry))
+	for _, ancestor := range ancestry {
+		names = append(names, ancestor.Name)
+	}
+	assert.Equal(t, []string{"/", root, a, b}, names)
+	for _, ancestor := range ancestry {
+		assert.True(t, ancestor.Tracked, "%q should be tracked", ancestor.Name)
+		assert.NotNil(t, ancestor.Spec, "Spec of %q should be returned", ancestor.Name)
+	}
+
+	// A container that is not tracked has no ancestry.
+	_, err = fm.Cadvisor().Client().AncestryInfo(b + "/missing")
+	assert.Error(t, err)
+}
+
+// Names with characters that must be escaped in URLs round-trip through the client.
+func TestRawContainerWithSpecialCharacters(t *testing.T) {
+	fm := framework.New(t)
+	defer fm.Cleanup()
+
+	root := fmt.Sprintf("/test-raw-special-%d", os.Getpid())
+	name := root + "/user@1000.service with space"
+	containers := makeSleepContainers(fm, name)
+	defer containers.Cleanup()
+
+	waitForHierarchy(fm, root, map[string][]string{
+		root: {name},
+		name: {},
+	})
+
+	containerInfo, err := fm.Cadvisor().Client().ContainerInfo(name, &info.ContainerInfoRequest{NumStats: 1})
+	require.NoError(t, err)
+	assert.Equal(t, name, containerInfo.Name)
+	require.NotEmpty(t, containerInfo.Stats, "Stats of %q should be returned", name)
+	checkProbedSections(t, containerInfo)
+}
+
+// The spec has the sections whose stats can be collected: those are not
+// missing from the stats, while the others are not applicable rather than failing.
+func checkProbedSections(t *testing.T, containerInfo *info.ContainerInfo) {
+	stats := containerInfo.Stats[0]
+	if containerInfo.Spec.HasCpu {
+		require.False(t, stats.Missing(info.StatsSectionCpu), "CPU stats of %q should be collected", containerInfo.Name)
+		checkCpuStats(t, stats)
+	} else {
+		assert.False(t, stats.Missing(info.StatsSectionCpu), "CPU stats of %q are not applicable", containerInfo.Name)
+	}
+	if containerInfo.Spec.HasMemory {
+		require.False(t, stats.Missing(info.StatsSectionMemory), "Memory stats of %q should be collected", containerInfo.Name)
+		checkMemoryStats(t, stats)
+	} else {
+		assert.False(t, stats.Missing(info.StatsSectionMemory), "Memory stats of %q are not applicable", containerInfo.Name)
+	}
+	if !containerInfo.Spec.HasDiskIo {
+		assert.False(t, stats.Missing(info.StatsSectionDiskIo), "Disk I/O stats of %q are not applicable", containerInfo.Name)
+	}
+}
+
+func TestGetHierarchies(t *testing.T) {
+	mounts := `rootfs / rootfs rw 0 0
+cgroup /sys/fs/cgroup/cpuset cgroup rw,relatime,cpuset 0 0
+cgroup /sys/fs/cgroup/cpu,cpuacct cgroup rw,relatime,cpu,cpuacct 0 0
+cgroup /var/lib/docker/cgroup/cpuset cgroup rw,relatime,cpuset 0 0
+cgroup /sys/fs/cgroup/systemd cgroup rw,nosuid,name=systemd 0 0
+`
+	hierarchies, err := getHierarchies(mounts)
+	require.NoError(t, err)
+	// The bind mount of the cpuset hierarchy is not used.
+	assert.Equal(t, []hierarchy{
+		{mountpoint: "/sys/fs/cgroup/cpuset", cpuset: true},
+		{mountpoint: "/sys/fs/cgroup/cpu,cpuacct"},
+		{mountpoint: "/sys/fs/cgroup/systemd"},
+	}, hierarchies)
+}
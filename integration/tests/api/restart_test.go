@@ -0,0 +1,134 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/integration/framework"
+	"github.com/stretchr/testify/require"
+)
+
+// Housekeeping interval of the cAdvisor restarted by the tests, kept static
+// so that the gaps between samples are bounded.
+const restartHousekeepingInterval = time.Second
+
+func TestStatsSurviveRestart(t *testing.T) {
+	testStatsAcrossRestart(t, false)
+}
+
+func TestStatsSurviveRestartWithCheckpoint(t *testing.T) {
+	testStatsAcrossRestart(t, true)
+}
+
+// Checks that a container is re-discovered after cAdvisor restarts and that
+// its cumulative counters do not go back. With checkpointing, also checks
+// that the samples taken before the restart are kept and that the restart
+// only leaves a gap of its duration in them.
+func testStatsAcrossRestart(t *testing.T, checkpoint bool) {
+	args := []string{
+		"--housekeeping_interval=" + restartHousekeepingInterval.String(),
+		"--allow_dynamic_housekeeping=false",
+	}
+	if checkpoint {
+		dir, err := ioutil.TempDir("", "cadvisor-checkpoint")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		args = append(args, "--storage_memory_checkpoint_path="+path.Join(dir, "checkpoint"))
+	}
+	fm := framework.New(t, framework.CadvisorArgs(args...))
+	defer fm.Cleanup()
+
+	// Steady CPU load.
+	containerId := fm.Docker().RunBusybox("sh", "-c", "while true; do :; done")
+	waitForContainer(containerId, fm)
+	time.Sleep(3 * restartHousekeepingInterval)
+
+	before, err := fm.Cadvisor().Client().DockerContainer(containerId, &info.ContainerInfoRequest{NumStats: 1})
+	require.NoError(t, err)
+	require.NotEmpty(t, before.Stats)
+	lastBefore := before.Stats[len(before.Stats)-1]
+
+	restartStart := time.Now()
+	fm.Cadvisor().Restart()
+	restartDuration := time.Since(restartStart)
+
+	// Wait for a couple of samples taken after the restart.
+	var after info.ContainerInfo
+	err = framework.RetryForDuration(func() error {
+		after, err = fm.Cadvisor().Client().DockerContainer(containerId, &info.ContainerInfoRequest{NumStats: 600})
+		if err != nil {
+			return err
+		}
+		samples := 0
+		for _, stat := range after.Stats {
+			if stat.Timestamp.After(restartStart.Add(restartDuration)) {
+				samples++
+			}
+		}
+		if samples < 2 {
+			return fmt.Errorf("%d samples of container %q since the restart", samples, containerId)
+		}
+		return nil
+	}, 10*time.Second)
+	require.NoError(t, err, "Timed out waiting for container %q to be re-discovered after the restart", containerId)
+
+	// (a) Same container.
+	beforeAliases := append([]string{}, before.Aliases...)
+	afterAliases := append([]string{}, after.Aliases...)
+	sort.Strings(beforeAliases)
+	sort.Strings(afterAliases)
+	if !reflect.DeepEqual(beforeAliases, afterAliases) {
+		t.Errorf("Container %q has aliases %v after the restart, expected %v", containerId, afterAliases, beforeAliases)
+	}
+
+	// (b) Cumulative counters do not go back.
+	var previous *info.ContainerStats
+	if !lastBefore.Missing(info.StatsSectionCpu) {
+		previous = lastBefore
+	}
+	for _, stat := range after.Stats {
+		if stat.Missing(info.StatsSectionCpu) || stat.Timestamp.Before(lastBefore.Timestamp) {
+			continue
+		}
+		if previous != nil && (stat.Cpu.Usage.Total < previous.Cpu.Usage.Total || stat.Cpu.Usage.User < previous.Cpu.Usage.User || stat.Cpu.Usage.System < previous.Cpu.Usage.System) {
+			t.Errorf("CPU usage went back from %+v at %v to %+v at %v", previous.Cpu.Usage, previous.Timestamp, stat.Cpu.Usage, stat.Timestamp)
+		}
+		previous = stat
+	}
+
+	// (c) Without checkpointing the samples before the restart are lost.
+	if !checkpoint {
+		return
+	}
+	if len(after.Stats) == 0 || !after.Stats[0].Timestamp.Before(restartStart) {
+		t.Fatalf("Expected the samples of container %q before the restart to be restored", containerId)
+	}
+	maxGap := restartDuration + restartHousekeepingInterval
+	for i := 1; i < len(after.Stats); i++ {
+		gap := after.Stats[i].Timestamp.Sub(after.Stats[i-1].Timestamp)
+		if gap > maxGap {
+			t.Errorf("Gap of %v between the samples at %v and %v, expected at most %v (a restart of %v and a housekeeping interval)", gap, after.Stats[i-1].Timestamp, after.Stats[i].Timestamp, maxGap, restartDuration)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/integration/framework"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The stats collected under varied load match the cgroup files read around
+// their collection (cAdvisor runs with --self_check and
+// --self_check_interval=5s).
+func TestSelfCheckFindsNoDiscrepancies(t *testing.T) {
+	fm := framework.New(t)
+	defer fm.Cleanup()
+
+	// Bursts of CPU, and memory churning through the page cache with its page faults.
+	ids := []string{
+		fm.Docker().Run(framework.DockerRunArgs{
+			Image: "busybox",
+		}, "sh", "-c", "while true; do for i in $(seq 100000); do :; done; sleep 1; done"),
+		fm.Docker().Run(framework.DockerRunArgs{
+			Image: "busybox",
+			Args:  []string{"--memory=64m"},
+		}, "sh", "-c", "while true; do dd if=/dev/zero of=/tmp/data bs=1M count=48 && cat /tmp/data > /dev/null && rm /tmp/data; sleep 2; done"),
+	}
+	names := []string{"/"}
+	for _, id := range ids {
+		waitForContainer(id, fm)
+		containerInfo, err := fm.Cadvisor().Client().DockerContainer(id, &info.ContainerInfoRequest{NumStats: 1})
+		require.NoError(t, err)
+		names = append(names, containerInfo.Name)
+	}
+
+	time.Sleep(3 * time.Minute)
+	for _, name := range names {
+		status, err := fm.Cadvisor().Client().CollectionStatus(name)
+		require.NoError(t, err)
+		assert.True(t, status.SelfChecks > 0, "Expected self checks of %q", name)
+		assert.Empty(t, status.SelfCheckDiscrepancies, "Unexpected self check discrepancies of %q", name)
+	}
+}
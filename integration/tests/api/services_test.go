@@ -0,0 +1,66 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/integration/framework"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Waits for the container of the specified system service to have stats.
+func waitForService(fm framework.Framework, name string) *info.ContainerInfo {
+	var containerInfo *info.ContainerInfo
+	err := framework.RetryForDuration(func() error {
+		var err error
+		containerInfo, err = fm.Cadvisor().Client().ContainerInfo(name, &info.ContainerInfoRequest{NumStats: 1})
+		if err != nil {
+			return err
+		}
+		if len(containerInfo.Stats) != 1 {
+			return fmt.Errorf("no stats returned for service %q", name)
+		}
+		return nil
+	}, 10*time.Second)
+	require.NoError(fm.T(), err, "Timed out waiting for service %q", name)
+	return containerInfo
+}
+
+// The Docker daemon and cAdvisor are monitored as system services (cAdvisor runs with --monitor_system_services).
+func TestSystemServicesAreMonitored(t *testing.T) {
+	fm := framework.New(t)
+	defer fm.Cleanup()
+
+	for _, name := range []string{"docker", "cadvisor"} {
+		containerInfo := waitForService(fm, "/services/"+name)
+		assert.Equal(t, "services", containerInfo.Namespace, name)
+		assert.Equal(t, []string{name}, containerInfo.Aliases, name)
+		require.True(t, containerInfo.Spec.HasProcess, "Service %q should have process stats", name)
+
+		stats := containerInfo.Stats[0]
+		require.NotNil(t, stats.Process, "Process stats of %q", name)
+		assert.NotEqual(t, 0, stats.Process.Pid, "PID of %q", name)
+		assert.NotEqual(t, uint64(0), stats.Process.FdCount, "Open files of %q", name)
+		if stats.Process.SocketCount > stats.Process.FdCount {
+			t.Errorf("Service %q has more sockets (%d) than open files (%d)", name, stats.Process.SocketCount, stats.Process.FdCount)
+		}
+		assert.NotEqual(t, uint64(0), stats.Memory.WorkingSet, "Resident memory of %q", name)
+	}
+}
@@ -0,0 +1,343 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/client"
+	"github.com/google/cadvisor/http/instrument"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/integration/framework"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	soakDuration           = flag.Duration("soak_duration", 0, "How long TestSoak churns containers for. Zero skips the test")
+	soakChurnInterval      = flag.Duration("soak_churn_interval", time.Second, "Interval at which TestSoak creates a Docker and a raw container and removes the oldest ones")
+	soakLiveContainers     = flag.Int("soak_live_containers", 5, "Number of containers of each kind TestSoak keeps alive while churning")
+	soakMaxRssMb           = flag.Int("soak_max_rss_mb", 256, "Resident memory of cAdvisor, in MB, TestSoak fails above")
+	soakMaxGoroutineGrowth = flag.Int("soak_max_goroutine_growth", 100, "Number of goroutines cAdvisor may have gained by the end of TestSoak")
+	soakMaxDiscoveryP95    = flag.Duration("soak_max_discovery_p95", 5*time.Second, "95th percentile of the discovery latency of the containers TestSoak fails above")
+)
+
+// Pseudo-container whose stats are the counts and latencies of the containers
+// tracked by cAdvisor (manager.MetaContainerName).
+const metaContainerName = "/.cadvisor-meta"
+
+// What the poller of TestSoak observed.
+type soakObservations struct {
+	lock sync.Mutex
+
+	// Names and aliases of all the containers listed at some point.
+	seen map[string]bool
+
+	// Names and aliases of the containers listed by the latest poll.
+	listed map[string]bool
+
+	// Highest resident memory of cAdvisor, in bytes.
+	maxRss uint64
+
+	// Errors of the requests which failed.
+	errors []string
+}
+
+func (self *soakObservations) wasSeen(name string) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.seen[name]
+}
+
+func (self *soakObservations) isListed(name string) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.listed[name]
+}
+
+// Lists all the containers and the stats of cAdvisor once.
+func (self *soakObservations) poll(c *client.Client) {
+	listed := make(map[string]bool)
+	var errs []string
+	containers, err := c.SubcontainersInfo("/", &info.ContainerInfoRequest{NumStats: 1})
+	if err != nil {
+		errs = append(errs, err.Error())
+	}
+	for _, container := range containers {
+		listed[container.Name] = true
+		for _, alias := range container.Aliases {
+			listed[alias] = true
+		}
+	}
+	var rss uint64
+	cadvisor, err := c.ContainerInfo("/services/cadvisor", &info.ContainerInfoRequest{NumStats: 1})
+	if err != nil {
+		errs = append(errs, err.Error())
+	} else if len(cadvisor.Stats) != 0 {
+		rss = cadvisor.Stats[0].Memory.WorkingSet
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.listed = listed
+	for name := range listed {
+		self.seen[name] = true
+	}
+	if rss > self.maxRss {
+		self.maxRss = rss
+	}
+	self.errors = append(self.errors, errs...)
+}
+
+// Fetches the specified page of cAdvisor.
+func getPage(fm framework.Framework, page string) ([]byte, error) {
+	resp, err := http.Get(fm.Hostname().FullHostname() + page)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request for %q failed with status %q: %s", page, resp.Status, body)
+	}
+	return body, nil
+}
+
+// Number of responses with a 5xx status served by the API.
+func countServerErrors(fm framework.Framework) uint64 {
+	body, err := getPage(fm, "api/v2.0/requests")
+	require.NoError(fm.T(), err)
+	var requests instrument.Requests
+	require.NoError(fm.T(), json.Unmarshal(body, &requests))
+	count := uint64(0)
+	for _, endpoint := range requests.Endpoints {
+		count += endpoint.Codes["5xx"]
+	}
+	return count
+}
+
+// Number of goroutines of cAdvisor, from the first line of its goroutine
+// profile: "goroutine profile: total N".
+func countGoroutines(fm framework.Framework) int {
+	body, err := getPage(fm, "debug/pprof/goroutine?debug=1")
+	require.NoError(fm.T(), err)
+	line, _ := bufio.NewReader(strings.NewReader(string(body))).ReadString('\n')
+	fields := strings.Fields(line)
+	require.NotEmpty(fm.T(), fields, "Empty goroutine profile")
+	count, err := strconv.Atoi(fields[len(fields)-1])
+	require.NoError(fm.T(), err, "Unexpected goroutine profile header %q", line)
+	return count
+}
+
+// Logs the leak tracker of cAdvisor and writes its heap profile to a file.
+func dumpCadvisorMemory(fm framework.Framework) {
+	t := fm.T()
+	if tracked, err := getPage(fm, "tracked"); err != nil {
+		t.Logf("Failed to get the tracked objects: %v", err)
+	} else {
+		t.Logf("Tracked objects of cAdvisor:\n%s", tracked)
+	}
+	heap, err := getPage(fm, "debug/pprof/heap?debug=1")
+	if err != nil {
+		t.Logf("Failed to get the heap profile: %v", err)
+		return
+	}
+	file, err := ioutil.TempFile("", "cadvisor-soak-heap-")
+	if err != nil {
+		t.Logf("Failed to save the heap profile: %v", err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(heap); err != nil {
+		t.Logf("Failed to save the heap profile: %v", err)
+		return
+	}
+	t.Logf("Heap profile of cAdvisor written to %s", file.Name())
+}
+
+// Returns the latencies of discovering the containers created after the
+// specified time, from the first sample of cAdvisor's metadata taken after it.
+// Samples are taken at each global housekeeping.
+func discoveryLatencyAfter(fm framework.Framework, after time.Time) *info.LatencyPercentiles {
+	var latency *info.LatencyPercentiles
+	err := framework.RetryForDuration(func() error {
+		meta, err := fm.Cadvisor().Client().ContainerInfo(metaContainerName, &info.ContainerInfoRequest{NumStats: 1})
+		if err != nil {
+			return err
+		}
+		if len(meta.Stats) == 0 || !meta.Stats[0].Timestamp.After(after) {
+			return fmt.Errorf("no sample of %q after %v", metaContainerName, after)
+		}
+		if meta.Stats[0].ContainerCounts == nil || meta.Stats[0].ContainerCounts.DiscoveryLatency == nil {
+			return fmt.Errorf("no discovery latency in the sample of %q: %+v", metaContainerName, meta.Stats[0].ContainerCounts)
+		}
+		latency = meta.Stats[0].ContainerCounts.DiscoveryLatency
+		return nil
+	}, 2*time.Minute)
+	require.NoError(fm.T(), err)
+	return latency
+}
+
+// Creates and removes Docker and raw containers for --soak_duration while
+// polling the API, then checks that cAdvisor listed all of them, forgot them
+// once they were removed, discovered them quickly, and did not grow or fail
+// along the way.
+func TestSoak(t *testing.T) {
+	if *soakDuration <= 0 {
+		t.Skip("Soak test disabled, see --soak_duration")
+	}
+	fm := framework.New(t, framework.TestTimeout(*soakDuration+10*time.Minute))
+	defer fm.Cleanup()
+	fm.RequiresRoot()
+	defer func() {
+		if t.Failed() {
+			dumpCadvisorMemory(fm)
+		}
+	}()
+
+	c := fm.Cadvisor().Client()
+	waitForService(fm, "/services/cadvisor")
+	serverErrors := countServerErrors(fm)
+	goroutines := countGoroutines(fm)
+
+	observations := &soakObservations{seen: make(map[string]bool)}
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	var stopOnce sync.Once
+	stopPolling := func() {
+		stopOnce.Do(func() {
+			close(stop)
+			<-stopped
+		})
+	}
+	defer stopPolling()
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			observations.poll(c)
+			time.Sleep(100 * time.Millisecond)
+		}
+	}()
+
+	// Containers are removed here rather than by the framework, which would
+	// fail to remove them again.
+	var dockerIds, rawNames []string
+	rawContainers := make(map[string]*sleepContainers)
+	removeDocker := func() {
+		fm.Shell().Run("sudo", "docker", "rm", "-f", dockerIds[0])
+		dockerIds = dockerIds[1:]
+	}
+	removeRaw := func() {
+		rawContainers[rawNames[0]].Cleanup()
+		rawNames = rawNames[1:]
+	}
+	defer func() {
+		for len(dockerIds) > 0 {
+			removeDocker()
+		}
+		for len(rawNames) > 0 {
+			removeRaw()
+		}
+	}()
+
+	var createdDocker, createdRaw []string
+	ticker := time.NewTicker(*soakChurnInterval)
+	defer ticker.Stop()
+	for i, deadline := 0, time.Now().Add(*soakDuration); time.Now().Before(deadline); i++ {
+		output, _ := fm.Shell().Run("sudo", "docker", "run", "-d", "busybox", "sleep", "100000")
+		// The last line is the container ID.
+		fields := strings.Fields(output)
+		id := fields[len(fields)-1]
+		dockerIds = append(dockerIds, id)
+		createdDocker = append(createdDocker, id)
+
+		name := fmt.Sprintf("/cadvisor-soak-%d-%d", os.Getpid(), i)
+		rawContainers[name] = makeSleepContainers(fm, name)
+		rawNames = append(rawNames, name)
+		createdRaw = append(createdRaw, name)
+
+		for len(dockerIds) > *soakLiveContainers {
+			removeDocker()
+		}
+		for len(rawNames) > *soakLiveContainers {
+			removeRaw()
+		}
+		<-ticker.C
+	}
+	churnEnd := time.Now()
+	for len(dockerIds) > 0 {
+		removeDocker()
+	}
+	for len(rawNames) > 0 {
+		removeRaw()
+	}
+
+	created := append(append([]string{}, createdDocker...), createdRaw...)
+	err := framework.RetryForDuration(func() error {
+		for _, name := range created {
+			if observations.isListed(name) {
+				return fmt.Errorf("container %q is still listed after being removed", name)
+			}
+		}
+		return nil
+	}, 30*time.Second)
+	stopPolling()
+	if err != nil {
+		t.Error(err)
+	}
+
+	for _, name := range created {
+		if !observations.wasSeen(name) {
+			t.Errorf("Container %q was never listed", name)
+		}
+	}
+	if len(observations.errors) != 0 {
+		t.Errorf("%d requests failed while churning containers, the first one with: %s", len(observations.errors), observations.errors[0])
+	}
+	if errors := countServerErrors(fm) - serverErrors; errors != 0 {
+		t.Errorf("The API served %d responses with a 5xx status while churning containers", errors)
+	}
+	maxRss := uint64(*soakMaxRssMb) * 1024 * 1024
+	if observations.maxRss > maxRss {
+		t.Errorf("The resident memory of cAdvisor reached %d bytes, more than the %d MB allowed", observations.maxRss, *soakMaxRssMb)
+	}
+	if growth := countGoroutines(fm) - goroutines; growth > *soakMaxGoroutineGrowth {
+		t.Errorf("cAdvisor has %d more goroutines than before churning containers, more than the %d allowed", growth, *soakMaxGoroutineGrowth)
+	}
+	discovery := discoveryLatencyAfter(fm, churnEnd)
+	if discovery.P95 > *soakMaxDiscoveryP95 {
+		t.Errorf("The 95th percentile of the discovery latency of the containers is %v (over %d containers), more than the %v allowed", discovery.P95, discovery.Count, *soakMaxDiscoveryP95)
+	}
+	t.Logf("Discovery latency of the containers: %+v", *discovery)
+	t.Logf("Churned %d Docker and %d raw containers, cAdvisor used at most %d bytes of resident memory", len(createdDocker), len(createdRaw), observations.maxRss)
+}
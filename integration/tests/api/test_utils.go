@@ -15,6 +15,7 @@
 package api
 
 import (
+	"math"
 	"testing"
 	"time"
 
@@ -36,8 +37,13 @@ func inDelta(t *testing.T, expected, actual, delta uint64, description string) {
 }
 
 // Checks that CPU stats are valid.
-func checkCpuStats(t *testing.T, stat info.CpuStats) {
+func checkCpuStats(t *testing.T, stats *info.ContainerStats) {
+	if stats.Missing(info.StatsSectionCpu) {
+		t.Logf("CPU stats failed to be collected at %v, skipping their checks", stats.Timestamp)
+		return
+	}
 	assert := assert.New(t)
+	stat := stats.Cpu
 
 	assert.NotEqual(0, stat.Usage.Total, "Total CPU usage should not be zero")
 	assert.NotEmpty(stat.Usage.PerCpu, "Per-core usage should not be empty")
@@ -46,17 +52,42 @@ func checkCpuStats(t *testing.T, stat info.CpuStats) {
 		totalUsage += usage
 	}
 	inDelta(t, stat.Usage.Total, totalUsage, uint64((5 * time.Millisecond).Nanoseconds()), "Per-core CPU usage")
-	inDelta(t, stat.Usage.Total, stat.Usage.User+stat.Usage.System, uint64((500 * time.Millisecond).Nanoseconds()), "User + system CPU usage")
+	// All usage is in info.CpuUsageUnit so user and system time add up to the total.
+	breakdownTolerance := uint64((500 * time.Millisecond).Nanoseconds())
+	inDelta(t, stat.Usage.Total, stat.Usage.User+stat.Usage.System, breakdownTolerance, "User + system CPU usage")
+	if stat.Usage.User > stat.Usage.Total+breakdownTolerance || stat.Usage.System > stat.Usage.Total+breakdownTolerance {
+		t.Errorf("User (%d) and system (%d) CPU usage should each be at most the total usage (%d)", stat.Usage.User, stat.Usage.System, stat.Usage.Total)
+	}
 	// TODO(rjnagal): Add verification for cpu load.
 }
 
-func checkMemoryStats(t *testing.T, stat info.MemoryStats) {
+func checkMemoryStats(t *testing.T, stats *info.ContainerStats) {
+	if stats.Missing(info.StatsSectionMemory) {
+		t.Logf("Memory stats failed to be collected at %v, skipping their checks", stats.Timestamp)
+		return
+	}
 	assert := assert.New(t)
+	stat := stats.Memory
 
 	assert.NotEqual(0, stat.Usage, "Memory usage should not be zero")
 	assert.NotEqual(0, stat.WorkingSet, "Memory working set should not be zero")
 	if stat.WorkingSet > stat.Usage {
 		t.Errorf("Memory working set (%d) should be at most equal to memory usage (%d)", stat.WorkingSet, stat.Usage)
 	}
-	// TODO(vmarmol): Add checks for ContainerData and HierarchicalData
+	// Swap is computed by subtracting the memory usage, it would be huge if
+	// that wrapped around.
+	if stat.Swap > math.MaxInt64 {
+		t.Errorf("Swap usage (%d) should not have wrapped around", stat.Swap)
+	}
+
+	// The hierarchical counters include those of the container itself.
+	checkAtLeast := func(field string, hierarchical, container uint64) {
+		if hierarchical < container {
+			t.Errorf("Hierarchical %s (%d) should be at least the container's %s (%d)", field, hierarchical, field, container)
+		}
+	}
+	checkAtLeast("pgfault", stat.HierarchicalData.Pgfault, stat.ContainerData.Pgfault)
+	checkAtLeast("pgmajfault", stat.HierarchicalData.Pgmajfault, stat.ContainerData.Pgmajfault)
+	checkAtLeast("pgpgin", stat.HierarchicalData.Pgpgin, stat.ContainerData.Pgpgin)
+	checkAtLeast("pgpgout", stat.HierarchicalData.Pgpgout, stat.ContainerData.Pgpgout)
 }
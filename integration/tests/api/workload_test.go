@@ -0,0 +1,82 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/integration/framework"
+	"github.com/stretchr/testify/require"
+)
+
+// Memory allocated by the workload is accounted to its container.
+func TestWorkloadMemoryUsage(t *testing.T) {
+	fm := framework.New(t)
+	defer fm.Cleanup()
+
+	containerId := fm.Docker().RunWorkload("--memory_mb=64")
+	waitForContainer(containerId, fm)
+
+	err := framework.RetryForDuration(func() error {
+		containerInfo, err := fm.Cadvisor().Client().DockerContainer(containerId, &info.ContainerInfoRequest{NumStats: 1})
+		if err != nil {
+			return err
+		}
+		if len(containerInfo.Stats) != 1 {
+			return fmt.Errorf("no stats returned for container %q", containerId)
+		}
+		if usage := containerInfo.Stats[0].Memory.Usage; usage < 64<<20 {
+			return fmt.Errorf("memory usage of container %q is %d bytes, expected at least %d", containerId, usage, 64<<20)
+		}
+		return nil
+	}, 30*time.Second)
+	require.NoError(t, err, "Timed out waiting for the memory usage of the workload")
+}
+
+// A busy loop of the workload uses about one core.
+func TestWorkloadCpuUsage(t *testing.T) {
+	fm := framework.New(t)
+	defer fm.Cleanup()
+
+	containerId := fm.Docker().RunWorkload("--cpu_workers=1")
+	waitForContainer(containerId, fm)
+
+	err := framework.RetryForDuration(func() error {
+		containerInfo, err := fm.Cadvisor().Client().DockerContainer(containerId, &info.ContainerInfoRequest{NumStats: 2})
+		if err != nil {
+			return err
+		}
+		if len(containerInfo.Stats) != 2 {
+			return fmt.Errorf("need 2 stats of container %q, received %d", containerId, len(containerInfo.Stats))
+		}
+		previous, current := containerInfo.Stats[0], containerInfo.Stats[1]
+		if current.Timestamp.Before(previous.Timestamp) {
+			previous, current = current, previous
+		}
+		elapsed := current.Timestamp.Sub(previous.Timestamp)
+		if elapsed == 0 {
+			return fmt.Errorf("stats of container %q have the same timestamp", containerId)
+		}
+		cores := float64(current.Cpu.Usage.Total-previous.Cpu.Usage.Total) / float64(elapsed)
+		if cores < 0.5 {
+			return fmt.Errorf("container %q used %.2f cores, expected about 1", containerId, cores)
+		}
+		return nil
+	}, 30*time.Second)
+	require.NoError(t, err, "Timed out waiting for the CPU usage of the workload")
+}
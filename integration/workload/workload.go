@@ -0,0 +1,102 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// A small workload for integration tests. It uses the resources requested
+// through its flags and then runs until killed. Built statically so it can be
+// the only content of a Docker image (see framework.DockerActions).
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"time"
+)
+
+var cpuWorkers = flag.Int("cpu_workers", 0, "Number of goroutines which busy loop")
+var memoryMb = flag.Int("memory_mb", 0, "Megabytes of memory to allocate and touch")
+var fds = flag.Int("fds", 0, "Number of file descriptors to open")
+var metricsPort = flag.Int("metrics_port", 0, "Port on which to serve metrics about the workload in the Prometheus text format, none if 0")
+
+// Kept so the memory and files are not garbage collected.
+var memory []byte
+var files []*os.File
+
+const pageSize = 4096
+
+func burnCpu() {
+	for {
+		for i := 0; i < 1000000; i++ {
+		}
+		// Yield so the garbage collector and the metrics handler are not
+		// starved.
+		runtime.Gosched()
+	}
+}
+
+func allocateMemory(megabytes int) {
+	memory = make([]byte, megabytes<<20)
+	// Touch every page so it is resident.
+	for i := 0; i < len(memory); i += pageSize {
+		memory[i] = 1
+	}
+}
+
+func openFds(count int) error {
+	for i := 0; i < count; i++ {
+		// The root directory exists even in an empty image.
+		file, err := os.Open("/")
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+	}
+	return nil
+}
+
+func serveMetrics(port int, start time.Time) error {
+	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprintf(w, "workload_cpu_workers %d\n", *cpuWorkers)
+		fmt.Fprintf(w, "workload_memory_bytes %d\n", len(memory))
+		fmt.Fprintf(w, "workload_open_fds %d\n", len(files))
+		fmt.Fprintf(w, "workload_uptime_seconds %f\n", time.Since(start).Seconds())
+	})
+	return http.ListenAndServe(fmt.Sprintf(":%d", port), nil)
+}
+
+func main() {
+	flag.Parse()
+	start := time.Now()
+
+	allocateMemory(*memoryMb)
+	err := openFds(*fds)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open %d file descriptors: %v\n", *fds, err)
+		os.Exit(1)
+	}
+	runtime.GOMAXPROCS(*cpuWorkers + 1)
+	for i := 0; i < *cpuWorkers; i++ {
+		go burnCpu()
+	}
+
+	if *metricsPort != 0 {
+		err = serveMetrics(*metricsPort, start)
+		fmt.Fprintf(os.Stderr, "Failed to serve metrics on port %d: %v\n", *metricsPort, err)
+		os.Exit(1)
+	}
+	select {}
+}
@@ -0,0 +1,68 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Returns the names of the ancestors of the container from the root, followed
+// by its own, e.g.: "/", "/a", "/a/b" for "/a/b".
+func AncestorNames(containerName string) []string {
+	names := []string{"/"}
+	containerName = path.Clean("/" + containerName)
+	if containerName == "/" {
+		return names
+	}
+	components := strings.Split(strings.TrimPrefix(containerName, "/"), "/")
+	for i := range components {
+		names = append(names, "/"+strings.Join(components[:i+1], "/"))
+	}
+	return names
+}
+
+// Builds the ancestry of the container, from the root, by looking up each of
+// its ancestors by name. The lookup returns nil for a name that is not
+// tracked, which is listed as such, and the information with the latest stats
+// of one that is. The container itself must be tracked.
+func GetAncestry(containerName string, lookup func(name string) (*info.ContainerInfo, error)) ([]info.ContainerAncestor, error) {
+	names := AncestorNames(containerName)
+	ancestry := make([]info.ContainerAncestor, 0, len(names))
+	for _, name := range names {
+		cinfo, err := lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		ancestor := info.ContainerAncestor{
+			Name: name,
+		}
+		if cinfo != nil {
+			ancestor.Tracked = true
+			ancestor.Spec = &cinfo.Spec
+			if len(cinfo.Stats) > 0 {
+				ancestor.Stats = cinfo.Stats[len(cinfo.Stats)-1]
+			}
+		}
+		ancestry = append(ancestry, ancestor)
+	}
+	if !ancestry[len(ancestry)-1].Tracked {
+		return nil, fmt.Errorf("unknown container %q", containerName)
+	}
+	return ancestry, nil
+}
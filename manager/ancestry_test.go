@@ -0,0 +1,59 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAncestorNames(t *testing.T) {
+	assert.Equal(t, []string{"/"}, AncestorNames("/"))
+	assert.Equal(t, []string{"/", "/a"}, AncestorNames("/a"))
+	assert.Equal(t, []string{"/", "/a", "/a/b", "/a/b/c"}, AncestorNames("/a/b/c"))
+	assert.Equal(t, []string{"/", "/a", "/a/b"}, AncestorNames("/a//b/"))
+}
+
+func TestGetContainerAncestry(t *testing.T) {
+	query := &info.ContainerInfoRequest{
+		NumStats: 4,
+	}
+	// "/a" is not tracked.
+	m, infosMap, _ := expectManagerWithContainers([]string{"/", "/a/b", "/a/b/c"}, query, t)
+
+	ancestry, err := m.GetContainerAncestry("/a/b/c")
+	require.NoError(t, err)
+	require.Equal(t, 4, len(ancestry))
+	assert.Equal(t, info.ContainerAncestor{Name: "/a"}, ancestry[1])
+	for i, name := range []string{"/", "/a", "/a/b", "/a/b/c"} {
+		assert.Equal(t, name, ancestry[i].Name)
+		expected, ok := infosMap[name]
+		if !ok {
+			continue
+		}
+		assert.True(t, ancestry[i].Tracked, "%q should be tracked", name)
+		require.NotNil(t, ancestry[i].Spec)
+		assert.Equal(t, expected.Spec, *ancestry[i].Spec)
+		assert.Equal(t, expected.Stats[len(expected.Stats)-1], ancestry[i].Stats)
+	}
+	// No container is created for the untracked ancestor.
+	assert.Equal(t, 3, len(m.containers))
+
+	_, err = m.GetContainerAncestry("/a")
+	assert.Error(t, err)
+}
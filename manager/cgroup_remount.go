@@ -0,0 +1,103 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/events"
+	"github.com/google/cadvisor/info/v2"
+)
+
+// Periodically checks whether the cgroup hierarchies were remounted.
+func (m *manager) watchForCgroupRemounts(quit chan error) {
+	ticker := time.Tick(m.config.CgroupRemountCheckInterval)
+	for {
+		select {
+		case <-ticker:
+			err := m.checkCgroupRemount()
+			if err != nil {
+				glog.Errorf("Failed to check for cgroup remounts: %v", err)
+			}
+		case <-quit:
+			quit <- nil
+			glog.Infof("Exiting cgroup remount watcher thread")
+			return
+		}
+	}
+}
+
+// Re-resolves where the cgroup hierarchies are mounted. If any moved, the
+// handlers of the containers re-resolve their cgroup paths and the containers
+// are detected again: those which vanished under the new layout are destroyed.
+func (m *manager) checkCgroupRemount() error {
+	previous := m.cgroupMounts.Subsystems()
+	changed, err := m.cgroupMounts.Refresh()
+	if err != nil {
+		return fmt.Errorf("failed to resolve the cgroup mounts: %v", err)
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	current := m.cgroupMounts.Subsystems()
+
+	remount := v2.CgroupRemount{
+		Subsystems:          changed,
+		PreviousMountPoints: make(map[string]string, len(changed)),
+		CurrentMountPoints:  make(map[string]string, len(changed)),
+	}
+	for _, subsystem := range changed {
+		if mountPoint, ok := previous.MountPoints[subsystem]; ok {
+			remount.PreviousMountPoints[subsystem] = mountPoint
+		}
+		if mountPoint, ok := current.MountPoints[subsystem]; ok {
+			remount.CurrentMountPoints[subsystem] = mountPoint
+		}
+	}
+	glog.Warningf("Cgroup hierarchies were remounted (from %v to %v), re-resolving the cgroup paths of the containers", remount.PreviousMountPoints, remount.CurrentMountPoints)
+
+	var handlers []container.ContainerHandler
+	m.containersLock.RLock()
+	for name, cont := range m.containers {
+		// Skip aliases.
+		if name.Name != cont.info.Name {
+			continue
+		}
+		cont.lock.Lock()
+		handlers = append(handlers, cont.handler)
+		cont.lock.Unlock()
+	}
+	m.containersLock.RUnlock()
+	for _, handler := range handlers {
+		if resolver, ok := handler.(container.CgroupPathsResolver); ok {
+			resolver.ResolveCgroupPaths()
+		}
+	}
+
+	err = m.detectSubcontainers("/")
+	if err != nil {
+		glog.Errorf("Failed to detect containers after the cgroup remount: %v", err)
+	}
+
+	return m.eventHandler.AddEvent(&events.Event{
+		ContainerName: "/",
+		Timestamp:     time.Now(),
+		EventType:     events.TypeCgroupRemount,
+		EventData:     remount,
+	})
+}
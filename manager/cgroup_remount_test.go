@@ -0,0 +1,188 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/container/libcontainer"
+	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+	itest "github.com/google/cadvisor/info/v1/test"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Mount table of a single memory hierarchy and the containers under each of
+// its possible mount points.
+type remountTestTable struct {
+	lock       sync.Mutex
+	mountPoint string
+	containers map[string][]string
+}
+
+func (self *remountTestTable) remount(mountPoint string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.mountPoint = mountPoint
+}
+
+func (self *remountTestTable) resolve() (libcontainer.CgroupSubsystems, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return libcontainer.CgroupSubsystems{
+		MountPoints: map[string]string{"memory": self.mountPoint},
+	}, nil
+}
+
+// Handler which resolves its cgroup paths against the table. The root lists
+// the containers under the hierarchy it last resolved.
+type remountTestHandler struct {
+	*container.MockContainerHandler
+	table  *remountTestTable
+	mounts *libcontainer.CgroupMounts
+
+	lock       sync.Mutex
+	cgroupPath string
+}
+
+func (self *remountTestHandler) ResolveCgroupPaths() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.cgroupPath = self.mounts.CgroupPaths(self.Name)["memory"]
+}
+
+func (self *remountTestHandler) GetCgroupPath(resource string) (string, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.cgroupPath, nil
+}
+
+func (self *remountTestHandler) ListContainers(listType container.ListType) ([]info.ContainerReference, error) {
+	if self.Name != "/" {
+		return nil, nil
+	}
+	self.lock.Lock()
+	// The cgroup of the root is the mount point itself.
+	mountPoint := self.cgroupPath
+	self.lock.Unlock()
+
+	self.table.lock.Lock()
+	defer self.table.lock.Unlock()
+	var ret []info.ContainerReference
+	for _, name := range self.table.containers[mountPoint] {
+		ret = append(ret, info.ContainerReference{Name: name})
+	}
+	return ret, nil
+}
+
+// Returns a factory of handlers which resolve their cgroup paths against the table.
+func newRemountTestFactory(table *remountTestTable, mounts *libcontainer.CgroupMounts) *container.FactoryForMockContainerHandler {
+	return &container.FactoryForMockContainerHandler{
+		Name: "remount-test",
+		WrapContainerHandlerFunc: func(name string, handler *container.MockContainerHandler) (container.ContainerHandler, error) {
+			handler.On("GetSpec").Return(info.ContainerSpec{}, nil)
+			handler.On("GetStats").Return(itest.GenerateRandomStats(1, 4, time.Second)[0], nil)
+			ret := &remountTestHandler{
+				MockContainerHandler: handler,
+				table:                table,
+				mounts:               mounts,
+			}
+			ret.ResolveCgroupPaths()
+			return ret, nil
+		},
+	}
+}
+
+func TestCgroupRemountReResolvesPaths(t *testing.T) {
+	table := &remountTestTable{
+		mountPoint: "/old/memory",
+		containers: map[string][]string{
+			"/old/memory": {"/a", "/b"},
+			"/new/memory": {"/a"},
+		},
+	}
+	mounts, err := libcontainer.NewCgroupMounts(table.resolve)
+	require.Nil(t, err)
+	defer container.ClearContainerHandlerFactories()
+	m := newTestManager(newTestConfig(), newRemountTestFactory(table, mounts))
+	m.cgroupMounts = mounts
+	require.Nil(t, m.createContainer("/"))
+	require.Nil(t, m.detectSubcontainers("/"))
+	defer func() {
+		for name, cont := range m.containers {
+			if name.Name == cont.info.Name {
+				cont.StopAndWait(time.Second)
+			}
+		}
+	}()
+
+	cgroupPaths := func() map[string]string {
+		ret := make(map[string]string)
+		for name, cont := range m.containers {
+			cgroupPath, err := cont.handler.GetCgroupPath("memory")
+			require.Nil(t, err)
+			ret[name.Name] = cgroupPath
+		}
+		return ret
+	}
+	assert.Equal(t, map[string]string{
+		"/":  "/old/memory",
+		"/a": "/old/memory/a",
+		"/b": "/old/memory/b",
+	}, cgroupPaths())
+
+	// Nothing happens until the hierarchy is remounted.
+	require.Nil(t, m.checkCgroupRemount())
+	request := events.NewRequest()
+	request.EventType[events.TypeCgroupRemount] = true
+	evs, err := m.eventHandler.GetEvents(request)
+	require.Nil(t, err)
+	assert.Empty(t, evs)
+
+	// Only /a exists under the new layout.
+	table.remount("/new/memory")
+	require.Nil(t, m.checkCgroupRemount())
+	assert.Equal(t, map[string]string{
+		"/":  "/new/memory",
+		"/a": "/new/memory/a",
+	}, cgroupPaths())
+
+	deletions := events.NewRequest()
+	deletions.EventType[events.TypeContainerDeletion] = true
+	evs, err = m.eventHandler.GetEvents(deletions)
+	require.Nil(t, err)
+	var deleted []string
+	for _, ev := range evs {
+		deleted = append(deleted, ev.ContainerName)
+	}
+	sort.Strings(deleted)
+	assert.Equal(t, []string{"/b"}, deleted)
+
+	evs, err = m.eventHandler.GetEvents(request)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(evs))
+	assert.Equal(t, "/", evs[0].ContainerName)
+	assert.Equal(t, v2.CgroupRemount{
+		Subsystems:          []string{"memory"},
+		PreviousMountPoints: map[string]string{"memory": "/old/memory"},
+		CurrentMountPoints:  map[string]string{"memory": "/new/memory"},
+	}, evs[0].EventData)
+}
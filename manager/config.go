@@ -0,0 +1,179 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"math"
+	"time"
+)
+
+// Configuration of a manager and of the housekeeping of the containers it
+// tracks. Each manager has its own, so that several can run in the same
+// process with different configurations. The zero value disables most
+// features and has no housekeeping interval: start from DefaultConfig().
+type Config struct {
+	// Interval between container housekeepings.
+	HousekeepingInterval time.Duration
+
+	// Largest interval to allow between container housekeepings.
+	MaxHousekeepingInterval time.Duration
+
+	// Interval between housekeepings of the root container, which
+	// machine-level usage is read from. Zero uses HousekeepingInterval.
+	RootHousekeepingInterval time.Duration
+
+	// Whether to allow the housekeeping interval to be dynamic.
+	AllowDynamicHousekeeping bool
+
+	// Amount by which the wall-clock and monotonic time elapsed between two
+	// samples must differ for the wall clock to be considered to have jumped.
+	TimeJumpThreshold time.Duration
+
+	// Whether to log how long each section of the housekeepings that take
+	// longer than 100ms (or half the housekeeping interval) took.
+	ProfilingHousekeeping bool
+
+	// Number of consecutive housekeepings taking longer than the housekeeping
+	// interval after which collection for a container is degraded. Zero
+	// disables degradation.
+	HousekeepingOverrunThreshold int
+
+	// Number of housekeeping intervals a single housekeeping may run for
+	// before it is considered stuck and restarted. Zero disables the watchdog.
+	HousekeepingWatchdogIntervals int
+
+	// Maximum number of times the housekeeping of a container is restarted
+	// by the watchdog within the window, and the window.
+	HousekeepingWatchdogMaxRestarts   int
+	HousekeepingWatchdogRestartWindow time.Duration
+
+	// Interval between global housekeepings, which detect new containers.
+	GlobalHousekeepingInterval time.Duration
+
+	// Whether to log the usage of the cAdvisor container.
+	LogCadvisorUsage bool
+
+	// Whether to monitor the Docker daemon and cAdvisor processes as
+	// containers under /services.
+	MonitorSystemServices bool
+
+	// Label whose value groups the containers under /groups. Empty disables grouping.
+	GroupByLabel string
+
+	// Comma-separated list of files to read the machine ID from, the first
+	// one that exists is used.
+	MachineIdFiles string
+
+	// Path the root filesystem of the host is mounted at, which the OS image
+	// of the machine is read from.
+	RootFs string
+
+	// Interval between checks of whether the cgroup hierarchies were
+	// remounted. Zero disables the checks.
+	CgroupRemountCheckInterval time.Duration
+
+	// Number of container changes kept to answer differential listings.
+	ContainerDiffWindow int
+
+	// Number of retries and delay before the first retry, doubled after each,
+	// of the creation of a container that failed to be created.
+	ContainerCreationRetries int
+	ContainerCreationBackoff time.Duration
+
+	// Time a Docker container that disappeared is kept before it is
+	// considered deleted. Zero deletes such containers immediately.
+	DockerRestartGrace time.Duration
+
+	// Age under which deleted containers are considered short-lived, their
+	// stats are written as those of an aggregate. Zero disables it.
+	ShortLivedContainerThreshold time.Duration
+
+	// Number of deleted containers kept and how long they are kept for.
+	DeletedContainersCount int
+	DeletedContainersAge   time.Duration
+
+	// Number of housekeeping intervals after which the newest stats of a
+	// container are reported as stale, and as dead.
+	FreshnessStaleIntervals float64
+	FreshnessDeadIntervals  float64
+
+	// Whether to sample the CPU times of the processes of each container,
+	// how often and up to how many processes (zero is no limit).
+	EnableCpuSampling       bool
+	CpuSamplingInterval     time.Duration
+	CpuSamplingMaxProcesses int
+
+	// Thrashing score above which the memory of a container is considered
+	// under pressure, zero disables memory pressure events, and how long it
+	// must stay above it for an event to be emitted.
+	MemoryPressureThreshold float64
+	MemoryPressureDuration  time.Duration
+
+	// Whether to cross-check the stats with the cgroup files, how often, and
+	// the relative tolerance of the comparison.
+	SelfCheck          bool
+	SelfCheckInterval  time.Duration
+	SelfCheckTolerance float64
+}
+
+// Returns the configuration cAdvisor runs with by default.
+func DefaultConfig() Config {
+	return Config{
+		HousekeepingInterval:              1 * time.Second,
+		MaxHousekeepingInterval:           60 * time.Second,
+		AllowDynamicHousekeeping:          true,
+		TimeJumpThreshold:                 5 * time.Second,
+		HousekeepingOverrunThreshold:      3,
+		HousekeepingWatchdogIntervals:     10,
+		HousekeepingWatchdogMaxRestarts:   3,
+		HousekeepingWatchdogRestartWindow: time.Hour,
+		GlobalHousekeepingInterval:        1 * time.Minute,
+		MachineIdFiles:                    "/etc/machine-id,/var/lib/dbus/machine-id",
+		RootFs:                            "/",
+		CgroupRemountCheckInterval:        10 * time.Second,
+		ContainerDiffWindow:               1024,
+		ContainerCreationRetries:          5,
+		ContainerCreationBackoff:          time.Second,
+		DockerRestartGrace:                30 * time.Second,
+		DeletedContainersCount:            100,
+		DeletedContainersAge:              time.Hour,
+		FreshnessStaleIntervals:           2,
+		FreshnessDeadIntervals:            10,
+		CpuSamplingInterval:               10 * time.Second,
+		CpuSamplingMaxProcesses:           1000,
+		MemoryPressureThreshold:           1,
+		MemoryPressureDuration:            30 * time.Second,
+		SelfCheckInterval:                 time.Minute,
+		SelfCheckTolerance:                0.01,
+	}
+}
+
+// Returns the interval between housekeepings of the container before any
+// dynamic adjustment: that of the root container may be overridden, up to
+// MaxHousekeepingInterval.
+func (self *Config) baseHousekeepingInterval(containerName string) time.Duration {
+	if containerName != "/" || self.RootHousekeepingInterval <= 0 {
+		return self.HousekeepingInterval
+	}
+	if self.RootHousekeepingInterval > self.MaxHousekeepingInterval {
+		return self.MaxHousekeepingInterval
+	}
+	return self.RootHousekeepingInterval
+}
+
+// Decay value used for load average smoothing. Interval length of 10 seconds is used.
+func (self *Config) loadDecay() float64 {
+	return math.Exp(float64(-1 * self.HousekeepingInterval.Seconds() / 10))
+}
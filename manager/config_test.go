@@ -0,0 +1,108 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/events"
+	"github.com/google/cadvisor/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Returns the default configuration for a test to adjust.
+func newTestConfig() *Config {
+	config := DefaultConfig()
+	return &config
+}
+
+// Returns a manager with the specified configuration whose containers are
+// created by the specified factory, the only one registered (none if nil).
+func newTestManager(config *Config, factory container.ContainerHandlerFactory) *manager {
+	container.ClearContainerHandlerFactories()
+	if factory != nil {
+		container.RegisterContainerHandlerFactory(factory)
+	}
+	return &manager{
+		config:               *config,
+		containers:           make(map[namespacedContainerName]*containerData),
+		memoryStorage:        memory.New(60, nil),
+		eventHandler:         events.NewEventManager(),
+		startupTime:          time.Now(),
+		creationFailures:     make(map[string]*creationFailure),
+		missingContainers:    make(map[string]*missingContainer),
+		housekeepingRestarts: make(map[string]*housekeepingRestarts),
+	}
+}
+
+func TestDefaultConfig(t *testing.T) {
+	config := DefaultConfig()
+	assert.Equal(t, time.Second, config.baseHousekeepingInterval("/"))
+	assert.Equal(t, time.Second, config.baseHousekeepingInterval("/docker/a"))
+	assert.True(t, config.AllowDynamicHousekeeping)
+	assert.InDelta(t, 0.905, config.loadDecay(), 0.001)
+}
+
+func TestManagersHaveSeparateConfigs(t *testing.T) {
+	defer container.ClearContainerHandlerFactories()
+	fast := newTestConfig()
+	fast.HousekeepingInterval = 100 * time.Millisecond
+	fast.EnableCpuSampling = true
+	fast.MemoryPressureThreshold = 0
+	slow := newTestConfig()
+	slow.HousekeepingInterval = 5 * time.Second
+	slow.RootHousekeepingInterval = 10 * time.Second
+	slow.SelfCheck = true
+	factory := newListingTestFactory(nil)
+	fastManager, slowManager := newTestManager(fast, factory), newTestManager(slow, factory)
+	defer func() {
+		for _, m := range []*manager{fastManager, slowManager} {
+			for name := range m.containers {
+				m.destroyContainer(name.Name)
+			}
+		}
+	}()
+
+	// The managers track the same containers, each as configured.
+	for _, m := range []*manager{fastManager, slowManager} {
+		require.NoError(t, m.createContainer("/"))
+		require.NoError(t, m.createContainer("/a"))
+	}
+	getContainer := func(m *manager, name string) *containerData {
+		cont, err := m.getContainerData(name)
+		require.NoError(t, err)
+		return cont
+	}
+	fastRoot, fastA := getContainer(fastManager, "/"), getContainer(fastManager, "/a")
+	slowRoot, slowA := getContainer(slowManager, "/"), getContainer(slowManager, "/a")
+	assert.Equal(t, 100*time.Millisecond, fastRoot.baseHousekeepingInterval)
+	assert.Equal(t, 100*time.Millisecond, fastA.baseHousekeepingInterval)
+	assert.Equal(t, 10*time.Second, slowRoot.baseHousekeepingInterval)
+	assert.Equal(t, 5*time.Second, slowA.baseHousekeepingInterval)
+	assert.NotNil(t, fastA.cpuSampler)
+	assert.Nil(t, fastA.thrashing)
+	assert.Nil(t, fastA.selfCheck)
+	assert.Nil(t, slowA.cpuSampler)
+	assert.NotNil(t, slowA.thrashing)
+	assert.NotNil(t, slowA.selfCheck)
+
+	// A manager keeps its own copy of the configuration.
+	fast.HousekeepingInterval = time.Hour
+	require.NoError(t, fastManager.createContainer("/b"))
+	assert.Equal(t, 100*time.Millisecond, getContainer(fastManager, "/b").baseHousekeepingInterval)
+}
@@ -15,9 +15,8 @@
 package manager
 
 import (
-	"flag"
 	"fmt"
-	"math"
+	"reflect"
 	"sort"
 	"sync"
 	"time"
@@ -25,21 +24,18 @@ import (
 	"github.com/docker/docker/pkg/units"
 	"github.com/golang/glog"
 	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/events"
 	info "github.com/google/cadvisor/info/v1"
 	"github.com/google/cadvisor/info/v2"
 	"github.com/google/cadvisor/storage/memory"
 	"github.com/google/cadvisor/summary"
+	"github.com/google/cadvisor/utils/clock"
 	"github.com/google/cadvisor/utils/cpuload"
+	"github.com/google/cadvisor/utils/cpusampling"
+	"github.com/google/cadvisor/utils/leak"
+	"github.com/google/cadvisor/utils/timing"
 )
 
-// Housekeeping interval.
-var HousekeepingInterval = flag.Duration("housekeeping_interval", 1*time.Second, "Interval between container housekeepings")
-var maxHousekeepingInterval = flag.Duration("max_housekeeping_interval", 60*time.Second, "Largest interval to allow between container housekeepings")
-var allowDynamicHousekeeping = flag.Bool("allow_dynamic_housekeeping", true, "Whether to allow the housekeeping interval to be dynamic")
-
-// Decay value used for load average smoothing. Interval length of 10 seconds is used.
-var loadDecay = math.Exp(float64(-1 * (*HousekeepingInterval).Seconds() / 10))
-
 type containerInfo struct {
 	info.ContainerReference
 	Subcontainers []info.ContainerReference
@@ -47,6 +43,9 @@ type containerInfo struct {
 }
 
 type containerData struct {
+	// Configuration of the manager the container is tracked by, read-only.
+	config *Config
+
 	handler              container.ContainerHandler
 	info                 containerInfo
 	memoryStorage        *memory.InMemoryStorage
@@ -57,24 +56,207 @@ type containerData struct {
 	housekeepingInterval time.Duration
 	lastUpdatedTime      time.Time
 	lastErrorTime        time.Time
+	eventHandler         events.EventManager
+
+	// Called with the reference of the container when its spec changes, once
+	// it was first read. May be nil.
+	specChanged func(info.ContainerReference)
+
+	// Status of the stats collection, protected by lock.
+	collectionStatus v2.CollectionStatus
+
+	// Number of consecutive housekeepings that did not overrun the housekeeping interval.
+	consecutiveFastHousekeepings int
+
+	// Interval between housekeepings while collection is degraded.
+	degradedInterval time.Duration
+
+	// Interval the dynamic housekeeping interval starts from and returns to.
+	baseHousekeepingInterval time.Duration
+
+	// Whether an operation of the handler timed out since the last
+	// housekeeping was recorded, protected by lock.
+	timedOut bool
+
+	// Clock used to timestamp stats that the handler did not timestamp monotonically.
+	clock clock.Clock
+
+	// Times at which the container was discovered and its first stats were
+	// stored, and the time it was created if the latencies between them are
+	// measured (zero otherwise), protected by lock.
+	discoveredAt time.Time
+	firstStatsAt time.Time
+	createdAt    time.Time
+
+	// Called with the latency from the creation of the container to its
+	// first stats once they are stored. May be nil.
+	firstStatsStored func(time.Duration)
+
+	// Wall-clock and monotonic timestamps of the last stats collected. Used to detect time jumps.
+	lastStatsTimestamp time.Time
+	lastStatsMonotonic time.Duration
+
+	// Whether the wall clock jumped since the previous stats were collected.
+	timeJumped bool
 
 	// Whether to log the usage of this container when it is updated.
 	logUsage bool
 
-	// Tells the container to stop.
+	// Start of the housekeeping in progress (zero if none) and end of the
+	// last one, protected by lock. Used by the watchdog to find stuck housekeepings.
+	tickStart time.Time
+	lastTick  time.Time
+
+	// Whether the watchdog replaced this container, protected by lock. Its
+	// housekeeping exits and the stats of the stuck housekeeping are dropped.
+	poisoned bool
+
+	// Number of housekeepings that failed to collect each section of the
+	// stats (e.g.: info.StatsSectionMemory), protected by lock.
+	sectionFailures map[string]uint64
+
+	// Errors of the last housekeeping for the sections of the stats the
+	// container has, by section, protected by lock.
+	collectionErrors map[string]string
+
+	// Number of errors reading the stats by class (e.g.:
+	// container.ErrorClassPermission), and the sections whose reads were
+	// denied and are no longer expected, protected by lock.
+	readErrors     map[string]uint64
+	deniedSections map[string]bool
+
+	// Samples the CPU times of the processes of the container, nil unless
+	// EnableCpuSampling is configured. Monotonic time of the last sample and
+	// the breakdown by process name, states and context switches it produced.
+	cpuSampler      *cpusampling.Sampler
+	lastCpuSample   time.Duration
+	processCpu      *info.ProcessCpuStats
+	processStates   *info.ProcessStateStats
+	contextSwitches *info.ContextSwitchStats
+
+	// Detects the memory of the container thrashing, nil if memory pressure
+	// events are disabled.
+	thrashing *thrashingDetector
+
+	// Cross-checks the stats with the cgroup files, nil unless SelfCheck is configured.
+	selfCheck *selfChecker
+
+	// Aggregates the notices of the lifecycle of the container into the
+	// periodic summary of all containers. May be nil.
+	logs *logAggregator
+
+	// Serializes the collections of the stats.
+	collectLock sync.Mutex
+
+	// Time of the last collection of the stats forced through the API,
+	// protected by lock.
+	lastForcedCollection time.Time
+
+	// State of the housekeeping, protected by lock.
+	state containerState
+
+	// Tells the housekeeping started last to stop, protected by lock.
 	stop chan bool
+
+	// Closed when the housekeeping started last exits, protected by lock.
+	housekeepingDone chan struct{}
 }
 
+// Lifecycle of the housekeeping of a container.
+type containerState int
+
+const (
+	// Housekeeping was not started yet.
+	containerNew containerState = iota
+	// Housekeeping is running.
+	containerRunning
+	// Housekeeping was stopped or exited on its own (e.g.: when the watchdog
+	// replaced the container).
+	containerStopped
+)
+
+func (self containerState) String() string {
+	switch self {
+	case containerNew:
+		return "new"
+	case containerRunning:
+		return "running"
+	case containerStopped:
+		return "stopped"
+	}
+	return fmt.Sprintf("unknown(%d)", int(self))
+}
+
+// Starts the housekeeping of the container. Starting a running container does
+// nothing. A stopped container can't be started again, only reattached.
 func (c *containerData) Start() error {
-	go c.housekeeping()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	switch c.state {
+	case containerRunning:
+		return nil
+	case containerStopped:
+		return fmt.Errorf("housekeeping of container %q was stopped", c.info.Name)
+	}
+	c.startLocked()
 	return nil
 }
 
+// Starts a housekeeping goroutine. c.lock must be held.
+func (c *containerData) startLocked() {
+	stop := make(chan bool, 1)
+	done := make(chan struct{})
+	c.state = containerRunning
+	c.stop = stop
+	c.housekeepingDone = done
+	go func() {
+		c.housekeeping(stop)
+		c.lock.Lock()
+		c.state = containerStopped
+		c.lock.Unlock()
+		close(done)
+	}()
+}
+
+// Stops the housekeeping of the container without waiting for it to exit.
+// Stopping a stopped container does nothing. A container stopped before it
+// was started is never started.
 func (c *containerData) Stop() error {
-	c.stop <- true
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.state == containerRunning {
+		// Buffered, and only sent once per housekeeping, so this never blocks.
+		c.stop <- true
+	}
+	c.state = containerStopped
 	return nil
 }
 
+// Stops the housekeeping of the container and waits up to timeout for it to
+// exit.
+func (c *containerData) StopAndWait(timeout time.Duration) error {
+	c.lock.Lock()
+	done := c.housekeepingDone
+	c.lock.Unlock()
+	err := c.Stop()
+	if err != nil || done == nil {
+		return err
+	}
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("housekeeping of container %q did not stop within %v", c.info.Name, timeout)
+	}
+}
+
+// Returns the state of the housekeeping of the container.
+func (c *containerData) State() containerState {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.state
+}
+
 func (c *containerData) allowErrorLogging() bool {
 	if time.Since(c.lastErrorTime) > time.Minute {
 		c.lastErrorTime = time.Now()
@@ -85,7 +267,10 @@ func (c *containerData) allowErrorLogging() bool {
 
 func (c *containerData) GetInfo() (*containerInfo, error) {
 	// Get spec and subcontainers.
-	if time.Since(c.lastUpdatedTime) > 5*time.Second {
+	c.lock.Lock()
+	stale := time.Since(c.lastUpdatedTime) > 5*time.Second
+	c.lock.Unlock()
+	if stale {
 		err := c.updateSpec()
 		if err != nil {
 			return nil, err
@@ -94,12 +279,15 @@ func (c *containerData) GetInfo() (*containerInfo, error) {
 		if err != nil {
 			return nil, err
 		}
+		c.lock.Lock()
 		c.lastUpdatedTime = time.Now()
+		c.lock.Unlock()
 	}
 	// Make a copy of the info for the user.
 	c.lock.Lock()
 	defer c.lock.Unlock()
-	return &c.info, nil
+	ret := c.info
+	return &ret, nil
 }
 
 func (c *containerData) DerivedStats() (v2.DerivedStats, error) {
@@ -109,7 +297,150 @@ func (c *containerData) DerivedStats() (v2.DerivedStats, error) {
 	return c.summaryReader.DerivedStats()
 }
 
-func newContainerData(containerName string, memoryStorage *memory.InMemoryStorage, handler container.ContainerHandler, loadReader cpuload.CpuLoadReader, logUsage bool) (*containerData, error) {
+// Marks the container as missing since the specified time. Zero marks it as present.
+func (c *containerData) setMissingSince(since time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.collectionStatus.MissingSince = since
+}
+
+// Points the stopped container at the handler of its reappeared self (e.g.:
+// after the Docker daemon restarted) and resumes its housekeeping. Stats
+// continue to be appended to its history.
+func (c *containerData) reattach(handler container.ContainerHandler, ref info.ContainerReference) {
+	c.lock.Lock()
+	done := c.housekeepingDone
+	c.lock.Unlock()
+	if done != nil {
+		<-done
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	cleanupHandler(c.handler)
+	c.handler = handler
+	c.info.ContainerReference = ref
+	c.collectionStatus.MissingSince = time.Time{}
+	c.startLocked()
+}
+
+// Releases the resources of a handler no longer used, if it holds any (see
+// container.Cleaner).
+func cleanupHandler(handler container.ContainerHandler) {
+	if cleaner, ok := handler.(container.Cleaner); ok {
+		cleaner.Cleanup()
+	}
+}
+
+// Returns the full reference of the container: its name, aliases, and
+// namespace.
+func (c *containerData) reference() info.ContainerReference {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.info.ContainerReference
+}
+
+// Returns the CPU shares of the container and whether it has CPU isolation.
+func (c *containerData) cpuShares() (uint64, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.info.Spec.Cpu.Limit, c.info.Spec.HasCpu
+}
+
+// Marks the container as replaced by the watchdog.
+func (c *containerData) poison() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.poisoned = true
+}
+
+func (c *containerData) isPoisoned() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.poisoned
+}
+
+// Records the start of a housekeeping. Returns false if the container was poisoned.
+func (c *containerData) beginTick() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.tickStart = time.Now()
+	return !c.poisoned
+}
+
+// Records the end of a housekeeping. Returns false if the container was poisoned.
+func (c *containerData) endTick() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.tickStart = time.Time{}
+	c.lastTick = time.Now()
+	return !c.poisoned
+}
+
+// Returns for how long the housekeeping in progress has been running and
+// whether that is longer than the specified number of housekeeping intervals.
+// Since the previous housekeeping ended before this one started, the container
+// has not been housekept for at least as long.
+func (c *containerData) stuckTick(now time.Time, intervals int) (time.Duration, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.tickStart.IsZero() || c.poisoned {
+		return 0, false
+	}
+	running := now.Sub(c.tickStart)
+	return running, running > time.Duration(intervals)*c.collectionStatus.HousekeepingInterval
+}
+
+// Returns whether the last known reference and spec of the container are
+// selected by the filter, without reading them from the handler.
+func (c *containerData) matches(filter *ContainerFilter) bool {
+	if filter == nil {
+		return true
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return filter.Matches(c.info.ContainerReference, c.info.Spec)
+}
+
+func (c *containerData) CollectionStatus() v2.CollectionStatus {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	status := c.collectionStatus
+	status.State = c.state.String()
+	if len(c.sectionFailures) != 0 {
+		status.SectionFailures = make(map[string]uint64, len(c.sectionFailures))
+		for section, failures := range c.sectionFailures {
+			status.SectionFailures[section] = failures
+		}
+	}
+	if len(c.collectionErrors) != 0 {
+		status.CollectionErrors = make(map[string]string, len(c.collectionErrors))
+		for section, err := range c.collectionErrors {
+			status.CollectionErrors[section] = err
+		}
+	}
+	if len(c.readErrors) != 0 {
+		status.ReadErrors = make(map[string]uint64, len(c.readErrors))
+		for class, count := range c.readErrors {
+			status.ReadErrors[class] = count
+		}
+	}
+	for section := range c.deniedSections {
+		status.DeniedSections = append(status.DeniedSections, section)
+	}
+	sort.Strings(status.DeniedSections)
+	if c.selfCheck != nil {
+		status.SelfChecks = c.selfCheck.checks
+		if len(c.selfCheck.discrepancies) != 0 {
+			status.SelfCheckDiscrepancies = make(map[string]uint64, len(c.selfCheck.discrepancies))
+			for field, count := range c.selfCheck.discrepancies {
+				status.SelfCheckDiscrepancies[field] = count
+			}
+		}
+	}
+	return status
+}
+
+func newContainerData(containerName string, memoryStorage *memory.InMemoryStorage, handler container.ContainerHandler, config *Config, loadReader cpuload.CpuLoadReader, eventHandler events.EventManager, logUsage bool) (*containerData, error) {
 	if memoryStorage == nil {
 		return nil, fmt.Errorf("nil memory storage")
 	}
@@ -121,16 +452,31 @@ func newContainerData(containerName string, memoryStorage *memory.InMemoryStorag
 		return nil, err
 	}
 
+	housekeepingInterval := config.baseHousekeepingInterval(containerName)
 	cont := &containerData{
-		handler:              handler,
-		memoryStorage:        memoryStorage,
-		housekeepingInterval: *HousekeepingInterval,
-		loadReader:           loadReader,
-		logUsage:             logUsage,
-		loadAvg:              -1.0, // negative value indicates uninitialized.
-		stop:                 make(chan bool, 1),
+		config:                   config,
+		handler:                  handler,
+		memoryStorage:            memoryStorage,
+		housekeepingInterval:     housekeepingInterval,
+		baseHousekeepingInterval: housekeepingInterval,
+		loadReader:               loadReader,
+		eventHandler:             eventHandler,
+		logUsage:                 logUsage,
+		clock:                    clock.RealClock,
+		loadAvg:                  -1.0, // negative value indicates uninitialized.
+	}
+	if config.EnableCpuSampling {
+		cont.cpuSampler = cpusampling.New(config.CpuSamplingMaxProcesses)
 	}
+	if config.MemoryPressureThreshold > 0 {
+		cont.thrashing = newThrashingDetector(config.MemoryPressureThreshold, config.MemoryPressureDuration)
+	}
+	if config.SelfCheck {
+		cont.selfCheck = newSelfChecker(config.SelfCheckInterval, config.SelfCheckTolerance)
+	}
+	cont.discoveredAt = cont.clock.Now()
 	cont.info.ContainerReference = ref
+	cont.collectionStatus.HousekeepingInterval = cont.housekeepingInterval
 
 	err = cont.updateSpec()
 	if err != nil {
@@ -141,64 +487,182 @@ func newContainerData(containerName string, memoryStorage *memory.InMemoryStorag
 		cont.summaryReader = nil
 		glog.Warningf("Failed to create summary reader for %q: %v", ref.Name, err)
 	}
+	leak.Track(cont, "containerData")
 
 	return cont, nil
 }
 
 // Determine when the next housekeeping should occur.
 func (self *containerData) nextHousekeeping(lastHousekeeping time.Time) time.Time {
-	if *allowDynamicHousekeeping {
+	if self.config.AllowDynamicHousekeeping {
 		var empty time.Time
 		stats, err := self.memoryStorage.RecentStats(self.info.Name, empty, empty, 2)
 		if err != nil {
 			if self.allowErrorLogging() {
 				glog.Warningf("Failed to get RecentStats(%q) while determining the next housekeeping: %v", self.info.Name, err)
 			}
+		} else if len(stats) == 2 && stats[1].TimeJump {
+			// The wall clock jumped during the last interval, it says nothing about the usage.
+		} else if len(stats) == 2 && stats[0].Restored && !stats[1].Restored {
+			// The last interval spans a restart of cAdvisor.
 		} else if len(stats) == 2 {
-			// TODO(vishnuk): Use no processes as a signal.
 			// Raise the interval if usage hasn't changed in the last housekeeping.
-			if stats[0].StatsEq(stats[1]) && (self.housekeepingInterval < *maxHousekeepingInterval) {
+			if stats[0].StatsEq(stats[1]) && (self.housekeepingInterval < self.config.MaxHousekeepingInterval) {
 				self.housekeepingInterval *= 2
-				if self.housekeepingInterval > *maxHousekeepingInterval {
-					self.housekeepingInterval = *maxHousekeepingInterval
+				// An unchanged container without processes of its own stays idle
+				// until one is added, go straight to the longest interval.
+				if hasNoProcesses(stats[1]) || self.housekeepingInterval > self.config.MaxHousekeepingInterval {
+					self.housekeepingInterval = self.config.MaxHousekeepingInterval
 				}
-				glog.V(3).Infof("Raising housekeeping interval for %q to %v", self.info.Name, self.housekeepingInterval)
-			} else if self.housekeepingInterval != *HousekeepingInterval {
+				self.logs.intervalRaised(self.info.Name, self.housekeepingInterval)
+			} else if self.housekeepingInterval != self.baseHousekeepingInterval {
 				// Lower interval back to the baseline.
-				self.housekeepingInterval = *HousekeepingInterval
-				glog.V(3).Infof("Lowering housekeeping interval for %q to %v", self.info.Name, self.housekeepingInterval)
+				self.housekeepingInterval = self.baseHousekeepingInterval
+				self.logs.intervalLowered(self.info.Name, self.housekeepingInterval)
 			}
 		}
 	}
 
-	return lastHousekeeping.Add(self.housekeepingInterval)
+	// Housekeep less often while collection is degraded.
+	interval := self.housekeepingInterval
+	self.lock.Lock()
+	if self.collectionStatus.Degraded && self.degradedInterval > interval {
+		interval = self.degradedInterval
+	}
+	self.collectionStatus.HousekeepingInterval = interval
+	self.lock.Unlock()
+
+	return lastHousekeeping.Add(interval)
+}
+
+// Whether the stats report that the container itself has no processes.
+func hasNoProcesses(stats *info.ContainerStats) bool {
+	return stats.ProcessCount != nil && *stats.ProcessCount == 0
+}
+
+// Records how long a housekeeping took. After HousekeepingOverrunThreshold
+// consecutive housekeepings that take longer than the housekeeping interval,
+// collection is degraded: the container is housekept less often and its
+// expensive collectors are disabled. Collection is restored after the same
+// number of consecutive housekeepings that fit within the interval.
+func (c *containerData) recordHousekeepingDuration(duration time.Duration) {
+	threshold := c.config.HousekeepingOverrunThreshold
+
+	c.lock.Lock()
+	overrun := duration > c.baseHousekeepingInterval || c.timedOut
+	c.timedOut = false
+	status := &c.collectionStatus
+	status.LastHousekeepingDuration = duration
+	if overrun {
+		status.ConsecutiveOverruns++
+		status.TotalOverruns++
+		c.consecutiveFastHousekeepings = 0
+	} else {
+		status.ConsecutiveOverruns = 0
+		c.consecutiveFastHousekeepings++
+	}
+
+	var eventType events.EventType
+	changed := false
+	switch {
+	case !status.Degraded && overrun && threshold > 0 && status.ConsecutiveOverruns >= threshold:
+		// Give housekeeping at least twice the time it is taking.
+		status.Degraded = true
+		status.DegradedSince = time.Now()
+		c.degradedInterval = 2 * duration
+		if c.degradedInterval > c.config.MaxHousekeepingInterval {
+			c.degradedInterval = c.config.MaxHousekeepingInterval
+		}
+		eventType = events.TypeCollectionDegraded
+		changed = true
+	case status.Degraded && overrun:
+		// Still overrunning, back off further.
+		c.degradedInterval *= 2
+		if c.degradedInterval > c.config.MaxHousekeepingInterval {
+			c.degradedInterval = c.config.MaxHousekeepingInterval
+		}
+	case status.Degraded && c.consecutiveFastHousekeepings >= threshold:
+		status.Degraded = false
+		status.DegradedSince = time.Time{}
+		c.degradedInterval = 0
+		eventType = events.TypeCollectionRestored
+		changed = true
+	}
+	newStatus := *status
+	c.lock.Unlock()
+
+	if !changed {
+		return
+	}
+	if newStatus.Degraded {
+		glog.Warningf("[%s] Housekeeping overran its interval %d consecutive times (last took %v), degrading collection", c.info.Name, newStatus.ConsecutiveOverruns, duration)
+	} else {
+		glog.Infof("[%s] Housekeeping is fast again, restoring collection", c.info.Name)
+	}
+	c.handler.SetExpensiveCollectorsEnabled(!newStatus.Degraded)
+	if c.eventHandler != nil {
+		err := c.eventHandler.AddEvent(&events.Event{
+			ContainerName: c.info.Name,
+			Timestamp:     time.Now(),
+			EventType:     eventType,
+			EventData:     newStatus,
+		})
+		if err != nil {
+			glog.Errorf("[%s] Failed to add collection status event: %v", c.info.Name, err)
+		}
+	}
 }
 
-func (c *containerData) housekeeping() {
+// Counts an operation of the handler that timed out, if the error is a
+// timeout. The housekeeping it happened during counts as an overrun.
+func (c *containerData) recordTimeout(err error) {
+	if !container.IsTimeout(err) {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.collectionStatus.Timeouts++
+	c.timedOut = true
+}
+
+// Housekeeps the container until told to stop through stop.
+func (c *containerData) housekeeping(stop chan bool) {
 	// Long housekeeping is either 100ms or half of the housekeeping interval.
 	longHousekeeping := 100 * time.Millisecond
-	if *HousekeepingInterval/2 < longHousekeeping {
-		longHousekeeping = *HousekeepingInterval / 2
+	if c.baseHousekeepingInterval/2 < longHousekeeping {
+		longHousekeeping = c.baseHousekeepingInterval / 2
 	}
 
 	// Housekeep every second.
-	glog.Infof("Start housekeeping for container %q\n", c.info.Name)
+	c.logs.housekeepingStarted(c.info.Name)
 	lastHousekeeping := time.Now()
 	for {
 		select {
-		case <-c.stop:
+		case <-stop:
 			// Stop housekeeping when signaled.
 			return
 		default:
 			// Perform housekeeping.
+			if !c.beginTick() {
+				return
+			}
 			start := time.Now()
-			c.housekeepingTick()
+			sections := c.housekeepingTick()
+			if !c.endTick() {
+				// Replaced by the watchdog while stuck.
+				return
+			}
 
 			// Log if housekeeping took too long.
 			duration := time.Since(start)
 			if duration >= longHousekeeping {
-				glog.V(3).Infof("[%s] Housekeeping took %s", c.info.Name, duration)
+				if c.config.ProfilingHousekeeping {
+					glog.Infof("[%s] Housekeeping took %s (%v)", c.info.Name, duration, sections)
+				} else {
+					glog.V(3).Infof("[%s] Housekeeping took %s", c.info.Name, duration)
+				}
 			}
+			c.recordHousekeepingDuration(duration)
 		}
 
 		// Log usage if asked to do so.
@@ -212,6 +676,8 @@ func (c *containerData) housekeeping() {
 				}
 			} else if len(stats) < numSamples {
 				// Ignore, not enough stats yet.
+			} else if missingUsage(stats) {
+				// Ignore, the usage can't be computed across missing sections.
 			} else {
 				usageCpuNs := uint64(0)
 				for i := range stats {
@@ -221,34 +687,55 @@ func (c *containerData) housekeeping() {
 				}
 				usageMemory := stats[numSamples-1].Memory.Usage
 
-				instantUsageInCores := float64(stats[numSamples-1].Cpu.Usage.Total-stats[numSamples-2].Cpu.Usage.Total) / float64(stats[numSamples-1].Timestamp.Sub(stats[numSamples-2].Timestamp).Nanoseconds())
-				usageInCores := float64(usageCpuNs) / float64(stats[numSamples-1].Timestamp.Sub(stats[0].Timestamp).Nanoseconds())
+				instantUsageInCores := float64(stats[numSamples-1].Cpu.Usage.Total-stats[numSamples-2].Cpu.Usage.Total) / float64(stats[numSamples-1].Elapsed(stats[numSamples-2]).Nanoseconds())
+				usageInCores := float64(usageCpuNs) / float64(stats[numSamples-1].Elapsed(stats[0]).Nanoseconds())
 				usageInHuman := units.HumanSize(float64(usageMemory))
 				glog.Infof("[%s] %.3f cores (average: %.3f cores), %s of memory", c.info.Name, instantUsageInCores, usageInCores, usageInHuman)
 			}
 		}
 
 		// Schedule the next housekeeping. Sleep until that time.
+		if c.timeJumped {
+			// Don't try to catch up on (or wait for) housekeepings across a wall-clock jump.
+			lastHousekeeping = time.Now()
+		}
 		nextHousekeeping := c.nextHousekeeping(lastHousekeeping)
 		if time.Now().Before(nextHousekeeping) {
-			time.Sleep(nextHousekeeping.Sub(time.Now()))
+			select {
+			case <-stop:
+				return
+			case <-time.After(nextHousekeeping.Sub(time.Now())):
+			}
 		}
 		lastHousekeeping = nextHousekeeping
 	}
 }
 
-func (c *containerData) housekeepingTick() {
-	err := c.updateStats()
+// Updates the stats of the container. Returns how long each section of the update took.
+func (c *containerData) housekeepingTick() *timing.Sections {
+	sections := timing.NewSections()
+	err := c.updateStats(sections)
 	if err != nil {
-		if c.allowErrorLogging() {
-			glog.Infof("Failed to update stats for container \"%s\": %s", c.info.Name, err)
-		}
+		c.logs.collectionFailed(c.info.Name, err)
+	}
+
+	breakdown := make([]v2.HousekeepingSection, 0, len(sections.Sections()))
+	for _, section := range sections.Sections() {
+		breakdown = append(breakdown, v2.HousekeepingSection{
+			Name:     section.Name,
+			Duration: section.Duration,
+		})
 	}
+	c.lock.Lock()
+	c.collectionStatus.LastHousekeepingSections = breakdown
+	c.lock.Unlock()
+	return sections
 }
 
 func (c *containerData) updateSpec() error {
 	spec, err := c.handler.GetSpec()
 	if err != nil {
+		c.recordTimeout(err)
 		// Ignore errors if the container is dead.
 		if !c.handler.Exists() {
 			return nil
@@ -256,8 +743,14 @@ func (c *containerData) updateSpec() error {
 		return err
 	}
 	c.lock.Lock()
-	defer c.lock.Unlock()
+	degradeSpec(&spec, c.deniedSections)
+	changed := c.specChanged != nil && !reflect.DeepEqual(c.info.Spec, spec)
 	c.info.Spec = spec
+	ref := c.info.ContainerReference
+	c.lock.Unlock()
+	if changed {
+		c.specChanged(ref)
+	}
 	return nil
 }
 
@@ -268,32 +761,87 @@ func (c *containerData) updateLoad(newLoad uint64) {
 	if c.loadAvg < 0 {
 		c.loadAvg = float64(newLoad) // initialize to the first seen sample for faster stabilization.
 	} else {
+		loadDecay := c.config.loadDecay()
 		c.loadAvg = c.loadAvg*loadDecay + float64(newLoad)*(1.0-loadDecay)
 	}
 	glog.V(3).Infof("New load for %q: %v. latest sample: %d", c.info.Name, c.loadAvg, newLoad)
 }
 
-func (c *containerData) updateStats() error {
-	stats, statsErr := c.handler.GetStats()
+// Records how long each section of the update takes in sections.
+func (c *containerData) updateStats(sections *timing.Sections) error {
+	_, err := c.collectStats(sections)
+	return err
+}
+
+// Collects the stats of the container and stores them. Returns the stored
+// stats, which may be partial along with an error, nil if none were stored.
+// Collections (by the housekeeping or forced) run one at a time.
+func (c *containerData) collectStats(sections *timing.Sections) (*info.ContainerStats, error) {
+	c.collectLock.Lock()
+	defer c.collectLock.Unlock()
+
+	var stats *info.ContainerStats
+	var statsErr error
+	selfCheckBefore := c.beginSelfCheck()
+	if timedHandler, ok := c.handler.(container.TimedStatsHandler); ok {
+		stats, statsErr = timedHandler.GetStatsTimed(sections)
+	} else {
+		endStatsRead := sections.Time("stats read")
+		stats, statsErr = c.handler.GetStats()
+		endStatsRead()
+	}
 	if statsErr != nil {
+		c.recordTimeout(statsErr)
 		// Ignore errors if the container is dead.
 		if !c.handler.Exists() {
-			return nil
+			return nil, nil
+		}
+
+		if partial, ok := statsErr.(*container.PartialStatsError); ok && stats != nil {
+			collected := c.recordPartialFailure(stats, partial)
+			if c.benignFailures(partial.Failures) {
+				if !collected {
+					return nil, nil
+				}
+				statsErr = nil
+			} else if !collected {
+				return nil, fmt.Errorf("failed to collect any stats: %v", partial)
+			}
+		} else {
+			c.lock.Lock()
+			class := c.countReadErrorLocked(statsErr)
+			c.lock.Unlock()
+			// The container is racing away.
+			if class == container.ErrorClassGone {
+				return nil, nil
+			}
 		}
 
-		// Stats may be partially populated, push those before we return an error.
-		statsErr = fmt.Errorf("%v, continuing to push stats", statsErr)
+		if statsErr != nil {
+			// Stats may be partially populated, push those before we return an error.
+			statsErr = fmt.Errorf("%v, continuing to push stats", statsErr)
+		}
+	} else if stats != nil {
+		c.lock.Lock()
+		c.collectionErrors = nil
+		c.lock.Unlock()
 	}
 	if stats == nil {
-		return statsErr
+		return nil, statsErr
 	}
+	c.endSelfCheck(selfCheckBefore, stats)
+	c.detectTimeJump(stats)
+	c.detectMemoryPressure(stats)
+	c.sampleProcessCpu(sections, stats)
 	if c.loadReader != nil {
 		// TODO(vmarmol): Cache this path.
 		path, err := c.handler.GetCgroupPath("cpu")
 		if err == nil {
+			endProcessScan := sections.Time("process scan")
 			loadStats, err := c.loadReader.GetCpuLoad(c.info.Name, path)
+			endProcessScan()
 			if err != nil {
-				return fmt.Errorf("failed to get load stat for %q - path %q, error %s", c.info.Name, path, err)
+				return nil, fmt.Errorf("failed to get load stat for %q - path %q, error %s", c.info.Name, path, err)
 			}
 			stats.TaskStats = loadStats
 			c.updateLoad(loadStats.NrRunning)
@@ -302,31 +850,157 @@ func (c *containerData) updateStats() error {
 		}
 	}
 	if c.summaryReader != nil {
+		endSummary := sections.Time("summary")
 		err := c.summaryReader.AddSample(*stats)
+		endSummary()
 		if err != nil {
 			// Ignore summary errors for now.
 			glog.V(2).Infof("failed to add summary stats for %q: %v", c.info.Name, err)
 		}
 	}
-	ref, err := c.handler.ContainerReference()
+	if c.isPoisoned() {
+		// Replaced by the watchdog, which started collecting into the same history.
+		return nil, nil
+	}
+	// The reference cached at creation (or reattachment) is passed rather than
+	// asking the handler every tick, which can be a Docker inspect.
+	endStorageWrite := sections.Time("storage write")
+	err := c.memoryStorage.AddStats(c.reference(), stats)
+	endStorageWrite()
 	if err != nil {
-		// Ignore errors if the container is dead.
-		if !c.handler.Exists() {
-			return nil
+		return nil, err
+	}
+	c.markFirstStats(c.clock.Now())
+	return stats, statsErr
+}
+
+// Returns whether any of the stats is missing the CPU or memory usage.
+func missingUsage(stats []*info.ContainerStats) bool {
+	for _, stat := range stats {
+		if stat.Missing(info.StatsSectionCpu) || stat.Missing(info.StatsSectionMemory) {
+			return true
 		}
-		return err
 	}
-	err = c.memoryStorage.AddStats(ref, stats)
-	if err != nil {
-		return err
+	return false
+}
+
+// Annotates the stats with the sections that failed to be collected and
+// counts the failures. Returns whether any of the sections of the spec of the
+// container was collected, the stats are not worth storing otherwise.
+func (c *containerData) recordPartialFailure(stats *info.ContainerStats, partial *container.PartialStatsError) bool {
+	for _, section := range partial.Sections() {
+		stats.MarkMissing(section)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.sectionFailures == nil {
+		c.sectionFailures = make(map[string]uint64)
+	}
+	for _, section := range partial.Sections() {
+		c.sectionFailures[section]++
+	}
+	c.recordReadErrorsLocked(partial.Failures)
+
+	spec := c.info.Spec
+	c.collectionErrors = nil
+	for _, section := range partial.Sections() {
+		if !hasSection(&spec, section) {
+			continue
+		}
+		if c.collectionErrors == nil {
+			c.collectionErrors = make(map[string]string)
+		}
+		c.collectionErrors[section] = partial.Failures[section].Error()
+	}
+
+	specSections := map[string]bool{
+		info.StatsSectionCpu:        spec.HasCpu,
+		info.StatsSectionMemory:     spec.HasMemory,
+		info.StatsSectionDiskIo:     spec.HasDiskIo,
+		info.StatsSectionNetwork:    spec.HasNetwork,
+		info.StatsSectionFilesystem: spec.HasFilesystem,
+	}
+	for section, has := range specSections {
+		if has && !stats.Missing(section) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns whether the spec says the container has the resource whose stats
+// are collected into the section. Sections without a flag in the spec are
+// only collected for the containers which have them.
+func hasSection(spec *info.ContainerSpec, section string) bool {
+	switch section {
+	case info.StatsSectionCpu:
+		return spec.HasCpu
+	case info.StatsSectionMemory:
+		return spec.HasMemory
+	case info.StatsSectionDiskIo:
+		return spec.HasDiskIo
+	case info.StatsSectionNetwork:
+		return spec.HasNetwork
+	case info.StatsSectionFilesystem:
+		return spec.HasFilesystem
+	case info.StatsSectionPressure:
+		return spec.HasPressure
+	case info.StatsSectionPids:
+		return spec.HasPids
+	}
+	return true
+}
+
+// Flags the stats if the wall clock jumped since the previous stats were collected.
+// Since all containers see the same jump, only the root container emits an event for it.
+func (c *containerData) detectTimeJump(stats *info.ContainerStats) {
+	if stats.MonotonicTimestamp == 0 {
+		stats.MonotonicTimestamp = c.clock.Monotonic()
+	}
+	lastTimestamp, lastMonotonic := c.lastStatsTimestamp, c.lastStatsMonotonic
+	c.lastStatsTimestamp, c.lastStatsMonotonic = stats.Timestamp, stats.MonotonicTimestamp
+	c.timeJumped = false
+	if lastTimestamp.IsZero() || c.config.TimeJumpThreshold <= 0 {
+		return
+	}
+
+	jump := v2.TimeJump{
+		WallClockElapsed: stats.Timestamp.Sub(lastTimestamp),
+		MonotonicElapsed: stats.MonotonicTimestamp - lastMonotonic,
+	}
+	skew := jump.WallClockElapsed - jump.MonotonicElapsed
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew <= c.config.TimeJumpThreshold {
+		return
+	}
+	stats.TimeJump = true
+	c.timeJumped = true
+
+	if c.info.Name != "/" {
+		return
+	}
+	glog.Warningf("Wall clock jumped by %v (%v elapsed, %v monotonic)", jump.WallClockElapsed-jump.MonotonicElapsed, jump.WallClockElapsed, jump.MonotonicElapsed)
+	if c.eventHandler != nil {
+		err := c.eventHandler.AddEvent(&events.Event{
+			ContainerName: c.info.Name,
+			Timestamp:     stats.Timestamp,
+			EventType:     events.TypeTimeJump,
+			EventData:     jump,
+		})
+		if err != nil {
+			glog.Errorf("Failed to add time jump event: %v", err)
+		}
 	}
-	return statsErr
 }
 
 func (c *containerData) updateSubcontainers() error {
 	var subcontainers info.ContainerReferenceSlice
 	subcontainers, err := c.handler.ListContainers(container.ListSelf)
 	if err != nil {
+		c.recordTimeout(err)
 		// Ignore errors if the container is dead.
 		if !c.handler.Exists() {
 			return nil
@@ -0,0 +1,121 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"sync"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Name of the pseudo-container whose stats are the counts of the containers
+// tracked by cAdvisor (see info.ContainerCountStats). A sample is written
+// through the storage pipeline at each global housekeeping. It is not a
+// subcontainer of "/" and is left out of listings.
+const MetaContainerName = "/.cadvisor-meta"
+
+// Counts the containers created and deleted. Class is thread-safe.
+type containerCounter struct {
+	lock    sync.Mutex
+	created uint64
+	deleted uint64
+
+	// Time of the previous sample and the number of containers created and
+	// deleted at that time.
+	lastSample time.Time
+	lastChurn  uint64
+}
+
+func (self *containerCounter) containerCreated() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.created++
+}
+
+func (self *containerCounter) containerDeleted() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.deleted++
+}
+
+// Returns the counts at the specified time, with the specified number of
+// containers tracked. The churn rate is computed since the previous sample, or
+// since the specified start for the first one.
+func (self *containerCounter) sample(now time.Time, tracked int, start time.Time) *info.ContainerCountStats {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.lastSample.IsZero() {
+		self.lastSample = start
+	}
+	stats := &info.ContainerCountStats{
+		Tracked: uint64(tracked),
+		Created: self.created,
+		Deleted: self.deleted,
+	}
+	churn := self.created + self.deleted
+	if elapsed := now.Sub(self.lastSample); elapsed > 0 {
+		stats.ChurnPerMinute = float64(churn-self.lastChurn) / elapsed.Minutes()
+	}
+	self.lastSample, self.lastChurn = now, churn
+	return stats
+}
+
+// Returns the number of containers tracked, aliases aside.
+func (m *manager) trackedContainers() int {
+	m.containersLock.RLock()
+	defer m.containersLock.RUnlock()
+	tracked := 0
+	for name := range m.containers {
+		if name.Namespace == "" {
+			tracked++
+		}
+	}
+	return tracked
+}
+
+// Writes a sample of the container counts as stats of MetaContainerName.
+func (m *manager) writeContainerCounts(now time.Time) error {
+	counts := m.containerCounts.sample(now, m.trackedContainers(), m.startupTime)
+	counts.DiscoveryLatency, counts.FirstStatsLatency = m.containerLatencies.percentiles()
+	stats := &info.ContainerStats{
+		Timestamp:       now,
+		ContainerCounts: counts,
+	}
+	return m.memoryStorage.AddStats(info.ContainerReference{Name: MetaContainerName}, stats)
+}
+
+// Returns the information of MetaContainerName, which only has stats.
+func (m *manager) getMetaContainerInfo(query *info.ContainerInfoRequest) (*info.ContainerInfo, error) {
+	stats, err := m.memoryStorage.RecentStats(MetaContainerName, query.Start, query.End, query.NumStats)
+	if err != nil {
+		return nil, err
+	}
+	// A sample is taken at each global housekeeping.
+	var newest time.Time
+	if newestStats, err := m.memoryStorage.RecentStats(MetaContainerName, time.Time{}, time.Time{}, 1); err == nil && len(newestStats) != 0 {
+		newest = newestStats[0].Timestamp
+	}
+	return &info.ContainerInfo{
+		ContainerReference: info.ContainerReference{
+			Name: MetaContainerName,
+		},
+		Spec: info.ContainerSpec{
+			CreationTime: m.startupTime,
+		},
+		Stats:     stats,
+		Freshness: m.config.getFreshness(time.Now(), newest, m.config.GlobalHousekeepingInterval),
+	}, nil
+}
@@ -0,0 +1,153 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+)
+
+// Kinds of changes to the containers tracked.
+type containerChangeType int
+
+const (
+	containerAdded containerChangeType = iota
+	containerRemoved
+	containerChanged
+)
+
+type containerChange struct {
+	revision   uint64
+	changeType containerChangeType
+	ref        info.ContainerReference
+}
+
+// Numbers the changes to the containers tracked and keeps the most recent
+// ones to tell clients what changed since a revision. The zero value keeps no
+// changes. Class is thread-safe.
+type containerRevisions struct {
+	lock sync.Mutex
+
+	// Revision of the last change.
+	revision uint64
+
+	// Revision up to which the changes were dropped from the window. Changes
+	// since older revisions are no longer known.
+	dropped uint64
+
+	// Most recent changes, oldest first, at most window of them.
+	changes []containerChange
+	window  int
+}
+
+// Returns the revision of the container listing when cAdvisor started at the
+// specified time. Revisions start at that time in milliseconds since the epoch
+// so that those handed out by a previous run of cAdvisor are older than the
+// ones of this run, and thus known to require a resync.
+func firstContainerRevision(start time.Time) uint64 {
+	return uint64(start.UnixNano() / int64(time.Millisecond))
+}
+
+// Records a change to the specified container.
+func (self *containerRevisions) record(changeType containerChangeType, ref info.ContainerReference) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.revision++
+	change := containerChange{
+		revision:   self.revision,
+		changeType: changeType,
+		ref:        ref,
+	}
+	if self.window <= 0 {
+		self.dropped = self.revision
+		return
+	}
+	if len(self.changes) >= self.window {
+		self.dropped = self.changes[0].revision
+		copy(self.changes, self.changes[1:])
+		self.changes[len(self.changes)-1] = change
+	} else {
+		self.changes = append(self.changes, change)
+	}
+}
+
+// Net change to a container over several changes.
+type netChange struct {
+	// Whether the container existed at the revision diffed against and
+	// whether it exists now.
+	existed bool
+	exists  bool
+	ref     info.ContainerReference
+}
+
+// Returns the changes to the containers since the specified revision.
+func (self *containerRevisions) diff(since uint64) v2.ContainerDiff {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	diff := v2.ContainerDiff{
+		Revision: self.revision,
+	}
+	if since < self.dropped || since > self.revision {
+		diff.ResyncRequired = true
+		return diff
+	}
+
+	// The changes are in order, skip those the client already knows of.
+	i := sort.Search(len(self.changes), func(i int) bool {
+		return self.changes[i].revision > since
+	})
+	net := make(map[string]*netChange)
+	for _, change := range self.changes[i:] {
+		name := change.ref.Name
+		n, ok := net[name]
+		if !ok {
+			// The first change tells whether the container existed.
+			n = &netChange{
+				existed: change.changeType != containerAdded,
+			}
+			net[name] = n
+		}
+		n.exists = change.changeType != containerRemoved
+		n.ref = change.ref
+	}
+
+	for name, n := range net {
+		switch {
+		case n.existed && n.exists:
+			diff.Changed = append(diff.Changed, name)
+		case n.existed:
+			diff.Removed = append(diff.Removed, name)
+		case n.exists:
+			diff.Added = append(diff.Added, n.ref)
+		}
+	}
+	sort.Sort(info.ContainerReferenceSlice(diff.Added))
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff
+}
+
+func (m *manager) GetContainerDiff(since uint64) (v2.ContainerDiff, error) {
+	return m.containerRevisions.diff(since), nil
+}
+
+// Records a change to the spec of the container.
+func (m *manager) containerSpecChanged(ref info.ContainerReference) {
+	m.containerRevisions.record(containerChanged, ref)
+}
@@ -0,0 +1,199 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/google/cadvisor/container"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestContainerRevisions(window int) *containerRevisions {
+	return &containerRevisions{
+		revision: 100,
+		dropped:  100,
+		window:   window,
+	}
+}
+
+func TestContainerDiff(t *testing.T) {
+	revisions := newTestContainerRevisions(10)
+	revisions.record(containerAdded, info.ContainerReference{Name: "/a"})
+	revisions.record(containerAdded, info.ContainerReference{Name: "/b"})
+	since := revisions.diff(100).Revision
+
+	revisions.record(containerAdded, info.ContainerReference{Name: "/d", Aliases: []string{"d"}})
+	revisions.record(containerAdded, info.ContainerReference{Name: "/c"})
+	revisions.record(containerRemoved, info.ContainerReference{Name: "/a"})
+	revisions.record(containerChanged, info.ContainerReference{Name: "/b"})
+	// Added and removed since: left out.
+	revisions.record(containerAdded, info.ContainerReference{Name: "/e"})
+	revisions.record(containerRemoved, info.ContainerReference{Name: "/e"})
+
+	assert.Equal(t, v2.ContainerDiff{
+		Revision: 108,
+		Added: []info.ContainerReference{
+			{Name: "/c"},
+			{Name: "/d", Aliases: []string{"d"}},
+		},
+		Removed: []string{"/a"},
+		Changed: []string{"/b"},
+	}, revisions.diff(since))
+	assert.Equal(t, v2.ContainerDiff{Revision: 108}, revisions.diff(108))
+}
+
+func TestContainerDiffOfReplacedContainer(t *testing.T) {
+	revisions := newTestContainerRevisions(10)
+	revisions.record(containerAdded, info.ContainerReference{Name: "/a"})
+	revisions.record(containerRemoved, info.ContainerReference{Name: "/a"})
+	revisions.record(containerAdded, info.ContainerReference{Name: "/a"})
+
+	assert.Equal(t, v2.ContainerDiff{
+		Revision: 103,
+		Changed:  []string{"/a"},
+	}, revisions.diff(101))
+}
+
+func TestContainerDiffWindowExpiry(t *testing.T) {
+	revisions := newTestContainerRevisions(3)
+	for i := 0; i < 5; i++ {
+		revisions.record(containerAdded, info.ContainerReference{Name: fmt.Sprintf("/%d", i)})
+	}
+
+	// Revisions 103 to 105 are kept, the changes since 101 are not all known.
+	diff := revisions.diff(101)
+	assert.True(t, diff.ResyncRequired)
+	assert.Equal(t, uint64(105), diff.Revision)
+	assert.Empty(t, diff.Added)
+
+	diff = revisions.diff(102)
+	assert.False(t, diff.ResyncRequired)
+	assert.Equal(t, []info.ContainerReference{{Name: "/2"}, {Name: "/3"}, {Name: "/4"}}, diff.Added)
+}
+
+func TestContainerDiffRequiresResyncForUnknownRevisions(t *testing.T) {
+	revisions := newTestContainerRevisions(10)
+	revisions.record(containerAdded, info.ContainerReference{Name: "/a"})
+
+	// Before the start, e.g.: from a previous run of cAdvisor.
+	assert.True(t, revisions.diff(0).ResyncRequired)
+	assert.True(t, revisions.diff(99).ResyncRequired)
+	// After the last change.
+	assert.True(t, revisions.diff(102).ResyncRequired)
+	assert.False(t, revisions.diff(100).ResyncRequired)
+}
+
+func TestContainerDiffWithoutWindow(t *testing.T) {
+	revisions := newTestContainerRevisions(0)
+	assert.False(t, revisions.diff(100).ResyncRequired)
+	revisions.record(containerAdded, info.ContainerReference{Name: "/a"})
+	assert.True(t, revisions.diff(100).ResyncRequired)
+	assert.False(t, revisions.diff(101).ResyncRequired)
+}
+
+// Clients following the diffs while containers come and go must end up with
+// the containers that exist.
+func TestContainerDiffUnderConcurrentChurn(t *testing.T) {
+	const writers = 4
+	const churn = 200
+	revisions := newTestContainerRevisions(writers * churn * 2)
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < churn; i++ {
+				ref := info.ContainerReference{Name: fmt.Sprintf("/%d/%d", w, i)}
+				revisions.record(containerAdded, ref)
+				if i%2 == 0 {
+					revisions.record(containerRemoved, ref)
+				}
+			}
+		}(w)
+	}
+
+	known := make(map[string]bool)
+	since := uint64(100)
+	follow := func() {
+		diff := revisions.diff(since)
+		require.False(t, diff.ResyncRequired)
+		for _, ref := range diff.Added {
+			assert.False(t, known[ref.Name], "%q added twice", ref.Name)
+			known[ref.Name] = true
+		}
+		for _, name := range diff.Removed {
+			assert.True(t, known[name], "unknown %q removed", name)
+			delete(known, name)
+		}
+		since = diff.Revision
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for {
+		select {
+		case <-done:
+			follow()
+			assert.Len(t, known, writers*churn/2)
+			for w := 0; w < writers; w++ {
+				for i := 1; i < churn; i += 2 {
+					assert.True(t, known[fmt.Sprintf("/%d/%d", w, i)])
+				}
+			}
+			return
+		default:
+			follow()
+		}
+	}
+}
+
+// Returns the spec it is given instead of the mocked one.
+type changingSpecHandler struct {
+	*container.MockContainerHandler
+	spec info.ContainerSpec
+}
+
+func (self *changingSpecHandler) GetSpec() (info.ContainerSpec, error) {
+	return self.spec, nil
+}
+
+func TestUpdateSpecReportsChanges(t *testing.T) {
+	cd, mockHandler, _ := newTestContainerData(t)
+	handler := &changingSpecHandler{
+		MockContainerHandler: mockHandler,
+		spec:                 cd.info.Spec,
+	}
+	cd.handler = handler
+	var changed []string
+	cd.specChanged = func(ref info.ContainerReference) {
+		changed = append(changed, ref.Name)
+	}
+
+	require.Nil(t, cd.updateSpec())
+	assert.Empty(t, changed)
+
+	handler.spec.Labels = map[string]string{"tier": "web"}
+	require.Nil(t, cd.updateSpec())
+	assert.Equal(t, []string{containerName}, changed)
+}
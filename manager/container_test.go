@@ -18,15 +18,24 @@ package manager
 
 import (
 	"fmt"
+	"os"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/events"
 	info "github.com/google/cadvisor/info/v1"
 	itest "github.com/google/cadvisor/info/v1/test"
+	"github.com/google/cadvisor/info/v2"
 	"github.com/google/cadvisor/storage/memory"
+	stest "github.com/google/cadvisor/storage/test"
+	"github.com/google/cadvisor/utils/clock/fakeclock"
+	"github.com/google/cadvisor/utils/cpusampling"
+	"github.com/google/cadvisor/utils/timing"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
@@ -34,13 +43,18 @@ const containerName = "/container"
 
 // Create a containerData instance for a test.
 func setupContainerData(t *testing.T, spec info.ContainerSpec) (*containerData, *container.MockContainerHandler, *memory.InMemoryStorage) {
+	return setupContainerDataWithConfig(t, spec, newTestConfig())
+}
+
+// Create a containerData instance with the specified configuration for a test.
+func setupContainerDataWithConfig(t *testing.T, spec info.ContainerSpec, config *Config) (*containerData, *container.MockContainerHandler, *memory.InMemoryStorage) {
 	mockHandler := container.NewMockContainerHandler(containerName)
 	mockHandler.On("GetSpec").Return(
 		spec,
 		nil,
 	)
 	memoryStorage := memory.New(60, nil)
-	ret, err := newContainerData(containerName, memoryStorage, mockHandler, nil, false)
+	ret, err := newContainerData(containerName, memoryStorage, mockHandler, config, nil, nil, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -132,7 +146,7 @@ func TestUpdateStats(t *testing.T) {
 		nil,
 	)
 
-	err := cd.updateStats()
+	err := cd.updateStats(timing.NewSections())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -141,6 +155,198 @@ func TestUpdateStats(t *testing.T) {
 	mockHandler.AssertExpectations(t)
 }
 
+// Counts the calls to ContainerReference().
+type referenceCountingHandler struct {
+	*container.MockContainerHandler
+	referenceCalls int
+}
+
+func (self *referenceCountingHandler) ContainerReference() (info.ContainerReference, error) {
+	self.referenceCalls++
+	return self.MockContainerHandler.ContainerReference()
+}
+
+func TestUpdateStatsPassesCachedReference(t *testing.T) {
+	mockHandler := container.NewMockContainerHandler(containerName)
+	mockHandler.Aliases = []string{"alias", "id"}
+	mockHandler.On("GetSpec").Return(itest.GenerateRandomContainerSpec(4), nil)
+	mockHandler.On("GetStats").Return(itest.GenerateRandomStats(1, 4, time.Second)[0], nil)
+	handler := &referenceCountingHandler{MockContainerHandler: mockHandler}
+	backend := &stest.MockStorageDriver{}
+	expectedRef, err := mockHandler.ContainerReference()
+	require.NoError(t, err)
+	backend.On("AddStats", expectedRef, mock.Anything).Return(nil)
+
+	cd, err := newContainerData(containerName, memory.New(60, backend), handler, newTestConfig(), nil, nil, false)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, cd.updateStats(timing.NewSections()))
+	}
+
+	// The reference is only asked for when the container is created.
+	assert.Equal(t, 1, handler.referenceCalls)
+	backend.AssertNumberOfCalls(t, "AddStats", 3)
+	backend.AssertExpectations(t)
+}
+
+func TestUpdateStatsWithPartialFailure(t *testing.T) {
+	allSections := info.ContainerSpec{
+		HasCpu:        true,
+		HasMemory:     true,
+		HasDiskIo:     true,
+		HasNetwork:    true,
+		HasFilesystem: true,
+	}
+	testCases := []struct {
+		name    string
+		spec    info.ContainerSpec
+		failed  []string
+		stored  bool
+		missing []string
+	}{
+		{
+			name:    "memory",
+			spec:    allSections,
+			failed:  []string{info.StatsSectionMemory},
+			stored:  true,
+			missing: []string{info.StatsSectionMemory},
+		},
+		{
+			name:    "cpu and network",
+			spec:    allSections,
+			failed:  []string{info.StatsSectionNetwork, info.StatsSectionCpu},
+			stored:  true,
+			missing: []string{info.StatsSectionCpu, info.StatsSectionNetwork},
+		},
+		{
+			name:    "pressure",
+			spec:    allSections,
+			failed:  []string{info.StatsSectionPressure},
+			stored:  true,
+			missing: []string{info.StatsSectionPressure},
+		},
+		{
+			name:   "all sections",
+			spec:   allSections,
+			failed: []string{info.StatsSectionCpu, info.StatsSectionMemory, info.StatsSectionDiskIo, info.StatsSectionNetwork, info.StatsSectionFilesystem},
+			stored: false,
+		},
+		{
+			name:   "all sections of the spec",
+			spec:   info.ContainerSpec{HasCpu: true},
+			failed: []string{info.StatsSectionCpu},
+			stored: false,
+		},
+		{
+			name:    "sections not in the spec",
+			spec:    info.ContainerSpec{HasCpu: true},
+			failed:  []string{info.StatsSectionMemory, info.StatsSectionNetwork},
+			stored:  true,
+			missing: []string{info.StatsSectionMemory, info.StatsSectionNetwork},
+		},
+	}
+	for _, tc := range testCases {
+		stats := itest.GenerateRandomStats(1, 4, time.Second)[0]
+		partial := container.NewPartialStatsError()
+		for _, section := range tc.failed {
+			partial.Add(section, fmt.Errorf("failed to read %s", section))
+		}
+		cd, mockHandler, memoryStorage := setupContainerData(t, tc.spec)
+		mockHandler.On("GetStats").Return(stats, partial)
+		mockHandler.On("Exists").Return(true)
+
+		// Partial failures are still reported, the stats are only dropped if nothing was collected.
+		assert.NotNil(t, cd.updateStats(timing.NewSections()), tc.name)
+
+		var empty time.Time
+		stored, err := memoryStorage.RecentStats(containerName, empty, empty, -1)
+		if !tc.stored {
+			assert.NotNil(t, err, "%s: no stats should be stored", tc.name)
+		} else if assert.Nil(t, err, tc.name) && assert.Equal(t, 1, len(stored), tc.name) {
+			assert.Equal(t, tc.missing, stored[0].PartialFailure, tc.name)
+			for _, section := range tc.failed {
+				assert.True(t, stored[0].Missing(section), "%s: %s", tc.name, section)
+			}
+			if stored[0].Missing(info.StatsSectionMemory) {
+				assert.Equal(t, info.MemoryStats{}, stored[0].Memory, tc.name)
+			}
+			if stored[0].Missing(info.StatsSectionCpu) {
+				assert.Equal(t, info.CpuStats{}, stored[0].Cpu, tc.name)
+			}
+		}
+
+		failures := cd.CollectionStatus().SectionFailures
+		assert.Equal(t, len(tc.failed), len(failures), tc.name)
+		for _, section := range tc.failed {
+			assert.Equal(t, uint64(1), failures[section], "%s: %s", tc.name, section)
+		}
+		mockHandler.AssertExpectations(t)
+	}
+}
+
+func TestUpdateStatsCountsSectionFailures(t *testing.T) {
+	cd, mockHandler, memoryStorage := newTestContainerData(t)
+	mockHandler.On("Exists").Return(true)
+	for i := 0; i < 3; i++ {
+		partial := container.NewPartialStatsError()
+		partial.Add(info.StatsSectionDiskIo, fmt.Errorf("no such file"))
+		if i == 0 {
+			partial.Add(info.StatsSectionMemory, fmt.Errorf("no such file"))
+		}
+		mockHandler.On("GetStats").Return(itest.GenerateRandomStats(1, 4, time.Second)[0], partial).Once()
+		assert.NotNil(t, cd.updateStats(timing.NewSections()))
+	}
+	mockHandler.On("GetStats").Return(itest.GenerateRandomStats(1, 4, time.Second)[0], nil).Once()
+	assert.Nil(t, cd.updateStats(timing.NewSections()))
+
+	checkNumStats(t, memoryStorage, 4)
+	status := cd.CollectionStatus()
+	assert.Equal(t, map[string]uint64{
+		info.StatsSectionDiskIo: 3,
+		info.StatsSectionMemory: 1,
+	}, status.SectionFailures)
+
+	// The status is a copy.
+	status.SectionFailures[info.StatsSectionDiskIo] = 0
+	assert.Equal(t, uint64(3), cd.CollectionStatus().SectionFailures[info.StatsSectionDiskIo])
+}
+
+func TestUpdateStatsReportsCollectionErrors(t *testing.T) {
+	cd, mockHandler, _ := setupContainerData(t, info.ContainerSpec{HasCpu: true, HasMemory: true})
+	mockHandler.On("Exists").Return(true)
+	partial := container.NewPartialStatsError()
+	partial.Add(info.StatsSectionMemory, fmt.Errorf("permission denied"))
+	// Not applicable to the container.
+	partial.Add(info.StatsSectionNetwork, fmt.Errorf("no such device"))
+	mockHandler.On("GetStats").Return(itest.GenerateRandomStats(1, 4, time.Second)[0], partial).Once()
+	assert.NotNil(t, cd.updateStats(timing.NewSections()))
+
+	status := cd.CollectionStatus()
+	assert.Equal(t, map[string]string{
+		info.StatsSectionMemory: "permission denied",
+	}, status.CollectionErrors)
+	assert.Equal(t, 2, len(status.SectionFailures))
+
+	// Cleared once the sections are collected again.
+	mockHandler.On("GetStats").Return(itest.GenerateRandomStats(1, 4, time.Second)[0], nil).Once()
+	assert.Nil(t, cd.updateStats(timing.NewSections()))
+	assert.Empty(t, cd.CollectionStatus().CollectionErrors)
+}
+
+func TestUpdateStatsWithPartialFailureOnDeadContainer(t *testing.T) {
+	cd, mockHandler, memoryStorage := newTestContainerData(t)
+	partial := container.NewPartialStatsError()
+	partial.Add(info.StatsSectionMemory, fmt.Errorf("no such file"))
+	mockHandler.On("GetStats").Return(itest.GenerateRandomStats(1, 4, time.Second)[0], partial)
+	mockHandler.On("Exists").Return(false)
+
+	assert.Nil(t, cd.updateStats(timing.NewSections()))
+	var empty time.Time
+	_, err := memoryStorage.RecentStats(containerName, empty, empty, -1)
+	assert.NotNil(t, err, "no stats should be stored")
+	assert.Empty(t, cd.CollectionStatus().SectionFailures)
+}
+
 func TestUpdateSpec(t *testing.T) {
 	spec := itest.GenerateRandomContainerSpec(4)
 	cd, mockHandler, _ := newTestContainerData(t)
@@ -202,3 +408,447 @@ func TestGetInfo(t *testing.T) {
 		t.Errorf("received wrong container name: received %v; should be %v", info.Name, mockHandler.Name)
 	}
 }
+
+// Handler whose stats collection takes a configurable amount of time.
+type slowContainerHandler struct {
+	*container.MockContainerHandler
+	delay time.Duration
+}
+
+func (self *slowContainerHandler) GetStats() (*info.ContainerStats, error) {
+	time.Sleep(self.delay)
+	return self.MockContainerHandler.GetStats()
+}
+
+// Handler which times the sections of its stats collection, each taking the specified delay.
+type sectionedContainerHandler struct {
+	*container.MockContainerHandler
+	delays []timing.Section
+}
+
+func (self *sectionedContainerHandler) GetStatsTimed(sections *timing.Sections) (*info.ContainerStats, error) {
+	for _, delay := range self.delays {
+		end := sections.Time(delay.Name)
+		time.Sleep(delay.Duration)
+		end()
+	}
+	return self.MockContainerHandler.GetStats()
+}
+
+// Checks the names of the sections and that each took at least the specified duration.
+func checkSections(t *testing.T, expected []timing.Section, sections []v2.HousekeepingSection) {
+	require.Equal(t, len(expected), len(sections), "sections: %+v", sections)
+	for i := range expected {
+		assert.Equal(t, expected[i].Name, sections[i].Name)
+		assert.True(t, sections[i].Duration >= expected[i].Duration, "section %q took %v, expected at least %v", sections[i].Name, sections[i].Duration, expected[i].Duration)
+	}
+}
+
+func TestHousekeepingSections(t *testing.T) {
+	mockHandler := container.NewMockContainerHandler(containerName)
+	mockHandler.On("GetSpec").Return(itest.GenerateRandomContainerSpec(4), nil)
+	mockHandler.On("GetStats").Return(itest.GenerateRandomStats(1, 4, time.Second)[0], nil)
+	handler := &slowContainerHandler{
+		MockContainerHandler: mockHandler,
+		delay:                20 * time.Millisecond,
+	}
+	cd, err := newContainerData(containerName, memory.New(60, nil), handler, newTestConfig(), nil, nil, false)
+	require.Nil(t, err)
+
+	// The handler does not time its sections, so its stats are timed as a whole.
+	sections := cd.housekeepingTick()
+	expected := []timing.Section{
+		{Name: "stats read", Duration: 20 * time.Millisecond},
+		{Name: "summary"},
+		{Name: "storage write"},
+	}
+	checkSections(t, expected, cd.CollectionStatus().LastHousekeepingSections)
+	assert.Equal(t, len(expected), len(sections.Sections()))
+}
+
+func TestHousekeepingSectionsFromHandler(t *testing.T) {
+	mockHandler := container.NewMockContainerHandler(containerName)
+	mockHandler.On("GetSpec").Return(itest.GenerateRandomContainerSpec(4), nil)
+	mockHandler.On("GetStats").Return(itest.GenerateRandomStats(1, 4, time.Second)[0], nil)
+	handler := &sectionedContainerHandler{
+		MockContainerHandler: mockHandler,
+		delays: []timing.Section{
+			{Name: "cgroup read", Duration: 20 * time.Millisecond},
+			{Name: "network read", Duration: 10 * time.Millisecond},
+		},
+	}
+	cd, err := newContainerData(containerName, memory.New(60, nil), handler, newTestConfig(), nil, nil, false)
+	require.Nil(t, err)
+
+	cd.housekeepingTick()
+	checkSections(t, append(handler.delays, timing.Section{Name: "summary"}, timing.Section{Name: "storage write"}), cd.CollectionStatus().LastHousekeepingSections)
+
+	// Only the most recent breakdown is kept.
+	handler.delays = handler.delays[:1]
+	cd.housekeepingTick()
+	checkSections(t, append(handler.delays, timing.Section{Name: "summary"}, timing.Section{Name: "storage write"}), cd.CollectionStatus().LastHousekeepingSections)
+}
+
+func TestHousekeepingOverrunDegradesCollection(t *testing.T) {
+	config := newTestConfig()
+	config.HousekeepingInterval = 10 * time.Millisecond
+	config.HousekeepingOverrunThreshold = 2
+
+	mockHandler := container.NewMockContainerHandler(containerName)
+	mockHandler.On("GetSpec").Return(itest.GenerateRandomContainerSpec(4), nil)
+	mockHandler.On("GetStats").Return(itest.GenerateRandomStats(1, 4, time.Second)[0], nil)
+	mockHandler.On("SetExpensiveCollectorsEnabled", false).Return()
+	mockHandler.On("SetExpensiveCollectorsEnabled", true).Return()
+	handler := &slowContainerHandler{
+		MockContainerHandler: mockHandler,
+		delay:                20 * time.Millisecond,
+	}
+	eventHandler := events.NewEventManager()
+	cd, err := newContainerData(containerName, memory.New(60, nil), handler, config, nil, eventHandler, false)
+	require.Nil(t, err)
+
+	tick := func() {
+		start := time.Now()
+		cd.housekeepingTick()
+		cd.recordHousekeepingDuration(time.Since(start))
+	}
+
+	// First overrun does not degrade.
+	tick()
+	status := cd.CollectionStatus()
+	assert.False(t, status.Degraded)
+	assert.Equal(t, 1, status.ConsecutiveOverruns)
+
+	// Second one reaches the threshold.
+	tick()
+	status = cd.CollectionStatus()
+	assert.True(t, status.Degraded)
+	assert.Equal(t, 2, status.ConsecutiveOverruns)
+	mockHandler.AssertCalled(t, "SetExpensiveCollectorsEnabled", false)
+	next := cd.nextHousekeeping(time.Now())
+	assert.True(t, cd.CollectionStatus().HousekeepingInterval >= 40*time.Millisecond, "degraded interval should be at least twice the housekeeping duration")
+	assert.True(t, next.Sub(time.Now()) > config.HousekeepingInterval)
+
+	// Fast housekeepings restore collection after the threshold.
+	handler.delay = 0
+	tick()
+	assert.True(t, cd.CollectionStatus().Degraded)
+	tick()
+	status = cd.CollectionStatus()
+	assert.False(t, status.Degraded)
+	assert.Equal(t, 0, status.ConsecutiveOverruns)
+	assert.Equal(t, uint64(2), status.TotalOverruns)
+	mockHandler.AssertCalled(t, "SetExpensiveCollectorsEnabled", true)
+
+	// Both transitions were reported as events.
+	request := events.NewRequest()
+	request.EventType[events.TypeCollectionDegraded] = true
+	request.EventType[events.TypeCollectionRestored] = true
+	evs, err := eventHandler.GetEvents(request)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(evs))
+	assert.Equal(t, events.TypeCollectionDegraded, evs[0].EventType)
+	assert.Equal(t, events.TypeCollectionRestored, evs[1].EventType)
+}
+
+func TestHousekeepingOverrunDegradationDisabled(t *testing.T) {
+	config := newTestConfig()
+	config.HousekeepingOverrunThreshold = 0
+
+	cd, _, _ := setupContainerDataWithConfig(t, itest.GenerateRandomContainerSpec(4), config)
+	for i := 0; i < 10; i++ {
+		cd.recordHousekeepingDuration(config.HousekeepingInterval * 2)
+	}
+	status := cd.CollectionStatus()
+	assert.False(t, status.Degraded)
+	assert.Equal(t, 10, status.ConsecutiveOverruns)
+}
+
+func TestHandlerTimeoutsCountAsOverruns(t *testing.T) {
+	cd, mockHandler, _ := newTestContainerData(t)
+	timeout := &container.TimeoutError{Op: "inspect", Timeout: time.Second}
+	mockHandler.On("ListContainers", container.ListSelf).Return([]info.ContainerReference{}, timeout)
+	mockHandler.On("Exists").Return(true)
+
+	assert.NotNil(t, cd.updateSubcontainers())
+	// Fast, but an operation timed out.
+	cd.recordHousekeepingDuration(time.Millisecond)
+	status := cd.CollectionStatus()
+	assert.Equal(t, uint64(1), status.Timeouts)
+	assert.Equal(t, 1, status.ConsecutiveOverruns)
+
+	// Only the housekeeping with the timeout overran.
+	cd.recordHousekeepingDuration(time.Millisecond)
+	status = cd.CollectionStatus()
+	assert.Equal(t, uint64(1), status.Timeouts)
+	assert.Equal(t, 0, status.ConsecutiveOverruns)
+}
+
+func TestPartialStatsTimeoutIsCounted(t *testing.T) {
+	cd, mockHandler, _ := newTestContainerData(t)
+	partial := container.NewPartialStatsError()
+	partial.Add(info.StatsSectionNetwork, &container.TimeoutError{Op: "network read", Timeout: time.Second})
+	stats := itest.GenerateRandomStats(1, 4, time.Second)[0]
+	mockHandler.On("GetStats").Return(stats, partial)
+	mockHandler.On("Exists").Return(true)
+
+	cd.housekeepingTick()
+	assert.Equal(t, uint64(1), cd.CollectionStatus().Timeouts)
+}
+
+func TestTimeJumpFlagsStatsAndEmitsEvent(t *testing.T) {
+	eventHandler := events.NewEventManager()
+	clock := fakeclock.NewFakeClock(time.Now())
+	newData := func(name string) *containerData {
+		mockHandler := container.NewMockContainerHandler(name)
+		mockHandler.On("GetSpec").Return(itest.GenerateRandomContainerSpec(4), nil)
+		cd, err := newContainerData(name, memory.New(60, nil), mockHandler, newTestConfig(), nil, eventHandler, false)
+		require.Nil(t, err)
+		cd.clock = clock
+		return cd
+	}
+	root := newData("/")
+	sub := newData(containerName)
+	collect := func() (*info.ContainerStats, *info.ContainerStats) {
+		rootStats := &info.ContainerStats{Timestamp: clock.Now()}
+		root.detectTimeJump(rootStats)
+		subStats := &info.ContainerStats{Timestamp: clock.Now()}
+		sub.detectTimeJump(subStats)
+		return rootStats, subStats
+	}
+
+	collect()
+	clock.Step(time.Second)
+	rootStats, subStats := collect()
+	assert.False(t, rootStats.TimeJump)
+	assert.False(t, subStats.TimeJump)
+
+	// Resume after being suspended for 3 hours.
+	clock.Step(time.Second)
+	clock.Jump(3 * time.Hour)
+	rootStats, subStats = collect()
+	assert.True(t, rootStats.TimeJump)
+	assert.True(t, subStats.TimeJump)
+	assert.True(t, root.timeJumped)
+
+	clock.Step(time.Second)
+	rootStats, subStats = collect()
+	assert.False(t, rootStats.TimeJump)
+	assert.False(t, subStats.TimeJump)
+
+	// Only the root container reported the jump.
+	request := events.NewRequest()
+	request.EventType[events.TypeTimeJump] = true
+	evs, err := eventHandler.GetEvents(request)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(evs))
+	assert.Equal(t, "/", evs[0].ContainerName)
+	jump, ok := evs[0].EventData.(v2.TimeJump)
+	require.True(t, ok)
+	assert.Equal(t, 3*time.Hour+time.Second, jump.WallClockElapsed)
+	assert.Equal(t, time.Second, jump.MonotonicElapsed)
+}
+
+func TestDynamicHousekeepingIgnoresTimeJumps(t *testing.T) {
+	cd, _, memoryStorage := newTestContainerData(t)
+	ref := info.ContainerReference{Name: containerName}
+	now := time.Now()
+	stats := itest.GenerateRandomStats(1, 4, time.Second)[0]
+	first := *stats
+	first.Timestamp = now
+	second := *stats
+	second.Timestamp = now.Add(time.Hour)
+	second.TimeJump = true
+	require.Nil(t, memoryStorage.AddStats(ref, &first))
+	require.Nil(t, memoryStorage.AddStats(ref, &second))
+
+	// Unchanged stats would normally raise the interval.
+	cd.nextHousekeeping(now)
+	assert.Equal(t, cd.config.HousekeepingInterval, cd.housekeepingInterval)
+}
+
+func TestRootHousekeepingInterval(t *testing.T) {
+	config := newTestConfig()
+	config.MaxHousekeepingInterval = time.Minute
+
+	config.RootHousekeepingInterval = 0
+	assert.Equal(t, config.HousekeepingInterval, config.baseHousekeepingInterval("/"))
+
+	// Only the root container is affected.
+	config.RootHousekeepingInterval = 250 * time.Millisecond
+	assert.Equal(t, 250*time.Millisecond, config.baseHousekeepingInterval("/"))
+	assert.Equal(t, config.HousekeepingInterval, config.baseHousekeepingInterval("/docker/a"))
+
+	config.RootHousekeepingInterval = time.Hour
+	assert.Equal(t, time.Minute, config.baseHousekeepingInterval("/"))
+}
+
+func TestDynamicHousekeepingFromRootInterval(t *testing.T) {
+	config := newTestConfig()
+	config.MaxHousekeepingInterval = 800 * time.Millisecond
+	config.RootHousekeepingInterval = 300 * time.Millisecond
+
+	mockHandler := container.NewMockContainerHandler("/")
+	mockHandler.On("GetSpec").Return(itest.GenerateRandomContainerSpec(4), nil)
+	memoryStorage := memory.New(60, nil)
+	cd, err := newContainerData("/", memoryStorage, mockHandler, config, nil, nil, false)
+	require.Nil(t, err)
+	assert.Equal(t, 300*time.Millisecond, cd.CollectionStatus().HousekeepingInterval)
+
+	ref := info.ContainerReference{Name: "/"}
+	now := time.Now()
+	stats := itest.GenerateRandomStats(1, 4, time.Second)[0]
+	for i := 0; i < 2; i++ {
+		sample := *stats
+		sample.Timestamp = now.Add(time.Duration(i) * time.Second)
+		require.Nil(t, memoryStorage.AddStats(ref, &sample))
+	}
+
+	// Unchanged stats raise the interval up to the maximum.
+	cd.nextHousekeeping(now)
+	assert.Equal(t, 600*time.Millisecond, cd.housekeepingInterval)
+	cd.nextHousekeeping(now)
+	assert.Equal(t, 800*time.Millisecond, cd.housekeepingInterval)
+	assert.Equal(t, 800*time.Millisecond, cd.CollectionStatus().HousekeepingInterval)
+
+	// Changed stats lower it back to the root's interval.
+	changed := *stats
+	changed.Timestamp = now.Add(2 * time.Second)
+	changed.Memory.Usage++
+	require.Nil(t, memoryStorage.AddStats(ref, &changed))
+	cd.nextHousekeeping(now)
+	assert.Equal(t, 300*time.Millisecond, cd.housekeepingInterval)
+}
+
+func TestDynamicHousekeepingWithoutProcesses(t *testing.T) {
+	config := newTestConfig()
+	config.MaxHousekeepingInterval = time.Minute
+
+	cd, _, memoryStorage := setupContainerDataWithConfig(t, itest.GenerateRandomContainerSpec(4), config)
+	ref := info.ContainerReference{Name: containerName}
+	now := time.Now()
+	stats := itest.GenerateRandomStats(1, 4, time.Second)[0]
+	none := uint64(0)
+	stats.ProcessCount = &none
+	for i := 0; i < 2; i++ {
+		sample := *stats
+		sample.Timestamp = now.Add(time.Duration(i) * time.Second)
+		require.Nil(t, memoryStorage.AddStats(ref, &sample))
+	}
+
+	// Unchanged stats of an empty container raise the interval straight to the maximum.
+	cd.nextHousekeeping(now)
+	assert.Equal(t, time.Minute, cd.housekeepingInterval)
+
+	// A process lowers it back.
+	one := uint64(1)
+	changed := *stats
+	changed.Timestamp = now.Add(2 * time.Second)
+	changed.ProcessCount = &one
+	require.Nil(t, memoryStorage.AddStats(ref, &changed))
+	cd.nextHousekeeping(now)
+	assert.Equal(t, config.HousekeepingInterval, cd.housekeepingInterval)
+}
+
+func TestUpdateStatsSamplesProcessCpu(t *testing.T) {
+	cd, mockHandler, memoryStorage := newTestContainerData(t)
+	cd.cpuSampler = cpusampling.New(0)
+	mockHandler.On("ListProcesses", container.ListSelf).Return([]int{os.Getpid()}, nil)
+	for _, monotonic := range []time.Duration{time.Second, 2 * time.Second, 11 * time.Second, 12 * time.Second} {
+		stats := itest.GenerateRandomStats(1, 4, time.Second)[0]
+		stats.MonotonicTimestamp = monotonic
+		mockHandler.On("GetStats").Return(stats, nil).Once()
+		require.Nil(t, cd.updateStats(timing.NewSections()))
+	}
+
+	stored, err := memoryStorage.RecentStats(containerName, time.Time{}, time.Time{}, -1)
+	require.Nil(t, err)
+	require.Equal(t, 4, len(stored))
+	// The first sample has nothing to compare to, the next one is taken once the interval elapsed.
+	assert.Nil(t, stored[0].ProcessCpu)
+	assert.Nil(t, stored[1].ProcessCpu)
+	require.NotNil(t, stored[2].ProcessCpu)
+	assert.Equal(t, 10*time.Second, stored[2].ProcessCpu.Interval)
+	assert.Equal(t, stored[2].ProcessCpu, stored[3].ProcessCpu)
+	// Process states are counted from the first sample.
+	require.NotNil(t, stored[0].ProcessStates)
+	assert.Equal(t, stored[0].ProcessStates, stored[1].ProcessStates)
+	mockHandler.AssertNumberOfCalls(t, "ListProcesses", 2)
+}
+
+// Returns a container whose handler always returns the same stats.
+func newLifecycleTestContainerData(t *testing.T, config *Config) *containerData {
+	cd, mockHandler, _ := setupContainerDataWithConfig(t, itest.GenerateRandomContainerSpec(4), config)
+	mockHandler.On("GetStats").Return(itest.GenerateRandomStats(1, 4, time.Second)[0], nil)
+	return cd
+}
+
+func TestContainerLifecycle(t *testing.T) {
+	cd := newLifecycleTestContainerData(t, newTestConfig())
+	assert.Equal(t, containerNew, cd.State())
+	assert.Equal(t, "new", cd.CollectionStatus().State)
+
+	require.NoError(t, cd.Start())
+	done := cd.housekeepingDone
+	// Starting again does not start a second housekeeping.
+	require.NoError(t, cd.Start())
+	assert.True(t, done == cd.housekeepingDone, "a second housekeeping was started")
+	assert.Equal(t, containerRunning, cd.State())
+
+	require.NoError(t, cd.StopAndWait(time.Second))
+	assert.Equal(t, containerStopped, cd.State())
+	assert.Equal(t, "stopped", cd.CollectionStatus().State)
+	// Stopping again does nothing, and a stopped container is not restarted.
+	require.NoError(t, cd.Stop())
+	assert.NotNil(t, cd.Start())
+	assert.Equal(t, containerStopped, cd.State())
+}
+
+func TestContainerStoppedBeforeStart(t *testing.T) {
+	cd := newLifecycleTestContainerData(t, newTestConfig())
+
+	require.NoError(t, cd.Stop())
+	assert.Equal(t, containerStopped, cd.State())
+	require.NoError(t, cd.StopAndWait(time.Second))
+	assert.NotNil(t, cd.Start())
+	assert.Nil(t, cd.housekeepingDone, "housekeeping was started")
+}
+
+func TestContainerReattachRestartsHousekeeping(t *testing.T) {
+	cd := newLifecycleTestContainerData(t, newTestConfig())
+	require.NoError(t, cd.Start())
+	require.NoError(t, cd.StopAndWait(time.Second))
+
+	handler := container.NewMockContainerHandler(containerName)
+	handler.On("GetStats").Return(itest.GenerateRandomStats(1, 4, time.Second)[0], nil)
+	cd.reattach(handler, info.ContainerReference{Name: containerName})
+	assert.Equal(t, containerRunning, cd.State())
+	require.NoError(t, cd.StopAndWait(time.Second))
+	assert.Equal(t, containerStopped, cd.State())
+}
+
+func TestContainerConcurrentStartStop(t *testing.T) {
+	config := newTestConfig()
+	config.HousekeepingInterval = time.Millisecond
+
+	for i := 0; i < 20; i++ {
+		cd := newLifecycleTestContainerData(t, config)
+		var wg sync.WaitGroup
+		for j := 0; j < 8; j++ {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+				if j%2 == 0 {
+					cd.Start()
+				} else {
+					cd.Stop()
+				}
+				cd.State()
+				cd.CollectionStatus()
+			}(j)
+		}
+		wg.Wait()
+		require.NoError(t, cd.StopAndWait(time.Second))
+		assert.Equal(t, containerStopped, cd.State())
+	}
+}
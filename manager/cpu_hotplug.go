@@ -0,0 +1,95 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/google/cadvisor/utils/sysinfo"
+)
+
+// Checks whether CPUs went offline or came online since the last check. If any
+// did, the cores of the machine info are updated and a hotplug event is added.
+func (m *manager) checkCpuHotplug() error {
+	online, err := sysinfo.GetOnlineCpus(m.sysFs)
+	if err != nil {
+		return fmt.Errorf("failed to read the online CPUs: %v", err)
+	}
+	previous := m.onlineCpus
+	m.onlineCpus = online
+	// Nothing to compare against on the first successful read.
+	if previous == nil {
+		return nil
+	}
+
+	hotplug := v2.CpuHotplug{
+		Online:   cpusNotIn(online, previous),
+		Offline:  cpusNotIn(previous, online),
+		NumCores: len(online),
+	}
+	if len(hotplug.Online) == 0 && len(hotplug.Offline) == 0 {
+		return nil
+	}
+	glog.Infof("CPUs changed state, online: %v, offline: %v", hotplug.Online, hotplug.Offline)
+	m.updateCores(len(online))
+
+	return m.eventHandler.AddEvent(&events.Event{
+		ContainerName: "/",
+		Timestamp:     time.Now(),
+		EventType:     events.TypeCpuHotplug,
+		EventData:     hotplug,
+	})
+}
+
+// Updates the core count of the machine info to the online CPUs and
+// re-collects the topology they are in.
+func (m *manager) updateCores(numCores int) {
+	cpuinfo, err := ioutil.ReadFile("/proc/cpuinfo")
+	var topology []info.Node
+	if err == nil {
+		topology, _, err = getTopology(m.sysFs, string(cpuinfo))
+	}
+	if err != nil {
+		glog.Errorf("Failed to update the topology after CPU hotplug: %v", err)
+	}
+
+	m.machineInfoLock.Lock()
+	defer m.machineInfoLock.Unlock()
+	m.machineInfo.NumCores = numCores
+	if err == nil {
+		m.machineInfo.Topology = topology
+	}
+}
+
+// Returns the sorted CPUs of a which are not in b, both sorted.
+func cpusNotIn(a, b []int) []int {
+	var ret []int
+	j := 0
+	for _, cpu := range a {
+		for j < len(b) && b[j] < cpu {
+			j++
+		}
+		if j == len(b) || b[j] != cpu {
+			ret = append(ret, cpu)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,81 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"testing"
+
+	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/google/cadvisor/utils/sysfs/fakesysfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckCpuHotplug(t *testing.T) {
+	sysFs := &fakesysfs.FakeSysFs{}
+	sysFs.SetOnlineCpus("0-3")
+	m := newTestManager(newTestConfig(), nil)
+	m.sysFs = sysFs
+	m.machineInfo = info.MachineInfo{NumCores: 4}
+	request := events.NewRequest()
+	request.EventType[events.TypeCpuHotplug] = true
+	hotplugs := func() []v2.CpuHotplug {
+		evs, err := m.eventHandler.GetEvents(request)
+		require.Nil(t, err)
+		var ret []v2.CpuHotplug
+		for _, ev := range evs {
+			assert.Equal(t, "/", ev.ContainerName)
+			ret = append(ret, ev.EventData.(v2.CpuHotplug))
+		}
+		return ret
+	}
+	numCores := func() int {
+		machineInfo, err := m.GetMachineInfo()
+		require.Nil(t, err)
+		return machineInfo.NumCores
+	}
+
+	// The first check only records the online CPUs.
+	require.Nil(t, m.checkCpuHotplug())
+	require.Nil(t, m.checkCpuHotplug())
+	assert.Empty(t, hotplugs())
+
+	sysFs.SetOnlineCpus("0-1,3")
+	require.Nil(t, m.checkCpuHotplug())
+	assert.Equal(t, []v2.CpuHotplug{{Offline: []int{2}, NumCores: 3}}, hotplugs())
+	assert.Equal(t, 3, numCores())
+
+	sysFs.SetOnlineCpus("0-3")
+	require.Nil(t, m.checkCpuHotplug())
+	assert.Equal(t, []v2.CpuHotplug{
+		{Offline: []int{2}, NumCores: 3},
+		{Online: []int{2}, NumCores: 4},
+	}, hotplugs())
+	assert.Equal(t, 4, numCores())
+
+	// Unreadable lists are not hotplugs.
+	sysFs.SetOnlineCpus("0-")
+	assert.NotNil(t, m.checkCpuHotplug())
+	assert.Equal(t, 2, len(hotplugs()))
+	assert.Equal(t, 4, numCores())
+}
+
+func TestCpusNotIn(t *testing.T) {
+	assert.Equal(t, []int{2, 5}, cpusNotIn([]int{0, 1, 2, 3, 5}, []int{0, 1, 3, 4}))
+	assert.Empty(t, cpusNotIn([]int{0, 1}, []int{0, 1, 2}))
+	assert.Equal(t, []int{0, 1}, cpusNotIn([]int{0, 1}, []int{}))
+}
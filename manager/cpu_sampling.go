@@ -0,0 +1,64 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/container"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/utils/sem"
+	"github.com/google/cadvisor/utils/timing"
+)
+
+// Waits for the turn of an expensive collector, replaced in tests.
+var acquireExpensive = sem.AcquireExpensive
+
+// Attaches the latest breakdown of the CPU usage of the container by process
+// name, the counts of its processes in uninterruptible sleep and zombies, and
+// their context switches to the stats, sampling the processes if the sampling interval elapsed. The
+// sampling is skipped, and retried at the next housekeeping, when too many
+// expensive collectors are running.
+func (c *containerData) sampleProcessCpu(sections *timing.Sections, stats *info.ContainerStats) {
+	if c.cpuSampler == nil {
+		return
+	}
+	if c.lastCpuSample == 0 || stats.MonotonicTimestamp-c.lastCpuSample >= c.config.CpuSamplingInterval {
+		c.sampleProcesses(sections, stats)
+	}
+	stats.ProcessCpu = c.processCpu
+	stats.ProcessStates = c.processStates
+	stats.Cpu.ContextSwitches = c.contextSwitches
+}
+
+func (c *containerData) sampleProcesses(sections *timing.Sections, stats *info.ContainerStats) {
+	release, err := acquireExpensive(info.CollectorCpuSampling, sem.CpuSamplingWeight)
+	if err != nil {
+		stats.StaleCollectors = append(stats.StaleCollectors, info.CollectorCpuSampling)
+		return
+	}
+	defer release()
+
+	endSampling := sections.Time("cpu sampling")
+	defer endSampling()
+	pids, err := c.handler.ListProcesses(container.ListSelf)
+	if err != nil {
+		glog.V(2).Infof("failed to list the processes of %q for CPU sampling: %v", c.info.Name, err)
+		return
+	}
+	c.processCpu = c.cpuSampler.Sample(pids, stats.MonotonicTimestamp)
+	c.processStates = c.cpuSampler.States()
+	c.contextSwitches = c.cpuSampler.ContextSwitches()
+	c.lastCpuSample = stats.MonotonicTimestamp
+}
@@ -0,0 +1,166 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+)
+
+// State of a container whose creation failed.
+type creationFailure struct {
+	status v2.FailedContainer
+
+	// Timer for the next retry. Nil if not retrying.
+	retry *time.Timer
+}
+
+// Creates the specified container. If the creation fails, it is retried with
+// exponential backoff until it succeeds or ContainerCreationRetries retries
+// fail, after which the container is reported as failed. Containers whose
+// creation failed are only created again by their pending retry: once given
+// up on, they are not until their failure is forgotten, e.g.: they are no
+// longer listed and are then detected as new again.
+func (m *manager) createContainerWithRetry(containerName string) error {
+	m.creationFailuresLock.Lock()
+	_, failed := m.creationFailures[containerName]
+	m.creationFailuresLock.Unlock()
+	if failed {
+		return nil
+	}
+	return m.attemptContainerCreation(containerName)
+}
+
+func (m *manager) attemptContainerCreation(containerName string) error {
+	err := m.createContainer(containerName)
+
+	m.creationFailuresLock.Lock()
+	defer m.creationFailuresLock.Unlock()
+	if err == nil {
+		delete(m.creationFailures, containerName)
+		return nil
+	}
+
+	failure, ok := m.creationFailures[containerName]
+	if !ok {
+		failure = &creationFailure{
+			status: v2.FailedContainer{
+				Name: containerName,
+			},
+		}
+		m.creationFailures[containerName] = failure
+	}
+	failure.status.Attempts++
+	failure.status.LastError = err.Error()
+	failure.status.LastAttempt = time.Now()
+	failure.retry = nil
+
+	retries := failure.status.Attempts - 1
+	if retries >= m.config.ContainerCreationRetries {
+		failure.status.NextRetry = time.Time{}
+		glog.Warningf("Giving up on creating container %q after %d attempts: %v", containerName, failure.status.Attempts, err)
+		return err
+	}
+	backoff := m.config.ContainerCreationBackoff << uint(retries)
+	failure.status.NextRetry = failure.status.LastAttempt.Add(backoff)
+	failure.retry = time.AfterFunc(backoff, func() {
+		m.retryContainerCreation(containerName, failure)
+	})
+	glog.V(2).Infof("Failed to create container %q, retrying in %v: %v", containerName, backoff, err)
+	return err
+}
+
+func (m *manager) retryContainerCreation(containerName string, failure *creationFailure) {
+	m.creationFailuresLock.Lock()
+	current, ok := m.creationFailures[containerName]
+	if !ok || current != failure || failure.retry == nil {
+		// The retry was cancelled.
+		m.creationFailuresLock.Unlock()
+		return
+	}
+	failure.retry = nil
+	m.creationFailuresLock.Unlock()
+
+	err := m.attemptContainerCreation(containerName)
+	if err == nil {
+		glog.Infof("Created container %q after %d failed attempts", containerName, failure.status.Attempts)
+	}
+}
+
+// Forgets about the creation failures of the specified container and cancels any pending retry.
+func (m *manager) forgetCreationFailure(containerName string) {
+	m.creationFailuresLock.Lock()
+	defer m.creationFailuresLock.Unlock()
+	failure, ok := m.creationFailures[containerName]
+	if !ok {
+		return
+	}
+	if failure.retry != nil {
+		failure.retry.Stop()
+		failure.retry = nil
+	}
+	delete(m.creationFailures, containerName)
+}
+
+// Forgets about the creation failures of the subcontainers of the specified
+// container which are not in the specified listing of its subcontainers.
+func (m *manager) forgetVanishedCreationFailures(containerName string, subcontainers []info.ContainerReference) {
+	existing := make(map[string]bool, len(subcontainers))
+	for _, cont := range subcontainers {
+		existing[cont.Name] = true
+	}
+	prefix := strings.TrimSuffix(containerName, "/") + "/"
+
+	m.creationFailuresLock.Lock()
+	var vanished []string
+	for name := range m.creationFailures {
+		if strings.HasPrefix(name, prefix) && !existing[name] {
+			vanished = append(vanished, name)
+		}
+	}
+	m.creationFailuresLock.Unlock()
+
+	for _, name := range vanished {
+		m.forgetCreationFailure(name)
+	}
+}
+
+// Cancels all pending retries.
+func (m *manager) stopCreationRetries() {
+	m.creationFailuresLock.Lock()
+	defer m.creationFailuresLock.Unlock()
+	for _, failure := range m.creationFailures {
+		if failure.retry != nil {
+			failure.retry.Stop()
+			failure.retry = nil
+		}
+	}
+}
+
+func (m *manager) GetFailedContainers() ([]v2.FailedContainer, error) {
+	m.creationFailuresLock.Lock()
+	defer m.creationFailuresLock.Unlock()
+	failed := make([]v2.FailedContainer, 0, len(m.creationFailures))
+	for _, failure := range m.creationFailures {
+		failed = append(failed, failure.status)
+	}
+	sort.Sort(v2.FailedContainerSlice(failed))
+	return failed, nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/container"
+	info "github.com/google/cadvisor/info/v1"
+	itest "github.com/google/cadvisor/info/v1/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Returns a factory of handlers which fails to create them the specified number
+// of times (forever if negative) before succeeding, and a function returning the
+// number of attempts to create them.
+func newFlakyFactory(failures int) (*container.FactoryForMockContainerHandler, func() int) {
+	var lock sync.Mutex
+	attempts := 0
+	factory := &container.FactoryForMockContainerHandler{
+		Name: "flaky",
+		WrapContainerHandlerFunc: func(name string, handler *container.MockContainerHandler) (container.ContainerHandler, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			attempts++
+			if failures < 0 || attempts <= failures {
+				return nil, fmt.Errorf("inspecting %q timed out", name)
+			}
+			handler.On("GetSpec").Return(info.ContainerSpec{}, nil)
+			handler.On("GetStats").Return(itest.GenerateRandomStats(1, 4, time.Second)[0], nil)
+			return handler, nil
+		},
+	}
+	return factory, func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		return attempts
+	}
+}
+
+// Creates a manager whose containers are created by a factory that fails the specified
+// number of times (forever if negative) and are retried as configured.
+func newRetryTestManager(failures, retries int, backoff time.Duration) (*manager, func() int, func()) {
+	config := newTestConfig()
+	config.ContainerCreationRetries, config.ContainerCreationBackoff = retries, backoff
+
+	factory, attempts := newFlakyFactory(failures)
+	m := newTestManager(config, factory)
+	return m, attempts, func() {
+		m.stopCreationRetries()
+		container.ClearContainerHandlerFactories()
+	}
+}
+
+// Waits for the condition to be true, failing the test if it does not happen within a second.
+func waitFor(t *testing.T, description string, condition func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", description)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestContainerCreationIsRetried(t *testing.T) {
+	const name = "/docker/flaky"
+	m, attempts, cleanup := newRetryTestManager(2, 5, time.Millisecond)
+	defer cleanup()
+
+	err := m.createContainerWithRetry(name)
+	assert.NotNil(t, err)
+
+	waitFor(t, "the container to be created", func() bool {
+		_, err := m.getContainerData(name)
+		return err == nil
+	})
+	assert.Equal(t, 3, attempts())
+	failed, err := m.GetFailedContainers()
+	require.Nil(t, err)
+	assert.Empty(t, failed)
+
+	require.Nil(t, m.destroyContainer(name))
+}
+
+func TestContainerCreationGivesUp(t *testing.T) {
+	const name = "/docker/broken"
+	m, attempts, cleanup := newRetryTestManager(-1, 2, time.Millisecond)
+	defer cleanup()
+
+	m.createContainerWithRetry(name)
+	waitFor(t, "the retries to be exhausted", func() bool {
+		failed, err := m.GetFailedContainers()
+		return err == nil && len(failed) == 1 && failed[0].NextRetry.IsZero()
+	})
+	failed, err := m.GetFailedContainers()
+	require.Nil(t, err)
+	assert.Equal(t, name, failed[0].Name)
+	assert.Equal(t, 3, failed[0].Attempts)
+	assert.Contains(t, failed[0].LastError, "timed out")
+	assert.Equal(t, 3, attempts())
+
+	// Detecting it again on later housekeepings makes no new attempt.
+	for i := 0; i < 3; i++ {
+		m.createContainerWithRetry(name)
+	}
+	assert.Equal(t, 3, attempts())
+	failed, err = m.GetFailedContainers()
+	require.Nil(t, err)
+	assert.Equal(t, 3, failed[0].Attempts)
+
+	// The container going away clears the failure.
+	require.Nil(t, m.destroyContainer(name))
+	failed, err = m.GetFailedContainers()
+	require.Nil(t, err)
+	assert.Empty(t, failed)
+
+	// And it is retried anew once detected again.
+	m.createContainerWithRetry(name)
+	assert.Equal(t, 4, attempts())
+}
+
+func TestContainerCreationGivenUpResumesOnceNoLongerListed(t *testing.T) {
+	const name = "/docker/broken"
+	m, attempts, cleanup := newRetryTestManager(-1, 0, time.Millisecond)
+	defer cleanup()
+
+	m.createContainerWithRetry(name)
+	m.forgetVanishedCreationFailures("/", []info.ContainerReference{{Name: "/"}, {Name: name}})
+	m.createContainerWithRetry(name)
+	assert.Equal(t, 1, attempts())
+
+	m.forgetVanishedCreationFailures("/", []info.ContainerReference{{Name: "/"}})
+	m.createContainerWithRetry(name)
+	assert.Equal(t, 2, attempts())
+}
+
+func TestPendingContainerCreationRetryIsNotDuplicated(t *testing.T) {
+	const name = "/docker/slow"
+	m, attempts, cleanup := newRetryTestManager(-1, 5, time.Hour)
+	defer cleanup()
+
+	m.createContainerWithRetry(name)
+	m.createContainerWithRetry(name)
+	assert.Equal(t, 1, attempts())
+
+	failed, err := m.GetFailedContainers()
+	require.Nil(t, err)
+	require.Equal(t, 1, len(failed))
+	assert.False(t, failed[0].NextRetry.IsZero())
+}
+
+func TestVanishedContainerCreationFailuresAreForgotten(t *testing.T) {
+	m, _, cleanup := newRetryTestManager(-1, 5, time.Hour)
+	defer cleanup()
+
+	m.createContainerWithRetry("/docker/gone")
+	m.createContainerWithRetry("/docker/present")
+	m.forgetVanishedCreationFailures("/", []info.ContainerReference{{Name: "/"}, {Name: "/docker/present"}})
+
+	failed, err := m.GetFailedContainers()
+	require.Nil(t, err)
+	require.Equal(t, 1, len(failed))
+	assert.Equal(t, "/docker/present", failed[0].Name)
+}
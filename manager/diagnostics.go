@@ -0,0 +1,139 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/google/cadvisor/utils/procfs"
+)
+
+const (
+	// Maximum number of processes listed in the diagnostics of a container,
+	// those with the lowest PIDs are.
+	maxDiagnosticProcesses = 1000
+
+	// Maximum size of the contents of a cgroup file in the diagnostics.
+	maxDiagnosticCgroupFileSize = 64 * 1024
+)
+
+// Cgroup files included in the diagnostics of a container, by subsystem. Only
+// files describing the limits and usage of the container itself are.
+var diagnosticCgroupFiles = map[string][]string{
+	"cpu":     {"cpu.shares", "cpu.cfs_period_us", "cpu.cfs_quota_us", "cpu.stat"},
+	"cpuacct": {"cpuacct.usage", "cpuacct.stat"},
+	"cpuset":  {"cpuset.cpus", "cpuset.mems"},
+	"memory":  {"memory.limit_in_bytes", "memory.soft_limit_in_bytes", "memory.memsw.limit_in_bytes", "memory.usage_in_bytes", "memory.max_usage_in_bytes", "memory.failcnt", "memory.oom_control", "memory.stat"},
+	"blkio":   {"blkio.weight", "blkio.throttle.io_service_bytes", "blkio.throttle.io_serviced"},
+	"pids":    {"pids.max", "pids.current"},
+}
+
+func (self *manager) GetContainerDiagnostics(containerName string) (v2.ContainerDiagnostics, error) {
+	cont, err := self.getContainerData(containerName)
+	if err != nil {
+		return v2.ContainerDiagnostics{}, err
+	}
+	return getContainerDiagnostics(cont.handler), nil
+}
+
+// Lists the processes of the container and reads its allowlisted cgroup
+// files. Sections which fail are reported in the diagnostics.
+func getContainerDiagnostics(handler container.ContainerHandler) v2.ContainerDiagnostics {
+	diagnostics := v2.ContainerDiagnostics{
+		Processes:   []v2.ProcessInfo{},
+		CgroupFiles: make(map[string]string),
+	}
+	addError := func(section string, err error) {
+		if diagnostics.Errors == nil {
+			diagnostics.Errors = make(map[string]string)
+		}
+		diagnostics.Errors[section] = err.Error()
+	}
+
+	pids, err := handler.ListProcesses(container.ListSelf)
+	if err != nil {
+		addError("processes", err)
+	}
+	sort.Ints(pids)
+	if len(pids) > maxDiagnosticProcesses {
+		pids = pids[:maxDiagnosticProcesses]
+		diagnostics.Truncated = append(diagnostics.Truncated, "processes")
+	}
+	for _, pid := range pids {
+		stat, err := procfs.ReadProcessStat(pid)
+		if err != nil {
+			// The process exited since it was listed.
+			continue
+		}
+		diagnostics.Processes = append(diagnostics.Processes, v2.ProcessInfo{
+			Pid:   pid,
+			Name:  stat.Comm,
+			State: stat.State,
+		})
+	}
+
+	subsystems := make([]string, 0, len(diagnosticCgroupFiles))
+	for subsystem := range diagnosticCgroupFiles {
+		subsystems = append(subsystems, subsystem)
+	}
+	sort.Strings(subsystems)
+	for _, subsystem := range subsystems {
+		dir, err := handler.GetCgroupPath(subsystem)
+		if err != nil {
+			// The subsystem is not mounted or not used by the container.
+			continue
+		}
+		for _, file := range diagnosticCgroupFiles[subsystem] {
+			name := path.Join(subsystem, file)
+			contents, truncated, err := readBounded(path.Join(dir, file), maxDiagnosticCgroupFileSize)
+			if os.IsNotExist(err) {
+				// Not supported by the kernel or disabled.
+				continue
+			}
+			if err != nil {
+				addError("cgroup_files/"+name, err)
+				continue
+			}
+			diagnostics.CgroupFiles[name] = contents
+			if truncated {
+				diagnostics.Truncated = append(diagnostics.Truncated, "cgroup_files/"+name)
+			}
+		}
+	}
+	return diagnostics
+}
+
+// Reads at most maxSize bytes of the file. Returns whether it was longer.
+func readBounded(file string, maxSize int) (string, bool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+	out, err := ioutil.ReadAll(io.LimitReader(f, int64(maxSize)+1))
+	if err != nil {
+		return "", false, err
+	}
+	if len(out) > maxSize {
+		return string(out[:maxSize]), true, nil
+	}
+	return string(out), false, nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/google/cadvisor/utils/procfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, file, contents string) {
+	require.Nil(t, os.MkdirAll(path.Dir(file), 0755))
+	require.Nil(t, ioutil.WriteFile(file, []byte(contents), 0644))
+}
+
+func TestGetContainerDiagnostics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cadvisor-diagnostics")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	defer procfs.SetRoot(path.Join(dir, "proc"))()
+
+	writeFile(t, path.Join(dir, "proc", "12", "stat"), "12 (nginx worker) S 1 12 12 0 -1 4202752 5000 0 0 0 150 75 0 0 20 0 12 0 1000 0 0")
+	writeFile(t, path.Join(dir, "proc", "7", "stat"), "7 (nginx) S 1 7 7 0 -1 4202752 5000 0 0 0 150 75 0 0 20 0 12 0 900 0 0")
+	// PID 9 exited since it was listed.
+	memoryDir := path.Join(dir, "memory", "docker", "web")
+	writeFile(t, path.Join(memoryDir, "memory.limit_in_bytes"), "1048576\n")
+	writeFile(t, path.Join(memoryDir, "memory.stat"), strings.Repeat("x", maxDiagnosticCgroupFileSize+1))
+	// Files not in the allowlist are left out.
+	writeFile(t, path.Join(memoryDir, "memory.force_empty"), "0\n")
+	cpuDir := path.Join(dir, "cpu", "docker", "web")
+	writeFile(t, path.Join(cpuDir, "cpu.shares"), "512\n")
+	// A file which cannot be read.
+	require.Nil(t, os.MkdirAll(path.Join(cpuDir, "cpu.stat"), 0755))
+
+	handler := container.NewMockContainerHandler("/docker/web")
+	handler.On("ListProcesses", container.ListSelf).Return([]int{12, 9, 7}, nil)
+	for subsystem := range diagnosticCgroupFiles {
+		switch subsystem {
+		case "memory":
+			handler.On("GetCgroupPath", subsystem).Return(memoryDir, nil)
+		case "cpu":
+			handler.On("GetCgroupPath", subsystem).Return(cpuDir, nil)
+		default:
+			handler.On("GetCgroupPath", subsystem).Return("", fmt.Errorf("%s is not mounted", subsystem))
+		}
+	}
+
+	diagnostics := getContainerDiagnostics(handler)
+	assert.Equal(t, []v2.ProcessInfo{
+		{Pid: 7, Name: "nginx", State: "S"},
+		{Pid: 12, Name: "nginx worker", State: "S"},
+	}, diagnostics.Processes)
+	assert.Equal(t, []string{"cpu/cpu.shares", "memory/memory.limit_in_bytes", "memory/memory.stat"}, sortedKeys(diagnostics.CgroupFiles))
+	assert.Equal(t, "1048576\n", diagnostics.CgroupFiles["memory/memory.limit_in_bytes"])
+	assert.Equal(t, maxDiagnosticCgroupFileSize, len(diagnostics.CgroupFiles["memory/memory.stat"]))
+	assert.Equal(t, []string{"cgroup_files/memory/memory.stat"}, diagnostics.Truncated)
+	assert.Equal(t, 1, len(diagnostics.Errors))
+	_, ok := diagnostics.Errors["cgroup_files/cpu/cpu.stat"]
+	assert.True(t, ok, "errors: %v", diagnostics.Errors)
+}
+
+func TestGetContainerDiagnosticsBoundsProcesses(t *testing.T) {
+	pids := make([]int, maxDiagnosticProcesses+1)
+	for i := range pids {
+		pids[i] = i + 1
+	}
+	handler := container.NewMockContainerHandler("/docker/web")
+	handler.On("ListProcesses", container.ListSelf).Return(pids, nil)
+	for subsystem := range diagnosticCgroupFiles {
+		handler.On("GetCgroupPath", subsystem).Return("", fmt.Errorf("%s is not mounted", subsystem))
+	}
+	diagnostics := getContainerDiagnostics(handler)
+	assert.Equal(t, []string{"processes"}, diagnostics.Truncated)
+	assert.NotNil(t, diagnostics.Processes)
+	assert.Empty(t, diagnostics.CgroupFiles)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
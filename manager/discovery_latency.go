@@ -0,0 +1,146 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Number of recent latencies of each kind the percentiles are computed over.
+const maxLatencySamples = 1000
+
+// The most recent latencies of a kind, oldest overwritten first.
+type latencyWindow struct {
+	samples []time.Duration
+	next    int
+}
+
+func (self *latencyWindow) add(latency time.Duration) {
+	if len(self.samples) < maxLatencySamples {
+		self.samples = append(self.samples, latency)
+		return
+	}
+	self.samples[self.next] = latency
+	self.next = (self.next + 1) % maxLatencySamples
+}
+
+// Returns the percentiles of the latencies, nil if there are none.
+func (self *latencyWindow) percentiles() *info.LatencyPercentiles {
+	if len(self.samples) == 0 {
+		return nil
+	}
+	sorted := make(durationSlice, len(self.samples))
+	copy(sorted, self.samples)
+	sort.Sort(sorted)
+	return &info.LatencyPercentiles{
+		Count: uint64(len(sorted)),
+		P50:   sorted.percentile(0.5),
+		P95:   sorted.percentile(0.95),
+		Max:   sorted[len(sorted)-1],
+	}
+}
+
+type durationSlice []time.Duration
+
+func (self durationSlice) Len() int           { return len(self) }
+func (self durationSlice) Swap(i, j int)      { self[i], self[j] = self[j], self[i] }
+func (self durationSlice) Less(i, j int) bool { return self[i] < self[j] }
+
+// Returns the nearest-rank percentile of the sorted durations.
+func (self durationSlice) percentile(p float64) time.Duration {
+	rank := int(math.Ceil(p * float64(len(self))))
+	if rank < 1 {
+		rank = 1
+	}
+	return self[rank-1]
+}
+
+// Latencies of showing the containers created after cAdvisor started, from
+// their creation to their discovery and to their first stats. Reported with
+// the container counts. Class is thread-safe.
+type latencyRecorder struct {
+	lock       sync.Mutex
+	discovery  latencyWindow
+	firstStats latencyWindow
+}
+
+func (self *latencyRecorder) recordDiscovery(latency time.Duration) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.discovery.add(latency)
+}
+
+func (self *latencyRecorder) recordFirstStats(latency time.Duration) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.firstStats.add(latency)
+}
+
+// Returns the percentiles of the recent discovery and first stats latencies.
+func (self *latencyRecorder) percentiles() (discovery, firstStats *info.LatencyPercentiles) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.discovery.percentiles(), self.firstStats.percentiles()
+}
+
+// Returns the time elapsed from start to end. The creation time of a
+// container may be coarser than the clock (e.g.: a directory mtime), so it is
+// never negative.
+func latencySince(start, end time.Time) time.Duration {
+	if latency := end.Sub(start); latency > 0 {
+		return latency
+	}
+	return 0
+}
+
+// Starts measuring the latencies of showing the container, which was created
+// at the specified time. Returns its discovery latency. Its latency to the
+// first stats is passed to firstStats once they are stored.
+func (c *containerData) measureLatencies(created time.Time, firstStats func(time.Duration)) time.Duration {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.createdAt = created
+	c.firstStatsStored = firstStats
+	c.collectionStatus.DiscoveryLatency = latencySince(created, c.discoveredAt)
+	return c.collectionStatus.DiscoveryLatency
+}
+
+// Marks the stats stored at the specified time as the first ones of the
+// container, unless some were stored before.
+func (c *containerData) markFirstStats(now time.Time) {
+	c.lock.Lock()
+	if !c.firstStatsAt.IsZero() {
+		c.lock.Unlock()
+		return
+	}
+	c.firstStatsAt = now
+	if c.createdAt.IsZero() {
+		c.lock.Unlock()
+		return
+	}
+	latency := latencySince(c.createdAt, now)
+	c.collectionStatus.FirstStatsLatency = latency
+	firstStats := c.firstStatsStored
+	c.lock.Unlock()
+
+	if firstStats != nil {
+		firstStats(latency)
+	}
+}
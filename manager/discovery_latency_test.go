@@ -0,0 +1,116 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	itest "github.com/google/cadvisor/info/v1/test"
+	"github.com/google/cadvisor/utils/clock/fakeclock"
+	"github.com/google/cadvisor/utils/timing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyWindowPercentiles(t *testing.T) {
+	var window latencyWindow
+	assert.Nil(t, window.percentiles())
+
+	// Added out of order.
+	for i := 100; i > 0; i-- {
+		window.add(time.Duration(i) * time.Millisecond)
+	}
+	assert.Equal(t, &info.LatencyPercentiles{
+		Count: 100,
+		P50:   50 * time.Millisecond,
+		P95:   95 * time.Millisecond,
+		Max:   100 * time.Millisecond,
+	}, window.percentiles())
+
+	// Only the most recent latencies are kept.
+	for i := 0; i < maxLatencySamples; i++ {
+		window.add(time.Second)
+	}
+	assert.Equal(t, &info.LatencyPercentiles{
+		Count: maxLatencySamples,
+		P50:   time.Second,
+		P95:   time.Second,
+		Max:   time.Second,
+	}, window.percentiles())
+}
+
+func TestContainerLatencies(t *testing.T) {
+	clock := fakeclock.NewFakeClock(time.Unix(1433160000, 0))
+	cd, mockHandler, _ := newTestContainerData(t)
+	cd.clock = clock
+	cd.discoveredAt = clock.Now()
+	mockHandler.On("GetStats").Return(itest.GenerateRandomStats(1, 4, time.Second)[0], nil)
+
+	var recorder latencyRecorder
+	discovery := cd.measureLatencies(clock.Now().Add(-2*time.Second), recorder.recordFirstStats)
+	assert.Equal(t, 2*time.Second, discovery)
+	recorder.recordDiscovery(discovery)
+
+	clock.Step(3 * time.Second)
+	require.NoError(t, cd.updateStats(timing.NewSections()))
+	clock.Step(time.Second)
+	require.NoError(t, cd.updateStats(timing.NewSections()))
+
+	status := cd.CollectionStatus()
+	assert.Equal(t, 2*time.Second, status.DiscoveryLatency)
+	// Measured at the first stats only.
+	assert.Equal(t, 5*time.Second, status.FirstStatsLatency)
+
+	discoveryPercentiles, firstStatsPercentiles := recorder.percentiles()
+	assert.Equal(t, &info.LatencyPercentiles{Count: 1, P50: 2 * time.Second, P95: 2 * time.Second, Max: 2 * time.Second}, discoveryPercentiles)
+	assert.Equal(t, &info.LatencyPercentiles{Count: 1, P50: 5 * time.Second, P95: 5 * time.Second, Max: 5 * time.Second}, firstStatsPercentiles)
+}
+
+func TestContainerLatenciesNotMeasured(t *testing.T) {
+	clock := fakeclock.NewFakeClock(time.Unix(1433160000, 0))
+	cd, mockHandler, _ := newTestContainerData(t)
+	cd.clock = clock
+	mockHandler.On("GetStats").Return(itest.GenerateRandomStats(1, 4, time.Second)[0], nil)
+
+	require.NoError(t, cd.updateStats(timing.NewSections()))
+	status := cd.CollectionStatus()
+	assert.Equal(t, time.Duration(0), status.DiscoveryLatency)
+	assert.Equal(t, time.Duration(0), status.FirstStatsLatency)
+}
+
+func TestDiscoveryLatencyNeverNegative(t *testing.T) {
+	cd, _, _ := newTestContainerData(t)
+	// The creation time is rounded up past the discovery.
+	assert.Equal(t, time.Duration(0), cd.measureLatencies(cd.discoveredAt.Add(time.Second), nil))
+}
+
+func TestContainerCountsReportLatencies(t *testing.T) {
+	m := newTestManager(newTestConfig(), nil)
+	start := m.startupTime
+	for _, latency := range []time.Duration{time.Second, 3 * time.Second, 2 * time.Second} {
+		m.containerLatencies.recordDiscovery(latency)
+	}
+	require.NoError(t, m.writeContainerCounts(start.Add(time.Minute)))
+
+	cinfo, err := m.getMetaContainerInfo(&info.ContainerInfoRequest{NumStats: 1})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(cinfo.Stats))
+	counts := cinfo.Stats[0].ContainerCounts
+	require.NotNil(t, counts)
+	assert.Equal(t, &info.LatencyPercentiles{Count: 3, P50: 2 * time.Second, P95: 3 * time.Second, Max: 3 * time.Second}, counts.DiscoveryLatency)
+	assert.Nil(t, counts.FirstStatsLatency)
+}
@@ -0,0 +1,157 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/container/docker"
+	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// A Docker container that disappeared and may reappear.
+type missingContainer struct {
+	cont *containerData
+
+	// Timer for deleting the container once the grace period is over.
+	expiry *time.Timer
+}
+
+// Marks the specified container as missing if it is a Docker container and
+// DockerRestartGrace is configured. Its housekeeping is stopped but it is kept
+// until the grace period is over. Returns whether the container was marked
+// (or already was) missing.
+func (m *manager) markContainerMissing(containerName string) (bool, error) {
+	if m.config.DockerRestartGrace <= 0 {
+		return false, nil
+	}
+	cont, err := m.getContainerData(containerName)
+	if err != nil || cont.info.Namespace != docker.DockerNamespace {
+		return false, nil
+	}
+	id := docker.ContainerNameToDockerId(cont.info.Name)
+
+	m.missingContainersLock.Lock()
+	defer m.missingContainersLock.Unlock()
+	if _, ok := m.missingContainers[id]; ok {
+		return true, nil
+	}
+
+	err = cont.Stop()
+	if err != nil {
+		return false, err
+	}
+	cont.setMissingSince(time.Now())
+	missing := &missingContainer{
+		cont: cont,
+	}
+	missing.expiry = time.AfterFunc(m.config.DockerRestartGrace, func() {
+		m.expireMissingContainer(id, missing)
+	})
+	m.missingContainers[id] = missing
+	glog.Infof("Container %q (aliases: %v) is missing, waiting %v for it to reappear", containerName, cont.info.Aliases, m.config.DockerRestartGrace)
+	return true, nil
+}
+
+// Deletes the missing container once its grace period is over.
+func (m *manager) expireMissingContainer(id string, missing *missingContainer) {
+	m.missingContainersLock.Lock()
+	if m.missingContainers[id] != missing {
+		// Reattached in the meantime.
+		m.missingContainersLock.Unlock()
+		return
+	}
+	delete(m.missingContainers, id)
+	m.missingContainersLock.Unlock()
+
+	name := missing.cont.info.Name
+	if _, ok := m.removeContainer(name); !ok {
+		return
+	}
+	err := m.containerDestroyed(missing.cont)
+	if err != nil {
+		glog.Errorf("Failed to delete missing container %q: %v", name, err)
+	}
+}
+
+// Resumes tracking of the missing container the handler belongs to, keeping
+// its history. Returns false if there is no such missing container.
+func (m *manager) reattachMissingContainer(handler container.ContainerHandler) (bool, error) {
+	ref, err := handler.ContainerReference()
+	if err != nil || ref.Namespace != docker.DockerNamespace {
+		return false, nil
+	}
+	id := docker.ContainerNameToDockerId(ref.Name)
+
+	m.missingContainersLock.Lock()
+	missing, ok := m.missingContainers[id]
+	if !ok || !missing.expiry.Stop() {
+		// Not missing, or already being deleted.
+		m.missingContainersLock.Unlock()
+		return false, nil
+	}
+	delete(m.missingContainers, id)
+	m.missingContainersLock.Unlock()
+
+	cont := missing.cont
+	oldName := cont.info.Name
+	m.removeContainer(oldName)
+	if oldName != ref.Name {
+		m.memoryStorage.RenameContainer(oldName, ref.Name)
+	}
+	cont.reattach(handler, ref)
+	m.addContainer(cont)
+	if oldName != ref.Name {
+		m.containerRevisions.record(containerRemoved, info.ContainerReference{Name: oldName})
+		m.containerRevisions.record(containerAdded, ref)
+	} else {
+		m.containerRevisions.record(containerChanged, ref)
+	}
+	glog.Infof("Reattached container: %q (aliases: %v, namespace: %q)", ref.Name, ref.Aliases, ref.Namespace)
+
+	newEvent := &events.Event{
+		ContainerName: ref.Name,
+		Timestamp:     time.Now(),
+		EventType:     events.TypeContainerReattached,
+	}
+	err = m.eventHandler.AddEvent(newEvent)
+	if err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+// Returns the names of the containers which are currently missing.
+func (m *manager) missingContainerNames() map[string]bool {
+	m.missingContainersLock.Lock()
+	defer m.missingContainersLock.Unlock()
+	names := make(map[string]bool, len(m.missingContainers))
+	for _, missing := range m.missingContainers {
+		names[missing.cont.info.Name] = true
+	}
+	return names
+}
+
+// Stops the deletion of missing containers, e.g.: when the manager is stopped.
+func (m *manager) stopMissingContainerExpiries() {
+	m.missingContainersLock.Lock()
+	defer m.missingContainersLock.Unlock()
+	for _, missing := range m.missingContainers {
+		missing.expiry.Stop()
+	}
+}
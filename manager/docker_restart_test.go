@@ -0,0 +1,223 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/container/docker"
+	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+	itest "github.com/google/cadvisor/info/v1/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Number of cleanups of the handlers of each Docker container.
+type cleanupCounts struct {
+	lock   sync.Mutex
+	counts map[string]int
+}
+
+func (self *cleanupCounts) get(name string) int {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.counts[name]
+}
+
+// Handler of a Docker container, counting its cleanups.
+type cleanupCountingHandler struct {
+	*container.MockContainerHandler
+	cleanups *cleanupCounts
+	name     string
+}
+
+func (self *cleanupCountingHandler) Cleanup() {
+	self.cleanups.lock.Lock()
+	defer self.cleanups.lock.Unlock()
+	if self.cleanups.counts == nil {
+		self.cleanups.counts = make(map[string]int)
+	}
+	self.cleanups.counts[self.name]++
+}
+
+// Returns a factory of handlers for the specified Docker containers under a
+// root container, and the number of cleanups of their handlers.
+func newDockerTestFactory(names ...string) (*container.FactoryForMockContainerHandler, *cleanupCounts) {
+	var subcontainers []info.ContainerReference
+	for _, name := range names {
+		subcontainers = append(subcontainers, info.ContainerReference{Name: name})
+	}
+	cleanups := &cleanupCounts{}
+	factory := &container.FactoryForMockContainerHandler{
+		Name: "docker-test",
+		WrapContainerHandlerFunc: func(name string, handler *container.MockContainerHandler) (container.ContainerHandler, error) {
+			handler.On("GetSpec").Return(info.ContainerSpec{}, nil)
+			handler.On("GetStats").Return(itest.GenerateRandomStats(1, 4, time.Second)[0], nil)
+			if name == "/" {
+				handler.On("ListContainers", container.ListRecursive).Return(subcontainers, nil)
+				return handler, nil
+			}
+			// Referenced by the mock rather than by its name to be in the Docker namespace.
+			handler.Name = ""
+			handler.On("ContainerReference").Return(info.ContainerReference{
+				Name:      name,
+				Aliases:   []string{docker.ContainerNameToDockerId(name)},
+				Namespace: docker.DockerNamespace,
+			}, nil)
+			handler.On("ListContainers", container.ListSelf).Return([]info.ContainerReference{}, nil)
+			return &cleanupCountingHandler{handler, cleanups, name}, nil
+		},
+	}
+	return factory, cleanups
+}
+
+// Creates a manager of the specified Docker containers with the specified
+// restart grace period, and the factory of their handlers.
+func newDockerRestartTestManager(grace time.Duration, names ...string) (*manager, *cleanupCounts, func()) {
+	config := newTestConfig()
+	config.DockerRestartGrace, config.HousekeepingInterval = grace, 5*time.Millisecond
+
+	factory, cleanups := newDockerTestFactory(names...)
+	m := newTestManager(config, factory)
+	return m, cleanups, func() {
+		m.stopMissingContainerExpiries()
+		stopped := m.missingContainerNames()
+		for name, cont := range m.containers {
+			if name.Name != cont.info.Name {
+				continue
+			}
+			if !stopped[name.Name] {
+				cont.StopAndWait(time.Second)
+			}
+			<-cont.housekeepingDone
+		}
+		container.ClearContainerHandlerFactories()
+	}
+}
+
+func numStats(m *manager, name string) int {
+	stats, err := m.memoryStorage.RecentStats(name, time.Time{}, time.Time{}, -1)
+	if err != nil {
+		return 0
+	}
+	return len(stats)
+}
+
+func getEventTypes(t *testing.T, m *manager) []events.EventType {
+	request := events.NewRequest()
+	request.EventType[events.TypeContainerDeletion] = true
+	request.EventType[events.TypeContainerReattached] = true
+	request.MaxEventsReturned = -1
+	evs, err := m.eventHandler.GetEvents(request)
+	require.Nil(t, err)
+	var types []events.EventType
+	for _, ev := range evs {
+		types = append(types, ev.EventType)
+	}
+	return types
+}
+
+func TestMissingDockerContainerIsReattached(t *testing.T) {
+	const name = "/docker/abc"
+	m, cleanups, cleanup := newDockerRestartTestManager(time.Hour, name)
+	defer cleanup()
+
+	require.Nil(t, m.createContainer("/"))
+	require.Nil(t, m.createContainer(name))
+	cont, err := m.getContainerData(name)
+	require.Nil(t, err)
+	waitFor(t, "stats to be collected", func() bool {
+		return numStats(m, name) >= 2
+	})
+
+	// The container disappears: it is kept, but no longer collected.
+	require.Nil(t, m.destroyContainer(name))
+	missing, err := m.getContainerData(name)
+	require.Nil(t, err)
+	assert.True(t, cont == missing)
+	assert.False(t, cont.CollectionStatus().MissingSince.IsZero())
+	assert.True(t, m.missingContainerNames()[name])
+	assert.Equal(t, 0, cleanups.get(name))
+
+	// Listing it again reattaches it.
+	added, removed, err := m.getContainersDiff("/")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(added))
+	assert.Equal(t, name, added[0].Name)
+	assert.Empty(t, removed)
+
+	before := numStats(m, name)
+	require.Nil(t, m.createContainer(name))
+	reattached, err := m.getContainerData(name)
+	require.Nil(t, err)
+	assert.True(t, cont == reattached)
+	assert.True(t, cont.CollectionStatus().MissingSince.IsZero())
+	// The handler of the container before it disappeared is replaced.
+	assert.Equal(t, 1, cleanups.get(name))
+	assert.Empty(t, m.missingContainerNames())
+	waitFor(t, "stats to be collected again", func() bool {
+		return numStats(m, name) > before
+	})
+	assert.Equal(t, []events.EventType{events.TypeContainerReattached}, getEventTypes(t, m))
+
+}
+
+func TestMissingDockerContainerIsDeletedAfterGrace(t *testing.T) {
+	const name = "/docker/abc"
+	m, cleanups, cleanup := newDockerRestartTestManager(10 * time.Millisecond)
+	defer cleanup()
+
+	require.Nil(t, m.createContainer(name))
+	cont, err := m.getContainerData(name)
+	require.Nil(t, err)
+	require.Nil(t, m.destroyContainer(name))
+	<-cont.housekeepingDone
+	// The deletion is recorded once the container is removed.
+	waitFor(t, "the container to be deleted", func() bool {
+		return len(getEventTypes(t, m)) != 0
+	})
+	_, err = m.getContainerData(name)
+	assert.NotNil(t, err)
+	assert.Empty(t, m.missingContainerNames())
+	assert.Equal(t, []events.EventType{events.TypeContainerDeletion}, getEventTypes(t, m))
+	assert.Equal(t, 1, cleanups.get(name))
+
+	// Reappearing afterwards creates a new container.
+	require.Nil(t, m.createContainer(name))
+	recreated, err := m.getContainerData(name)
+	require.Nil(t, err)
+	assert.False(t, cont == recreated)
+}
+
+func TestDockerContainerIsDeletedWithoutGrace(t *testing.T) {
+	const name = "/docker/abc"
+	m, cleanups, cleanup := newDockerRestartTestManager(0)
+	defer cleanup()
+
+	require.Nil(t, m.createContainer(name))
+	cont, err := m.getContainerData(name)
+	require.Nil(t, err)
+	require.Nil(t, m.destroyContainer(name))
+	<-cont.housekeepingDone
+	_, err = m.getContainerData(name)
+	assert.NotNil(t, err)
+	assert.Empty(t, m.missingContainerNames())
+	assert.Equal(t, []events.EventType{events.TypeContainerDeletion}, getEventTypes(t, m))
+	assert.Equal(t, 1, cleanups.get(name))
+}
@@ -0,0 +1,54 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/events"
+	"github.com/google/cadvisor/storage"
+)
+
+// EventManager which also writes the events to the backend storage so that
+// they outlive cAdvisor. Past events are read from the backend storage,
+// watches are served from memory.
+type storedEventManager struct {
+	events.EventManager
+	storage storage.EventStorage
+}
+
+func newStoredEventManager(eventManager events.EventManager, eventStorage storage.EventStorage) *storedEventManager {
+	return &storedEventManager{
+		EventManager: eventManager,
+		storage:      eventStorage,
+	}
+}
+
+func (self *storedEventManager) AddEvent(e *events.Event) error {
+	err := self.storage.AddEvent(e)
+	if err != nil {
+		// The event is still kept in memory.
+		glog.Errorf("Failed to write event %+v to the backend storage: %v", e, err)
+	}
+	return self.EventManager.AddEvent(e)
+}
+
+func (self *storedEventManager) GetEvents(request *events.Request) (events.EventSlice, error) {
+	eventList, err := self.storage.Events(request)
+	if err != nil {
+		glog.Errorf("Failed to read events from the backend storage, only returning the events in memory: %v", err)
+		return self.EventManager.GetEvents(request)
+	}
+	return eventList, nil
+}
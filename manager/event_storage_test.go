@@ -0,0 +1,99 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/events"
+)
+
+// Keeps the events in a slice.
+type fakeEventStorage struct {
+	events events.EventSlice
+	err    error
+}
+
+func (self *fakeEventStorage) AddEvent(e *events.Event) error {
+	if self.err != nil {
+		return self.err
+	}
+	self.events = append(self.events, e)
+	return nil
+}
+
+func (self *fakeEventStorage) Events(request *events.Request) (events.EventSlice, error) {
+	if self.err != nil {
+		return nil, self.err
+	}
+	return events.FilterEvents(request, self.events), nil
+}
+
+func oomRequest() *events.Request {
+	request := events.NewRequest()
+	request.EventType[events.TypeOom] = true
+	return request
+}
+
+func TestStoredEventManager(t *testing.T) {
+	eventStorage := &fakeEventStorage{
+		// Written by a previous cAdvisor.
+		events: events.EventSlice{{ContainerName: "/old", Timestamp: time.Now().Add(-time.Hour), EventType: events.TypeOom}},
+	}
+	inMemory := events.NewEventManager()
+	eventManager := newStoredEventManager(inMemory, eventStorage)
+
+	err := eventManager.AddEvent(&events.Event{ContainerName: "/new", Timestamp: time.Now(), EventType: events.TypeOom})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eventStorage.events) != 2 {
+		t.Errorf("Expected the event to be written to the storage, it has %d events", len(eventStorage.events))
+	}
+	if inMemoryEvents, _ := inMemory.GetEvents(oomRequest()); len(inMemoryEvents) != 1 {
+		t.Errorf("Expected the event to be kept in memory, found %d events", len(inMemoryEvents))
+	}
+
+	// Past events come from the storage.
+	eventList, err := eventManager.GetEvents(oomRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eventList) != 2 || eventList[0].ContainerName != "/old" || eventList[1].ContainerName != "/new" {
+		t.Errorf("Expected the events of the storage, found %+v", eventList)
+	}
+}
+
+func TestStoredEventManagerFallsBackToMemory(t *testing.T) {
+	eventStorage := &fakeEventStorage{
+		err: fmt.Errorf("storage is down"),
+	}
+	eventManager := newStoredEventManager(events.NewEventManager(), eventStorage)
+
+	// Events are kept in memory even if the storage fails.
+	err := eventManager.AddEvent(&events.Event{ContainerName: "/new", Timestamp: time.Now(), EventType: events.TypeOom})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventList, err := eventManager.GetEvents(oomRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eventList) != 1 || eventList[0].ContainerName != "/new" {
+		t.Errorf("Expected the events in memory, found %+v", eventList)
+	}
+}
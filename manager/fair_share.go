@@ -0,0 +1,76 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"path"
+
+	"github.com/google/cadvisor/info/v2"
+)
+
+// Returns the latest CPU rate of the container relative to its fair share of
+// its parent's rate: the parent's rate weighted by the container's CPU shares
+// over the total shares of its siblings (itself included). Nil for the root
+// container, containers without CPU isolation, and when the rates are not known.
+func (self *manager) fairShareRatio(containerName string, derived v2.DerivedStats) *float64 {
+	if containerName == "/" {
+		return nil
+	}
+	parentName := path.Dir(containerName)
+
+	var cont, parent *containerData
+	var siblings []*containerData
+	func() {
+		self.containersLock.RLock()
+		defer self.containersLock.RUnlock()
+		cont = self.containers[namespacedContainerName{Name: containerName}]
+		parent = self.containers[namespacedContainerName{Name: parentName}]
+		for name, cont := range self.containers {
+			if name.Namespace == "" && name.Name != "/" && path.Dir(name.Name) == parentName {
+				siblings = append(siblings, cont)
+			}
+		}
+	}()
+	if cont == nil || parent == nil {
+		return nil
+	}
+
+	var shares, totalShares uint64
+	for _, sibling := range siblings {
+		siblingShares, hasCpu := sibling.cpuShares()
+		if !hasCpu {
+			continue
+		}
+		totalShares += siblingShares
+		if sibling == cont {
+			shares = siblingShares
+		}
+	}
+	parentDerived, err := parent.DerivedStats()
+	if err != nil {
+		return nil
+	}
+	return computeFairShareRatio(derived.LatestUsage.Cpu, parentDerived.LatestUsage.Cpu, shares, totalShares)
+}
+
+// Returns rate / (shares / totalShares × parentRate), nil if the fair share is zero.
+func computeFairShareRatio(rate, parentRate, shares, totalShares uint64) *float64 {
+	if shares == 0 || totalShares == 0 || parentRate == 0 {
+		return nil
+	}
+	fairShare := float64(shares) / float64(totalShares) * float64(parentRate)
+	ratio := float64(rate) / fairShare
+	return &ratio
+}
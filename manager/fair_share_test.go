@@ -0,0 +1,97 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/container"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/storage/memory"
+)
+
+// Adds a container using the CPU at the rate, in milliCPUs, to the manager.
+func addFairShareContainer(m *manager, name string, spec info.ContainerSpec, rate uint64, t *testing.T) {
+	handler := container.NewMockContainerHandler(name)
+	spec.HasMemory = true
+	handler.On("GetSpec").Return(spec, nil)
+	cont, err := newContainerData(name, memory.New(60, nil), handler, newTestConfig(), nil, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for i, usage := range []uint64{0, rate * uint64(time.Millisecond)} {
+		stats := info.ContainerStats{Timestamp: start.Add(time.Duration(i) * time.Second)}
+		stats.Cpu.Usage.Total = usage
+		if err := cont.summaryReader.AddSample(stats); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.containers[namespacedContainerName{Name: name}] = cont
+}
+
+func cpuSpec(shares uint64) info.ContainerSpec {
+	return info.ContainerSpec{HasCpu: true, Cpu: info.CpuSpec{Limit: shares}}
+}
+
+func TestFairShareRatio(t *testing.T) {
+	m := newTestManager(newTestConfig(), nil)
+	// /a has 2/3 of the shares under /parent and /b 1/3, /c has no CPU isolation.
+	addFairShareContainer(m, "/parent", cpuSpec(1024), 2000, t)
+	addFairShareContainer(m, "/parent/a", cpuSpec(1024), 1000, t)
+	addFairShareContainer(m, "/parent/b", cpuSpec(512), 250, t)
+	addFairShareContainer(m, "/parent/c", info.ContainerSpec{}, 500, t)
+
+	expected := map[string]float64{
+		// 1000 / (2/3 * 2000)
+		"/parent/a": 0.75,
+		// 250 / (1/3 * 2000)
+		"/parent/b": 0.375,
+	}
+	for name, ratio := range expected {
+		derived, err := m.GetContainerDerivedStats(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if derived.FairShareRatio == nil || math.Abs(*derived.FairShareRatio-ratio) > 1e-9 {
+			t.Errorf("Expected a fair share ratio of %v for %q, got %v", ratio, name, derived.FairShareRatio)
+		}
+	}
+
+	// No ratio without CPU isolation or a parent.
+	for _, name := range []string{"/parent/c", "/parent"} {
+		derived, err := m.GetContainerDerivedStats(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if derived.FairShareRatio != nil {
+			t.Errorf("Expected no fair share ratio for %q, got %v", name, *derived.FairShareRatio)
+		}
+	}
+}
+
+func TestComputeFairShareRatio(t *testing.T) {
+	if ratio := computeFairShareRatio(500, 1000, 1024, 2048); ratio == nil || *ratio != 1 {
+		t.Errorf("Expected a ratio of 1 for a container getting its share, got %v", ratio)
+	}
+	if ratio := computeFairShareRatio(500, 0, 1024, 2048); ratio != nil {
+		t.Errorf("Expected no ratio for an idle parent, got %v", *ratio)
+	}
+	if ratio := computeFairShareRatio(500, 1000, 0, 2048); ratio != nil {
+		t.Errorf("Expected no ratio without shares, got %v", *ratio)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"regexp"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Selects containers by name, alias, namespace and labels. Nil and empty
+// fields select all containers, the others must all match.
+type ContainerFilter struct {
+	// Matched against the absolute name of the container.
+	Name *regexp.Regexp
+
+	// Matched against the aliases of the container, any of which may match.
+	Alias *regexp.Regexp
+
+	// Namespace of the aliases of the container (e.g.: "docker").
+	Namespace string
+
+	// Labels the container must have with the specified values.
+	Labels map[string]string
+}
+
+// Returns whether the container with the specified reference and spec is selected.
+func (self *ContainerFilter) Matches(ref info.ContainerReference, spec info.ContainerSpec) bool {
+	if self == nil {
+		return true
+	}
+	if self.Name != nil && !self.Name.MatchString(ref.Name) {
+		return false
+	}
+	if self.Alias != nil {
+		matched := false
+		for _, alias := range ref.Aliases {
+			if self.Alias.MatchString(alias) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if self.Namespace != "" && ref.Namespace != self.Namespace {
+		return false
+	}
+	for key, value := range self.Labels {
+		// Containers without labels are not selected.
+		if actual, ok := spec.Labels[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,58 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"regexp"
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerFilterMatches(t *testing.T) {
+	ref := info.ContainerReference{
+		Name:      "/docker/abc",
+		Aliases:   []string{"web-1", "abc"},
+		Namespace: "docker",
+	}
+	spec := info.ContainerSpec{
+		Labels: map[string]string{"env": "prod", "tier": "frontend"},
+	}
+	for _, test := range []struct {
+		filter   *ContainerFilter
+		expected bool
+	}{
+		{nil, true},
+		{&ContainerFilter{}, true},
+		{&ContainerFilter{Name: regexp.MustCompile("^/docker/")}, true},
+		{&ContainerFilter{Name: regexp.MustCompile("^/system")}, false},
+		{&ContainerFilter{Alias: regexp.MustCompile("^web-")}, true},
+		{&ContainerFilter{Alias: regexp.MustCompile("^db")}, false},
+		{&ContainerFilter{Namespace: "docker"}, true},
+		{&ContainerFilter{Namespace: "other"}, false},
+		{&ContainerFilter{Labels: map[string]string{"env": "prod", "tier": "frontend"}}, true},
+		{&ContainerFilter{Labels: map[string]string{"env": "prod", "tier": "backend"}}, false},
+		{&ContainerFilter{Labels: map[string]string{"owner": ""}}, false},
+		{&ContainerFilter{Name: regexp.MustCompile("abc"), Labels: map[string]string{"env": "dev"}}, false},
+	} {
+		assert.Equal(t, test.expected, test.filter.Matches(ref, spec), "filter %+v", test.filter)
+	}
+
+	// Containers without aliases or labels are not selected by filters on them.
+	unlabeled := info.ContainerReference{Name: "/system"}
+	assert.False(t, (&ContainerFilter{Alias: regexp.MustCompile(".*")}).Matches(unlabeled, info.ContainerSpec{}))
+	assert.False(t, (&ContainerFilter{Labels: map[string]string{"env": "prod"}}).Matches(unlabeled, info.ContainerSpec{}))
+}
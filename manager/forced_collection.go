@@ -0,0 +1,121 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/container"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/utils/timing"
+)
+
+// Minimum interval between forced collections of the stats of a container.
+const forcedCollectionInterval = time.Second
+
+// Error returned when the stats of a container were already force-collected
+// less than forcedCollectionInterval ago.
+type RateLimitedError struct {
+	ContainerName string
+
+	// Time until the stats of the container may be force-collected again.
+	RetryAfter time.Duration
+
+	// Age of the newest stats of the container, negative if it has none.
+	NewestSampleAge time.Duration
+}
+
+func (self *RateLimitedError) Error() string {
+	newest := "there are no stats yet"
+	if self.NewestSampleAge >= 0 {
+		newest = fmt.Sprintf("the newest stats are %v old", self.NewestSampleAge)
+	}
+	return fmt.Sprintf("the stats of container %q were collected on request less than %v ago, retry in %v (%s)", self.ContainerName, forcedCollectionInterval, self.RetryAfter, newest)
+}
+
+// Records a forced collection at the specified time. Returns false and how
+// long until the next one is allowed if the last one was too recent.
+func (c *containerData) allowForcedCollection(now time.Time) (time.Duration, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if elapsed := now.Sub(c.lastForcedCollection); elapsed >= 0 && elapsed < forcedCollectionInterval {
+		return forcedCollectionInterval - elapsed, false
+	}
+	c.lastForcedCollection = now
+	return 0, true
+}
+
+func (self *manager) CollectContainerInfo(containerName string, query *info.ContainerInfoRequest) (*info.ContainerInfo, error) {
+	cont, err := self.getContainerData(containerName)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if retryAfter, ok := cont.allowForcedCollection(now); !ok {
+		rateLimited := &RateLimitedError{
+			ContainerName:   containerName,
+			RetryAfter:      retryAfter,
+			NewestSampleAge: -1,
+		}
+		stats, err := self.memoryStorage.RecentStats(cont.info.Name, time.Time{}, time.Time{}, 1)
+		if err == nil && len(stats) == 1 {
+			rateLimited.NewestSampleAge = now.Sub(stats[0].Timestamp)
+		}
+		return nil, rateLimited
+	}
+
+	// The stats must not be used if the collection timed out, it may still set them.
+	var stats *info.ContainerStats
+	var collectErr, specErr error
+	err = container.RunWithTimeout(fmt.Sprintf("collection of the stats of %q", containerName), func() error {
+		stats, collectErr = cont.collectStats(timing.NewSections())
+		// The limits may just have changed too.
+		specErr = cont.updateSpec()
+		return nil
+	})
+	if err != nil {
+		cont.recordTimeout(err)
+		return nil, err
+	}
+	if stats == nil {
+		if collectErr == nil {
+			collectErr = fmt.Errorf("container %q no longer exists", containerName)
+		}
+		return nil, collectErr
+	}
+	if collectErr != nil {
+		glog.V(1).Infof("Collected partial stats of container %q on request: %v", containerName, collectErr)
+	}
+	if specErr != nil {
+		glog.V(1).Infof("Failed to refresh the spec of container %q on request: %v", containerName, specErr)
+	}
+
+	cinfo, err := cont.GetInfo()
+	if err != nil {
+		return nil, err
+	}
+	ret := &info.ContainerInfo{
+		ContainerReference: cinfo.ContainerReference,
+		Subcontainers:      cinfo.Subcontainers,
+		Spec:               self.getAdjustedSpec(cinfo),
+		Stats:              []*info.ContainerStats{stats},
+	}
+	if query.IncludeMachine {
+		self.addMachineCapacity(ret)
+	}
+	return ret, nil
+}
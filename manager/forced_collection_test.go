@@ -0,0 +1,102 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/container"
+	info "github.com/google/cadvisor/info/v1"
+	itest "github.com/google/cadvisor/info/v1/test"
+	"github.com/google/cadvisor/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Returns a manager with only the specified container.
+func newForcedCollectionTestManager(cd *containerData, memoryStorage *memory.InMemoryStorage) *manager {
+	m := newTestManager(newTestConfig(), nil)
+	m.containers[namespacedContainerName{Name: cd.info.Name}] = cd
+	m.memoryStorage = memoryStorage
+	return m
+}
+
+func TestCollectContainerInfoIsRateLimited(t *testing.T) {
+	cd, mockHandler, memoryStorage := newTestContainerData(t)
+	stats := itest.GenerateRandomStats(1, 4, time.Second)[0]
+	stats.Timestamp = time.Now()
+	mockHandler.On("GetStats").Return(stats, nil)
+	mockHandler.On("ListContainers", container.ListSelf).Return([]info.ContainerReference{}, nil)
+	m := newForcedCollectionTestManager(cd, memoryStorage)
+
+	cinfo, err := m.CollectContainerInfo(containerName, &info.ContainerInfoRequest{})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(cinfo.Stats))
+	assert.True(t, cinfo.Stats[0].Eq(stats))
+	checkNumStats(t, memoryStorage, 1)
+
+	// A second collection right away is rejected with the age of the stats just collected.
+	_, err = m.CollectContainerInfo(containerName, &info.ContainerInfoRequest{})
+	rateLimited, ok := err.(*RateLimitedError)
+	require.True(t, ok, "expected a rate limited error, got %v", err)
+	assert.True(t, rateLimited.RetryAfter > 0 && rateLimited.RetryAfter <= forcedCollectionInterval)
+	assert.True(t, rateLimited.NewestSampleAge >= 0 && rateLimited.NewestSampleAge < time.Minute)
+	checkNumStats(t, memoryStorage, 1)
+
+	// Once the interval elapsed, the stats are collected again.
+	cd.lock.Lock()
+	cd.lastForcedCollection = cd.lastForcedCollection.Add(-forcedCollectionInterval)
+	cd.lock.Unlock()
+	_, err = m.CollectContainerInfo(containerName, &info.ContainerInfoRequest{})
+	assert.Nil(t, err)
+}
+
+func TestCollectContainerInfoRateLimitedWithoutStats(t *testing.T) {
+	cd, _, memoryStorage := newTestContainerData(t)
+	m := newForcedCollectionTestManager(cd, memoryStorage)
+	_, ok := cd.allowForcedCollection(time.Now())
+	require.True(t, ok)
+
+	_, err := m.CollectContainerInfo(containerName, &info.ContainerInfoRequest{})
+	rateLimited, ok := err.(*RateLimitedError)
+	require.True(t, ok, "expected a rate limited error, got %v", err)
+	assert.True(t, rateLimited.NewestSampleAge < 0)
+}
+
+func TestCollectContainerInfoTimesOut(t *testing.T) {
+	oldTimeout := *container.HandlerOpTimeout
+	*container.HandlerOpTimeout = 10 * time.Millisecond
+	defer func() {
+		*container.HandlerOpTimeout = oldTimeout
+	}()
+
+	mockHandler := container.NewMockContainerHandler(containerName)
+	mockHandler.On("GetSpec").Return(itest.GenerateRandomContainerSpec(4), nil)
+	mockHandler.On("GetStats").Return(itest.GenerateRandomStats(1, 4, time.Second)[0], nil)
+	mockHandler.On("Exists").Return(true)
+	handler := &slowContainerHandler{
+		MockContainerHandler: mockHandler,
+		delay:                200 * time.Millisecond,
+	}
+	memoryStorage := memory.New(60, nil)
+	cd, err := newContainerData(containerName, memoryStorage, handler, newTestConfig(), nil, nil, false)
+	require.Nil(t, err)
+	m := newForcedCollectionTestManager(cd, memoryStorage)
+
+	_, err = m.CollectContainerInfo(containerName, &info.ContainerInfoRequest{})
+	assert.True(t, container.IsTimeout(err), "expected a timeout, got %v", err)
+	assert.Equal(t, uint64(1), cd.CollectionStatus().Timeouts)
+}
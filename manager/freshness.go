@@ -0,0 +1,67 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Classifies the freshness of stats whose newest sample was taken at newest
+// (zero if there is none) by a housekeeping every interval. Stats are fresh
+// up to FreshnessStaleIntervals intervals old and stale up to
+// FreshnessDeadIntervals, after which the container is considered dead.
+// A container without stats yet is stale.
+func (self *Config) getFreshness(now, newest time.Time, interval time.Duration) *info.Freshness {
+	freshness := &info.Freshness{
+		State:                info.FreshnessStale,
+		NewestSampleAge:      -1,
+		HousekeepingInterval: interval,
+	}
+	if newest.IsZero() {
+		return freshness
+	}
+	age := now.Sub(newest)
+	if age < 0 {
+		age = 0
+	}
+	freshness.NewestSampleAge = age
+	switch {
+	case float64(age) <= self.FreshnessStaleIntervals*float64(interval):
+		freshness.State = info.FreshnessFresh
+	case float64(age) <= self.FreshnessDeadIntervals*float64(interval):
+		freshness.State = info.FreshnessStale
+	default:
+		freshness.State = info.FreshnessDead
+	}
+	return freshness
+}
+
+// Returns the freshness of the container's stats, compared with the
+// interval it is currently housekept at.
+func (c *containerData) freshness() *info.Freshness {
+	c.lock.Lock()
+	name := c.info.Name
+	interval := c.collectionStatus.HousekeepingInterval
+	c.lock.Unlock()
+
+	var newest time.Time
+	stats, err := c.memoryStorage.RecentStats(name, time.Time{}, time.Time{}, 1)
+	if err == nil && len(stats) != 0 {
+		newest = stats[0].Timestamp
+	}
+	return c.config.getFreshness(c.clock.Now(), newest, interval)
+}
@@ -0,0 +1,96 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/utils/clock/fakeclock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetFreshnessBoundaries(t *testing.T) {
+	config := newTestConfig()
+	config.FreshnessStaleIntervals, config.FreshnessDeadIntervals = 2, 10
+
+	now := time.Unix(1433160000, 0)
+	interval := time.Second
+	for _, c := range []struct {
+		age   time.Duration
+		state string
+	}{
+		{0, info.FreshnessFresh},
+		{2 * time.Second, info.FreshnessFresh},
+		{2*time.Second + time.Millisecond, info.FreshnessStale},
+		{10 * time.Second, info.FreshnessStale},
+		{10*time.Second + time.Millisecond, info.FreshnessDead},
+		{time.Hour, info.FreshnessDead},
+	} {
+		freshness := config.getFreshness(now, now.Add(-c.age), interval)
+		assert.Equal(t, c.state, freshness.State, "age %v", c.age)
+		assert.Equal(t, c.age, freshness.NewestSampleAge)
+		assert.Equal(t, interval, freshness.HousekeepingInterval)
+	}
+
+	// Stats from slightly in the future are fresh.
+	freshness := config.getFreshness(now, now.Add(time.Second), interval)
+	assert.Equal(t, info.FreshnessFresh, freshness.State)
+	assert.Equal(t, time.Duration(0), freshness.NewestSampleAge)
+
+	// No stats yet.
+	freshness = config.getFreshness(now, time.Time{}, interval)
+	assert.Equal(t, info.FreshnessStale, freshness.State)
+	assert.True(t, freshness.NewestSampleAge < 0)
+
+	// The thresholds scale with the interval.
+	freshness = config.getFreshness(now, now.Add(-30*time.Second), 20*time.Second)
+	assert.Equal(t, info.FreshnessFresh, freshness.State)
+}
+
+func TestContainerFreshness(t *testing.T) {
+	cd, _, memoryStorage := newTestContainerData(t)
+	clock := fakeclock.NewFakeClock(time.Unix(1433160000, 0))
+	cd.clock = clock
+	cd.lock.Lock()
+	cd.collectionStatus.HousekeepingInterval = 10 * time.Second
+	cd.lock.Unlock()
+
+	assert.Equal(t, info.FreshnessStale, cd.freshness().State)
+
+	stats := &info.ContainerStats{Timestamp: clock.Now()}
+	require.Nil(t, memoryStorage.AddStats(info.ContainerReference{Name: containerName}, stats))
+	freshness := cd.freshness()
+	assert.Equal(t, info.FreshnessFresh, freshness.State)
+	assert.Equal(t, 10*time.Second, freshness.HousekeepingInterval)
+
+	clock.Step(time.Duration(cd.config.FreshnessStaleIntervals*10) * time.Second)
+	assert.Equal(t, info.FreshnessFresh, cd.freshness().State)
+	clock.Step(time.Second)
+	assert.Equal(t, info.FreshnessStale, cd.freshness().State)
+
+	// A longer interval, e.g. while collection is degraded, makes the stats fresh again.
+	cd.lock.Lock()
+	cd.collectionStatus.HousekeepingInterval = time.Minute
+	cd.lock.Unlock()
+	assert.Equal(t, info.FreshnessFresh, cd.freshness().State)
+
+	clock.Step(time.Duration(cd.config.FreshnessDeadIntervals) * time.Minute)
+	freshness = cd.freshness()
+	assert.Equal(t, info.FreshnessDead, freshness.State)
+	assert.True(t, freshness.NewestSampleAge > time.Duration(cd.config.FreshnessDeadIntervals)*time.Minute)
+}
@@ -0,0 +1,56 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/cadvisor/container/groups"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Gives the groups access to the containers of the manager and their stats.
+type groupSource struct {
+	m *manager
+}
+
+// Lists the containers with the label from their last known spec, without
+// reading it from their handlers. Groups are not members of other groups.
+func (self groupSource) ListMembers(label string) map[string][]groups.Member {
+	self.m.containersLock.RLock()
+	defer self.m.containersLock.RUnlock()
+	ret := make(map[string][]groups.Member)
+	for key, cont := range self.m.containers {
+		cont.lock.Lock()
+		name, spec := cont.info.Name, cont.info.Spec
+		cont.lock.Unlock()
+		// Only the canonical name, not the aliases.
+		if key.Name != name || key.Namespace != "" || name == groups.Root || strings.HasPrefix(name, groups.Root+"/") {
+			continue
+		}
+		if value, ok := spec.Labels[label]; ok {
+			ret[value] = append(ret[value], groups.Member{
+				Name: name,
+				Spec: spec,
+			})
+		}
+	}
+	return ret
+}
+
+func (self groupSource) Snapshot(names []string) (time.Time, map[string]*info.ContainerStats) {
+	return self.m.memoryStorage.Snapshot(names)
+}
@@ -0,0 +1,54 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/google/cadvisor/container/groups"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupSourceListsLabeledContainers(t *testing.T) {
+	m := newTestManager(newTestConfig(), nil)
+	for name, labels := range map[string]map[string]string{
+		"/docker/pause":   {"pod": "web"},
+		"/docker/app":     {"pod": "web", "tier": "frontend"},
+		"/docker/db":      {"pod": "db"},
+		"/docker/other":   {"tier": "backend"},
+		groups.Root:       nil,
+		"/groups/web":     {"pod": "web"},
+		"/system/unnamed": nil,
+	} {
+		cont := newChurnContainer(name, []string{"alias-" + name}, t)
+		cont.info.Spec.Labels = labels
+		require.True(t, m.addContainer(cont))
+	}
+
+	members := groupSource{m}.ListMembers("pod")
+	require.Equal(t, 2, len(members))
+	var names []string
+	for _, member := range members["web"] {
+		names = append(names, member.Name)
+		assert.Equal(t, "web", member.Spec.Labels["pod"])
+	}
+	sort.Strings(names)
+	// Aliases and the groups themselves are not members.
+	assert.Equal(t, []string{"/docker/app", "/docker/pause"}, names)
+	require.Equal(t, 1, len(members["db"]))
+	assert.Equal(t, "/docker/db", members["db"][0].Name)
+}
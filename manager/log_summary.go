@@ -0,0 +1,156 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// Number of containers named in a summary of those failing to collect stats.
+const maxSummaryNames = 10
+
+// Aggregates the lifecycle and housekeeping interval changes of the containers
+// into a single log line per global housekeeping, rather than a line per
+// container which adds up to megabytes per minute with thousands of
+// containers. The notices of each container are only logged at V(3).
+// Methods may be called on a nil aggregator, which only logs the notices.
+type logAggregator struct {
+	lock sync.Mutex
+
+	// Numbers of containers added and removed since the last summary.
+	added   int
+	removed int
+
+	// Containers whose housekeeping interval was raised, lowered, or which
+	// failed to collect stats since the last summary.
+	raised  map[string]bool
+	lowered map[string]bool
+	failing map[string]bool
+}
+
+func newLogAggregator() *logAggregator {
+	return &logAggregator{
+		raised:  make(map[string]bool),
+		lowered: make(map[string]bool),
+		failing: make(map[string]bool),
+	}
+}
+
+func (self *logAggregator) containerAdded(name string, aliases []string, namespace string) {
+	glog.V(3).Infof("Added container: %q (aliases: %v, namespace: %q)", name, aliases, namespace)
+	if self == nil {
+		return
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.added++
+}
+
+func (self *logAggregator) containerRemoved(name string, aliases []string, namespace string) {
+	glog.V(3).Infof("Destroyed container: %q (aliases: %v, namespace: %q)", name, aliases, namespace)
+	if self == nil {
+		return
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.removed++
+}
+
+func (self *logAggregator) housekeepingStarted(name string) {
+	glog.V(3).Infof("Start housekeeping for container %q", name)
+}
+
+func (self *logAggregator) intervalRaised(name string, interval time.Duration) {
+	glog.V(3).Infof("Raising housekeeping interval for %q to %v", name, interval)
+	if self == nil {
+		return
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.raised[name] = true
+}
+
+func (self *logAggregator) intervalLowered(name string, interval time.Duration) {
+	glog.V(3).Infof("Lowering housekeeping interval for %q to %v", name, interval)
+	if self == nil {
+		return
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.lowered[name] = true
+}
+
+func (self *logAggregator) collectionFailed(name string, err error) {
+	glog.V(3).Infof("Failed to update stats for container %q: %v", name, err)
+	if self == nil {
+		return
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.failing[name] = true
+}
+
+// Returns the summary of the notices since the last summary and starts a new
+// one, empty if there were none.
+func (self *logAggregator) summarize() string {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	var parts []string
+	if self.added != 0 {
+		parts = append(parts, fmt.Sprintf("added %d", self.added))
+	}
+	if self.removed != 0 {
+		parts = append(parts, fmt.Sprintf("removed %d", self.removed))
+	}
+	if len(self.raised) != 0 {
+		parts = append(parts, fmt.Sprintf("raised interval on %d", len(self.raised)))
+	}
+	if len(self.lowered) != 0 {
+		parts = append(parts, fmt.Sprintf("lowered interval on %d", len(self.lowered)))
+	}
+	if len(self.failing) != 0 {
+		names := make([]string, 0, len(self.failing))
+		for name := range self.failing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) > maxSummaryNames {
+			names = append(names[:maxSummaryNames], fmt.Sprintf("and %d more", len(self.failing)-maxSummaryNames))
+		}
+		parts = append(parts, fmt.Sprintf("collection failing on %d: [%s]", len(self.failing), strings.Join(names, ", ")))
+	}
+	self.added = 0
+	self.removed = 0
+	self.raised = make(map[string]bool)
+	self.lowered = make(map[string]bool)
+	self.failing = make(map[string]bool)
+	return strings.Join(parts, ", ")
+}
+
+// Logs the summary of the notices since the last one, if any.
+func (self *logAggregator) flush() {
+	if self == nil {
+		return
+	}
+	if summary := self.summarize(); summary != "" {
+		glog.Infof("Containers %s", summary)
+	}
+}
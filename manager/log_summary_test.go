@@ -0,0 +1,75 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogSummary(t *testing.T) {
+	logs := newLogAggregator()
+	assert.Equal(t, "", logs.summarize())
+
+	for i := 0; i < 3; i++ {
+		logs.containerAdded(fmt.Sprintf("/docker/%d", i), nil, "docker")
+	}
+	logs.containerRemoved("/docker/0", nil, "docker")
+	logs.intervalRaised("/docker/1", 2*time.Second)
+	// A container counts once however many times its interval changed.
+	logs.intervalRaised("/docker/1", 4*time.Second)
+	logs.intervalRaised("/docker/2", 2*time.Second)
+	logs.intervalLowered("/docker/1", time.Second)
+	logs.collectionFailed("/docker/2", fmt.Errorf("failed to read memory"))
+	logs.collectionFailed("/docker/1", fmt.Errorf("failed to read memory"))
+	logs.collectionFailed("/docker/2", fmt.Errorf("failed to read memory"))
+	assert.Equal(t, "added 3, removed 1, raised interval on 2, lowered interval on 1, collection failing on 2: [/docker/1, /docker/2]", logs.summarize())
+
+	// Each summary starts anew.
+	assert.Equal(t, "", logs.summarize())
+	logs.containerRemoved("/docker/1", nil, "docker")
+	assert.Equal(t, "removed 1", logs.summarize())
+}
+
+func TestLogSummaryNamesFewFailingContainers(t *testing.T) {
+	logs := newLogAggregator()
+	for i := 0; i < maxSummaryNames+3; i++ {
+		logs.collectionFailed(fmt.Sprintf("/docker/%02d", i), fmt.Errorf("failed to read memory"))
+	}
+	assert.Equal(t, "collection failing on 13: [/docker/00, /docker/01, /docker/02, /docker/03, /docker/04, /docker/05, /docker/06, /docker/07, /docker/08, /docker/09, and 3 more]", logs.summarize())
+}
+
+func TestLogSummaryOfContainerLifecycle(t *testing.T) {
+	m, _, cleanup := newDockerRestartTestManager(0)
+	defer cleanup()
+	m.logs = newLogAggregator()
+
+	for _, name := range []string{"/docker/a", "/docker/b"} {
+		assert.Nil(t, m.createContainer(name))
+	}
+	assert.Nil(t, m.destroyContainer("/docker/a"))
+	assert.Equal(t, "added 2, removed 1", m.logs.summarize())
+}
+
+func TestNilLogAggregator(t *testing.T) {
+	var logs *logAggregator
+	logs.containerAdded("/docker/a", nil, "docker")
+	logs.intervalRaised("/docker/a", 2*time.Second)
+	logs.collectionFailed("/docker/a", fmt.Errorf("failed to read memory"))
+	logs.flush()
+}
@@ -16,9 +16,9 @@ package manager
 
 import (
 	"bytes"
-	"flag"
 	"fmt"
 	"io/ioutil"
+	"path"
 	"regexp"
 	"strconv"
 	"strings"
@@ -41,8 +41,6 @@ var nodeRegExp = regexp.MustCompile("physical id\\t*: +([0-9]+)")
 var CpuClockSpeedMHz = regexp.MustCompile("cpu MHz\\t*: +([0-9]+.[0-9]+)")
 var memoryCapacityRegexp = regexp.MustCompile("MemTotal: *([0-9]+) kB")
 
-var machineIdFilePath = flag.String("machine_id_file", "/etc/machine-id,/var/lib/dbus/machine-id", "Comma-separated list of files to check for machine-id. Use the first one that exists.")
-
 func getClockSpeed(procInfo []byte) (uint64, error) {
 	// First look through sys to find a max supported cpu frequency.
 	const maxFreqFile = "/sys/devices/system/cpu/cpu0/cpufreq/cpuinfo_max_freq"
@@ -209,21 +207,32 @@ func getTopology(sysFs sysfs.SysFs, cpuinfo string) ([]info.Node, int, error) {
 	return nodes, numCores, nil
 }
 
-func getMachineID() string {
-	if len(*machineIdFilePath) == 0 {
+// Reads the machine ID from the first of the comma-separated files that exists.
+func getMachineID(machineIdFiles string) string {
+	if len(machineIdFiles) == 0 {
 		return ""
 	}
-	for _, file := range strings.Split(*machineIdFilePath, ",") {
+	for _, file := range strings.Split(machineIdFiles, ",") {
 		id, err := ioutil.ReadFile(file)
 		if err == nil {
 			return strings.TrimSpace(string(id))
 		}
 	}
-	glog.Infof("Couldn't collect machine-id from any of the files in %q", *machineIdFilePath)
+	glog.Infof("Couldn't collect machine-id from any of the files in %q", machineIdFiles)
 	return ""
 }
 
-func getMachineInfo(sysFs sysfs.SysFs, fsInfo fs.FsInfo) (*info.MachineInfo, error) {
+// Collects the information of the machine. It is collected once, at startup,
+// and given to both the manager and the storage drivers (see GetMachineTags).
+func GetMachineInfo(sysFs sysfs.SysFs, config Config) (*info.MachineInfo, error) {
+	fsInfo, err := fs.NewFsInfo(fs.Context{DockerRoot: docker.RootDir()})
+	if err != nil {
+		return nil, err
+	}
+	return getMachineInfo(sysFs, fsInfo, config.MachineIdFiles, config.RootFs)
+}
+
+func getMachineInfo(sysFs sysfs.SysFs, fsInfo fs.FsInfo, machineIdFiles string, rootFs string) (*info.MachineInfo, error) {
 	cpuinfo, err := ioutil.ReadFile("/proc/cpuinfo")
 	clockSpeed, err := getClockSpeed(cpuinfo)
 	if err != nil {
@@ -273,10 +282,15 @@ func getMachineInfo(sysFs sysfs.SysFs, fsInfo fs.FsInfo) (*info.MachineInfo, err
 		DiskMap:        diskMap,
 		NetworkDevices: netDevices,
 		Topology:       topology,
-		MachineID:      getMachineID(),
+		MachineID:      getMachineID(machineIdFiles),
 		SystemUUID:     systemUUID,
 	}
 
+	machineInfo.KernelVersion = getKernelVersion()
+	machineInfo.OSImage = getOsImage(rootFs)
+	machineInfo.DockerVersion, machineInfo.DockerStorageDriver = getDockerInfo()
+	machineInfo.CadvisorVersion = version.VERSION
+
 	for _, fs := range filesystems {
 		machineInfo.Filesystems = append(machineInfo.Filesystems, info.FsInfo{Device: fs.Device, Capacity: fs.Capacity})
 	}
@@ -298,23 +312,52 @@ func getVersionInfo() (*info.VersionInfo, error) {
 	}, nil
 }
 
+const osReleaseFile = "/etc/os-release"
+
 func getContainerOsVersion() string {
 	container_os := "Unknown"
-	os_release, err := ioutil.ReadFile("/etc/os-release")
+	os_release, err := ioutil.ReadFile(osReleaseFile)
 	if err == nil {
 		// We might be running in a busybox or some hand-crafted image.
 		// It's useful to know why cadvisor didn't come up.
-		for _, line := range strings.Split(string(os_release), "\n") {
-			parsed := strings.Split(line, "\"")
-			if len(parsed) == 3 && parsed[0] == "PRETTY_NAME=" {
-				container_os = parsed[1]
-				break
-			}
+		if prettyName := parseOsRelease(string(os_release)); prettyName != "" {
+			container_os = prettyName
 		}
 	}
 	return container_os
 }
 
+// Returns the PRETTY_NAME of the os-release of the host, whose root filesystem
+// is mounted at rootFs, or "Unknown" if it cannot be read.
+func getOsImage(rootFs string) string {
+	osRelease, err := ioutil.ReadFile(path.Join(rootFs, osReleaseFile))
+	if err != nil {
+		glog.V(2).Infof("Failed to read the os-release of the host: %v", err)
+		return "Unknown"
+	}
+	if prettyName := parseOsRelease(string(osRelease)); prettyName != "" {
+		return prettyName
+	}
+	return "Unknown"
+}
+
+// Returns the PRETTY_NAME in the specified os-release contents or an empty string if there is none.
+// Values may be unquoted or surrounded by single or double quotes.
+func parseOsRelease(os_release string) string {
+	for _, line := range strings.Split(os_release, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "PRETTY_NAME=") {
+			continue
+		}
+		value := strings.TrimPrefix(line, "PRETTY_NAME=")
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		return value
+	}
+	return ""
+}
+
 func getDockerVersion() string {
 	docker_version := "Unknown"
 	client, err := dclient.NewClient(*docker.ArgDockerEndpoint)
@@ -327,6 +370,45 @@ func getDockerVersion() string {
 	return docker_version
 }
 
+// Returns the version and storage driver of the Docker daemon. Both are empty if Docker is not present.
+func getDockerInfo() (string, string) {
+	client, err := dclient.NewClient(*docker.ArgDockerEndpoint)
+	if err != nil {
+		return "", ""
+	}
+	version, err := client.Version()
+	if err != nil {
+		glog.V(2).Infof("Docker not present, omitting Docker version: %v", err)
+		return "", ""
+	}
+	storageDriver := ""
+	dockerInfo, err := client.Info()
+	if err != nil {
+		glog.Warningf("Failed to get Docker storage driver: %v", err)
+	} else {
+		storageDriver = dockerInfo.Get("Driver")
+	}
+	return version.Get("Version"), storageDriver
+}
+
+// Returns the machine's identifying attributes as static tags suitable for a storage driver.
+// Attributes that are not known (e.g.: Docker is not present) are omitted.
+func GetMachineTags(machineInfo *info.MachineInfo) map[string]string {
+	tags := make(map[string]string)
+	add := func(key, value string) {
+		if value != "" && value != "Unknown" {
+			tags[key] = value
+		}
+	}
+	add("machine_id", machineInfo.MachineID)
+	add("kernel_version", machineInfo.KernelVersion)
+	add("os_image", machineInfo.OSImage)
+	add("docker_version", machineInfo.DockerVersion)
+	add("docker_storage_driver", machineInfo.DockerStorageDriver)
+	add("cadvisor_version", machineInfo.CadvisorVersion)
+	return tags
+}
+
 func getKernelVersion() string {
 	uname := &syscall.Utsname{}
 
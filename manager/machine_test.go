@@ -0,0 +1,86 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+func TestParseOsRelease(t *testing.T) {
+	cases := map[string]string{
+		"./testdata/os-release-coreos":   "CoreOS 681.2.0",
+		"./testdata/os-release-debian":   "Debian GNU/Linux 8 (jessie)",
+		"./testdata/os-release-unquoted": "Handcrafted",
+	}
+	for testfile, expected := range cases {
+		contents, err := ioutil.ReadFile(testfile)
+		if err != nil {
+			t.Fatalf("unable to read input test file %s", testfile)
+		}
+		if prettyName := parseOsRelease(string(contents)); prettyName != expected {
+			t.Errorf("expected %q from %s, found %q", expected, testfile, prettyName)
+		}
+	}
+}
+
+func TestParseOsReleaseWithoutPrettyName(t *testing.T) {
+	if prettyName := parseOsRelease("NAME=busybox\nID=busybox\n"); prettyName != "" {
+		t.Errorf("expected no pretty name, found %q", prettyName)
+	}
+}
+
+func TestOsImageReadFromRootFs(t *testing.T) {
+	rootFs, err := ioutil.TempDir("", "rootfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootFs)
+	if osImage := getOsImage(rootFs); osImage != "Unknown" {
+		t.Errorf("expected an unknown OS image without os-release, found %q", osImage)
+	}
+
+	if err := os.MkdirAll(path.Join(rootFs, "etc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(rootFs, osReleaseFile), []byte("NAME=CoreOS\nPRETTY_NAME=\"CoreOS 681.2.0\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if osImage := getOsImage(rootFs); osImage != "CoreOS 681.2.0" {
+		t.Errorf("expected the OS image of the os-release under the root filesystem, found %q", osImage)
+	}
+}
+
+func TestMachineTagsOmitsUnknownAttributes(t *testing.T) {
+	tags := GetMachineTags(&info.MachineInfo{
+		MachineID:       "abc",
+		KernelVersion:   "3.10.0",
+		OSImage:         "Unknown",
+		CadvisorVersion: "0.16.0",
+	})
+	expected := map[string]string{
+		"machine_id":       "abc",
+		"kernel_version":   "3.10.0",
+		"cadvisor_version": "0.16.0",
+	}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Errorf("expected tags %v, found %v", expected, tags)
+	}
+}
@@ -16,10 +16,10 @@
 package manager
 
 import (
-	"flag"
 	"fmt"
 	"path"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -28,20 +28,22 @@ import (
 	"github.com/golang/glog"
 	"github.com/google/cadvisor/container"
 	"github.com/google/cadvisor/container/docker"
+	"github.com/google/cadvisor/container/groups"
+	"github.com/google/cadvisor/container/libcontainer"
 	"github.com/google/cadvisor/container/raw"
+	"github.com/google/cadvisor/container/services"
 	"github.com/google/cadvisor/events"
 	"github.com/google/cadvisor/fs"
 	info "github.com/google/cadvisor/info/v1"
 	"github.com/google/cadvisor/info/v2"
 	"github.com/google/cadvisor/storage/memory"
+	"github.com/google/cadvisor/utils/clockskew"
 	"github.com/google/cadvisor/utils/cpuload"
 	"github.com/google/cadvisor/utils/oomparser"
 	"github.com/google/cadvisor/utils/sysfs"
+	"github.com/google/cadvisor/utils/sysinfo"
 )
 
-var globalHousekeepingInterval = flag.Duration("global_housekeeping_interval", 1*time.Minute, "Interval between global housekeepings")
-var logCadvisorUsage = flag.Bool("log_cadvisor_usage", false, "Whether to log the usage of the cAdvisor container")
-
 // The Manager interface defines operations for starting a manager and getting
 // container and machine information.
 type Manager interface {
@@ -54,21 +56,66 @@ type Manager interface {
 	// Get information about a container.
 	GetContainerInfo(containerName string, query *info.ContainerInfoRequest) (*info.ContainerInfo, error)
 
+	// Collects the stats of a container right away, rather than waiting for its
+	// next housekeeping, and gets its information with only those stats. Returns
+	// a *RateLimitedError if its stats were already collected that way less
+	// than a second ago.
+	CollectContainerInfo(containerName string, query *info.ContainerInfoRequest) (*info.ContainerInfo, error)
+
+	// Get the ancestors of a container from the root, followed by the container
+	// itself, each with its spec and latest stats. Intermediate cgroups that are
+	// not tracked are listed as such.
+	GetContainerAncestry(containerName string) ([]info.ContainerAncestor, error)
+
 	// Get information about all subcontainers of the specified container (includes self).
 	SubcontainersInfo(containerName string, query *info.ContainerInfoRequest) ([]*info.ContainerInfo, error)
 
+	// Get information about all subcontainers of the specified container
+	// (includes self), sorted by name, with their stats at a single instant:
+	// the cut, which is also returned. Each container has its most recent
+	// stats no newer than the cut, if any. The cut is the time of the oldest
+	// of their latest stats.
+	GetSnapshot(containerName string) ([]*info.ContainerInfo, time.Time, error)
+
+	// Get the sorted names of all subcontainers of the specified container
+	// (includes self) selected by the filter. A nil filter selects all of them.
+	SubcontainerNames(containerName string, filter *ContainerFilter) ([]string, error)
+
 	// Gets all the Docker containers. Return is a map from full container name to ContainerInfo.
 	AllDockerContainers(query *info.ContainerInfoRequest) (map[string]info.ContainerInfo, error)
 
 	// Gets information about a specific Docker container. The specified name is within the Docker namespace.
 	DockerContainer(dockerName string, query *info.ContainerInfoRequest) (info.ContainerInfo, error)
 
+	// Gets all the containers of the namespace. Return is a map from full container name to ContainerInfo.
+	AllNamespacedContainers(namespace string, query *info.ContainerInfoRequest) (map[string]info.ContainerInfo, error)
+
+	// Gets information about a specific container of the namespace. The specified name is within the namespace.
+	NamespacedContainer(namespace string, name string, query *info.ContainerInfoRequest) (info.ContainerInfo, error)
+
+	// Gets the namespaces of the registered container factories, in order of registration.
+	GetNamespaces() ([]v2.Namespace, error)
+
 	// Gets spec for a container.
 	GetContainerSpec(containerName string) (v2.ContainerSpec, error)
 
 	// Get derived stats for a container.
 	GetContainerDerivedStats(containerName string) (v2.DerivedStats, error)
 
+	// Get the status of the stats collection for a container.
+	GetContainerCollectionStatus(containerName string) (v2.CollectionStatus, error)
+
+	// Get the processes of a container and the contents of an allowlist of its
+	// cgroup files, for diagnostics.
+	GetContainerDiagnostics(containerName string) (v2.ContainerDiagnostics, error)
+
+	// Get the recently deleted containers as they were last known, the most
+	// recently deleted first, without their stats.
+	GetDeletedContainers() ([]v2.DeletedContainer, error)
+
+	// Get a recently deleted container as it was last known, with its last stats.
+	GetDeletedContainer(containerName string) (v2.DeletedContainer, error)
+
 	// Get information about the machine.
 	GetMachineInfo() (*info.MachineInfo, error)
 
@@ -79,18 +126,34 @@ type Manager interface {
 	// Returns information for all global filesystems if label is empty.
 	GetFsInfo(label string) ([]v2.FsInfo, error)
 
-	// Get events streamed through passedChannel that fit the request.
-	WatchForEvents(request *events.Request, passedChannel chan *events.Event) error
+	// Get events streamed through the returned channel that fit the request.
+	// CloseEventChannel() must be called once done with it.
+	WatchForEvents(request *events.Request) (*events.EventChannel, error)
+
+	// Stops the watch of events with the given id and closes its channel.
+	CloseEventChannel(watchId int)
 
 	// Get past events that have been detected and that fit the request.
 	GetPastEvents(request *events.Request) (events.EventSlice, error)
+
+	// Get the containers that could not be created, sorted by name.
+	GetFailedContainers() ([]v2.FailedContainer, error)
+
+	// Get the changes to the containers tracked since the specified revision
+	// of the container listing.
+	GetContainerDiff(since uint64) (v2.ContainerDiff, error)
 }
 
-// New takes a memory storage and returns a new manager.
-func New(memoryStorage *memory.InMemoryStorage, sysfs sysfs.SysFs) (Manager, error) {
+// New takes a memory storage and the information of the machine collected at
+// startup (see GetMachineInfo) and returns a new manager with the specified
+// configuration, see DefaultConfig().
+func New(memoryStorage *memory.InMemoryStorage, sysfs sysfs.SysFs, machineInfo *info.MachineInfo, config Config) (Manager, error) {
 	if memoryStorage == nil {
 		return nil, fmt.Errorf("manager requires memory storage")
 	}
+	if machineInfo == nil {
+		return nil, fmt.Errorf("manager requires the information of the machine")
+	}
 
 	// Detect the container we are running on.
 	selfContainer, err := cgroups.GetThisCgroupDir("cpu")
@@ -104,21 +167,34 @@ func New(memoryStorage *memory.InMemoryStorage, sysfs sysfs.SysFs) (Manager, err
 	if err != nil {
 		return nil, err
 	}
+	startupTime := time.Now()
 	newManager := &manager{
-		containers:        make(map[namespacedContainerName]*containerData),
-		quitChannels:      make([]chan error, 0, 2),
-		memoryStorage:     memoryStorage,
-		fsInfo:            fsInfo,
-		cadvisorContainer: selfContainer,
-		startupTime:       time.Now(),
+		config:               config,
+		containers:           make(map[namespacedContainerName]*containerData),
+		quitChannels:         make([]chan error, 0, 2),
+		memoryStorage:        memoryStorage,
+		fsInfo:               fsInfo,
+		cadvisorContainer:    selfContainer,
+		startupTime:          startupTime,
+		creationFailures:     make(map[string]*creationFailure),
+		missingContainers:    make(map[string]*missingContainer),
+		housekeepingRestarts: make(map[string]*housekeepingRestarts),
+		tombstones:           newTombstones(config.DeletedContainersCount, config.DeletedContainersAge),
+		logs:                 newLogAggregator(),
+		containerRevisions: containerRevisions{
+			revision: firstContainerRevision(startupTime),
+			dropped:  firstContainerRevision(startupTime),
+			window:   config.ContainerDiffWindow,
+		},
 	}
 
-	machineInfo, err := getMachineInfo(sysfs, fsInfo)
-	if err != nil {
-		return nil, err
-	}
 	newManager.machineInfo = *machineInfo
+	newManager.sysFs = sysfs
 	glog.Infof("Machine: %+v", newManager.machineInfo)
+	newManager.onlineCpus, err = sysinfo.GetOnlineCpus(sysfs)
+	if err != nil {
+		glog.Warningf("Failed to read the online CPUs, CPU hotplug will not be detected: %v", err)
+	}
 
 	versionInfo, err := getVersionInfo()
 	if err != nil {
@@ -128,15 +204,44 @@ func New(memoryStorage *memory.InMemoryStorage, sysfs sysfs.SysFs) (Manager, err
 	glog.Infof("Version: %+v", newManager.versionInfo)
 
 	newManager.eventHandler = events.NewEventManager()
+	if eventStorage, ok := memoryStorage.EventStorage(); ok {
+		newManager.eventHandler = newStoredEventManager(newManager.eventHandler, eventStorage)
+	}
+
+	// Register the system services factory, its containers are not cgroups.
+	if config.MonitorSystemServices {
+		err = services.Register()
+		if err != nil {
+			glog.Errorf("Registration of the system services factory failed: %v", err)
+		} else {
+			newManager.detachedRoots = append(newManager.detachedRoots, services.Root)
+		}
+	}
+
+	// Register the groups factory, its containers are sums of others.
+	if config.GroupByLabel != "" {
+		err = groups.Register(config.GroupByLabel, groupSource{newManager})
+		if err != nil {
+			glog.Errorf("Registration of the groups factory failed: %v", err)
+		} else {
+			newManager.detachedRoots = append(newManager.detachedRoots, groups.Root)
+		}
+	}
+
+	// The cgroup factories share where the cgroup hierarchies are mounted.
+	newManager.cgroupMounts, err = libcontainer.NewCgroupMounts(libcontainer.GetCgroupSubsystems)
+	if err != nil {
+		glog.Errorf("Failed to get cgroup subsystems: %v", err)
+	}
 
 	// Register Docker container factory.
-	err = docker.Register(newManager, fsInfo)
+	err = docker.Register(newManager, fsInfo, newManager.cgroupMounts)
 	if err != nil {
 		glog.Errorf("Docker container factory registration failed: %v.", err)
 	}
 
 	// Register the raw driver.
-	err = raw.Register(newManager, fsInfo)
+	err = raw.Register(newManager, fsInfo, newManager.cgroupMounts)
 	if err != nil {
 		glog.Errorf("Registration of the raw container factory failed: %v", err)
 	}
@@ -154,11 +259,14 @@ type namespacedContainerName struct {
 }
 
 type manager struct {
+	config                 Config
 	containers             map[namespacedContainerName]*containerData
 	containersLock         sync.RWMutex
 	memoryStorage          *memory.InMemoryStorage
 	fsInfo                 fs.FsInfo
 	machineInfo            info.MachineInfo
+	machineInfoLock        sync.RWMutex
+	sysFs                  sysfs.SysFs
 	versionInfo            info.VersionInfo
 	quitChannels           []chan error
 	cadvisorContainer      string
@@ -166,6 +274,50 @@ type manager struct {
 	loadReader             cpuload.CpuLoadReader
 	eventHandler           events.EventManager
 	startupTime            time.Time
+
+	// Containers whose creation failed by name, protected by creationFailuresLock.
+	creationFailures     map[string]*creationFailure
+	creationFailuresLock sync.Mutex
+
+	// Docker containers that disappeared by Docker ID, protected by missingContainersLock.
+	missingContainers     map[string]*missingContainer
+	missingContainersLock sync.Mutex
+
+	// Restarts of stuck housekeepings by container name, protected by housekeepingRestartsLock.
+	housekeepingRestarts     map[string]*housekeepingRestarts
+	housekeepingRestartsLock sync.Mutex
+
+	// Timers for writing the stats of the containers that may still be
+	// short-lived as their own, by name, protected by youngContainersLock.
+	youngContainers     map[string]*time.Timer
+	youngContainersLock sync.Mutex
+
+	// Roots of the containers outside of the cgroup hierarchy (e.g.: the
+	// system services). They are not subcontainers of "/" but are detected with them.
+	detachedRoots []string
+
+	// Counts of the containers created and deleted, reported as MetaContainerName.
+	containerCounts containerCounter
+
+	// Latencies of showing new containers, reported with the container counts.
+	containerLatencies latencyRecorder
+
+	// Revisions of the container listing, for differential listings.
+	containerRevisions containerRevisions
+
+	// Recently deleted containers. Nil if they are not kept.
+	tombstones *tombstones
+
+	// Summarizes the lifecycle of the containers in the logs. May be nil.
+	logs *logAggregator
+
+	// Where the cgroup hierarchies are mounted, shared with the cgroup
+	// factories. Nil if they could not be found.
+	cgroupMounts *libcontainer.CgroupMounts
+
+	// Sorted ids of the online CPUs as of the last global housekeeping. Only
+	// used by the global housekeeping.
+	onlineCpus []int
 }
 
 // Start the container manager.
@@ -209,6 +361,10 @@ func (self *manager) Start() error {
 		return err
 	}
 	glog.Infof("Recovery completed")
+	err = self.writeContainerCounts(time.Now())
+	if err != nil {
+		glog.Errorf("Failed to write the container counts: %v", err)
+	}
 
 	// Watch for new container.
 	quitWatcher := make(chan error)
@@ -223,6 +379,20 @@ func (self *manager) Start() error {
 	self.quitChannels = append(self.quitChannels, quitGlobalHousekeeping)
 	go self.globalHousekeeping(quitGlobalHousekeeping)
 
+	// Re-resolve the cgroup paths of the containers when the hierarchies are remounted.
+	if self.cgroupMounts != nil && self.config.CgroupRemountCheckInterval > 0 {
+		quitRemountWatcher := make(chan error)
+		self.quitChannels = append(self.quitChannels, quitRemountWatcher)
+		go self.watchForCgroupRemounts(quitRemountWatcher)
+	}
+
+	// Restart the housekeeping of containers when it gets stuck.
+	if self.config.HousekeepingWatchdogIntervals > 0 {
+		quitWatchdog := make(chan error)
+		self.quitChannels = append(self.quitChannels, quitWatchdog)
+		go self.housekeepingWatchdog(quitWatchdog)
+	}
+
 	return nil
 }
 
@@ -239,6 +409,9 @@ func (self *manager) Stop() error {
 		}
 	}
 	self.quitChannels = make([]chan error, 0, 2)
+	self.stopCreationRetries()
+	self.stopMissingContainerExpiries()
+	self.flushShortLivedStats()
 	if self.loadReader != nil {
 		self.loadReader.Stop()
 		self.loadReader = nil
@@ -249,11 +422,11 @@ func (self *manager) Stop() error {
 func (self *manager) globalHousekeeping(quit chan error) {
 	// Long housekeeping is either 100ms or half of the housekeeping interval.
 	longHousekeeping := 100 * time.Millisecond
-	if *globalHousekeepingInterval/2 < longHousekeeping {
-		longHousekeeping = *globalHousekeepingInterval / 2
+	if self.config.GlobalHousekeepingInterval/2 < longHousekeeping {
+		longHousekeeping = self.config.GlobalHousekeepingInterval / 2
 	}
 
-	ticker := time.Tick(*globalHousekeepingInterval)
+	ticker := time.Tick(self.config.GlobalHousekeepingInterval)
 	for {
 		select {
 		case t := <-ticker:
@@ -264,6 +437,15 @@ func (self *manager) globalHousekeeping(quit chan error) {
 			if err != nil {
 				glog.Errorf("Failed to detect containers: %s", err)
 			}
+			err = self.writeContainerCounts(t)
+			if err != nil {
+				glog.Errorf("Failed to write the container counts: %v", err)
+			}
+			err = self.checkCpuHotplug()
+			if err != nil {
+				glog.Errorf("Failed to check for CPU hotplug: %v", err)
+			}
+			self.logs.flush()
 
 			// Log if housekeeping took too long.
 			duration := time.Since(start)
@@ -313,24 +495,7 @@ func (self *manager) GetContainerSpec(containerName string) (v2.ContainerSpec, e
 // Get V2 container spec from v1 container info.
 func (self *manager) getV2Spec(cinfo *containerInfo) v2.ContainerSpec {
 	specV1 := self.getAdjustedSpec(cinfo)
-	specV2 := v2.ContainerSpec{
-		CreationTime: specV1.CreationTime,
-		HasCpu:       specV1.HasCpu,
-		HasMemory:    specV1.HasMemory,
-	}
-	if specV1.HasCpu {
-		specV2.Cpu.Limit = specV1.Cpu.Limit
-		specV2.Cpu.MaxLimit = specV1.Cpu.MaxLimit
-		specV2.Cpu.Mask = specV1.Cpu.Mask
-	}
-	if specV1.HasMemory {
-		specV2.Memory.Limit = specV1.Memory.Limit
-		specV2.Memory.Reservation = specV1.Memory.Reservation
-		specV2.Memory.SwapLimit = specV1.Memory.SwapLimit
-	}
-	specV2.Aliases = cinfo.Aliases
-	specV2.Namespace = cinfo.Namespace
-	return specV2
+	return v2.ContainerSpecFromV1(&specV1, cinfo.ContainerReference)
 }
 
 func (self *manager) getAdjustedSpec(cinfo *containerInfo) info.ContainerSpec {
@@ -340,7 +505,9 @@ func (self *manager) getAdjustedSpec(cinfo *containerInfo) info.ContainerSpec {
 	if spec.HasMemory {
 		// Memory.Limit is 0 means there's no limit
 		if spec.Memory.Limit == 0 {
+			self.machineInfoLock.RLock()
 			spec.Memory.Limit = uint64(self.machineInfo.MemoryCapacity)
+			self.machineInfoLock.RUnlock()
 		}
 	}
 	return spec
@@ -348,13 +515,33 @@ func (self *manager) getAdjustedSpec(cinfo *containerInfo) info.ContainerSpec {
 
 // Get a container by name.
 func (self *manager) GetContainerInfo(containerName string, query *info.ContainerInfoRequest) (*info.ContainerInfo, error) {
+	if containerName == MetaContainerName {
+		return self.getMetaContainerInfo(query)
+	}
 	cont, err := self.getContainerData(containerName)
 	if err != nil {
+		// A current container with the name shadows a deleted one.
+		if query.IncludeDeleted {
+			if cinfo, ok := self.getDeletedContainerInfo(containerName, query); ok {
+				return cinfo, nil
+			}
+		}
 		return nil, err
 	}
 	return self.containerDataToContainerInfo(cont, query)
 }
 
+func (self *manager) GetContainerAncestry(containerName string) ([]info.ContainerAncestor, error) {
+	return GetAncestry(containerName, func(name string) (*info.ContainerInfo, error) {
+		// Untracked ancestors are only listed, no container is created for them.
+		cont, err := self.getContainerData(name)
+		if err != nil {
+			return nil, nil
+		}
+		return self.containerDataToContainerInfo(cont, &info.ContainerInfoRequest{NumStats: 1})
+	})
+}
+
 func (self *manager) containerDataToContainerInfo(cont *containerData, query *info.ContainerInfoRequest) (*info.ContainerInfo, error) {
 	// Get the info from the container.
 	cinfo, err := cont.GetInfo()
@@ -362,21 +549,38 @@ func (self *manager) containerDataToContainerInfo(cont *containerData, query *in
 		return nil, err
 	}
 
-	stats, err := self.memoryStorage.RecentStats(cinfo.Name, query.Start, query.End, query.NumStats)
-	if err != nil {
-		return nil, err
-	}
-
 	// Make a copy of the info for the user.
 	ret := &info.ContainerInfo{
 		ContainerReference: cinfo.ContainerReference,
 		Subcontainers:      cinfo.Subcontainers,
 		Spec:               self.getAdjustedSpec(cinfo),
-		Stats:              stats,
+	}
+	if query.Resolution > 0 {
+		ret.Buckets, ret.Resolution, err = self.getStatsBuckets(cont, query)
+	} else {
+		ret.Stats, err = self.memoryStorage.RecentStats(cinfo.Name, query.Start, query.End, query.NumStats)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ret.Freshness = cont.freshness()
+	if query.IncludeMachine {
+		self.addMachineCapacity(ret)
 	}
 	return ret, nil
 }
 
+// Adds the capacity of the machine, and the share of it the container may use,
+// to the container's info.
+func (self *manager) addMachineCapacity(cinfo *info.ContainerInfo) {
+	self.machineInfoLock.RLock()
+	machine := self.machineInfo.Capacity()
+	self.machineInfoLock.RUnlock()
+	effective := cinfo.Spec.EffectiveCapacity(machine)
+	cinfo.MachineCapacity = &machine
+	cinfo.EffectiveCapacity = &effective
+}
+
 func (self *manager) SubcontainersInfo(containerName string, query *info.ContainerInfoRequest) ([]*info.ContainerInfo, error) {
 	var containersMap map[string]*containerData
 	func() {
@@ -401,20 +605,114 @@ func (self *manager) SubcontainersInfo(containerName string, query *info.Contain
 	return self.containerDataSliceToContainerInfoSlice(containers, query)
 }
 
-func (self *manager) AllDockerContainers(query *info.ContainerInfoRequest) (map[string]info.ContainerInfo, error) {
-	var containers map[string]*containerData
+func (self *manager) GetSnapshot(containerName string) ([]*info.ContainerInfo, time.Time, error) {
+	var containers []*containerData
+	func() {
+		self.containersLock.RLock()
+		defer self.containersLock.RUnlock()
+		matchedName := path.Join(containerName, "/")
+		for key, cont := range self.containers {
+			// Aliases are in other namespaces.
+			if key.Namespace != "" {
+				continue
+			}
+			if key.Name == containerName || strings.HasPrefix(key.Name, matchedName) {
+				containers = append(containers, cont)
+			}
+		}
+	}()
+	if len(containers) == 0 {
+		return nil, time.Time{}, fmt.Errorf("unknown container %q", containerName)
+	}
+
+	infos := make([]*info.ContainerInfo, 0, len(containers))
+	names := make([]string, 0, len(containers))
+	for _, cont := range containers {
+		cinfo, err := cont.GetInfo()
+		if err != nil {
+			// Skip containers with errors, we try to degrade gracefully.
+			continue
+		}
+		infos = append(infos, &info.ContainerInfo{
+			ContainerReference: cinfo.ContainerReference,
+			Subcontainers:      cinfo.Subcontainers,
+			Spec:               self.getAdjustedSpec(cinfo),
+		})
+		names = append(names, cinfo.Name)
+	}
+	cut, stats := self.memoryStorage.Snapshot(names)
+	for _, cinfo := range infos {
+		if s, ok := stats[cinfo.Name]; ok {
+			cinfo.Stats = []*info.ContainerStats{s}
+		}
+	}
+	sort.Sort(containerInfosByName(infos))
+	return infos, cut, nil
+}
+
+type containerInfosByName []*info.ContainerInfo
+
+func (self containerInfosByName) Len() int           { return len(self) }
+func (self containerInfosByName) Swap(i, j int)      { self[i], self[j] = self[j], self[i] }
+func (self containerInfosByName) Less(i, j int) bool { return self[i].Name < self[j].Name }
+
+func (self *manager) SubcontainerNames(containerName string, filter *ContainerFilter) ([]string, error) {
+	var names []string
+	found := false
 	func() {
 		self.containersLock.RLock()
 		defer self.containersLock.RUnlock()
-		containers = make(map[string]*containerData, len(self.containers))
 
-		// Get containers in the Docker namespace.
-		for name, cont := range self.containers {
-			if name.Namespace == docker.DockerNamespace {
-				containers[cont.info.Name] = cont
+		prefix := containerName + "/"
+		if containerName == "/" {
+			prefix = "/"
+		}
+		for key, cont := range self.containers {
+			// Aliases are in other namespaces.
+			if key.Namespace != "" {
+				continue
+			}
+			if key.Name != containerName && !strings.HasPrefix(key.Name, prefix) {
+				continue
+			}
+			found = true
+			if cont.matches(filter) {
+				names = append(names, key.Name)
 			}
 		}
 	}()
+	if !found {
+		return nil, fmt.Errorf("unknown container %q", containerName)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (self *manager) AllDockerContainers(query *info.ContainerInfoRequest) (map[string]info.ContainerInfo, error) {
+	return self.AllNamespacedContainers(docker.DockerNamespace, query)
+}
+
+func (self *manager) DockerContainer(containerName string, query *info.ContainerInfoRequest) (info.ContainerInfo, error) {
+	return self.NamespacedContainer(docker.DockerNamespace, containerName, query)
+}
+
+// Returns the containers of the namespace by full name.
+func (self *manager) namespacedContainers(namespace string) map[string]*containerData {
+	self.containersLock.RLock()
+	defer self.containersLock.RUnlock()
+	containers := make(map[string]*containerData, len(self.containers))
+
+	// The containers are in the namespace under each of their aliases.
+	for name, cont := range self.containers {
+		if name.Namespace == namespace {
+			containers[cont.info.Name] = cont
+		}
+	}
+	return containers
+}
+
+func (self *manager) AllNamespacedContainers(namespace string, query *info.ContainerInfoRequest) (map[string]info.ContainerInfo, error) {
+	containers := self.namespacedContainers(namespace)
 
 	output := make(map[string]info.ContainerInfo, len(containers))
 	for name, cont := range containers {
@@ -427,15 +725,15 @@ func (self *manager) AllDockerContainers(query *info.ContainerInfoRequest) (map[
 	return output, nil
 }
 
-func (self *manager) DockerContainer(containerName string, query *info.ContainerInfoRequest) (info.ContainerInfo, error) {
+func (self *manager) NamespacedContainer(namespace string, containerName string, query *info.ContainerInfoRequest) (info.ContainerInfo, error) {
 	var container *containerData = nil
 	func() {
 		self.containersLock.RLock()
 		defer self.containersLock.RUnlock()
 
-		// Check for the container in the Docker container namespace.
+		// Check for the container in the namespace.
 		cont, ok := self.containers[namespacedContainerName{
-			Namespace: docker.DockerNamespace,
+			Namespace: namespace,
 			Name:      containerName,
 		}]
 		if ok {
@@ -443,7 +741,10 @@ func (self *manager) DockerContainer(containerName string, query *info.Container
 		}
 	}()
 	if container == nil {
-		return info.ContainerInfo{}, fmt.Errorf("unable to find Docker container %q", containerName)
+		if namespace == docker.DockerNamespace {
+			return info.ContainerInfo{}, fmt.Errorf("unable to find Docker container %q", containerName)
+		}
+		return info.ContainerInfo{}, fmt.Errorf("unable to find container %q in namespace %q", containerName, namespace)
 	}
 
 	inf, err := self.containerDataToContainerInfo(container, query)
@@ -453,6 +754,20 @@ func (self *manager) DockerContainer(containerName string, query *info.Container
 	return *inf, nil
 }
 
+func (self *manager) GetNamespaces() ([]v2.Namespace, error) {
+	registered := container.Namespaces()
+	namespaces := make([]v2.Namespace, 0, len(registered))
+	for _, ns := range registered {
+		namespaces = append(namespaces, v2.Namespace{
+			Name:          ns.Name,
+			MountPoint:    ns.MountPoint,
+			Factory:       ns.Factory,
+			NumContainers: len(self.namespacedContainers(ns.Name)),
+		})
+	}
+	return namespaces, nil
+}
+
 func (self *manager) containerDataSliceToContainerInfoSlice(containers []*containerData, query *info.ContainerInfoRequest) ([]*info.ContainerInfo, error) {
 	if len(containers) == 0 {
 		return nil, fmt.Errorf("no containers found")
@@ -483,7 +798,20 @@ func (self *manager) GetContainerDerivedStats(containerName string) (v2.DerivedS
 	if !ok {
 		return v2.DerivedStats{}, fmt.Errorf("unknown container %q", containerName)
 	}
-	return cont.DerivedStats()
+	derived, err := cont.DerivedStats()
+	if err != nil {
+		return derived, err
+	}
+	derived.FairShareRatio = self.fairShareRatio(containerName, derived)
+	return derived, nil
+}
+
+func (self *manager) GetContainerCollectionStatus(containerName string) (v2.CollectionStatus, error) {
+	cont, err := self.getContainerData(containerName)
+	if err != nil {
+		return v2.CollectionStatus{}, err
+	}
+	return cont.CollectionStatus(), nil
 }
 
 func (self *manager) GetFsInfo(label string) ([]v2.FsInfo, error) {
@@ -527,7 +855,11 @@ func (self *manager) GetFsInfo(label string) ([]v2.FsInfo, error) {
 
 func (m *manager) GetMachineInfo() (*info.MachineInfo, error) {
 	// Copy and return the MachineInfo.
-	return &m.machineInfo, nil
+	m.machineInfoLock.RLock()
+	defer m.machineInfoLock.RUnlock()
+	machineInfo := m.machineInfo
+	machineInfo.ClockOffsets = clockskew.Offsets()
+	return &machineInfo, nil
 }
 
 func (m *manager) GetVersionInfo() (*info.VersionInfo, error) {
@@ -540,49 +872,35 @@ func (m *manager) createContainer(containerName string) error {
 	if err != nil {
 		return err
 	}
-	logUsage := *logCadvisorUsage && containerName == m.cadvisorContainer
-	cont, err := newContainerData(containerName, m.memoryStorage, handler, m.loadReader, logUsage)
+	reattached, err := m.reattachMissingContainer(handler)
+	if reattached || err != nil {
+		return err
+	}
+	logUsage := m.config.LogCadvisorUsage && containerName == m.cadvisorContainer
+	cont, err := newContainerData(containerName, m.memoryStorage, handler, &m.config, m.loadReader, m.eventHandler, logUsage)
 	if err != nil {
 		return err
 	}
+	cont.specChanged = m.containerSpecChanged
+	cont.logs = m.logs
 
 	// Add to the containers map.
-	alreadyExists := func() bool {
-		m.containersLock.Lock()
-		defer m.containersLock.Unlock()
-
-		namespacedName := namespacedContainerName{
-			Name: containerName,
-		}
-
-		// Check that the container didn't already exist.
-		_, ok := m.containers[namespacedName]
-		if ok {
-			return true
-		}
-
-		// Add the container name and all its aliases. The aliases must be within the namespace of the factory.
-		m.containers[namespacedName] = cont
-		for _, alias := range cont.info.Aliases {
-			m.containers[namespacedContainerName{
-				Namespace: cont.info.Namespace,
-				Name:      alias,
-			}] = cont
-		}
-
-		return false
-	}()
-	if alreadyExists {
+	if !m.addContainer(cont) {
 		return nil
 	}
-	glog.Infof("Added container: %q (aliases: %v, namespace: %q)", containerName, cont.info.Aliases, cont.info.Namespace)
+	m.containerCounts.containerCreated()
+	m.containerRevisions.record(containerAdded, cont.reference())
+	m.logs.containerAdded(containerName, cont.info.Aliases, cont.info.Namespace)
 
 	contSpecs, err := cont.handler.GetSpec()
 	if err != nil {
 		return err
 	}
+	m.deferShortLivedStats(containerName, contSpecs.CreationTime)
 
 	if contSpecs.CreationTime.After(m.startupTime) {
+		m.containerLatencies.recordDiscovery(cont.measureLatencies(contSpecs.CreationTime, m.containerLatencies.recordFirstStats))
+
 		contRef, err := cont.handler.ContainerReference()
 		if err != nil {
 			return err
@@ -607,33 +925,41 @@ func (m *manager) createContainer(containerName string) error {
 }
 
 func (m *manager) destroyContainer(containerName string) error {
-	m.containersLock.Lock()
-	defer m.containersLock.Unlock()
+	m.forgetCreationFailure(containerName)
 
-	namespacedName := namespacedContainerName{
-		Name: containerName,
+	// Docker containers may only be missing while the Docker daemon restarts.
+	missing, err := m.markContainerMissing(containerName)
+	if missing || err != nil {
+		return err
 	}
-	cont, ok := m.containers[namespacedName]
+
+	// Remove the container from our records so no new requests see it.
+	cont, ok := m.removeContainer(containerName)
 	if !ok {
 		// Already destroyed, done.
 		return nil
 	}
 
-	// Tell the container to stop.
-	err := cont.Stop()
+	// Tell the container to stop. If it cannot be, it is tracked again so that
+	// its deletion is retried once it is next detected as removed.
+	err = cont.Stop()
 	if err != nil {
+		m.addContainer(cont)
 		return err
 	}
+	return m.containerDestroyed(cont)
+}
 
-	// Remove the container from our records (and all its aliases).
-	delete(m.containers, namespacedName)
-	for _, alias := range cont.info.Aliases {
-		delete(m.containers, namespacedContainerName{
-			Namespace: cont.info.Namespace,
-			Name:      alias,
-		})
-	}
-	glog.Infof("Destroyed container: %q (aliases: %v, namespace: %q)", containerName, cont.info.Aliases, cont.info.Namespace)
+// Records the deletion of the container, which must already be stopped and removed.
+func (m *manager) containerDestroyed(cont *containerData) error {
+	cleanupHandler(cont.handler)
+	m.logs.containerRemoved(cont.info.Name, cont.info.Aliases, cont.info.Namespace)
+	deletionTime := time.Now()
+	m.recordTombstone(cont, deletionTime)
+	m.containerCounts.containerDeleted()
+	m.forgetHousekeepingRestarts(cont.info.Name)
+	m.containerRevisions.record(containerRemoved, cont.reference())
+	m.rollUpShortLivedStats(cont.info.Name)
 
 	contRef, err := cont.handler.ContainerReference()
 	if err != nil {
@@ -642,7 +968,7 @@ func (m *manager) destroyContainer(containerName string) error {
 
 	newEvent := &events.Event{
 		ContainerName: contRef.Name,
-		Timestamp:     time.Now(),
+		Timestamp:     deletionTime,
 		EventType:     events.TypeContainerDeletion,
 	}
 	err = m.eventHandler.AddEvent(newEvent)
@@ -652,23 +978,83 @@ func (m *manager) destroyContainer(containerName string) error {
 	return nil
 }
 
-// Detect all containers that have been added or deleted from the specified container.
-func (m *manager) getContainersDiff(containerName string) (added []info.ContainerReference, removed []info.ContainerReference, err error) {
-	m.containersLock.RLock()
-	defer m.containersLock.RUnlock()
+// Adds the container and all its aliases to the containers map. The aliases
+// must be within the namespace of the factory. Returns false if the container
+// was already known.
+func (m *manager) addContainer(cont *containerData) bool {
+	m.containersLock.Lock()
+	defer m.containersLock.Unlock()
+
+	namespacedName := namespacedContainerName{
+		Name: cont.info.Name,
+	}
 
-	// Get all subcontainers recursively.
-	cont, ok := m.containers[namespacedContainerName{
+	// Check that the container didn't already exist.
+	_, ok := m.containers[namespacedName]
+	if ok {
+		return false
+	}
+
+	m.containers[namespacedName] = cont
+	for _, alias := range cont.info.Aliases {
+		m.containers[namespacedContainerName{
+			Namespace: cont.info.Namespace,
+			Name:      alias,
+		}] = cont
+	}
+	return true
+}
+
+// Removes the container and all its aliases from the containers map. Returns
+// the removed container and whether it was known.
+func (m *manager) removeContainer(containerName string) (*containerData, bool) {
+	m.containersLock.Lock()
+	defer m.containersLock.Unlock()
+
+	namespacedName := namespacedContainerName{
 		Name: containerName,
-	}]
+	}
+	cont, ok := m.containers[namespacedName]
 	if !ok {
+		return nil, false
+	}
+
+	delete(m.containers, namespacedName)
+	for _, alias := range cont.info.Aliases {
+		// Only remove aliases that still point to this container.
+		aliasName := namespacedContainerName{
+			Namespace: cont.info.Namespace,
+			Name:      alias,
+		}
+		if m.containers[aliasName] == cont {
+			delete(m.containers, aliasName)
+		}
+	}
+	return cont, true
+}
+
+// Detect all containers that have been added or deleted from the specified container.
+func (m *manager) getContainersDiff(containerName string) (added []info.ContainerReference, removed []info.ContainerReference, err error) {
+	cont, err := m.getContainerData(containerName)
+	if err != nil {
 		return nil, nil, fmt.Errorf("failed to find container %q while checking for new containers", containerName)
 	}
+
+	// Get all subcontainers recursively. This may be slow so it is done without holding the lock.
 	allContainers, err := cont.handler.ListContainers(container.ListRecursive)
 	if err != nil {
+		cont.recordTimeout(err)
 		return nil, nil, err
 	}
 	allContainers = append(allContainers, info.ContainerReference{Name: containerName})
+	if containerName == "/" {
+		allContainers = append(allContainers, m.listDetachedContainers()...)
+	}
+	m.forgetVanishedCreationFailures(containerName, allContainers)
+	missing := m.missingContainerNames()
+
+	m.containersLock.RLock()
+	defer m.containersLock.RUnlock()
 
 	// Determine which were added and which were removed.
 	allContainersSet := make(map[string]*containerData)
@@ -685,19 +1071,43 @@ func (m *manager) getContainersDiff(containerName string) (added []info.Containe
 		_, ok := m.containers[namespacedContainerName{
 			Name: c.Name,
 		}]
-		if !ok {
+		// Missing containers that are listed again are to be reattached.
+		if !ok || missing[c.Name] {
 			added = append(added, c)
 		}
 	}
 
 	// Removed ones are no longer in the container listing.
 	for _, d := range allContainersSet {
+		if missing[d.info.Name] {
+			continue
+		}
 		removed = append(removed, d.info.ContainerReference)
 	}
 
 	return
 }
 
+// Lists the detached roots and their subcontainers. Roots which do not exist yet
+// are listed alone, their subcontainers are detected once they are created.
+func (m *manager) listDetachedContainers() []info.ContainerReference {
+	var ret []info.ContainerReference
+	for _, root := range m.detachedRoots {
+		ret = append(ret, info.ContainerReference{Name: root})
+		cont, err := m.getContainerData(root)
+		if err != nil {
+			continue
+		}
+		subcontainers, err := cont.handler.ListContainers(container.ListRecursive)
+		if err != nil {
+			glog.Errorf("Failed to list the subcontainers of %q: %v", root, err)
+			continue
+		}
+		ret = append(ret, subcontainers...)
+	}
+	return ret
+}
+
 // Detect the existing subcontainers and reflect the setup here.
 func (m *manager) detectSubcontainers(containerName string) error {
 	added, removed, err := m.getContainersDiff(containerName)
@@ -707,7 +1117,7 @@ func (m *manager) detectSubcontainers(containerName string) error {
 
 	// Add the new containers.
 	for _, cont := range added {
-		err = m.createContainer(cont.Name)
+		err = m.createContainerWithRetry(cont.Name)
 		if err != nil {
 			glog.Errorf("Failed to create existing container: %s: %s", cont.Name, err)
 		}
@@ -759,7 +1169,7 @@ func (self *manager) watchForNewContainers(quit chan error) error {
 			case event := <-eventsChannel:
 				switch {
 				case event.EventType == container.SubcontainerAdd:
-					err = self.createContainer(event.Name)
+					err = self.createContainerWithRetry(event.Name)
 				case event.EventType == container.SubcontainerDelete:
 					err = self.destroyContainer(event.Name)
 				}
@@ -810,8 +1220,12 @@ func (self *manager) watchForNewOoms() error {
 }
 
 // can be called by the api which will take events returned on the channel
-func (self *manager) WatchForEvents(request *events.Request, passedChannel chan *events.Event) error {
-	return self.eventHandler.WatchEvents(passedChannel, request)
+func (self *manager) WatchForEvents(request *events.Request) (*events.EventChannel, error) {
+	return self.eventHandler.WatchEvents(request)
+}
+
+func (self *manager) CloseEventChannel(watchId int) {
+	self.eventHandler.StopWatch(watchId)
 }
 
 // can be called by the api which will return all events satisfying the request
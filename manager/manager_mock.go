@@ -15,6 +15,8 @@
 package manager
 
 import (
+	"time"
+
 	"github.com/google/cadvisor/events"
 	info "github.com/google/cadvisor/info/v1"
 	"github.com/google/cadvisor/info/v2"
@@ -40,11 +42,31 @@ func (c *ManagerMock) GetContainerInfo(name string, query *info.ContainerInfoReq
 	return args.Get(0).(*info.ContainerInfo), args.Error(1)
 }
 
+func (c *ManagerMock) GetContainerAncestry(name string) ([]info.ContainerAncestor, error) {
+	args := c.Called(name)
+	return args.Get(0).([]info.ContainerAncestor), args.Error(1)
+}
+
+func (c *ManagerMock) CollectContainerInfo(name string, query *info.ContainerInfoRequest) (*info.ContainerInfo, error) {
+	args := c.Called(name, query)
+	return args.Get(0).(*info.ContainerInfo), args.Error(1)
+}
+
 func (c *ManagerMock) SubcontainersInfo(containerName string, query *info.ContainerInfoRequest) ([]*info.ContainerInfo, error) {
 	args := c.Called(containerName, query)
 	return args.Get(0).([]*info.ContainerInfo), args.Error(1)
 }
 
+func (c *ManagerMock) SubcontainerNames(containerName string, filter *ContainerFilter) ([]string, error) {
+	args := c.Called(containerName, filter)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (c *ManagerMock) GetSnapshot(containerName string) ([]*info.ContainerInfo, time.Time, error) {
+	args := c.Called(containerName)
+	return args.Get(0).([]*info.ContainerInfo), args.Get(1).(time.Time), args.Error(2)
+}
+
 func (c *ManagerMock) AllDockerContainers(query *info.ContainerInfoRequest) (map[string]info.ContainerInfo, error) {
 	args := c.Called(query)
 	return args.Get(0).(map[string]info.ContainerInfo), args.Error(1)
@@ -55,9 +77,24 @@ func (c *ManagerMock) DockerContainer(name string, query *info.ContainerInfoRequ
 	return args.Get(0).(info.ContainerInfo), args.Error(1)
 }
 
-func (c *ManagerMock) GetContainerSpec(containerName string) (info.ContainerSpec, error) {
+func (c *ManagerMock) AllNamespacedContainers(namespace string, query *info.ContainerInfoRequest) (map[string]info.ContainerInfo, error) {
+	args := c.Called(namespace, query)
+	return args.Get(0).(map[string]info.ContainerInfo), args.Error(1)
+}
+
+func (c *ManagerMock) NamespacedContainer(namespace string, name string, query *info.ContainerInfoRequest) (info.ContainerInfo, error) {
+	args := c.Called(namespace, name, query)
+	return args.Get(0).(info.ContainerInfo), args.Error(1)
+}
+
+func (c *ManagerMock) GetNamespaces() ([]v2.Namespace, error) {
+	args := c.Called()
+	return args.Get(0).([]v2.Namespace), args.Error(1)
+}
+
+func (c *ManagerMock) GetContainerSpec(containerName string) (v2.ContainerSpec, error) {
 	args := c.Called(containerName)
-	return args.Get(0).(info.ContainerSpec), args.Error(1)
+	return args.Get(0).(v2.ContainerSpec), args.Error(1)
 }
 
 func (c *ManagerMock) GetContainerDerivedStats(containerName string) (v2.DerivedStats, error) {
@@ -65,9 +102,33 @@ func (c *ManagerMock) GetContainerDerivedStats(containerName string) (v2.Derived
 	return args.Get(0).(v2.DerivedStats), args.Error(1)
 }
 
-func (c *ManagerMock) WatchForEvents(queryuest *events.Request, passedChannel chan *events.Event) error {
-	args := c.Called(queryuest, passedChannel)
-	return args.Error(0)
+func (c *ManagerMock) GetContainerCollectionStatus(containerName string) (v2.CollectionStatus, error) {
+	args := c.Called(containerName)
+	return args.Get(0).(v2.CollectionStatus), args.Error(1)
+}
+
+func (c *ManagerMock) GetContainerDiagnostics(containerName string) (v2.ContainerDiagnostics, error) {
+	args := c.Called(containerName)
+	return args.Get(0).(v2.ContainerDiagnostics), args.Error(1)
+}
+
+func (c *ManagerMock) GetDeletedContainers() ([]v2.DeletedContainer, error) {
+	args := c.Called()
+	return args.Get(0).([]v2.DeletedContainer), args.Error(1)
+}
+
+func (c *ManagerMock) GetDeletedContainer(containerName string) (v2.DeletedContainer, error) {
+	args := c.Called(containerName)
+	return args.Get(0).(v2.DeletedContainer), args.Error(1)
+}
+
+func (c *ManagerMock) WatchForEvents(queryuest *events.Request) (*events.EventChannel, error) {
+	args := c.Called(queryuest)
+	return args.Get(0).(*events.EventChannel), args.Error(1)
+}
+
+func (c *ManagerMock) CloseEventChannel(watchId int) {
+	c.Called(watchId)
 }
 
 func (c *ManagerMock) GetPastEvents(queryuest *events.Request) (events.EventSlice, error) {
@@ -85,7 +146,17 @@ func (c *ManagerMock) GetVersionInfo() (*info.VersionInfo, error) {
 	return args.Get(0).(*info.VersionInfo), args.Error(1)
 }
 
-func (c *ManagerMock) GetFsInfo() ([]v2.FsInfo, error) {
-	args := c.Called()
+func (c *ManagerMock) GetFsInfo(label string) ([]v2.FsInfo, error) {
+	args := c.Called(label)
 	return args.Get(0).([]v2.FsInfo), args.Error(1)
 }
+
+func (c *ManagerMock) GetFailedContainers() ([]v2.FailedContainer, error) {
+	args := c.Called()
+	return args.Get(0).([]v2.FailedContainer), args.Error(1)
+}
+
+func (c *ManagerMock) GetContainerDiff(since uint64) (v2.ContainerDiff, error) {
+	args := c.Called(since)
+	return args.Get(0).(v2.ContainerDiff), args.Error(1)
+}
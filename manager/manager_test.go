@@ -17,8 +17,10 @@
 package manager
 
 import (
+	"fmt"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -26,8 +28,11 @@ import (
 	"github.com/google/cadvisor/container/docker"
 	info "github.com/google/cadvisor/info/v1"
 	itest "github.com/google/cadvisor/info/v1/test"
+	"github.com/google/cadvisor/info/v2"
 	"github.com/google/cadvisor/storage/memory"
 	"github.com/google/cadvisor/utils/sysfs/fakesysfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TODO(vmarmol): Refactor these tests.
@@ -37,14 +42,10 @@ func createManagerAndAddContainers(
 	sysfs *fakesysfs.FakeSysFs,
 	containers []string,
 	f func(*container.MockContainerHandler),
-	t *testing.T,
+	t testing.TB,
 ) *manager {
-	container.ClearContainerHandlerFactories()
-	mif := &manager{
-		containers:    make(map[namespacedContainerName]*containerData),
-		quitChannels:  make([]chan error, 0, 2),
-		memoryStorage: memoryStorage,
-	}
+	mif := newTestManager(newTestConfig(), nil)
+	mif.memoryStorage = memoryStorage
 	for _, name := range containers {
 		mockHandler := container.NewMockContainerHandler(name)
 		spec := itest.GenerateRandomContainerSpec(4)
@@ -52,7 +53,7 @@ func createManagerAndAddContainers(
 			spec,
 			nil,
 		).Once()
-		cont, err := newContainerData(name, memoryStorage, mockHandler, nil, false)
+		cont, err := newContainerData(name, memoryStorage, mockHandler, &mif.config, nil, nil, false)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -73,7 +74,7 @@ func createManagerAndAddContainers(
 
 // Expect a manager with the specified containers and query. Returns the manager, map of ContainerInfo objects,
 // and map of MockContainerHandler objects.}
-func expectManagerWithContainers(containers []string, query *info.ContainerInfoRequest, t *testing.T) (*manager, map[string]*info.ContainerInfo, map[string]*container.MockContainerHandler) {
+func expectManagerWithContainers(containers []string, query *info.ContainerInfoRequest, t testing.TB) (*manager, map[string]*info.ContainerInfo, map[string]*container.MockContainerHandler) {
 	infosMap := make(map[string]*info.ContainerInfo, len(containers))
 	handlerMap := make(map[string]*container.MockContainerHandler, len(containers))
 
@@ -143,6 +144,11 @@ func TestGetContainerInfo(t *testing.T) {
 		handler.AssertExpectations(t)
 		returned := returnedInfos[container]
 		expected := infosMap[container]
+		// The freshness is computed when the info is returned.
+		if returned.Freshness == nil {
+			t.Errorf("returned no freshness for container %v", container)
+		}
+		returned.Freshness = nil
 		if !reflect.DeepEqual(returned, expected) {
 			t.Errorf("returned unexpected info for container %v; returned %+v; expected %+v", container, returned, expected)
 		}
@@ -203,9 +209,428 @@ func TestDockerContainersInfo(t *testing.T) {
 	}
 }
 
+func TestGetNamespaces(t *testing.T) {
+	containers := []string{
+		"/docker/c1",
+		"/docker/c2",
+		"/lxc/a",
+	}
+	query := &info.ContainerInfoRequest{
+		NumStats: 2,
+	}
+	m, _, _ := expectManagerWithContainers(containers, query, t)
+	// Containers are counted once, whatever their number of aliases.
+	m.containers[namespacedContainerName{Namespace: docker.DockerNamespace, Name: "first"}] = m.containers[namespacedContainerName{Name: "/docker/c1"}]
+	m.containers[namespacedContainerName{Namespace: "lxc", Name: "a"}] = m.containers[namespacedContainerName{Name: "/lxc/a"}]
+
+	defer container.ClearContainerHandlerFactories()
+	container.RegisterNamespacedContainerHandlerFactory(&container.FactoryForMockContainerHandler{Name: "docker"}, container.Namespace{Name: docker.DockerNamespace, MountPoint: "/docker"})
+	container.RegisterNamespacedContainerHandlerFactory(&container.FactoryForMockContainerHandler{Name: "lxc"}, container.Namespace{Name: "lxc", MountPoint: "/lxc"})
+	container.RegisterNamespacedContainerHandlerFactory(&container.FactoryForMockContainerHandler{Name: "empty"}, container.Namespace{Name: "empty", MountPoint: "/empty"})
+
+	namespaces, err := m.GetNamespaces()
+	require.Nil(t, err)
+	assert.Equal(t, []v2.Namespace{
+		{Name: docker.DockerNamespace, MountPoint: "/docker", Factory: "docker", NumContainers: 2},
+		{Name: "lxc", MountPoint: "/lxc", Factory: "lxc", NumContainers: 1},
+		{Name: "empty", MountPoint: "/empty", Factory: "empty", NumContainers: 0},
+	}, namespaces)
+
+	all, err := m.AllNamespacedContainers("lxc", query)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(all))
+	cont, err := m.NamespacedContainer("lxc", "a", query)
+	require.Nil(t, err)
+	assert.Equal(t, "/lxc/a", cont.Name)
+	_, err = m.NamespacedContainer("empty", "a", query)
+	assert.NotNil(t, err)
+}
+
+func TestGetContainerInfoWithMachineCapacity(t *testing.T) {
+	containers := []string{
+		"/c1",
+	}
+
+	query := &info.ContainerInfoRequest{
+		NumStats: 2,
+	}
+
+	m, infosMap, _ := expectManagerWithContainers(containers, query, t)
+	m.machineInfo = info.MachineInfo{
+		NumCores:       8,
+		MemoryCapacity: 1 << 30,
+		NetworkDevices: []info.NetInfo{{Name: "eth0", Speed: 1000}},
+	}
+
+	// Not included by default.
+	cinfo, err := m.GetContainerInfo("/c1", query)
+	if err != nil {
+		t.Fatalf("Unable to get info for container /c1: %v", err)
+	}
+	if cinfo.MachineCapacity != nil || cinfo.EffectiveCapacity != nil {
+		t.Errorf("Expected no capacity without IncludeMachine, got %+v and %+v", cinfo.MachineCapacity, cinfo.EffectiveCapacity)
+	}
+
+	// The container is restricted to 4 cores and its memory limit.
+	query.IncludeMachine = true
+	cinfo, err = m.GetContainerInfo("/c1", query)
+	if err != nil {
+		t.Fatalf("Unable to get info for container /c1: %v", err)
+	}
+	expectedMachine := info.MachineCapacity{NumCores: 8, MemoryBytes: 1 << 30, NetworkSpeed: 1000}
+	if cinfo.MachineCapacity == nil || *cinfo.MachineCapacity != expectedMachine {
+		t.Errorf("Expected machine capacity %+v, got %+v", expectedMachine, cinfo.MachineCapacity)
+	}
+	expectedEffective := info.MachineCapacity{NumCores: 4, MemoryBytes: int64(infosMap["/c1"].Spec.Memory.Limit), NetworkSpeed: 1000}
+	if cinfo.EffectiveCapacity == nil || *cinfo.EffectiveCapacity != expectedEffective {
+		t.Errorf("Expected effective capacity %+v, got %+v", expectedEffective, cinfo.EffectiveCapacity)
+	}
+}
+
 func TestNewNilManager(t *testing.T) {
-	_, err := New(nil, nil)
+	_, err := New(nil, nil, nil, DefaultConfig())
 	if err == nil {
 		t.Fatalf("Expected nil manager to return error")
 	}
 }
+
+// Creates a container that is not in the manager, for adding and removing it.
+func newChurnContainer(name string, aliases []string, t testing.TB) *containerData {
+	mockHandler := container.NewMockContainerHandler(name)
+	mockHandler.Aliases = aliases
+	mockHandler.On("GetSpec").Return(
+		itest.GenerateRandomContainerSpec(4),
+		nil,
+	)
+	mockHandler.On("ListContainers", container.ListSelf).Return(
+		[]info.ContainerReference(nil),
+		nil,
+	)
+	cont, err := newContainerData(name, memory.New(1, nil), mockHandler, newTestConfig(), nil, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cont
+}
+
+func TestAddRemoveContainersWhileListing(t *testing.T) {
+	containers := []string{
+		"/c1",
+		"/c2",
+		"/docker/c3",
+	}
+	query := &info.ContainerInfoRequest{
+		NumStats: 2,
+	}
+	m, _, _ := expectManagerWithContainers(containers, query, t)
+	numEntries := len(m.containers)
+
+	churn := make([]*containerData, 0, 10)
+	for i := 0; i < cap(churn); i++ {
+		churn = append(churn, newChurnContainer(fmt.Sprintf("/churn/c%d", i), []string{fmt.Sprintf("churn%d", i)}, t))
+	}
+
+	// Add and remove containers while readers list and look them up.
+	stop := make(chan struct{})
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			for _, cont := range churn {
+				m.addContainer(cont)
+			}
+			for _, cont := range churn {
+				m.removeContainer(cont.info.Name)
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				infos, err := m.SubcontainersInfo("/", query)
+				if err != nil {
+					t.Errorf("failed to list subcontainers: %v", err)
+					return
+				}
+				if len(infos) < len(containers) {
+					t.Errorf("expected at least %d containers, got %d", len(containers), len(infos))
+				}
+				if _, err := m.DockerContainer("c3", query); err != nil {
+					t.Errorf("failed to get Docker container: %v", err)
+				}
+				if _, err := m.GetContainerInfo("/c1", query); err != nil {
+					t.Errorf("failed to get container: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-writerDone
+
+	// All churned containers and their aliases should be gone.
+	if len(m.containers) != numEntries {
+		t.Errorf("expected %d entries in the containers map, found %d: %v", numEntries, len(m.containers), m.containers)
+	}
+}
+
+func TestRemoveContainerKeepsOtherAliases(t *testing.T) {
+	m, _, _ := expectManagerWithContainers([]string{"/c1"}, &info.ContainerInfoRequest{NumStats: 1}, t)
+	first := newChurnContainer("/first", []string{"shared"}, t)
+	second := newChurnContainer("/second", []string{"shared"}, t)
+
+	if !m.addContainer(first) {
+		t.Fatalf("expected container %q to be added", first.info.Name)
+	}
+	if m.addContainer(first) {
+		t.Errorf("expected container %q to only be added once", first.info.Name)
+	}
+	m.addContainer(second)
+
+	// The alias now belongs to the second container and must survive removing the first.
+	m.removeContainer(first.info.Name)
+	if m.containers[namespacedContainerName{Name: "shared"}] != second {
+		t.Errorf("expected alias to still point to %q", second.info.Name)
+	}
+	if _, ok := m.removeContainer(first.info.Name); ok {
+		t.Errorf("expected container %q to already be removed", first.info.Name)
+	}
+}
+
+// Returns a factory of handlers listing the specified subcontainers of each container.
+func newListingTestFactory(subcontainers map[string][]info.ContainerReference) *container.FactoryForMockContainerHandler {
+	return &container.FactoryForMockContainerHandler{
+		Name: "listing-test",
+		PrepareContainerHandlerFunc: func(name string, handler *container.MockContainerHandler) {
+			handler.On("GetSpec").Return(info.ContainerSpec{}, nil)
+			handler.On("GetStats").Return(itest.GenerateRandomStats(1, 4, time.Second)[0], nil)
+			handler.On("ListContainers", container.ListRecursive).Return(subcontainers[name], nil)
+		},
+	}
+}
+
+func TestDetachedRootsAreDetected(t *testing.T) {
+	defer container.ClearContainerHandlerFactories()
+	m := newTestManager(newTestConfig(), newListingTestFactory(map[string][]info.ContainerReference{
+		"/":         {{Name: "/a"}},
+		"/services": {{Name: "/services/docker"}},
+	}))
+	m.detachedRoots = []string{"/services"}
+	defer func() {
+		for name, cont := range m.containers {
+			if name.Name == cont.info.Name {
+				cont.StopAndWait(time.Second)
+			}
+		}
+	}()
+	if err := m.createContainer("/"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The root is detected with the cgroup containers, its subcontainers once it exists.
+	if err := m.detectSubcontainers("/"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"/a", "/services"} {
+		if _, err := m.getContainerData(name); err != nil {
+			t.Errorf("expected %q to be detected: %v", name, err)
+		}
+	}
+	if err := m.detectSubcontainers("/"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.getContainerData("/services/docker"); err != nil {
+		t.Errorf("expected the subcontainers of the detached root to be detected: %v", err)
+	}
+
+	// Containers outside of the cgroup hierarchy are not removed.
+	added, removed, err := m.getContainersDiff("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 0 || len(removed) != 0 {
+		t.Errorf("expected no changes, got added %v and removed %v", added, removed)
+	}
+}
+
+// Containers listed by the root, which change between listings.
+type churnTestContainers struct {
+	lock       sync.Mutex
+	containers []info.ContainerReference
+}
+
+func (self *churnTestContainers) setContainers(names ...string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.containers = nil
+	for _, name := range names {
+		self.containers = append(self.containers, info.ContainerReference{Name: name})
+	}
+}
+
+// Handler listing the current containers if it is the root.
+type churnTestHandler struct {
+	*container.MockContainerHandler
+	containers *churnTestContainers
+}
+
+func (self *churnTestHandler) ListContainers(listType container.ListType) ([]info.ContainerReference, error) {
+	self.containers.lock.Lock()
+	defer self.containers.lock.Unlock()
+	if self.Name != "/" {
+		return nil, nil
+	}
+	return append([]info.ContainerReference(nil), self.containers.containers...), nil
+}
+
+func TestContainerCounts(t *testing.T) {
+	defer container.ClearContainerHandlerFactories()
+	listed := &churnTestContainers{}
+	m := newTestManager(newTestConfig(), &container.FactoryForMockContainerHandler{
+		Name: "churn-test",
+		WrapContainerHandlerFunc: func(name string, handler *container.MockContainerHandler) (container.ContainerHandler, error) {
+			handler.On("GetSpec").Return(info.ContainerSpec{}, nil)
+			handler.On("GetStats").Return(itest.GenerateRandomStats(1, 4, time.Second)[0], nil)
+			return &churnTestHandler{handler, listed}, nil
+		},
+	})
+	start := m.startupTime
+	// Includes the containers deleted along the way, whose housekeeping is
+	// stopped without waiting for it.
+	started := make(map[*containerData]bool)
+	defer func() {
+		for cont := range started {
+			cont.StopAndWait(time.Second)
+		}
+	}()
+	if err := m.createContainer("/"); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		containers []string
+		expected   info.ContainerCountStats
+	}{
+		{
+			containers: []string{"/a", "/b"},
+			expected:   info.ContainerCountStats{Tracked: 3, Created: 3, ChurnPerMinute: 3},
+		}, {
+			containers: []string{"/a"},
+			expected:   info.ContainerCountStats{Tracked: 2, Created: 3, Deleted: 1, ChurnPerMinute: 1},
+		}, {
+			containers: []string{"/a", "/c", "/d"},
+			expected:   info.ContainerCountStats{Tracked: 4, Created: 5, Deleted: 1, ChurnPerMinute: 2},
+		}, {
+			containers: []string{},
+			expected:   info.ContainerCountStats{Tracked: 1, Created: 5, Deleted: 4, ChurnPerMinute: 3},
+		},
+	}
+	for i, step := range steps {
+		listed.setContainers(step.containers...)
+		if err := m.detectSubcontainers("/"); err != nil {
+			t.Fatal(err)
+		}
+		for _, cont := range m.containers {
+			started[cont] = true
+		}
+		// A minute between samples.
+		if err := m.writeContainerCounts(start.Add(time.Duration(i+1) * time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+		cinfo, err := m.GetContainerInfo(MetaContainerName, &info.ContainerInfoRequest{NumStats: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cinfo.Stats) != 1 || cinfo.Stats[0].ContainerCounts == nil {
+			t.Fatalf("expected the container counts, got %+v", cinfo.Stats)
+		}
+		if counts := *cinfo.Stats[0].ContainerCounts; counts != step.expected {
+			t.Errorf("after listing %v expected counts %+v, got %+v", step.containers, step.expected, counts)
+		}
+	}
+
+	// The pseudo-container is not listed.
+	containers, err := m.SubcontainersInfo("/", &info.ContainerInfoRequest{NumStats: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cont := range containers {
+		if cont.Name == MetaContainerName {
+			t.Errorf("expected %q not to be a subcontainer of /", MetaContainerName)
+		}
+	}
+}
+
+func TestContainerCounterChurnRate(t *testing.T) {
+	start := time.Unix(1000, 0)
+	counter := &containerCounter{}
+	for i := 0; i < 6; i++ {
+		counter.containerCreated()
+	}
+	if rate := counter.sample(start.Add(2*time.Minute), 6, start).ChurnPerMinute; rate != 3 {
+		t.Errorf("expected a churn of 3 per minute since the start, got %v", rate)
+	}
+	counter.containerDeleted()
+	if rate := counter.sample(start.Add(2*time.Minute+30*time.Second), 5, start).ChurnPerMinute; rate != 2 {
+		t.Errorf("expected a churn of 2 per minute since the previous sample, got %v", rate)
+	}
+	if rate := counter.sample(start.Add(2*time.Minute+30*time.Second), 5, start).ChurnPerMinute; rate != 0 {
+		t.Errorf("expected no churn without time elapsed, got %v", rate)
+	}
+}
+
+// Measures lookups from 64 concurrent readers while containers are periodically added and removed.
+func BenchmarkGetContainerInfoWithConcurrentWrites(b *testing.B) {
+	const numReaders = 64
+	containers := make([]string, 0, 100)
+	for i := 0; i < cap(containers); i++ {
+		containers = append(containers, fmt.Sprintf("/c%d", i))
+	}
+	query := &info.ContainerInfoRequest{
+		NumStats: 1,
+	}
+	m, _, _ := expectManagerWithContainers(containers, query, b)
+	churn := newChurnContainer("/churn", []string{"churn"}, b)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.addContainer(churn)
+				m.removeContainer(churn.info.Name)
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	b.ResetTimer()
+	var wg sync.WaitGroup
+	for i := 0; i < numReaders; i++ {
+		wg.Add(1)
+		go func(reader int) {
+			defer wg.Done()
+			for j := reader; j < b.N; j += numReaders {
+				_, err := m.GetContainerInfo(containers[j%len(containers)], query)
+				if err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,106 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/google/cadvisor/summary"
+)
+
+// Detects a container whose thrashing score stays above a threshold.
+type thrashingDetector struct {
+	threshold float64
+	duration  time.Duration
+
+	// Latest stat point with memory stats, nil if none.
+	previous *info.ContainerStats
+
+	// How long the score has been above the threshold, zero if it is not.
+	above time.Duration
+
+	// Whether the pressure was already reported since the score went above
+	// the threshold. It is reported once until the score goes back below.
+	reported bool
+}
+
+func newThrashingDetector(threshold float64, duration time.Duration) *thrashingDetector {
+	return &thrashingDetector{
+		threshold: threshold,
+		duration:  duration,
+	}
+}
+
+// Feeds the next stat point of the container, whose memory limit is the one
+// specified. Returns the pressure to report when the score has stayed above
+// the threshold for long enough, nil otherwise.
+func (self *thrashingDetector) update(stats *info.ContainerStats, limit uint64) *v2.MemoryPressure {
+	if stats.Missing(info.StatsSectionMemory) {
+		return nil
+	}
+	previous := self.previous
+	self.previous = stats
+	if previous == nil {
+		return nil
+	}
+	score, ok := summary.ThrashingScore(stats, previous)
+	if !ok {
+		return nil
+	}
+	if score <= self.threshold {
+		self.above = 0
+		self.reported = false
+		return nil
+	}
+	self.above += stats.Elapsed(previous)
+	if self.reported || self.above < self.duration {
+		return nil
+	}
+	self.reported = true
+	return &v2.MemoryPressure{
+		Usage:          stats.Memory.Usage,
+		Limit:          limit,
+		ThrashingScore: score,
+		Duration:       self.above,
+	}
+}
+
+// Emits a memory pressure event if the memory of the container has been
+// thrashing for long enough.
+func (c *containerData) detectMemoryPressure(stats *info.ContainerStats) {
+	if c.thrashing == nil || !c.info.Spec.HasMemory {
+		return
+	}
+	pressure := c.thrashing.update(stats, c.info.Spec.Memory.Limit)
+	if pressure == nil {
+		return
+	}
+	glog.Warningf("[%s] Memory is thrashing: thrashing score of %.2f for %v (usage %d, limit %d)", c.info.Name, pressure.ThrashingScore, pressure.Duration, pressure.Usage, pressure.Limit)
+	if c.eventHandler != nil {
+		err := c.eventHandler.AddEvent(&events.Event{
+			ContainerName: c.info.Name,
+			Timestamp:     stats.Timestamp,
+			EventType:     events.TypeMemoryPressure,
+			EventData:     *pressure,
+		})
+		if err != nil {
+			glog.Errorf("[%s] Failed to add memory pressure event: %v", c.info.Name, err)
+		}
+	}
+}
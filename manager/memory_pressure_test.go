@@ -0,0 +1,145 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/google/cadvisor/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Usage of the containers of the tests, in pages and bytes.
+const thrashingUsagePages = 1000
+
+var thrashingUsage = uint64(thrashingUsagePages * os.Getpagesize())
+
+// Generates a stat point every second scanning the specified fractions of the
+// usage of the container for reclaim during the preceding second.
+func thrashingSequence(start time.Time, scores ...float64) []*info.ContainerStats {
+	var ret []*info.ContainerStats
+	scanned := uint64(0)
+	for i, score := range scores {
+		scanned += uint64(score * thrashingUsagePages)
+		stats := &info.ContainerStats{
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+		}
+		stats.Memory.Usage = thrashingUsage
+		stats.Memory.HierarchicalData.PgscanKswapd = scanned
+		ret = append(ret, stats)
+	}
+	return ret
+}
+
+// Feeds the sequence to the detector and returns the indexes of the stat
+// points at which pressure was reported.
+func feedThrashingDetector(t *testing.T, detector *thrashingDetector, sequence []*info.ContainerStats) []int {
+	var reported []int
+	for i, stats := range sequence {
+		pressure := detector.update(stats, 2*thrashingUsage)
+		if pressure == nil {
+			continue
+		}
+		reported = append(reported, i)
+		assert.Equal(t, thrashingUsage, pressure.Usage)
+		assert.Equal(t, 2*thrashingUsage, pressure.Limit)
+		assert.True(t, pressure.ThrashingScore > 1, "Reported a thrashing score of %v", pressure.ThrashingScore)
+		assert.True(t, pressure.Duration >= 3*time.Second, "Reported a duration of %v", pressure.Duration)
+	}
+	return reported
+}
+
+func TestThrashingDetectorReportsSustainedPressure(t *testing.T) {
+	detector := newThrashingDetector(1, 3*time.Second)
+	// The first point has no score, then three seconds of thrashing are
+	// reported once until the score goes back below the threshold.
+	sequence := thrashingSequence(time.Unix(1000, 0), 0, 0.5, 2, 2, 2, 2, 2, 0.1, 2, 2, 2)
+	assert.Equal(t, []int{4, 10}, feedThrashingDetector(t, detector, sequence))
+}
+
+func TestThrashingDetectorIgnoresSpikes(t *testing.T) {
+	detector := newThrashingDetector(1, 3*time.Second)
+	sequence := thrashingSequence(time.Unix(1000, 0), 0, 5, 5, 0, 5, 5, 0.5, 5, 5, 1)
+	assert.Empty(t, feedThrashingDetector(t, detector, sequence))
+}
+
+func TestThrashingDetectorSkipsMissingMemory(t *testing.T) {
+	detector := newThrashingDetector(1, 3*time.Second)
+	sequence := thrashingSequence(time.Unix(1000, 0), 0, 2, 2, 2, 2)
+	// A point without memory stats does not break the pressure, the score is
+	// computed across it.
+	sequence[2].MarkMissing(info.StatsSectionMemory)
+	assert.Equal(t, []int{3}, feedThrashingDetector(t, detector, sequence))
+}
+
+func TestThrashingDetectorCounterReset(t *testing.T) {
+	detector := newThrashingDetector(1, 10*time.Second)
+	sequence := thrashingSequence(time.Unix(1000, 0), 0, 2, 2)
+	// The container was recreated, its counters started over. The score is
+	// computed from the first point after the reset.
+	restarted := thrashingSequence(time.Unix(1003, 0), 0, 0.5)
+	sequence = append(sequence, restarted...)
+	for _, stats := range sequence {
+		assert.Nil(t, detector.update(stats, 0))
+	}
+	assert.Equal(t, time.Duration(0), detector.above)
+}
+
+func TestMemoryPressureEmitsEvent(t *testing.T) {
+	config := newTestConfig()
+	config.MemoryPressureThreshold = 1
+	config.MemoryPressureDuration = 2 * time.Second
+
+	eventHandler := events.NewEventManager()
+	mockHandler := container.NewMockContainerHandler(containerName)
+	spec := info.ContainerSpec{HasMemory: true}
+	spec.Memory.Limit = thrashingUsage
+	mockHandler.On("GetSpec").Return(spec, nil)
+	cd, err := newContainerData(containerName, memory.New(60, nil), mockHandler, config, nil, eventHandler, false)
+	require.Nil(t, err)
+
+	for _, stats := range thrashingSequence(time.Unix(1000, 0), 0, 3, 3, 3) {
+		cd.detectMemoryPressure(stats)
+	}
+
+	request := events.NewRequest()
+	request.EventType[events.TypeMemoryPressure] = true
+	evs, err := eventHandler.GetEvents(request)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(evs))
+	assert.Equal(t, containerName, evs[0].ContainerName)
+	assert.Equal(t, time.Unix(1002, 0), evs[0].Timestamp)
+	pressure, ok := evs[0].EventData.(v2.MemoryPressure)
+	require.True(t, ok)
+	assert.Equal(t, thrashingUsage, pressure.Usage)
+	assert.Equal(t, thrashingUsage, pressure.Limit)
+	assert.InDelta(t, 3, pressure.ThrashingScore, 0.01)
+	assert.Equal(t, 2*time.Second, pressure.Duration)
+}
+
+func TestMemoryPressureDisabled(t *testing.T) {
+	config := newTestConfig()
+	config.MemoryPressureThreshold = 0
+
+	cd, _, _ := setupContainerDataWithConfig(t, info.ContainerSpec{}, config)
+	assert.Nil(t, cd.thrashing)
+}
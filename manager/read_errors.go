@@ -0,0 +1,116 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/container"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Sections whose reads were denied to some container, so that the denial is
+// only warned about once per section: it is a misconfiguration which usually
+// affects all containers.
+var permissionWarnings = struct {
+	sync.Mutex
+	warned map[string]bool
+}{warned: make(map[string]bool)}
+
+func warnPermissionDenied(section, containerName string, err error) {
+	permissionWarnings.Lock()
+	defer permissionWarnings.Unlock()
+	if permissionWarnings.warned[section] {
+		glog.V(2).Infof("Permission denied reading the %s stats of %q, no longer collecting them: %v", section, containerName, err)
+		return
+	}
+	permissionWarnings.warned[section] = true
+	glog.Errorf("PERMISSION DENIED reading the %s stats of %q: %v. cAdvisor needs read access to the cgroup hierarchies (e.g.: to run as root). The %s stats of the containers whose reads are denied are no longer collected until cAdvisor restarts", section, containerName, err, section)
+}
+
+// Counts the errors of the failed sections by class and stops expecting the
+// sections whose reads were denied from the container: they are removed from
+// its spec. Must be called with the lock held.
+func (c *containerData) recordReadErrorsLocked(failures map[string]error) {
+	sections := make([]string, 0, len(failures))
+	for section := range failures {
+		sections = append(sections, section)
+	}
+	sort.Strings(sections)
+	for _, section := range sections {
+		err := failures[section]
+		class := c.countReadErrorLocked(err)
+		if class != container.ErrorClassPermission || c.deniedSections[section] {
+			continue
+		}
+		if c.deniedSections == nil {
+			c.deniedSections = make(map[string]bool)
+		}
+		c.deniedSections[section] = true
+		degradeSpec(&c.info.Spec, c.deniedSections)
+		warnPermissionDenied(section, c.info.Name, err)
+	}
+}
+
+// Counts the error by class and returns the class. Must be called with the lock held.
+func (c *containerData) countReadErrorLocked(err error) string {
+	class := container.ClassifyError(err)
+	if c.readErrors == nil {
+		c.readErrors = make(map[string]uint64)
+	}
+	c.readErrors[class]++
+	return class
+}
+
+// Returns whether none of the failures is worth failing the housekeeping
+// for: the files of an exiting container being gone, or reads of sections
+// already known to be denied.
+func (c *containerData) benignFailures(failures map[string]error) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for section, err := range failures {
+		if c.deniedSections[section] {
+			continue
+		}
+		if container.ClassifyError(err) != container.ErrorClassGone {
+			return false
+		}
+	}
+	return true
+}
+
+// Removes the sections whose reads are denied from the spec.
+func degradeSpec(spec *info.ContainerSpec, denied map[string]bool) {
+	for section := range denied {
+		switch section {
+		case info.StatsSectionCpu:
+			spec.HasCpu = false
+		case info.StatsSectionMemory:
+			spec.HasMemory = false
+		case info.StatsSectionDiskIo:
+			spec.HasDiskIo = false
+		case info.StatsSectionNetwork:
+			spec.HasNetwork = false
+		case info.StatsSectionFilesystem:
+			spec.HasFilesystem = false
+		case info.StatsSectionPressure:
+			spec.HasPressure = false
+		case info.StatsSectionPids:
+			spec.HasPids = false
+		}
+	}
+}
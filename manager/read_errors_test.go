@@ -0,0 +1,138 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/container"
+	info "github.com/google/cadvisor/info/v1"
+	itest "github.com/google/cadvisor/info/v1/test"
+	"github.com/google/cadvisor/utils/timing"
+	"github.com/stretchr/testify/assert"
+)
+
+// Returns the error reading the specified cgroup file with the specified errno.
+func readError(file string, errno syscall.Errno) error {
+	return container.NewReadError(&os.PathError{Op: "open", Path: file, Err: errno})
+}
+
+func TestUpdateStatsClassifiesReadErrors(t *testing.T) {
+	testCases := []struct {
+		name       string
+		failures   map[string]error
+		failed     bool
+		readErrors map[string]uint64
+		denied     []string
+	}{
+		{
+			name:       "file gone with the container",
+			failures:   map[string]error{info.StatsSectionMemory: readError("memory.stat", syscall.ENOENT)},
+			failed:     false,
+			readErrors: map[string]uint64{container.ErrorClassGone: 1},
+		},
+		{
+			name:       "permission denied",
+			failures:   map[string]error{info.StatsSectionMemory: readError("memory.stat", syscall.EACCES)},
+			failed:     false,
+			readErrors: map[string]uint64{container.ErrorClassPermission: 1},
+			denied:     []string{info.StatsSectionMemory},
+		},
+		{
+			name:       "I/O error",
+			failures:   map[string]error{info.StatsSectionMemory: readError("memory.stat", syscall.EIO)},
+			failed:     true,
+			readErrors: map[string]uint64{container.ErrorClassIo: 1},
+		},
+		{
+			name: "gone and I/O error",
+			failures: map[string]error{
+				info.StatsSectionMemory: readError("memory.stat", syscall.ENOENT),
+				info.StatsSectionDiskIo: readError("blkio.io_serviced_recursive", syscall.EIO),
+			},
+			failed:     true,
+			readErrors: map[string]uint64{container.ErrorClassGone: 1, container.ErrorClassIo: 1},
+		},
+		{
+			name:       "unclassified",
+			failures:   map[string]error{info.StatsSectionMemory: fmt.Errorf("unable to parse memory.stat")},
+			failed:     true,
+			readErrors: map[string]uint64{container.ErrorClassOther: 1},
+		},
+	}
+	for _, tc := range testCases {
+		cd, mockHandler, memoryStorage := setupContainerData(t, info.ContainerSpec{HasCpu: true, HasMemory: true, HasDiskIo: true})
+		partial := container.NewPartialStatsError()
+		for section, err := range tc.failures {
+			partial.Add(section, err)
+		}
+		mockHandler.On("GetStats").Return(itest.GenerateRandomStats(1, 4, time.Second)[0], partial)
+		mockHandler.On("Exists").Return(true)
+
+		err := cd.updateStats(timing.NewSections())
+		assert.Equal(t, tc.failed, err != nil, "%s: %v", tc.name, err)
+		checkNumStats(t, memoryStorage, 1)
+
+		status := cd.CollectionStatus()
+		assert.Equal(t, tc.readErrors, status.ReadErrors, tc.name)
+		assert.Equal(t, tc.denied, status.DeniedSections, tc.name)
+	}
+}
+
+func TestPermissionDeniedDegradesSpec(t *testing.T) {
+	cd, mockHandler, _ := setupContainerData(t, info.ContainerSpec{HasCpu: true, HasMemory: true})
+	mockHandler.On("Exists").Return(true)
+	for i := 0; i < 2; i++ {
+		partial := container.NewPartialStatsError()
+		partial.Add(info.StatsSectionMemory, readError("memory.stat", syscall.EACCES))
+		mockHandler.On("GetStats").Return(itest.GenerateRandomStats(1, 4, time.Second)[0], partial).Once()
+		// Denied reads are not failing the housekeeping, they will not go away.
+		assert.Nil(t, cd.updateStats(timing.NewSections()))
+	}
+
+	spec := func() info.ContainerSpec {
+		cd.lock.Lock()
+		defer cd.lock.Unlock()
+		return cd.info.Spec
+	}
+	assert.False(t, spec().HasMemory, "the memory stats should no longer be expected")
+	assert.True(t, spec().HasCpu)
+	assert.Empty(t, cd.CollectionStatus().CollectionErrors)
+
+	// The handler still reports the memory cgroup.
+	assert.Nil(t, cd.updateSpec())
+	assert.False(t, spec().HasMemory, "the updated spec should not expect the memory stats")
+
+	permissionWarnings.Lock()
+	defer permissionWarnings.Unlock()
+	assert.True(t, permissionWarnings.warned["memory"])
+}
+
+func TestUpdateStatsOfContainerGoneAway(t *testing.T) {
+	cd, mockHandler, memoryStorage := newTestContainerData(t)
+	// The cgroup was removed while the container still exists.
+	mockHandler.On("GetStats").Return((*info.ContainerStats)(nil), readError("cpuacct.usage", syscall.ENOENT))
+	mockHandler.On("Exists").Return(true)
+
+	assert.Nil(t, cd.updateStats(timing.NewSections()))
+	var empty time.Time
+	_, err := memoryStorage.RecentStats(containerName, empty, empty, -1)
+	assert.NotNil(t, err, "no stats should be stored")
+	assert.Equal(t, map[string]uint64{container.ErrorClassGone: 1}, cd.CollectionStatus().ReadErrors)
+}
@@ -0,0 +1,46 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/summary"
+)
+
+// Returns the stats of the container requested aggregated at the requested
+// resolution, along with the resolution used. Resolutions of a minute or more
+// are served from the minute aggregates of the container's summary, which go
+// further back than the stats kept in memory. Finer ones are aggregated from
+// the stats kept in memory.
+func (self *manager) getStatsBuckets(cont *containerData, query *info.ContainerInfoRequest) ([]info.StatsBucket, time.Duration, error) {
+	var buckets []info.StatsBucket
+	resolution := query.Resolution
+	if resolution >= time.Minute && cont.summaryReader != nil {
+		buckets, resolution = cont.summaryReader.Buckets(query.Start, query.End, resolution)
+	} else {
+		stats, err := self.memoryStorage.RecentStats(cont.info.Name, query.Start, query.End, -1)
+		if err != nil {
+			return nil, 0, err
+		}
+		buckets = summary.Buckets(stats, resolution)
+	}
+	// The most recent buckets are kept, like the most recent stats are.
+	if query.NumStats >= 0 && len(buckets) > query.NumStats {
+		buckets = buckets[len(buckets)-query.NumStats:]
+	}
+	return buckets, resolution, nil
+}
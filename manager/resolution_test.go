@@ -0,0 +1,64 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sumSamples(buckets []info.StatsBucket) uint64 {
+	var samples uint64
+	for _, b := range buckets {
+		samples += b.Samples
+	}
+	return samples
+}
+
+func TestGetContainerInfoWithResolution(t *testing.T) {
+	query := &info.ContainerInfoRequest{NumStats: 300}
+	m, infosMap, _ := expectManagerWithContainers([]string{"/c1"}, query, t)
+	stats := infosMap["/c1"].Stats
+
+	// Aggregated from the stats kept in memory.
+	cinfo, err := m.GetContainerInfo("/c1", &info.ContainerInfoRequest{NumStats: 100, Resolution: 10 * time.Second})
+	require.Nil(t, err)
+	assert.Nil(t, cinfo.Stats)
+	assert.Equal(t, 10*time.Second, cinfo.Resolution)
+	assert.Equal(t, uint64(len(stats)), sumSamples(cinfo.Buckets))
+
+	// The most recent buckets are returned.
+	cinfo, err = m.GetContainerInfo("/c1", &info.ContainerInfoRequest{NumStats: 2, Resolution: 10 * time.Second})
+	require.Nil(t, err)
+	require.Equal(t, 2, len(cinfo.Buckets))
+	assert.Equal(t, stats[len(stats)-1].Timestamp.Truncate(10*time.Second), cinfo.Buckets[1].Timestamp)
+
+	// Aggregated from the minute aggregates of the summary, at a whole number of minutes.
+	cont, err := m.getContainerData("/c1")
+	require.Nil(t, err)
+	require.NotNil(t, cont.summaryReader)
+	for _, s := range stats {
+		require.Nil(t, cont.summaryReader.AddSample(*s))
+	}
+	cinfo, err = m.GetContainerInfo("/c1", &info.ContainerInfoRequest{NumStats: 100, Resolution: 90 * time.Second})
+	require.Nil(t, err)
+	assert.Nil(t, cinfo.Stats)
+	assert.Equal(t, 2*time.Minute, cinfo.Resolution)
+	assert.Equal(t, uint64(len(stats)), sumSamples(cinfo.Buckets))
+}
@@ -0,0 +1,87 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/utils/selfcheck"
+)
+
+// Periodically cross-checks the stats collected for a container.
+type selfChecker struct {
+	interval  time.Duration
+	tolerance float64
+
+	// Monotonic time of the last check, zero if none.
+	lastCheck time.Duration
+
+	// Number of checks made and of discrepancies found by field, protected
+	// by the lock of the container.
+	checks        uint64
+	discrepancies map[string]uint64
+}
+
+func newSelfChecker(interval time.Duration, tolerance float64) *selfChecker {
+	return &selfChecker{
+		interval:      interval,
+		tolerance:     tolerance,
+		discrepancies: make(map[string]uint64),
+	}
+}
+
+// Reads the cgroup files of the container before its stats are collected if
+// a self check is due. Returns nil if none is.
+func (c *containerData) beginSelfCheck() selfcheck.Values {
+	if c.selfCheck == nil {
+		return nil
+	}
+	now := c.clock.Monotonic()
+	if c.selfCheck.lastCheck != 0 && now-c.selfCheck.lastCheck < c.selfCheck.interval {
+		return nil
+	}
+	c.selfCheck.lastCheck = now
+	before, err := selfcheck.Read(c.handler.GetCgroupPath)
+	if err != nil {
+		glog.V(4).Infof("[%s] Failed to read the cgroup files for the self check: %v", c.info.Name, err)
+		return nil
+	}
+	return before
+}
+
+// Compares the stats collected with the cgroup files read before and now, and
+// counts the discrepancies.
+func (c *containerData) endSelfCheck(before selfcheck.Values, stats *info.ContainerStats) {
+	if before == nil || stats == nil {
+		return
+	}
+	after, err := selfcheck.Read(c.handler.GetCgroupPath)
+	if err != nil {
+		glog.V(4).Infof("[%s] Failed to read the cgroup files for the self check: %v", c.info.Name, err)
+		return
+	}
+	discrepancies := selfcheck.Compare(before, after, selfcheck.FromStats(stats), c.selfCheck.tolerance)
+	for _, d := range discrepancies {
+		glog.Warningf("[%s] Self check discrepancy: %v", c.info.Name, d)
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.selfCheck.checks++
+	for _, d := range discrepancies {
+		c.selfCheck.discrepancies[d.Field]++
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/utils/clock/fakeclock"
+	"github.com/google/cadvisor/utils/selfcheck"
+	"github.com/google/cadvisor/utils/timing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelfCheckCountsDiscrepancies(t *testing.T) {
+	dir, err := ioutil.TempDir("", "self_check")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	require.Nil(t, ioutil.WriteFile(path.Join(dir, "memory.usage_in_bytes"), []byte("1000\n"), 0644))
+	require.Nil(t, ioutil.WriteFile(path.Join(dir, "memory.stat"), []byte("pgfault 10\npgmajfault 1\ntotal_pgfault 30\ntotal_pgmajfault 3\n"), 0644))
+
+	cd, mockHandler, _ := newTestContainerData(t)
+	clock := fakeclock.NewFakeClock(time.Now())
+	cd.clock = clock
+	cd.selfCheck = newSelfChecker(time.Minute, 0.01)
+	mockHandler.On("GetCgroupPath", "cpuacct").Return("", fmt.Errorf("no cpuacct hierarchy"))
+	mockHandler.On("GetCgroupPath", "memory").Return(dir, nil)
+
+	// The local page faults reported as those of the hierarchy.
+	stats := &info.ContainerStats{Timestamp: clock.Now(), PartialFailure: []string{info.StatsSectionCpu}}
+	stats.Memory.Usage = 1000
+	stats.Memory.ContainerData = info.MemoryStatsMemoryData{Pgfault: 10, Pgmajfault: 1}
+	stats.Memory.HierarchicalData = info.MemoryStatsMemoryData{Pgfault: 10, Pgmajfault: 3}
+	mockHandler.On("GetStats").Return(stats, nil)
+
+	require.Nil(t, cd.updateStats(timing.NewSections()))
+	status := cd.CollectionStatus()
+	assert.Equal(t, uint64(1), status.SelfChecks)
+	assert.Equal(t, map[string]uint64{selfcheck.MemoryHierarchicalPgfault: 1}, status.SelfCheckDiscrepancies)
+
+	// The next check is made once the interval elapsed.
+	clock.Step(30 * time.Second)
+	require.Nil(t, cd.updateStats(timing.NewSections()))
+	assert.Equal(t, uint64(1), cd.CollectionStatus().SelfChecks)
+	clock.Step(30 * time.Second)
+	require.Nil(t, cd.updateStats(timing.NewSections()))
+	status = cd.CollectionStatus()
+	assert.Equal(t, uint64(2), status.SelfChecks)
+	assert.Equal(t, map[string]uint64{selfcheck.MemoryHierarchicalPgfault: 2}, status.SelfCheckDiscrepancies)
+}
+
+func TestSelfCheckDisabled(t *testing.T) {
+	cd, mockHandler, _ := newTestContainerData(t)
+	mockHandler.On("GetStats").Return(&info.ContainerStats{Timestamp: time.Now()}, nil)
+	require.Nil(t, cd.updateStats(timing.NewSections()))
+	status := cd.CollectionStatus()
+	assert.Equal(t, uint64(0), status.SelfChecks)
+	mockHandler.AssertNotCalled(t, "GetCgroupPath", "memory")
+}
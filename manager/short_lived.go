@@ -0,0 +1,96 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"path"
+	"time"
+
+	"github.com/golang/glog"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Name of the aggregate of the short-lived containers of a parent, within it.
+const shortLivedAggregateName = "_short_lived"
+
+// Returns the name of the aggregate the specified container is rolled into
+// if it is short-lived.
+func shortLivedAggregate(containerName string) string {
+	return path.Join(path.Dir(containerName), shortLivedAggregateName)
+}
+
+// Holds back the stats of the new container from the storage backend while it
+// is younger than ShortLivedContainerThreshold. They are written as its
+// own once it is older, or rolled into the aggregate of its parent if it is
+// deleted before.
+func (m *manager) deferShortLivedStats(containerName string, creationTime time.Time) {
+	if m.config.ShortLivedContainerThreshold <= 0 || containerName == "/" {
+		return
+	}
+	age := time.Duration(0)
+	if !creationTime.IsZero() {
+		age = time.Since(creationTime)
+	}
+	if age >= m.config.ShortLivedContainerThreshold {
+		return
+	}
+
+	m.memoryStorage.DeferBackend(containerName)
+	m.youngContainersLock.Lock()
+	defer m.youngContainersLock.Unlock()
+	if m.youngContainers == nil {
+		m.youngContainers = make(map[string]*time.Timer)
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(m.config.ShortLivedContainerThreshold-age, func() {
+		m.youngContainersLock.Lock()
+		if m.youngContainers[containerName] == timer {
+			delete(m.youngContainers, containerName)
+		}
+		m.youngContainersLock.Unlock()
+		m.memoryStorage.FlushDeferred(containerName)
+	})
+	m.youngContainers[containerName] = timer
+}
+
+// Rolls the stats of the deleted container into the aggregate of its parent
+// if it was short-lived.
+func (m *manager) rollUpShortLivedStats(containerName string) {
+	m.youngContainersLock.Lock()
+	timer, ok := m.youngContainers[containerName]
+	delete(m.youngContainers, containerName)
+	m.youngContainersLock.Unlock()
+	if !ok || !timer.Stop() {
+		// Not short-lived, its stats are (being) written as its own.
+		return
+	}
+	aggregate := shortLivedAggregate(containerName)
+	n := m.memoryStorage.RollUpDeferred(containerName, info.ContainerReference{Name: aggregate})
+	glog.V(3).Infof("Rolled %d stats of short-lived container %q into %q", n, containerName, aggregate)
+}
+
+// Writes the stats held back for the young containers as their own, e.g.:
+// when the manager is stopped.
+func (m *manager) flushShortLivedStats() {
+	m.youngContainersLock.Lock()
+	young := m.youngContainers
+	m.youngContainers = nil
+	m.youngContainersLock.Unlock()
+	for name, timer := range young {
+		if timer.Stop() {
+			m.memoryStorage.FlushDeferred(name)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/events"
+	"github.com/google/cadvisor/storage/memory"
+	stest "github.com/google/cadvisor/storage/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Creates a manager of Docker containers writing to the returned backend,
+// with the specified short-lived container threshold.
+func newShortLivedTestManager(threshold time.Duration) (*manager, *stest.RecordingStorageDriver, func()) {
+	config := newTestConfig()
+	config.ShortLivedContainerThreshold, config.DockerRestartGrace, config.HousekeepingInterval = threshold, 0, 5*time.Millisecond
+
+	factory, _ := newDockerTestFactory()
+	backend := stest.NewRecordingStorageDriver()
+	m := newTestManager(config, factory)
+	m.memoryStorage = memory.New(60, backend)
+	return m, backend, func() {
+		for name := range m.containers {
+			m.destroyContainer(name.Name)
+		}
+		m.flushShortLivedStats()
+		container.ClearContainerHandlerFactories()
+	}
+}
+
+func TestShortLivedContainersAreRolledUp(t *testing.T) {
+	m, backend, cleanup := newShortLivedTestManager(300 * time.Millisecond)
+	defer cleanup()
+
+	const longLived = "/docker/long"
+	require.Nil(t, m.createContainer(longLived))
+	var shortLived []string
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("/docker/short%d", i)
+		require.Nil(t, m.createContainer(name))
+		// Served individually while it exists.
+		waitFor(t, "stats of "+name, func() bool {
+			return numStats(m, name) > 0
+		})
+		require.Nil(t, m.destroyContainer(name))
+		shortLived = append(shortLived, name)
+	}
+	assert.Equal(t, map[string]bool{"/docker/_short_lived": true}, backend.ContainerNames())
+	aggregated, err := backend.RecentStats("/docker/_short_lived", -1)
+	require.Nil(t, err)
+	assert.True(t, len(aggregated) >= len(shortLived), "expected the stats of all short-lived containers, got %d", len(aggregated))
+
+	// The long-lived container is written as its own once past the threshold.
+	waitFor(t, "stats of the long-lived container", func() bool {
+		return backend.ContainerNames()[longLived]
+	})
+	assert.Equal(t, map[string]bool{"/docker/_short_lived": true, longLived: true}, backend.ContainerNames())
+
+	// The deletions are still recorded with the real names.
+	request := events.NewRequest()
+	request.EventType[events.TypeContainerDeletion] = true
+	request.MaxEventsReturned = -1
+	evs, err := m.eventHandler.GetEvents(request)
+	require.Nil(t, err)
+	var deleted []string
+	for _, ev := range evs {
+		deleted = append(deleted, ev.ContainerName)
+	}
+	assert.Equal(t, shortLived, deleted)
+}
+
+func TestShortLivedThresholdDisabled(t *testing.T) {
+	m, backend, cleanup := newShortLivedTestManager(0)
+	defer cleanup()
+
+	const name = "/docker/short"
+	require.Nil(t, m.createContainer(name))
+	waitFor(t, "stats of "+name, func() bool {
+		return backend.ContainerNames()[name]
+	})
+	require.Nil(t, m.destroyContainer(name))
+	assert.Equal(t, map[string]bool{name: true}, backend.ContainerNames())
+}
+
+func TestShortLivedAggregate(t *testing.T) {
+	assert.Equal(t, "/docker/_short_lived", shortLivedAggregate("/docker/abc"))
+	assert.Equal(t, "/_short_lived", shortLivedAggregate("/user"))
+}
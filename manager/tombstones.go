@@ -0,0 +1,233 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"container/list"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+)
+
+// Number of the last stats of a deleted container which are kept.
+const tombstoneStats = 10
+
+// A deleted container as it was last known.
+type tombstone struct {
+	ref          info.ContainerReference
+	spec         info.ContainerSpec
+	status       v2.CollectionStatus
+	deletionTime time.Time
+	stats        []*info.ContainerStats
+}
+
+func (self *tombstone) deletedContainer(withStats bool) v2.DeletedContainer {
+	ret := v2.DeletedContainer{
+		Name:             self.ref.Name,
+		Spec:             v2.ContainerSpecFromV1(&self.spec, self.ref),
+		CollectionStatus: self.status,
+		DeletionTime:     self.deletionTime,
+	}
+	if withStats {
+		ret.Stats = self.stats
+	}
+	return ret
+}
+
+// The most recently deleted containers, at most maxCount of them deleted in
+// the last maxAge. The least recently recorded or looked up is forgotten
+// first. A container deleted again replaces its previous tombstone.
+// Class is thread-safe.
+type tombstones struct {
+	lock     sync.Mutex
+	maxCount int
+	maxAge   time.Duration
+
+	// Tombstones by container name and in the order they were last used,
+	// least recently first.
+	byName map[string]*list.Element
+	order  *list.List
+
+	// Returns the current time, replaced in tests.
+	now func() time.Time
+}
+
+func newTombstones(maxCount int, maxAge time.Duration) *tombstones {
+	return &tombstones{
+		maxCount: maxCount,
+		maxAge:   maxAge,
+		byName:   make(map[string]*list.Element),
+		order:    list.New(),
+		now:      time.Now,
+	}
+}
+
+func (self *tombstones) add(t *tombstone) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.maxCount <= 0 {
+		return
+	}
+	self.removeLocked(t.ref.Name)
+	for self.order.Len() >= self.maxCount {
+		self.removeLocked(self.order.Front().Value.(*tombstone).ref.Name)
+	}
+	self.byName[t.ref.Name] = self.order.PushBack(t)
+}
+
+// Returns the tombstone of the container and marks it as the most recently used.
+func (self *tombstones) get(name string) (*tombstone, bool) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.expireLocked()
+	elem, ok := self.byName[name]
+	if !ok {
+		return nil, false
+	}
+	self.order.MoveToBack(elem)
+	return elem.Value.(*tombstone), true
+}
+
+// Returns the tombstones, the most recently deleted container first.
+func (self *tombstones) list() []*tombstone {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.expireLocked()
+	ret := make([]*tombstone, 0, self.order.Len())
+	for elem := self.order.Front(); elem != nil; elem = elem.Next() {
+		ret = append(ret, elem.Value.(*tombstone))
+	}
+	sort.Sort(tombstonesByDeletion(ret))
+	return ret
+}
+
+// Forgets the containers deleted more than maxAge ago.
+func (self *tombstones) expireLocked() {
+	if self.maxAge <= 0 {
+		return
+	}
+	oldest := self.now().Add(-self.maxAge)
+	for name, elem := range self.byName {
+		if elem.Value.(*tombstone).deletionTime.Before(oldest) {
+			self.removeLocked(name)
+		}
+	}
+}
+
+func (self *tombstones) removeLocked(name string) {
+	if elem, ok := self.byName[name]; ok {
+		self.order.Remove(elem)
+		delete(self.byName, name)
+	}
+}
+
+// Sorts by deletion time, the most recent first.
+type tombstonesByDeletion []*tombstone
+
+func (self tombstonesByDeletion) Len() int      { return len(self) }
+func (self tombstonesByDeletion) Swap(i, j int) { self[i], self[j] = self[j], self[i] }
+func (self tombstonesByDeletion) Less(i, j int) bool {
+	return self[i].deletionTime.After(self[j].deletionTime)
+}
+
+// Records the tombstone of the container, which was deleted at the specified
+// time, along with its last stats.
+func (m *manager) recordTombstone(cont *containerData, deletionTime time.Time) {
+	if m.tombstones == nil {
+		return
+	}
+	cont.lock.Lock()
+	cinfo := cont.info
+	cont.lock.Unlock()
+	// The stats are copied out of the memory storage, where those of a new
+	// container with the same name would overwrite them.
+	var stats []*info.ContainerStats
+	recent, err := m.memoryStorage.RecentStats(cinfo.Name, time.Time{}, time.Time{}, tombstoneStats)
+	if err == nil {
+		stats = make([]*info.ContainerStats, 0, len(recent))
+		for _, s := range recent {
+			copied := *s
+			stats = append(stats, &copied)
+		}
+	}
+	m.tombstones.add(&tombstone{
+		ref:          cinfo.ContainerReference,
+		spec:         m.getAdjustedSpec(&cinfo),
+		status:       cont.CollectionStatus(),
+		deletionTime: deletionTime,
+		stats:        stats,
+	})
+}
+
+func (m *manager) GetDeletedContainers() ([]v2.DeletedContainer, error) {
+	ret := []v2.DeletedContainer{}
+	if m.tombstones == nil {
+		return ret, nil
+	}
+	for _, t := range m.tombstones.list() {
+		ret = append(ret, t.deletedContainer(false))
+	}
+	return ret, nil
+}
+
+func (m *manager) GetDeletedContainer(containerName string) (v2.DeletedContainer, error) {
+	if m.tombstones != nil {
+		if t, ok := m.tombstones.get(containerName); ok {
+			return t.deletedContainer(true), nil
+		}
+	}
+	return v2.DeletedContainer{}, fmt.Errorf("unknown deleted container %q", containerName)
+}
+
+// Returns the info of the deleted container as it was last known.
+func (m *manager) getDeletedContainerInfo(containerName string, query *info.ContainerInfoRequest) (*info.ContainerInfo, bool) {
+	if m.tombstones == nil {
+		return nil, false
+	}
+	t, ok := m.tombstones.get(containerName)
+	if !ok {
+		return nil, false
+	}
+	var stats []*info.ContainerStats
+	for _, s := range t.stats {
+		if (query.Start.IsZero() || !s.Timestamp.Before(query.Start)) && (query.End.IsZero() || !s.Timestamp.After(query.End)) {
+			stats = append(stats, s)
+		}
+	}
+	if query.NumStats >= 0 && len(stats) > query.NumStats {
+		stats = stats[len(stats)-query.NumStats:]
+	}
+	ret := &info.ContainerInfo{
+		ContainerReference: t.ref,
+		Spec:               t.spec,
+		Stats:              stats,
+		// Deleted containers are no longer housekept.
+		Freshness: &info.Freshness{
+			State:           info.FreshnessDead,
+			NewestSampleAge: -1,
+		},
+	}
+	if len(t.stats) != 0 {
+		ret.Freshness.NewestSampleAge = time.Since(t.stats[len(t.stats)-1].Timestamp)
+	}
+	if query.IncludeMachine {
+		m.addMachineCapacity(ret)
+	}
+	return ret, true
+}
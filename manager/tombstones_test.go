@@ -0,0 +1,137 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var deletionStart = time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestTombstone(name string, deleted time.Duration) *tombstone {
+	return &tombstone{
+		ref:          info.ContainerReference{Name: name},
+		deletionTime: deletionStart.Add(deleted),
+	}
+}
+
+func tombstoneNames(list []*tombstone) []string {
+	var names []string
+	for _, t := range list {
+		names = append(names, t.ref.Name)
+	}
+	return names
+}
+
+func TestTombstonesEvictLeastRecentlyUsed(t *testing.T) {
+	tombstones := newTombstones(3, 0)
+	tombstones.add(newTestTombstone("/a", 0))
+	tombstones.add(newTestTombstone("/b", time.Second))
+	tombstones.add(newTestTombstone("/c", 2*time.Second))
+
+	// Looking up /a makes /b the least recently used.
+	_, ok := tombstones.get("/a")
+	require.True(t, ok)
+	tombstones.add(newTestTombstone("/d", 3*time.Second))
+
+	_, ok = tombstones.get("/b")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"/d", "/c", "/a"}, tombstoneNames(tombstones.list()))
+}
+
+func TestTombstonesExpire(t *testing.T) {
+	tombstones := newTombstones(10, time.Minute)
+	now := deletionStart
+	tombstones.now = func() time.Time { return now }
+	tombstones.add(newTestTombstone("/a", 0))
+	tombstones.add(newTestTombstone("/b", 30*time.Second))
+
+	now = deletionStart.Add(45 * time.Second)
+	assert.Equal(t, []string{"/b", "/a"}, tombstoneNames(tombstones.list()))
+	now = deletionStart.Add(75 * time.Second)
+	assert.Equal(t, []string{"/b"}, tombstoneNames(tombstones.list()))
+	_, ok := tombstones.get("/a")
+	assert.False(t, ok)
+}
+
+func TestTombstonesReplacePreviousDeletion(t *testing.T) {
+	tombstones := newTombstones(10, 0)
+	tombstones.add(newTestTombstone("/a", 0))
+	tombstones.add(newTestTombstone("/a", time.Minute))
+	list := tombstones.list()
+	require.Equal(t, 1, len(list))
+	assert.Equal(t, deletionStart.Add(time.Minute), list[0].deletionTime)
+}
+
+func TestTombstonesDisabled(t *testing.T) {
+	tombstones := newTombstones(0, time.Hour)
+	tombstones.add(newTestTombstone("/a", 0))
+	assert.Equal(t, 0, len(tombstones.list()))
+}
+
+func TestRecreatedContainerShadowsTombstone(t *testing.T) {
+	m, _, cleanup := newShortLivedTestManager(0)
+	defer cleanup()
+	m.tombstones = newTombstones(10, time.Hour)
+
+	const name = "/docker/oom"
+	require.Nil(t, m.createContainer(name))
+	waitFor(t, "stats of "+name, func() bool {
+		return numStats(m, name) > 0
+	})
+	require.Nil(t, m.destroyContainer(name))
+	_, err := m.GetContainerInfo(name, &info.ContainerInfoRequest{NumStats: 10})
+	assert.NotNil(t, err)
+
+	deleted, err := m.GetDeletedContainer(name)
+	require.Nil(t, err)
+	require.NotEqual(t, 0, len(deleted.Stats))
+	tombstoneStats := make([]info.ContainerStats, 0, len(deleted.Stats))
+	for _, stats := range deleted.Stats {
+		tombstoneStats = append(tombstoneStats, *stats)
+	}
+	cinfo, err := m.GetContainerInfo(name, &info.ContainerInfoRequest{NumStats: 10, IncludeDeleted: true})
+	require.Nil(t, err)
+	assert.Equal(t, name, cinfo.Name)
+	assert.Equal(t, len(deleted.Stats), len(cinfo.Stats))
+
+	// The new container is served rather than the deleted one, whose stats
+	// are left as they were.
+	require.Nil(t, m.createContainer(name))
+	before := numStats(m, name)
+	waitFor(t, "stats of the new "+name, func() bool {
+		return numStats(m, name) > before
+	})
+	cinfo, err = m.GetContainerInfo(name, &info.ContainerInfoRequest{NumStats: 1, IncludeDeleted: true})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(cinfo.Stats))
+	assert.True(t, cinfo.Stats[0].Timestamp.After(tombstoneStats[len(tombstoneStats)-1].Timestamp))
+
+	deleted, err = m.GetDeletedContainer(name)
+	require.Nil(t, err)
+	require.Equal(t, len(tombstoneStats), len(deleted.Stats))
+	for i, stats := range deleted.Stats {
+		assert.Equal(t, tombstoneStats[i], *stats)
+	}
+	list, err := m.GetDeletedContainers()
+	require.Nil(t, err)
+	require.Equal(t, 1, len(list))
+	assert.Nil(t, list[0].Stats)
+}
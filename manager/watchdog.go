@@ -0,0 +1,216 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/events"
+)
+
+// Restarts of the housekeeping of a container.
+type housekeepingRestarts struct {
+	// Times of the restarts within the restart window.
+	times []time.Time
+
+	// Whether running out of restarts was logged.
+	exhaustedLogged bool
+}
+
+// Periodically restarts the housekeeping of containers whose housekeeping is stuck.
+func (m *manager) housekeepingWatchdog(quit chan error) {
+	ticker := time.Tick(m.config.HousekeepingInterval)
+	for {
+		select {
+		case now := <-ticker:
+			m.restartStuckHousekeepings(now)
+		case <-quit:
+			quit <- nil
+			glog.Infof("Exiting housekeeping watchdog thread")
+			return
+		}
+	}
+}
+
+// Restarts the housekeeping of the containers whose housekeeping has been
+// running for more than HousekeepingWatchdogIntervals intervals.
+func (m *manager) restartStuckHousekeepings(now time.Time) {
+	type stuckContainer struct {
+		cont     *containerData
+		stuckFor time.Duration
+	}
+	var stuck []stuckContainer
+	m.containersLock.RLock()
+	for name, cont := range m.containers {
+		// Skip aliases.
+		if name.Name != cont.info.Name {
+			continue
+		}
+		if stuckFor, ok := cont.stuckTick(now, m.config.HousekeepingWatchdogIntervals); ok {
+			stuck = append(stuck, stuckContainer{cont, stuckFor})
+		}
+	}
+	m.containersLock.RUnlock()
+
+	for _, s := range stuck {
+		restarts, ok := m.allowHousekeepingRestart(s.cont.info.Name, now)
+		if !ok {
+			continue
+		}
+		glog.Errorf("[%s] Housekeeping has been stuck for %v, restarting it. Goroutines:\n%s", s.cont.info.Name, s.stuckFor, allStacks())
+		s.cont.poison()
+		// Creating the handler may get stuck on whatever the housekeeping is stuck on.
+		go m.restartHousekeeping(s.cont, restarts)
+	}
+}
+
+// Records a restart of the housekeeping of the specified container. Returns
+// the number of restarts so far and false if the container is out of restarts.
+func (m *manager) allowHousekeepingRestart(containerName string, now time.Time) (int, bool) {
+	m.housekeepingRestartsLock.Lock()
+	defer m.housekeepingRestartsLock.Unlock()
+
+	restarts, ok := m.housekeepingRestarts[containerName]
+	if !ok {
+		restarts = &housekeepingRestarts{}
+		m.housekeepingRestarts[containerName] = restarts
+	}
+	var recent []time.Time
+	for _, t := range restarts.times {
+		if now.Sub(t) < m.config.HousekeepingWatchdogRestartWindow {
+			recent = append(recent, t)
+		}
+	}
+	restarts.times = recent
+	if len(restarts.times) >= m.config.HousekeepingWatchdogMaxRestarts {
+		if !restarts.exhaustedLogged {
+			glog.Errorf("[%s] Housekeeping is stuck but was already restarted %d times in the last %v, not restarting it", containerName, len(restarts.times), m.config.HousekeepingWatchdogRestartWindow)
+			restarts.exhaustedLogged = true
+		}
+		return 0, false
+	}
+	restarts.times = append(restarts.times, now)
+	restarts.exhaustedLogged = false
+	return len(restarts.times), true
+}
+
+// Forgets the restarts of the housekeeping of the specified container, once it is deleted.
+func (m *manager) forgetHousekeepingRestarts(containerName string) {
+	m.housekeepingRestartsLock.Lock()
+	defer m.housekeepingRestartsLock.Unlock()
+	delete(m.housekeepingRestarts, containerName)
+}
+
+// Replaces the poisoned container with a new one, with a new handler, that
+// continues its history.
+func (m *manager) restartHousekeeping(old *containerData, restarts int) {
+	name := old.info.Name
+	cont, err := m.newRestartedContainer(name, old.logUsage)
+	if err != nil {
+		glog.Errorf("[%s] Failed to restart housekeeping, the container will be recreated when detected again: %v", name, err)
+	}
+	if !m.replaceContainer(old, cont) {
+		// Destroyed in the meantime.
+		return
+	}
+	if cont == nil {
+		m.containerRevisions.record(containerRemoved, old.reference())
+		return
+	}
+	oldStatus := old.CollectionStatus()
+	cont.lock.Lock()
+	cont.collectionStatus.HousekeepingRestarts = oldStatus.HousekeepingRestarts + 1
+	cont.sectionFailures = oldStatus.SectionFailures
+	status := cont.collectionStatus
+	cont.lock.Unlock()
+	cont.Start()
+	glog.Infof("[%s] Restarted housekeeping (%d restarts in the last %v)", name, restarts, m.config.HousekeepingWatchdogRestartWindow)
+
+	err = m.eventHandler.AddEvent(&events.Event{
+		ContainerName: name,
+		Timestamp:     time.Now(),
+		EventType:     events.TypeCollectorRestarted,
+		EventData:     status,
+	})
+	if err != nil {
+		glog.Errorf("[%s] Failed to add collector restart event: %v", name, err)
+	}
+}
+
+func (m *manager) newRestartedContainer(containerName string, logUsage bool) (*containerData, error) {
+	handler, err := container.NewContainerHandler(containerName)
+	if err != nil {
+		return nil, err
+	}
+	cont, err := newContainerData(containerName, m.memoryStorage, handler, &m.config, m.loadReader, m.eventHandler, logUsage)
+	if err != nil {
+		return nil, err
+	}
+	cont.specChanged = m.containerSpecChanged
+	cont.logs = m.logs
+	return cont, nil
+}
+
+// Replaces the specified container and all its aliases in the containers map
+// with the new container. A nil new container only removes the old one.
+// Returns false if the old container is no longer known.
+func (m *manager) replaceContainer(old *containerData, cont *containerData) bool {
+	m.containersLock.Lock()
+	defer m.containersLock.Unlock()
+
+	namespacedName := namespacedContainerName{
+		Name: old.info.Name,
+	}
+	if m.containers[namespacedName] != old {
+		return false
+	}
+	delete(m.containers, namespacedName)
+	for _, alias := range old.info.Aliases {
+		aliasName := namespacedContainerName{
+			Namespace: old.info.Namespace,
+			Name:      alias,
+		}
+		if m.containers[aliasName] == old {
+			delete(m.containers, aliasName)
+		}
+	}
+	if cont == nil {
+		return true
+	}
+
+	m.containers[namespacedName] = cont
+	for _, alias := range cont.info.Aliases {
+		m.containers[namespacedContainerName{
+			Namespace: cont.info.Namespace,
+			Name:      alias,
+		}] = cont
+	}
+	return true
+}
+
+// Returns the stacks of all goroutines.
+func allStacks() []byte {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+	itest "github.com/google/cadvisor/info/v1/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Container handler whose stats reads block until unblocked.
+type blockingHandler struct {
+	*container.MockContainerHandler
+	unblock chan struct{}
+	stats   *info.ContainerStats
+}
+
+func (self *blockingHandler) GetStats() (*info.ContainerStats, error) {
+	<-self.unblock
+	return self.stats, nil
+}
+
+// Handlers created for a single container. The first ones block.
+type blockingHandlers struct {
+	lock     sync.Mutex
+	blocking int
+	handlers []*blockingHandler
+}
+
+func (self *blockingHandlers) add(handler *container.MockContainerHandler) *blockingHandler {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	stats := itest.GenerateRandomStats(1, 4, time.Second)[0]
+	ret := &blockingHandler{
+		MockContainerHandler: handler,
+		unblock:              make(chan struct{}),
+		stats:                stats,
+	}
+	handler.On("GetSpec").Return(info.ContainerSpec{}, nil)
+	if len(self.handlers) >= self.blocking {
+		close(ret.unblock)
+	} else {
+		// Stats of stuck handlers are in the future so they would be stored if not dropped.
+		stats.Timestamp = time.Now().Add(time.Hour)
+	}
+	self.handlers = append(self.handlers, ret)
+	return ret
+}
+
+func (self *blockingHandlers) handler(i int) *blockingHandler {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.handlers[i]
+}
+
+// Creates a manager whose first handlers block, with the watchdog restarting
+// housekeepings stuck for two intervals at most the specified number of times.
+func newWatchdogTestManager(blocking, maxRestarts int) (*manager, *blockingHandlers, func()) {
+	config := newTestConfig()
+	config.HousekeepingInterval, config.HousekeepingWatchdogIntervals, config.HousekeepingWatchdogMaxRestarts, config.HousekeepingOverrunThreshold = 5*time.Millisecond, 2, maxRestarts, 0
+
+	handlers := &blockingHandlers{blocking: blocking}
+	factory := &container.FactoryForMockContainerHandler{
+		Name: "blocking",
+		WrapContainerHandlerFunc: func(name string, handler *container.MockContainerHandler) (container.ContainerHandler, error) {
+			return handlers.add(handler), nil
+		},
+	}
+	m := newTestManager(config, factory)
+	return m, handlers, func() {
+		handlers.lock.Lock()
+		for _, handler := range handlers.handlers {
+			select {
+			case <-handler.unblock:
+			default:
+				close(handler.unblock)
+			}
+		}
+		handlers.lock.Unlock()
+		for _, cont := range m.containers {
+			cont.Stop()
+			<-cont.housekeepingDone
+		}
+		container.ClearContainerHandlerFactories()
+	}
+}
+
+func getCollectorRestartEvents(t *testing.T, m *manager) []*events.Event {
+	request := events.NewRequest()
+	request.EventType[events.TypeCollectorRestarted] = true
+	request.MaxEventsReturned = -1
+	evs, err := m.eventHandler.GetEvents(request)
+	require.Nil(t, err)
+	return evs
+}
+
+func TestStuckHousekeepingIsRestarted(t *testing.T) {
+	const name = "/stuck"
+	m, handlers, cleanup := newWatchdogTestManager(1, 3)
+	defer cleanup()
+
+	require.Nil(t, m.createContainer(name))
+	stuck, err := m.getContainerData(name)
+	require.Nil(t, err)
+
+	waitFor(t, "the housekeeping to be restarted", func() bool {
+		m.restartStuckHousekeepings(time.Now())
+		cont, err := m.getContainerData(name)
+		return err == nil && cont != stuck
+	})
+	waitFor(t, "stats to be collected", func() bool {
+		return numStats(m, name) >= 2
+	})
+	restarted, err := m.getContainerData(name)
+	require.Nil(t, err)
+	assert.Equal(t, 1, restarted.CollectionStatus().HousekeepingRestarts)
+
+	evs := getCollectorRestartEvents(t, m)
+	require.Equal(t, 1, len(evs))
+	assert.Equal(t, name, evs[0].ContainerName)
+
+	// The late stats of the stuck housekeeping are dropped and it exits.
+	close(handlers.handler(0).unblock)
+	<-stuck.housekeepingDone
+	stats, err := m.memoryStorage.RecentStats(name, time.Time{}, time.Time{}, -1)
+	require.Nil(t, err)
+	for _, s := range stats {
+		assert.True(t, s.Timestamp.Before(time.Now()), "stats of the stuck housekeeping were stored")
+	}
+}
+
+func TestHousekeepingRestartsAreLimited(t *testing.T) {
+	const name = "/stuck"
+	m, _, cleanup := newWatchdogTestManager(3, 1)
+	defer cleanup()
+
+	require.Nil(t, m.createContainer(name))
+	stuck, err := m.getContainerData(name)
+	require.Nil(t, err)
+	waitFor(t, "the housekeeping to be restarted", func() bool {
+		m.restartStuckHousekeepings(time.Now())
+		cont, err := m.getContainerData(name)
+		return err == nil && cont != stuck
+	})
+
+	// The restarted housekeeping is stuck too, but there are no restarts left.
+	restarted, err := m.getContainerData(name)
+	require.Nil(t, err)
+	waitFor(t, "the housekeeping to be stuck again", func() bool {
+		_, ok := restarted.stuckTick(time.Now(), m.config.HousekeepingWatchdogIntervals)
+		return ok
+	})
+	m.restartStuckHousekeepings(time.Now())
+	time.Sleep(10 * time.Millisecond)
+	cont, err := m.getContainerData(name)
+	require.Nil(t, err)
+	assert.True(t, cont == restarted)
+	assert.False(t, restarted.isPoisoned())
+	assert.Equal(t, 1, len(getCollectorRestartEvents(t, m)))
+}
+
+func TestHealthyHousekeepingIsNotRestarted(t *testing.T) {
+	const name = "/healthy"
+	m, _, cleanup := newWatchdogTestManager(0, 3)
+	defer cleanup()
+
+	require.Nil(t, m.createContainer(name))
+	cont, err := m.getContainerData(name)
+	require.Nil(t, err)
+	waitFor(t, "stats to be collected", func() bool {
+		return numStats(m, name) >= 3
+	})
+	m.restartStuckHousekeepings(time.Now())
+	after, err := m.getContainerData(name)
+	require.Nil(t, err)
+	assert.True(t, cont == after)
+	assert.Empty(t, getCollectorRestartEvents(t, m))
+}
+
+func TestHousekeepingRestartsAreForgottenOnDeletion(t *testing.T) {
+	const name = "/stuck"
+	m, _, cleanup := newWatchdogTestManager(1, 3)
+	defer cleanup()
+
+	require.Nil(t, m.createContainer(name))
+	stuck, err := m.getContainerData(name)
+	require.Nil(t, err)
+	waitFor(t, "the housekeeping to be restarted", func() bool {
+		m.restartStuckHousekeepings(time.Now())
+		cont, err := m.getContainerData(name)
+		return err == nil && cont != stuck
+	})
+	m.housekeepingRestartsLock.Lock()
+	assert.Equal(t, 1, len(m.housekeepingRestarts))
+	m.housekeepingRestartsLock.Unlock()
+
+	require.Nil(t, m.destroyContainer(name))
+	m.housekeepingRestartsLock.Lock()
+	defer m.housekeepingRestartsLock.Unlock()
+	assert.Empty(t, m.housekeepingRestarts)
+}
@@ -0,0 +1,65 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build linux
+
+package main
+
+import (
+	"flag"
+
+	"github.com/google/cadvisor/manager"
+)
+
+// Configuration of the manager, set by the flags below. The manager package
+// has no flags of its own so that it can be embedded with a configuration of
+// the program's choosing.
+var managerConfig = manager.DefaultConfig()
+
+func init() {
+	flag.DurationVar(&managerConfig.HousekeepingInterval, "housekeeping_interval", managerConfig.HousekeepingInterval, "Interval between container housekeepings")
+	flag.DurationVar(&managerConfig.MaxHousekeepingInterval, "max_housekeeping_interval", managerConfig.MaxHousekeepingInterval, "Largest interval to allow between container housekeepings")
+	flag.DurationVar(&managerConfig.RootHousekeepingInterval, "root_housekeeping_interval", managerConfig.RootHousekeepingInterval, "Interval between housekeepings of the root container, which machine-level usage is read from. Zero uses --housekeeping_interval")
+	flag.BoolVar(&managerConfig.AllowDynamicHousekeeping, "allow_dynamic_housekeeping", managerConfig.AllowDynamicHousekeeping, "Whether to allow the housekeeping interval to be dynamic")
+	flag.DurationVar(&managerConfig.TimeJumpThreshold, "time_jump_threshold", managerConfig.TimeJumpThreshold, "Amount by which the wall-clock and monotonic time elapsed between two samples must differ for the wall clock to be considered to have jumped (e.g.: suspend/resume)")
+	flag.BoolVar(&managerConfig.ProfilingHousekeeping, "profiling_housekeeping", managerConfig.ProfilingHousekeeping, "Whether to log how long each section of housekeepings that take longer than 100ms (or half the housekeeping interval) took")
+	flag.IntVar(&managerConfig.HousekeepingOverrunThreshold, "housekeeping_overrun_threshold", managerConfig.HousekeepingOverrunThreshold, "Number of consecutive housekeepings taking longer than the housekeeping interval after which collection for a container is degraded. Zero disables degradation")
+	flag.IntVar(&managerConfig.HousekeepingWatchdogIntervals, "housekeeping_watchdog_intervals", managerConfig.HousekeepingWatchdogIntervals, "Number of housekeeping intervals a single housekeeping of a container may run for before it is considered stuck and the container's housekeeping is restarted. Zero disables the watchdog")
+	flag.IntVar(&managerConfig.HousekeepingWatchdogMaxRestarts, "housekeeping_watchdog_max_restarts", managerConfig.HousekeepingWatchdogMaxRestarts, "Maximum number of times the housekeeping of a container is restarted within --housekeeping_watchdog_restart_window")
+	flag.DurationVar(&managerConfig.HousekeepingWatchdogRestartWindow, "housekeeping_watchdog_restart_window", managerConfig.HousekeepingWatchdogRestartWindow, "Window over which restarts of the housekeeping of a container are counted against --housekeeping_watchdog_max_restarts")
+	flag.DurationVar(&managerConfig.GlobalHousekeepingInterval, "global_housekeeping_interval", managerConfig.GlobalHousekeepingInterval, "Interval between global housekeepings")
+	flag.BoolVar(&managerConfig.LogCadvisorUsage, "log_cadvisor_usage", managerConfig.LogCadvisorUsage, "Whether to log the usage of the cAdvisor container")
+	flag.BoolVar(&managerConfig.MonitorSystemServices, "monitor_system_services", managerConfig.MonitorSystemServices, "Whether to monitor the Docker daemon and cAdvisor processes as containers under /services")
+	flag.StringVar(&managerConfig.GroupByLabel, "group_by_label", managerConfig.GroupByLabel, "Label whose value groups the containers (e.g.: io.kubernetes.pod.name). Each group is reported as the container /groups/<value>, with the summed usage of the containers with that value. Empty disables grouping")
+	flag.StringVar(&managerConfig.MachineIdFiles, "machine_id_file", managerConfig.MachineIdFiles, "Comma-separated list of files to check for machine-id. Use the first one that exists.")
+	flag.StringVar(&managerConfig.RootFs, "rootfs", managerConfig.RootFs, "Path the root filesystem of the host is mounted at, e.g.: /rootfs when cAdvisor runs in a container with --volume=/:/rootfs:ro. The OS image of the machine is read from there")
+	flag.DurationVar(&managerConfig.CgroupRemountCheckInterval, "cgroup_remount_check_interval", managerConfig.CgroupRemountCheckInterval, "Interval between checks of whether the cgroup hierarchies were remounted, after which the cgroup paths of the containers are re-resolved. Zero disables the checks")
+	flag.IntVar(&managerConfig.ContainerDiffWindow, "container_diff_window", managerConfig.ContainerDiffWindow, "Number of container additions, deletions and spec changes kept to answer differential container listings. Clients behind by more changes than that must list all containers again")
+	flag.IntVar(&managerConfig.ContainerCreationRetries, "container_creation_retries", managerConfig.ContainerCreationRetries, "Number of times to retry creating a container that failed to be created before giving up on it until it is detected again")
+	flag.DurationVar(&managerConfig.ContainerCreationBackoff, "container_creation_backoff", managerConfig.ContainerCreationBackoff, "Delay before retrying to create a container that failed to be created. Doubles after each failed retry")
+	flag.DurationVar(&managerConfig.DockerRestartGrace, "docker_restart_grace", managerConfig.DockerRestartGrace, "Time a Docker container that disappeared (e.g.: while the Docker daemon restarts) is kept, with its history, before it is considered deleted. It resumes being tracked if it reappears with the same ID. Zero deletes such containers immediately")
+	flag.DurationVar(&managerConfig.ShortLivedContainerThreshold, "short_lived_container_threshold", managerConfig.ShortLivedContainerThreshold, "Age under which deleted containers are considered short-lived: their stats are written to the storage backend as those of an aggregate of the short-lived containers of their parent (e.g.: /docker/_short_lived) rather than their own. Zero writes the stats of all containers as their own")
+	flag.IntVar(&managerConfig.DeletedContainersCount, "deleted_containers_count", managerConfig.DeletedContainersCount, "Number of deleted containers whose reference, spec, collection status and last stats are kept, e.g.: to investigate a container killed for running out of memory. Zero forgets deleted containers")
+	flag.DurationVar(&managerConfig.DeletedContainersAge, "deleted_containers_age", managerConfig.DeletedContainersAge, "Time deleted containers are kept after their deletion, see --deleted_containers_count")
+	flag.Float64Var(&managerConfig.FreshnessStaleIntervals, "freshness_stale_intervals", managerConfig.FreshnessStaleIntervals, "Number of housekeeping intervals of a container after which its newest stats are reported as stale rather than fresh")
+	flag.Float64Var(&managerConfig.FreshnessDeadIntervals, "freshness_dead_intervals", managerConfig.FreshnessDeadIntervals, "Number of housekeeping intervals of a container after which its newest stats are reported as dead rather than stale")
+	flag.BoolVar(&managerConfig.EnableCpuSampling, "enable_cpu_sampling", managerConfig.EnableCpuSampling, "Whether to attribute the CPU usage of each container to the names of its processes by sampling their CPU times. The ten busiest process names are reported, along with the numbers of processes in uninterruptible sleep (D state) and of zombies, and the context switches of the processes")
+	flag.DurationVar(&managerConfig.CpuSamplingInterval, "cpu_sampling_interval", managerConfig.CpuSamplingInterval, "Interval between samples of the CPU times of the processes of a container when --enable_cpu_sampling is set")
+	flag.IntVar(&managerConfig.CpuSamplingMaxProcesses, "cpu_sampling_max_processes", managerConfig.CpuSamplingMaxProcesses, "Maximum number of processes of a container sampled when --enable_cpu_sampling is set, those with the lowest PIDs are. Zero is no limit")
+	flag.Float64Var(&managerConfig.MemoryPressureThreshold, "memory_pressure_threshold", managerConfig.MemoryPressureThreshold, "Thrashing score (memory scanned for reclaim per second, relative to the memory usage) of a container above which its memory is considered under pressure. Zero disables memory pressure events")
+	flag.DurationVar(&managerConfig.MemoryPressureDuration, "memory_pressure_duration", managerConfig.MemoryPressureDuration, "How long the thrashing score of a container must stay above --memory_pressure_threshold for a memory pressure event to be emitted")
+	flag.BoolVar(&managerConfig.SelfCheck, "self_check", managerConfig.SelfCheck, "Whether to periodically re-read the cgroup files of each container through a separate minimal reader and compare them with the stats collected at the same time, counting the discrepancies in the collection status of the container. Meant for integration runs")
+	flag.DurationVar(&managerConfig.SelfCheckInterval, "self_check_interval", managerConfig.SelfCheckInterval, "Interval between self checks of the stats of a container when --self_check is set")
+	flag.Float64Var(&managerConfig.SelfCheckTolerance, "self_check_tolerance", managerConfig.SelfCheckTolerance, "Relative amount by which a collected value may fall outside of the values read before and after its collection before the self check counts a discrepancy (e.g.: 0.01 for 1%)")
+}
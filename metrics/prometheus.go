@@ -58,6 +58,10 @@ type containerMetric struct {
 	valueType   prometheus.ValueType
 	extraLabels []string
 	getValues   func(s *info.ContainerStats) metricValues
+
+	// Section of the stats the metric is computed from, if the metric is not
+	// exported when that section failed to be collected.
+	section string
 }
 
 func (cm *containerMetric) desc() *prometheus.Desc {
@@ -90,6 +94,7 @@ func NewPrometheusCollector(infoProvider subcontainersInfoProvider) *PrometheusC
 				},
 			}, {
 				name:      "container_cpu_user_seconds_total",
+				section:   info.StatsSectionCpu,
 				help:      "Cumulative user cpu time consumed in seconds.",
 				valueType: prometheus.CounterValue,
 				getValues: func(s *info.ContainerStats) metricValues {
@@ -97,6 +102,7 @@ func NewPrometheusCollector(infoProvider subcontainersInfoProvider) *PrometheusC
 				},
 			}, {
 				name:      "container_cpu_system_seconds_total",
+				section:   info.StatsSectionCpu,
 				help:      "Cumulative system cpu time consumed in seconds.",
 				valueType: prometheus.CounterValue,
 				getValues: func(s *info.ContainerStats) metricValues {
@@ -104,6 +110,7 @@ func NewPrometheusCollector(infoProvider subcontainersInfoProvider) *PrometheusC
 				},
 			}, {
 				name:        "container_cpu_usage_seconds_total",
+				section:     info.StatsSectionCpu,
 				help:        "Cumulative cpu time consumed per cpu in seconds.",
 				valueType:   prometheus.CounterValue,
 				extraLabels: []string{"cpu"},
@@ -119,6 +126,7 @@ func NewPrometheusCollector(infoProvider subcontainersInfoProvider) *PrometheusC
 				},
 			}, {
 				name:      "container_memory_usage_bytes",
+				section:   info.StatsSectionMemory,
 				help:      "Current memory usage in bytes.",
 				valueType: prometheus.GaugeValue,
 				getValues: func(s *info.ContainerStats) metricValues {
@@ -126,6 +134,7 @@ func NewPrometheusCollector(infoProvider subcontainersInfoProvider) *PrometheusC
 				},
 			}, {
 				name:      "container_memory_working_set_bytes",
+				section:   info.StatsSectionMemory,
 				help:      "Current working set in bytes.",
 				valueType: prometheus.GaugeValue,
 				getValues: func(s *info.ContainerStats) metricValues {
@@ -133,6 +142,7 @@ func NewPrometheusCollector(infoProvider subcontainersInfoProvider) *PrometheusC
 				},
 			}, {
 				name:        "container_memory_failures_total",
+				section:     info.StatsSectionMemory,
 				help:        "Cumulative count of memory allocation failures.",
 				valueType:   prometheus.CounterValue,
 				extraLabels: []string{"type", "scope"},
@@ -288,6 +298,7 @@ func NewPrometheusCollector(infoProvider subcontainersInfoProvider) *PrometheusC
 				},
 			}, {
 				name:      "container_network_receive_bytes_total",
+				section:   info.StatsSectionNetwork,
 				help:      "Cumulative count of bytes received",
 				valueType: prometheus.CounterValue,
 				getValues: func(s *info.ContainerStats) metricValues {
@@ -295,6 +306,7 @@ func NewPrometheusCollector(infoProvider subcontainersInfoProvider) *PrometheusC
 				},
 			}, {
 				name:      "container_network_receive_packets_total",
+				section:   info.StatsSectionNetwork,
 				help:      "Cumulative count of packets received",
 				valueType: prometheus.CounterValue,
 				getValues: func(s *info.ContainerStats) metricValues {
@@ -302,6 +314,7 @@ func NewPrometheusCollector(infoProvider subcontainersInfoProvider) *PrometheusC
 				},
 			}, {
 				name:      "container_network_receive_packets_dropped_total",
+				section:   info.StatsSectionNetwork,
 				help:      "Cumulative count of packets dropped while receiving",
 				valueType: prometheus.CounterValue,
 				getValues: func(s *info.ContainerStats) metricValues {
@@ -309,6 +322,7 @@ func NewPrometheusCollector(infoProvider subcontainersInfoProvider) *PrometheusC
 				},
 			}, {
 				name:      "container_network_receive_errors_total",
+				section:   info.StatsSectionNetwork,
 				help:      "Cumulative count of errors encountered while receiving",
 				valueType: prometheus.CounterValue,
 				getValues: func(s *info.ContainerStats) metricValues {
@@ -316,6 +330,7 @@ func NewPrometheusCollector(infoProvider subcontainersInfoProvider) *PrometheusC
 				},
 			}, {
 				name:      "container_network_transmit_bytes_total",
+				section:   info.StatsSectionNetwork,
 				help:      "Cumulative count of bytes transmitted",
 				valueType: prometheus.CounterValue,
 				getValues: func(s *info.ContainerStats) metricValues {
@@ -323,6 +338,7 @@ func NewPrometheusCollector(infoProvider subcontainersInfoProvider) *PrometheusC
 				},
 			}, {
 				name:      "container_network_transmit_packets_total",
+				section:   info.StatsSectionNetwork,
 				help:      "Cumulative count of packets transmitted",
 				valueType: prometheus.CounterValue,
 				getValues: func(s *info.ContainerStats) metricValues {
@@ -330,6 +346,7 @@ func NewPrometheusCollector(infoProvider subcontainersInfoProvider) *PrometheusC
 				},
 			}, {
 				name:      "container_network_transmit_packets_dropped_total",
+				section:   info.StatsSectionNetwork,
 				help:      "Cumulative count of packets dropped while transmitting",
 				valueType: prometheus.CounterValue,
 				getValues: func(s *info.ContainerStats) metricValues {
@@ -337,6 +354,7 @@ func NewPrometheusCollector(infoProvider subcontainersInfoProvider) *PrometheusC
 				},
 			}, {
 				name:      "container_network_transmit_errors_total",
+				section:   info.StatsSectionNetwork,
 				help:      "Cumulative count of errors encountered while transmitting",
 				valueType: prometheus.CounterValue,
 				getValues: func(s *info.ContainerStats) metricValues {
@@ -404,6 +422,9 @@ func (c *PrometheusCollector) Collect(ch chan<- prometheus.Metric) {
 		stats := container.Stats[0]
 
 		for _, cm := range c.containerMetrics {
+			if cm.section != "" && stats.Missing(cm.section) {
+				continue
+			}
 			desc := cm.desc()
 			for _, metricValue := range cm.getValues(stats) {
 				ch <- prometheus.MustNewConstMetric(desc, cm.valueType, float64(metricValue.value), append([]string{name, id}, metricValue.labels...)...)
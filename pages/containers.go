@@ -173,6 +173,13 @@ func toMemoryPercent(usage uint64, spec *info.ContainerSpec, machine *info.Machi
 	return int((usage * 100) / limit)
 }
 
+// Returns the escaped link to the page of the specified container, whose name
+// may contain characters that are not valid in a URL path (e.g.: spaces).
+func containerLink(name string) string {
+	u := url.URL{Path: path.Join(ContainersPage, name)}
+	return u.String()
+}
+
 func serveContainersPage(m manager.Manager, w http.ResponseWriter, u *url.URL) error {
 	start := time.Now()
 
@@ -209,7 +216,7 @@ func serveContainersPage(m manager.Manager, w http.ResponseWriter, u *url.URL) e
 		}
 		parentContainers = append(parentContainers, link{
 			Text: pathParts[i],
-			Link: path.Join(ContainersPage, path.Join(pathParts[1:i+1]...)),
+			Link: containerLink(path.Join(pathParts[1 : i+1]...)),
 		})
 	}
 
@@ -218,7 +225,7 @@ func serveContainersPage(m manager.Manager, w http.ResponseWriter, u *url.URL) e
 	for _, sub := range cont.Subcontainers {
 		subcontainerLinks = append(subcontainerLinks, link{
 			Text: getContainerDisplayName(sub),
-			Link: path.Join(ContainersPage, sub.Name),
+			Link: containerLink(sub.Name),
 		})
 	}
 
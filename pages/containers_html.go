@@ -50,6 +50,7 @@ const containersHtmlTemplate = `
       {{if .IsRoot}}
       <div class="col-sm-12">
         <h4><a href="/docker">Docker Containers</a></h4>
+        <h4><a href="/live/">Live Graphs</a></h4>
       </div>
       {{end}}
       {{if .Subcontainers}}
@@ -0,0 +1,155 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Pages for /live/
+package pages
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/manager"
+)
+
+// Index of the containers, whose graphs are at LiveGraphsPage.
+const LivePage = "/live/"
+
+const LiveGraphsPage = "/live/graphs/"
+
+var liveTemplate *template.Template
+
+func init() {
+	liveTemplate = template.New("liveTemplate")
+	_, err := liveTemplate.Parse(liveHtmlTemplate)
+	if err != nil {
+		glog.Fatalf("Failed to parse template: %s", err)
+	}
+}
+
+type liveContainer struct {
+	Name    string
+	Aliases []string
+	// Depth of the container in the hierarchy, the root is 0.
+	Depth int
+	Link  string
+}
+
+type liveIndexData struct {
+	Containers []liveContainer
+}
+
+type liveGraphsData struct {
+	DisplayName   string
+	ContainerName string
+	Parent        link
+	// Where the stats are polled from.
+	StatsUrl string
+	// How often the stats are polled, in milliseconds.
+	IntervalMs int64
+	HasCpu     bool
+	HasMemory  bool
+	HasNetwork bool
+}
+
+// Returns the escaped link to the graphs of the specified container.
+func liveGraphsLink(name string) string {
+	u := url.URL{Path: path.Join(LiveGraphsPage, name)}
+	if name == "/" {
+		u.Path = LiveGraphsPage
+	}
+	return u.String()
+}
+
+func containerDepth(name string) int {
+	if name == "/" {
+		return 0
+	}
+	return strings.Count(name, "/")
+}
+
+func serveLiveIndex(m manager.Manager, w http.ResponseWriter) error {
+	conts, err := m.SubcontainersInfo("/", &info.ContainerInfoRequest{NumStats: 0})
+	if err != nil {
+		return fmt.Errorf("failed to list containers: %v", err)
+	}
+	containers := make([]liveContainer, 0, len(conts))
+	for _, cont := range conts {
+		containers = append(containers, liveContainer{
+			Name:    cont.Name,
+			Aliases: cont.Aliases,
+			Depth:   containerDepth(cont.Name),
+			Link:    liveGraphsLink(cont.Name),
+		})
+	}
+	// Sorting by name lists children right after their parent.
+	sort.Sort(byLiveContainerName(containers))
+	return liveTemplate.ExecuteTemplate(w, "index", &liveIndexData{Containers: containers})
+}
+
+type byLiveContainerName []liveContainer
+
+func (self byLiveContainerName) Len() int           { return len(self) }
+func (self byLiveContainerName) Swap(i, j int)      { self[i], self[j] = self[j], self[i] }
+func (self byLiveContainerName) Less(i, j int) bool { return self[i].Name < self[j].Name }
+
+func serveLiveGraphs(m manager.Manager, w http.ResponseWriter, containerName string) error {
+	cont, err := m.GetContainerInfo(containerName, &info.ContainerInfoRequest{NumStats: 0})
+	if err != nil {
+		// The container may have gone away since the index was loaded.
+		w.WriteHeader(http.StatusNotFound)
+		return liveTemplate.ExecuteTemplate(w, "missing", containerName)
+	}
+
+	parent := link{Text: "All containers", Link: LivePage}
+	if cont.Name != "/" {
+		parentName := path.Dir(cont.Name)
+		parent = link{Text: parentName, Link: liveGraphsLink(parentName)}
+	}
+	// Poll as often as the container is housekept.
+	interval := time.Second
+	if cont.Freshness != nil && cont.Freshness.HousekeepingInterval > 0 {
+		interval = cont.Freshness.HousekeepingInterval
+	}
+	data := &liveGraphsData{
+		DisplayName:   getContainerDisplayName(cont.ContainerReference),
+		ContainerName: cont.Name,
+		Parent:        parent,
+		StatsUrl:      (&url.URL{Path: "/api/v2.0/stats" + cont.Name}).String(),
+		IntervalMs:    int64(interval / time.Millisecond),
+		HasCpu:        cont.Spec.HasCpu,
+		HasMemory:     cont.Spec.HasMemory,
+		HasNetwork:    cont.Spec.HasNetwork,
+	}
+	return liveTemplate.ExecuteTemplate(w, "graphs", data)
+}
+
+func serveLivePage(m manager.Manager, w http.ResponseWriter, u *url.URL) error {
+	if strings.HasPrefix(u.Path, LiveGraphsPage) {
+		// The container name is the path after the handler
+		return serveLiveGraphs(m, w, u.Path[len(LiveGraphsPage)-1:])
+	}
+	if u.Path != LivePage {
+		http.Error(w, fmt.Sprintf("unknown page %q", u.Path), http.StatusNotFound)
+		return nil
+	}
+	return serveLiveIndex(m, w)
+}
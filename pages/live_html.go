@@ -0,0 +1,94 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pages
+
+const liveHtmlTemplate = `
+{{define "header"}}
+<html>
+  <head>
+    <title>cAdvisor - {{.}}</title>
+    <link rel="stylesheet" href="/static/bootstrap-3.1.1.min.css">
+    <link rel="stylesheet" href="/static/live.css">
+  </head>
+  <body>
+    <div class="container">
+{{end}}
+
+{{define "footer"}}
+    </div>
+  </body>
+</html>
+{{end}}
+
+{{define "index"}}
+{{template "header" "Containers"}}
+      <div class="page-header">
+        <h1>Containers</h1>
+      </div>
+      <div class="list-group">
+        {{range .Containers}}
+        <a href="{{.Link}}" class="list-group-item" style="margin-left: {{.Depth}}em">
+          {{.Name}}
+          {{range .Aliases}}<span class="label label-default">{{.}}</span> {{end}}
+        </a>
+        {{end}}
+      </div>
+{{template "footer"}}
+{{end}}
+
+{{define "graphs"}}
+{{template "header" .DisplayName}}
+      <div class="page-header">
+        <h1>{{.DisplayName}}</h1>
+      </div>
+      <ol class="breadcrumb">
+        <li><a href="{{.Parent.Link}}">{{.Parent.Text}}</a></li>
+        <li class="active">{{.ContainerName}}</li>
+      </ol>
+      <div id="live-status" class="alert alert-warning hidden"></div>
+      <div id="live-graphs" data-stats-url="{{.StatsUrl}}" data-interval-ms="{{.IntervalMs}}">
+        {{if .HasCpu}}
+        <div class="sparkline">
+          <h4>CPU <small class="value" data-metric="cpu"></small></h4>
+          <canvas data-metric="cpu" width="600" height="60"></canvas>
+        </div>
+        {{end}}
+        {{if .HasMemory}}
+        <div class="sparkline">
+          <h4>Memory <small class="value" data-metric="memory"></small></h4>
+          <canvas data-metric="memory" width="600" height="60"></canvas>
+        </div>
+        {{end}}
+        {{if .HasNetwork}}
+        <div class="sparkline">
+          <h4>Network <small class="value" data-metric="network"></small></h4>
+          <canvas data-metric="network" width="600" height="60"></canvas>
+        </div>
+        {{end}}
+      </div>
+      <script type="text/javascript" src="/static/live.js"></script>
+{{template "footer"}}
+{{end}}
+
+{{define "missing"}}
+{{template "header" .}}
+      <div class="page-header">
+        <h1>{{.}}</h1>
+      </div>
+      <div class="alert alert-warning">Container {{.}} does not exist, it may have been deleted.</div>
+      <a href="/live/">All containers</a>
+{{template "footer"}}
+{{end}}
+`
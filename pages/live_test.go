@@ -0,0 +1,134 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pages
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Manager with a fixed set of containers.
+type containersManager struct {
+	manager.Manager
+	containers map[string]*info.ContainerInfo
+}
+
+func newContainersManager(conts ...info.ContainerInfo) *containersManager {
+	m := &containersManager{
+		containers: make(map[string]*info.ContainerInfo, len(conts)),
+	}
+	for i := range conts {
+		m.containers[conts[i].Name] = &conts[i]
+	}
+	return m
+}
+
+func (self *containersManager) GetContainerInfo(name string, query *info.ContainerInfoRequest) (*info.ContainerInfo, error) {
+	cont, ok := self.containers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown container %q", name)
+	}
+	return cont, nil
+}
+
+func (self *containersManager) SubcontainersInfo(containerName string, query *info.ContainerInfoRequest) ([]*info.ContainerInfo, error) {
+	var conts []*info.ContainerInfo
+	for _, cont := range self.containers {
+		if cont.Name == containerName || strings.HasPrefix(cont.Name, strings.TrimSuffix(containerName, "/")+"/") {
+			conts = append(conts, cont)
+		}
+	}
+	return conts, nil
+}
+
+func seededManager() *containersManager {
+	return newContainersManager(
+		info.ContainerInfo{
+			ContainerReference: info.ContainerReference{Name: "/"},
+			Spec:               info.ContainerSpec{HasCpu: true, HasMemory: true, HasNetwork: true},
+		},
+		info.ContainerInfo{
+			ContainerReference: info.ContainerReference{Name: "/docker"},
+			Spec:               info.ContainerSpec{HasCpu: true, HasMemory: true},
+		},
+		info.ContainerInfo{
+			ContainerReference: info.ContainerReference{
+				Name:    "/docker/abcdef",
+				Aliases: []string{"web", "abcdef"},
+			},
+			Spec: info.ContainerSpec{HasCpu: true},
+		},
+	)
+}
+
+func getLivePage(t *testing.T, m manager.Manager, path string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	require.Nil(t, RegisterHandlersBasic(mux, m, nil))
+	r, err := http.NewRequest("GET", "http://localhost:8080"+path, nil)
+	require.Nil(t, err)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	return w
+}
+
+func TestLiveIndexListsContainerTree(t *testing.T) {
+	w := getLivePage(t, seededManager(), LivePage)
+	require.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+
+	// Parents are listed before their children.
+	root := strings.Index(body, `href="/live/graphs/"`)
+	docker := strings.Index(body, `href="/live/graphs/docker"`)
+	web := strings.Index(body, `href="/live/graphs/docker/abcdef"`)
+	assert.True(t, root >= 0 && docker > root && web > docker, "expected the containers in tree order, got:\n%s", body)
+	assert.Contains(t, body, `<span class="label label-default">web</span>`)
+	assert.NotContains(t, body, "googleapis")
+}
+
+func TestLiveGraphsRender(t *testing.T) {
+	w := getLivePage(t, seededManager(), LiveGraphsPage+"docker/abcdef")
+	require.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, "web (/docker/abcdef)")
+	assert.Contains(t, body, `data-stats-url="/api/v2.0/stats/docker/abcdef"`)
+	assert.Contains(t, body, `href="/live/graphs/docker"`)
+	assert.Contains(t, body, `<canvas data-metric="cpu"`)
+	assert.NotContains(t, body, `<canvas data-metric="memory"`)
+	assert.Contains(t, body, `src="/static/live.js"`)
+
+	w = getLivePage(t, seededManager(), LiveGraphsPage)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `data-stats-url="/api/v2.0/stats/"`)
+	assert.Contains(t, w.Body.String(), `<canvas data-metric="network"`)
+}
+
+func TestLiveGraphsOfMissingContainer(t *testing.T) {
+	w := getLivePage(t, seededManager(), LiveGraphsPage+"docker/deleted")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "/docker/deleted does not exist")
+}
+
+func TestLiveUnknownPage(t *testing.T) {
+	w := getLivePage(t, seededManager(), LivePage+"other")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
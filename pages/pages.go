@@ -97,15 +97,35 @@ func dockerHandler(containerManager manager.Manager) auth.AuthenticatedHandlerFu
 	}
 }
 
+func liveHandlerNoAuth(containerManager manager.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := serveLivePage(containerManager, w, r.URL)
+		if err != nil {
+			fmt.Fprintf(w, "%s", err)
+		}
+	}
+}
+
+func liveHandler(containerManager manager.Manager) auth.AuthenticatedHandlerFunc {
+	return func(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+		err := serveLivePage(containerManager, w, r.URL)
+		if err != nil {
+			fmt.Fprintf(w, "%s", err)
+		}
+	}
+}
+
 // Register http handlers
 func RegisterHandlersDigest(mux httpMux.Mux, containerManager manager.Manager, authenticator *auth.DigestAuth) error {
 	// Register the handler for the containers page.
 	if authenticator != nil {
-		mux.HandleFunc(ContainersPage, authenticator.Wrap(containerHandler(containerManager)))
-		mux.HandleFunc(DockerPage, authenticator.Wrap(dockerHandler(containerManager)))
+		mux.Handle(ContainersPage, httpMux.SafeReads(authenticator.Wrap(containerHandler(containerManager))))
+		mux.Handle(DockerPage, httpMux.SafeReads(authenticator.Wrap(dockerHandler(containerManager))))
+		mux.Handle(LivePage, httpMux.SafeReads(authenticator.Wrap(liveHandler(containerManager))))
 	} else {
-		mux.HandleFunc(ContainersPage, containerHandlerNoAuth(containerManager))
-		mux.HandleFunc(DockerPage, dockerHandlerNoAuth(containerManager))
+		mux.Handle(ContainersPage, httpMux.SafeReads(containerHandlerNoAuth(containerManager)))
+		mux.Handle(DockerPage, httpMux.SafeReads(dockerHandlerNoAuth(containerManager)))
+		mux.Handle(LivePage, httpMux.SafeReads(liveHandlerNoAuth(containerManager)))
 	}
 	return nil
 }
@@ -113,11 +133,13 @@ func RegisterHandlersDigest(mux httpMux.Mux, containerManager manager.Manager, a
 func RegisterHandlersBasic(mux httpMux.Mux, containerManager manager.Manager, authenticator *auth.BasicAuth) error {
 	// Register the handler for the containers and docker age.
 	if authenticator != nil {
-		mux.HandleFunc(ContainersPage, authenticator.Wrap(containerHandler(containerManager)))
-		mux.HandleFunc(DockerPage, authenticator.Wrap(dockerHandler(containerManager)))
+		mux.Handle(ContainersPage, httpMux.SafeReads(authenticator.Wrap(containerHandler(containerManager))))
+		mux.Handle(DockerPage, httpMux.SafeReads(authenticator.Wrap(dockerHandler(containerManager))))
+		mux.Handle(LivePage, httpMux.SafeReads(authenticator.Wrap(liveHandler(containerManager))))
 	} else {
-		mux.HandleFunc(ContainersPage, containerHandlerNoAuth(containerManager))
-		mux.HandleFunc(DockerPage, dockerHandlerNoAuth(containerManager))
+		mux.Handle(ContainersPage, httpMux.SafeReads(containerHandlerNoAuth(containerManager)))
+		mux.Handle(DockerPage, httpMux.SafeReads(dockerHandlerNoAuth(containerManager)))
+		mux.Handle(LivePage, httpMux.SafeReads(liveHandlerNoAuth(containerManager)))
 	}
 	return nil
 }
@@ -1519,7 +1519,7 @@ function drawGauges(elementId, gauges) {
 
 // Get the machine info.
 function getMachineInfo(callback) {
-	$.getJSON("/api/v1.0/machine", function(data) {
+	$.getJSON("/api/v1.3/machine", function(data) {
 		callback(data);
 	});
 }
@@ -1532,7 +1532,9 @@ function getStats(containerName, callback) {
 		"num_stats": 60,
 		"num_samples": 0
 	});
-	$.post("/api/v1.0/containers" + containerName, request, function(data) {
+	// Container names may contain characters that must be escaped in a URL (e.g.: spaces).
+	var escapedName = containerName.split("/").map(encodeURIComponent).join("/");
+	$.post("/api/v1.3/containers" + escapedName, request, function(data) {
 		callback(data);
 	}, "json");
 }
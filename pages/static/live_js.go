@@ -0,0 +1,181 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package static
+
+// Polls the stats of the container of the live graphs page and draws them as
+// sparklines. Kept dependency-free so that the page works without access to
+// the internet.
+const liveJs = `
+(function() {
+  var graphs = document.getElementById("live-graphs");
+  if (!graphs) {
+    return;
+  }
+  var statsUrl = graphs.getAttribute("data-stats-url");
+  var intervalMs = parseInt(graphs.getAttribute("data-interval-ms"), 10) || 1000;
+  var maxSamples = 61;
+  var samples = [];
+
+  function showStatus(text) {
+    var status = document.getElementById("live-status");
+    status.textContent = text;
+    status.className = "alert alert-warning";
+  }
+
+  function hideStatus() {
+    document.getElementById("live-status").className = "alert alert-warning hidden";
+  }
+
+  // Browsers only parse up to millisecond precision.
+  function parseTime(timestamp) {
+    return Date.parse(timestamp.replace(/(\.\d{3})\d+/, "$1"));
+  }
+
+  function networkBytes(stats) {
+    var total = 0;
+    var interfaces = stats.network || [];
+    for (var i = 0; i < interfaces.length; i++) {
+      total += (interfaces[i].rx_bytes || 0) + (interfaces[i].tx_bytes || 0);
+    }
+    return total;
+  }
+
+  // Returns the values of the metrics between two consecutive samples.
+  function metricsBetween(prev, cur) {
+    var seconds = (cur.time - prev.time) / 1000;
+    if (seconds <= 0) {
+      return null;
+    }
+    return {
+      cpu: cur.stats.has_cpu ? (cur.stats.cpu.usage.total - prev.stats.cpu.usage.total) / 1e9 / seconds : 0,
+      memory: cur.stats.has_memory ? cur.stats.memory.usage : 0,
+      network: cur.stats.has_network ? (networkBytes(cur.stats) - networkBytes(prev.stats)) / seconds : 0
+    };
+  }
+
+  function humanizeBytes(bytes) {
+    var units = ["B", "KB", "MB", "GB", "TB"];
+    var i = 0;
+    while (bytes >= 1024 && i < units.length - 1) {
+      bytes /= 1024;
+      i++;
+    }
+    return bytes.toFixed(2) + " " + units[i];
+  }
+
+  var formatters = {
+    cpu: function(value) { return value.toFixed(3) + " cores"; },
+    memory: humanizeBytes,
+    network: function(value) { return humanizeBytes(value) + "/s"; }
+  };
+
+  function drawSparkline(canvas, values) {
+    var ctx = canvas.getContext("2d");
+    ctx.clearRect(0, 0, canvas.width, canvas.height);
+    if (values.length < 2) {
+      return;
+    }
+    var max = 0;
+    for (var i = 0; i < values.length; i++) {
+      max = Math.max(max, values[i]);
+    }
+    if (max <= 0) {
+      max = 1;
+    }
+    var step = canvas.width / (maxSamples - 2);
+    var offset = canvas.width - step * (values.length - 1);
+    ctx.strokeStyle = "#428bca";
+    ctx.lineWidth = 1.5;
+    ctx.beginPath();
+    for (var i = 0; i < values.length; i++) {
+      var x = offset + i * step;
+      var y = canvas.height - 1 - (values[i] / max) * (canvas.height - 2);
+      if (i == 0) {
+        ctx.moveTo(x, y);
+      } else {
+        ctx.lineTo(x, y);
+      }
+    }
+    ctx.stroke();
+  }
+
+  function render() {
+    var series = {cpu: [], memory: [], network: []};
+    for (var i = 1; i < samples.length; i++) {
+      var metrics = metricsBetween(samples[i - 1], samples[i]);
+      if (!metrics) {
+        continue;
+      }
+      for (var metric in series) {
+        series[metric].push(metrics[metric]);
+      }
+    }
+    var canvases = graphs.getElementsByTagName("canvas");
+    for (var i = 0; i < canvases.length; i++) {
+      drawSparkline(canvases[i], series[canvases[i].getAttribute("data-metric")]);
+    }
+    var values = graphs.getElementsByTagName("small");
+    for (var i = 0; i < values.length; i++) {
+      var metric = values[i].getAttribute("data-metric");
+      var points = series[metric];
+      values[i].textContent = points.length ? formatters[metric](points[points.length - 1]) : "";
+    }
+  }
+
+  // Adds the samples newer than the ones already seen.
+  function addSamples(stats) {
+    var last = samples.length ? samples[samples.length - 1].time : 0;
+    var added = [];
+    for (var i = 0; i < stats.length; i++) {
+      var time = parseTime(stats[i].timestamp);
+      if (time > last) {
+        added.push({time: time, stats: stats[i]});
+      }
+    }
+    added.sort(function(a, b) { return a.time - b.time; });
+    samples = samples.concat(added).slice(-maxSamples);
+  }
+
+  function poll() {
+    var count = samples.length ? 2 : maxSamples;
+    var request = new XMLHttpRequest();
+    request.open("GET", statsUrl + "?count=" + count);
+    request.onload = function() {
+      if (request.status == 200) {
+        var response = JSON.parse(request.responseText);
+        for (var name in response) {
+          addSamples(response[name]);
+        }
+        hideStatus();
+        render();
+      } else if (request.responseText.indexOf("unknown container") >= 0) {
+        // The container was deleted, there is nothing left to poll.
+        showStatus("The container no longer exists, its graphs are not updated anymore.");
+        return;
+      } else {
+        showStatus("Failed to get the stats of the container, retrying: " + request.responseText);
+      }
+      setTimeout(poll, intervalMs);
+    };
+    request.onerror = function() {
+      showStatus("Failed to reach cAdvisor, retrying.");
+      setTimeout(poll, intervalMs);
+    };
+    request.send();
+  }
+
+  poll();
+})();
+`
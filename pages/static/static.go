@@ -32,6 +32,8 @@ var staticFiles = map[string]string{
 	"jquery-1.10.2.min.js":          jqueryJs,
 	"bootstrap-3.1.1.min.js":        bootstrapJs,
 	"google-jsapi.js":               googleJsapiJs,
+	"live.css":                      liveCss,
+	"live.js":                       liveJs,
 }
 
 func HandleRequest(w http.ResponseWriter, u *url.URL) error {
@@ -0,0 +1,455 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/google/cadvisor/manager"
+	"github.com/google/cadvisor/storage/memory"
+	"github.com/google/cadvisor/utils/clock"
+	"github.com/google/cadvisor/version"
+)
+
+// Implementation of manager.Manager serving the samples of a recording as they
+// become due, in place of collecting stats. Containers appear with their first
+// sample (or that of a subcontainer) and are never deleted.
+type replayManager struct {
+	lock      sync.Mutex
+	recording *Recording
+	// How many times faster than recorded the samples are replayed. 0 replays
+	// them all at once.
+	speed float64
+	clock clock.Clock
+	// Monotonic time the replay started at.
+	start   time.Duration
+	started bool
+	// Index in the recording of the next sample to replay.
+	next int
+
+	// Containers replayed so far by name, without their stats.
+	containers map[string]*info.ContainerInfo
+	// Stats replayed so far. Holds all the samples of a container so that its
+	// whole history can be queried.
+	stats       *memory.InMemoryStorage
+	machineInfo *info.MachineInfo
+	// Revision of the container listing, bumped by each container replayed.
+	revision uint64
+	// There are no events in a recording: watchers are never sent any.
+	events events.EventManager
+}
+
+// Returns a manager replaying the recording at the speed (see
+// --replay_speed) once started. It never collects stats.
+func New(recording *Recording, speed float64) manager.Manager {
+	return newReplayManager(recording, speed, clock.RealClock)
+}
+
+func newReplayManager(recording *Recording, speed float64, clock clock.Clock) *replayManager {
+	maxStats := 1
+	numStats := make(map[string]int)
+	numCores := 0
+	for _, sample := range recording.Samples {
+		numStats[sample.Ref.Name]++
+		if numStats[sample.Ref.Name] > maxStats {
+			maxStats = numStats[sample.Ref.Name]
+		}
+		if len(sample.Stats.Cpu.Usage.PerCpu) > numCores {
+			numCores = len(sample.Stats.Cpu.Usage.PerCpu)
+		}
+	}
+	return &replayManager{
+		recording:  recording,
+		speed:      speed,
+		clock:      clock,
+		containers: make(map[string]*info.ContainerInfo),
+		stats:      memory.New(maxStats, nil),
+		// Only the number of cores can be told from the stats.
+		machineInfo: &info.MachineInfo{NumCores: numCores},
+		events:      events.NewEventManager(),
+	}
+}
+
+func (self *replayManager) Start() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.started {
+		return fmt.Errorf("the replay is already started")
+	}
+	self.start = self.clock.Monotonic()
+	self.started = true
+	// The root is served from the start, even before its first sample.
+	var creationTime time.Time
+	if len(self.recording.Samples) > 0 {
+		creationTime = self.recording.Samples[0].Stats.Timestamp
+	}
+	self.addContainer(info.ContainerReference{Name: "/"}, creationTime)
+	self.advance()
+	return nil
+}
+
+func (self *replayManager) Stop() error {
+	return nil
+}
+
+// Replays the samples which are due: those recorded no later than speed times
+// the time elapsed since the start after the first sample. The lock must be held.
+func (self *replayManager) advance() {
+	if !self.started || self.next == len(self.recording.Samples) {
+		return
+	}
+	var until time.Time
+	if self.speed > 0 {
+		elapsed := float64(self.clock.Monotonic()-self.start) * self.speed
+		until = self.recording.Samples[0].Stats.Timestamp.Add(time.Duration(elapsed))
+	}
+	for ; self.next < len(self.recording.Samples); self.next++ {
+		sample := self.recording.Samples[self.next]
+		if self.speed > 0 && sample.Stats.Timestamp.After(until) {
+			return
+		}
+		cinfo := self.addContainer(sample.Ref, sample.Stats.Timestamp)
+		cinfo.ContainerReference = sample.Ref
+		updateSpec(&cinfo.Spec, sample.Stats)
+		self.stats.AddStats(sample.Ref, sample.Stats)
+	}
+}
+
+// Returns the container, adding it and its missing ancestors as created at
+// the time. The lock must be held.
+func (self *replayManager) addContainer(ref info.ContainerReference, creationTime time.Time) *info.ContainerInfo {
+	if cinfo, ok := self.containers[ref.Name]; ok {
+		return cinfo
+	}
+	if ref.Name != "/" {
+		self.addContainer(info.ContainerReference{Name: path.Dir(ref.Name)}, creationTime)
+	}
+	cinfo := &info.ContainerInfo{
+		ContainerReference: ref,
+		Spec: info.ContainerSpec{
+			CreationTime: creationTime,
+		},
+	}
+	self.containers[ref.Name] = cinfo
+	self.revision++
+	return cinfo
+}
+
+// Marks the resources the stats have values for as isolated by the spec.
+func updateSpec(spec *info.ContainerSpec, stats *info.ContainerStats) {
+	if stats.Cpu.Usage.Total > 0 {
+		spec.HasCpu = true
+	}
+	if stats.Memory.Usage > 0 {
+		spec.HasMemory = true
+	}
+	if len(stats.Network.Interfaces) > 0 || stats.Network.RxBytes > 0 || stats.Network.TxBytes > 0 {
+		spec.HasNetwork = true
+	}
+	if len(stats.Filesystem) > 0 {
+		spec.HasFilesystem = true
+	}
+	if len(stats.DiskIo.IoServiceBytes) > 0 || len(stats.DiskIo.IoServiced) > 0 {
+		spec.HasDiskIo = true
+	}
+	if stats.Pressure != nil {
+		spec.HasPressure = true
+	}
+	if stats.Pids != nil {
+		spec.HasPids = true
+	}
+	if stats.Process != nil {
+		spec.HasProcess = true
+	}
+}
+
+func (self *replayManager) GetContainerInfo(containerName string, query *info.ContainerInfoRequest) (*info.ContainerInfo, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.advance()
+	cinfo, ok := self.containers[containerName]
+	if !ok {
+		return nil, fmt.Errorf("unknown container %q", containerName)
+	}
+	return self.withStats(cinfo, query), nil
+}
+
+func (self *replayManager) GetContainerAncestry(containerName string) ([]info.ContainerAncestor, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.advance()
+	return manager.GetAncestry(containerName, func(name string) (*info.ContainerInfo, error) {
+		cinfo, ok := self.containers[name]
+		if !ok {
+			return nil, nil
+		}
+		return self.withStats(cinfo, &info.ContainerInfoRequest{NumStats: 1}), nil
+	})
+}
+
+func (self *replayManager) CollectContainerInfo(containerName string, query *info.ContainerInfoRequest) (*info.ContainerInfo, error) {
+	return nil, fmt.Errorf("unable to collect the stats of %q: stats are replayed, not collected", containerName)
+}
+
+// Returns a copy of the container information with the stats that match the
+// query. The lock must be held.
+func (self *replayManager) withStats(cinfo *info.ContainerInfo, query *info.ContainerInfoRequest) *info.ContainerInfo {
+	ret := *cinfo
+	// Ancestors without samples of their own have no stats.
+	ret.Stats, _ = self.stats.RecentStats(cinfo.Name, query.Start, query.End, query.NumStats)
+	return &ret
+}
+
+func (self *replayManager) SubcontainersInfo(containerName string, query *info.ContainerInfoRequest) ([]*info.ContainerInfo, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.advance()
+	names, err := self.subcontainerNames(containerName, nil)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*info.ContainerInfo, 0, len(names))
+	for _, name := range names {
+		ret = append(ret, self.withStats(self.containers[name], query))
+	}
+	return ret, nil
+}
+
+func (self *replayManager) GetSnapshot(containerName string) ([]*info.ContainerInfo, time.Time, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.advance()
+	names, err := self.subcontainerNames(containerName, nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	cut, stats := self.stats.Snapshot(names)
+	ret := make([]*info.ContainerInfo, 0, len(names))
+	for _, name := range names {
+		cinfo := *self.containers[name]
+		if s, ok := stats[name]; ok {
+			cinfo.Stats = []*info.ContainerStats{s}
+		}
+		ret = append(ret, &cinfo)
+	}
+	return ret, cut, nil
+}
+
+func (self *replayManager) SubcontainerNames(containerName string, filter *manager.ContainerFilter) ([]string, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.advance()
+	return self.subcontainerNames(containerName, filter)
+}
+
+// Returns the sorted names of the container and its subcontainers selected by
+// the filter. The lock must be held.
+func (self *replayManager) subcontainerNames(containerName string, filter *manager.ContainerFilter) ([]string, error) {
+	if _, ok := self.containers[containerName]; !ok {
+		return nil, fmt.Errorf("unknown container %q", containerName)
+	}
+	names := make([]string, 0, len(self.containers))
+	for name, cinfo := range self.containers {
+		if containerName != "/" && name != containerName && !strings.HasPrefix(name, containerName+"/") {
+			continue
+		}
+		if filter.Matches(cinfo.ContainerReference, cinfo.Spec) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (self *replayManager) AllDockerContainers(query *info.ContainerInfoRequest) (map[string]info.ContainerInfo, error) {
+	return self.AllNamespacedContainers("docker", query)
+}
+
+func (self *replayManager) DockerContainer(dockerName string, query *info.ContainerInfoRequest) (info.ContainerInfo, error) {
+	return self.NamespacedContainer("docker", dockerName, query)
+}
+
+func (self *replayManager) AllNamespacedContainers(namespace string, query *info.ContainerInfoRequest) (map[string]info.ContainerInfo, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.advance()
+	ret := make(map[string]info.ContainerInfo)
+	for name, cinfo := range self.containers {
+		if cinfo.Namespace == namespace {
+			ret[name] = *self.withStats(cinfo, query)
+		}
+	}
+	return ret, nil
+}
+
+func (self *replayManager) NamespacedContainer(namespace string, name string, query *info.ContainerInfoRequest) (info.ContainerInfo, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.advance()
+	for _, cinfo := range self.containers {
+		if cinfo.Namespace != namespace {
+			continue
+		}
+		for _, alias := range cinfo.Aliases {
+			if alias == name {
+				return *self.withStats(cinfo, query), nil
+			}
+		}
+	}
+	if namespace == "docker" {
+		return info.ContainerInfo{}, fmt.Errorf("unable to find Docker container %q", name)
+	}
+	return info.ContainerInfo{}, fmt.Errorf("unable to find container %q in namespace %q", name, namespace)
+}
+
+// The namespaces are those of the containers replayed so far, mounted where
+// the first of them (by name) lives.
+func (self *replayManager) GetNamespaces() ([]v2.Namespace, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.advance()
+	names := make([]string, 0, len(self.containers))
+	for name := range self.containers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ret := []v2.Namespace{}
+	index := make(map[string]int)
+	for _, name := range names {
+		ns := self.containers[name].Namespace
+		if ns == "" {
+			continue
+		}
+		i, ok := index[ns]
+		if !ok {
+			i = len(ret)
+			index[ns] = i
+			ret = append(ret, v2.Namespace{
+				Name:       ns,
+				MountPoint: path.Dir(name),
+				Factory:    "replay",
+			})
+		}
+		ret[i].NumContainers++
+	}
+	return ret, nil
+}
+
+func (self *replayManager) GetContainerSpec(containerName string) (v2.ContainerSpec, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.advance()
+	cinfo, ok := self.containers[containerName]
+	if !ok {
+		return v2.ContainerSpec{}, fmt.Errorf("unknown container %q", containerName)
+	}
+	return v2.ContainerSpecFromV1(&cinfo.Spec, cinfo.ContainerReference), nil
+}
+
+// Derived stats are computed by housekeeping, which does not run in a replay.
+func (self *replayManager) GetContainerDerivedStats(containerName string) (v2.DerivedStats, error) {
+	if err := self.checkContainer(containerName); err != nil {
+		return v2.DerivedStats{}, err
+	}
+	return v2.DerivedStats{}, nil
+}
+
+func (self *replayManager) GetContainerCollectionStatus(containerName string) (v2.CollectionStatus, error) {
+	if err := self.checkContainer(containerName); err != nil {
+		return v2.CollectionStatus{}, err
+	}
+	return v2.CollectionStatus{}, nil
+}
+
+// There are no processes nor cgroup files to read in a replay.
+func (self *replayManager) GetContainerDiagnostics(containerName string) (v2.ContainerDiagnostics, error) {
+	if err := self.checkContainer(containerName); err != nil {
+		return v2.ContainerDiagnostics{}, err
+	}
+	return v2.ContainerDiagnostics{
+		Processes:   []v2.ProcessInfo{},
+		CgroupFiles: map[string]string{},
+	}, nil
+}
+
+// Returns an error if the container has not been replayed.
+func (self *replayManager) checkContainer(containerName string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.advance()
+	if _, ok := self.containers[containerName]; !ok {
+		return fmt.Errorf("unknown container %q", containerName)
+	}
+	return nil
+}
+
+func (self *replayManager) GetDeletedContainers() ([]v2.DeletedContainer, error) {
+	return []v2.DeletedContainer{}, nil
+}
+
+func (self *replayManager) GetDeletedContainer(containerName string) (v2.DeletedContainer, error) {
+	return v2.DeletedContainer{}, fmt.Errorf("unknown deleted container %q", containerName)
+}
+
+func (self *replayManager) GetMachineInfo() (*info.MachineInfo, error) {
+	return self.machineInfo, nil
+}
+
+func (self *replayManager) GetVersionInfo() (*info.VersionInfo, error) {
+	return &info.VersionInfo{
+		CadvisorVersion: version.VERSION,
+	}, nil
+}
+
+func (self *replayManager) GetFsInfo(label string) ([]v2.FsInfo, error) {
+	return []v2.FsInfo{}, nil
+}
+
+func (self *replayManager) WatchForEvents(request *events.Request) (*events.EventChannel, error) {
+	return self.events.WatchEvents(request)
+}
+
+func (self *replayManager) CloseEventChannel(watchId int) {
+	self.events.StopWatch(watchId)
+}
+
+func (self *replayManager) GetPastEvents(request *events.Request) (events.EventSlice, error) {
+	return self.events.GetEvents(request)
+}
+
+func (self *replayManager) GetFailedContainers() ([]v2.FailedContainer, error) {
+	return []v2.FailedContainer{}, nil
+}
+
+// The changes to the containers are not kept: there are none since the
+// current revision and all others require a resync.
+func (self *replayManager) GetContainerDiff(since uint64) (v2.ContainerDiff, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.advance()
+	return v2.ContainerDiff{
+		Revision:       self.revision,
+		ResyncRequired: since != self.revision,
+	}, nil
+}
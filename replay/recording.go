@@ -0,0 +1,116 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package replay serves stats recorded by the file storage driver as if they
+// were collected by a live cAdvisor, for developing against realistic data on
+// a machine without the containers.
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// A line of the stats file of the file storage driver.
+type statsEntry struct {
+	Ref   info.ContainerReference `json:"ref"`
+	Stats *info.ContainerStats    `json:"stats"`
+}
+
+// Stats of a container at a point of the recording.
+type Sample struct {
+	Ref   info.ContainerReference
+	Stats *info.ContainerStats
+}
+
+// Stats recorded by the file storage driver.
+type Recording struct {
+	// Samples of all the containers, oldest first.
+	Samples []Sample
+
+	// Number of lines which are not JSON, e.g.: a last line cut short by a crash.
+	Corrupt int
+
+	// Number of lines which are JSON but not stats of a container, e.g.: with
+	// no absolute container name or no timestamp.
+	Unknown int
+}
+
+// Number of records skipped by Load.
+func (self *Recording) Skipped() int {
+	return self.Corrupt + self.Unknown
+}
+
+// Reads the stats in the format of the stats.json of the file storage driver.
+// Records which cannot be replayed are skipped and counted in the recording.
+func Load(r io.Reader) (*Recording, error) {
+	recording := &Recording{}
+	lines := bufio.NewReader(r)
+	for {
+		line, err := lines.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			recording.add(line)
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	sort.Stable(samplesByTimestamp(recording.Samples))
+	return recording, nil
+}
+
+// Reads the stats file at the path, see Load.
+func LoadFile(path string) (*Recording, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Load(file)
+}
+
+func (self *Recording) add(line []byte) {
+	var entry statsEntry
+	err := json.Unmarshal(line, &entry)
+	if err != nil {
+		self.Corrupt++
+		return
+	}
+	if !strings.HasPrefix(entry.Ref.Name, "/") || entry.Stats == nil || entry.Stats.Timestamp.IsZero() {
+		self.Unknown++
+		return
+	}
+	self.Samples = append(self.Samples, Sample{
+		Ref:   entry.Ref,
+		Stats: entry.Stats,
+	})
+}
+
+type samplesByTimestamp []Sample
+
+func (self samplesByTimestamp) Len() int      { return len(self) }
+func (self samplesByTimestamp) Swap(i, j int) { self[i], self[j] = self[j], self[i] }
+func (self samplesByTimestamp) Less(i, j int) bool {
+	return self[i].Stats.Timestamp.Before(self[j].Stats.Timestamp)
+}
@@ -0,0 +1,144 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/client"
+	cadvisorHttp "github.com/google/cadvisor/http"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/utils/clock/fakeclock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	sampleCapture = "testdata/stats.json"
+	// The Docker container of the sample capture.
+	dockerId = "4b8c5ab1a1f0e4d1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f6071829"
+)
+
+func TestLoadSkipsCorruptAndUnknownRecords(t *testing.T) {
+	input := strings.Join([]string{
+		`{"ref":{"name":"/a"},"stats":{"timestamp":"2015-06-01T12:00:02Z"}}`,
+		`not json`,
+		`{"ref":{"name":"/a"}}`,
+		`{"ref":{"name":"relative"},"stats":{"timestamp":"2015-06-01T12:00:03Z"}}`,
+		``,
+		`{"ref":{"name":"/b"},"stats":{"timestamp":"2015-06-01T12:00:01Z"}}`,
+		// Cut short by a crash.
+		`{"ref":{"name":"/b"},"stats":{"timest`,
+	}, "\n")
+	recording, err := Load(strings.NewReader(input))
+	require.Nil(t, err)
+
+	assert.Equal(t, 2, recording.Corrupt)
+	assert.Equal(t, 2, recording.Unknown)
+	assert.Equal(t, 4, recording.Skipped())
+	require.Equal(t, 2, len(recording.Samples))
+	// Oldest first.
+	assert.Equal(t, "/b", recording.Samples[0].Ref.Name)
+	assert.Equal(t, "/a", recording.Samples[1].Ref.Name)
+}
+
+// Serves the sample capture replayed at the speed with the handlers of the
+// real cAdvisor.
+func serveCapture(t *testing.T, speed float64) (*replayManager, *fakeclock.FakeClock, *client.Client, func()) {
+	recording, err := LoadFile(sampleCapture)
+	require.Nil(t, err)
+	require.Equal(t, 0, recording.Skipped())
+
+	clock := fakeclock.NewFakeClock(time.Now())
+	m := newReplayManager(recording, speed, clock)
+	require.Nil(t, m.Start())
+
+	mux := http.NewServeMux()
+	require.Nil(t, cadvisorHttp.RegisterHandlers(mux, m, "", "", "", "", "/metrics", true, false))
+	server := httptest.NewServer(mux)
+	c, err := client.NewClient(server.URL)
+	require.Nil(t, err)
+	return m, clock, c, server.Close
+}
+
+func TestReplayServesCapture(t *testing.T) {
+	_, _, c, stop := serveCapture(t, 0)
+	defer stop()
+
+	query := &info.ContainerInfoRequest{NumStats: 60}
+	root, err := c.ContainerInfo("/", query)
+	require.Nil(t, err)
+	require.Equal(t, 5, len(root.Stats))
+	assert.Equal(t, time.Date(2015, 6, 1, 12, 0, 4, 1000000, time.UTC), root.Stats[4].Timestamp.UTC())
+	assert.True(t, root.Spec.HasCpu)
+	assert.True(t, root.Spec.HasMemory)
+	assert.True(t, root.Spec.HasNetwork)
+	assert.True(t, root.Spec.HasFilesystem)
+	assert.Equal(t, time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC), root.Spec.CreationTime.UTC())
+
+	// /docker has no samples of its own but is part of the tree.
+	containers, err := c.SubcontainersInfo("/", query)
+	require.Nil(t, err)
+	names := []string{}
+	for _, cinfo := range containers {
+		names = append(names, cinfo.Name)
+	}
+	assert.Equal(t, []string{"/", "/docker", "/docker/" + dockerId, "/user"}, names)
+	assert.Equal(t, 0, len(containers[1].Stats))
+
+	web, err := c.DockerContainer("web", query)
+	require.Nil(t, err)
+	assert.Equal(t, "/docker/"+dockerId, web.Name)
+	assert.Equal(t, 5, len(web.Stats))
+	assert.True(t, web.Spec.HasNetwork)
+
+	user, err := c.ContainerInfo("/user", query)
+	require.Nil(t, err)
+	assert.False(t, user.Spec.HasNetwork)
+
+	minfo, err := c.MachineInfo()
+	require.Nil(t, err)
+	assert.Equal(t, 2, minfo.NumCores)
+
+	// Nothing is collected.
+	_, err = c.CollectNow("/", query)
+	assert.NotNil(t, err)
+}
+
+func TestReplayAtSpeed(t *testing.T) {
+	_, clock, c, stop := serveCapture(t, 10)
+	defer stop()
+
+	numStats := func() int {
+		cinfo, err := c.ContainerInfo("/docker/"+dockerId, &info.ContainerInfoRequest{NumStats: 60})
+		require.Nil(t, err)
+		return len(cinfo.Stats)
+	}
+	// Only the first samples are due at the start.
+	assert.Equal(t, 1, numStats())
+
+	// 1s of the recording, the second samples were recorded 2ms later.
+	clock.Step(100 * time.Millisecond)
+	assert.Equal(t, 1, numStats())
+	clock.Step(time.Millisecond)
+	assert.Equal(t, 2, numStats())
+
+	clock.Step(time.Hour)
+	assert.Equal(t, 5, numStats())
+}
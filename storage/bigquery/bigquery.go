@@ -15,11 +15,16 @@
 package bigquery
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
+	"sort"
 	"strconv"
+	"sync"
 	"time"
 
 	bigquery "code.google.com/p/google-api-go-client/bigquery/v2"
+	"github.com/golang/glog"
 	info "github.com/google/cadvisor/info/v1"
 	"github.com/google/cadvisor/storage"
 	"github.com/google/cadvisor/storage/bigquery/client"
@@ -28,6 +33,24 @@ import (
 type bigqueryStorage struct {
 	client      *client.Client
 	machineName string
+	machineId   string
+	datasetId   string
+	// Prefix of the daily tables the stats are written to.
+	tableName string
+	// Whether to only log the columns missing from existing tables.
+	schemaDryRun   bool
+	bufferDuration time.Duration
+	batchSize      int
+	lock           sync.Mutex
+	buffer         []bufferedRow
+	lastWrite      time.Time
+	// The columns of the daily tables whose schema was checked, by table.
+	tables map[string]map[string]bool
+}
+
+type bufferedRow struct {
+	table string
+	row   client.Row
 }
 
 const (
@@ -38,6 +61,7 @@ const (
 
 	colTimestamp          string = "timestamp"
 	colMachineName        string = "machine"
+	colMachineId          string = "machine_id"
 	colContainerName      string = "container_name"
 	colCpuCumulativeUsage string = "cpu_cumulative_usage"
 	// Cumulative Cpu usage in system and user mode
@@ -47,6 +71,8 @@ const (
 	colMemoryUsage string = "memory_usage"
 	// Working set size
 	colMemoryWorkingSet string = "memory_working_set"
+	// Swap usage
+	colMemorySwap string = "memory_swap"
 	// Container page fault
 	colMemoryContainerPgfault string = "memory_container_pgfault"
 	// Constainer major page fault
@@ -73,7 +99,7 @@ const (
 
 // TODO(jnagal): Infer schema through reflection. (See bigquery/client/example)
 func (self *bigqueryStorage) GetSchema() *bigquery.TableSchema {
-	fields := make([]*bigquery.TableFieldSchema, 18)
+	fields := make([]*bigquery.TableFieldSchema, 21)
 	i := 0
 	fields[i] = &bigquery.TableFieldSchema{
 		Type: typeTimestamp,
@@ -172,6 +198,16 @@ func (self *bigqueryStorage) GetSchema() *bigquery.TableSchema {
 		Type: typeInteger,
 		Name: colFsUsage,
 	}
+	i++
+	fields[i] = &bigquery.TableFieldSchema{
+		Type: typeString,
+		Name: colMachineId,
+	}
+	i++
+	fields[i] = &bigquery.TableFieldSchema{
+		Type: typeInteger,
+		Name: colMemorySwap,
+	}
 	return &bigquery.TableSchema{
 		Fields: fields,
 	}
@@ -183,18 +219,7 @@ func (self *bigqueryStorage) containerStatsToRows(
 ) (row map[string]interface{}) {
 	row = make(map[string]interface{})
 
-	// Timestamp
-	row[colTimestamp] = stats.Timestamp
-
-	// Machine name
-	row[colMachineName] = self.machineName
-
-	// Container name
-	name := ref.Name
-	if len(ref.Aliases) > 0 {
-		name = ref.Aliases[0]
-	}
-	row[colContainerName] = name
+	self.addContainerColumns(row, ref, stats)
 
 	// Cumulative Cpu Usage
 	row[colCpuCumulativeUsage] = stats.Cpu.Usage.Total
@@ -211,6 +236,9 @@ func (self *bigqueryStorage) containerStatsToRows(
 	// Working set size
 	row[colMemoryWorkingSet] = stats.Memory.WorkingSet
 
+	// Swap usage
+	row[colMemorySwap] = stats.Memory.Swap
+
 	// container page fault
 	row[colMemoryContainerPgfault] = stats.Memory.ContainerData.Pgfault
 
@@ -240,6 +268,7 @@ func (self *bigqueryStorage) containerFilesystemStatsToRows(
 ) (rows []map[string]interface{}) {
 	for _, fsStat := range stats.Filesystem {
 		row := make(map[string]interface{}, 0)
+		self.addContainerColumns(row, ref, stats)
 		row[colFsDevice] = fsStat.Device
 		row[colFsLimit] = fsStat.Limit
 		row[colFsUsage] = fsStat.Usage
@@ -248,6 +277,35 @@ func (self *bigqueryStorage) containerFilesystemStatsToRows(
 	return rows
 }
 
+// Sets the columns identifying the sample: its timestamp, machine and container.
+func (self *bigqueryStorage) addContainerColumns(row map[string]interface{}, ref info.ContainerReference, stats *info.ContainerStats) {
+	row[colTimestamp] = stats.Timestamp
+	row[colMachineName] = self.machineName
+	row[colMachineId] = self.machineId
+
+	// Container name
+	name := ref.Name
+	if len(ref.Aliases) > 0 {
+		name = ref.Aliases[0]
+	}
+	row[colContainerName] = name
+}
+
+// Returns the insert ID of a row, unique to the machine, container, timestamp
+// and, for filesystem rows, device. Rows inserted again on retries have the
+// same ID and are deduplicated by BigQuery.
+func (self *bigqueryStorage) insertId(ref info.ContainerReference, timestamp time.Time, device string) string {
+	hash := sha1.New()
+	fmt.Fprintf(hash, "%s\x00%s\x00%d\x00%s", self.machineId, ref.Name, timestamp.UnixNano(), device)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Returns the name of the daily table the stats at the timestamp are written
+// to, e.g.: stats_20150601. Queries select the days with TABLE_DATE_RANGE.
+func (self *bigqueryStorage) dailyTable(timestamp time.Time) string {
+	return self.tableName + "_" + timestamp.UTC().Format("20060102")
+}
+
 func convertToUint64(v interface{}) (uint64, error) {
 	if v == nil {
 		return 0, nil
@@ -293,8 +351,16 @@ func (self *bigqueryStorage) valuesToContainerStats(columns []string, values []i
 		v := values[i]
 		switch {
 		case col == colTimestamp:
-			if t, ok := v.(time.Time); ok {
+			switch t := v.(type) {
+			case time.Time:
 				stats.Timestamp = t
+			case string:
+				// Queries return timestamps as seconds since the epoch.
+				var seconds float64
+				seconds, err = strconv.ParseFloat(t, 64)
+				if err == nil {
+					stats.Timestamp = time.Unix(0, int64(seconds*float64(time.Second))).UTC()
+				}
 			}
 		case col == colMachineName:
 			if m, ok := v.(string); ok {
@@ -319,6 +385,9 @@ func (self *bigqueryStorage) valuesToContainerStats(columns []string, values []i
 		// Working set size
 		case col == colMemoryWorkingSet:
 			stats.Memory.WorkingSet, err = convertToUint64(v)
+		// Swap usage
+		case col == colMemorySwap:
+			stats.Memory.Swap, err = convertToUint64(v)
 		// container page fault
 		case col == colMemoryContainerPgfault:
 			stats.Memory.ContainerData.Pgfault, err = convertToUint64(v)
@@ -381,25 +450,163 @@ func (self *bigqueryStorage) AddStats(ref info.ContainerReference, stats *info.C
 	if stats == nil {
 		return nil
 	}
-	rows := make([]map[string]interface{}, 0)
-	rows = append(rows, self.containerStatsToRows(ref, stats))
-	rows = append(rows, self.containerFilesystemStatsToRows(ref, stats)...)
+	table := self.dailyTable(stats.Timestamp)
+	rows := []bufferedRow{
+		{
+			table: table,
+			row: client.Row{
+				InsertId: self.insertId(ref, stats.Timestamp, ""),
+				Data:     self.containerStatsToRows(ref, stats),
+			},
+		},
+	}
+	for i, row := range self.containerFilesystemStatsToRows(ref, stats) {
+		rows = append(rows, bufferedRow{
+			table: table,
+			row: client.Row{
+				InsertId: self.insertId(ref, stats.Timestamp, stats.Filesystem[i].Device),
+				Data:     row,
+			},
+		})
+	}
+
+	var rowsToWrite []bufferedRow
+	func() {
+		self.lock.Lock()
+		defer self.lock.Unlock()
+		self.buffer = append(self.buffer, rows...)
+		if len(self.buffer) >= self.batchSize || self.readyToFlush() {
+			rowsToWrite = self.buffer
+			self.buffer = nil
+			self.lastWrite = time.Now()
+		}
+	}()
+	return self.write(rowsToWrite)
+}
+
+// Must be called while holding the lock.
+func (self *bigqueryStorage) readyToFlush() bool {
+	return time.Since(self.lastWrite) >= self.bufferDuration
+}
+
+// Inserts the rows in their daily tables, in requests of at most batchSize rows.
+func (self *bigqueryStorage) write(rows []bufferedRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	rowsByTable := make(map[string][]client.Row)
 	for _, row := range rows {
-		err := self.client.InsertRow(row)
+		rowsByTable[row.table] = append(rowsByTable[row.table], row.row)
+	}
+	tables := make([]string, 0, len(rowsByTable))
+	for table := range rowsByTable {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	for _, table := range tables {
+		columns, err := self.checkTable(table)
 		if err != nil {
 			return err
 		}
+		tableRows := rowsByTable[table]
+		// Columns the table is missing are only left out with --storage_bigquery_schema_dry_run.
+		for _, row := range tableRows {
+			for col := range row.Data {
+				if !columns[col] {
+					delete(row.Data, col)
+				}
+			}
+		}
+		for start := 0; start < len(tableRows); start += self.batchSize {
+			end := start + self.batchSize
+			if end > len(tableRows) {
+				end = len(tableRows)
+			}
+			err = self.client.InsertRows(table, tableRows[start:end])
+			if err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
 
-func (self *bigqueryStorage) getRecentRows(containerName string, numRows int) ([]string, [][]interface{}, error) {
-	tableName, err := self.client.GetTableName()
+// Creates the table if it does not exist, or adds the columns of the schema it
+// is missing. Columns are never removed nor changed. Returns the columns of the
+// table.
+func (self *bigqueryStorage) checkTable(table string) (map[string]bool, error) {
+	self.lock.Lock()
+	columns, ok := self.tables[table]
+	self.lock.Unlock()
+	if ok {
+		return columns, nil
+	}
+
+	schema := self.GetSchema()
+	current, exists, err := self.client.GetTableSchema(table)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	if !exists {
+		glog.Infof("Creating BigQuery table %q", table)
+		err = self.client.AddTable(table, schema)
+		if err != nil {
+			return nil, err
+		}
+		current = schema
+	} else if missing := missingFields(current, schema); len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for _, field := range missing {
+			names = append(names, field.Name)
+		}
+		if self.schemaDryRun {
+			glog.Warningf("BigQuery table %q is missing the columns %v, which are not written. Restart without --storage_bigquery_schema_dry_run to add them", table, names)
+		} else {
+			glog.Infof("Adding the columns %v to BigQuery table %q", names, table)
+			current = &bigquery.TableSchema{
+				Fields: append(current.Fields, missing...),
+			}
+			err = self.client.PatchTableSchema(table, current)
+			if err != nil {
+				return nil, fmt.Errorf("failed to add the columns %v to table %q: %v", names, table, err)
+			}
+		}
 	}
 
-	query := fmt.Sprintf("SELECT * FROM %v WHERE %v='%v' and %v='%v'", tableName, colContainerName, containerName, colMachineName, self.machineName)
+	columns = make(map[string]bool, len(current.Fields))
+	for _, field := range current.Fields {
+		columns[field.Name] = true
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.tables[table] = columns
+	return columns, nil
+}
+
+// Returns the fields of the schema which the current schema of a table is
+// missing. They are nullable since BigQuery cannot add required columns.
+func missingFields(current, schema *bigquery.TableSchema) []*bigquery.TableFieldSchema {
+	existing := make(map[string]bool, len(current.Fields))
+	for _, field := range current.Fields {
+		existing[field.Name] = true
+	}
+	var missing []*bigquery.TableFieldSchema
+	for _, field := range schema.Fields {
+		if existing[field.Name] {
+			continue
+		}
+		added := *field
+		added.Mode = "NULLABLE"
+		missing = append(missing, &added)
+	}
+	return missing
+}
+
+func (self *bigqueryStorage) getRecentRows(containerName string, numRows int) ([]string, [][]interface{}, error) {
+	// The stats of the last day may be in yesterday's table.
+	tables := fmt.Sprintf("TABLE_DATE_RANGE([%s.%s_], DATE_ADD(CURRENT_TIMESTAMP(), -1, 'DAY'), CURRENT_TIMESTAMP())", self.datasetId, self.tableName)
+	query := fmt.Sprintf("SELECT * FROM %v WHERE %v=%v AND %v=%v AND %v IS NULL ORDER BY %v DESC", tables, colContainerName, storage.QuoteString(containerName), colMachineName, storage.QuoteString(self.machineName), colFsDevice, colTimestamp)
 	if numRows > 0 {
 		query = fmt.Sprintf("%v LIMIT %v", query, numRows)
 	}
@@ -416,8 +623,9 @@ func (self *bigqueryStorage) RecentStats(containerName string, numStats int) ([]
 		return nil, err
 	}
 	statsList := make([]*info.ContainerStats, 0, len(rows))
-	for _, row := range rows {
-		stats, err := self.valuesToContainerStats(header, row)
+	// Rows are queried from the most recent.
+	for i := len(rows) - 1; i >= 0; i-- {
+		stats, err := self.valuesToContainerStats(header, rows[i])
 		if err != nil {
 			return nil, err
 		}
@@ -429,19 +637,59 @@ func (self *bigqueryStorage) RecentStats(containerName string, numStats int) ([]
 	return statsList, nil
 }
 
+// Checks that today's table can be accessed, creating it or adding the
+// columns it is missing.
+func (self *bigqueryStorage) VerifyConnection() error {
+	_, err := self.checkTable(self.dailyTable(time.Now()))
+	return err
+}
+
 func (self *bigqueryStorage) Close() error {
+	self.lock.Lock()
+	rows := self.buffer
+	self.buffer = nil
+	self.lock.Unlock()
+	err := self.write(rows)
 	self.client.Close()
 	self.client = nil
-	return nil
+	return err
+}
+
+func newStorage(bqClient *client.Client, machineName, machineId, datasetId, tableName string, bufferDuration time.Duration, batchSize int, schemaDryRun bool) *bigqueryStorage {
+	if machineId == "" {
+		machineId = machineName
+	}
+	return &bigqueryStorage{
+		client:         bqClient,
+		machineName:    machineName,
+		machineId:      machineId,
+		datasetId:      datasetId,
+		tableName:      tableName,
+		schemaDryRun:   schemaDryRun,
+		bufferDuration: bufferDuration,
+		batchSize:      batchSize,
+		lastWrite:      time.Now(),
+		tables:         make(map[string]map[string]bool),
+	}
 }
 
 // Create a new bigquery storage driver.
 // machineName: A unique identifier to identify the host that current cAdvisor
 // instance is running on.
-// tableName: BigQuery table used for storing stats.
+// machineId: The ID of the machine, which identifies the rows to deduplicate
+// along with their container and timestamp. Defaults to the machine name.
+// tableName: Prefix of the daily BigQuery tables used for storing stats.
+// bufferDuration: Rows are buffered for this duration and inserted in batches
+// of at most batchSize rows.
+// schemaDryRun: Whether to only log the columns missing from existing tables
+// rather than adding them.
 func New(machineName,
+	machineId,
 	datasetId,
 	tableName string,
+	bufferDuration time.Duration,
+	batchSize int,
+	schemaDryRun bool,
 ) (storage.StorageDriver, error) {
 	bqClient, err := client.NewClient()
 	if err != nil {
@@ -451,15 +699,5 @@ func New(machineName,
 	if err != nil {
 		return nil, err
 	}
-
-	ret := &bigqueryStorage{
-		client:      bqClient,
-		machineName: machineName,
-	}
-	schema := ret.GetSchema()
-	err = bqClient.CreateTable(tableName, schema)
-	if err != nil {
-		return nil, err
-	}
-	return ret, nil
+	return newStorage(bqClient, machineName, machineId, datasetId, tableName, bufferDuration, batchSize, schemaDryRun), nil
 }
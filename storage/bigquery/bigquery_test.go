@@ -0,0 +1,251 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigquery
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	bigquery "code.google.com/p/google-api-go-client/bigquery/v2"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/storage/bigquery/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const tablesPath = "/projects/project/datasets/stats/tables"
+
+// Fake BigQuery keeping the schema of the tables and the rows inserted in them.
+type fakeBigquery struct {
+	*httptest.Server
+	lock    sync.Mutex
+	schemas map[string]*bigquery.TableSchema
+	// The rows of each insertion request, by table.
+	inserts map[string][][]*bigquery.TableDataInsertAllRequestRows
+	patches []string
+}
+
+func newFakeBigquery() *fakeBigquery {
+	fake := &fakeBigquery{
+		schemas: make(map[string]*bigquery.TableSchema),
+		inserts: make(map[string][][]*bigquery.TableDataInsertAllRequestRows),
+	}
+	fake.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fake.lock.Lock()
+		defer fake.lock.Unlock()
+		if r.Method == "POST" && r.URL.Path == "/projects/project/datasets" {
+			w.Write([]byte(`{}`))
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, tablesPath) {
+			http.Error(w, `{"error":{"code":404}}`, http.StatusNotFound)
+			return
+		}
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, tablesPath), "/")
+		switch {
+		case r.Method == "POST" && len(parts) == 1:
+			var table bigquery.Table
+			json.NewDecoder(r.Body).Decode(&table)
+			fake.schemas[table.TableReference.TableId] = table.Schema
+			json.NewEncoder(w).Encode(table)
+		case r.Method == "GET" && len(parts) == 2:
+			schema, ok := fake.schemas[parts[1]]
+			if !ok {
+				http.Error(w, `{"error":{"code":404}}`, http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(bigquery.Table{Schema: schema})
+		case r.Method == "PATCH" && len(parts) == 2:
+			var table bigquery.Table
+			json.NewDecoder(r.Body).Decode(&table)
+			fake.schemas[parts[1]] = table.Schema
+			fake.patches = append(fake.patches, parts[1])
+			json.NewEncoder(w).Encode(table)
+		case r.Method == "POST" && len(parts) == 3 && parts[2] == "insertAll":
+			var request bigquery.TableDataInsertAllRequest
+			json.NewDecoder(r.Body).Decode(&request)
+			fake.inserts[parts[1]] = append(fake.inserts[parts[1]], request.Rows)
+			w.Write([]byte(`{}`))
+		default:
+			http.Error(w, `{"error":{"code":400}}`, http.StatusBadRequest)
+		}
+	}))
+	return fake
+}
+
+// Returns the rows inserted in the table, in the order of the insertions.
+func (self *fakeBigquery) Rows(table string) []*bigquery.TableDataInsertAllRequestRows {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	var rows []*bigquery.TableDataInsertAllRequestRows
+	for _, insert := range self.inserts[table] {
+		rows = append(rows, insert...)
+	}
+	return rows
+}
+
+func newTestStorage(t *testing.T, fake *fakeBigquery, bufferDuration time.Duration, batchSize int, schemaDryRun bool) *bigqueryStorage {
+	service, err := bigquery.New(http.DefaultClient)
+	require.Nil(t, err)
+	service.BasePath = fake.URL + "/"
+	bqClient := client.NewClientWithService(service, "project")
+	require.Nil(t, bqClient.CreateDataset("stats"))
+	return newStorage(bqClient, "host1", "m1", "stats", "cadvisor", bufferDuration, batchSize, schemaDryRun)
+}
+
+var testRef = info.ContainerReference{
+	Name:    "/docker/abc",
+	Aliases: []string{"web", "abc"},
+}
+
+func newTestStats(timestamp time.Time) *info.ContainerStats {
+	stats := &info.ContainerStats{
+		Timestamp: timestamp,
+		Filesystem: []info.FsStats{
+			{Device: "/dev/sda1", Limit: 100, Usage: 50},
+		},
+	}
+	stats.Cpu.Usage.Total = 100
+	stats.Memory.Usage = 1024
+	stats.Memory.Swap = 256
+	return stats
+}
+
+func TestRowsAreInsertedInDailyTables(t *testing.T) {
+	fake := newFakeBigquery()
+	defer fake.Close()
+	driver := newTestStorage(t, fake, 0, 100, false)
+
+	beforeMidnight := time.Date(2015, 6, 11, 23, 59, 59, 0, time.UTC)
+	afterMidnight := time.Date(2015, 6, 12, 0, 0, 1, 0, time.UTC)
+	require.Nil(t, driver.AddStats(testRef, newTestStats(beforeMidnight)))
+	require.Nil(t, driver.AddStats(testRef, newTestStats(afterMidnight)))
+
+	for _, table := range []string{"cadvisor_20150611", "cadvisor_20150612"} {
+		require.NotNil(t, fake.schemas[table], "table %q was not created", table)
+		rows := fake.Rows(table)
+		require.Equal(t, 2, len(rows), "rows of %q", table)
+		assert.Equal(t, "web", rows[0].Json[colContainerName])
+		assert.Equal(t, "m1", rows[0].Json[colMachineId])
+		assert.Equal(t, float64(256), rows[0].Json[colMemorySwap])
+		// Filesystem rows identify their sample as well.
+		assert.Equal(t, "/dev/sda1", rows[1].Json[colFsDevice])
+		assert.Equal(t, "web", rows[1].Json[colContainerName])
+		assert.NotNil(t, rows[1].Json[colTimestamp])
+	}
+}
+
+func TestInsertIdsAreDerivedFromTheSample(t *testing.T) {
+	fake := newFakeBigquery()
+	defer fake.Close()
+	driver := newTestStorage(t, fake, 0, 100, false)
+
+	timestamp := time.Date(2015, 6, 11, 12, 0, 0, 0, time.UTC)
+	require.Nil(t, driver.AddStats(testRef, newTestStats(timestamp)))
+	// A retried insertion of the same sample.
+	require.Nil(t, driver.AddStats(testRef, newTestStats(timestamp)))
+	require.Nil(t, driver.AddStats(testRef, newTestStats(timestamp.Add(time.Second))))
+	otherRef := info.ContainerReference{Name: "/docker/def", Aliases: []string{"web"}}
+	require.Nil(t, driver.AddStats(otherRef, newTestStats(timestamp)))
+
+	rows := fake.Rows("cadvisor_20150611")
+	require.Equal(t, 8, len(rows))
+	for _, row := range rows {
+		assert.Equal(t, 40, len(row.InsertId))
+	}
+	assert.Equal(t, rows[0].InsertId, rows[2].InsertId)
+	assert.Equal(t, rows[1].InsertId, rows[3].InsertId)
+	assert.NotEqual(t, rows[0].InsertId, rows[1].InsertId)
+	assert.NotEqual(t, rows[0].InsertId, rows[4].InsertId)
+	// Containers sharing an alias are told apart by their name.
+	assert.NotEqual(t, rows[0].InsertId, rows[6].InsertId)
+}
+
+func TestRowsAreInsertedInBatches(t *testing.T) {
+	fake := newFakeBigquery()
+	defer fake.Close()
+	driver := newTestStorage(t, fake, time.Hour, 3, false)
+
+	timestamp := time.Date(2015, 6, 11, 12, 0, 0, 0, time.UTC)
+	require.Nil(t, driver.AddStats(testRef, newTestStats(timestamp)))
+	assert.Equal(t, 0, len(fake.Rows("cadvisor_20150611")))
+
+	// Reaching the batch size inserts the buffered rows, in batches.
+	require.Nil(t, driver.AddStats(testRef, newTestStats(timestamp.Add(time.Second))))
+	assert.Equal(t, 4, len(fake.Rows("cadvisor_20150611")))
+	assert.Equal(t, 2, len(fake.inserts["cadvisor_20150611"]))
+
+	require.Nil(t, driver.AddStats(testRef, newTestStats(timestamp.Add(2*time.Second))))
+	assert.Equal(t, 4, len(fake.Rows("cadvisor_20150611")))
+	require.Nil(t, driver.Close())
+	assert.Equal(t, 6, len(fake.Rows("cadvisor_20150611")))
+}
+
+// Returns the schema of a table created before the machine ID and swap were
+// written.
+func oldSchema(driver *bigqueryStorage) *bigquery.TableSchema {
+	schema := &bigquery.TableSchema{}
+	for _, field := range driver.GetSchema().Fields {
+		if field.Name != colMachineId && field.Name != colMemorySwap {
+			schema.Fields = append(schema.Fields, field)
+		}
+	}
+	return schema
+}
+
+func TestMissingColumnsAreAdded(t *testing.T) {
+	fake := newFakeBigquery()
+	defer fake.Close()
+	driver := newTestStorage(t, fake, 0, 100, false)
+	table := driver.dailyTable(time.Now())
+	fake.schemas[table] = oldSchema(driver)
+
+	require.Nil(t, driver.VerifyConnection())
+	assert.Equal(t, []string{table}, fake.patches)
+	fields := fake.schemas[table].Fields
+	require.Equal(t, len(driver.GetSchema().Fields), len(fields))
+	for _, field := range fields[len(fields)-2:] {
+		assert.Equal(t, "NULLABLE", field.Mode)
+	}
+
+	// The schema is only checked once.
+	require.Nil(t, driver.VerifyConnection())
+	assert.Equal(t, 1, len(fake.patches))
+}
+
+func TestSchemaDryRun(t *testing.T) {
+	fake := newFakeBigquery()
+	defer fake.Close()
+	driver := newTestStorage(t, fake, 0, 100, true)
+	timestamp := time.Date(2015, 6, 11, 12, 0, 0, 0, time.UTC)
+	fake.schemas["cadvisor_20150611"] = oldSchema(driver)
+
+	require.Nil(t, driver.AddStats(testRef, newTestStats(timestamp)))
+	assert.Equal(t, 0, len(fake.patches))
+	rows := fake.Rows("cadvisor_20150611")
+	require.Equal(t, 2, len(rows))
+	_, ok := rows[0].Json[colMemorySwap]
+	assert.False(t, ok)
+	assert.Equal(t, float64(1024), rows[0].Json[colMemoryUsage])
+
+	// New tables have all the columns.
+	require.Nil(t, driver.AddStats(testRef, newTestStats(timestamp.Add(24*time.Hour))))
+	assert.Equal(t, len(driver.GetSchema().Fields), len(fake.schemas["cadvisor_20150612"].Fields))
+}
@@ -24,6 +24,7 @@ import (
 	"code.google.com/p/goauth2/oauth"
 	"code.google.com/p/goauth2/oauth/jwt"
 	bigquery "code.google.com/p/google-api-go-client/bigquery/v2"
+	"code.google.com/p/google-api-go-client/googleapi"
 )
 
 var (
@@ -43,10 +44,18 @@ const (
 type Client struct {
 	service   *bigquery.Service
 	token     *oauth.Token
+	projectId string
 	datasetId string
 	tableId   string
 }
 
+// A row to insert in a table. Rows with the same insert ID are only inserted
+// once, so that retried insertions are deduplicated by BigQuery.
+type Row struct {
+	InsertId string
+	Data     map[string]interface{}
+}
+
 // Helper method to create an authenticated connection.
 func connect() (*oauth.Token, *bigquery.Service, error) {
 	if *clientId == "" {
@@ -103,12 +112,22 @@ func NewClient() (*Client, error) {
 		return nil, err
 	}
 	c := &Client{
-		token:   token,
-		service: service,
+		token:     token,
+		service:   service,
+		projectId: *projectId,
 	}
 	return c, nil
 }
 
+// Creates a new client instance using the specified service, e.g.: one whose
+// BasePath points to a fake BigQuery in tests. Its token is never refreshed.
+func NewClientWithService(service *bigquery.Service, projectId string) *Client {
+	return &Client{
+		service:   service,
+		projectId: projectId,
+	}
+}
+
 func (c *Client) Close() error {
 	c.service = nil
 	return nil
@@ -117,12 +136,12 @@ func (c *Client) Close() error {
 // Helper method to return the bigquery service connection.
 // Expired connection is refreshed.
 func (c *Client) getService() (*bigquery.Service, error) {
-	if c.token == nil || c.service == nil {
+	if c.service == nil {
 		return nil, fmt.Errorf("service not initialized")
 	}
 
 	// Refresh expired token.
-	if c.token.Expired() {
+	if c.token != nil && c.token.Expired() {
 		token, service, err := connect()
 		if err != nil {
 			return nil, err
@@ -135,7 +154,7 @@ func (c *Client) getService() (*bigquery.Service, error) {
 }
 
 func (c *Client) PrintDatasets() error {
-	datasetList, err := c.service.Datasets.List(*projectId).Do()
+	datasetList, err := c.service.Datasets.List(c.projectId).Do()
 	if err != nil {
 		fmt.Printf("Failed to get list of datasets\n")
 		return err
@@ -153,10 +172,10 @@ func (c *Client) CreateDataset(datasetId string) error {
 	if c.service == nil {
 		return fmt.Errorf("no service created")
 	}
-	_, err := c.service.Datasets.Insert(*projectId, &bigquery.Dataset{
+	_, err := c.service.Datasets.Insert(c.projectId, &bigquery.Dataset{
 		DatasetReference: &bigquery.DatasetReference{
 			DatasetId: datasetId,
-			ProjectId: *projectId,
+			ProjectId: c.projectId,
 		},
 	}).Do()
 	// TODO(jnagal): Do a Get() to verify dataset already exists.
@@ -173,14 +192,14 @@ func (c *Client) CreateTable(tableId string, schema *bigquery.TableSchema) error
 	if c.service == nil || c.datasetId == "" {
 		return fmt.Errorf("no dataset created")
 	}
-	_, err := c.service.Tables.Get(*projectId, c.datasetId, tableId).Do()
+	_, err := c.service.Tables.Get(c.projectId, c.datasetId, tableId).Do()
 	if err != nil {
 		// Create a new table.
-		_, err := c.service.Tables.Insert(*projectId, c.datasetId, &bigquery.Table{
+		_, err := c.service.Tables.Insert(c.projectId, c.datasetId, &bigquery.Table{
 			Schema: schema,
 			TableReference: &bigquery.TableReference{
 				DatasetId: c.datasetId,
-				ProjectId: *projectId,
+				ProjectId: c.projectId,
 				TableId:   tableId,
 			},
 		}).Do()
@@ -193,28 +212,99 @@ func (c *Client) CreateTable(tableId string, schema *bigquery.TableSchema) error
 	return nil
 }
 
+// Returns the schema of the table with the provided table ID, and whether the
+// table exists.
+func (c *Client) GetTableSchema(tableId string) (*bigquery.TableSchema, bool, error) {
+	service, err := c.getService()
+	if err != nil {
+		return nil, false, err
+	}
+	if c.datasetId == "" {
+		return nil, false, fmt.Errorf("no dataset created")
+	}
+	table, err := service.Tables.Get(c.projectId, c.datasetId, tableId).Do()
+	if err != nil {
+		if apiErr, ok := err.(*googleapi.Error); ok && apiErr.Code == http.StatusNotFound {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	if table.Schema == nil {
+		return &bigquery.TableSchema{}, true, nil
+	}
+	return table.Schema, true, nil
+}
+
+// Adds a table with the provided table ID and schema to the dataset. Tables
+// created concurrently by another client are not an error.
+func (c *Client) AddTable(tableId string, schema *bigquery.TableSchema) error {
+	service, err := c.getService()
+	if err != nil {
+		return err
+	}
+	if c.datasetId == "" {
+		return fmt.Errorf("no dataset created")
+	}
+	_, err = service.Tables.Insert(c.projectId, c.datasetId, &bigquery.Table{
+		Schema: schema,
+		TableReference: &bigquery.TableReference{
+			DatasetId: c.datasetId,
+			ProjectId: c.projectId,
+			TableId:   tableId,
+		},
+	}).Do()
+	if apiErr, ok := err.(*googleapi.Error); ok && apiErr.Code == http.StatusConflict {
+		return nil
+	}
+	return err
+}
+
+// Replaces the schema of the table with the provided table ID. BigQuery only
+// accepts schemas which extend the current one with new nullable columns.
+func (c *Client) PatchTableSchema(tableId string, schema *bigquery.TableSchema) error {
+	service, err := c.getService()
+	if err != nil {
+		return err
+	}
+	if c.datasetId == "" {
+		return fmt.Errorf("no dataset created")
+	}
+	_, err = service.Tables.Patch(c.projectId, c.datasetId, tableId, &bigquery.Table{
+		Schema: schema,
+	}).Do()
+	return err
+}
+
 // Add a row to the connected table.
 func (c *Client) InsertRow(rowData map[string]interface{}) error {
-	service, _ := c.getService()
-	if service == nil || c.datasetId == "" || c.tableId == "" {
+	if c.tableId == "" {
 		return fmt.Errorf("table not setup to add rows")
 	}
-	jsonRows := make(map[string]bigquery.JsonValue)
-	for key, value := range rowData {
-		jsonRows[key] = bigquery.JsonValue(value)
+	return c.InsertRows(c.tableId, []Row{{Data: rowData}})
+}
+
+// Adds the rows to the table with the provided table ID in a single request.
+func (c *Client) InsertRows(tableId string, rowData []Row) error {
+	service, _ := c.getService()
+	if service == nil || c.datasetId == "" {
+		return fmt.Errorf("table not setup to add rows")
 	}
-	rows := []*bigquery.TableDataInsertAllRequestRows{
-		{
-			Json: jsonRows,
-		},
+	rows := make([]*bigquery.TableDataInsertAllRequestRows, 0, len(rowData))
+	for _, row := range rowData {
+		jsonRow := make(map[string]bigquery.JsonValue)
+		for key, value := range row.Data {
+			jsonRow[key] = bigquery.JsonValue(value)
+		}
+		rows = append(rows, &bigquery.TableDataInsertAllRequestRows{
+			InsertId: row.InsertId,
+			Json:     jsonRow,
+		})
 	}
-
-	// TODO(jnagal): Batch insert requests.
 	insertRequest := &bigquery.TableDataInsertAllRequest{Rows: rows}
 
-	result, err := service.Tabledata.InsertAll(*projectId, c.datasetId, c.tableId, insertRequest).Do()
+	result, err := service.Tabledata.InsertAll(c.projectId, c.datasetId, tableId, insertRequest).Do()
 	if err != nil {
-		return fmt.Errorf("error inserting row: %v", err)
+		return fmt.Errorf("error inserting rows: %v", err)
 	}
 
 	if len(result.InsertErrors) > 0 {
@@ -237,6 +327,19 @@ func (c *Client) GetTableName() (string, error) {
 	return fmt.Sprintf("%s.%s", c.datasetId, c.tableId), nil
 }
 
+// Checks that the connected table can be accessed.
+func (c *Client) VerifyTable() error {
+	service, err := c.getService()
+	if err != nil {
+		return err
+	}
+	if c.datasetId == "" || c.tableId == "" {
+		return fmt.Errorf("table not setup")
+	}
+	_, err = service.Tables.Get(c.projectId, c.datasetId, c.tableId).Do()
+	return err
+}
+
 // Do a synchronous query on bigtable and return a header and data rows.
 // Number of rows are capped to queryLimit.
 func (c *Client) Query(query string) ([]string, [][]interface{}, error) {
@@ -246,7 +349,7 @@ func (c *Client) Query(query string) ([]string, [][]interface{}, error) {
 	}
 	datasetRef := &bigquery.DatasetReference{
 		DatasetId: c.datasetId,
-		ProjectId: *projectId,
+		ProjectId: c.projectId,
 	}
 
 	queryRequest := &bigquery.QueryRequest{
@@ -256,7 +359,7 @@ func (c *Client) Query(query string) ([]string, [][]interface{}, error) {
 		Query:          query,
 	}
 
-	results, err := service.Jobs.Query(*projectId, queryRequest).Do()
+	results, err := service.Jobs.Query(c.projectId, queryRequest).Do()
 	if err != nil {
 		return nil, nil, err
 	}
@@ -0,0 +1,150 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var suppressedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "cadvisor",
+	Subsystem: "storage",
+	Name:      "suppressed_writes_total",
+	Help:      "Number of stats not written to the storage driver because they had not changed since the last ones written.",
+}, []string{"driver"})
+
+// A storage driver which only writes the stats of a container to the driver
+// it wraps when they changed since the last ones written, or when the last
+// ones written are at least a window old. Backends are then sent at least a
+// sample per container and window. Gauges (e.g.: the memory usage) are
+// unchanged within a relative tolerance, counters must be equal. Class is
+// thread-safe.
+type DedupDriver struct {
+	driver    StorageDriver
+	name      string
+	window    time.Duration
+	tolerance float64
+
+	lock sync.Mutex
+	// The last stats written, by container name.
+	lastWritten map[string]*info.ContainerStats
+	// Time of the newest stats when the containers no longer written were last forgotten.
+	lastPruned time.Time
+	suppressed uint64
+}
+
+// Returns the specified driver, named e.g. influxdb, writing the stats of a
+// container at least once per window and otherwise only when they change by
+// more than the tolerance (e.g.: 0.01 for 1%).
+func Dedup(name string, driver StorageDriver, window time.Duration, tolerance float64) *DedupDriver {
+	return &DedupDriver{
+		driver:      driver,
+		name:        name,
+		window:      window,
+		tolerance:   tolerance,
+		lastWritten: make(map[string]*info.ContainerStats),
+	}
+}
+
+func (self *DedupDriver) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	if self.suppress(ref.Name, stats) {
+		return nil
+	}
+	if err := self.driver.AddStats(ref, stats); err != nil {
+		// The stats are compared with the last ones which made it.
+		return err
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.lastWritten[ref.Name] = stats
+	self.pruneLocked(stats.Timestamp)
+	return nil
+}
+
+// Returns whether the stats of the container are to be skipped, in which case
+// they are counted as suppressed.
+func (self *DedupDriver) suppress(name string, stats *info.ContainerStats) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	last, ok := self.lastWritten[name]
+	if !ok || stats.Timestamp.Sub(last.Timestamp) >= self.window || !self.unchanged(last, stats) {
+		return false
+	}
+	self.suppressed++
+	suppressedTotal.WithLabelValues(self.name).Inc()
+	return true
+}
+
+// Returns whether the stats are the same as the last ones written, the gauges
+// within the tolerance. A counter which was reset (e.g.: the container was
+// restarted) differs.
+func (self *DedupDriver) unchanged(last, stats *info.ContainerStats) bool {
+	if !self.gaugeEq(float64(last.Memory.Usage), float64(stats.Memory.Usage)) ||
+		!self.gaugeEq(float64(last.Memory.WorkingSet), float64(stats.Memory.WorkingSet)) ||
+		!self.gaugeEq(float64(last.Memory.Swap), float64(stats.Memory.Swap)) ||
+		!self.gaugeEq(float64(last.Cpu.LoadAverage), float64(stats.Cpu.LoadAverage)) {
+		return false
+	}
+	compared := *stats
+	compared.Memory.Usage = last.Memory.Usage
+	compared.Memory.WorkingSet = last.Memory.WorkingSet
+	compared.Memory.Swap = last.Memory.Swap
+	compared.Cpu.LoadAverage = last.Cpu.LoadAverage
+	return last.StatsEq(&compared)
+}
+
+// Whether the gauges differ by at most the tolerance, relative to the largest.
+func (self *DedupDriver) gaugeEq(a, b float64) bool {
+	return math.Abs(a-b) <= self.tolerance*math.Max(math.Abs(a), math.Abs(b))
+}
+
+// Forgets the containers whose stats were not written for two windows: since
+// stats are written at least once per window, they are no longer added.
+func (self *DedupDriver) pruneLocked(now time.Time) {
+	if now.Sub(self.lastPruned) < self.window {
+		return
+	}
+	for name, last := range self.lastWritten {
+		if now.Sub(last.Timestamp) >= 2*self.window {
+			delete(self.lastWritten, name)
+		}
+	}
+	self.lastPruned = now
+}
+
+// Returns the number of stats which were not written because they had not changed.
+func (self *DedupDriver) Suppressed() uint64 {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.suppressed
+}
+
+func (self *DedupDriver) RecentStats(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	return self.driver.RecentStats(containerName, numStats)
+}
+
+func (self *DedupDriver) VerifyConnection() error {
+	return self.driver.VerifyConnection()
+}
+
+func (self *DedupDriver) Close() error {
+	return self.driver.Close()
+}
@@ -0,0 +1,180 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// A storage driver recording the stats written to it.
+type recordingDriver struct {
+	fakeDriver
+	added []*info.ContainerStats
+	refs  []info.ContainerReference
+}
+
+func (self *recordingDriver) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	if self.err != nil {
+		return self.err
+	}
+	self.added = append(self.added, stats)
+	self.refs = append(self.refs, ref)
+	return nil
+}
+
+var dedupStart = time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// Returns stats taken the specified number of seconds after dedupStart.
+func dedupStats(seconds int, cpu, memory uint64) *info.ContainerStats {
+	stats := &info.ContainerStats{
+		Timestamp: dedupStart.Add(time.Duration(seconds) * time.Second),
+	}
+	stats.Cpu.Usage.Total = cpu
+	stats.Memory.Usage = memory
+	return stats
+}
+
+func TestDedupSuppressesUnchangedStats(t *testing.T) {
+	backend := &recordingDriver{}
+	driver := Dedup("test", backend, time.Minute, 0.01)
+	ref := info.ContainerReference{Name: "/idle"}
+
+	for i, stats := range []*info.ContainerStats{
+		dedupStats(0, 100, 1000),
+		dedupStats(10, 100, 1000),
+		// Within the 1% tolerance of the memory usage.
+		dedupStats(20, 100, 1005),
+		// The CPU usage is a counter.
+		dedupStats(30, 101, 1000),
+		// Beyond the tolerance.
+		dedupStats(40, 101, 1100),
+	} {
+		if err := driver.AddStats(ref, stats); err != nil {
+			t.Fatalf("Failed to add stats %d: %v", i, err)
+		}
+	}
+	if len(backend.added) != 3 {
+		t.Fatalf("Expected 3 stats to be written, got %d", len(backend.added))
+	}
+	for i, seconds := range []int{0, 30, 40} {
+		if expected := dedupStart.Add(time.Duration(seconds) * time.Second); !backend.added[i].Timestamp.Equal(expected) {
+			t.Errorf("Expected stats %d to be those of %v, got %v", i, expected, backend.added[i].Timestamp)
+		}
+	}
+	if suppressed := driver.Suppressed(); suppressed != 2 {
+		t.Errorf("Expected 2 suppressed stats, got %d", suppressed)
+	}
+}
+
+func TestDedupWritesOncePerWindow(t *testing.T) {
+	backend := &recordingDriver{}
+	driver := Dedup("test", backend, time.Minute, 0.01)
+	ref := info.ContainerReference{Name: "/idle"}
+
+	for seconds := 0; seconds <= 150; seconds += 10 {
+		if err := driver.AddStats(ref, dedupStats(seconds, 100, 1000)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The heartbeats are a window after the last written.
+	if len(backend.added) != 3 {
+		t.Fatalf("Expected 3 stats to be written, got %d", len(backend.added))
+	}
+	for i, seconds := range []int{0, 60, 120} {
+		if expected := dedupStart.Add(time.Duration(seconds) * time.Second); !backend.added[i].Timestamp.Equal(expected) {
+			t.Errorf("Expected stats %d to be those of %v, got %v", i, expected, backend.added[i].Timestamp)
+		}
+	}
+	if suppressed := driver.Suppressed(); suppressed != 13 {
+		t.Errorf("Expected 13 suppressed stats, got %d", suppressed)
+	}
+}
+
+func TestDedupWritesCounterResets(t *testing.T) {
+	backend := &recordingDriver{}
+	driver := Dedup("test", backend, time.Minute, 0.01)
+	ref := info.ContainerReference{Name: "/restarted"}
+
+	for _, stats := range []*info.ContainerStats{
+		dedupStats(0, 100, 1000),
+		// The container restarted, its CPU usage started over.
+		dedupStats(10, 0, 1000),
+		dedupStats(20, 0, 1000),
+	} {
+		if err := driver.AddStats(ref, stats); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(backend.added) != 2 {
+		t.Fatalf("Expected 2 stats to be written, got %d", len(backend.added))
+	}
+	if usage := backend.added[1].Cpu.Usage.Total; usage != 0 {
+		t.Errorf("Expected the reset stats to be written, got a CPU usage of %d", usage)
+	}
+}
+
+func TestDedupKeepsContainersApart(t *testing.T) {
+	backend := &recordingDriver{}
+	driver := Dedup("test", backend, time.Minute, 0.01)
+
+	for _, name := range []string{"/a", "/b", "/a", "/b"} {
+		if err := driver.AddStats(info.ContainerReference{Name: name}, dedupStats(0, 100, 1000)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(backend.added) != 2 {
+		t.Errorf("Expected the first stats of each container to be written, got %d stats", len(backend.added))
+	}
+}
+
+func TestDedupRetriesFailedWrites(t *testing.T) {
+	backend := &recordingDriver{}
+	backend.err = errors.New("connection refused")
+	driver := Dedup("test", backend, time.Minute, 0.01)
+	ref := info.ContainerReference{Name: "/idle"}
+
+	if err := driver.AddStats(ref, dedupStats(0, 100, 1000)); err == nil {
+		t.Fatal("Expected the write to fail")
+	}
+	backend.err = nil
+	if err := driver.AddStats(ref, dedupStats(10, 100, 1000)); err != nil {
+		t.Fatal(err)
+	}
+	if len(backend.added) != 1 || driver.Suppressed() != 0 {
+		t.Errorf("Expected the stats to be written after the failure, got %d written and %d suppressed", len(backend.added), driver.Suppressed())
+	}
+}
+
+func TestDedupForgetsRemovedContainers(t *testing.T) {
+	backend := &recordingDriver{}
+	driver := Dedup("test", backend, time.Minute, 0.01)
+
+	if err := driver.AddStats(info.ContainerReference{Name: "/removed"}, dedupStats(0, 100, 1000)); err != nil {
+		t.Fatal(err)
+	}
+	if err := driver.AddStats(info.ContainerReference{Name: "/live"}, dedupStats(150, 100, 1000)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := driver.lastWritten["/removed"]; ok {
+		t.Error("Expected the removed container to be forgotten")
+	}
+	if _, ok := driver.lastWritten["/live"]; !ok {
+		t.Error("Expected the live container to be remembered")
+	}
+}
@@ -0,0 +1,358 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Storage driver which appends the stats and events to files as lines of JSON.
+// Meant for machines without a database. Entries older than the retention are
+// dropped by compacting the files at startup and then hourly.
+package file
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+const (
+	statsFileName  = "stats.json"
+	eventsFileName = "events.json"
+)
+
+// Interval between the compactions of the files.
+const compactionInterval = time.Hour
+
+type fileStorage struct {
+	// Serializes writes, reads and compactions of the files.
+	lock       sync.Mutex
+	statsFile  *os.File
+	eventsFile *os.File
+
+	// Age of the oldest entries kept, zero keeps them all.
+	retention time.Duration
+
+	// Current time, replaced by tests.
+	now func() time.Time
+
+	// Time of the last compaction and number of malformed lines skipped
+	// (e.g.: cut short by a crash), protected by lock.
+	lastCompaction time.Time
+	skippedLines   uint64
+}
+
+// A line of the stats file.
+type statsEntry struct {
+	Ref   info.ContainerReference `json:"ref"`
+	Stats *info.ContainerStats    `json:"stats"`
+}
+
+// Parses a line of the stats file, nil if it holds no stats.
+func parseStatsLine(line []byte) (*statsEntry, error) {
+	var entry statsEntry
+	err := json.Unmarshal(line, &entry)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Stats == nil {
+		return nil, fmt.Errorf("no stats in line")
+	}
+	return &entry, nil
+}
+
+// Parses a line of the events file.
+func parseEventLine(line []byte) (*events.Event, error) {
+	var e events.Event
+	err := json.Unmarshal(line, &e)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (self *fileStorage) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	if stats == nil {
+		return nil
+	}
+	return self.appendLine(&self.statsFile, statsEntry{
+		Ref:   ref,
+		Stats: stats,
+	})
+}
+
+func (self *fileStorage) RecentStats(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	if numStats == 0 {
+		return nil, nil
+	}
+	statsList := make([]*info.ContainerStats, 0)
+	cutoff := self.cutoff()
+	err := self.readLines(&self.statsFile, func(line []byte) error {
+		entry, err := parseStatsLine(line)
+		if err != nil {
+			return err
+		}
+		if entry.Ref.Name == containerName && !entry.Stats.Timestamp.Before(cutoff) {
+			statsList = append(statsList, entry.Stats)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if numStats > 0 && len(statsList) > numStats {
+		statsList = statsList[len(statsList)-numStats:]
+	}
+	return statsList, nil
+}
+
+func (self *fileStorage) AddEvent(e *events.Event) error {
+	return self.appendLine(&self.eventsFile, e)
+}
+
+func (self *fileStorage) Events(request *events.Request) (events.EventSlice, error) {
+	eventList := events.EventSlice{}
+	cutoff := self.cutoff()
+	err := self.readLines(&self.eventsFile, func(line []byte) error {
+		e, err := parseEventLine(line)
+		if err != nil {
+			return err
+		}
+		if !e.Timestamp.Before(cutoff) {
+			eventList = append(eventList, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events.FilterEvents(request, eventList), nil
+}
+
+// Number of malformed lines skipped by the reads and compactions of the files.
+func (self *fileStorage) SkippedLines() uint64 {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.skippedLines
+}
+
+// The files are opened when the driver is created, there is nothing to connect to.
+func (self *fileStorage) VerifyConnection() error {
+	return nil
+}
+
+func (self *fileStorage) Close() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	statsErr := self.statsFile.Close()
+	eventsErr := self.eventsFile.Close()
+	if statsErr != nil {
+		return statsErr
+	}
+	return eventsErr
+}
+
+// Returns the time before which entries are past the retention, zero if they
+// are all kept.
+func (self *fileStorage) cutoff() time.Time {
+	if self.retention <= 0 {
+		return time.Time{}
+	}
+	return self.now().Add(-self.retention)
+}
+
+// Appends the value as a line of JSON to the file, compacting the files first
+// if they were not for compactionInterval. The file is passed by reference as
+// the compaction reopens it.
+func (self *fileStorage) appendLine(file **os.File, value interface{}) error {
+	line, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.retention > 0 && self.now().Sub(self.lastCompaction) >= compactionInterval {
+		err = self.compactLocked()
+		if err != nil {
+			glog.Errorf("Failed to compact the files of the file storage driver: %v", err)
+		}
+	}
+	_, err = (*file).Write(append(line, '\n'))
+	if err != nil {
+		return fmt.Errorf("failed to write to %q: %v", (*file).Name(), err)
+	}
+	return nil
+}
+
+// Calls handleLine with each line of the file. Lines it fails to handle are
+// malformed (e.g.: cut short by a crash), they are skipped and counted.
+func (self *fileStorage) readLines(file **os.File, handleLine func([]byte) error) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.readLinesLocked((*file).Name(), handleLine)
+}
+
+func (self *fileStorage) readLinesLocked(name string, handleLine func([]byte) error) error {
+	reader, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	skipped := 0
+	lines := bufio.NewReader(reader)
+	for {
+		line, err := lines.ReadBytes('\n')
+		if err == io.EOF {
+			// A last line without a newline was cut short, e.g.: by a crash.
+			if len(line) != 0 {
+				skipped++
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if handleLine(line) != nil {
+			skipped++
+		}
+	}
+	if skipped != 0 {
+		self.skippedLines += uint64(skipped)
+		glog.Warningf("Skipped %d malformed lines of %q", skipped, name)
+	}
+	return nil
+}
+
+// Rewrites the files without the malformed lines and the entries past the
+// retention, and reopens them.
+func (self *fileStorage) compactLocked() error {
+	self.lastCompaction = self.now()
+	cutoff := self.cutoff()
+	statsFile, statsErr := self.compactFileLocked(self.statsFile, func(line []byte) (bool, error) {
+		entry, err := parseStatsLine(line)
+		if err != nil {
+			return false, err
+		}
+		return !entry.Stats.Timestamp.Before(cutoff), nil
+	})
+	self.statsFile = statsFile
+	eventsFile, eventsErr := self.compactFileLocked(self.eventsFile, func(line []byte) (bool, error) {
+		e, err := parseEventLine(line)
+		if err != nil {
+			return false, err
+		}
+		return !e.Timestamp.Before(cutoff), nil
+	})
+	self.eventsFile = eventsFile
+	if statsErr != nil {
+		return statsErr
+	}
+	return eventsErr
+}
+
+// Rewrites the file with the lines to keep and returns it reopened for
+// appending. On failure, the file is left as it was and returned as is.
+func (self *fileStorage) compactFileLocked(file *os.File, keep func([]byte) (bool, error)) (*os.File, error) {
+	name := file.Name()
+	tmpName := name + ".tmp"
+	tmp, err := os.Create(tmpName)
+	if err != nil {
+		return file, err
+	}
+	writer := bufio.NewWriter(tmp)
+	var writeErr error
+	err = self.readLinesLocked(name, func(line []byte) error {
+		kept, err := keep(line)
+		if err != nil {
+			return err
+		}
+		if kept && writeErr == nil {
+			_, writeErr = writer.Write(line)
+		}
+		return nil
+	})
+	if err == nil {
+		err = writeErr
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpName, name)
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return file, fmt.Errorf("failed to compact %q: %v", name, err)
+	}
+	compacted, err := openForAppend(name)
+	if err != nil {
+		// Still appending to the unlinked file until it can be reopened.
+		return file, err
+	}
+	file.Close()
+	return compacted, nil
+}
+
+func openForAppend(name string) (*os.File, error) {
+	return os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+}
+
+// Returns a storage driver which writes to stats.json and events.json in dir,
+// appending to the files left by previous runs. The files are compacted right
+// away, which also drops a last line cut short by a crash so that appending
+// does not extend it. Entries older than the retention are dropped, zero keeps
+// them all.
+func New(dir string, retention time.Duration) (*fileStorage, error) {
+	return newFileStorage(dir, retention, time.Now)
+}
+
+func newFileStorage(dir string, retention time.Duration, now func() time.Time) (*fileStorage, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	statsFile, err := openForAppend(path.Join(dir, statsFileName))
+	if err != nil {
+		return nil, err
+	}
+	eventsFile, err := openForAppend(path.Join(dir, eventsFileName))
+	if err != nil {
+		statsFile.Close()
+		return nil, err
+	}
+	storage := &fileStorage{
+		statsFile:  statsFile,
+		eventsFile: eventsFile,
+		retention:  retention,
+		now:        now,
+	}
+	storage.lock.Lock()
+	defer storage.lock.Unlock()
+	err = storage.compactLocked()
+	if err != nil {
+		storage.statsFile.Close()
+		storage.eventsFile.Close()
+		return nil, err
+	}
+	return storage, nil
+}
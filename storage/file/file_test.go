@@ -0,0 +1,278 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStorage(t *testing.T) (*fileStorage, string) {
+	dir, err := ioutil.TempDir("", "file-storage")
+	require.NoError(t, err)
+	storage, err := New(dir, 0)
+	require.NoError(t, err)
+	return storage, dir
+}
+
+var eventsStart = time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func addTestEvents(t *testing.T, storage *fileStorage) {
+	testEvents := []*events.Event{
+		{ContainerName: "/docker/a", Timestamp: eventsStart, EventType: events.TypeContainerCreation},
+		{ContainerName: "/docker/a", Timestamp: eventsStart.Add(time.Minute), EventType: events.TypeOom, EventData: map[string]interface{}{"pid": 42.0}},
+		{ContainerName: "/docker/ab", Timestamp: eventsStart.Add(2 * time.Minute), EventType: events.TypeContainerCreation},
+		{ContainerName: "/system", Timestamp: eventsStart.Add(3 * time.Minute), EventType: events.TypeContainerCreation},
+		{ContainerName: "/docker/a", Timestamp: eventsStart.Add(4 * time.Minute), EventType: events.TypeContainerDeletion},
+	}
+	for _, e := range testEvents {
+		require.NoError(t, storage.AddEvent(e))
+	}
+}
+
+func allEventTypes() map[events.EventType]bool {
+	return map[events.EventType]bool{
+		events.TypeOom:               true,
+		events.TypeContainerCreation: true,
+		events.TypeContainerDeletion: true,
+	}
+}
+
+// Returns the names and minutes after eventsStart of the events.
+func describeEvents(eventList events.EventSlice) []string {
+	described := make([]string, 0, len(eventList))
+	for _, e := range eventList {
+		described = append(described, fmt.Sprintf("%s@%d", e.ContainerName, int(e.Timestamp.Sub(eventsStart).Minutes())))
+	}
+	return described
+}
+
+func TestEventsRoundTrip(t *testing.T) {
+	storage, dir := newTestStorage(t)
+	defer os.RemoveAll(dir)
+	addTestEvents(t, storage)
+	require.NoError(t, storage.Close())
+
+	// The events outlive the driver.
+	storage, err := New(dir, 0)
+	require.NoError(t, err)
+	defer storage.Close()
+	request := events.NewRequest()
+	request.EventType = allEventTypes()
+	eventList, err := storage.Events(request)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/docker/a@0", "/docker/a@1", "/docker/ab@2", "/system@3", "/docker/a@4"}, describeEvents(eventList))
+
+	oom := eventList[1]
+	assert.Equal(t, events.TypeOom, oom.EventType)
+	assert.True(t, oom.Timestamp.Equal(eventsStart.Add(time.Minute)))
+	assert.Equal(t, map[string]interface{}{"pid": 42.0}, oom.EventData)
+}
+
+func TestEventsFilters(t *testing.T) {
+	storage, dir := newTestStorage(t)
+	defer os.RemoveAll(dir)
+	defer storage.Close()
+	addTestEvents(t, storage)
+
+	cases := []struct {
+		name     string
+		request  events.Request
+		expected []string
+	}{
+		{
+			name:     "by type",
+			request:  events.Request{EventType: map[events.EventType]bool{events.TypeContainerCreation: true}},
+			expected: []string{"/docker/a@0", "/docker/ab@2", "/system@3"},
+		},
+		{
+			name:     "by container",
+			request:  events.Request{EventType: allEventTypes(), ContainerName: "/docker/a"},
+			expected: []string{"/docker/a@0", "/docker/a@1", "/docker/a@4"},
+		},
+		{
+			// Prefixes match whole path components, /docker/ab is not under /docker/a.
+			name:     "by container prefix",
+			request:  events.Request{EventType: allEventTypes(), ContainerName: "/docker", IncludeSubcontainers: true},
+			expected: []string{"/docker/a@0", "/docker/a@1", "/docker/ab@2", "/docker/a@4"},
+		},
+		{
+			name:     "by time range",
+			request:  events.Request{EventType: allEventTypes(), StartTime: eventsStart.Add(time.Minute), EndTime: eventsStart.Add(3 * time.Minute)},
+			expected: []string{"/docker/a@1", "/docker/ab@2", "/system@3"},
+		},
+		{
+			name:     "most recent",
+			request:  events.Request{EventType: allEventTypes(), MaxEventsReturned: 2},
+			expected: []string{"/system@3", "/docker/a@4"},
+		},
+	}
+	for _, c := range cases {
+		eventList, err := storage.Events(&c.request)
+		require.NoError(t, err, c.name)
+		assert.Equal(t, c.expected, describeEvents(eventList), c.name)
+	}
+}
+
+func TestStatsRoundTrip(t *testing.T) {
+	storage, dir := newTestStorage(t)
+	defer os.RemoveAll(dir)
+	defer storage.Close()
+
+	ref := info.ContainerReference{Name: "/docker/a", Aliases: []string{"a"}, Namespace: "docker"}
+	other := info.ContainerReference{Name: "/system"}
+	for i := 0; i < 3; i++ {
+		stats := &info.ContainerStats{Timestamp: eventsStart.Add(time.Duration(i) * time.Second)}
+		stats.Cpu.Usage.Total = uint64(i)
+		require.NoError(t, storage.AddStats(ref, stats))
+		require.NoError(t, storage.AddStats(other, stats))
+	}
+
+	statsList, err := storage.RecentStats("/docker/a", 2)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(statsList))
+	assert.Equal(t, uint64(1), statsList[0].Cpu.Usage.Total)
+	assert.Equal(t, uint64(2), statsList[1].Cpu.Usage.Total)
+
+	statsList, err = storage.RecentStats("/docker/a", -1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, len(statsList))
+}
+
+func TestTruncatedLastLineIsIgnored(t *testing.T) {
+	storage, dir := newTestStorage(t)
+	defer os.RemoveAll(dir)
+	defer storage.Close()
+	addTestEvents(t, storage)
+
+	file, err := os.OpenFile(path.Join(dir, eventsFileName), os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"ContainerName":"/cut`)
+	require.NoError(t, err)
+	file.Close()
+
+	request := events.NewRequest()
+	request.EventType = allEventTypes()
+	eventList, err := storage.Events(request)
+	require.NoError(t, err)
+	assert.Equal(t, 5, len(eventList))
+}
+
+// Appends the contents to the events file of the storage in dir, as a crash would.
+func appendToEventsFile(t *testing.T, dir string, contents string) {
+	file, err := os.OpenFile(path.Join(dir, eventsFileName), os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	defer file.Close()
+	_, err = file.WriteString(contents)
+	require.NoError(t, err)
+}
+
+func TestAppendingAfterATruncatedLine(t *testing.T) {
+	storage, dir := newTestStorage(t)
+	defer os.RemoveAll(dir)
+	addTestEvents(t, storage)
+	require.NoError(t, storage.Close())
+	appendToEventsFile(t, dir, `{"ContainerName":"/cut`)
+
+	// The next run does not extend the line cut short.
+	storage, err := New(dir, 0)
+	require.NoError(t, err)
+	defer storage.Close()
+	require.NoError(t, storage.AddEvent(&events.Event{ContainerName: "/late", Timestamp: eventsStart.Add(5 * time.Minute), EventType: events.TypeContainerCreation}))
+
+	request := events.NewRequest()
+	request.EventType = allEventTypes()
+	eventList, err := storage.Events(request)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/docker/a@0", "/docker/a@1", "/docker/ab@2", "/system@3", "/docker/a@4", "/late@5"}, describeEvents(eventList))
+	assert.Equal(t, uint64(1), storage.SkippedLines())
+}
+
+func TestMalformedLinesAreSkipped(t *testing.T) {
+	storage, dir := newTestStorage(t)
+	defer os.RemoveAll(dir)
+	defer storage.Close()
+	require.NoError(t, storage.AddEvent(&events.Event{ContainerName: "/first", Timestamp: eventsStart, EventType: events.TypeContainerCreation}))
+	appendToEventsFile(t, dir, "not json\n")
+	require.NoError(t, storage.AddEvent(&events.Event{ContainerName: "/second", Timestamp: eventsStart.Add(time.Minute), EventType: events.TypeContainerCreation}))
+
+	request := events.NewRequest()
+	request.EventType = allEventTypes()
+	eventList, err := storage.Events(request)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/first@0", "/second@1"}, describeEvents(eventList))
+	assert.Equal(t, uint64(1), storage.SkippedLines())
+}
+
+// Returns the number of lines of the file.
+func countLines(t *testing.T, name string) int {
+	contents, err := ioutil.ReadFile(name)
+	require.NoError(t, err)
+	return strings.Count(string(contents), "\n")
+}
+
+func TestEntriesPastTheRetentionAreDropped(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-storage")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	now := eventsStart
+	clock := func() time.Time {
+		return now
+	}
+	storage, err := newFileStorage(dir, 3*time.Hour, clock)
+	require.NoError(t, err)
+	ref := info.ContainerReference{Name: "/docker/a"}
+	for i := 0; i < 4; i++ {
+		timestamp := eventsStart.Add(time.Duration(i) * time.Hour)
+		require.NoError(t, storage.AddEvent(&events.Event{ContainerName: fmt.Sprintf("/%d", i), Timestamp: timestamp, EventType: events.TypeContainerCreation}))
+		require.NoError(t, storage.AddStats(ref, &info.ContainerStats{Timestamp: timestamp}))
+	}
+
+	// Reads leave out the entries past the retention.
+	now = eventsStart.Add(4*time.Hour + time.Minute)
+	request := events.NewRequest()
+	request.EventType = allEventTypes()
+	eventList, err := storage.Events(request)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/2@120", "/3@180"}, describeEvents(eventList))
+	statsList, err := storage.RecentStats("/docker/a", -1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(statsList))
+
+	// The files are compacted at the next write once an hour passed.
+	assert.Equal(t, 4, countLines(t, path.Join(dir, eventsFileName)))
+	require.NoError(t, storage.AddEvent(&events.Event{ContainerName: "/4", Timestamp: now, EventType: events.TypeContainerCreation}))
+	assert.Equal(t, 3, countLines(t, path.Join(dir, eventsFileName)))
+	assert.Equal(t, 2, countLines(t, path.Join(dir, statsFileName)))
+	require.NoError(t, storage.Close())
+
+	// And when the driver is created.
+	now = now.Add(time.Hour)
+	storage, err = newFileStorage(dir, 3*time.Hour, clock)
+	require.NoError(t, err)
+	defer storage.Close()
+	assert.Equal(t, 2, countLines(t, path.Join(dir, eventsFileName)))
+	assert.Equal(t, 1, countLines(t, path.Join(dir, statsFileName)))
+}
@@ -0,0 +1,232 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var filteredTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "cadvisor",
+	Subsystem: "storage",
+	Name:      "filtered_writes_total",
+	Help:      "Number of stats not written to the storage driver because its filter does not select their container.",
+}, []string{"driver"})
+
+// Prefix of the selectors of a ContainerFilter which select a namespace.
+const namespaceSelector = "namespace="
+
+// Selects the containers whose stats are written to a storage driver. A
+// container is selected by any of the selectors.
+type ContainerFilter struct {
+	// Names selecting the containers they are the name of or a parent of,
+	// e.g.: /docker selects /docker and /docker/<id> but not /dockerd.
+	Prefixes []string
+
+	// Namespaces of the aliases of the containers, e.g.: docker.
+	Namespaces []string
+}
+
+// Whether the filter selects the container.
+func (self *ContainerFilter) Matches(ref info.ContainerReference) bool {
+	for _, prefix := range self.Prefixes {
+		if prefix == "/" || ref.Name == prefix || strings.HasPrefix(ref.Name, prefix+"/") {
+			return true
+		}
+	}
+	for _, namespace := range self.Namespaces {
+		if ref.Namespace == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// The selectors of the filter, e.g.: /docker,namespace=docker.
+func (self *ContainerFilter) String() string {
+	selectors := append([]string(nil), self.Prefixes...)
+	for _, namespace := range self.Namespaces {
+		selectors = append(selectors, namespaceSelector+namespace)
+	}
+	return strings.Join(selectors, ",")
+}
+
+// Parses the filters of the storage drivers, e.g.:
+// influxdb:/docker,bigquery:namespace=docker. Each comma-separated item is a
+// driver and a selector: a container name, which selects it and its
+// subcontainers, or namespace= and the namespace of the aliases of the
+// containers it selects. The selectors of the same driver are combined.
+// Only the specified drivers may have filters.
+func ParseFilters(value string, drivers []string) (map[string]*ContainerFilter, error) {
+	known := make(map[string]bool, len(drivers))
+	for _, driver := range drivers {
+		known[driver] = true
+	}
+	filters := make(map[string]*ContainerFilter)
+	if value == "" {
+		return filters, nil
+	}
+	for _, item := range strings.Split(value, ",") {
+		parts := strings.SplitN(item, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid filter %q, expected <driver>:<selector>", item)
+		}
+		driver, selector := parts[0], parts[1]
+		if !known[driver] {
+			return nil, fmt.Errorf("invalid filter %q, storage driver %q is not in use", item, driver)
+		}
+		filter, ok := filters[driver]
+		if !ok {
+			filter = &ContainerFilter{}
+			filters[driver] = filter
+		}
+		switch {
+		case strings.HasPrefix(selector, namespaceSelector):
+			namespace := strings.TrimPrefix(selector, namespaceSelector)
+			if namespace == "" {
+				return nil, fmt.Errorf("invalid filter %q, the namespace is empty", item)
+			}
+			filter.Namespaces = append(filter.Namespaces, namespace)
+		case strings.HasPrefix(selector, "/"):
+			if path.Clean(selector) != selector {
+				return nil, fmt.Errorf("invalid filter %q, container name %q is not clean, expected %q", item, selector, path.Clean(selector))
+			}
+			filter.Prefixes = append(filter.Prefixes, selector)
+		default:
+			return nil, fmt.Errorf("invalid filter %q, expected a container name starting with / or namespace=<namespace>", item)
+		}
+	}
+	return filters, nil
+}
+
+// A filter in use and the stats it kept from its storage driver.
+type FilterStatus struct {
+	// Name of the driver, e.g.: bigquery.
+	Name string
+
+	// The selectors of the filter, e.g.: /docker,namespace=docker.
+	Filter string
+
+	// Number of stats not written to the driver.
+	Filtered uint64
+}
+
+// A storage driver which only writes the stats of the containers selected by
+// a filter to the driver it wraps. Class is thread-safe.
+type FilteredDriver struct {
+	// Number of stats not written. First for the alignment of atomic operations.
+	filtered uint64
+
+	driver StorageDriver
+	name   string
+	filter *ContainerFilter
+	// The registry the driver is in until it is closed.
+	registry *filterRegistry
+}
+
+// The filtered drivers not closed yet.
+type filterRegistry struct {
+	lock    sync.Mutex
+	drivers []*FilteredDriver
+}
+
+// The registry of the drivers returned by Filter().
+var defaultFilterRegistry = &filterRegistry{}
+
+// Returns the specified driver, named e.g. bigquery, only written the stats
+// of the containers the filter selects. The filters in use are reported by
+// FilterStatuses() until the driver is closed.
+func Filter(name string, driver StorageDriver, filter *ContainerFilter) *FilteredDriver {
+	return defaultFilterRegistry.filter(name, driver, filter)
+}
+
+func (self *filterRegistry) filter(name string, driver StorageDriver, filter *ContainerFilter) *FilteredDriver {
+	filtered := &FilteredDriver{
+		driver:   driver,
+		name:     name,
+		filter:   filter,
+		registry: self,
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.drivers = append(self.drivers, filtered)
+	return filtered
+}
+
+func (self *filterRegistry) remove(driver *FilteredDriver) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for i, d := range self.drivers {
+		if d == driver {
+			self.drivers = append(self.drivers[:i], self.drivers[i+1:]...)
+			return
+		}
+	}
+}
+
+func (self *filterRegistry) statuses() []FilterStatus {
+	self.lock.Lock()
+	drivers := append([]*FilteredDriver(nil), self.drivers...)
+	self.lock.Unlock()
+	ret := make([]FilterStatus, 0, len(drivers))
+	for _, driver := range drivers {
+		ret = append(ret, driver.Status())
+	}
+	return ret
+}
+
+func (self *FilteredDriver) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	if !self.filter.Matches(ref) {
+		atomic.AddUint64(&self.filtered, 1)
+		filteredTotal.WithLabelValues(self.name).Inc()
+		return nil
+	}
+	return self.driver.AddStats(ref, stats)
+}
+
+func (self *FilteredDriver) RecentStats(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	return self.driver.RecentStats(containerName, numStats)
+}
+
+func (self *FilteredDriver) VerifyConnection() error {
+	return self.driver.VerifyConnection()
+}
+
+// Closes the driver, whose filter is no longer reported.
+func (self *FilteredDriver) Close() error {
+	self.registry.remove(self)
+	return self.driver.Close()
+}
+
+// Returns the filter and the number of stats it kept from the driver.
+func (self *FilteredDriver) Status() FilterStatus {
+	return FilterStatus{
+		Name:     self.name,
+		Filter:   self.filter.String(),
+		Filtered: atomic.LoadUint64(&self.filtered),
+	}
+}
+
+// Returns the filters passed to Filter() whose driver is not closed.
+func FilterStatuses() []FilterStatus {
+	return defaultFilterRegistry.statuses()
+}
@@ -0,0 +1,165 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+func TestParseFilters(t *testing.T) {
+	filters, err := ParseFilters("influxdb:/docker,bigquery:/docker,bigquery:namespace=rkt", []string{"influxdb", "bigquery", "file"})
+	if err != nil {
+		t.Fatalf("Failed to parse the filters: %v", err)
+	}
+	expected := map[string]*ContainerFilter{
+		"influxdb": {Prefixes: []string{"/docker"}},
+		"bigquery": {Prefixes: []string{"/docker"}, Namespaces: []string{"rkt"}},
+	}
+	if !reflect.DeepEqual(filters, expected) {
+		t.Errorf("Expected filters %+v, got %+v", expected, filters)
+	}
+	if s := filters["bigquery"].String(); s != "/docker,namespace=rkt" {
+		t.Errorf("Expected the bigquery filter to be /docker,namespace=rkt, got %q", s)
+	}
+
+	filters, err = ParseFilters("", nil)
+	if err != nil || len(filters) != 0 {
+		t.Errorf("Expected no filters, got %+v and error %v", filters, err)
+	}
+}
+
+func TestParseFiltersInvalid(t *testing.T) {
+	for _, value := range []string{
+		"influxdb",
+		"influxdb:",
+		":/docker",
+		// Not in use.
+		"file:/docker",
+		"influxdb:docker",
+		"influxdb:/docker/",
+		"influxdb:/docker/../system",
+		"influxdb:namespace=",
+		"influxdb:/docker,",
+	} {
+		if filters, err := ParseFilters(value, []string{"influxdb", "bigquery"}); err == nil {
+			t.Errorf("Expected %q to be invalid, got %+v", value, filters)
+		}
+	}
+}
+
+func TestContainerFilterMatches(t *testing.T) {
+	filter := &ContainerFilter{
+		Prefixes:   []string{"/docker"},
+		Namespaces: []string{"rkt"},
+	}
+	for _, test := range []struct {
+		ref     info.ContainerReference
+		matches bool
+	}{
+		{info.ContainerReference{Name: "/docker"}, true},
+		{info.ContainerReference{Name: "/docker/abc", Aliases: []string{"web"}, Namespace: "docker"}, true},
+		{info.ContainerReference{Name: "/docker/_short_lived"}, true},
+		{info.ContainerReference{Name: "/dockerd"}, false},
+		{info.ContainerReference{Name: "/"}, false},
+		{info.ContainerReference{Name: "/machine.slice/pod", Namespace: "rkt"}, true},
+	} {
+		if matches := filter.Matches(test.ref); matches != test.matches {
+			t.Errorf("Expected %q in namespace %q to match %v, got %v", test.ref.Name, test.ref.Namespace, test.matches, matches)
+		}
+	}
+	root := &ContainerFilter{Prefixes: []string{"/"}}
+	if !root.Matches(info.ContainerReference{Name: "/system.slice"}) {
+		t.Errorf("Expected / to select all the containers")
+	}
+}
+
+// Returns the names of the containers written to the driver.
+func writtenNames(driver *recordingDriver) []string {
+	names := make([]string, 0, len(driver.refs))
+	for _, ref := range driver.refs {
+		names = append(names, ref.Name)
+	}
+	return names
+}
+
+func TestFiltersRouteStats(t *testing.T) {
+	influxdb := &recordingDriver{}
+	bigquery := &recordingDriver{}
+	file := &recordingDriver{}
+	registry := &filterRegistry{}
+	dockerFilter := registry.filter("influxdb", influxdb, &ContainerFilter{Prefixes: []string{"/docker"}})
+	systemFilter := registry.filter("bigquery", bigquery, &ContainerFilter{Prefixes: []string{"/system.slice"}, Namespaces: []string{"rkt"}})
+	driver := Multi(dockerFilter, systemFilter, file)
+
+	refs := []info.ContainerReference{
+		{Name: "/"},
+		{Name: "/docker/abc", Aliases: []string{"web"}, Namespace: "docker"},
+		{Name: "/system.slice/sshd.service"},
+		{Name: "/machine.slice/pod", Namespace: "rkt"},
+	}
+	for _, ref := range refs {
+		if err := driver.AddStats(ref, dedupStats(0, 100, 1000)); err != nil {
+			t.Fatalf("Failed to add the stats of %q: %v", ref.Name, err)
+		}
+	}
+
+	if names := writtenNames(influxdb); !reflect.DeepEqual(names, []string{"/docker/abc"}) {
+		t.Errorf("Expected influxdb to be written /docker/abc, got %v", names)
+	}
+	if names := writtenNames(bigquery); !reflect.DeepEqual(names, []string{"/system.slice/sshd.service", "/machine.slice/pod"}) {
+		t.Errorf("Expected bigquery to be written /system.slice/sshd.service and /machine.slice/pod, got %v", names)
+	}
+	if len(file.refs) != len(refs) {
+		t.Errorf("Expected the driver without filters to be written all %d containers, got %v", len(refs), writtenNames(file))
+	}
+
+	if status := dockerFilter.Status(); status != (FilterStatus{Name: "influxdb", Filter: "/docker", Filtered: 3}) {
+		t.Errorf("Unexpected influxdb filter status %+v", status)
+	}
+	if status := systemFilter.Status(); status != (FilterStatus{Name: "bigquery", Filter: "/system.slice,namespace=rkt", Filtered: 2}) {
+		t.Errorf("Unexpected bigquery filter status %+v", status)
+	}
+	if statuses := registry.statuses(); !reflect.DeepEqual(statuses, []FilterStatus{dockerFilter.Status(), systemFilter.Status()}) {
+		t.Errorf("Expected both filters to be reported, got %+v", statuses)
+	}
+
+	// Closed drivers are no longer reported.
+	if err := dockerFilter.Close(); err != nil {
+		t.Fatalf("Failed to close the influxdb filter: %v", err)
+	}
+	if statuses := registry.statuses(); !reflect.DeepEqual(statuses, []FilterStatus{systemFilter.Status()}) {
+		t.Errorf("Expected only the bigquery filter to be reported, got %+v", statuses)
+	}
+}
+
+func TestMultiWritesToAllDrivers(t *testing.T) {
+	failing := &recordingDriver{fakeDriver: fakeDriver{err: errors.New("unavailable")}}
+	working := &recordingDriver{}
+	driver := Multi(failing, working)
+	err := driver.AddStats(info.ContainerReference{Name: "/"}, dedupStats(0, 100, 1000))
+	if err == nil {
+		t.Errorf("Expected the error of the failing driver")
+	}
+	if len(working.added) != 1 {
+		t.Errorf("Expected the other driver to be written the stats despite the error, got %d stats", len(working.added))
+	}
+	if err := driver.VerifyConnection(); err == nil {
+		t.Errorf("Expected the verification of the failing driver to fail")
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package influxdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/cadvisor/events"
+	"github.com/google/cadvisor/storage"
+	influxdb "github.com/influxdb/influxdb/client"
+)
+
+const (
+	// Type of the event, see events.EventType.
+	colEventType string = "event_type"
+	// Data of the event as JSON.
+	colEventData string = "event_data"
+)
+
+// Events are written to their own series, next to the stats.
+func (self *influxdbStorage) eventsTableName() string {
+	return self.tableName + "_events"
+}
+
+// Events are rare so, unlike stats, they are written right away.
+func (self *influxdbStorage) AddEvent(e *events.Event) error {
+	data, err := json.Marshal(e.EventData)
+	if err != nil {
+		return err
+	}
+	series := &influxdb.Series{
+		Name:    self.eventsTableName(),
+		Columns: []string{colTimestamp, colMachineName, colContainerName, colEventType, colEventData},
+		Points: [][]interface{}{
+			{e.Timestamp.UnixNano() / 1e3, self.machineName, e.ContainerName, int(e.EventType), string(data)},
+		},
+	}
+	err = self.client.WriteSeriesWithTimePrecision([]*influxdb.Series{series}, influxdb.Microsecond)
+	if err != nil {
+		return fmt.Errorf("failed to write event to influxDb - %s", err)
+	}
+	return nil
+}
+
+func (self *influxdbStorage) Events(request *events.Request) (events.EventSlice, error) {
+	query := fmt.Sprintf("select * from %v where %v=%v", self.eventsTableName(), colMachineName, storage.QuoteString(self.machineName))
+	series, err := self.client.Query(query, influxdb.Microsecond)
+	if err != nil {
+		return nil, err
+	}
+	eventList := events.EventSlice{}
+	for _, s := range series {
+		for _, values := range s.Points {
+			e, err := valuesToEvent(s.Columns, values)
+			if err != nil {
+				return nil, err
+			}
+			eventList = append(eventList, e)
+		}
+	}
+	return events.FilterEvents(request, eventList), nil
+}
+
+func valuesToEvent(columns []string, values []interface{}) (*events.Event, error) {
+	e := &events.Event{}
+	for i, col := range columns {
+		v := values[i]
+		switch col {
+		case colTimestamp:
+			if microseconds, ok := v.(float64); ok {
+				e.Timestamp = time.Unix(0, int64(microseconds)*1e3)
+			}
+		case colContainerName:
+			if name, ok := v.(string); ok {
+				e.ContainerName = name
+			}
+		case colEventType:
+			if eventType, ok := v.(float64); ok {
+				e.EventType = events.EventType(eventType)
+			}
+		case colEventData:
+			if data, ok := v.(string); ok {
+				err := json.Unmarshal([]byte(data), &e.EventData)
+				if err != nil {
+					return nil, fmt.Errorf("event data field is not JSON: %v", err)
+				}
+			}
+		}
+	}
+	return e, nil
+}
@@ -16,16 +16,23 @@ package influxdb
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/storage"
 	influxdb "github.com/influxdb/influxdb/client"
 )
 
 type influxdbStorage struct {
 	client         *influxdb.Client
+	database       string
+	username       string
+	password       string
 	machineName    string
+	staticTags     map[string]string
+	tagColumns     []string
 	tableName      string
 	bufferDuration time.Duration
 	lastWrite      time.Time
@@ -72,6 +79,12 @@ func (self *influxdbStorage) getSeriesDefaultValues(
 	*columns = append(*columns, colMachineName)
 	*values = append(*values, self.machineName)
 
+	// Static machine tags
+	for _, tag := range self.tagColumns {
+		*columns = append(*columns, tag)
+		*values = append(*values, self.staticTags[tag])
+	}
+
 	// Container name
 	*columns = append(*columns, colContainerName)
 	if len(ref.Aliases) > 0 {
@@ -288,8 +301,7 @@ func (self *influxdbStorage) RecentStats(containerName string, numStats int) ([]
 		return nil, nil
 	}
 	// TODO(dengnan): select only columns that we need
-	// TODO(dengnan): escape names
-	query := fmt.Sprintf("select * from %v where %v='%v' and %v='%v'", self.tableName, colContainerName, containerName, colMachineName, self.machineName)
+	query := fmt.Sprintf("select * from %v where %v=%v and %v=%v", self.tableName, colContainerName, storage.QuoteString(containerName), colMachineName, storage.QuoteString(self.machineName))
 	if numStats > 0 {
 		query = fmt.Sprintf("%v limit %v", query, numStats)
 	}
@@ -319,6 +331,18 @@ func (self *influxdbStorage) RecentStats(containerName string, numStats int) ([]
 	return statsList, nil
 }
 
+// Checks that InfluxDB is reachable and that the user can authenticate to
+// the database.
+func (self *influxdbStorage) VerifyConnection() error {
+	if err := self.client.Ping(); err != nil {
+		return fmt.Errorf("failed to reach InfluxDB: %v", err)
+	}
+	if err := self.client.AuthenticateDatabaseUser(self.database, self.username, self.password); err != nil {
+		return fmt.Errorf("failed to authenticate as %q to database %q: %v", self.username, self.database, err)
+	}
+	return nil
+}
+
 func (self *influxdbStorage) Close() error {
 	self.client = nil
 	return nil
@@ -338,8 +362,10 @@ func (self *influxdbStorage) newSeries(columns []string, points []interface{}) *
 
 // machineName: A unique identifier to identify the host that current cAdvisor
 // instance is running on.
+// staticTags: Attributes of the host (e.g.: kernel_version) added as columns to every point.
 // influxdbHost: The host which runs influxdb.
-func New(machineName,
+func New(machineName string,
+	staticTags map[string]string,
 	tablename,
 	database,
 	username,
@@ -364,12 +390,20 @@ func New(machineName,
 
 	ret := &influxdbStorage{
 		client:         client,
+		database:       database,
+		username:       username,
+		password:       password,
 		machineName:    machineName,
+		staticTags:     staticTags,
 		tableName:      tablename,
 		bufferDuration: bufferDuration,
 		lastWrite:      time.Now(),
 		series:         make([]*influxdb.Series, 0),
 	}
+	for tag := range staticTags {
+		ret.tagColumns = append(ret.tagColumns, tag)
+	}
+	sort.Strings(ret.tagColumns)
 	ret.readyToFlush = ret.defaultReadyToFlush
 	return ret, nil
 }
@@ -62,6 +62,10 @@ func (self *influxDbTestStorageDriver) Samples(containerName string, numSamples
 	return self.base.Samples(containerName, numSamples)
 }
 
+func (self *influxDbTestStorageDriver) VerifyConnection() error {
+	return self.base.VerifyConnection()
+}
+
 func (self *influxDbTestStorageDriver) Close() error {
 	return self.base.Close()
 }
@@ -134,6 +138,7 @@ func runStorageTest(f func(test.TestStorageDriver, *testing.T), t *testing.T, bu
 	defer client.Query(deleteAll)
 
 	driver, err := New(machineName,
+		nil,
 		tablename,
 		database,
 		username,
@@ -154,6 +159,7 @@ func runStorageTest(f func(test.TestStorageDriver, *testing.T), t *testing.T, bu
 
 	// generate another container's data on another machine.
 	driverForAnotherMachine, err := New("machineB",
+		nil,
 		tablename,
 		database,
 		username,
@@ -0,0 +1,104 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package influxdb
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A point of the InfluxDB line protocol: a measurement, tags identifying the
+// series and fields holding the values, e.g.:
+//
+//	memory,container_name=/docker/abc,machine_id=m1 usage=1024i,working_set=512i 1434055562000000000
+type point struct {
+	measurement string
+	tags        map[string]string
+	// Values are int64, uint64, float64, bool or string.
+	fields    map[string]interface{}
+	timestamp time.Time
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	keyEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	stringEscaper      = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+// Appends the point as a line of the protocol to buf. Tags and fields are
+// sorted by key, as InfluxDB prefers, and tags with empty values, which it
+// rejects, are left out. The timestamp is in nanoseconds.
+func (self *point) appendLine(buf *bytes.Buffer) {
+	buf.WriteString(measurementEscaper.Replace(self.measurement))
+	for _, key := range sortedKeys(self.tags) {
+		if self.tags[key] == "" {
+			continue
+		}
+		buf.WriteByte(',')
+		buf.WriteString(keyEscaper.Replace(key))
+		buf.WriteByte('=')
+		buf.WriteString(keyEscaper.Replace(self.tags[key]))
+	}
+	fieldKeys := make([]string, 0, len(self.fields))
+	for key := range self.fields {
+		fieldKeys = append(fieldKeys, key)
+	}
+	sort.Strings(fieldKeys)
+	for i, key := range fieldKeys {
+		if i == 0 {
+			buf.WriteByte(' ')
+		} else {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(keyEscaper.Replace(key))
+		buf.WriteByte('=')
+		appendFieldValue(buf, self.fields[key])
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.FormatInt(self.timestamp.UnixNano(), 10))
+	buf.WriteByte('\n')
+}
+
+// Integers are suffixed with an i, otherwise InfluxDB stores them as floats.
+func appendFieldValue(buf *bytes.Buffer, value interface{}) {
+	switch v := value.(type) {
+	case int64:
+		buf.WriteString(strconv.FormatInt(v, 10))
+		buf.WriteByte('i')
+	case uint64:
+		buf.WriteString(strconv.FormatUint(v, 10))
+		buf.WriteByte('i')
+	case float64:
+		buf.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case string:
+		buf.WriteByte('"')
+		buf.WriteString(stringEscaper.Replace(v))
+		buf.WriteByte('"')
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
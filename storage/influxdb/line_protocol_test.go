@@ -0,0 +1,59 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package influxdb
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func lineOf(p *point) string {
+	var buf bytes.Buffer
+	p.appendLine(&buf)
+	return buf.String()
+}
+
+func TestPointLineEscaping(t *testing.T) {
+	p := &point{
+		measurement: "my measurement,1",
+		tags: map[string]string{
+			"container_name": "/system.slice/a b,c=d",
+			"empty":          "",
+		},
+		fields: map[string]interface{}{
+			"data": `{"a": "b\c"}`,
+		},
+		timestamp: time.Unix(1, 5),
+	}
+	assert.Equal(t, `my\ measurement\,1,container_name=/system.slice/a\ b\,c\=d data="{\"a\": \"b\\c\"}" 1000000005`+"\n", lineOf(p))
+}
+
+func TestPointLineFieldTypes(t *testing.T) {
+	p := &point{
+		measurement: "m",
+		tags:        map[string]string{"b": "2", "a": "1"},
+		fields: map[string]interface{}{
+			"int":   int64(-3),
+			"uint":  uint64(18446744073709551615),
+			"float": 0.5,
+			"bool":  true,
+		},
+		timestamp: time.Unix(0, 0),
+	}
+	assert.Equal(t, "m,a=1,b=2 bool=true,float=0.5,int=-3i,uint=18446744073709551615i 0\n", lineOf(p))
+}
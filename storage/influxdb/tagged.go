@@ -0,0 +1,581 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package influxdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/storage"
+)
+
+// Measurements of the tagged schema, one per group of stats.
+const (
+	measurementCpu        = "cpu"
+	measurementMemory     = "memory"
+	measurementNetwork    = "network"
+	measurementFilesystem = "filesystem"
+	measurementEvents     = "events"
+)
+
+// Tags of the points of the tagged schema, besides the static tags of the machine.
+const (
+	tagMachineId     = "machine_id"
+	tagContainerName = "container_name"
+	// First alias of the container, if any (e.g.: the name of a Docker container).
+	tagAlias = "alias"
+	// Device of the filesystem.
+	tagDevice = "device"
+)
+
+// Time after which requests to InfluxDB are abandoned.
+const requestTimeout = 30 * time.Second
+
+// Stores the stats with the line protocol of InfluxDB 0.9 and later: a
+// measurement per group of stats (e.g.: memory) with the machine and the
+// container as tags and the values as fields. Unlike the legacy schema, the
+// container names are not part of series names.
+type taggedStorage struct {
+	httpClient      *http.Client
+	baseUrl         string
+	database        string
+	retentionPolicy string
+	username        string
+	password        string
+	createDatabase  bool
+	// Tags of all the points: the static tags of the machine and its ID.
+	machineTags    map[string]string
+	machineId      string
+	bufferDuration time.Duration
+	batchSize      int
+	lastWrite      time.Time
+	points         []*point
+	lock           sync.Mutex
+	readyToFlush   func() bool
+}
+
+// Returns the tags of the points of the container.
+func (self *taggedStorage) containerTags(ref info.ContainerReference) map[string]string {
+	tags := make(map[string]string, len(self.machineTags)+2)
+	for key, value := range self.machineTags {
+		tags[key] = value
+	}
+	tags[tagContainerName] = ref.Name
+	if len(ref.Aliases) > 0 {
+		tags[tagAlias] = ref.Aliases[0]
+	}
+	return tags
+}
+
+// Converts the stats to a point per measurement, and one per filesystem.
+// Sections which failed to be collected are left out rather than written as zeros.
+func (self *taggedStorage) statsToPoints(ref info.ContainerReference, stats *info.ContainerStats) []*point {
+	tags := self.containerTags(ref)
+	var points []*point
+	add := func(measurement string, tags map[string]string, fields map[string]interface{}) {
+		points = append(points, &point{
+			measurement: measurement,
+			tags:        tags,
+			fields:      fields,
+			timestamp:   stats.Timestamp,
+		})
+	}
+	if !stats.Missing(info.StatsSectionCpu) {
+		add(measurementCpu, tags, map[string]interface{}{
+			"usage_total":  stats.Cpu.Usage.Total,
+			"usage_user":   stats.Cpu.Usage.User,
+			"usage_system": stats.Cpu.Usage.System,
+			"load_average": int64(stats.Cpu.LoadAverage),
+		})
+	}
+	if !stats.Missing(info.StatsSectionMemory) {
+		add(measurementMemory, tags, map[string]interface{}{
+			"usage":       stats.Memory.Usage,
+			"working_set": stats.Memory.WorkingSet,
+			"swap":        stats.Memory.Swap,
+			"pgfault":     stats.Memory.HierarchicalData.Pgfault,
+			"pgmajfault":  stats.Memory.HierarchicalData.Pgmajfault,
+		})
+	}
+	if !stats.Missing(info.StatsSectionNetwork) {
+		add(measurementNetwork, tags, map[string]interface{}{
+			"rx_bytes":   stats.Network.RxBytes,
+			"rx_packets": stats.Network.RxPackets,
+			"rx_errors":  stats.Network.RxErrors,
+			"rx_dropped": stats.Network.RxDropped,
+			"tx_bytes":   stats.Network.TxBytes,
+			"tx_packets": stats.Network.TxPackets,
+			"tx_errors":  stats.Network.TxErrors,
+			"tx_dropped": stats.Network.TxDropped,
+		})
+	}
+	if !stats.Missing(info.StatsSectionFilesystem) {
+		for _, fs := range stats.Filesystem {
+			fsTags := make(map[string]string, len(tags)+1)
+			for key, value := range tags {
+				fsTags[key] = value
+			}
+			fsTags[tagDevice] = fs.Device
+			add(measurementFilesystem, fsTags, map[string]interface{}{
+				"limit": fs.Limit,
+				"usage": fs.Usage,
+			})
+		}
+	}
+	return points
+}
+
+func (self *taggedStorage) OverrideReadyToFlush(readyToFlush func() bool) {
+	self.readyToFlush = readyToFlush
+}
+
+func (self *taggedStorage) defaultReadyToFlush() bool {
+	return time.Since(self.lastWrite) >= self.bufferDuration
+}
+
+// Points are buffered until the buffer duration elapsed or a batch is full.
+func (self *taggedStorage) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	if stats == nil {
+		return nil
+	}
+	var pointsToFlush []*point
+	func() {
+		// AddStats will be invoked simultaneously from multiple threads and only one of them will perform a write.
+		self.lock.Lock()
+		defer self.lock.Unlock()
+
+		self.points = append(self.points, self.statsToPoints(ref, stats)...)
+		if len(self.points) >= self.batchSize || self.readyToFlush() {
+			pointsToFlush = self.points
+			self.points = nil
+			self.lastWrite = time.Now()
+		}
+	}()
+	if err := self.writePoints(pointsToFlush); err != nil {
+		return fmt.Errorf("failed to write stats to influxDb - %s", err)
+	}
+	return nil
+}
+
+// Writes the points in requests of at most batchSize points.
+func (self *taggedStorage) writePoints(points []*point) error {
+	for len(points) > 0 {
+		n := len(points)
+		if n > self.batchSize {
+			n = self.batchSize
+		}
+		var buf bytes.Buffer
+		for _, p := range points[:n] {
+			p.appendLine(&buf)
+		}
+		query := url.Values{}
+		query.Set("db", self.database)
+		if self.retentionPolicy != "" {
+			query.Set("rp", self.retentionPolicy)
+		}
+		query.Set("precision", "n")
+		if _, err := self.do("POST", "/write", query, &buf); err != nil {
+			return err
+		}
+		points = points[n:]
+	}
+	return nil
+}
+
+// Makes a request to the HTTP API of InfluxDB as the user. Returns the body
+// of the response if it succeeded.
+func (self *taggedStorage) do(method, path string, query url.Values, body io.Reader) ([]byte, error) {
+	if self.username != "" {
+		query.Set("u", self.username)
+		query.Set("p", self.password)
+	}
+	req, err := http.NewRequest(method, self.baseUrl+path+"?"+query.Encode(), body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := self.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	out, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s %s failed with status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(out)))
+	}
+	return out, nil
+}
+
+// A series returned by a query.
+type querySeries struct {
+	Name    string          `json:"name"`
+	Columns []string        `json:"columns"`
+	Values  [][]interface{} `json:"values"`
+}
+
+type queryResponse struct {
+	Results []struct {
+		Series []querySeries `json:"series"`
+		Error  string        `json:"error"`
+	} `json:"results"`
+	Error string `json:"error"`
+}
+
+// Runs the InfluxQL statements, separated by semicolons, in the database.
+// Returns the series returned by each statement. Numbers are returned as
+// json.Number, times in nanoseconds.
+func (self *taggedStorage) query(method, statements string) ([][]querySeries, error) {
+	query := url.Values{}
+	query.Set("db", self.database)
+	query.Set("q", statements)
+	query.Set("epoch", "ns")
+	out, err := self.do(method, "/query", query, nil)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	decoder.UseNumber()
+	var resp queryResponse
+	if err := decoder.Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to decode response %q: %v", out, err)
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	ret := make([][]querySeries, 0, len(resp.Results))
+	for _, result := range resp.Results {
+		if result.Error != "" {
+			return nil, errors.New(result.Error)
+		}
+		ret = append(ret, result.Series)
+	}
+	return ret, nil
+}
+
+// Quotes the name of a database, measurement or retention policy, e.g.: my"db -> "my\"db".
+func quoteIdentifier(name string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name) + `"`
+}
+
+// Returns the value of the specified number as a uint64, zero if it is not one.
+func numberToUint64(v interface{}) uint64 {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0
+	}
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		return u
+	}
+	if f, err := n.Float64(); err == nil && f > 0 {
+		return uint64(f)
+	}
+	return 0
+}
+
+// Returns the values of a row of a series by column.
+func rowValues(columns []string, row []interface{}) map[string]interface{} {
+	values := make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		if i < len(row) {
+			values[column] = row[i]
+		}
+	}
+	return values
+}
+
+// Fills the section of the stats stored in the measurement from a row.
+func fillStats(stats *info.ContainerStats, measurement string, values map[string]interface{}) {
+	switch measurement {
+	case measurementCpu:
+		stats.Cpu.Usage.Total = numberToUint64(values["usage_total"])
+		stats.Cpu.Usage.User = numberToUint64(values["usage_user"])
+		stats.Cpu.Usage.System = numberToUint64(values["usage_system"])
+		stats.Cpu.LoadAverage = int32(numberToUint64(values["load_average"]))
+	case measurementMemory:
+		stats.Memory.Usage = numberToUint64(values["usage"])
+		stats.Memory.WorkingSet = numberToUint64(values["working_set"])
+		stats.Memory.Swap = numberToUint64(values["swap"])
+		stats.Memory.HierarchicalData.Pgfault = numberToUint64(values["pgfault"])
+		stats.Memory.HierarchicalData.Pgmajfault = numberToUint64(values["pgmajfault"])
+	case measurementNetwork:
+		stats.Network.RxBytes = numberToUint64(values["rx_bytes"])
+		stats.Network.RxPackets = numberToUint64(values["rx_packets"])
+		stats.Network.RxErrors = numberToUint64(values["rx_errors"])
+		stats.Network.RxDropped = numberToUint64(values["rx_dropped"])
+		stats.Network.TxBytes = numberToUint64(values["tx_bytes"])
+		stats.Network.TxPackets = numberToUint64(values["tx_packets"])
+		stats.Network.TxErrors = numberToUint64(values["tx_errors"])
+		stats.Network.TxDropped = numberToUint64(values["tx_dropped"])
+	case measurementFilesystem:
+		device, _ := values[tagDevice].(string)
+		stats.Filesystem = append(stats.Filesystem, info.FsStats{
+			Device: device,
+			Limit:  numberToUint64(values["limit"]),
+			Usage:  numberToUint64(values["usage"]),
+		})
+	}
+}
+
+// Selects the points of the container on this machine.
+func (self *taggedStorage) containerCondition(containerName string) string {
+	return fmt.Sprintf("%s = %s AND %s = %s", quoteIdentifier(tagMachineId), storage.QuoteString(self.machineId), quoteIdentifier(tagContainerName), storage.QuoteString(containerName))
+}
+
+// Reads the last numStats points of each measurement, then the filesystems
+// since the oldest of them.
+func (self *taggedStorage) RecentStats(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	if numStats == 0 {
+		return nil, nil
+	}
+	condition := self.containerCondition(containerName)
+	limit := ""
+	if numStats > 0 {
+		limit = fmt.Sprintf(" LIMIT %d", numStats)
+	}
+	var statements []string
+	for _, measurement := range []string{measurementCpu, measurementMemory, measurementNetwork} {
+		statements = append(statements, fmt.Sprintf("SELECT * FROM %s WHERE %s ORDER BY time DESC%s", quoteIdentifier(measurement), condition, limit))
+	}
+	results, err := self.query("GET", strings.Join(statements, "; "))
+	if err != nil {
+		return nil, err
+	}
+
+	// The stats by their timestamp in nanoseconds.
+	byTime := make(map[int64]*info.ContainerStats)
+	addRows := func(results [][]querySeries) {
+		for _, result := range results {
+			for _, series := range result {
+				for _, row := range series.Values {
+					values := rowValues(series.Columns, row)
+					timestamp := int64(numberToUint64(values["time"]))
+					stats, ok := byTime[timestamp]
+					if !ok {
+						stats = &info.ContainerStats{
+							Timestamp: time.Unix(0, timestamp),
+						}
+						byTime[timestamp] = stats
+					}
+					fillStats(stats, series.Name, values)
+				}
+			}
+		}
+	}
+	addRows(results)
+	if len(byTime) == 0 {
+		return nil, nil
+	}
+	timestamps := make([]int64, 0, len(byTime))
+	for timestamp := range byTime {
+		timestamps = append(timestamps, timestamp)
+	}
+	sort.Sort(int64Slice(timestamps))
+	// Measurements may have been written for different samples, e.g.: when a section failed.
+	if numStats > 0 && len(timestamps) > numStats {
+		timestamps = timestamps[len(timestamps)-numStats:]
+	}
+
+	results, err = self.query("GET", fmt.Sprintf("SELECT * FROM %s WHERE %s AND time >= %d", quoteIdentifier(measurementFilesystem), condition, timestamps[0]))
+	if err != nil {
+		return nil, err
+	}
+	addRows(results)
+
+	statsList := make([]*info.ContainerStats, 0, len(timestamps))
+	for _, timestamp := range timestamps {
+		statsList = append(statsList, byTime[timestamp])
+	}
+	return statsList, nil
+}
+
+type int64Slice []int64
+
+func (self int64Slice) Len() int           { return len(self) }
+func (self int64Slice) Less(i, j int) bool { return self[i] < self[j] }
+func (self int64Slice) Swap(i, j int)      { self[i], self[j] = self[j], self[i] }
+
+// Events are rare so, unlike stats, they are written right away. The type of
+// the event is a field rather than a tag, as in the legacy schema.
+func (self *taggedStorage) AddEvent(e *events.Event) error {
+	data, err := json.Marshal(e.EventData)
+	if err != nil {
+		return err
+	}
+	p := &point{
+		measurement: measurementEvents,
+		tags:        self.containerTags(info.ContainerReference{Name: e.ContainerName}),
+		fields: map[string]interface{}{
+			colEventType: int64(e.EventType),
+			colEventData: string(data),
+		},
+		timestamp: e.Timestamp,
+	}
+	if err := self.writePoints([]*point{p}); err != nil {
+		return fmt.Errorf("failed to write event to influxDb - %s", err)
+	}
+	return nil
+}
+
+func (self *taggedStorage) Events(request *events.Request) (events.EventSlice, error) {
+	results, err := self.query("GET", fmt.Sprintf("SELECT * FROM %s WHERE %s = %s", quoteIdentifier(measurementEvents), quoteIdentifier(tagMachineId), storage.QuoteString(self.machineId)))
+	if err != nil {
+		return nil, err
+	}
+	eventList := events.EventSlice{}
+	for _, result := range results {
+		for _, series := range result {
+			for _, row := range series.Values {
+				values := rowValues(series.Columns, row)
+				e := &events.Event{
+					Timestamp: time.Unix(0, int64(numberToUint64(values["time"]))),
+					EventType: events.EventType(numberToUint64(values[colEventType])),
+				}
+				e.ContainerName, _ = values[tagContainerName].(string)
+				if data, ok := values[colEventData].(string); ok {
+					if err := json.Unmarshal([]byte(data), &e.EventData); err != nil {
+						return nil, fmt.Errorf("event data field is not JSON: %v", err)
+					}
+				}
+				eventList = append(eventList, e)
+			}
+		}
+	}
+	return events.FilterEvents(request, eventList), nil
+}
+
+// Creates the database if asked to, then checks that the user can read it
+// and that it has the retention policy, if any.
+func (self *taggedStorage) VerifyConnection() error {
+	if self.createDatabase {
+		// Older versions of InfluxDB fail to create existing databases.
+		_, err := self.query("POST", "CREATE DATABASE "+quoteIdentifier(self.database))
+		if err != nil && !strings.Contains(err.Error(), "already exists") {
+			return fmt.Errorf("failed to create database %q: %v", self.database, err)
+		}
+	}
+	results, err := self.query("GET", "SHOW RETENTION POLICIES ON "+quoteIdentifier(self.database))
+	if err != nil {
+		return fmt.Errorf("failed to read database %q as %q: %v", self.database, self.username, err)
+	}
+	if self.retentionPolicy == "" {
+		return nil
+	}
+	for _, result := range results {
+		for _, series := range result {
+			for _, row := range series.Values {
+				if name, ok := rowValues(series.Columns, row)["name"].(string); ok && name == self.retentionPolicy {
+					return nil
+				}
+			}
+		}
+	}
+	return fmt.Errorf("database %q has no retention policy %q", self.database, self.retentionPolicy)
+}
+
+// Returns the time of the InfluxDB server, from the Date header of its answer
+// to a ping.
+func (self *taggedStorage) Time() (time.Time, error) {
+	resp, err := self.httpClient.Get(self.baseUrl + "/ping")
+	if err != nil {
+		return time.Time{}, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return time.Time{}, fmt.Errorf("ping failed with status %d", resp.StatusCode)
+	}
+	date := resp.Header.Get("Date")
+	if date == "" {
+		return time.Time{}, fmt.Errorf("ping answered without a Date header")
+	}
+	t, err := http.ParseTime(date)
+	if err != nil {
+		return time.Time{}, err
+	}
+	// The header is truncated to the second, the server is half a second
+	// later on average.
+	return t.Add(500 * time.Millisecond), nil
+}
+
+func (self *taggedStorage) Close() error {
+	return nil
+}
+
+// NewTagged returns a driver writing the stats with the line protocol of
+// InfluxDB 0.9 and later.
+// machineName: A unique identifier to identify the host that current cAdvisor
+// instance is running on, used as its machine_id when it has none.
+// staticTags: Attributes of the host (e.g.: kernel_version) added as tags to every point.
+// retentionPolicy: Retention policy the stats are written to, empty for the
+// default policy of the database.
+// createDatabase: Whether VerifyConnection creates the database.
+// batchSize: Largest number of points written at once.
+func NewTagged(machineName string,
+	staticTags map[string]string,
+	database,
+	retentionPolicy,
+	username,
+	password,
+	influxdbHost string,
+	isSecure bool,
+	createDatabase bool,
+	bufferDuration time.Duration,
+	batchSize int,
+) (*taggedStorage, error) {
+	if batchSize <= 0 {
+		return nil, fmt.Errorf("invalid batch size %d, it must be positive", batchSize)
+	}
+	scheme := "http"
+	if isSecure {
+		scheme = "https"
+	}
+	ret := &taggedStorage{
+		httpClient:      &http.Client{Timeout: requestTimeout},
+		baseUrl:         scheme + "://" + influxdbHost,
+		database:        database,
+		retentionPolicy: retentionPolicy,
+		username:        username,
+		password:        password,
+		createDatabase:  createDatabase,
+		machineTags:     make(map[string]string, len(staticTags)+1),
+		bufferDuration:  bufferDuration,
+		batchSize:       batchSize,
+		lastWrite:       time.Now(),
+	}
+	for key, value := range staticTags {
+		ret.machineTags[key] = value
+	}
+	ret.machineId = staticTags[tagMachineId]
+	if ret.machineId == "" {
+		ret.machineId = machineName
+	}
+	ret.machineTags[tagMachineId] = ret.machineId
+	ret.readyToFlush = ret.defaultReadyToFlush
+	return ret, nil
+}
@@ -0,0 +1,252 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package influxdb
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A request received by the fake InfluxDB.
+type influxdbRequest struct {
+	method string
+	path   string
+	query  url.Values
+	body   string
+}
+
+// Fake InfluxDB recording the requests it receives and answering queries
+// with the response for the first statement they contain, if any.
+type fakeInfluxdb struct {
+	*httptest.Server
+	lock      sync.Mutex
+	requests  []influxdbRequest
+	responses map[string]string
+}
+
+func newFakeInfluxdb() *fakeInfluxdb {
+	fake := &fakeInfluxdb{
+		responses: make(map[string]string),
+	}
+	fake.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		fake.lock.Lock()
+		defer fake.lock.Unlock()
+		fake.requests = append(fake.requests, influxdbRequest{r.Method, r.URL.Path, r.URL.Query(), string(body)})
+		if r.URL.Path == "/write" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		for statement, response := range fake.responses {
+			if strings.HasPrefix(r.URL.Query().Get("q"), statement) {
+				w.Write([]byte(response))
+				return
+			}
+		}
+		w.Write([]byte(`{"results":[{}]}`))
+	}))
+	return fake
+}
+
+func (self *fakeInfluxdb) Requests() []influxdbRequest {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return append([]influxdbRequest(nil), self.requests...)
+}
+
+func newTestTaggedStorage(t *testing.T, fake *fakeInfluxdb, batchSize int) *taggedStorage {
+	driver, err := NewTagged("host1", map[string]string{"machine_id": "m1", "kernel_version": "3.10.0"}, "cadvisor", "week", "root", "secret", strings.TrimPrefix(fake.URL, "http://"), false, false, time.Minute, batchSize)
+	require.Nil(t, err)
+	return driver
+}
+
+var testRef = info.ContainerReference{
+	Name:    "/docker/abc",
+	Aliases: []string{"web 1", "abc"},
+}
+
+func newTestStats() *info.ContainerStats {
+	stats := &info.ContainerStats{
+		Timestamp: time.Unix(1434055562, 0),
+	}
+	stats.Cpu.Usage.Total = 100
+	stats.Cpu.Usage.User = 60
+	stats.Cpu.Usage.System = 40
+	stats.Cpu.LoadAverage = 1500
+	stats.Memory.Usage = 1024
+	stats.Memory.WorkingSet = 512
+	stats.Memory.HierarchicalData.Pgfault = 7
+	stats.Memory.HierarchicalData.Pgmajfault = 1
+	stats.Network.RxBytes = 10
+	stats.Network.RxPackets = 2
+	stats.Network.TxBytes = 20
+	stats.Network.TxPackets = 3
+	return stats
+}
+
+func TestStatsToLines(t *testing.T) {
+	fake := newFakeInfluxdb()
+	defer fake.Close()
+	driver := newTestTaggedStorage(t, fake, 100)
+	stats := newTestStats()
+	stats.Filesystem = []info.FsStats{{Device: "/dev/sda1", Limit: 2048, Usage: 1024}}
+
+	payload := ""
+	for _, p := range driver.statsToPoints(testRef, stats) {
+		payload += lineOf(p)
+	}
+	expected := `cpu,alias=web\ 1,container_name=/docker/abc,kernel_version=3.10.0,machine_id=m1 load_average=1500i,usage_system=40i,usage_total=100i,usage_user=60i 1434055562000000000
+memory,alias=web\ 1,container_name=/docker/abc,kernel_version=3.10.0,machine_id=m1 pgfault=7i,pgmajfault=1i,swap=0i,usage=1024i,working_set=512i 1434055562000000000
+network,alias=web\ 1,container_name=/docker/abc,kernel_version=3.10.0,machine_id=m1 rx_bytes=10i,rx_dropped=0i,rx_errors=0i,rx_packets=2i,tx_bytes=20i,tx_dropped=0i,tx_errors=0i,tx_packets=3i 1434055562000000000
+filesystem,alias=web\ 1,container_name=/docker/abc,device=/dev/sda1,kernel_version=3.10.0,machine_id=m1 limit=2048i,usage=1024i 1434055562000000000
+`
+	assert.Equal(t, expected, payload)
+}
+
+func TestStatsToLinesSkipsMissingSections(t *testing.T) {
+	fake := newFakeInfluxdb()
+	defer fake.Close()
+	driver := newTestTaggedStorage(t, fake, 100)
+	stats := newTestStats()
+	stats.MarkMissing(info.StatsSectionMemory)
+
+	points := driver.statsToPoints(info.ContainerReference{Name: "/"}, stats)
+	require.Equal(t, 2, len(points))
+	assert.Equal(t, "cpu,container_name=/,kernel_version=3.10.0,machine_id=m1 load_average=1500i,usage_system=40i,usage_total=100i,usage_user=60i 1434055562000000000\n", lineOf(points[0]))
+	assert.Equal(t, measurementNetwork, points[1].measurement)
+}
+
+func TestMachineIdDefaultsToMachineName(t *testing.T) {
+	driver, err := NewTagged("host1", nil, "cadvisor", "", "", "", "localhost:8086", false, false, time.Minute, 100)
+	require.Nil(t, err)
+	assert.Equal(t, "host1", driver.containerTags(testRef)[tagMachineId])
+}
+
+func TestWritesAreBatched(t *testing.T) {
+	fake := newFakeInfluxdb()
+	defer fake.Close()
+	driver := newTestTaggedStorage(t, fake, 5)
+	flush := false
+	driver.OverrideReadyToFlush(func() bool {
+		return flush
+	})
+
+	// 3 points per stats, the first ones are buffered.
+	require.Nil(t, driver.AddStats(testRef, newTestStats()))
+	assert.Equal(t, 0, len(fake.Requests()))
+
+	// A batch is full, the 6 buffered points are written in batches of 5.
+	require.Nil(t, driver.AddStats(testRef, newTestStats()))
+	requests := fake.Requests()
+	require.Equal(t, 2, len(requests))
+	for _, request := range requests {
+		assert.Equal(t, "POST", request.method)
+		assert.Equal(t, "/write", request.path)
+		assert.Equal(t, "cadvisor", request.query.Get("db"))
+		assert.Equal(t, "week", request.query.Get("rp"))
+		assert.Equal(t, "n", request.query.Get("precision"))
+		assert.Equal(t, "root", request.query.Get("u"))
+	}
+	assert.Equal(t, 5, strings.Count(requests[0].body, "\n"))
+	assert.Equal(t, 1, strings.Count(requests[1].body, "\n"))
+
+	// Once the buffer duration elapsed, the points are written even if the batch is not full.
+	flush = true
+	require.Nil(t, driver.AddStats(testRef, newTestStats()))
+	requests = fake.Requests()
+	require.Equal(t, 3, len(requests))
+	assert.Equal(t, 3, strings.Count(requests[2].body, "\n"))
+}
+
+func TestTaggedRecentStats(t *testing.T) {
+	fake := newFakeInfluxdb()
+	defer fake.Close()
+	fake.responses[`SELECT * FROM "cpu"`] = `{"results":[
+		{"series":[{"name":"cpu","columns":["time","alias","container_name","machine_id","usage_total","usage_user","usage_system","load_average"],"values":[
+			[1434055563000000000,"web 1","/docker/abc","m1",200,120,80,1000],
+			[1434055562000000000,"web 1","/docker/abc","m1",100,60,40,1500]]}]},
+		{"series":[{"name":"memory","columns":["time","usage","working_set","swap","pgfault","pgmajfault"],"values":[
+			[1434055563000000000,2048,1024,0,8,1],
+			[1434055562000000000,1024,512,0,7,1]]}]},
+		{}]}`
+	fake.responses[`SELECT * FROM "filesystem"`] = `{"results":[{"series":[{"name":"filesystem","columns":["time","device","limit","usage"],"values":[
+		[1434055563000000000,"/dev/sda1",2048,1024]]}]}]}`
+	driver := newTestTaggedStorage(t, fake, 100)
+
+	stats, err := driver.RecentStats("/docker/abc", 2)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(stats))
+	assert.True(t, stats[0].Timestamp.Equal(time.Unix(1434055562, 0)))
+	assert.Equal(t, uint64(100), stats[0].Cpu.Usage.Total)
+	assert.Equal(t, int32(1500), stats[0].Cpu.LoadAverage)
+	assert.Equal(t, uint64(512), stats[0].Memory.WorkingSet)
+	assert.Equal(t, 0, len(stats[0].Filesystem))
+	assert.True(t, stats[1].Timestamp.Equal(time.Unix(1434055563, 0)))
+	assert.Equal(t, uint64(2048), stats[1].Memory.Usage)
+	assert.Equal(t, []info.FsStats{{Device: "/dev/sda1", Limit: 2048, Usage: 1024}}, stats[1].Filesystem)
+
+	requests := fake.Requests()
+	require.Equal(t, 2, len(requests))
+	assert.Equal(t, `SELECT * FROM "cpu" WHERE "machine_id" = 'm1' AND "container_name" = '/docker/abc' ORDER BY time DESC LIMIT 2; `+
+		`SELECT * FROM "memory" WHERE "machine_id" = 'm1' AND "container_name" = '/docker/abc' ORDER BY time DESC LIMIT 2; `+
+		`SELECT * FROM "network" WHERE "machine_id" = 'm1' AND "container_name" = '/docker/abc' ORDER BY time DESC LIMIT 2`, requests[0].query.Get("q"))
+	assert.Equal(t, `SELECT * FROM "filesystem" WHERE "machine_id" = 'm1' AND "container_name" = '/docker/abc' AND time >= 1434055562000000000`, requests[1].query.Get("q"))
+	assert.Equal(t, "ns", requests[0].query.Get("epoch"))
+}
+
+func TestVerifyConnectionCreatesDatabase(t *testing.T) {
+	fake := newFakeInfluxdb()
+	defer fake.Close()
+	fake.responses[`SHOW RETENTION POLICIES`] = `{"results":[{"series":[{"columns":["name","duration","replicaN","default"],"values":[
+		["default","0",1,true],["week","168h0m0s",1,false]]}]}]}`
+	fake.responses[`CREATE DATABASE`] = `{"results":[{"error":"database already exists"}]}`
+	driver := newTestTaggedStorage(t, fake, 100)
+	driver.createDatabase = true
+
+	require.Nil(t, driver.VerifyConnection())
+	requests := fake.Requests()
+	require.Equal(t, 2, len(requests))
+	assert.Equal(t, "POST", requests[0].method)
+	assert.Equal(t, `CREATE DATABASE "cadvisor"`, requests[0].query.Get("q"))
+	assert.Equal(t, `SHOW RETENTION POLICIES ON "cadvisor"`, requests[1].query.Get("q"))
+
+	driver.retentionPolicy = "month"
+	assert.NotNil(t, driver.VerifyConnection())
+}
+
+func TestTimeFromPing(t *testing.T) {
+	fake := newFakeInfluxdb()
+	defer fake.Close()
+	driver := newTestTaggedStorage(t, fake, 100)
+
+	before := time.Now()
+	serverTime, err := driver.Time()
+	require.Nil(t, err)
+	// The Date header set by the server is truncated to the second.
+	assert.True(t, serverTime.After(before.Add(-time.Second)) && serverTime.Before(time.Now().Add(time.Second)), "expected about %v, got %v", before, serverTime)
+	requests := fake.Requests()
+	require.Equal(t, 1, len(requests))
+	assert.Equal(t, "/ping", requests[0].path)
+}
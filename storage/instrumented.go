@@ -0,0 +1,294 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	httpMux "github.com/google/cadvisor/http/mux"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Page reporting the requests made to the storage drivers.
+const RequestsPage = "/storage"
+
+// Operations of a storage driver which are instrumented.
+const (
+	OperationAddStats    = "add_stats"
+	OperationRecentStats = "recent_stats"
+)
+
+// Upper bounds of the latency buckets, in seconds.
+var latencyBuckets = prometheus.DefBuckets
+
+var (
+	writeSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "cadvisor",
+		Subsystem: "storage",
+		Name:      "write_seconds",
+		Help:      "Latency of the writes of stats to the storage driver, in seconds.",
+		Buckets:   latencyBuckets,
+	}, []string{"driver"})
+	readSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "cadvisor",
+		Subsystem: "storage",
+		Name:      "read_seconds",
+		Help:      "Latency of the reads of stats from the storage driver, in seconds.",
+		Buckets:   latencyBuckets,
+	}, []string{"driver"})
+	errorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cadvisor",
+		Subsystem: "storage",
+		Name:      "errors_total",
+		Help:      "Number of requests to the storage driver which failed, by operation and type of error (timeout, network or other).",
+	}, []string{"driver", "operation", "type"})
+	bytesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cadvisor",
+		Subsystem: "storage",
+		Name:      "bytes_total",
+		Help:      "Size of the stats written to and read from the storage driver, serialized as JSON, in bytes.",
+	}, []string{"driver", "operation"})
+)
+
+// The Prometheus metrics of the requests made to the storage drivers.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{writeSeconds, readSeconds, errorsTotal, bytesTotal, suppressedTotal, filteredTotal}
+}
+
+// Requests made for an operation of a storage driver.
+type RequestStats struct {
+	// Number of requests, including failed ones.
+	Count uint64 `json:"count"`
+
+	// Number of failed requests by type of error (timeout, network or other).
+	Errors map[string]uint64 `json:"errors,omitempty"`
+
+	// Size of the stats written or read, serialized as JSON.
+	// Units: Bytes.
+	Bytes uint64 `json:"bytes"`
+
+	// Total time taken by the requests.
+	Latency time.Duration `json:"latency"`
+
+	// Number of requests which took at most the upper bound of each bucket
+	// (see LatencyBuckets), the buckets are cumulative.
+	LatencyCounts []uint64 `json:"latency_counts"`
+}
+
+// Upper bounds of the latency buckets of RequestStats.LatencyCounts.
+func LatencyBuckets() []time.Duration {
+	ret := make([]time.Duration, len(latencyBuckets))
+	for i, bound := range latencyBuckets {
+		ret[i] = time.Duration(bound * float64(time.Second))
+	}
+	return ret
+}
+
+// A storage driver recording the requests made to the driver it wraps. Requests
+// are reported by RequestsPage and in the Prometheus metrics. Class is thread-safe.
+type InstrumentedDriver struct {
+	driver StorageDriver
+	name   string
+
+	lock     sync.Mutex
+	requests map[string]*RequestStats
+}
+
+var (
+	instrumentedLock    sync.Mutex
+	instrumentedDrivers []*InstrumentedDriver
+)
+
+// Returns the specified driver instrumented under the specified name (e.g.:
+// influxdb). Its requests are reported at RequestsPage.
+func Instrument(name string, driver StorageDriver) *InstrumentedDriver {
+	self := &InstrumentedDriver{
+		driver:   driver,
+		name:     name,
+		requests: make(map[string]*RequestStats),
+	}
+	instrumentedLock.Lock()
+	defer instrumentedLock.Unlock()
+	instrumentedDrivers = append(instrumentedDrivers, self)
+	return self
+}
+
+func (self *InstrumentedDriver) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	start := time.Now()
+	err := self.driver.AddStats(ref, stats)
+	latency := time.Since(start)
+	writeSeconds.WithLabelValues(self.name).Observe(latency.Seconds())
+	self.record(OperationAddStats, latency, serializedSize(stats), err)
+	return err
+}
+
+func (self *InstrumentedDriver) RecentStats(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	start := time.Now()
+	stats, err := self.driver.RecentStats(containerName, numStats)
+	latency := time.Since(start)
+	readSeconds.WithLabelValues(self.name).Observe(latency.Seconds())
+	var size uint64
+	for _, stat := range stats {
+		size += serializedSize(stat)
+	}
+	self.record(OperationRecentStats, latency, size, err)
+	return stats, err
+}
+
+func (self *InstrumentedDriver) VerifyConnection() error {
+	return self.driver.VerifyConnection()
+}
+
+func (self *InstrumentedDriver) Close() error {
+	return self.driver.Close()
+}
+
+// Records a request.
+func (self *InstrumentedDriver) record(operation string, latency time.Duration, size uint64, err error) {
+	bytesTotal.WithLabelValues(self.name, operation).Add(float64(size))
+	if err != nil {
+		errorsTotal.WithLabelValues(self.name, operation, errorType(err)).Inc()
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	requests, ok := self.requests[operation]
+	if !ok {
+		requests = &RequestStats{
+			LatencyCounts: make([]uint64, len(latencyBuckets)),
+		}
+		self.requests[operation] = requests
+	}
+	requests.Count++
+	requests.Bytes += size
+	requests.Latency += latency
+	for i, bound := range latencyBuckets {
+		if latency.Seconds() <= bound {
+			requests.LatencyCounts[i]++
+		}
+	}
+	if err != nil {
+		if requests.Errors == nil {
+			requests.Errors = make(map[string]uint64)
+		}
+		requests.Errors[errorType(err)]++
+	}
+}
+
+// Returns the requests made so far by operation (e.g.: OperationAddStats).
+func (self *InstrumentedDriver) Requests() map[string]RequestStats {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	ret := make(map[string]RequestStats, len(self.requests))
+	for operation, requests := range self.requests {
+		copied := *requests
+		copied.LatencyCounts = append([]uint64(nil), requests.LatencyCounts...)
+		if requests.Errors != nil {
+			copied.Errors = make(map[string]uint64, len(requests.Errors))
+			for errType, count := range requests.Errors {
+				copied.Errors[errType] = count
+			}
+		}
+		ret[operation] = copied
+	}
+	return ret
+}
+
+// Returns the size of the stats serialized as JSON, which is how most
+// backends store them.
+func serializedSize(stats *info.ContainerStats) uint64 {
+	if stats == nil {
+		return 0
+	}
+	out, err := json.Marshal(stats)
+	if err != nil {
+		return 0
+	}
+	return uint64(len(out))
+}
+
+// Classifies the error as a timeout, another network error or other.
+func errorType(err error) string {
+	netErr, ok := err.(net.Error)
+	if !ok {
+		return "other"
+	}
+	if netErr.Timeout() {
+		return "timeout"
+	}
+	return "network"
+}
+
+// Serves the requests made to the instrumented drivers as text.
+func serveRequests(w http.ResponseWriter, r *http.Request) {
+	instrumentedLock.Lock()
+	drivers := append([]*InstrumentedDriver(nil), instrumentedDrivers...)
+	instrumentedLock.Unlock()
+	if len(drivers) == 0 {
+		fmt.Fprintf(w, "No storage driver is in use\n")
+		return
+	}
+
+	fmt.Fprintf(w, "Latency buckets: %v\n\n", LatencyBuckets())
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "DRIVER\tOPERATION\tREQUESTS\tERRORS\tBYTES\tMEAN LATENCY\tLATENCY COUNTS\n")
+	for _, driver := range drivers {
+		requests := driver.Requests()
+		operations := make([]string, 0, len(requests))
+		for operation := range requests {
+			operations = append(operations, operation)
+		}
+		sort.Strings(operations)
+		for _, operation := range operations {
+			stats := requests[operation]
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%d\t%v\t%v\n", driver.name, operation, stats.Count, formatErrors(stats.Errors), stats.Bytes, stats.Latency/time.Duration(stats.Count), stats.LatencyCounts)
+		}
+	}
+	tw.Flush()
+}
+
+// Formats the error counts by type, e.g.: network=2,timeout=1.
+func formatErrors(errors map[string]uint64) string {
+	if len(errors) == 0 {
+		return "0"
+	}
+	types := make([]string, 0, len(errors))
+	for errType := range errors {
+		types = append(types, errType)
+	}
+	sort.Strings(types)
+	ret := ""
+	for i, errType := range types {
+		if i > 0 {
+			ret += ","
+		}
+		ret += fmt.Sprintf("%s=%d", errType, errors[errType])
+	}
+	return ret
+}
+
+// Registers the handler of RequestsPage.
+func RegisterHandler(mux httpMux.Mux) error {
+	mux.Handle(RequestsPage, httpMux.SafeReads(http.HandlerFunc(serveRequests)))
+	return nil
+}
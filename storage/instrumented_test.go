@@ -0,0 +1,172 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// A storage driver taking the specified time for each request and failing with the specified error.
+type fakeDriver struct {
+	latency time.Duration
+	err     error
+	stats   []*info.ContainerStats
+}
+
+func (self *fakeDriver) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	time.Sleep(self.latency)
+	return self.err
+}
+
+func (self *fakeDriver) RecentStats(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	time.Sleep(self.latency)
+	return self.stats, self.err
+}
+
+func (self *fakeDriver) VerifyConnection() error {
+	return self.err
+}
+
+func (self *fakeDriver) Close() error {
+	return nil
+}
+
+// A network error.
+type fakeNetError struct {
+	timeout bool
+}
+
+func (self fakeNetError) Error() string   { return "network error" }
+func (self fakeNetError) Timeout() bool   { return self.timeout }
+func (self fakeNetError) Temporary() bool { return false }
+
+func jsonSize(t *testing.T, stats *info.ContainerStats) uint64 {
+	out, err := json.Marshal(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return uint64(len(out))
+}
+
+// Returns the index of the first latency bucket including the specified latency.
+func bucketOf(latency time.Duration) int {
+	for i, bound := range LatencyBuckets() {
+		if latency <= bound {
+			return i
+		}
+	}
+	return len(latencyBuckets)
+}
+
+func TestInstrumentedAddStats(t *testing.T) {
+	fake := &fakeDriver{latency: 20 * time.Millisecond}
+	driver := Instrument("fake-add", fake)
+	stats := &info.ContainerStats{Timestamp: time.Unix(100, 0)}
+	for i := 0; i < 2; i++ {
+		if err := driver.AddStats(info.ContainerReference{Name: "/"}, stats); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	requests := driver.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("expected only writes, got %+v", requests)
+	}
+	writes := requests[OperationAddStats]
+	if writes.Count != 2 || writes.Errors != nil {
+		t.Errorf("expected 2 successful writes, got %+v", writes)
+	}
+	if expected := 2 * jsonSize(t, stats); writes.Bytes != expected {
+		t.Errorf("expected %d bytes written, got %d", expected, writes.Bytes)
+	}
+	if writes.Latency < 2*fake.latency {
+		t.Errorf("expected the writes to take at least %v, got %v", 2*fake.latency, writes.Latency)
+	}
+	// The requests are only counted in the buckets long enough for them.
+	for i, count := range writes.LatencyCounts {
+		if i < bucketOf(fake.latency) && count != 0 {
+			t.Errorf("expected no writes faster than %v, got %v", LatencyBuckets()[i], writes.LatencyCounts)
+		}
+	}
+	if last := writes.LatencyCounts[len(writes.LatencyCounts)-1]; last != 2 {
+		t.Errorf("expected both writes within the last bucket, got %v", writes.LatencyCounts)
+	}
+}
+
+func TestInstrumentedErrors(t *testing.T) {
+	fake := &fakeDriver{}
+	driver := Instrument("fake-errors", fake)
+	for _, err := range []error{fakeNetError{timeout: true}, fakeNetError{}, fmt.Errorf("bad query"), fmt.Errorf("bad query")} {
+		fake.err = err
+		if _, actual := driver.RecentStats("/", 10); actual != err {
+			t.Errorf("expected the error of the driver %v, got %v", err, actual)
+		}
+	}
+
+	reads := driver.Requests()[OperationRecentStats]
+	expected := map[string]uint64{
+		"timeout": 1,
+		"network": 1,
+		"other":   2,
+	}
+	if reads.Count != 4 || !reflect.DeepEqual(reads.Errors, expected) {
+		t.Errorf("expected 4 reads failing with %v, got %+v", expected, reads)
+	}
+}
+
+func TestInstrumentedRecentStatsBytes(t *testing.T) {
+	stats := []*info.ContainerStats{
+		{Timestamp: time.Unix(100, 0)},
+		{Timestamp: time.Unix(101, 0), Cpu: info.CpuStats{Usage: info.CpuUsage{Total: 1000}}},
+	}
+	driver := Instrument("fake-read", &fakeDriver{stats: stats})
+	returned, err := driver.RecentStats("/", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(returned, stats) {
+		t.Errorf("expected the stats of the driver, got %+v", returned)
+	}
+	if expected := jsonSize(t, stats[0]) + jsonSize(t, stats[1]); driver.Requests()[OperationRecentStats].Bytes != expected {
+		t.Errorf("expected %d bytes read, got %+v", expected, driver.Requests()[OperationRecentStats])
+	}
+}
+
+func TestRequestsPage(t *testing.T) {
+	driver := Instrument("fake-page", &fakeDriver{err: fmt.Errorf("bad query")})
+	driver.AddStats(info.ContainerReference{Name: "/"}, &info.ContainerStats{})
+
+	w := httptest.NewRecorder()
+	serveRequests(w, &http.Request{})
+	var line string
+	for _, l := range strings.Split(w.Body.String(), "\n") {
+		if strings.HasPrefix(l, "fake-page ") {
+			line = l
+		}
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 4 || fields[1] != OperationAddStats || fields[2] != "1" || fields[3] != "other=1" {
+		t.Errorf("expected a failed write of fake-page, got %q in:\n%s", line, w.Body.String())
+	}
+}
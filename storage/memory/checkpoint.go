@@ -0,0 +1,155 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/glog"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// First line of checkpoints. Bump the version when their format changes.
+// v2: user and system CPU times are always in nanoseconds, v1 checkpoints
+// may have them in ticks and are not restored.
+const checkpointHeader = "cadvisor memory storage checkpoint v2\n"
+
+// The stats of a container in a checkpoint, oldest first.
+type checkpointedContainer struct {
+	Ref   info.ContainerReference
+	Stats []info.ContainerStats
+}
+
+// Writes the stats of all containers to w so that they can be restored with
+// ReadCheckpoint(), e.g.: by the next cAdvisor after a restart.
+func (self *InMemoryStorage) WriteCheckpoint(w io.Writer) error {
+	self.lock.RLock()
+	cstores := make([]*containerStorage, 0, len(self.containerStorageMap))
+	for _, cstore := range self.containerStorageMap {
+		cstores = append(cstores, cstore)
+	}
+	self.lock.RUnlock()
+
+	containers := make([]checkpointedContainer, 0, len(cstores))
+	for _, cstore := range cstores {
+		cstore.lock.RLock()
+		stats := cstore.recentStats.InTimeRange(time.Time{}, time.Time{}, -1)
+		container := checkpointedContainer{
+			Ref:   cstore.ref,
+			Stats: make([]info.ContainerStats, 0, len(stats)),
+		}
+		for _, stat := range stats {
+			container.Stats = append(container.Stats, *stat)
+		}
+		cstore.lock.RUnlock()
+		containers = append(containers, container)
+	}
+
+	if _, err := io.WriteString(w, checkpointHeader); err != nil {
+		return err
+	}
+	return gob.NewEncoder(w).Encode(containers)
+}
+
+// Restores the stats newer than since from a checkpoint written by
+// WriteCheckpoint(). Restored stats are marked as such and are not written to
+// the backend storage. Nothing is restored if the checkpoint is malformed.
+// Returns the number of stats restored.
+func (self *InMemoryStorage) ReadCheckpoint(r io.Reader, since time.Time) (int, error) {
+	reader := bufio.NewReader(r)
+	header, err := reader.ReadString('\n')
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the checkpoint header: %v", err)
+	}
+	if header != checkpointHeader {
+		return 0, fmt.Errorf("unsupported checkpoint header %q, expected %q", header, checkpointHeader)
+	}
+	var containers []checkpointedContainer
+	if err := gob.NewDecoder(reader).Decode(&containers); err != nil {
+		return 0, fmt.Errorf("failed to decode the checkpoint: %v", err)
+	}
+
+	restored := 0
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for _, container := range containers {
+		cstore, ok := self.containerStorageMap[container.Ref.Name]
+		if !ok {
+			cstore = newContainerStore(container.Ref, self.maxNumStats)
+			self.containerStorageMap[container.Ref.Name] = cstore
+		}
+		cstore.lock.Lock()
+		for i := range container.Stats {
+			stats := &container.Stats[i]
+			if !stats.Timestamp.After(since) {
+				continue
+			}
+			// Monotonic timestamps of another process are meaningless.
+			stats.MonotonicTimestamp = 0
+			stats.Restored = true
+			cstore.recentStats.Add(stats)
+			restored++
+		}
+		cstore.lock.Unlock()
+	}
+	return restored, nil
+}
+
+// Writes a checkpoint to the specified file. The file is replaced atomically
+// so that a partial checkpoint is never left behind.
+func (self *InMemoryStorage) WriteCheckpointFile(path string) error {
+	file, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	err = self.WriteCheckpoint(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return nil
+}
+
+// Restores the stats newer than since from the checkpoint in the specified
+// file, if any. Malformed checkpoints are ignored with a warning.
+func (self *InMemoryStorage) ReadCheckpointFile(path string, since time.Time) {
+	file, err := os.Open(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			glog.Warningf("Ignoring checkpoint %q: %v", path, err)
+		}
+		return
+	}
+	defer file.Close()
+	restored, err := self.ReadCheckpoint(file, since)
+	if err != nil {
+		glog.Warningf("Ignoring checkpoint %q: %v", path, err)
+		return
+	}
+	glog.Infof("Restored %d stats from checkpoint %q", restored, path)
+}
@@ -0,0 +1,167 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const numCheckpointStats = 3000
+
+// Makes a storage with numCheckpointStats stats, one per second, for each of the specified containers.
+func makeCheckpointedStorage(start time.Time, names ...string) *InMemoryStorage {
+	memoryStorage := New(numCheckpointStats, nil)
+	for _, name := range names {
+		ref := info.ContainerReference{
+			Name:    name,
+			Aliases: []string{strings.TrimPrefix(name, "/")},
+		}
+		for i := 0; i < numCheckpointStats; i++ {
+			stats := makeStat(i)
+			stats.Timestamp = start.Add(time.Duration(i) * time.Second)
+			stats.MonotonicTimestamp = time.Duration(i+1) * time.Second
+			stats.Cpu.Usage.Total = uint64(i) * uint64(time.Second)
+			stats.Memory.Usage = uint64(i) * 1024
+			memoryStorage.AddStats(ref, stats)
+		}
+	}
+	return memoryStorage
+}
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	start := time.Unix(1000000, 0)
+	names := []string{"/", "/docker", "/docker/abc"}
+	original := makeCheckpointedStorage(start, names...)
+
+	var checkpoint bytes.Buffer
+	require.Nil(t, original.WriteCheckpoint(&checkpoint))
+
+	restoredStorage := New(numCheckpointStats, nil)
+	restored, err := restoredStorage.ReadCheckpoint(&checkpoint, time.Time{})
+	require.Nil(t, err)
+	assert.Equal(t, len(names)*numCheckpointStats, restored)
+
+	for _, name := range names {
+		expected, err := original.RecentStats(name, time.Time{}, time.Time{}, -1)
+		require.Nil(t, err)
+		actual, err := restoredStorage.RecentStats(name, time.Time{}, time.Time{}, -1)
+		require.Nil(t, err)
+		require.Equal(t, len(expected), len(actual))
+		for i := range expected {
+			assert.True(t, actual[i].Restored, "stats %d of %q are not marked as restored", i, name)
+			assert.Equal(t, time.Duration(0), actual[i].MonotonicTimestamp)
+			assert.True(t, expected[i].Eq(actual[i]), "stats %d of %q differ: %+v != %+v", i, name, expected[i], actual[i])
+		}
+		assert.Equal(t, name, restoredStorage.containerStorageMap[name].ref.Name)
+		assert.Equal(t, []string{strings.TrimPrefix(name, "/")}, restoredStorage.containerStorageMap[name].ref.Aliases)
+	}
+}
+
+func TestCheckpointOnlyRestoresRecentStats(t *testing.T) {
+	start := time.Unix(1000000, 0)
+	original := makeCheckpointedStorage(start, "/")
+
+	var checkpoint bytes.Buffer
+	require.Nil(t, original.WriteCheckpoint(&checkpoint))
+
+	restoredStorage := New(numCheckpointStats, nil)
+	since := start.Add((numCheckpointStats - 100) * time.Second)
+	restored, err := restoredStorage.ReadCheckpoint(&checkpoint, since)
+	require.Nil(t, err)
+	assert.Equal(t, 99, restored)
+	stats, err := restoredStorage.RecentStats("/", time.Time{}, time.Time{}, -1)
+	require.Nil(t, err)
+	require.Equal(t, 99, len(stats))
+	assert.True(t, stats[0].Timestamp.After(since))
+}
+
+func TestTruncatedCheckpointIsIgnored(t *testing.T) {
+	original := makeCheckpointedStorage(time.Unix(1000000, 0), "/", "/docker")
+	var checkpoint bytes.Buffer
+	require.Nil(t, original.WriteCheckpoint(&checkpoint))
+
+	for _, size := range []int{0, 10, len(checkpointHeader), checkpoint.Len() / 2, checkpoint.Len() - 1} {
+		restoredStorage := New(numCheckpointStats, nil)
+		_, err := restoredStorage.ReadCheckpoint(bytes.NewReader(checkpoint.Bytes()[:size]), time.Time{})
+		assert.NotNil(t, err, "truncated to %d bytes", size)
+		assert.Empty(t, restoredStorage.containerStorageMap, "truncated to %d bytes", size)
+	}
+}
+
+func TestCheckpointWithAnotherVersionIsIgnored(t *testing.T) {
+	original := makeCheckpointedStorage(time.Unix(1000000, 0), "/")
+	var checkpoint bytes.Buffer
+	require.Nil(t, original.WriteCheckpoint(&checkpoint))
+
+	contents := strings.Replace(checkpoint.String(), "checkpoint v2\n", "checkpoint v3\n", 1)
+	restoredStorage := New(numCheckpointStats, nil)
+	_, err := restoredStorage.ReadCheckpoint(strings.NewReader(contents), time.Time{})
+	assert.NotNil(t, err)
+	assert.Empty(t, restoredStorage.containerStorageMap)
+}
+
+func TestCheckpointWithCpuTicksIsIgnored(t *testing.T) {
+	original := makeCheckpointedStorage(time.Unix(1000000, 0), "/")
+	var checkpoint bytes.Buffer
+	require.Nil(t, original.WriteCheckpoint(&checkpoint))
+
+	// v1 checkpoints may have user and system CPU times in ticks.
+	contents := strings.Replace(checkpoint.String(), "checkpoint v2\n", "checkpoint v1\n", 1)
+	restoredStorage := New(numCheckpointStats, nil)
+	_, err := restoredStorage.ReadCheckpoint(strings.NewReader(contents), time.Time{})
+	assert.NotNil(t, err)
+	assert.Empty(t, restoredStorage.containerStorageMap)
+}
+
+func TestCheckpointFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoint")
+
+	// A missing checkpoint restores nothing.
+	restoredStorage := New(numCheckpointStats, nil)
+	restoredStorage.ReadCheckpointFile(path, time.Time{})
+	assert.Empty(t, restoredStorage.containerStorageMap)
+
+	original := makeCheckpointedStorage(time.Unix(1000000, 0), "/")
+	require.Nil(t, original.WriteCheckpointFile(path))
+	files, err := ioutil.ReadDir(dir)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(files), "temporary files were left behind")
+
+	restoredStorage.ReadCheckpointFile(path, time.Time{})
+	stats, err := restoredStorage.RecentStats("/", time.Time{}, time.Time{}, -1)
+	require.Nil(t, err)
+	assert.Equal(t, numCheckpointStats, len(stats))
+
+	// A corrupt checkpoint is ignored.
+	contents, err := ioutil.ReadFile(path)
+	require.Nil(t, err)
+	require.Nil(t, ioutil.WriteFile(path, contents[:len(contents)/3], 0644))
+	restoredStorage = New(numCheckpointStats, nil)
+	restoredStorage.ReadCheckpointFile(path, time.Time{})
+	assert.Empty(t, restoredStorage.containerStorageMap)
+}
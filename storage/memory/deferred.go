@@ -0,0 +1,99 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"github.com/golang/glog"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Stats of a container held back from the backend.
+type deferredStats struct {
+	ref   info.ContainerReference
+	stats []*info.ContainerStats
+}
+
+// Holds back the stats of the specified container from the backend until they
+// are flushed or rolled up (e.g.: while the container may be short-lived).
+// They are still kept in memory. No-op without a backend.
+func (self *InMemoryStorage) DeferBackend(name string) {
+	if self.backend == nil {
+		return
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if _, ok := self.deferred[name]; !ok {
+		self.deferred[name] = &deferredStats{
+			ref: info.ContainerReference{Name: name},
+		}
+	}
+}
+
+// Holds the stats back from the backend if their container is deferred. At
+// most maxNumStats stats are held, the oldest are dropped. self.lock must be held.
+func (self *InMemoryStorage) holdLocked(ref info.ContainerReference, stats *info.ContainerStats) bool {
+	d, ok := self.deferred[ref.Name]
+	if !ok {
+		return false
+	}
+	d.ref = ref
+	if len(d.stats) >= self.maxNumStats {
+		d.stats = d.stats[1:]
+	}
+	d.stats = append(d.stats, stats)
+	return true
+}
+
+// Writes the stats held back for the specified container to the backend and
+// stops holding them back.
+func (self *InMemoryStorage) FlushDeferred(name string) {
+	self.writeDeferred(name, nil)
+}
+
+// Writes the stats held back for the specified container to the backend as
+// stats of the specified container instead (e.g.: an aggregate of short-lived
+// containers) and stops holding them back. Returns the number of stats written.
+func (self *InMemoryStorage) RollUpDeferred(name string, as info.ContainerReference) int {
+	return self.writeDeferred(name, &as)
+}
+
+func (self *InMemoryStorage) writeDeferred(name string, as *info.ContainerReference) int {
+	written := 0
+	for {
+		// Stats added while writing are still held so that they are written
+		// in order, after those being written.
+		self.lock.Lock()
+		d, ok := self.deferred[name]
+		if !ok || len(d.stats) == 0 {
+			delete(self.deferred, name)
+			self.lock.Unlock()
+			return written
+		}
+		stats := d.stats
+		d.stats = nil
+		ref := d.ref
+		if as != nil {
+			ref = *as
+		}
+		self.lock.Unlock()
+
+		for _, s := range stats {
+			if err := self.backend.AddStats(ref, s); err != nil {
+				glog.Error(err)
+			}
+		}
+		written += len(stats)
+	}
+}
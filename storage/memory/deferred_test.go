@@ -0,0 +1,78 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/storage/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeferredStatsAreFlushedInOrder(t *testing.T) {
+	backend := test.NewRecordingStorageDriver()
+	memoryStorage := New(60, backend)
+	memoryStorage.DeferBackend(containerName)
+
+	require.Nil(t, memoryStorage.AddStats(containerRef, makeStat(0)))
+	require.Nil(t, memoryStorage.AddStats(containerRef, makeStat(1)))
+	// Held back from the backend but kept in memory.
+	assert.Empty(t, backend.ContainerNames())
+	assert.Len(t, getRecentStats(t, memoryStorage, -1), 2)
+
+	memoryStorage.FlushDeferred(containerName)
+	require.Nil(t, memoryStorage.AddStats(containerRef, makeStat(2)))
+	stats, err := backend.RecentStats(containerName, -1)
+	require.Nil(t, err)
+	assert.Equal(t, []*info.ContainerStats{makeStat(0), makeStat(1), makeStat(2)}, stats)
+}
+
+func TestDeferredStatsAreRolledUp(t *testing.T) {
+	backend := test.NewRecordingStorageDriver()
+	memoryStorage := New(60, backend)
+	memoryStorage.DeferBackend(containerName)
+	require.Nil(t, memoryStorage.AddStats(containerRef, makeStat(0)))
+	require.Nil(t, memoryStorage.AddStats(containerRef, makeStat(1)))
+
+	aggregate := info.ContainerReference{Name: "/_aggregate"}
+	assert.Equal(t, 2, memoryStorage.RollUpDeferred(containerName, aggregate))
+	assert.Equal(t, map[string]bool{"/_aggregate": true}, backend.ContainerNames())
+	// Nothing is held anymore.
+	assert.Equal(t, 0, memoryStorage.RollUpDeferred(containerName, aggregate))
+}
+
+func TestDeferredStatsAreBounded(t *testing.T) {
+	backend := test.NewRecordingStorageDriver()
+	memoryStorage := New(2, backend)
+	memoryStorage.DeferBackend(containerName)
+	for i := 0; i < 3; i++ {
+		require.Nil(t, memoryStorage.AddStats(containerRef, makeStat(i)))
+	}
+
+	memoryStorage.FlushDeferred(containerName)
+	stats, err := backend.RecentStats(containerName, -1)
+	require.Nil(t, err)
+	assert.Equal(t, []*info.ContainerStats{makeStat(1), makeStat(2)}, stats)
+}
+
+func TestDeferBackendWithoutBackend(t *testing.T) {
+	memoryStorage := New(60, nil)
+	memoryStorage.DeferBackend(containerName)
+	require.Nil(t, memoryStorage.AddStats(containerRef, makeStat(0)))
+	assert.Equal(t, 0, memoryStorage.RollUpDeferred(containerName, containerRef))
+	assert.Len(t, getRecentStats(t, memoryStorage, -1), 1)
+}
@@ -16,6 +16,7 @@ package memory
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -33,10 +34,13 @@ type containerStorage struct {
 	lock        sync.RWMutex
 }
 
-func (self *containerStorage) AddStats(stats *info.ContainerStats) error {
+func (self *containerStorage) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
 	self.lock.Lock()
 	defer self.lock.Unlock()
 
+	// Keep the latest aliases of the container, e.g.: for checkpoints.
+	self.ref = ref
+
 	// Add the stat to storage.
 	self.recentStats.Add(stats)
 	return nil
@@ -61,11 +65,15 @@ type InMemoryStorage struct {
 	containerStorageMap map[string]*containerStorage
 	maxNumStats         int
 	backend             storage.StorageDriver
+
+	// Stats held back from the backend by container name, see DeferBackend.
+	deferred map[string]*deferredStats
 }
 
 func (self *InMemoryStorage) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
 	var cstore *containerStorage
 	var ok bool
+	var held bool
 
 	func() {
 		self.lock.Lock()
@@ -74,9 +82,10 @@ func (self *InMemoryStorage) AddStats(ref info.ContainerReference, stats *info.C
 			cstore = newContainerStore(ref, self.maxNumStats)
 			self.containerStorageMap[ref.Name] = cstore
 		}
+		held = self.holdLocked(ref, stats)
 	}()
 
-	if self.backend != nil {
+	if self.backend != nil && !held {
 		// TODO(monnand): To deal with long delay write operations, we
 		// may want to start a pool of goroutines to do write
 		// operations.
@@ -84,7 +93,7 @@ func (self *InMemoryStorage) AddStats(ref info.ContainerReference, stats *info.C
 			glog.Error(err)
 		}
 	}
-	return cstore.AddStats(stats)
+	return cstore.AddStats(ref, stats)
 }
 
 func (self *InMemoryStorage) RecentStats(name string, start, end time.Time, maxStats int) ([]*info.ContainerStats, error) {
@@ -105,6 +114,72 @@ func (self *InMemoryStorage) RecentStats(name string, start, end time.Time, maxS
 	return cstore.RecentStats(start, end, maxStats)
 }
 
+// Returns the most recent stats of each of the specified containers which are
+// no newer than the cut, along with the cut. The cut is the oldest of the
+// latest stats of the containers, so that the stats returned were all the
+// current ones at that instant. Containers without stats that old, or without
+// stats at all, are left out. The stats of all the containers are read at once.
+func (self *InMemoryStorage) Snapshot(names []string) (time.Time, map[string]*info.ContainerStats) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	stores := make(map[string]*containerStorage, len(names))
+	for _, name := range names {
+		if cstore, ok := self.containerStorageMap[name]; ok {
+			stores[name] = cstore
+			cstore.lock.RLock()
+			defer cstore.lock.RUnlock()
+		}
+	}
+
+	var cut time.Time
+	for _, cstore := range stores {
+		if cstore.recentStats.Size() == 0 {
+			continue
+		}
+		latest := cstore.recentStats.Get(0).Timestamp
+		if cut.IsZero() || latest.Before(cut) {
+			cut = latest
+		}
+	}
+
+	stats := make(map[string]*info.ContainerStats, len(stores))
+	for name, cstore := range stores {
+		buffer := cstore.recentStats
+		// Stats are from the most recent, find the first no newer than the cut.
+		i := sort.Search(buffer.Size(), func(index int) bool {
+			return !buffer.Get(index).Timestamp.After(cut)
+		})
+		if i < buffer.Size() {
+			stats[name] = buffer.Get(i)
+		}
+	}
+	return cut, stats
+}
+
+// Moves the stats of a container to its new name (e.g.: its cgroup moved).
+// Stats already recorded under the new name are kept instead.
+func (self *InMemoryStorage) RenameContainer(oldName, newName string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	cstore, ok := self.containerStorageMap[oldName]
+	if !ok {
+		return
+	}
+	delete(self.containerStorageMap, oldName)
+	if _, ok := self.containerStorageMap[newName]; !ok {
+		self.containerStorageMap[newName] = cstore
+	}
+}
+
+// Returns the backend storage as an EventStorage if it can persist events.
+func (self *InMemoryStorage) EventStorage() (storage.EventStorage, bool) {
+	if self.backend == nil {
+		return nil, false
+	}
+	return storage.AsEventStorage(self.backend)
+}
+
 func (self *InMemoryStorage) Close() error {
 	self.lock.Lock()
 	self.containerStorageMap = make(map[string]*containerStorage, 32)
@@ -120,6 +195,7 @@ func New(
 		containerStorageMap: make(map[string]*containerStorage, 32),
 		maxNumStats:         maxNumStats,
 		backend:             backend,
+		deferred:            make(map[string]*deferredStats),
 	}
 	return ret
 }
@@ -61,6 +61,27 @@ func TestAddStats(t *testing.T) {
 	assert.Nil(memoryStorage.AddStats(containerRef2, makeStat(1)))
 }
 
+func TestAddStatsRecordsLatestAliases(t *testing.T) {
+	memoryStorage := New(60, nil)
+
+	ref := info.ContainerReference{
+		Name:      "/docker/abcdef",
+		Aliases:   []string{"old-name", "abcdef"},
+		Namespace: "docker",
+	}
+	assert.Nil(t, memoryStorage.AddStats(ref, makeStat(0)))
+	renamed := ref
+	renamed.Aliases = []string{"new-name", "abcdef"}
+	assert.Nil(t, memoryStorage.AddStats(renamed, makeStat(1)))
+
+	// Stats are kept by name, along with the aliases they were last added with.
+	assert.Len(t, memoryStorage.containerStorageMap, 1)
+	assert.Equal(t, renamed, memoryStorage.containerStorageMap[ref.Name].ref)
+	stats, err := memoryStorage.RecentStats(ref.Name, zero, zero, -1)
+	assert.Nil(t, err)
+	assert.Len(t, stats, 2)
+}
+
 func TestRecentStatsNoRecentStats(t *testing.T) {
 	memoryStorage := makeWithStats(0)
 
@@ -95,3 +116,44 @@ func TestRecentStatsGetAllStats(t *testing.T) {
 
 	assert.Len(t, getRecentStats(t, memoryStorage, -1), 10)
 }
+
+func TestRenameContainer(t *testing.T) {
+	memoryStorage := makeWithStats(5)
+
+	memoryStorage.RenameContainer(containerName, "/renamed")
+	_, err := memoryStorage.RecentStats(containerName, zero, zero, -1)
+	assert.NotNil(t, err)
+	stats, err := memoryStorage.RecentStats("/renamed", zero, zero, -1)
+	require.Nil(t, err)
+	assert.Equal(t, 5, len(stats))
+}
+
+func TestSnapshotCutsAtTheOldestLatestStats(t *testing.T) {
+	memoryStorage := New(60, nil)
+	// Containers sampled every 10s, each with its own offset.
+	add := func(name string, offsets ...int) {
+		for _, offset := range offsets {
+			require.Nil(t, memoryStorage.AddStats(info.ContainerReference{Name: name}, makeStat(offset)))
+		}
+	}
+	add("/a", 1, 11, 21)
+	add("/b", 4, 14, 24)
+	add("/c", 8, 18)
+	// Only sampled after the cut.
+	add("/d", 25)
+	// Never sampled.
+	memoryStorage.containerStorageMap["/e"] = newContainerStore(info.ContainerReference{Name: "/e"}, 60)
+
+	cut, stats := memoryStorage.Snapshot([]string{"/a", "/b", "/c", "/d", "/e", "/unknown"})
+	assert.Equal(t, makeStat(18).Timestamp, cut)
+	require.Equal(t, 3, len(stats))
+	assert.Equal(t, makeStat(11).Timestamp, stats["/a"].Timestamp)
+	assert.Equal(t, makeStat(14).Timestamp, stats["/b"].Timestamp)
+	assert.Equal(t, makeStat(18).Timestamp, stats["/c"].Timestamp)
+
+	// The cut only depends on the requested containers.
+	cut, stats = memoryStorage.Snapshot([]string{"/a", "/b"})
+	assert.Equal(t, makeStat(21).Timestamp, cut)
+	assert.Equal(t, makeStat(21).Timestamp, stats["/a"].Timestamp)
+	assert.Equal(t, makeStat(14).Timestamp, stats["/b"].Timestamp)
+}
@@ -0,0 +1,104 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// A storage driver writing to several drivers, e.g.: to InfluxDB and
+// BigQuery. Each driver may filter or drop the stats on its own. Class is
+// thread-safe if the drivers are.
+type MultiDriver struct {
+	drivers []StorageDriver
+}
+
+// Returns a driver writing to all the specified drivers. Stats are read from the first.
+func Multi(drivers ...StorageDriver) *MultiDriver {
+	return &MultiDriver{
+		drivers: drivers,
+	}
+}
+
+// Writes to all the drivers, even after one fails. Returns the first error.
+func (self *MultiDriver) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	var ret error
+	for _, driver := range self.drivers {
+		if err := driver.AddStats(ref, stats); err != nil && ret == nil {
+			ret = err
+		}
+	}
+	return ret
+}
+
+func (self *MultiDriver) RecentStats(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	return self.drivers[0].RecentStats(containerName, numStats)
+}
+
+func (self *MultiDriver) VerifyConnection() error {
+	for _, driver := range self.drivers {
+		if err := driver.VerifyConnection(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (self *MultiDriver) Close() error {
+	var ret error
+	for _, driver := range self.drivers {
+		if err := driver.Close(); err != nil && ret == nil {
+			ret = err
+		}
+	}
+	return ret
+}
+
+// Persists the events in all the drivers which can, see AsEventStorage().
+type multiEventStorage []EventStorage
+
+// Adds the event to all the storages. Returns the first error.
+func (self multiEventStorage) AddEvent(e *events.Event) error {
+	var ret error
+	for _, eventStorage := range self {
+		if err := eventStorage.AddEvent(e); err != nil && ret == nil {
+			ret = err
+		}
+	}
+	return ret
+}
+
+// Returns the events of the first storage.
+func (self multiEventStorage) Events(request *events.Request) (events.EventSlice, error) {
+	return self[0].Events(request)
+}
+
+// Returns the drivers which can persist events as a single EventStorage.
+func (self *MultiDriver) eventStorage() (EventStorage, bool) {
+	var ret multiEventStorage
+	for _, driver := range self.drivers {
+		if eventStorage, ok := AsEventStorage(driver); ok {
+			ret = append(ret, eventStorage)
+		}
+	}
+	if len(ret) == 0 {
+		return nil, false
+	}
+	if len(ret) == 1 {
+		return ret[0], true
+	}
+	return ret, true
+}
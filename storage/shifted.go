@@ -0,0 +1,62 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// A storage driver which corrects the timestamps of the stats written to the
+// driver it wraps by the offset of the clock of the machine, e.g.: from the
+// clock of the backend. Stats are written as timestamped while the offset is
+// not known. Stats read are returned as written. Class is thread-safe if the
+// offset function is.
+type ShiftedDriver struct {
+	driver StorageDriver
+	offset func() (time.Duration, bool)
+}
+
+// Returns the specified driver, written the stats with their timestamps moved
+// by the offset returned by the function, if known.
+func Shift(driver StorageDriver, offset func() (time.Duration, bool)) *ShiftedDriver {
+	return &ShiftedDriver{
+		driver: driver,
+		offset: offset,
+	}
+}
+
+func (self *ShiftedDriver) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	if offset, ok := self.offset(); ok && offset != 0 {
+		// The stats are shared with the other drivers and the memory cache.
+		shifted := *stats
+		shifted.Timestamp = stats.Timestamp.Add(offset)
+		stats = &shifted
+	}
+	return self.driver.AddStats(ref, stats)
+}
+
+func (self *ShiftedDriver) RecentStats(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	return self.driver.RecentStats(containerName, numStats)
+}
+
+func (self *ShiftedDriver) VerifyConnection() error {
+	return self.driver.VerifyConnection()
+}
+
+func (self *ShiftedDriver) Close() error {
+	return self.driver.Close()
+}
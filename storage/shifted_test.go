@@ -0,0 +1,55 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+func TestShiftCorrectsTimestamps(t *testing.T) {
+	backend := &recordingDriver{}
+	var offset time.Duration
+	known := false
+	driver := Shift(backend, func() (time.Duration, bool) {
+		return offset, known
+	})
+	ref := info.ContainerReference{Name: "/"}
+
+	// Not measured yet.
+	stats := dedupStats(0, 100, 1000)
+	if err := driver.AddStats(ref, stats); err != nil {
+		t.Fatalf("Failed to add stats: %v", err)
+	}
+	offset, known = -3*time.Second, true
+	if err := driver.AddStats(ref, stats); err != nil {
+		t.Fatalf("Failed to add stats: %v", err)
+	}
+
+	if len(backend.added) != 2 {
+		t.Fatalf("Expected 2 stats to be written, got %d", len(backend.added))
+	}
+	if !backend.added[0].Timestamp.Equal(dedupStart) {
+		t.Errorf("Expected the stats to be written as timestamped while the offset is not known, got %v", backend.added[0].Timestamp)
+	}
+	if expected := dedupStart.Add(-3 * time.Second); !backend.added[1].Timestamp.Equal(expected) {
+		t.Errorf("Expected the stats to be written at %v, got %v", expected, backend.added[1].Timestamp)
+	}
+	if !stats.Timestamp.Equal(dedupStart) || backend.added[1].Cpu.Usage.Total != 100 {
+		t.Errorf("Expected the stats written to be a copy, the original is now %v", stats.Timestamp)
+	}
+}
@@ -14,9 +14,19 @@
 
 package storage
 
-import info "github.com/google/cadvisor/info/v1"
+import (
+	"strings"
+
+	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+)
 
 type StorageDriver interface {
+	// Adds the stats of the container. The manager always passes the full
+	// reference of the container: its name, which is unique on the machine,
+	// and its aliases (e.g.: the name and ID of a Docker container) and their
+	// namespace, if any. Aliases may change over the life of the container, so
+	// drivers should index by name.
 	AddStats(ref info.ContainerReference, stats *info.ContainerStats) error
 
 	// Read most recent stats. numStats indicates max number of stats
@@ -26,8 +36,57 @@ type StorageDriver interface {
 	// recent stats should be the last.
 	RecentStats(containerName string, numStats int) ([]*info.ContainerStats, error)
 
+	// Checks that the storage can be reached and written to with the
+	// configured address and credentials. Called once at startup so that
+	// misconfigurations are reported before any stats are added.
+	VerifyConnection() error
+
 	// Close will clear the state of the storage driver. The elements
 	// stored in the underlying storage may or may not be deleted depending
 	// on the implementation of the storage driver.
 	Close() error
 }
+
+// Implemented by the storage drivers which can also persist events (e.g.:
+// OOMs, container creations and deletions) so that they outlive cAdvisor.
+type EventStorage interface {
+	// Adds the event to the storage.
+	AddEvent(e *events.Event) error
+
+	// Returns the stored events which satisfy the request, sorted
+	// chronologically. The data of the events (Event.EventData) is returned
+	// as decoded from JSON.
+	Events(request *events.Request) (events.EventSlice, error)
+}
+
+// Returns the driver as an EventStorage if it can persist events. Events are
+// not filtered by the filters of the drivers.
+func AsEventStorage(driver StorageDriver) (EventStorage, bool) {
+	if multi, ok := driver.(*MultiDriver); ok {
+		return multi.eventStorage()
+	}
+	if filtered, ok := driver.(*FilteredDriver); ok {
+		driver = filtered.driver
+	}
+	if shifted, ok := driver.(*ShiftedDriver); ok {
+		driver = shifted.driver
+	}
+	if dedup, ok := driver.(*DedupDriver); ok {
+		driver = dedup.driver
+	}
+	if verified, ok := driver.(*VerifiedDriver); ok {
+		driver = verified.driver
+	}
+	if instrumented, ok := driver.(*InstrumentedDriver); ok {
+		driver = instrumented.driver
+	}
+	eventStorage, ok := driver.(EventStorage)
+	return eventStorage, ok
+}
+
+// Quotes the value as a string literal of the SQL-like query languages of the
+// storage backends, e.g.: it's -> 'it\'s'. Container names may contain any
+// character but a slash.
+func QuoteString(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
@@ -0,0 +1,80 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/events"
+)
+
+func TestQuoteString(t *testing.T) {
+	testCases := map[string]string{
+		"/docker/abcdef":                  `'/docker/abcdef'`,
+		"/system.slice/user@1000.service": `'/system.slice/user@1000.service'`,
+		"/with space/100%":                `'/with space/100%'`,
+		"/it's":                           `'/it\'s'`,
+		`/back\slash'`:                    `'/back\\slash\''`,
+	}
+	for value, expected := range testCases {
+		if actual := QuoteString(value); actual != expected {
+			t.Errorf("QuoteString(%q) is %s, expected %s", value, actual, expected)
+		}
+	}
+}
+
+// A driver which persists events.
+type eventDriver struct {
+	StorageDriver
+}
+
+func (self *eventDriver) AddEvent(e *events.Event) error {
+	return nil
+}
+
+func (self *eventDriver) Events(request *events.Request) (events.EventSlice, error) {
+	return nil, nil
+}
+
+func TestAsEventStorage(t *testing.T) {
+	driver := &eventDriver{}
+	if eventStorage, ok := AsEventStorage(driver); !ok || eventStorage != driver {
+		t.Errorf("Expected the driver to be an event storage, got %v (ok: %v)", eventStorage, ok)
+	}
+	// Instrumentation does not hide it.
+	if eventStorage, ok := AsEventStorage(Instrument("events", driver)); !ok || eventStorage != driver {
+		t.Errorf("Expected the instrumented driver to be an event storage, got %v (ok: %v)", eventStorage, ok)
+	}
+	if eventStorage, ok := AsEventStorage(Dedup("events", Instrument("events", driver), time.Minute, 0)); !ok || eventStorage != driver {
+		t.Errorf("Expected the deduplicated driver to be an event storage, got %v (ok: %v)", eventStorage, ok)
+	}
+	if eventStorage, ok := AsEventStorage(Filter("events", driver, &ContainerFilter{})); !ok || eventStorage != driver {
+		t.Errorf("Expected the filtered driver to be an event storage, got %v (ok: %v)", eventStorage, ok)
+	}
+	// Only the drivers persisting events are written them.
+	if eventStorage, ok := AsEventStorage(Multi(&fakeDriver{}, driver)); !ok || eventStorage != driver {
+		t.Errorf("Expected the driver persisting events to be the event storage, got %v (ok: %v)", eventStorage, ok)
+	}
+	if eventStorage, ok := AsEventStorage(Multi(driver, &eventDriver{})); !ok || len(eventStorage.(multiEventStorage)) != 2 {
+		t.Errorf("Expected both drivers to be the event storage, got %v (ok: %v)", eventStorage, ok)
+	}
+	if _, ok := AsEventStorage(driver.StorageDriver); ok {
+		t.Errorf("Expected a driver without events not to be an event storage")
+	}
+	if _, ok := AsEventStorage(Multi(&fakeDriver{})); ok {
+		t.Errorf("Expected drivers without events not to be an event storage")
+	}
+}
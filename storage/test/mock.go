@@ -34,6 +34,10 @@ func (self *MockStorageDriver) RecentStats(containerName string, numStats int) (
 	return args.Get(0).([]*info.ContainerStats), args.Error(1)
 }
 
+func (self *MockStorageDriver) VerifyConnection() error {
+	return nil
+}
+
 func (self *MockStorageDriver) Close() error {
 	if self.MockCloseMethod {
 		args := self.Called()
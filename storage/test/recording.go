@@ -0,0 +1,67 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"sync"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// A storage driver which records the stats added to it, by container name.
+// Class is thread-safe.
+type RecordingStorageDriver struct {
+	lock  sync.Mutex
+	stats map[string][]*info.ContainerStats
+}
+
+func NewRecordingStorageDriver() *RecordingStorageDriver {
+	return &RecordingStorageDriver{
+		stats: make(map[string][]*info.ContainerStats),
+	}
+}
+
+func (self *RecordingStorageDriver) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.stats[ref.Name] = append(self.stats[ref.Name], stats)
+	return nil
+}
+
+// Returns all the stats recorded for the container, in the order they were added.
+func (self *RecordingStorageDriver) RecentStats(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return append([]*info.ContainerStats(nil), self.stats[containerName]...), nil
+}
+
+func (self *RecordingStorageDriver) VerifyConnection() error {
+	return nil
+}
+
+// Returns the names of the containers stats were recorded for.
+func (self *RecordingStorageDriver) ContainerNames() map[string]bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	names := make(map[string]bool, len(self.stats))
+	for name := range self.stats {
+		names[name] = true
+	}
+	return names
+}
+
+func (self *RecordingStorageDriver) Close() error {
+	return nil
+}
@@ -0,0 +1,189 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Delays before retrying to verify the connection to a degraded driver. The
+// delay doubles after each failed retry, up to the maximum.
+var (
+	verificationBackoff    = time.Second
+	maxVerificationBackoff = 5 * time.Minute
+)
+
+// The state of the connection to a storage driver.
+type ConnectionStatus struct {
+	// Name of the driver, e.g.: influxdb.
+	Name string
+
+	// Whether the connection was verified. Stats are only written to the
+	// driver once it is.
+	Verified bool
+
+	// Number of verifications attempted.
+	Attempts int
+
+	// Error of the last failed verification, if not verified.
+	LastError string
+
+	// Time of the next verification, if not verified.
+	NextRetry time.Time
+
+	// Number of stats dropped while the connection was not verified.
+	DroppedStats uint64
+}
+
+// A storage driver whose connection is verified before stats are written to
+// it. Until the connection is verified, the driver is degraded: the stats
+// written to it are dropped and the verification is retried with exponential
+// backoff. Class is thread-safe.
+type VerifiedDriver struct {
+	driver StorageDriver
+
+	lock   sync.Mutex
+	status ConnectionStatus
+	// Timer for the next verification. Nil if not retrying.
+	retry  *time.Timer
+	closed bool
+}
+
+var (
+	verifiedLock    sync.Mutex
+	verifiedDrivers []*VerifiedDriver
+)
+
+// Verifies the connection to the specified driver, named e.g. influxdb. If the
+// verification fails, an error is returned if the driver is required.
+// Otherwise the driver is returned degraded until a retry succeeds. The
+// connections of the drivers are reported by ConnectionStatuses().
+func Verify(name string, driver StorageDriver, required bool) (*VerifiedDriver, error) {
+	self := &VerifiedDriver{
+		driver: driver,
+		status: ConnectionStatus{
+			Name: name,
+		},
+	}
+	if err := self.VerifyConnection(); err != nil {
+		if required {
+			self.Close()
+			return nil, fmt.Errorf("failed to verify the connection to storage driver %q: %v", name, err)
+		}
+		glog.Errorf("Failed to verify the connection to storage driver %q, no stats are written to it until it is verified: %v", name, err)
+	} else {
+		glog.Infof("Verified the connection to storage driver %q", name)
+	}
+
+	verifiedLock.Lock()
+	defer verifiedLock.Unlock()
+	verifiedDrivers = append(verifiedDrivers, self)
+	return self, nil
+}
+
+// Verifies the connection to the driver now. If it fails, the verification is
+// retried later.
+func (self *VerifiedDriver) VerifyConnection() error {
+	err := self.driver.VerifyConnection()
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.status.Attempts++
+	if self.retry != nil {
+		self.retry.Stop()
+		self.retry = nil
+	}
+	if err == nil {
+		self.status.Verified = true
+		self.status.LastError = ""
+		self.status.NextRetry = time.Time{}
+		return nil
+	}
+
+	self.status.Verified = false
+	self.status.LastError = err.Error()
+	backoff := verificationBackoff << uint(self.status.Attempts-1)
+	if backoff > maxVerificationBackoff || backoff <= 0 {
+		backoff = maxVerificationBackoff
+	}
+	if !self.closed {
+		self.status.NextRetry = time.Now().Add(backoff)
+		self.retry = time.AfterFunc(backoff, self.retryVerification)
+	}
+	return err
+}
+
+func (self *VerifiedDriver) retryVerification() {
+	err := self.VerifyConnection()
+	status := self.Status()
+	if err != nil {
+		glog.V(2).Infof("Failed to verify the connection to storage driver %q, retrying at %v: %v", status.Name, status.NextRetry, err)
+		return
+	}
+	glog.Infof("Verified the connection to storage driver %q after %d attempts, %d stats were dropped until then", status.Name, status.Attempts, status.DroppedStats)
+}
+
+// Returns the state of the connection to the driver.
+func (self *VerifiedDriver) Status() ConnectionStatus {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.status
+}
+
+func (self *VerifiedDriver) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	self.lock.Lock()
+	verified := self.status.Verified
+	if !verified {
+		self.status.DroppedStats++
+	}
+	self.lock.Unlock()
+	if !verified {
+		return nil
+	}
+	return self.driver.AddStats(ref, stats)
+}
+
+func (self *VerifiedDriver) RecentStats(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	return self.driver.RecentStats(containerName, numStats)
+}
+
+// Stops retrying to verify the connection and closes the driver.
+func (self *VerifiedDriver) Close() error {
+	self.lock.Lock()
+	self.closed = true
+	if self.retry != nil {
+		self.retry.Stop()
+		self.retry = nil
+	}
+	self.lock.Unlock()
+	return self.driver.Close()
+}
+
+// Returns the state of the connections to the drivers passed to Verify().
+func ConnectionStatuses() []ConnectionStatus {
+	verifiedLock.Lock()
+	drivers := append([]*VerifiedDriver(nil), verifiedDrivers...)
+	verifiedLock.Unlock()
+	ret := make([]ConnectionStatus, 0, len(drivers))
+	for _, driver := range drivers {
+		ret = append(ret, driver.Status())
+	}
+	return ret
+}
@@ -0,0 +1,142 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A storage driver whose connection can only be verified after the specified
+// number of failed verifications.
+type unreachableDriver struct {
+	lock     sync.Mutex
+	failures int
+	attempts int
+	added    int
+}
+
+func (self *unreachableDriver) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.added++
+	return nil
+}
+
+func (self *unreachableDriver) RecentStats(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	return nil, nil
+}
+
+func (self *unreachableDriver) VerifyConnection() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.attempts++
+	if self.attempts <= self.failures {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (self *unreachableDriver) Close() error {
+	return nil
+}
+
+func (self *unreachableDriver) addedStats() int {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.added
+}
+
+func withVerificationBackoff(backoff time.Duration) func() {
+	oldBackoff, oldMaxBackoff := verificationBackoff, maxVerificationBackoff
+	verificationBackoff, maxVerificationBackoff = backoff, 4*backoff
+	return func() {
+		verificationBackoff, maxVerificationBackoff = oldBackoff, oldMaxBackoff
+	}
+}
+
+func TestVerifyFailsRequiredDriver(t *testing.T) {
+	defer withVerificationBackoff(time.Hour)()
+	_, err := Verify("unreachable", &unreachableDriver{failures: 1}, true)
+	assert.NotNil(t, err)
+}
+
+func TestVerifiedDriverWritesStats(t *testing.T) {
+	driver := &unreachableDriver{}
+	verified, err := Verify("reachable", driver, true)
+	require.Nil(t, err)
+	defer verified.Close()
+
+	require.Nil(t, verified.AddStats(info.ContainerReference{Name: "/"}, &info.ContainerStats{}))
+	assert.Equal(t, 1, driver.addedStats())
+	status := verified.Status()
+	assert.True(t, status.Verified)
+	assert.Equal(t, 1, status.Attempts)
+}
+
+func TestDegradedDriverIsRetriedWithBackoff(t *testing.T) {
+	defer withVerificationBackoff(10 * time.Millisecond)()
+	driver := &unreachableDriver{failures: 3}
+	verified, err := Verify("degraded", driver, false)
+	require.Nil(t, err)
+	defer verified.Close()
+
+	// Stats are dropped while degraded.
+	status := verified.Status()
+	assert.False(t, status.Verified)
+	assert.Equal(t, "connection refused", status.LastError)
+	assert.False(t, status.NextRetry.IsZero())
+	require.Nil(t, verified.AddStats(info.ContainerReference{Name: "/"}, &info.ContainerStats{}))
+	assert.Equal(t, 0, driver.addedStats())
+
+	found := false
+	for _, s := range ConnectionStatuses() {
+		if s.Name == "degraded" {
+			found = true
+			assert.Equal(t, uint64(1), s.DroppedStats)
+		}
+	}
+	assert.True(t, found, "degraded driver should be reported")
+
+	// Retried after 10ms, 20ms and 40ms.
+	deadline := time.Now().Add(5 * time.Second)
+	for !verified.Status().Verified && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	status = verified.Status()
+	require.True(t, status.Verified, "driver should be verified once reachable")
+	assert.Equal(t, 4, status.Attempts)
+	assert.Equal(t, "", status.LastError)
+	require.Nil(t, verified.AddStats(info.ContainerReference{Name: "/"}, &info.ContainerStats{}))
+	assert.Equal(t, 1, driver.addedStats())
+}
+
+func TestClosedDriverIsNotRetried(t *testing.T) {
+	defer withVerificationBackoff(time.Millisecond)()
+	driver := &unreachableDriver{failures: 100}
+	verified, err := Verify("closed", driver, false)
+	require.Nil(t, err)
+	require.Nil(t, verified.Close())
+
+	attempts := verified.Status().Attempts
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, attempts, verified.Status().Attempts)
+}
@@ -12,20 +12,26 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// +build linux
+
 package main
 
 import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/golang/glog"
+	info "github.com/google/cadvisor/info/v1"
 	"github.com/google/cadvisor/manager"
 	"github.com/google/cadvisor/storage"
 	"github.com/google/cadvisor/storage/bigquery"
+	"github.com/google/cadvisor/storage/file"
 	"github.com/google/cadvisor/storage/influxdb"
 	"github.com/google/cadvisor/storage/memory"
+	"github.com/google/cadvisor/utils/clockskew"
 )
 
 var argDbUsername = flag.String("storage_driver_user", "root", "database username")
@@ -35,23 +41,109 @@ var argDbName = flag.String("storage_driver_db", "cadvisor", "database name")
 var argDbTable = flag.String("storage_driver_table", "stats", "table name")
 var argDbIsSecure = flag.Bool("storage_driver_secure", false, "use secure connection with database")
 var argDbBufferDuration = flag.Duration("storage_driver_buffer_duration", 60*time.Second, "Writes in the storage driver will be buffered for this duration, and committed to the non memory backends as a single transaction")
+var argFileStorageDir = flag.String("storage_driver_file_dir", "/var/lib/cadvisor", "Directory the file storage driver writes the stats and events to")
+var argFileStorageRetention = flag.Duration("storage_driver_file_retention", 7*24*time.Hour, "Age of the oldest stats and events kept by the file storage driver. Older ones are left out of reads and dropped from the files, which are compacted at startup and hourly. 0 keeps them all")
+var argStorageRequired = flag.Bool("storage_driver_required", false, "Whether to exit at startup if the connection to the storage driver cannot be verified. Otherwise no stats are written to it until a verification retried in the background succeeds")
+var argInfluxdbLegacySchema = flag.Bool("storage_influxdb_legacy_schema", false, "Whether to write to InfluxDB with the schema of InfluxDB 0.8: a series named after --storage_driver_table with a column per stat. Otherwise stats are written with the line protocol of InfluxDB 0.9 and later, with a measurement per group of stats and the machine and container as tags")
+var argInfluxdbRetentionPolicy = flag.String("storage_influxdb_retention_policy", "", "Retention policy of the InfluxDB database the stats are written to. Empty uses the default policy of the database. Not supported with the legacy schema")
+var argInfluxdbCreateDatabase = flag.Bool("storage_influxdb_create_database", false, "Whether to create the InfluxDB database at startup if it does not exist. Not supported with the legacy schema")
+var argInfluxdbBatchSize = flag.Int("storage_influxdb_batch_size", 5000, "Largest number of points written to InfluxDB in a request. The buffered points are written once there are that many, even if --storage_driver_buffer_duration has not elapsed. Not supported with the legacy schema")
+var argBigqueryBatchSize = flag.Int("storage_bigquery_batch_size", 500, "Largest number of rows inserted in BigQuery in a request. The buffered rows are inserted once there are that many, even if --storage_driver_buffer_duration has not elapsed")
+var argBigquerySchemaDryRun = flag.Bool("storage_bigquery_schema_dry_run", false, "Whether to only log the columns that the BigQuery tables are missing at startup rather than adding them. The missing columns are not written")
+var argDedupWindow = flag.Duration("storage_dedup_window", 0, "Longest time the stats of a container are not written to the backend storage while they do not change. Stats equal to the last written, gauges within --storage_dedup_tolerance, are skipped until they are that old. Stats are still cached in memory. 0 writes all stats")
+var argDedupTolerance = flag.Float64("storage_dedup_tolerance", 0.01, "Relative change of the gauges of a container (e.g.: its memory usage) below which its stats are considered unchanged by --storage_dedup_window")
+var argStorageDriverFilters = flag.String("storage_driver_filters", "", "Comma-separated filters of the containers whose stats are written to each storage driver, e.g.: influxdb:/docker,bigquery:namespace=docker. A filter is a storage driver and a container name, which selects the container and its subcontainers, or namespace= and a namespace of container aliases. A driver without filters is written the stats of all the containers")
+var argMemoryCheckpointPath = flag.String("storage_memory_checkpoint_path", "", "File the stats cached in memory are written to on shutdown and restored from on startup, so that restarts do not lose them. Empty disables checkpointing")
+var argClockSkewThreshold = flag.Duration("clock_skew_threshold", 2*time.Second, "Offset of the clock of the machine from the storage backend or --clock_skew_ntp_server above which a warning is logged and /validate reports the clock as skewed")
+var argClockSkewInterval = flag.Duration("clock_skew_check_interval", 10*time.Minute, "Interval between the comparisons of the clock of the machine with the storage backends which report their time (InfluxDB) and --clock_skew_ntp_server")
+var argClockSkewNtpServer = flag.String("clock_skew_ntp_server", "", "NTP server (host or host:port) the clock of the machine is compared with, in addition to the storage backends which report their time. Empty only compares with the backends")
+var argClockSkewCorrect = flag.Bool("clock_skew_correct", false, "Whether to add the measured offset of the clock of the machine to the timestamps of the stats written to the storage backends: that from the backend itself if it reports its time, otherwise that from --clock_skew_ntp_server. The stats cached in memory keep their timestamps")
 
 const statsRequestedByUI = 60
 
-// Creates a memory storage with an optional backend storage option.
-func NewMemoryStorage(backendStorageName string) (*memory.InMemoryStorage, error) {
-	var storageDriver *memory.InMemoryStorage
-	var backendStorage storage.StorageDriver
-	var err error
+// Longest time an NTP server has to answer.
+const ntpTimeout = 5 * time.Second
+
+// Creates a memory storage with optional backend storages, e.g.: influxdb,bigquery.
+// The backend storages are tagged with the identification of the machine.
+func NewMemoryStorage(backendStorageNames string, machineInfo *info.MachineInfo) (*memory.InMemoryStorage, error) {
 	// TODO(vmarmol): We shouldn't need the housekeeping interval here and it shouldn't be public.
-	statsToCache := int(*argDbBufferDuration / *manager.HousekeepingInterval)
+	statsToCache := int(*argDbBufferDuration / managerConfig.HousekeepingInterval)
 	if statsToCache < statsRequestedByUI {
 		// The UI requests the most recent 60 stats by default.
 		statsToCache = statsRequestedByUI
 	}
-	switch backendStorageName {
-	case "":
-		backendStorage = nil
+	var names []string
+	if backendStorageNames != "" {
+		names = strings.Split(backendStorageNames, ",")
+	}
+	// Invalid filters are reported before connecting to any backend.
+	filters, err := storage.ParseFilters(*argStorageDriverFilters, names)
+	if err != nil {
+		return nil, err
+	}
+	clockMonitor := clockskew.New(*argClockSkewThreshold)
+	if *argClockSkewNtpServer != "" {
+		clockMonitor.AddSource("ntp", clockskew.NewNtpSource(*argClockSkewNtpServer, ntpTimeout))
+	}
+	backendStorages := make([]storage.StorageDriver, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[name] {
+			closeBackendStorages(backendStorages)
+			clockMonitor.Stop()
+			return nil, fmt.Errorf("backend storage driver %q is specified more than once", name)
+		}
+		seen[name] = true
+		backendStorage, err := newBackendStorage(name, machineInfo, clockMonitor)
+		if err != nil {
+			closeBackendStorages(backendStorages)
+			clockMonitor.Stop()
+			return nil, err
+		}
+		if filter, ok := filters[name]; ok {
+			backendStorage = storage.Filter(name, backendStorage, filter)
+			glog.Infof("Only writing the stats of %q to backend storage type %q", filter, name)
+		}
+		glog.Infof("Using backend storage type %q", name)
+		backendStorages = append(backendStorages, backendStorage)
+	}
+	clockMonitor.Start(*argClockSkewInterval)
+	var backendStorage storage.StorageDriver
+	switch len(backendStorages) {
+	case 0:
+		glog.Infof("No backend storage selected")
+	case 1:
+		backendStorage = backendStorages[0]
+	default:
+		backendStorage = storage.Multi(backendStorages...)
+	}
+	glog.Infof("Caching %d stats in memory", statsToCache)
+	storageDriver := memory.New(statsToCache, backendStorage)
+	if *argMemoryCheckpointPath != "" {
+		// Only restore the stats which would still be cached had we not restarted.
+		retention := time.Duration(statsToCache) * managerConfig.HousekeepingInterval
+		storageDriver.ReadCheckpointFile(*argMemoryCheckpointPath, time.Now().Add(-retention))
+	}
+	return storageDriver, nil
+}
+
+// Closes the backend storages created before one failed to be.
+func closeBackendStorages(backendStorages []storage.StorageDriver) {
+	for _, backendStorage := range backendStorages {
+		if err := backendStorage.Close(); err != nil {
+			glog.Warningf("Failed to close backend storage: %v", err)
+		}
+	}
+}
+
+// Creates the backend storage of the specified name, e.g.: influxdb, tagged with
+// the identification of the machine. The clock of the machine is compared with
+// that of the backend if it reports it.
+func newBackendStorage(name string, machineInfo *info.MachineInfo, clockMonitor *clockskew.Monitor) (storage.StorageDriver, error) {
+	var backendStorage storage.StorageDriver
+	var err error
+	switch name {
 	case "influxdb":
 		var hostname string
 		hostname, err = os.Hostname()
@@ -59,16 +151,33 @@ func NewMemoryStorage(backendStorageName string) (*memory.InMemoryStorage, error
 			return nil, err
 		}
 
-		backendStorage, err = influxdb.New(
-			hostname,
-			*argDbTable,
-			*argDbName,
-			*argDbUsername,
-			*argDbPassword,
-			*argDbHost,
-			*argDbIsSecure,
-			*argDbBufferDuration,
-		)
+		if *argInfluxdbLegacySchema {
+			backendStorage, err = influxdb.New(
+				hostname,
+				manager.GetMachineTags(machineInfo),
+				*argDbTable,
+				*argDbName,
+				*argDbUsername,
+				*argDbPassword,
+				*argDbHost,
+				*argDbIsSecure,
+				*argDbBufferDuration,
+			)
+		} else {
+			backendStorage, err = influxdb.NewTagged(
+				hostname,
+				manager.GetMachineTags(machineInfo),
+				*argDbName,
+				*argInfluxdbRetentionPolicy,
+				*argDbUsername,
+				*argDbPassword,
+				*argDbHost,
+				*argDbIsSecure,
+				*argInfluxdbCreateDatabase,
+				*argDbBufferDuration,
+				*argInfluxdbBatchSize,
+			)
+		}
 	case "bigquery":
 		var hostname string
 		hostname, err = os.Hostname()
@@ -77,21 +186,38 @@ func NewMemoryStorage(backendStorageName string) (*memory.InMemoryStorage, error
 		}
 		backendStorage, err = bigquery.New(
 			hostname,
+			manager.GetMachineTags(machineInfo)["machine_id"],
 			*argDbTable,
 			*argDbName,
+			*argDbBufferDuration,
+			*argBigqueryBatchSize,
+			*argBigquerySchemaDryRun,
 		)
+	case "file":
+		backendStorage, err = file.New(*argFileStorageDir, *argFileStorageRetention)
 	default:
-		err = fmt.Errorf("unknown backend storage driver: %v", *argDbDriver)
+		err = fmt.Errorf("unknown backend storage driver: %v", name)
 	}
 	if err != nil {
 		return nil, err
 	}
-	if backendStorageName != "" {
-		glog.Infof("Using backend storage type %q", backendStorageName)
-	} else {
-		glog.Infof("No backend storage selected")
+	if source, ok := backendStorage.(clockskew.Source); ok {
+		clockMonitor.AddSource(name, source)
 	}
-	glog.Info("Caching %d stats in memory", statsToCache)
-	storageDriver = memory.New(statsToCache, backendStorage)
-	return storageDriver, nil
+	// Instrumented so that slow or failing writes are visible without verbose logs.
+	backendStorage = storage.Instrument(name, backendStorage)
+	// Misconfigurations are reported now rather than by every write.
+	backendStorage, err = storage.Verify(name, backendStorage, *argStorageRequired)
+	if err != nil {
+		return nil, err
+	}
+	if *argDedupWindow > 0 {
+		backendStorage = storage.Dedup(name, backendStorage, *argDedupWindow, *argDedupTolerance)
+	}
+	if *argClockSkewCorrect {
+		backendStorage = storage.Shift(backendStorage, func() (time.Duration, bool) {
+			return clockMonitor.Offset(name, "ntp")
+		})
+	}
+	return backendStorage, nil
 }
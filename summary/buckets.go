@@ -0,0 +1,217 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"time"
+
+	"github.com/google/cadvisor/info/v1"
+)
+
+// Sums of the stats of a period, from which its v1.StatsBucket is computed.
+// Sums rather than means are kept so that periods can be merged exactly.
+type bucket struct {
+	start   time.Time
+	samples uint64
+
+	// Counters used during the period.
+	cpu     uint64
+	rxBytes uint64
+	txBytes uint64
+
+	// Number of samples with memory stats, and the sums and maximums of
+	// their gauges.
+	memorySamples uint64
+	usageSum      uint64
+	usageMax      uint64
+	workingSetSum uint64
+	workingSetMax uint64
+}
+
+func (self *bucket) merge(other *bucket) {
+	self.samples += other.samples
+	self.cpu += other.cpu
+	self.rxBytes += other.rxBytes
+	self.txBytes += other.txBytes
+	self.memorySamples += other.memorySamples
+	self.usageSum += other.usageSum
+	self.workingSetSum += other.workingSetSum
+	if other.usageMax > self.usageMax {
+		self.usageMax = other.usageMax
+	}
+	if other.workingSetMax > self.workingSetMax {
+		self.workingSetMax = other.workingSetMax
+	}
+}
+
+func (self *bucket) statsBucket() v1.StatsBucket {
+	ret := v1.StatsBucket{
+		Timestamp: self.start,
+		Samples:   self.samples,
+		CpuUsage:  self.cpu,
+		RxBytes:   self.rxBytes,
+		TxBytes:   self.txBytes,
+	}
+	if self.memorySamples != 0 {
+		ret.MemoryUsage = v1.GaugeStats{Mean: self.usageSum / self.memorySamples, Max: self.usageMax}
+		ret.WorkingSet = v1.GaugeStats{Mean: self.workingSetSum / self.memorySamples, Max: self.workingSetMax}
+	}
+	return ret
+}
+
+// Aggregates consecutive stats into buckets of a resolution, aligned on
+// multiples of it. Counters are attributed to the bucket of the stats they
+// were read in, the first stats only serve as the baseline.
+type bucketer struct {
+	resolution time.Duration
+
+	// Completed buckets, oldest first. At most maxBuckets are kept if it is
+	// not zero.
+	buckets    []bucket
+	maxBuckets int
+
+	// Bucket of the latest stats, nil before the first ones.
+	current *bucket
+
+	// Latest values of the counters, when known.
+	cpu        uint64
+	rxBytes    uint64
+	txBytes    uint64
+	hasCpu     bool
+	hasNetwork bool
+}
+
+func newBucketer(resolution time.Duration, maxBuckets int) *bucketer {
+	return &bucketer{
+		resolution: resolution,
+		maxBuckets: maxBuckets,
+	}
+}
+
+// Amount a counter increased by. A counter which went back was restarted
+// (e.g.: its network interface was recreated) and counts from zero.
+func counterDelta(value, previous uint64) uint64 {
+	if value < previous {
+		return value
+	}
+	return value - previous
+}
+
+func (self *bucketer) add(stats *v1.ContainerStats) {
+	start := stats.Timestamp.Truncate(self.resolution)
+	// Stats older than the current bucket (e.g.: after the clock was set
+	// back) are added to it.
+	if self.current == nil || start.After(self.current.start) {
+		if self.current != nil {
+			self.buckets = append(self.buckets, *self.current)
+			if self.maxBuckets != 0 && len(self.buckets) > self.maxBuckets {
+				self.buckets = self.buckets[len(self.buckets)-self.maxBuckets:]
+			}
+		}
+		self.current = &bucket{start: start}
+	}
+	b := self.current
+	b.samples++
+	if !stats.Missing(v1.StatsSectionCpu) {
+		if self.hasCpu {
+			b.cpu += counterDelta(stats.Cpu.Usage.Total, self.cpu)
+		}
+		self.cpu, self.hasCpu = stats.Cpu.Usage.Total, true
+	}
+	if !stats.Missing(v1.StatsSectionNetwork) {
+		if self.hasNetwork {
+			b.rxBytes += counterDelta(stats.Network.RxBytes, self.rxBytes)
+			b.txBytes += counterDelta(stats.Network.TxBytes, self.txBytes)
+		}
+		self.rxBytes, self.txBytes, self.hasNetwork = stats.Network.RxBytes, stats.Network.TxBytes, true
+	}
+	if !stats.Missing(v1.StatsSectionMemory) {
+		b.memorySamples++
+		b.usageSum += stats.Memory.Usage
+		b.workingSetSum += stats.Memory.WorkingSet
+		if stats.Memory.Usage > b.usageMax {
+			b.usageMax = stats.Memory.Usage
+		}
+		if stats.Memory.WorkingSet > b.workingSetMax {
+			b.workingSetMax = stats.Memory.WorkingSet
+		}
+	}
+}
+
+// Returns the completed buckets followed by the current one, if any.
+func (self *bucketer) all() []bucket {
+	ret := make([]bucket, 0, len(self.buckets)+1)
+	ret = append(ret, self.buckets...)
+	if self.current != nil {
+		ret = append(ret, *self.current)
+	}
+	return ret
+}
+
+// Merges consecutive buckets into buckets of a coarser resolution, which
+// must be a multiple of theirs. Buckets not overlapping [start, end] are
+// left out, a zero time leaves the interval open.
+func regroup(buckets []bucket, resolution time.Duration, start, end time.Time) []v1.StatsBucket {
+	var ret []v1.StatsBucket
+	var current *bucket
+	for i := range buckets {
+		groupStart := buckets[i].start.Truncate(resolution)
+		if !start.IsZero() && !groupStart.Add(resolution).After(start) {
+			continue
+		}
+		if !end.IsZero() && groupStart.After(end) {
+			break
+		}
+		if current == nil || !groupStart.Equal(current.start) {
+			if current != nil {
+				ret = append(ret, current.statsBucket())
+			}
+			current = &bucket{start: groupStart}
+		}
+		current.merge(&buckets[i])
+	}
+	if current != nil {
+		ret = append(ret, current.statsBucket())
+	}
+	return ret
+}
+
+// Aggregates the stats, oldest first, into buckets of the resolution.
+func Buckets(stats []*v1.ContainerStats, resolution time.Duration) []v1.StatsBucket {
+	b := newBucketer(resolution, 0)
+	for _, s := range stats {
+		b.add(s)
+	}
+	all := b.all()
+	ret := make([]v1.StatsBucket, 0, len(all))
+	for i := range all {
+		ret = append(ret, all[i].statsBucket())
+	}
+	return ret
+}
+
+// Returns the stats in [start, end] aggregated into buckets of the
+// resolution rounded up to a whole minute, computed from the minute
+// aggregates kept, along with the resolution used. Zero times leave the
+// interval open.
+func (s *StatsSummary) Buckets(start, end time.Time, resolution time.Duration) ([]v1.StatsBucket, time.Duration) {
+	if remainder := resolution % time.Minute; remainder != 0 {
+		resolution += time.Minute - remainder
+	}
+	s.dataLock.RLock()
+	minutes := s.minutes.all()
+	s.dataLock.RUnlock()
+	return regroup(minutes, resolution, start, end), resolution
+}
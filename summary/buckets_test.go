@@ -0,0 +1,199 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/info/v1"
+)
+
+// Stats of a container sampled every second for the specified duration, with
+// varying CPU, memory and network usage.
+func syntheticStats(start time.Time, duration time.Duration) []*v1.ContainerStats {
+	r := rand.New(rand.NewSource(1))
+	var ret []*v1.ContainerStats
+	var cpu, rx, tx uint64
+	for t := start; t.Before(start.Add(duration)); t = t.Add(time.Second) {
+		cpu += uint64(r.Int63n(int64(2 * time.Second)))
+		rx += uint64(r.Int63n(1 << 20))
+		tx += uint64(r.Int63n(1 << 16))
+		stats := &v1.ContainerStats{Timestamp: t}
+		stats.Cpu.Usage.Total = cpu
+		stats.Memory.Usage = uint64(64<<20 + r.Int63n(64<<20))
+		stats.Memory.WorkingSet = stats.Memory.Usage - uint64(r.Int63n(16<<20))
+		stats.Network.RxBytes = rx
+		stats.Network.TxBytes = tx
+		ret = append(ret, stats)
+	}
+	return ret
+}
+
+// Aggregates the stats by brute force: the stats of each bucket of the
+// resolution are collected, then summed.
+func bruteForceBuckets(stats []*v1.ContainerStats, resolution time.Duration) []v1.StatsBucket {
+	var starts []time.Time
+	byStart := make(map[time.Time][]int)
+	for i, s := range stats {
+		start := s.Timestamp.Truncate(resolution)
+		if _, ok := byStart[start]; !ok {
+			starts = append(starts, start)
+		}
+		byStart[start] = append(byStart[start], i)
+	}
+	var ret []v1.StatsBucket
+	for _, start := range starts {
+		b := v1.StatsBucket{Timestamp: start}
+		var usageSum, workingSetSum uint64
+		for _, i := range byStart[start] {
+			s := stats[i]
+			b.Samples++
+			if i > 0 {
+				b.CpuUsage += s.Cpu.Usage.Total - stats[i-1].Cpu.Usage.Total
+				b.RxBytes += s.Network.RxBytes - stats[i-1].Network.RxBytes
+				b.TxBytes += s.Network.TxBytes - stats[i-1].Network.TxBytes
+			}
+			usageSum += s.Memory.Usage
+			workingSetSum += s.Memory.WorkingSet
+			if s.Memory.Usage > b.MemoryUsage.Max {
+				b.MemoryUsage.Max = s.Memory.Usage
+			}
+			if s.Memory.WorkingSet > b.WorkingSet.Max {
+				b.WorkingSet.Max = s.Memory.WorkingSet
+			}
+		}
+		b.MemoryUsage.Mean = usageSum / b.Samples
+		b.WorkingSet.Mean = workingSetSum / b.Samples
+		ret = append(ret, b)
+	}
+	return ret
+}
+
+func checkBuckets(t *testing.T, expected, actual []v1.StatsBucket) {
+	if len(actual) != len(expected) {
+		t.Fatalf("got %d buckets, expected %d", len(actual), len(expected))
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(actual[i], expected[i]) {
+			t.Errorf("bucket %d is %+v, expected %+v", i, actual[i], expected[i])
+		}
+	}
+}
+
+var bucketsStart = time.Date(2015, 6, 1, 12, 0, 17, 0, time.UTC)
+
+func TestBucketsMatchBruteForce(t *testing.T) {
+	stats := syntheticStats(bucketsStart, 30*time.Minute)
+	for _, resolution := range []time.Duration{time.Second, 10 * time.Second, 72 * time.Second, 5 * time.Minute} {
+		checkBuckets(t, bruteForceBuckets(stats, resolution), Buckets(stats, resolution))
+	}
+}
+
+func newTestSummary(t *testing.T, stats []*v1.ContainerStats) *StatsSummary {
+	summary, err := New(v1.ContainerSpec{HasCpu: true, HasMemory: true, HasNetwork: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range stats {
+		if err := summary.AddSample(*s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return summary
+}
+
+func TestSummaryBucketsMatchBruteForce(t *testing.T) {
+	stats := syntheticStats(bucketsStart, 30*time.Minute)
+	summary := newTestSummary(t, stats)
+	for _, resolution := range []time.Duration{time.Minute, 5 * time.Minute, 10 * time.Minute} {
+		buckets, used := summary.Buckets(time.Time{}, time.Time{}, resolution)
+		if used != resolution {
+			t.Errorf("used resolution %v, expected %v", used, resolution)
+		}
+		checkBuckets(t, bruteForceBuckets(stats, resolution), buckets)
+	}
+
+	// Resolutions are rounded up to whole minutes.
+	buckets, used := summary.Buckets(time.Time{}, time.Time{}, 72*time.Second)
+	if used != 2*time.Minute {
+		t.Errorf("used resolution %v, expected 2m", used)
+	}
+	checkBuckets(t, bruteForceBuckets(stats, 2*time.Minute), buckets)
+}
+
+func TestSummaryBucketsInTimeRange(t *testing.T) {
+	stats := syntheticStats(bucketsStart, 30*time.Minute)
+	summary := newTestSummary(t, stats)
+	expected := bruteForceBuckets(stats, 5*time.Minute)
+
+	// Buckets overlapping the range are included.
+	buckets, _ := summary.Buckets(bucketsStart.Add(5*time.Minute), bucketsStart.Add(12*time.Minute), 5*time.Minute)
+	checkBuckets(t, expected[1:3], buckets)
+	buckets, _ = summary.Buckets(bucketsStart.Add(25*time.Minute), time.Time{}, 5*time.Minute)
+	checkBuckets(t, expected[5:], buckets)
+}
+
+func TestSummaryBucketsHistory(t *testing.T) {
+	stats := syntheticStats(bucketsStart, 30*time.Minute)
+	summary, err := New(v1.ContainerSpec{HasCpu: true, HasMemory: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary.minutes = newBucketer(time.Minute, 10)
+	for _, s := range stats {
+		if err := summary.AddSample(*s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The 10 completed minutes kept, and the current one.
+	buckets, _ := summary.Buckets(time.Time{}, time.Time{}, time.Minute)
+	expected := bruteForceBuckets(stats, time.Minute)
+	checkBuckets(t, expected[len(expected)-11:], buckets)
+}
+
+func TestBucketsRestartedCounters(t *testing.T) {
+	var stats []*v1.ContainerStats
+	for i, cpu := range []uint64{100, 200, 50, 80} {
+		s := &v1.ContainerStats{Timestamp: bucketsStart.Add(time.Duration(i) * time.Second)}
+		s.Cpu.Usage.Total = cpu
+		stats = append(stats, s)
+	}
+	buckets := Buckets(stats, time.Minute)
+	if len(buckets) != 1 || buckets[0].CpuUsage != 100+50+30 {
+		t.Errorf("got buckets %+v, expected a CPU usage of 180", buckets)
+	}
+}
+
+func TestBucketsSkipMissingSections(t *testing.T) {
+	var stats []*v1.ContainerStats
+	for i, cpu := range []uint64{100, 200, 300} {
+		s := &v1.ContainerStats{Timestamp: bucketsStart.Add(time.Duration(i) * time.Second)}
+		s.Cpu.Usage.Total = cpu
+		s.Memory.Usage = 1000
+		stats = append(stats, s)
+	}
+	stats[1].PartialFailure = []string{v1.StatsSectionCpu, v1.StatsSectionMemory}
+	stats[1].Memory.Usage = 0
+	buckets := Buckets(stats, time.Minute)
+	if len(buckets) != 1 {
+		t.Fatalf("got buckets %+v, expected one", buckets)
+	}
+	if buckets[0].Samples != 3 || buckets[0].CpuUsage != 200 || buckets[0].MemoryUsage.Mean != 1000 {
+		t.Errorf("got bucket %+v, expected 3 samples using 200ns of CPU and 1000 bytes of memory", buckets[0])
+	}
+}
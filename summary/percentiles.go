@@ -22,6 +22,7 @@ import (
 	"sort"
 
 	"github.com/golang/glog"
+	"github.com/google/cadvisor/info/v1"
 	info "github.com/google/cadvisor/info/v2"
 )
 
@@ -139,7 +140,7 @@ func getPercentComplete(stats []*secondSample) (percent int32) {
 	numSamples := len(stats)
 	if numSamples > 1 {
 		percent = 100
-		timeRange := stats[numSamples-1].Timestamp.Sub(stats[0].Timestamp).Nanoseconds()
+		timeRange := stats[numSamples-1].elapsedSince(stats[0]).Nanoseconds()
 		// allow some slack
 		if timeRange < 58*secondsToNanoSeconds {
 			percent = int32((timeRange * 100) / 60 * secondsToNanoSeconds)
@@ -151,7 +152,7 @@ func getPercentComplete(stats []*secondSample) (percent int32) {
 // Calculate cpurate from two consecutive total cpu usage samples.
 func getCpuRate(latest, previous secondSample) (uint64, error) {
 	var elapsed int64
-	elapsed = latest.Timestamp.Sub(previous.Timestamp).Nanoseconds()
+	elapsed = latest.elapsedSince(&previous).Nanoseconds()
 	if elapsed < 10*milliSecondsToNanoSeconds {
 		return 0, fmt.Errorf("elapsed time too small: %d ns: time now %s last %s", elapsed, latest.Timestamp.String(), previous.Timestamp.String())
 	}
@@ -163,6 +164,105 @@ func getCpuRate(latest, previous secondSample) (uint64, error) {
 	return cpuRate, nil
 }
 
+// Returns the utilization of each disk between the two samples. Disks which
+// are not in both samples or whose stats were reset are skipped.
+func getDiskUtilization(latest, previous secondSample) []info.DiskUtilization {
+	elapsed := latest.elapsedSince(&previous).Nanoseconds()
+	if elapsed < 10*milliSecondsToNanoSeconds || len(latest.Disks) == 0 {
+		return nil
+	}
+	elapsedMs := float64(elapsed) / milliSecondsToNanoSeconds
+	type device struct {
+		major, minor uint64
+	}
+	previousDisks := make(map[device]*v1.MachineDiskStats, len(previous.Disks))
+	for i := range previous.Disks {
+		disk := &previous.Disks[i]
+		previousDisks[device{disk.Major, disk.Minor}] = disk
+	}
+	var ret []info.DiskUtilization
+	for _, disk := range latest.Disks {
+		prev, ok := previousDisks[device{disk.Major, disk.Minor}]
+		if !ok || disk.IoTime < prev.IoTime || disk.WeightedIoTime < prev.WeightedIoTime {
+			continue
+		}
+		utilization := float64(disk.IoTime-prev.IoTime) * 100 / elapsedMs
+		if utilization > 100 {
+			// The kernel updates the I/O time lazily.
+			utilization = 100
+		}
+		ret = append(ret, info.DiskUtilization{
+			Major:              disk.Major,
+			Minor:              disk.Minor,
+			Device:             disk.Device,
+			Utilization:        utilization,
+			AverageQueueLength: float64(disk.WeightedIoTime-prev.WeightedIoTime) / elapsedMs,
+		})
+	}
+	return ret
+}
+
+// Returns the context switch rates between the two latest CPU samplings, nil
+// if unknown or at the first sampling.
+func getContextSwitchRates(switches *v1.ContextSwitchStats) *info.ContextSwitchRates {
+	if switches == nil || switches.Interval <= 0 {
+		return nil
+	}
+	seconds := switches.Interval.Seconds()
+	return &info.ContextSwitchRates{
+		Voluntary:   float64(switches.IntervalVoluntary) / seconds,
+		Involuntary: float64(switches.IntervalInvoluntary) / seconds,
+	}
+}
+
+// Returns the error and drop rates of each network interface between the two
+// samples. Interfaces which are not in both samples or whose counters were
+// reset (e.g.: the interface was recreated) are skipped.
+func getInterfaceIssueRates(latest, previous secondSample) []info.InterfaceIssueRates {
+	elapsed := latest.elapsedSince(&previous).Nanoseconds()
+	if elapsed < 10*milliSecondsToNanoSeconds || len(latest.Network) == 0 {
+		return nil
+	}
+	elapsedSeconds := float64(elapsed) / secondsToNanoSeconds
+	previousInterfaces := make(map[string]*v1.InterfaceStats, len(previous.Network))
+	for i := range previous.Network {
+		previousInterfaces[previous.Network[i].Name] = &previous.Network[i]
+	}
+	var ret []info.InterfaceIssueRates
+	for _, cur := range latest.Network {
+		prev, ok := previousInterfaces[cur.Name]
+		if !ok || countersReset(&cur, prev) {
+			continue
+		}
+		ret = append(ret, info.InterfaceIssueRates{
+			Name:      cur.Name,
+			RxErrors:  float64(cur.RxErrors-prev.RxErrors) / elapsedSeconds,
+			RxDropped: float64(cur.RxDropped-prev.RxDropped) / elapsedSeconds,
+			TxErrors:  float64(cur.TxErrors-prev.TxErrors) / elapsedSeconds,
+			TxDropped: float64(cur.TxDropped-prev.TxDropped) / elapsedSeconds,
+		})
+	}
+	return ret
+}
+
+// Whether any counter of the interface went back since the previous sample.
+func countersReset(cur, prev *v1.InterfaceStats) bool {
+	return cur.RxBytes < prev.RxBytes || cur.RxPackets < prev.RxPackets ||
+		cur.RxErrors < prev.RxErrors || cur.RxDropped < prev.RxDropped ||
+		cur.TxBytes < prev.TxBytes || cur.TxPackets < prev.TxPackets ||
+		cur.TxErrors < prev.TxErrors || cur.TxDropped < prev.TxDropped
+}
+
+// Whether any of the rates is above the threshold.
+func hasNetworkIssues(rates []info.InterfaceIssueRates, threshold float64) bool {
+	for _, r := range rates {
+		if r.RxErrors > threshold || r.RxDropped > threshold || r.TxErrors > threshold || r.TxDropped > threshold {
+			return true
+		}
+	}
+	return false
+}
+
 // Returns a percentile sample for a minute by aggregating seconds samples.
 func GetMinutePercentiles(stats []*secondSample) info.Usage {
 	lastSample := secondSample{}
@@ -18,7 +18,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/cadvisor/info/v1"
 	info "github.com/google/cadvisor/info/v2"
+	"github.com/google/cadvisor/utils/clock/fakeclock"
 )
 
 const Nanosecond = 1000000000
@@ -138,6 +140,41 @@ func TestSamplesCloseInTimeIgnored(t *testing.T) {
 	}
 }
 
+func TestWallClockJumpIgnored(t *testing.T) {
+	N := uint64(60)
+	var i uint64
+	clock := fakeclock.NewFakeClock(time.Now())
+	stats := make([]*secondSample, 0, N)
+	for i = 1; i <= N; i++ {
+		clock.Step(time.Second)
+		if i == N/2 {
+			// Machine resumes after being suspended for hours.
+			clock.Jump(5 * time.Hour)
+		}
+		stats = append(stats, &secondSample{
+			Timestamp: clock.Now(),
+			Monotonic: clock.Monotonic(),
+			// cpu rate is 1 s/s
+			Cpu:    i * Nanosecond,
+			Memory: 1024,
+		})
+	}
+	usage := GetMinutePercentiles(stats)
+	cpuExpected := info.Percentiles{
+		Present: true,
+		Mean:    1000,
+		Max:     1000,
+		Ninety:  1000,
+	}
+	if usage.Cpu != cpuExpected {
+		t.Errorf("cpu stats are %+v. Expected %+v", usage.Cpu, cpuExpected)
+	}
+	// The samples span 59 seconds of monotonic time, not 5 hours.
+	if usage.PercentComplete != 100 {
+		t.Errorf("percent complete is %d. Expected 100", usage.PercentComplete)
+	}
+}
+
 func TestDerivedStats(t *testing.T) {
 	N := uint64(100)
 	var i uint64
@@ -180,3 +217,188 @@ func TestDerivedStats(t *testing.T) {
 		t.Errorf("memory stats are mean %+v. Expected %+v", usage.Memory, memExpected)
 	}
 }
+
+func TestDiskUtilization(t *testing.T) {
+	ct := time.Now()
+	previous := secondSample{
+		Timestamp: ct,
+		Disks: []v1.MachineDiskStats{
+			{Major: 8, Minor: 0, Device: "sda", IoTime: 1000, WeightedIoTime: 2000},
+			{Major: 8, Minor: 16, Device: "sdb", IoTime: 5000, WeightedIoTime: 5000},
+			{Major: 8, Minor: 32, Device: "sdc", IoTime: 100, WeightedIoTime: 100},
+		},
+	}
+	latest := secondSample{
+		Timestamp: ct.Add(2 * time.Second),
+		Disks: []v1.MachineDiskStats{
+			// Busy half of the time with 3 I/Os on average.
+			{Major: 8, Minor: 0, Device: "sda", IoTime: 2000, WeightedIoTime: 8000},
+			// Stats were reset.
+			{Major: 8, Minor: 16, Device: "sdb", IoTime: 10, WeightedIoTime: 10},
+			// The I/O time is updated lazily and may exceed the interval.
+			{Major: 8, Minor: 32, Device: "sdc", IoTime: 2200, WeightedIoTime: 2200},
+			// Not in the previous sample.
+			{Major: 8, Minor: 48, Device: "sdd", IoTime: 10, WeightedIoTime: 10},
+		},
+	}
+	expected := []info.DiskUtilization{
+		{Major: 8, Minor: 0, Device: "sda", Utilization: 50, AverageQueueLength: 3},
+		{Major: 8, Minor: 32, Device: "sdc", Utilization: 100, AverageQueueLength: 1.05},
+	}
+	utilization := getDiskUtilization(latest, previous)
+	if len(utilization) != len(expected) {
+		t.Fatalf("Disk utilization is %+v. Expected %+v", utilization, expected)
+	}
+	for i := range expected {
+		if utilization[i] != expected[i] {
+			t.Errorf("Disk utilization is %+v. Expected %+v", utilization[i], expected[i])
+		}
+	}
+
+	// Samples too close in time are ignored.
+	latest.Timestamp = ct.Add(time.Millisecond)
+	if utilization := getDiskUtilization(latest, previous); utilization != nil {
+		t.Errorf("Disk utilization of samples 1ms apart is %+v. Expected none", utilization)
+	}
+}
+
+func interfaceStats(name string, packets, errors, dropped uint64) v1.InterfaceStats {
+	return v1.InterfaceStats{
+		Name:      name,
+		RxPackets: packets,
+		RxErrors:  errors,
+		RxDropped: dropped,
+		TxPackets: packets,
+	}
+}
+
+func TestInterfaceIssueRates(t *testing.T) {
+	ct := time.Now()
+	previous := secondSample{
+		Timestamp: ct,
+		Network: []v1.InterfaceStats{
+			interfaceStats("eth0", 1000, 10, 20),
+			interfaceStats("eth1", 1000, 10, 20),
+			interfaceStats("eth2", 1000, 10, 20),
+		},
+	}
+	latest := secondSample{
+		Timestamp: ct.Add(2 * time.Second),
+		Network: []v1.InterfaceStats{
+			// 2 errors and 1 drop per second.
+			interfaceStats("eth0", 2000, 14, 22),
+			// The interface was recreated, its counters restarted from zero.
+			interfaceStats("eth1", 10, 0, 0),
+			// Only the packet counter went back.
+			interfaceStats("eth2", 10, 10, 20),
+			// Not in the previous sample.
+			interfaceStats("eth3", 10, 100, 100),
+		},
+	}
+	expected := []info.InterfaceIssueRates{
+		{Name: "eth0", RxErrors: 2, RxDropped: 1},
+	}
+	rates := getInterfaceIssueRates(latest, previous)
+	if len(rates) != len(expected) {
+		t.Fatalf("Interface issue rates are %+v. Expected %+v", rates, expected)
+	}
+	for i := range expected {
+		if rates[i] != expected[i] {
+			t.Errorf("Interface issue rates are %+v. Expected %+v", rates[i], expected[i])
+		}
+	}
+
+	// Samples too close in time are ignored.
+	latest.Timestamp = ct.Add(time.Millisecond)
+	if rates := getInterfaceIssueRates(latest, previous); rates != nil {
+		t.Errorf("Interface issue rates of samples 1ms apart are %+v. Expected none", rates)
+	}
+}
+
+func TestHasNetworkIssues(t *testing.T) {
+	rates := []info.InterfaceIssueRates{
+		{Name: "eth0"},
+		{Name: "eth1", TxDropped: 0.5},
+	}
+	cases := []struct {
+		threshold float64
+		expected  bool
+	}{
+		{0, true},
+		{0.25, true},
+		// The threshold itself is not an issue.
+		{0.5, false},
+		{1, false},
+	}
+	for _, c := range cases {
+		if issues := hasNetworkIssues(rates, c.threshold); issues != c.expected {
+			t.Errorf("Network issues with threshold %v is %v. Expected %v", c.threshold, issues, c.expected)
+		}
+	}
+	if hasNetworkIssues(nil, 0) {
+		t.Errorf("Network issues without any interface. Expected none")
+	}
+}
+
+func TestInterfaceResetIsNotAnIssue(t *testing.T) {
+	oldThreshold := *networkIssueThreshold
+	*networkIssueThreshold = 0
+	defer func() {
+		*networkIssueThreshold = oldThreshold
+	}()
+	summary, err := New(v1.ContainerSpec{HasCpu: true, HasNetwork: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct := time.Now()
+	series := [][]v1.InterfaceStats{
+		{interfaceStats("eth0", 1000, 50, 50)},
+		{interfaceStats("eth0", 2000, 50, 50)},
+		// The interface bounced.
+		{interfaceStats("eth0", 10, 0, 0)},
+		{interfaceStats("eth0", 20, 0, 0)},
+		{interfaceStats("eth0", 30, 1, 0)},
+	}
+	expected := []bool{false, false, false, false, true}
+	for i, interfaces := range series {
+		stats := v1.ContainerStats{
+			Timestamp: ct.Add(time.Duration(i) * time.Second),
+			Network:   v1.NetworkStats{Interfaces: interfaces},
+		}
+		stats.Cpu.Usage.Total = uint64(i) * Nanosecond
+		if err := summary.AddSample(stats); err != nil {
+			t.Fatal(err)
+		}
+		derived, err := summary.DerivedStats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if derived.HasNetworkIssues != expected[i] {
+			t.Errorf("Network issues after sample %d is %v with rates %+v. Expected %v", i, derived.HasNetworkIssues, derived.LatestUsage.Network, expected[i])
+		}
+	}
+}
+
+func TestGetContextSwitchRates(t *testing.T) {
+	rates := getContextSwitchRates(&v1.ContextSwitchStats{
+		Voluntary:           5000,
+		Involuntary:         800,
+		Interval:            10 * time.Second,
+		IntervalVoluntary:   250,
+		IntervalInvoluntary: 40,
+	})
+	expected := &info.ContextSwitchRates{
+		Voluntary:   25,
+		Involuntary: 4,
+	}
+	if rates == nil || *rates != *expected {
+		t.Errorf("Context switch rates are %+v. Expected %+v", rates, expected)
+	}
+	// At the first sampling.
+	if rates := getContextSwitchRates(&v1.ContextSwitchStats{Voluntary: 5000}); rates != nil {
+		t.Errorf("Context switch rates of the first sampling are %+v. Expected none", rates)
+	}
+	if rates := getContextSwitchRates(nil); rates != nil {
+		t.Errorf("Context switch rates without CPU sampling are %+v. Expected none", rates)
+	}
+}
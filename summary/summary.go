@@ -21,6 +21,7 @@
 package summary
 
 import (
+	"flag"
 	"fmt"
 	"sync"
 	"time"
@@ -29,16 +30,36 @@ import (
 	info "github.com/google/cadvisor/info/v2"
 )
 
+var resolutionHistory = flag.Duration("stats_resolution_history", 6*time.Hour, "How long the stats of each container are kept aggregated by minute, to serve requests for stats at a resolution of a minute or more")
+var networkIssueThreshold = flag.Float64("network_issue_rate_threshold", 0, "Rate of errors or drops per second of a network interface of a container above which its derived stats report network issues")
+
 // Usage fields we track for generating percentiles.
 type secondSample struct {
-	Timestamp time.Time // time when the sample was recorded.
-	Cpu       uint64    // cpu usage
-	Memory    uint64    // memory usage
+	Timestamp time.Time             // time when the sample was recorded.
+	Monotonic time.Duration         // monotonic time when the sample was recorded, zero if unknown.
+	Cpu       uint64                // cpu usage
+	Memory    uint64                // memory usage
+	Disks     []v1.MachineDiskStats // cumulative disk stats of the machine, only for the root container
+	Network   []v1.InterfaceStats   // cumulative stats of the network interfaces
+	Usage     uint64                // memory usage, including caches
+	Scanned   uint64                // cumulative pages scanned for reclaim
+
+	// context switches at the latest CPU sampling, nil if unknown
+	ContextSwitches *v1.ContextSwitchStats
+}
+
+// Time elapsed between two samples, ignoring any wall-clock jumps if the monotonic times are known.
+func (s *secondSample) elapsedSince(previous *secondSample) time.Duration {
+	if s.Monotonic != 0 && previous.Monotonic != 0 {
+		return s.Monotonic - previous.Monotonic
+	}
+	return s.Timestamp.Sub(previous.Timestamp)
 }
 
 type availableResources struct {
-	Cpu    bool
-	Memory bool
+	Cpu     bool
+	Memory  bool
+	Network bool
 }
 
 type StatsSummary struct {
@@ -51,20 +72,37 @@ type StatsSummary struct {
 	// latest derived instant, minute, hour, and day stats. Instant sample updated every second.
 	// Others updated every minute.
 	derivedStats info.DerivedStats // Guarded by dataLock.
-	dataLock     sync.RWMutex
+	// stats aggregated by minute, to serve coarse resolutions. Guarded by dataLock.
+	minutes  *bucketer
+	dataLock sync.RWMutex
 }
 
 // Adds a new seconds sample.
 // If enough seconds samples are collected, a minute sample is generated and derived
 // stats are updated.
 func (s *StatsSummary) AddSample(stat v1.ContainerStats) error {
+	s.dataLock.Lock()
+	s.minutes.add(&stat)
+	s.dataLock.Unlock()
+	if (s.available.Cpu && stat.Missing(v1.StatsSectionCpu)) || (s.available.Memory && stat.Missing(v1.StatsSectionMemory)) {
+		// Zeroed usage would skew the percentiles, wait for a complete sample.
+		return nil
+	}
 	sample := secondSample{}
 	sample.Timestamp = stat.Timestamp
+	sample.Monotonic = stat.MonotonicTimestamp
 	if s.available.Cpu {
 		sample.Cpu = stat.Cpu.Usage.Total
+		sample.ContextSwitches = stat.Cpu.ContextSwitches
 	}
 	if s.available.Memory {
 		sample.Memory = stat.Memory.WorkingSet
+		sample.Usage = stat.Memory.Usage
+		sample.Scanned = reclaimScanned(&stat.Memory)
+	}
+	sample.Disks = stat.MachineDisks
+	if s.available.Network && !stat.Missing(v1.StatsSectionNetwork) {
+		sample.Network = stat.Network.Interfaces
 	}
 	s.secondSamples = append(s.secondSamples, &sample)
 	s.updateLatestUsage()
@@ -72,9 +110,7 @@ func (s *StatsSummary) AddSample(stat v1.ContainerStats) error {
 	numSamples := len(s.secondSamples)
 	elapsed := time.Nanosecond
 	if numSamples > 1 {
-		start := s.secondSamples[0].Timestamp
-		end := s.secondSamples[numSamples-1].Timestamp
-		elapsed = end.Sub(start)
+		elapsed = s.secondSamples[numSamples-1].elapsedSince(s.secondSamples[0])
 	}
 	if elapsed > 60*time.Second {
 		// Make a minute sample. This works with dynamic housekeeping as long
@@ -101,17 +137,24 @@ func (s *StatsSummary) updateLatestUsage() {
 	}
 	latest := s.secondSamples[numStats-1]
 	usage.Memory = latest.Memory
+	usage.ContextSwitches = getContextSwitchRates(latest.ContextSwitches)
 	if numStats > 1 {
 		previous := s.secondSamples[numStats-2]
 		cpu, err := getCpuRate(*latest, *previous)
 		if err == nil {
 			usage.Cpu = cpu
 		}
+		usage.Disks = getDiskUtilization(*latest, *previous)
+		usage.Network = getInterfaceIssueRates(*latest, *previous)
+		if s.available.Memory {
+			usage.ThrashingScore, _ = thrashingScore(latest.Scanned, previous.Scanned, latest.Usage, latest.elapsedSince(previous))
+		}
 	}
 
 	s.dataLock.Lock()
 	defer s.dataLock.Unlock()
 	s.derivedStats.LatestUsage = usage
+	s.derivedStats.HasNetworkIssues = hasNetworkIssues(usage.Network, *networkIssueThreshold)
 	s.derivedStats.Timestamp = latest.Timestamp
 	return
 }
@@ -139,6 +182,7 @@ func (s *StatsSummary) updateDerivedStats() error {
 	s.dataLock.Lock()
 	defer s.dataLock.Unlock()
 	derived.LatestUsage = s.derivedStats.LatestUsage
+	derived.HasNetworkIssues = s.derivedStats.HasNetworkIssues
 	s.derivedStats = derived
 
 	return nil
@@ -177,9 +221,17 @@ func New(spec v1.ContainerSpec) (*StatsSummary, error) {
 	if spec.HasMemory {
 		summary.available.Memory = true
 	}
+	if spec.HasNetwork {
+		summary.available.Network = true
+	}
 	if !summary.available.Cpu && !summary.available.Memory {
 		return nil, fmt.Errorf("none of the resources are being tracked.")
 	}
 	summary.minuteSamples = NewSamplesBuffer(60 /* one hour */)
+	minutes := int(*resolutionHistory / time.Minute)
+	if minutes < 1 {
+		minutes = 1
+	}
+	summary.minutes = newBucketer(time.Minute, minutes)
 	return &summary, nil
 }
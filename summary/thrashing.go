@@ -0,0 +1,47 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"os"
+	"time"
+
+	"github.com/google/cadvisor/info/v1"
+)
+
+// Size of the pages counted by the reclaim counters of the memory stats.
+var pageSize = uint64(os.Getpagesize())
+
+// Returns the thrashing score of a container between two of its stat points:
+// the memory the kernel scanned for reclaim per second, relative to the memory
+// usage of the container. A container whose pages are scanned as fast as its
+// whole usage every second has a score of 1. Returns false if the score is
+// unknown (e.g.: the counters were reset).
+func ThrashingScore(latest, previous *v1.ContainerStats) (float64, bool) {
+	return thrashingScore(reclaimScanned(&latest.Memory), reclaimScanned(&previous.Memory), latest.Memory.Usage, latest.Elapsed(previous))
+}
+
+// Pages of the container and its descendants scanned for reclaim.
+func reclaimScanned(memory *v1.MemoryStats) uint64 {
+	return memory.HierarchicalData.PgscanDirect + memory.HierarchicalData.PgscanKswapd
+}
+
+func thrashingScore(scanned, previousScanned, usage uint64, elapsed time.Duration) (float64, bool) {
+	if elapsed < 10*time.Millisecond || usage == 0 || scanned < previousScanned {
+		return 0, false
+	}
+	scannedBytes := float64((scanned - previousScanned) * pageSize)
+	return scannedBytes / elapsed.Seconds() / float64(usage), true
+}
@@ -0,0 +1,70 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/info/v1"
+)
+
+func reclaimStats(timestamp time.Time, usage, scanDirect, scanKswapd uint64) *v1.ContainerStats {
+	stats := &v1.ContainerStats{Timestamp: timestamp}
+	stats.Memory.Usage = usage
+	stats.Memory.HierarchicalData.PgscanDirect = scanDirect
+	stats.Memory.HierarchicalData.PgscanKswapd = scanKswapd
+	return stats
+}
+
+func TestThrashingScore(t *testing.T) {
+	start := time.Unix(1000, 0)
+	usage := 1000 * pageSize
+	previous := reclaimStats(start, usage, 100, 200)
+	// 1000 pages scanned over 2 seconds, half the usage per second.
+	latest := reclaimStats(start.Add(2*time.Second), usage, 500, 800)
+	score, ok := ThrashingScore(latest, previous)
+	if !ok {
+		t.Fatal("Expected a thrashing score")
+	}
+	if score != 0.5 {
+		t.Errorf("Thrashing score is %v, expected 0.5", score)
+	}
+}
+
+func TestThrashingScoreWithoutReclaim(t *testing.T) {
+	start := time.Unix(1000, 0)
+	score, ok := ThrashingScore(reclaimStats(start.Add(time.Second), 4096, 0, 0), reclaimStats(start, 4096, 0, 0))
+	if !ok || score != 0 {
+		t.Errorf("Expected a thrashing score of 0, got %v (%v)", score, ok)
+	}
+}
+
+func TestThrashingScoreUnknown(t *testing.T) {
+	start := time.Unix(1000, 0)
+	previous := reclaimStats(start, 4096, 100, 100)
+	for _, latest := range []*v1.ContainerStats{
+		// Counters reset.
+		reclaimStats(start.Add(time.Second), 4096, 10, 10),
+		// No usage.
+		reclaimStats(start.Add(time.Second), 0, 200, 200),
+		// No time elapsed.
+		reclaimStats(start, 4096, 200, 200),
+	} {
+		if score, ok := ThrashingScore(latest, previous); ok {
+			t.Errorf("Expected no thrashing score for %+v, got %v", latest.Memory, score)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cgroups finds where the cgroup hierarchies are mounted.
+package cgroups
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// A mounted cgroup hierarchy.
+type Mount struct {
+	Mountpoint string
+
+	// Subsystems attached to the hierarchy, e.g.: ["cpu", "cpuacct"]. Named
+	// hierarchies are included with their name option, e.g.: "name=systemd".
+	Subsystems []string
+}
+
+// Options of cgroup mounts which are not subsystems.
+var mountOptions = map[string]bool{
+	"rw":             true,
+	"ro":             true,
+	"nosuid":         true,
+	"nodev":          true,
+	"noexec":         true,
+	"relatime":       true,
+	"noatime":        true,
+	"strictatime":    true,
+	"xattr":          true,
+	"noprefix":       true,
+	"clone_children": true,
+	"sane_behavior":  true,
+	"all":            true,
+	"none":           true,
+}
+
+var procMounts = "/proc/mounts"
+
+// Returns the cgroup hierarchies mounted on this machine, in mount order.
+func GetMounts() ([]Mount, error) {
+	file, err := os.Open(procMounts)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseMounts(file)
+}
+
+// Returns the cgroup hierarchies in the specified /proc/mounts contents, in mount order.
+func ParseMounts(mounts io.Reader) ([]Mount, error) {
+	var ret []Mount
+	scanner := bufio.NewScanner(mounts)
+	for scanner.Scan() {
+		// Format: <device> <mountpoint> <type> <options> <dump> <pass>
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("malformed mount entry %q", scanner.Text())
+		}
+		if fields[2] != "cgroup" {
+			continue
+		}
+		mountpoint, err := unescapeMountpoint(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		mount := Mount{
+			Mountpoint: mountpoint,
+		}
+		for _, opt := range strings.Split(fields[3], ",") {
+			if mountOptions[opt] || strings.HasPrefix(opt, "release_agent=") {
+				continue
+			}
+			mount.Subsystems = append(mount.Subsystems, opt)
+		}
+		ret = append(ret, mount)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Undoes the octal escaping of whitespace and backslashes in /proc/mounts, e.g.: "\040" is a space.
+func unescapeMountpoint(mountpoint string) (string, error) {
+	if !strings.Contains(mountpoint, "\\") {
+		return mountpoint, nil
+	}
+	var unescaped []byte
+	for i := 0; i < len(mountpoint); i++ {
+		if mountpoint[i] == '\\' && i+4 <= len(mountpoint) {
+			c, err := strconv.ParseUint(mountpoint[i+1:i+4], 8, 8)
+			if err != nil {
+				return "", fmt.Errorf("malformed escape sequence in mountpoint %q", mountpoint)
+			}
+			unescaped = append(unescaped, byte(c))
+			i += 3
+			continue
+		}
+		unescaped = append(unescaped, mountpoint[i])
+	}
+	return string(unescaped), nil
+}
+
+// Returns the mountpoint of each of the specified subsystems, and the mounts
+// providing them. A subsystem is mounted once per hierarchy it is attached to
+// no matter how many subsystems share that hierarchy. When a hierarchy is
+// mounted more than once (e.g.: bind mounts) the first mount is used.
+func GetMountpoints(mounts []Mount, subsystems map[string]struct{}) (map[string]string, []Mount) {
+	mountpoints := make(map[string]string, len(subsystems))
+	var used []Mount
+	for _, mount := range mounts {
+		usedMount := false
+		for _, subsystem := range mount.Subsystems {
+			if _, ok := subsystems[subsystem]; !ok {
+				continue
+			}
+			if _, ok := mountpoints[subsystem]; ok {
+				// Already mounted elsewhere.
+				continue
+			}
+			mountpoints[subsystem] = mount.Mountpoint
+			usedMount = true
+		}
+		if usedMount {
+			used = append(used, mount)
+		}
+	}
+	return mountpoints, used
+}
+
+// Returns the mountpoint of the specified subsystem.
+func FindMountpoint(subsystem string) (string, error) {
+	mounts, err := GetMounts()
+	if err != nil {
+		return "", err
+	}
+	mountpoints, _ := GetMountpoints(mounts, map[string]struct{}{subsystem: {}})
+	mountpoint, ok := mountpoints[subsystem]
+	if !ok {
+		return "", fmt.Errorf("cgroup subsystem %q is not mounted", subsystem)
+	}
+	return mountpoint, nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cgroups
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testSubsystems = map[string]struct{}{
+	"cpu":     {},
+	"cpuacct": {},
+	"memory":  {},
+	"cpuset":  {},
+	"blkio":   {},
+}
+
+func TestGetMountpoints(t *testing.T) {
+	cases := []struct {
+		mountsFile  string
+		mountpoints map[string]string
+		mounts      []Mount
+	}{
+		{
+			// Debian does not enable the memory cgroup by default.
+			mountsFile: "./testdata/mounts-debian",
+			mountpoints: map[string]string{
+				"cpuset":  "/sys/fs/cgroup/cpuset",
+				"cpu":     "/sys/fs/cgroup/cpu,cpuacct",
+				"cpuacct": "/sys/fs/cgroup/cpu,cpuacct",
+				"blkio":   "/sys/fs/cgroup/blkio",
+			},
+			mounts: []Mount{
+				{"/sys/fs/cgroup/cpuset", []string{"cpuset"}},
+				{"/sys/fs/cgroup/cpu,cpuacct", []string{"cpu", "cpuacct"}},
+				{"/sys/fs/cgroup/blkio", []string{"blkio"}},
+			},
+		},
+		{
+			mountsFile: "./testdata/mounts-centos",
+			mountpoints: map[string]string{
+				"cpuset":  "/sys/fs/cgroup/cpuset",
+				"cpu":     "/sys/fs/cgroup/cpu,cpuacct",
+				"cpuacct": "/sys/fs/cgroup/cpu,cpuacct",
+				"memory":  "/sys/fs/cgroup/memory",
+				"blkio":   "/sys/fs/cgroup/blkio",
+			},
+			mounts: []Mount{
+				{"/sys/fs/cgroup/cpuset", []string{"cpuset"}},
+				{"/sys/fs/cgroup/cpu,cpuacct", []string{"cpuacct", "cpu"}},
+				{"/sys/fs/cgroup/memory", []string{"memory"}},
+				{"/sys/fs/cgroup/blkio", []string{"blkio"}},
+			},
+		},
+		{
+			// cAdvisor running in a container with the host's root bind mounted at /rootfs.
+			mountsFile: "./testdata/mounts-coreos",
+			mountpoints: map[string]string{
+				"cpuset":  "/sys/fs/cgroup/cpuset",
+				"cpu":     "/sys/fs/cgroup/cpu,cpuacct",
+				"cpuacct": "/sys/fs/cgroup/cpu,cpuacct",
+				"memory":  "/sys/fs/cgroup/memory",
+				"blkio":   "/sys/fs/cgroup/blkio",
+			},
+			mounts: []Mount{
+				{"/sys/fs/cgroup/cpuset", []string{"cpuset"}},
+				{"/sys/fs/cgroup/cpu,cpuacct", []string{"cpu", "cpuacct"}},
+				{"/sys/fs/cgroup/memory", []string{"memory"}},
+				{"/sys/fs/cgroup/blkio", []string{"blkio"}},
+			},
+		},
+	}
+	for _, c := range cases {
+		file, err := os.Open(c.mountsFile)
+		if err != nil {
+			t.Fatalf("unable to open input test file %s: %v", c.mountsFile, err)
+		}
+		allMounts, err := ParseMounts(file)
+		file.Close()
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", c.mountsFile, err)
+		}
+		mountpoints, mounts := GetMountpoints(allMounts, testSubsystems)
+		if !reflect.DeepEqual(mountpoints, c.mountpoints) {
+			t.Errorf("expected mountpoints %v from %s, found %v", c.mountpoints, c.mountsFile, mountpoints)
+		}
+		if !reflect.DeepEqual(mounts, c.mounts) {
+			t.Errorf("expected mounts %v from %s, found %v", c.mounts, c.mountsFile, mounts)
+		}
+	}
+}
+
+func TestParseMountsNamedHierarchy(t *testing.T) {
+	mounts, err := ParseMounts(strings.NewReader("cgroup /sys/fs/cgroup/systemd cgroup rw,nosuid,nodev,noexec,relatime,xattr,release_agent=/lib/systemd/systemd-cgroups-agent,name=systemd 0 0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Mount{{"/sys/fs/cgroup/systemd", []string{"name=systemd"}}}
+	if !reflect.DeepEqual(mounts, expected) {
+		t.Errorf("expected %v, found %v", expected, mounts)
+	}
+}
+
+func TestParseMountsEscapedMountpoint(t *testing.T) {
+	mounts, err := ParseMounts(strings.NewReader("cgroup /cgroup\\040dir/cpu cgroup rw,relatime,cpu 0 0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Mount{{"/cgroup dir/cpu", []string{"cpu"}}}
+	if !reflect.DeepEqual(mounts, expected) {
+		t.Errorf("expected %v, found %v", expected, mounts)
+	}
+}
+
+func TestParseMountsMalformed(t *testing.T) {
+	if _, err := ParseMounts(strings.NewReader("cgroup /sys/fs/cgroup/cpu\n")); err == nil {
+		t.Errorf("expected an error for a malformed mount entry")
+	}
+}
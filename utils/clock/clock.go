@@ -0,0 +1,46 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Clocks used to timestamp samples. Alongside the wall-clock time, a
+// monotonic time is kept which is not affected by changes to the wall clock
+// and does not advance while the machine is suspended.
+package clock
+
+import (
+	"time"
+)
+
+type Clock interface {
+	// Current wall-clock time.
+	Now() time.Time
+
+	// Time elapsed since an arbitrary, fixed point in the past.
+	Monotonic() time.Duration
+}
+
+// Clock backed by the system's wall and monotonic clocks.
+var RealClock Clock = realClock{}
+
+// Used when the monotonic clock is not available.
+var startTime = time.Now()
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Monotonic() time.Duration {
+	return monotonic()
+}
@@ -0,0 +1,61 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakeclock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock whose wall and monotonic times only change when told to.
+type FakeClock struct {
+	lock      sync.Mutex
+	now       time.Time
+	monotonic time.Duration
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{
+		now:       now,
+		monotonic: time.Hour,
+	}
+}
+
+func (self *FakeClock) Now() time.Time {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.now
+}
+
+func (self *FakeClock) Monotonic() time.Duration {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.monotonic
+}
+
+// Advances both the wall and monotonic times by d.
+func (self *FakeClock) Step(d time.Duration) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.now = self.now.Add(d)
+	self.monotonic += d
+}
+
+// Moves only the wall time by d, as when the clock is set or the machine resumes from suspend.
+func (self *FakeClock) Jump(d time.Duration) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.now = self.now.Add(d)
+}
@@ -0,0 +1,218 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Measures the offset of the clock of the machine from sources of time, e.g.:
+// the storage backend or an NTP server. The stats of a machine whose clock
+// drifted land out of order in the backends it shares with other machines.
+package clockskew
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/utils/clock"
+)
+
+// A source of time the clock of the machine is compared with.
+type Source interface {
+	// Returns the current time of the source. Must be bounded by a timeout.
+	Time() (time.Time, error)
+}
+
+// A source and its last measured offset.
+type sourceState struct {
+	name   string
+	source Source
+	offset info.ClockOffset
+	// Whether offset.Offset was measured successfully at least once.
+	measured bool
+}
+
+// Measures the offset of the clock of the machine from sources of time, on
+// request or periodically. Class is thread-safe.
+type Monitor struct {
+	clock     clock.Clock
+	threshold time.Duration
+
+	lock    sync.Mutex
+	sources []*sourceState
+	// Closed by Stop().
+	stop    chan struct{}
+	stopped bool
+}
+
+var (
+	monitorsLock sync.Mutex
+	monitors     []*Monitor
+)
+
+// Returns a monitor warning about offsets above the threshold. The offsets it
+// measures are reported by Offsets().
+func New(threshold time.Duration) *Monitor {
+	self := newMonitor(clock.RealClock, threshold)
+	monitorsLock.Lock()
+	defer monitorsLock.Unlock()
+	monitors = append(monitors, self)
+	return self
+}
+
+func newMonitor(clock clock.Clock, threshold time.Duration) *Monitor {
+	return &Monitor{
+		clock:     clock,
+		threshold: threshold,
+		stop:      make(chan struct{}),
+	}
+}
+
+// Compares the clock with the source, named e.g. influxdb, from the next
+// measurement on.
+func (self *Monitor) AddSource(name string, source Source) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.sources = append(self.sources, &sourceState{
+		name:   name,
+		source: source,
+		offset: info.ClockOffset{Source: name},
+	})
+}
+
+// Measures the offset from each source. Warns about those above the threshold.
+func (self *Monitor) Measure() {
+	self.lock.Lock()
+	sources := append([]*sourceState(nil), self.sources...)
+	self.lock.Unlock()
+	for _, state := range sources {
+		// The source is assumed to have read its time halfway through the request.
+		before := self.clock.Now()
+		t, err := state.source.Time()
+		after := self.clock.Now()
+		offset := t.Sub(before.Add(after.Sub(before) / 2))
+
+		self.lock.Lock()
+		state.offset.Timestamp = after
+		if err != nil {
+			state.offset.Error = err.Error()
+			self.lock.Unlock()
+			glog.Warningf("Failed to compare the clock with %q: %v", state.name, err)
+			continue
+		}
+		state.offset.Error = ""
+		state.offset.Offset = offset
+		state.offset.Skewed = abs(offset) > self.threshold
+		state.measured = true
+		self.lock.Unlock()
+
+		if abs(offset) > self.threshold {
+			direction := "ahead of"
+			if offset > 0 {
+				direction = "behind"
+			}
+			glog.Warningf("The clock is %v %s %q, more than %v: the stats written to shared storage backends may be out of order", abs(offset), direction, state.name, self.threshold)
+		} else {
+			glog.V(2).Infof("The clock is %v off %q", offset, state.name)
+		}
+	}
+}
+
+// Measures the offsets now and then at every interval, in the background.
+// Does nothing without sources.
+func (self *Monitor) Start(interval time.Duration) {
+	self.lock.Lock()
+	numSources := len(self.sources)
+	self.lock.Unlock()
+	if numSources == 0 {
+		return
+	}
+	go func() {
+		self.Measure()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				self.Measure()
+			case <-self.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stops the background measurements, if started. The offsets of the monitor
+// are no longer reported by Offsets().
+func (self *Monitor) Stop() {
+	self.lock.Lock()
+	if !self.stopped {
+		self.stopped = true
+		close(self.stop)
+	}
+	self.lock.Unlock()
+
+	monitorsLock.Lock()
+	defer monitorsLock.Unlock()
+	for i, monitor := range monitors {
+		if monitor == self {
+			monitors = append(monitors[:i], monitors[i+1:]...)
+			return
+		}
+	}
+}
+
+// Returns the last measured offset from the first of the specified sources
+// (e.g.: influxdb, ntp) which was measured successfully.
+func (self *Monitor) Offset(sources ...string) (time.Duration, bool) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for _, name := range sources {
+		for _, state := range self.sources {
+			if state.name == name && state.measured {
+				return state.offset.Offset, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// Returns the last measurement from each source.
+func (self *Monitor) Offsets() []info.ClockOffset {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	ret := make([]info.ClockOffset, 0, len(self.sources))
+	for _, state := range self.sources {
+		ret = append(ret, state.offset)
+	}
+	return ret
+}
+
+// Returns the last measurements of the monitors created by New(). Nil if
+// there are no sources.
+func Offsets() []info.ClockOffset {
+	monitorsLock.Lock()
+	all := append([]*Monitor(nil), monitors...)
+	monitorsLock.Unlock()
+	var ret []info.ClockOffset
+	for _, monitor := range all {
+		ret = append(ret, monitor.Offsets()...)
+	}
+	return ret
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,119 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clockskew
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/utils/clock/fakeclock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A source whose clock is off the fake clock by an offset, answering after a
+// round trip taking the fake clock forward.
+type fakeSource struct {
+	clock     *fakeclock.FakeClock
+	offset    time.Duration
+	roundTrip time.Duration
+	err       error
+}
+
+func (self *fakeSource) Time() (time.Time, error) {
+	self.clock.Step(self.roundTrip / 2)
+	t := self.clock.Now().Add(self.offset)
+	self.clock.Step(self.roundTrip / 2)
+	return t, self.err
+}
+
+var testStart = time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func TestMeasureDetectsSkew(t *testing.T) {
+	clock := fakeclock.NewFakeClock(testStart)
+	monitor := newMonitor(clock, 2*time.Second)
+	backend := &fakeSource{clock: clock, offset: 5 * time.Second, roundTrip: 300 * time.Millisecond}
+	ntp := &fakeSource{clock: clock, offset: -time.Second, roundTrip: 40 * time.Millisecond}
+	monitor.AddSource("influxdb", backend)
+	monitor.AddSource("ntp", ntp)
+
+	_, ok := monitor.Offset("influxdb", "ntp")
+	assert.False(t, ok, "no offset is known before the first measurement")
+
+	monitor.Measure()
+	offsets := monitor.Offsets()
+	require.Equal(t, 2, len(offsets))
+	assert.Equal(t, "influxdb", offsets[0].Source)
+	assert.Equal(t, 5*time.Second, offsets[0].Offset)
+	assert.True(t, offsets[0].Skewed)
+	assert.Equal(t, testStart.Add(300*time.Millisecond), offsets[0].Timestamp)
+	assert.Equal(t, "ntp", offsets[1].Source)
+	assert.Equal(t, -time.Second, offsets[1].Offset)
+	assert.False(t, offsets[1].Skewed)
+
+	offset, ok := monitor.Offset("influxdb", "ntp")
+	assert.True(t, ok)
+	assert.Equal(t, 5*time.Second, offset)
+	offset, ok = monitor.Offset("bigquery", "ntp")
+	assert.True(t, ok, "the offset of the fallback source is used")
+	assert.Equal(t, -time.Second, offset)
+
+	// The clock was set right.
+	backend.offset = 100 * time.Millisecond
+	monitor.Measure()
+	offsets = monitor.Offsets()
+	assert.Equal(t, 100*time.Millisecond, offsets[0].Offset)
+	assert.False(t, offsets[0].Skewed)
+}
+
+func TestMeasureKeepsLastOffsetOnError(t *testing.T) {
+	clock := fakeclock.NewFakeClock(testStart)
+	monitor := newMonitor(clock, time.Second)
+	source := &fakeSource{clock: clock, offset: -3 * time.Second}
+	monitor.AddSource("ntp", source)
+
+	source.err = errors.New("timeout")
+	monitor.Measure()
+	_, ok := monitor.Offset("ntp")
+	assert.False(t, ok, "failed measurements are not offsets")
+	assert.Equal(t, "timeout", monitor.Offsets()[0].Error)
+
+	source.err = nil
+	monitor.Measure()
+	source.err = errors.New("timeout")
+	clock.Step(time.Minute)
+	monitor.Measure()
+	offsets := monitor.Offsets()
+	assert.Equal(t, "timeout", offsets[0].Error)
+	assert.Equal(t, -3*time.Second, offsets[0].Offset)
+	assert.True(t, offsets[0].Skewed)
+	assert.Equal(t, testStart.Add(time.Minute), offsets[0].Timestamp)
+	offset, ok := monitor.Offset("ntp")
+	assert.True(t, ok)
+	assert.Equal(t, -3*time.Second, offset)
+}
+
+func TestStoppedMonitorsAreNotReported(t *testing.T) {
+	monitor := New(time.Second)
+	monitor.AddSource("ntp", &fakeSource{clock: fakeclock.NewFakeClock(testStart)})
+	monitor.Measure()
+	assert.Len(t, Offsets(), 1)
+
+	monitor.Stop()
+	assert.Empty(t, Offsets())
+	// Stopping again is a no-op.
+	monitor.Stop()
+}
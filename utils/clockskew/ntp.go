@@ -0,0 +1,96 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clockskew
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	// Size of an NTP packet without extensions.
+	ntpPacketSize = 48
+
+	// Seconds from the NTP epoch (1900) to the Unix epoch.
+	ntpEpochOffset = 2208988800
+
+	ntpModeClient = 3
+	ntpModeServer = 4
+)
+
+// A minimal SNTP client (RFC 4330) reading the time of an NTP server.
+type ntpSource struct {
+	server  string
+	timeout time.Duration
+}
+
+// Returns the time of the NTP server, e.g.: pool.ntp.org or 10.0.0.1:123.
+// Each request is abandoned after the timeout.
+func NewNtpSource(server string, timeout time.Duration) Source {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+	return &ntpSource{
+		server:  server,
+		timeout: timeout,
+	}
+}
+
+func (self *ntpSource) Time() (time.Time, error) {
+	conn, err := net.DialTimeout("udp", self.server, self.timeout)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(self.timeout)); err != nil {
+		return time.Time{}, err
+	}
+
+	request := make([]byte, ntpPacketSize)
+	// No leap indicator, version 4.
+	request[0] = 4<<3 | ntpModeClient
+	if _, err := conn.Write(request); err != nil {
+		return time.Time{}, err
+	}
+	response := make([]byte, ntpPacketSize)
+	n, err := conn.Read(response)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return parseNtpResponse(response[:n])
+}
+
+// Returns the transmit timestamp of the response of an NTP server.
+func parseNtpResponse(response []byte) (time.Time, error) {
+	if len(response) < ntpPacketSize {
+		return time.Time{}, fmt.Errorf("NTP response of %d bytes is too short", len(response))
+	}
+	if mode := response[0] & 0x7; mode != ntpModeServer {
+		return time.Time{}, fmt.Errorf("NTP response has mode %d rather than %d", mode, ntpModeServer)
+	}
+	// Stratum 0 is a kiss-o'-death, with its code as reference ID.
+	if response[1] == 0 {
+		return time.Time{}, fmt.Errorf("NTP server refused the request: %q", response[12:16])
+	}
+	seconds := binary.BigEndian.Uint32(response[40:44])
+	fraction := binary.BigEndian.Uint32(response[44:48])
+	if seconds == 0 {
+		return time.Time{}, fmt.Errorf("NTP response has no transmit timestamp")
+	}
+	// TODO: Handle the rollover of the NTP era in 2036.
+	return time.Unix(int64(seconds)-ntpEpochOffset, int64(fraction)*1e9>>32).UTC(), nil
+}
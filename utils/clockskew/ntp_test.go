@@ -0,0 +1,104 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clockskew
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Starts an NTP server on localhost answering each request with the response
+// returned for it, if any. Returns its address.
+func startFakeNtpServer(t *testing.T, respond func(request []byte) []byte) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	go func() {
+		defer conn.Close()
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if response := respond(buf[:n]); response != nil {
+				conn.WriteTo(response, addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+// Returns the response of a server of the stratum transmitting at the time.
+func ntpResponse(stratum byte, t time.Time) []byte {
+	response := make([]byte, ntpPacketSize)
+	response[0] = 4<<3 | ntpModeServer
+	response[1] = stratum
+	binary.BigEndian.PutUint32(response[40:], uint32(t.Unix()+ntpEpochOffset))
+	binary.BigEndian.PutUint32(response[44:], uint32((int64(t.Nanosecond())<<32)/1e9))
+	return response
+}
+
+func TestNtpSource(t *testing.T) {
+	serverTime := time.Date(2015, 6, 1, 12, 0, 0, 250000000, time.UTC)
+	requests := make(chan []byte, 1)
+	server := startFakeNtpServer(t, func(request []byte) []byte {
+		requests <- append([]byte(nil), request...)
+		return ntpResponse(2, serverTime)
+	})
+
+	actual, err := NewNtpSource(server, time.Second).Time()
+	require.Nil(t, err)
+	assert.True(t, actual.Sub(serverTime) < time.Microsecond && serverTime.Sub(actual) < time.Microsecond, "expected %v, got %v", serverTime, actual)
+	request := <-requests
+	require.Equal(t, ntpPacketSize, len(request))
+	assert.Equal(t, byte(0x23), request[0], "expected a version 4 client request")
+}
+
+func TestNtpSourceTimesOut(t *testing.T) {
+	server := startFakeNtpServer(t, func([]byte) []byte {
+		return nil
+	})
+	start := time.Now()
+	_, err := NewNtpSource(server, 100*time.Millisecond).Time()
+	assert.NotNil(t, err)
+	assert.True(t, time.Since(start) < 5*time.Second, "the request took %v", time.Since(start))
+}
+
+func TestNtpSourceKissOfDeath(t *testing.T) {
+	server := startFakeNtpServer(t, func([]byte) []byte {
+		response := ntpResponse(0, time.Now())
+		copy(response[12:], "RATE")
+		return response
+	})
+	_, err := NewNtpSource(server, time.Second).Time()
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "RATE")
+}
+
+func TestParseNtpResponseInvalid(t *testing.T) {
+	_, err := parseNtpResponse(make([]byte, 12))
+	assert.NotNil(t, err, "short response")
+	clientMode := ntpResponse(2, time.Now())
+	clientMode[0] = 4<<3 | ntpModeClient
+	_, err = parseNtpResponse(clientMode)
+	assert.NotNil(t, err, "not a server response")
+	_, err = parseNtpResponse(ntpResponse(2, time.Unix(-ntpEpochOffset, 0)))
+	assert.NotNil(t, err, "no transmit timestamp")
+}
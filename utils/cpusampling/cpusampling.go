@@ -0,0 +1,253 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cpusampling attributes the CPU used by a container to its processes
+// by sampling their CPU times from /proc/<pid>/stat. The same samples count
+// the processes in uninterruptible sleep and the zombies, and their context
+// switches from /proc/<pid>/status.
+package cpusampling
+
+import (
+	"sort"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/utils/procfs"
+)
+
+// Number of process names reported.
+const topProcessNames = 10
+
+// Identifies a process across PID reuse.
+type processKey struct {
+	pid int
+
+	// Time the process started after boot, in jiffies.
+	startTime uint64
+}
+
+// CPU times of a process at a sample, in jiffies.
+type processSample struct {
+	name       string
+	userTime   uint64
+	systemTime uint64
+
+	// Context switches, nil if unknown.
+	switches *procfs.ProcessStatus
+}
+
+// Samples the CPU times of the processes of a container. Not thread-safe.
+type Sampler struct {
+	// Maximum number of processes sampled, those with the lowest PIDs are.
+	maxProcesses int
+
+	// Processes at the previous sample, nil before the first sample.
+	previous map[processKey]processSample
+
+	// Time of the previous sample.
+	previousTime time.Duration
+
+	// Whether processes were left out of the previous sample.
+	previousTruncated bool
+
+	// States of the processes at the previous sample, nil before the first sample.
+	states *info.ProcessStateStats
+
+	// Context switches at the previous sample, nil if unknown.
+	switches *info.ContextSwitchStats
+}
+
+// Returns a sampler of at most maxProcesses processes (zero is no limit).
+func New(maxProcesses int) *Sampler {
+	return &Sampler{
+		maxProcesses: maxProcesses,
+	}
+}
+
+// Samples the specified processes at the specified (monotonic) time. Returns
+// the CPU used by each process name since the previous sample, nil at the
+// first sample.
+//
+// Processes are matched across samples by PID and start time so that a
+// reused PID is not mistaken for the process that previously had it. A process
+// that is not in the previous sample started since, all its CPU time is
+// counted. The CPU used by processes which exited since the previous sample is
+// lost.
+func (self *Sampler) Sample(pids []int, now time.Duration) *info.ProcessCpuStats {
+	truncated := false
+	if self.maxProcesses > 0 && len(pids) > self.maxProcesses {
+		pids = append([]int(nil), pids...)
+		sort.Ints(pids)
+		pids = pids[:self.maxProcesses]
+		truncated = true
+	}
+
+	current := make(map[processKey]processSample, len(pids))
+	states := &info.ProcessStateStats{
+		Truncated: truncated,
+	}
+	// Start time of the oldest process in uninterruptible sleep.
+	var oldestUninterruptible uint64
+	for _, pid := range pids {
+		stat, err := procfs.ReadProcessStat(pid)
+		if err != nil {
+			// Exited since it was listed.
+			continue
+		}
+		switch stat.State {
+		case procfs.ProcessStateUninterruptible:
+			if states.NrUninterruptible == 0 || stat.StartTime < oldestUninterruptible {
+				states.OldestUninterruptible = stat.Comm
+				oldestUninterruptible = stat.StartTime
+			}
+			states.NrUninterruptible++
+		case procfs.ProcessStateZombie:
+			states.NrZombie++
+		}
+		sample := processSample{
+			name:       stat.Comm,
+			userTime:   stat.UserTime,
+			systemTime: stat.SystemTime,
+		}
+		// Not reported by old kernels.
+		if status, err := procfs.ReadProcessStatus(pid); err == nil {
+			sample.switches = status
+		}
+		current[processKey{pid, stat.StartTime}] = sample
+	}
+
+	var ret *info.ProcessCpuStats
+	if self.previous != nil {
+		ret = computeUsage(self.previous, current, self.previousTruncated)
+		ret.Interval = now - self.previousTime
+		ret.Truncated = truncated || self.previousTruncated
+	}
+	self.switches = countSwitches(self.previous, current, self.previousTruncated)
+	if self.switches != nil && self.previous != nil {
+		self.switches.Interval = now - self.previousTime
+	}
+	self.previous, self.previousTime, self.previousTruncated = current, now, truncated
+	self.states = states
+	return ret
+}
+
+// Returns the number of processes in uninterruptible sleep and of zombies at
+// the latest sample, nil before the first sample.
+func (self *Sampler) States() *info.ProcessStateStats {
+	return self.states
+}
+
+// Returns the context switches of the processes at the latest sample, and
+// since the previous sample. Nil before the first sample or if the context
+// switches are not known.
+func (self *Sampler) ContextSwitches() *info.ContextSwitchStats {
+	return self.switches
+}
+
+// Sums the context switches of the current processes and counts those since
+// the previous sample, nil if no process reported them. New processes are
+// counted like by computeUsage().
+func countSwitches(previous, current map[processKey]processSample, previousTruncated bool) *info.ContextSwitchStats {
+	var ret *info.ContextSwitchStats
+	for key, cur := range current {
+		if cur.switches == nil {
+			continue
+		}
+		if ret == nil {
+			ret = &info.ContextSwitchStats{}
+		}
+		ret.Voluntary += cur.switches.VoluntaryCtxtSwitches
+		ret.Involuntary += cur.switches.NonvoluntaryCtxtSwitches
+		if previous == nil {
+			continue
+		}
+		prev, ok := previous[key]
+		if !ok && previousTruncated {
+			continue
+		}
+		prevSwitches := &procfs.ProcessStatus{}
+		if ok {
+			if prev.switches == nil {
+				// Not known at the previous sample.
+				continue
+			}
+			prevSwitches = prev.switches
+		}
+		ret.IntervalVoluntary += saturatingSub(cur.switches.VoluntaryCtxtSwitches, prevSwitches.VoluntaryCtxtSwitches)
+		ret.IntervalInvoluntary += saturatingSub(cur.switches.NonvoluntaryCtxtSwitches, prevSwitches.NonvoluntaryCtxtSwitches)
+	}
+	return ret
+}
+
+// Computes the CPU used by each process name between the previous and
+// current samples. New processes are only counted if no process was left out
+// of the previous sample, they may otherwise have been running for long.
+func computeUsage(previous, current map[processKey]processSample, previousTruncated bool) *info.ProcessCpuStats {
+	byName := make(map[string]*info.ProcessCpuUsage)
+	for key, cur := range current {
+		prev, ok := previous[key]
+		if !ok {
+			if previousTruncated {
+				continue
+			}
+			prev = processSample{}
+		}
+		user := saturatingSub(cur.userTime, prev.userTime)
+		system := saturatingSub(cur.systemTime, prev.systemTime)
+		if user == 0 && system == 0 {
+			continue
+		}
+		usage, ok := byName[cur.name]
+		if !ok {
+			usage = &info.ProcessCpuUsage{Name: cur.name}
+			byName[cur.name] = usage
+		}
+		usage.Processes++
+		usage.User += uint64(procfs.JiffiesToDuration(user))
+		usage.System += uint64(procfs.JiffiesToDuration(system))
+		usage.Total = usage.User + usage.System
+	}
+
+	top := make([]info.ProcessCpuUsage, 0, len(byName))
+	for _, usage := range byName {
+		top = append(top, *usage)
+	}
+	sort.Sort(byTotal(top))
+	if len(top) > topProcessNames {
+		top = top[:topProcessNames]
+	}
+	return &info.ProcessCpuStats{
+		Top: top,
+	}
+}
+
+// Returns a - b, or 0 if b is larger (e.g.: the counter was reset).
+func saturatingSub(a, b uint64) uint64 {
+	if b > a {
+		return 0
+	}
+	return a - b
+}
+
+// Sorts by descending total usage, then by name.
+type byTotal []info.ProcessCpuUsage
+
+func (self byTotal) Len() int      { return len(self) }
+func (self byTotal) Swap(i, j int) { self[i], self[j] = self[j], self[i] }
+func (self byTotal) Less(i, j int) bool {
+	if self[i].Total != self[j].Total {
+		return self[i].Total > self[j].Total
+	}
+	return self[i].Name < self[j].Name
+}
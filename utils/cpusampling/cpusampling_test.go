@@ -0,0 +1,173 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cpusampling
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/utils/procfs"
+)
+
+// Takes a sample of the specified processes from the specified fixture.
+func sampleFixture(sampler *Sampler, fixture string, pids []int, now time.Duration) *info.ProcessCpuStats {
+	defer procfs.SetRoot("testdata/" + fixture)()
+	return sampler.Sample(pids, now)
+}
+
+func usage(name string, processes int, user, system uint64) info.ProcessCpuUsage {
+	u := uint64(procfs.JiffiesToDuration(user))
+	s := uint64(procfs.JiffiesToDuration(system))
+	return info.ProcessCpuUsage{
+		Name:      name,
+		Processes: processes,
+		User:      u,
+		System:    s,
+		Total:     u + s,
+	}
+}
+
+func TestSample(t *testing.T) {
+	sampler := New(0)
+	if stats := sampleFixture(sampler, "sample1", []int{10, 11, 12, 13}, 10*time.Second); stats != nil {
+		t.Errorf("expected no usage at the first sample, got %+v", stats)
+	}
+
+	// 12 exited, 13 was reused by a new process and 14 started.
+	stats := sampleFixture(sampler, "sample2", []int{10, 11, 12, 13, 14}, 20*time.Second)
+	expected := &info.ProcessCpuStats{
+		Interval: 10 * time.Second,
+		Top: []info.ProcessCpuUsage{
+			usage("busy-one", 1, 200, 10),
+			usage("busy-two", 2, 20, 0),
+			usage("sleep", 1, 3, 1),
+		},
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("expected %+v, got %+v", expected, stats)
+	}
+}
+
+func TestSampleTruncated(t *testing.T) {
+	sampler := New(2)
+	sampleFixture(sampler, "sample1", []int{13, 12, 11, 10}, 10*time.Second)
+
+	// Processes not in the truncated previous sample are not counted.
+	stats := sampleFixture(sampler, "sample2", []int{14, 13, 11, 10}, 20*time.Second)
+	expected := &info.ProcessCpuStats{
+		Interval: 10 * time.Second,
+		Top: []info.ProcessCpuUsage{
+			usage("busy-one", 1, 200, 10),
+			usage("busy-two", 1, 10, 0),
+		},
+		Truncated: true,
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("expected %+v, got %+v", expected, stats)
+	}
+}
+
+func TestComputeUsage(t *testing.T) {
+	previous := map[processKey]processSample{
+		{1, 100}: {"a", 10, 10, nil},
+		{2, 100}: {"b", 10, 10, nil},
+	}
+	current := map[processKey]processSample{
+		// The counters went backwards.
+		{1, 100}: {"a", 5, 100, nil},
+		// Idle.
+		{2, 100}: {"b", 10, 10, nil},
+	}
+	for i := 0; i < 2*topProcessNames; i++ {
+		current[processKey{100 + i, 200}] = processSample{string(rune('c' + i)), uint64(i + 1), 0, nil}
+	}
+	stats := computeUsage(previous, current, false)
+	if len(stats.Top) != topProcessNames {
+		t.Fatalf("expected %d process names, got %+v", topProcessNames, stats.Top)
+	}
+	if top := stats.Top[0]; !reflect.DeepEqual(top, usage("a", 1, 0, 90)) {
+		t.Errorf("expected the user time that went backwards to be ignored, got %+v", top)
+	}
+	if top := stats.Top[1]; top.Name != string(rune('c'+2*topProcessNames-1)) {
+		t.Errorf("expected the busiest processes first, got %+v", top)
+	}
+	for _, u := range stats.Top {
+		if u.Name == "b" {
+			t.Errorf("expected idle processes to be left out, got %+v", u)
+		}
+	}
+}
+
+func TestSampleStates(t *testing.T) {
+	sampler := New(0)
+	if states := sampler.States(); states != nil {
+		t.Errorf("expected no states before the first sample, got %+v", states)
+	}
+	sampleFixture(sampler, "states", []int{20, 21, 22, 23, 24, 25}, 10*time.Second)
+	expected := &info.ProcessStateStats{
+		NrUninterruptible:     2,
+		NrZombie:              1,
+		OldestUninterruptible: "dd",
+	}
+	if states := sampler.States(); !reflect.DeepEqual(states, expected) {
+		t.Errorf("expected %+v, got %+v", expected, states)
+	}
+
+	// Only the sampled processes are counted.
+	sampler = New(2)
+	sampleFixture(sampler, "states", []int{24, 23, 22, 21, 20}, 10*time.Second)
+	expected = &info.ProcessStateStats{
+		NrUninterruptible:     2,
+		OldestUninterruptible: "dd",
+		Truncated:             true,
+	}
+	if states := sampler.States(); !reflect.DeepEqual(states, expected) {
+		t.Errorf("expected %+v, got %+v", expected, states)
+	}
+}
+
+func TestSampleContextSwitches(t *testing.T) {
+	sampler := New(0)
+	sampleFixture(sampler, "sample1", []int{10, 11, 12, 13}, 10*time.Second)
+	expected := &info.ContextSwitchStats{
+		Voluntary:   177,
+		Involuntary: 18,
+	}
+	if switches := sampler.ContextSwitches(); !reflect.DeepEqual(switches, expected) {
+		t.Errorf("expected %+v at the first sample, got %+v", expected, switches)
+	}
+
+	// 12 exited, lowering the totals, 13 was reused by a new process and 14
+	// started: all their context switches are since the previous sample.
+	sampleFixture(sampler, "sample2", []int{10, 11, 12, 13, 14}, 20*time.Second)
+	expected = &info.ContextSwitchStats{
+		Voluntary:           221,
+		Involuntary:         47,
+		Interval:            10 * time.Second,
+		IntervalVoluntary:   50 + 10 + 3 + 8,
+		IntervalInvoluntary: 30 + 0 + 0 + 2,
+	}
+	if switches := sampler.ContextSwitches(); !reflect.DeepEqual(switches, expected) {
+		t.Errorf("expected %+v, got %+v", expected, switches)
+	}
+
+	// The processes of the fixture do not report their context switches.
+	sampleFixture(sampler, "states", []int{20, 21}, 30*time.Second)
+	if switches := sampler.ContextSwitches(); switches != nil {
+		t.Errorf("expected unknown context switches, got %+v", switches)
+	}
+}
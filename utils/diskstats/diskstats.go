@@ -0,0 +1,121 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Statistics of the block devices of the machine, from /proc/diskstats.
+package diskstats
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+var allDevices = flag.Bool("machine_disk_stats_all_devices", false, "Whether to include partitions and device-mapper devices in the disk stats of the machine, not only whole disks")
+
+var (
+	procDiskstats = "/proc/diskstats"
+	sysBlock      = "/sys/block"
+)
+
+// Returns the statistics of the block devices of the machine. Only whole disks
+// are included unless --machine_disk_stats_all_devices is set.
+func GetMachineDiskStats() ([]info.MachineDiskStats, error) {
+	file, err := os.Open(procDiskstats)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	stats, err := Parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %v", procDiskstats, err)
+	}
+	if *allDevices {
+		return stats, nil
+	}
+	return filterDevices(stats, isWholeDisk), nil
+}
+
+// Parses the contents of /proc/diskstats.
+func Parse(diskstats io.Reader) ([]info.MachineDiskStats, error) {
+	var ret []info.MachineDiskStats
+	scanner := bufio.NewScanner(diskstats)
+	for scanner.Scan() {
+		// Format: <major> <minor> <device> followed by 11 statistics. Newer
+		// kernels append discard and flush statistics, which are ignored.
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 14 {
+			return nil, fmt.Errorf("expected at least 14 fields, found %d in %q", len(fields), scanner.Text())
+		}
+		values := make([]uint64, 0, 13)
+		for _, field := range append(fields[:2:2], fields[3:14]...) {
+			value, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("malformed line %q: %v", scanner.Text(), err)
+			}
+			values = append(values, value)
+		}
+		ret = append(ret, info.MachineDiskStats{
+			Major:           values[0],
+			Minor:           values[1],
+			Device:          fields[2],
+			ReadsCompleted:  values[2],
+			ReadsMerged:     values[3],
+			SectorsRead:     values[4],
+			ReadTime:        values[5],
+			WritesCompleted: values[6],
+			WritesMerged:    values[7],
+			SectorsWritten:  values[8],
+			WriteTime:       values[9],
+			IoInProgress:    values[10],
+			IoTime:          values[11],
+			WeightedIoTime:  values[12],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Whether the device is a whole disk rather than a partition or a device-mapper
+// device. Only whole disks are listed in /sys/block.
+func isWholeDisk(device string) bool {
+	if strings.HasPrefix(device, "dm-") {
+		return false
+	}
+	// Slashes in device names (e.g.: cciss/c0d0) are replaced by "!" in sysfs.
+	_, err := os.Stat(path.Join(sysBlock, strings.Replace(device, "/", "!", -1)))
+	return err == nil
+}
+
+// Returns the stats of the devices to keep.
+func filterDevices(stats []info.MachineDiskStats, keep func(device string) bool) []info.MachineDiskStats {
+	ret := make([]info.MachineDiskStats, 0, len(stats))
+	for _, stat := range stats {
+		if keep(stat.Device) {
+			ret = append(ret, stat)
+		}
+	}
+	return ret
+}
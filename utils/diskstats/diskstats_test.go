@@ -0,0 +1,101 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskstats
+
+import (
+	"os"
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseFile(t *testing.T, file string) ([]info.MachineDiskStats, error) {
+	f, err := os.Open(file)
+	require.Nil(t, err)
+	defer f.Close()
+	return Parse(f)
+}
+
+func devices(stats []info.MachineDiskStats) []string {
+	ret := make([]string, 0, len(stats))
+	for _, stat := range stats {
+		ret = append(ret, stat.Device)
+	}
+	return ret
+}
+
+func TestParse(t *testing.T) {
+	stats, err := parseFile(t, "testdata/diskstats")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"ram0", "loop0", "sda", "sda1", "sda2", "sdb", "dm-0", "nvme0n1", "nvme0n1p1"}, devices(stats))
+	assert.Equal(t, info.MachineDiskStats{
+		Major:           8,
+		Minor:           0,
+		Device:          "sda",
+		ReadsCompleted:  144290,
+		ReadsMerged:     3587,
+		SectorsRead:     7616846,
+		ReadTime:        145232,
+		WritesCompleted: 2237193,
+		WritesMerged:    2079416,
+		SectorsWritten:  84938122,
+		WriteTime:       5107748,
+		IoInProgress:    0,
+		IoTime:          1268432,
+		WeightedIoTime:  5252072,
+	}, stats[2])
+
+	// The discard and flush statistics of newer kernels are ignored.
+	assert.Equal(t, uint64(2), stats[5].IoInProgress)
+	assert.Equal(t, uint64(13000), stats[5].WeightedIoTime)
+	assert.Equal(t, uint64(259), stats[7].Major)
+	assert.Equal(t, uint64(100000), stats[7].WeightedIoTime)
+}
+
+func TestParseTooFewFields(t *testing.T) {
+	_, err := parseFile(t, "testdata/diskstats-short")
+	assert.NotNil(t, err)
+}
+
+func TestOnlyWholeDisksAreKept(t *testing.T) {
+	oldSysBlock := sysBlock
+	sysBlock = "testdata/sys-block"
+	defer func() {
+		sysBlock = oldSysBlock
+	}()
+
+	stats, err := parseFile(t, "testdata/diskstats")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"sda", "sdb", "nvme0n1"}, devices(filterDevices(stats, isWholeDisk)))
+}
+
+func TestGetMachineDiskStats(t *testing.T) {
+	oldProcDiskstats, oldSysBlock, oldAllDevices := procDiskstats, sysBlock, *allDevices
+	procDiskstats, sysBlock = "testdata/diskstats", "testdata/sys-block"
+	defer func() {
+		procDiskstats, sysBlock, *allDevices = oldProcDiskstats, oldSysBlock, oldAllDevices
+	}()
+
+	stats, err := GetMachineDiskStats()
+	require.Nil(t, err)
+	assert.Equal(t, []string{"sda", "sdb", "nvme0n1"}, devices(stats))
+
+	*allDevices = true
+	stats, err = GetMachineDiskStats()
+	require.Nil(t, err)
+	assert.Equal(t, 9, len(stats))
+}
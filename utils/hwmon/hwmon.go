@@ -0,0 +1,230 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Temperature and power of the machine, from the thermal zones and the RAPL
+// (Running Average Power Limit) domains in /sys/class.
+package hwmon
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+var enabled = flag.Bool("enable_hardware_monitoring", false, "Whether to report the temperature of the machine's thermal zones and the power drawn by its RAPL domains (e.g.: the CPU packages) in the stats of the root container")
+
+var (
+	thermalDir  = "/sys/class/thermal"
+	powercapDir = "/sys/class/powercap"
+)
+
+// Reads a file holding a single unsigned integer.
+func readUint(file string) (uint64, error) {
+	out, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(out)), 10, 64)
+}
+
+// Reads a file holding a single line of text, empty if it can't be read.
+func readName(file string) string {
+	out, err := ioutil.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Returns the temperature of the thermal zones under the specified directory.
+// Zones whose temperature can't be read (e.g.: the sensor is off) are skipped.
+func readThermalZones(dir string) ([]info.ThermalZoneStats, error) {
+	zones, err := filepath.Glob(path.Join(dir, "thermal_zone*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(zones)
+	var ret []info.ThermalZoneStats
+	for _, zone := range zones {
+		// Reported in millidegrees Celsius, may be negative.
+		out, err := ioutil.ReadFile(path.Join(zone, "temp"))
+		if err != nil {
+			continue
+		}
+		millidegrees, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the temperature of %q: %v", zone, err)
+		}
+		ret = append(ret, info.ThermalZoneStats{
+			Zone:        path.Base(zone),
+			Type:        readName(path.Join(zone, "type")),
+			Temperature: float64(millidegrees) / 1000,
+		})
+	}
+	return ret, nil
+}
+
+// The energy counter of a RAPL domain.
+type energyReading struct {
+	// E.g.: intel-rapl:0.
+	domain string
+	// E.g.: package-0.
+	name string
+	// Cumulative energy in microjoules.
+	energy uint64
+	// The value after which the counter wraps around to zero, zero if unknown.
+	maxRange uint64
+}
+
+// Returns the energy counters of the RAPL domains under the specified
+// directory. Entries without a counter (e.g.: the intel-rapl control type)
+// are skipped.
+func readEnergy(dir string) ([]energyReading, error) {
+	domains, err := filepath.Glob(path.Join(dir, "intel-rapl*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(domains)
+	var ret []energyReading
+	for _, domain := range domains {
+		energy, err := readUint(path.Join(domain, "energy_uj"))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read the energy of %q: %v", domain, err)
+		}
+		maxRange, err := readUint(path.Join(domain, "max_energy_range_uj"))
+		if err != nil {
+			maxRange = 0
+		}
+		ret = append(ret, energyReading{
+			domain:   path.Base(domain),
+			name:     readName(path.Join(domain, "name")),
+			energy:   energy,
+			maxRange: maxRange,
+		})
+	}
+	return ret, nil
+}
+
+// Returns the energy used between two readings of a counter, which wraps
+// around to zero past maxRange. False if the counter went back without a
+// known range.
+func energyDelta(previous, current, maxRange uint64) (uint64, bool) {
+	if current >= previous {
+		return current - previous, true
+	}
+	if maxRange == 0 || previous > maxRange {
+		return 0, false
+	}
+	return maxRange - previous + current, true
+}
+
+type energySample struct {
+	energy uint64
+	time   time.Time
+}
+
+// Collects the temperature and power of the machine. The power of a domain is
+// derived from the energy it used since the previous collection, so it is
+// only known from the second collection on. Class is thread-safe.
+type Collector struct {
+	thermalDir  string
+	powercapDir string
+
+	lock sync.Mutex
+	// The previous energy reading of each domain.
+	previous map[string]energySample
+}
+
+// Returns a collector of the hardware sensors of the machine, or nil if
+// hardware monitoring is disabled or the machine exposes no sensors (e.g.: it
+// is a VM).
+func NewCollector() *Collector {
+	if !*enabled {
+		return nil
+	}
+	return newCollector(thermalDir, powercapDir)
+}
+
+func newCollector(thermalDir, powercapDir string) *Collector {
+	zones, _ := readThermalZones(thermalDir)
+	domains, _ := readEnergy(powercapDir)
+	if len(zones) == 0 && len(domains) == 0 {
+		glog.V(1).Infof("No thermal zones in %q nor RAPL domains in %q, not monitoring the hardware", thermalDir, powercapDir)
+		return nil
+	}
+	return &Collector{
+		thermalDir:  thermalDir,
+		powercapDir: powercapDir,
+		previous:    make(map[string]energySample),
+	}
+}
+
+// Returns the temperature and power of the machine.
+func (self *Collector) GetStats() (*info.MachineStats, error) {
+	zones, err := readThermalZones(self.thermalDir)
+	if err != nil {
+		return nil, err
+	}
+	readings, err := readEnergy(self.powercapDir)
+	if err != nil {
+		return nil, err
+	}
+	return &info.MachineStats{
+		Thermal: zones,
+		Power:   self.updatePower(readings, time.Now()),
+	}, nil
+}
+
+// Records the energy readings and returns the power of the domains drawn
+// since their previous readings.
+func (self *Collector) updatePower(readings []energyReading, now time.Time) []info.PowerDomainStats {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	var ret []info.PowerDomainStats
+	for _, r := range readings {
+		previous, ok := self.previous[r.domain]
+		self.previous[r.domain] = energySample{energy: r.energy, time: now}
+		if !ok {
+			continue
+		}
+		elapsed := now.Sub(previous.time)
+		if elapsed <= 0 {
+			continue
+		}
+		delta, ok := energyDelta(previous.energy, r.energy, r.maxRange)
+		if !ok {
+			continue
+		}
+		ret = append(ret, info.PowerDomainStats{
+			Domain: r.domain,
+			Name:   r.name,
+			// Microjoules per microsecond are watts.
+			Power: float64(delta) / (float64(elapsed) / float64(time.Microsecond)),
+		})
+	}
+	return ret
+}
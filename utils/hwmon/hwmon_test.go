@@ -0,0 +1,106 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hwmon
+
+import (
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadThermalZones(t *testing.T) {
+	zones, err := readThermalZones("testdata/thermal")
+	require.Nil(t, err)
+	// Zones without a temperature are skipped.
+	assert.Equal(t, []info.ThermalZoneStats{
+		{Zone: "thermal_zone0", Type: "x86_pkg_temp", Temperature: 45},
+		{Zone: "thermal_zone1", Type: "acpitz", Temperature: -5.5},
+	}, zones)
+}
+
+func TestReadEnergy(t *testing.T) {
+	readings, err := readEnergy("testdata/powercap")
+	require.Nil(t, err)
+	assert.Equal(t, []energyReading{
+		{domain: "intel-rapl:0", name: "package-0", energy: 1000000, maxRange: 262143328850},
+		{domain: "intel-rapl:0:0", name: "core", energy: 250000, maxRange: 262143328850},
+	}, readings)
+}
+
+func TestCollectorDisabledWithoutSensors(t *testing.T) {
+	assert.Nil(t, newCollector("testdata/missing", "testdata/missing"))
+	assert.NotNil(t, newCollector("testdata/missing", "testdata/powercap"))
+	assert.NotNil(t, newCollector("testdata/thermal", "testdata/missing"))
+}
+
+func TestCollectorGetStats(t *testing.T) {
+	collector := newCollector("testdata/thermal", "testdata/powercap")
+	require.NotNil(t, collector)
+	stats, err := collector.GetStats()
+	require.Nil(t, err)
+	assert.Equal(t, 2, len(stats.Thermal))
+	// The power is only known from the second reading on.
+	assert.Empty(t, stats.Power)
+	stats, err = collector.GetStats()
+	require.Nil(t, err)
+	require.Equal(t, 2, len(stats.Power))
+	assert.Equal(t, "package-0", stats.Power[0].Name)
+}
+
+func TestEnergyDelta(t *testing.T) {
+	cases := []struct {
+		previous, current, maxRange uint64
+		delta                       uint64
+		ok                          bool
+	}{
+		{100, 300, 1000, 200, true},
+		{100, 100, 1000, 0, true},
+		// Wrapped around past the maximum.
+		{900, 50, 1000, 150, true},
+		// Went back without a known range.
+		{900, 50, 0, 0, false},
+		{2000, 50, 1000, 0, false},
+	}
+	for _, c := range cases {
+		delta, ok := energyDelta(c.previous, c.current, c.maxRange)
+		assert.Equal(t, c.ok, ok, "%+v", c)
+		assert.Equal(t, c.delta, delta, "%+v", c)
+	}
+}
+
+func TestUpdatePower(t *testing.T) {
+	collector := &Collector{previous: make(map[string]energySample)}
+	start := time.Unix(1000, 0)
+	reading := func(energy uint64) []energyReading {
+		return []energyReading{{domain: "intel-rapl:0", name: "package-0", energy: energy, maxRange: 100000000}}
+	}
+
+	assert.Empty(t, collector.updatePower(reading(90000000), start))
+
+	// 20 joules over 2 seconds.
+	power := collector.updatePower(reading(110000000%100000000), start.Add(2*time.Second))
+	require.Equal(t, 1, len(power))
+	assert.Equal(t, info.PowerDomainStats{Domain: "intel-rapl:0", Name: "package-0", Power: 10}, power[0])
+
+	// No time elapsed.
+	assert.Empty(t, collector.updatePower(reading(20000000), start.Add(2*time.Second)))
+
+	power = collector.updatePower(reading(25000000), start.Add(3*time.Second))
+	require.Equal(t, 1, len(power))
+	assert.Equal(t, float64(5), power[0].Power)
+}
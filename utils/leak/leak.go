@@ -0,0 +1,191 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Tracking of objects which are expected to be garbage collected, to find leaks.
+package leak
+
+import (
+	"container/list"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"runtime"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	httpMux "github.com/google/cadvisor/http/mux"
+)
+
+var sampleRate = flag.Int("leak_tracking_sample_rate", 0, "Track 1 in N objects for leaks, reported at /tracked with --enable_debug_endpoints. Zero disables leak tracking")
+var maxEntries = flag.Int("leak_tracking_max_entries", 10000, "Largest number of objects to track for leaks. The oldest tracked object is forgotten when it is exceeded")
+
+const TrackedPage = "/tracked"
+
+// A tracked object.
+type entry struct {
+	id   uint64
+	kind string
+}
+
+// Tracks a sample of objects until they are garbage collected. Counts are
+// estimated from the sample.
+type Tracker struct {
+	lock       sync.Mutex
+	sampleRate int
+	maxEntries int
+	random     *rand.Rand
+	nextId     uint64
+
+	// Tracked objects by ID and in the order they were tracked, oldest first.
+	entries map[uint64]*list.Element
+	order   *list.List
+
+	// Number of tracked objects forgotten to stay within maxEntries.
+	evictions uint64
+}
+
+// Returns a tracker of 1 in sampleRate objects (none if zero) which tracks at
+// most maxEntries objects at a time. Sampling is seeded with seed.
+func NewTracker(sampleRate, maxEntries int, seed int64) *Tracker {
+	return &Tracker{
+		sampleRate: sampleRate,
+		maxEntries: maxEntries,
+		random:     rand.New(rand.NewSource(seed)),
+		entries:    make(map[uint64]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Tracks the object, which must be a pointer without a finalizer, if it is
+// sampled. Returns whether it was.
+func (self *Tracker) Track(obj interface{}, kind string) bool {
+	self.lock.Lock()
+	if self.sampleRate <= 0 || self.maxEntries <= 0 || self.random.Intn(self.sampleRate) != 0 {
+		self.lock.Unlock()
+		return false
+	}
+	id := self.nextId
+	self.nextId++
+	if self.order.Len() >= self.maxEntries {
+		oldest := self.order.Front()
+		self.order.Remove(oldest)
+		delete(self.entries, oldest.Value.(*entry).id)
+		self.evictions++
+	}
+	self.entries[id] = self.order.PushBack(&entry{
+		id:   id,
+		kind: kind,
+	})
+	self.lock.Unlock()
+
+	runtime.SetFinalizer(obj, func(interface{}) {
+		self.objectDeleted(id)
+	})
+	return true
+}
+
+func (self *Tracker) objectDeleted(id uint64) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	elem, ok := self.entries[id]
+	if !ok {
+		// Evicted while it was alive.
+		return
+	}
+	self.order.Remove(elem)
+	delete(self.entries, id)
+}
+
+// Estimate of the live tracked objects.
+type Summary struct {
+	// Objects are tracked 1 in SampleRate times.
+	SampleRate int
+
+	// Number of objects currently tracked.
+	Tracked int
+
+	// Number of tracked objects forgotten to stay within the maximum number of
+	// tracked objects. Counts are underestimated when non-zero.
+	Evictions uint64
+
+	// Estimated number of live objects by kind.
+	Counts map[string]uint64
+}
+
+func (self *Tracker) Summary() Summary {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	summary := Summary{
+		SampleRate: self.sampleRate,
+		Tracked:    self.order.Len(),
+		Evictions:  self.evictions,
+		Counts:     make(map[string]uint64),
+	}
+	for elem := self.order.Front(); elem != nil; elem = elem.Next() {
+		summary.Counts[elem.Value.(*entry).kind] += uint64(self.sampleRate)
+	}
+	return summary
+}
+
+// Serves the summary as text.
+func (self *Tracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	summary := self.Summary()
+	if summary.SampleRate <= 0 {
+		fmt.Fprintf(w, "Leak tracking is disabled\n")
+		return
+	}
+	fmt.Fprintf(w, "Sampling rate: 1 in %d\n", summary.SampleRate)
+	fmt.Fprintf(w, "Tracked objects: %d\n", summary.Tracked)
+	fmt.Fprintf(w, "Evictions: %d\n\n", summary.Evictions)
+
+	kinds := make([]string, 0, len(summary.Counts))
+	for kind := range summary.Counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "KIND\tESTIMATED LIVE\n")
+	for _, kind := range kinds {
+		fmt.Fprintf(tw, "%s\t%d\n", kind, summary.Counts[kind])
+	}
+	tw.Flush()
+}
+
+var (
+	defaultTracker     *Tracker
+	defaultTrackerOnce sync.Once
+)
+
+// Returns the tracker configured by the flags.
+func getDefaultTracker() *Tracker {
+	defaultTrackerOnce.Do(func() {
+		defaultTracker = NewTracker(*sampleRate, *maxEntries, time.Now().UnixNano())
+	})
+	return defaultTracker
+}
+
+// Tracks the object, which must be a pointer without a finalizer, until it is
+// garbage collected if it is sampled. The kind groups objects in the output.
+func Track(obj interface{}, kind string) {
+	getDefaultTracker().Track(obj, kind)
+}
+
+// Register the /tracked handler which reports the estimated live tracked objects.
+func RegisterHandler(mux httpMux.Mux) error {
+	mux.Handle(TrackedPage, httpMux.SafeReads(getDefaultTracker()))
+	return nil
+}
@@ -0,0 +1,124 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package leak
+
+import (
+	"math"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type object struct {
+	value int
+
+	// Finalizers may not be run for objects small enough to share an allocation.
+	padding [32]byte
+}
+
+// Tracks n new objects of the specified kind, returning them to keep them alive.
+func trackObjects(tracker *Tracker, n int, kind string) []*object {
+	objects := make([]*object, n)
+	for i := range objects {
+		objects[i] = &object{value: i}
+		tracker.Track(objects[i], kind)
+	}
+	return objects
+}
+
+// Uses the objects so they are not collected before this point.
+func keepAlive(objects []*object) {
+	for _, obj := range objects {
+		obj.value++
+	}
+}
+
+func TestSampledCountsApproximateTrueCounts(t *testing.T) {
+	const n = 100000
+	for _, rate := range []int{1, 10, 100} {
+		tracker := NewTracker(rate, 2*n, 1)
+		a := trackObjects(tracker, n, "a")
+		b := trackObjects(tracker, n/4, "b")
+
+		summary := tracker.Summary()
+		assert.Equal(t, rate, summary.SampleRate)
+		assert.Equal(t, uint64(0), summary.Evictions)
+		// The sampled count is binomial, allow for 5 standard deviations.
+		for kind, count := range map[string]int{"a": len(a), "b": len(b)} {
+			tolerance := 5 * float64(rate) * math.Sqrt(float64(count)/float64(rate))
+			assert.InDelta(t, count, summary.Counts[kind], tolerance, "rate %d, kind %q", rate, kind)
+		}
+		keepAlive(a)
+		keepAlive(b)
+	}
+}
+
+func TestOldestEntriesAreEvicted(t *testing.T) {
+	tracker := NewTracker(1, 10, 1)
+	old := trackObjects(tracker, 5, "old")
+	recent := trackObjects(tracker, 10, "recent")
+
+	summary := tracker.Summary()
+	assert.Equal(t, 10, summary.Tracked)
+	assert.Equal(t, uint64(5), summary.Evictions)
+	assert.Equal(t, map[string]uint64{"recent": 10}, summary.Counts)
+
+	// Evicted objects being collected are ignored.
+	for id := uint64(0); id < 5; id++ {
+		tracker.objectDeleted(id)
+	}
+	assert.Equal(t, 10, tracker.Summary().Tracked)
+	keepAlive(old)
+	keepAlive(recent)
+}
+
+func TestCollectedObjectsAreForgotten(t *testing.T) {
+	tracker := NewTracker(1, 100, 1)
+	kept := trackObjects(tracker, 10, "kept")
+	trackObjects(tracker, 10, "collected")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for tracker.Summary().Counts["collected"] != 0 {
+		require.True(t, time.Now().Before(deadline), "Timed out waiting for objects to be collected")
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, uint64(10), tracker.Summary().Counts["kept"])
+	keepAlive(kept)
+}
+
+func TestDisabledTrackerTracksNothing(t *testing.T) {
+	tracker := NewTracker(0, 100, 1)
+	objects := trackObjects(tracker, 10, "a")
+	assert.Equal(t, 0, tracker.Summary().Tracked)
+	keepAlive(objects)
+}
+
+func TestServeHTTP(t *testing.T) {
+	tracker := NewTracker(2, 3, 1)
+	objects := trackObjects(tracker, 100, "a")
+
+	w := httptest.NewRecorder()
+	tracker.ServeHTTP(w, nil)
+	assert.Contains(t, w.Body.String(), "Sampling rate: 1 in 2\n")
+	assert.Contains(t, w.Body.String(), "Tracked objects: 3\n")
+	assert.Contains(t, w.Body.String(), "Evictions: ")
+	assert.Contains(t, w.Body.String(), "a     6\n")
+	keepAlive(objects)
+}
@@ -0,0 +1,210 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Statistics of the network interfaces of the machine, from /proc/net/dev.
+package netdev
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+var rootDevices = flag.String("root_network_devices", "", "Comma-separated list of the network interfaces whose traffic is reported as the root container's, e.g.: eth*,wlan0. By default all interfaces but lo, docker0 and veth*")
+
+var procNetDev = "/proc/net/dev"
+
+// Interfaces whose traffic is not the machine's by default: the loopback and
+// the bridge and virtual interfaces of containers, whose traffic also flows
+// through the physical interfaces.
+var defaultExcludedDevices = []string{"lo", "docker0", "veth*"}
+
+// Statistics of a network interface.
+type InterfaceStats struct {
+	Name string
+	info.NetworkStats
+}
+
+// Parses the contents of /proc/net/dev.
+func Parse(netDev io.Reader) ([]InterfaceStats, error) {
+	var ret []InterfaceStats
+	scanner := bufio.NewScanner(netDev)
+	for n := 0; scanner.Scan(); n++ {
+		// The first two lines are headers.
+		if n < 2 {
+			continue
+		}
+		// Format: <interface>: followed by 8 receive and 8 transmit statistics.
+		// There is no space after the colon on older kernels.
+		line := scanner.Text()
+		i := strings.Index(line, ":")
+		if i < 0 {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			return nil, fmt.Errorf("malformed line %q", line)
+		}
+		fields := strings.Fields(line[i+1:])
+		if len(fields) < 16 {
+			return nil, fmt.Errorf("expected 16 statistics, found %d in %q", len(fields), line)
+		}
+		values := make([]uint64, 16)
+		for j := range values {
+			value, err := strconv.ParseUint(fields[j], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("malformed line %q: %v", line, err)
+			}
+			values[j] = value
+		}
+		ret = append(ret, InterfaceStats{
+			Name: strings.TrimSpace(line[:i]),
+			NetworkStats: info.NetworkStats{
+				RxBytes:   values[0],
+				RxPackets: values[1],
+				RxErrors:  values[2],
+				RxDropped: values[3],
+				TxBytes:   values[8],
+				TxPackets: values[9],
+				TxErrors:  values[10],
+				TxDropped: values[11],
+			},
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Returns a filter keeping the interfaces matching one of the comma-separated
+// patterns, or all interfaces but the excluded ones by default.
+func newDeviceFilter(patterns string) func(name string) bool {
+	matchesAny := func(name string, patterns []string) bool {
+		for _, pattern := range patterns {
+			if matched, err := path.Match(strings.TrimSpace(pattern), name); err == nil && matched {
+				return true
+			}
+		}
+		return false
+	}
+	if patterns == "" {
+		return func(name string) bool {
+			return !matchesAny(name, defaultExcludedDevices)
+		}
+	}
+	included := strings.Split(patterns, ",")
+	return func(name string) bool {
+		return matchesAny(name, included)
+	}
+}
+
+// The counters of an interface. Counters restart from zero when an interface
+// is recreated, their values until then are kept in the base so that the
+// totals never go back.
+type interfaceCounters struct {
+	base info.NetworkStats
+	last info.NetworkStats
+}
+
+func (self *interfaceCounters) update(stats info.NetworkStats) {
+	if stats.RxBytes < self.last.RxBytes || stats.TxBytes < self.last.TxBytes {
+		self.base = addStats(self.base, self.last)
+	}
+	self.last = stats
+}
+
+func (self *interfaceCounters) total() info.NetworkStats {
+	return addStats(self.base, self.last)
+}
+
+func addStats(a, b info.NetworkStats) info.NetworkStats {
+	return info.NetworkStats{
+		RxBytes:   a.RxBytes + b.RxBytes,
+		RxPackets: a.RxPackets + b.RxPackets,
+		RxErrors:  a.RxErrors + b.RxErrors,
+		RxDropped: a.RxDropped + b.RxDropped,
+		TxBytes:   a.TxBytes + b.TxBytes,
+		TxPackets: a.TxPackets + b.TxPackets,
+		TxErrors:  a.TxErrors + b.TxErrors,
+		TxDropped: a.TxDropped + b.TxDropped,
+	}
+}
+
+// Collects the cumulative network statistics of the machine, summed over its
+// interfaces. The traffic of interfaces which disappear (e.g.: a VPN going
+// down) stays counted, so the totals never decrease. Class is thread-safe.
+type Collector struct {
+	lock sync.Mutex
+	// Whether the traffic of an interface is counted.
+	keep func(name string) bool
+	// The counters of every interface seen, by name.
+	interfaces map[string]*interfaceCounters
+}
+
+// Returns a collector of the interfaces selected with --root_network_devices.
+func NewCollector() *Collector {
+	return &Collector{
+		keep:       newDeviceFilter(*rootDevices),
+		interfaces: make(map[string]*interfaceCounters),
+	}
+}
+
+// Returns the network statistics of the machine.
+func (self *Collector) GetStats() (info.NetworkStats, error) {
+	file, err := os.Open(procNetDev)
+	if err != nil {
+		return info.NetworkStats{}, err
+	}
+	defer file.Close()
+	stats, err := Parse(file)
+	if err != nil {
+		return info.NetworkStats{}, fmt.Errorf("failed to parse %q: %v", procNetDev, err)
+	}
+	return self.update(stats), nil
+}
+
+// Updates the counters of the interfaces and returns their sum, along with
+// the counters of the interfaces currently present as they were read.
+func (self *Collector) update(stats []InterfaceStats) info.NetworkStats {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	var present []info.InterfaceStats
+	for _, stat := range stats {
+		if !self.keep(stat.Name) {
+			continue
+		}
+		counters, ok := self.interfaces[stat.Name]
+		if !ok {
+			counters = &interfaceCounters{}
+			self.interfaces[stat.Name] = counters
+		}
+		counters.update(stat.NetworkStats)
+		present = append(present, stat.OfInterface(stat.Name))
+	}
+
+	var total info.NetworkStats
+	for _, counters := range self.interfaces {
+		total = addStats(total, counters.total())
+	}
+	total.Interfaces = present
+	return total
+}
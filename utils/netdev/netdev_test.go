@@ -0,0 +1,130 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netdev
+
+import (
+	"os"
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseFile(t *testing.T, file string) ([]InterfaceStats, error) {
+	f, err := os.Open(file)
+	require.Nil(t, err)
+	defer f.Close()
+	return Parse(f)
+}
+
+func names(stats []InterfaceStats) []string {
+	ret := make([]string, 0, len(stats))
+	for _, stat := range stats {
+		ret = append(ret, stat.Name)
+	}
+	return ret
+}
+
+func TestParse(t *testing.T) {
+	stats, err := parseFile(t, "testdata/net-dev")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"lo", "eth0", "docker0", "vethab12cd3", "wlan0"}, names(stats))
+	assert.Equal(t, info.NetworkStats{
+		RxBytes:   1234567890,
+		RxPackets: 1234567,
+		RxErrors:  1,
+		RxDropped: 2,
+		TxBytes:   987654321,
+		TxPackets: 765432,
+		TxErrors:  3,
+		TxDropped: 4,
+	}, stats[1].NetworkStats)
+}
+
+func TestParseTooFewFields(t *testing.T) {
+	_, err := parseFile(t, "testdata/net-dev-short")
+	assert.NotNil(t, err)
+}
+
+func TestCollectorSumsPhysicalInterfaces(t *testing.T) {
+	oldProcNetDev := procNetDev
+	procNetDev = "testdata/net-dev"
+	defer func() {
+		procNetDev = oldProcNetDev
+	}()
+
+	stats, err := NewCollector().GetStats()
+	require.Nil(t, err)
+	// eth0 and wlan0 only.
+	assert.Equal(t, uint64(1234567890+1000), stats.RxBytes)
+	assert.Equal(t, uint64(987654321+2000), stats.TxBytes)
+	assert.Equal(t, uint64(1234567+10), stats.RxPackets)
+}
+
+func TestDeviceFilter(t *testing.T) {
+	keep := newDeviceFilter("")
+	assert.True(t, keep("eth0"))
+	assert.False(t, keep("lo"))
+	assert.False(t, keep("docker0"))
+	assert.False(t, keep("veth1234"))
+
+	keep = newDeviceFilter("eth*, wlan0")
+	assert.True(t, keep("eth1"))
+	assert.True(t, keep("wlan0"))
+	assert.False(t, keep("tun0"))
+}
+
+func interfaceStats(name string, rx, tx uint64) InterfaceStats {
+	return InterfaceStats{
+		Name: name,
+		NetworkStats: info.NetworkStats{
+			RxBytes: rx,
+			TxBytes: tx,
+		},
+	}
+}
+
+func TestCollectorKeepsTrafficOfRemovedInterfaces(t *testing.T) {
+	collector := &Collector{
+		keep:       newDeviceFilter(""),
+		interfaces: make(map[string]*interfaceCounters),
+	}
+	stats := collector.update([]InterfaceStats{interfaceStats("eth0", 100, 10)})
+	assert.Equal(t, uint64(100), stats.RxBytes)
+
+	// A VPN comes up.
+	stats = collector.update([]InterfaceStats{interfaceStats("eth0", 200, 20), interfaceStats("tun0", 50, 5)})
+	assert.Equal(t, uint64(250), stats.RxBytes)
+	assert.Equal(t, uint64(25), stats.TxBytes)
+
+	// The VPN goes down, its traffic stays counted.
+	stats = collector.update([]InterfaceStats{interfaceStats("eth0", 300, 30)})
+	assert.Equal(t, uint64(350), stats.RxBytes)
+
+	// The VPN comes back up with counters starting from zero.
+	stats = collector.update([]InterfaceStats{interfaceStats("eth0", 300, 30), interfaceStats("tun0", 10, 1)})
+	assert.Equal(t, uint64(360), stats.RxBytes)
+	assert.Equal(t, uint64(36), stats.TxBytes)
+	// The interfaces are reported with their own counters.
+	require.Equal(t, 2, len(stats.Interfaces))
+	assert.Equal(t, info.InterfaceStats{Name: "tun0", RxBytes: 10, TxBytes: 1}, stats.Interfaces[1])
+
+	// Excluded interfaces are never counted.
+	stats = collector.update([]InterfaceStats{interfaceStats("eth0", 300, 30), interfaceStats("veth1", 1000, 1000)})
+	assert.Equal(t, uint64(360), stats.RxBytes)
+	require.Equal(t, 1, len(stats.Interfaces))
+	assert.Equal(t, "eth0", stats.Interfaces[0].Name)
+}
@@ -0,0 +1,136 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netns
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/utils/procfs"
+)
+
+var sysDir = "/sys"
+
+// Returns the identity of the network namespace of process pid: its inode
+// and the host side of its veth pairs, which tools such as conntrack and
+// packet captures identify the traffic of the container by.
+func GetNetworkSpec(pid int) (*info.NetworkSpec, error) {
+	inode, err := namespaceInode(pid)
+	if err != nil {
+		return nil, err
+	}
+	spec := &info.NetworkSpec{
+		NamespaceInode: inode,
+	}
+	// A process in the namespace of the host (e.g.: Docker's --net=host)
+	// has no veth pair of its own.
+	if hostInode, err := namespaceInode(1); err == nil && hostInode == inode {
+		return spec, nil
+	}
+	spec.HostVeths, err = hostVeths(pid)
+	if err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// Returns the inode of the network namespace of process pid, from the
+// target of /proc/<pid>/ns/net (e.g.: "net:[4026531993]").
+func namespaceInode(pid int) (uint64, error) {
+	link := procfs.ProcessPath(pid, "ns", "net")
+	target, err := os.Readlink(link)
+	if err != nil {
+		return 0, err
+	}
+	if !strings.HasPrefix(target, "net:[") || !strings.HasSuffix(target, "]") {
+		return 0, fmt.Errorf("unexpected target %q of %q", target, link)
+	}
+	inode, err := strconv.ParseUint(target[len("net:["):len(target)-1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected target %q of %q", target, link)
+	}
+	return inode, nil
+}
+
+// Returns the names of the host interfaces paired with the interfaces of the
+// namespace of process pid, sorted. The interfaces of the namespace are read
+// from the sysfs mounted in the root of the process, where the index of the
+// peer of a veth (iflink) differs from its own (ifindex). Peers are looked up
+// among the interfaces of the host, those in other namespaces are skipped.
+func hostVeths(pid int) ([]string, error) {
+	containerNet := procfs.ProcessPath(pid, "root", "sys", "class", "net")
+	interfaces, err := ioutil.ReadDir(containerNet)
+	if err != nil {
+		return nil, err
+	}
+	peers := make(map[uint64]bool)
+	for _, iface := range interfaces {
+		if iface.Name() == "lo" {
+			continue
+		}
+		ifindex, err := readIndex(path.Join(containerNet, iface.Name(), "ifindex"))
+		if err != nil {
+			return nil, err
+		}
+		iflink, err := readIndex(path.Join(containerNet, iface.Name(), "iflink"))
+		if err != nil {
+			return nil, err
+		}
+		if iflink != ifindex {
+			peers[iflink] = true
+		}
+	}
+	if len(peers) == 0 {
+		return nil, nil
+	}
+
+	hostNet := path.Join(sysDir, "class", "net")
+	interfaces, err = ioutil.ReadDir(hostNet)
+	if err != nil {
+		return nil, err
+	}
+	var veths []string
+	for _, iface := range interfaces {
+		ifindex, err := readIndex(path.Join(hostNet, iface.Name(), "ifindex"))
+		if err != nil {
+			// The interface was removed since the directory was read.
+			continue
+		}
+		if peers[ifindex] {
+			veths = append(veths, iface.Name())
+		}
+	}
+	sort.Strings(veths)
+	return veths, nil
+}
+
+// Reads an interface index from a sysfs file.
+func readIndex(file string) (uint64, error) {
+	out, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	index, err := strconv.ParseUint(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse interface index in %q: %v", file, err)
+	}
+	return index, nil
+}
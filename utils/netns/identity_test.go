@@ -0,0 +1,148 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netns
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/utils/procfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Fake /proc and /sys under a temporary directory.
+type fakeIdentityFs struct {
+	t    *testing.T
+	root string
+}
+
+func newFakeIdentityFs(t *testing.T) (*fakeIdentityFs, func()) {
+	root, err := ioutil.TempDir("", "netns")
+	require.Nil(t, err)
+	restoreProc := procfs.SetRoot(path.Join(root, "proc"))
+	oldSysDir := sysDir
+	sysDir = path.Join(root, "sys")
+	return &fakeIdentityFs{t, root}, func() {
+		restoreProc()
+		sysDir = oldSysDir
+		os.RemoveAll(root)
+	}
+}
+
+// Adds process pid in the network namespace with the specified inode.
+func (self *fakeIdentityFs) addProcess(pid int, inode string) {
+	dir := procfs.ProcessPath(pid, "ns")
+	require.Nil(self.t, os.MkdirAll(dir, 0755))
+	require.Nil(self.t, os.Symlink("net:["+inode+"]", path.Join(dir, "net")))
+}
+
+// Adds an interface to the sysfs of process pid, that of the host if pid is 0.
+func (self *fakeIdentityFs) addInterface(pid int, name string, ifindex, iflink int) {
+	dir := path.Join(sysDir, "class", "net", name)
+	if pid != 0 {
+		dir = procfs.ProcessPath(pid, "root", "sys", "class", "net", name)
+	}
+	require.Nil(self.t, os.MkdirAll(dir, 0755))
+	require.Nil(self.t, ioutil.WriteFile(path.Join(dir, "ifindex"), []byte(strconv.Itoa(ifindex)+"\n"), 0644))
+	require.Nil(self.t, ioutil.WriteFile(path.Join(dir, "iflink"), []byte(strconv.Itoa(iflink)+"\n"), 0644))
+}
+
+func (self *fakeIdentityFs) addHost() {
+	self.addProcess(1, "4026531993")
+	self.addInterface(0, "lo", 1, 1)
+	self.addInterface(0, "eth0", 2, 2)
+	self.addInterface(0, "docker0", 3, 3)
+	self.addInterface(0, "veth1a2b3c4", 5, 4)
+	self.addInterface(0, "vethd5e6f7a", 7, 4)
+}
+
+func TestGetNetworkSpec(t *testing.T) {
+	fs, cleanup := newFakeIdentityFs(t)
+	defer cleanup()
+	fs.addHost()
+	fs.addProcess(1234, "4026532412")
+	fs.addInterface(1234, "lo", 1, 1)
+	fs.addInterface(1234, "eth0", 4, 5)
+
+	spec, err := GetNetworkSpec(1234)
+	require.Nil(t, err)
+	assert.Equal(t, &info.NetworkSpec{NamespaceInode: 4026532412, HostVeths: []string{"veth1a2b3c4"}}, spec)
+}
+
+func TestGetNetworkSpecMultipleVeths(t *testing.T) {
+	fs, cleanup := newFakeIdentityFs(t)
+	defer cleanup()
+	fs.addHost()
+	fs.addProcess(1234, "4026532412")
+	fs.addInterface(1234, "eth1", 6, 7)
+	fs.addInterface(1234, "eth0", 4, 5)
+	// A peer in another namespace than that of the host.
+	fs.addInterface(1234, "eth2", 8, 42)
+
+	spec, err := GetNetworkSpec(1234)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"veth1a2b3c4", "vethd5e6f7a"}, spec.HostVeths)
+}
+
+func TestGetNetworkSpecOfHostNamespace(t *testing.T) {
+	fs, cleanup := newFakeIdentityFs(t)
+	defer cleanup()
+	fs.addHost()
+	// The veths of the host peer with the interfaces of other namespaces.
+	fs.addProcess(1234, "4026531993")
+
+	spec, err := GetNetworkSpec(1234)
+	require.Nil(t, err)
+	assert.Equal(t, &info.NetworkSpec{NamespaceInode: 4026531993}, spec)
+}
+
+func TestGetNetworkSpecWithoutVeths(t *testing.T) {
+	fs, cleanup := newFakeIdentityFs(t)
+	defer cleanup()
+	fs.addHost()
+	fs.addProcess(1234, "4026532412")
+	fs.addInterface(1234, "lo", 1, 1)
+
+	spec, err := GetNetworkSpec(1234)
+	require.Nil(t, err)
+	assert.Equal(t, &info.NetworkSpec{NamespaceInode: 4026532412}, spec)
+}
+
+func TestGetNetworkSpecErrors(t *testing.T) {
+	fs, cleanup := newFakeIdentityFs(t)
+	defer cleanup()
+	fs.addHost()
+
+	// No such process.
+	_, err := GetNetworkSpec(1234)
+	assert.NotNil(t, err)
+
+	// Unexpected link target.
+	dir := procfs.ProcessPath(5678, "ns")
+	require.Nil(t, os.MkdirAll(dir, 0755))
+	require.Nil(t, os.Symlink("mnt:[4026531840]", path.Join(dir, "net")))
+	_, err = GetNetworkSpec(5678)
+	assert.NotNil(t, err)
+
+	// No sysfs in the root of the process.
+	fs.addProcess(9012, "4026532412")
+	_, err = GetNetworkSpec(9012)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,178 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Network statistics of containers, read over netlink from inside their
+// network namespace.
+package netns
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/golang/glog"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/utils/netdev"
+	"github.com/google/cadvisor/utils/procfs"
+)
+
+// An open network namespace.
+type namespace interface {
+	Fd() uintptr
+	Close() error
+}
+
+// The error of entering a namespace when the kernel or the privileges of
+// cAdvisor do not allow it.
+type setnsUnavailableError struct {
+	err error
+}
+
+func (self setnsUnavailableError) Error() string {
+	return fmt.Sprintf("setns is unavailable: %v", self.err)
+}
+
+// Collects the network statistics of a container, summed over its interfaces
+// but the loopback. The namespace of the container is opened once and kept
+// open, so that the statistics can be read while the processes of the
+// container come and go. Falls back to /proc/<pid>/net/dev when the
+// namespace cannot be entered. Class is thread-safe.
+type Collector struct {
+	lock sync.Mutex
+	// The init process of the container the namespace was opened from.
+	pid int
+	// The network namespace of the container, nil until opened.
+	ns namespace
+	// Whether entering namespaces failed for good, the statistics are then
+	// read from procfs.
+	setnsUnavailable bool
+	// Whether the collector was closed, it no longer opens namespaces.
+	closed bool
+
+	// Hooks for the namespace and statistics reads, replaced in tests.
+	openNamespace  func(pid int) (namespace, error)
+	readLinkStats  func(ns namespace) ([]netdev.InterfaceStats, error)
+	readProcNetDev func(pid int) ([]netdev.InterfaceStats, error)
+}
+
+func NewCollector() *Collector {
+	return &Collector{
+		openNamespace:  openNamespace,
+		readLinkStats:  readLinkStats,
+		readProcNetDev: readProcNetDev,
+	}
+}
+
+// Returns the network statistics of the container whose init process is pid.
+func (self *Collector) GetStats(pid int) (info.NetworkStats, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if self.closed {
+		return info.NetworkStats{}, fmt.Errorf("the network stats collector of process %d is closed", pid)
+	}
+	if self.setnsUnavailable {
+		return self.getProcStats(pid)
+	}
+	// The namespace is that of the previous init of the container if it
+	// restarted, open the current one.
+	if self.ns != nil && self.pid != pid {
+		self.closeNamespace()
+	}
+	if self.ns == nil {
+		ns, err := self.openNamespace(pid)
+		if err != nil {
+			return info.NetworkStats{}, fmt.Errorf("failed to open the network namespace of process %d: %v", pid, err)
+		}
+		self.ns = ns
+		self.pid = pid
+	}
+
+	stats, err := self.readLinkStats(self.ns)
+	if err != nil {
+		if _, ok := err.(setnsUnavailableError); ok {
+			glog.Infof("Reading the network stats of containers from procfs: %v", err)
+			self.setnsUnavailable = true
+			self.closeNamespace()
+			return self.getProcStats(pid)
+		}
+		return info.NetworkStats{}, err
+	}
+	return sumStats(stats), nil
+}
+
+// Closes the cached namespace, if any. Collections fail afterwards, so that one
+// racing with the close does not reopen the namespace.
+func (self *Collector) Close() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.closed = true
+	return self.closeNamespace()
+}
+
+func (self *Collector) closeNamespace() error {
+	if self.ns == nil {
+		return nil
+	}
+	err := self.ns.Close()
+	self.ns = nil
+	self.pid = 0
+	return err
+}
+
+func (self *Collector) getProcStats(pid int) (info.NetworkStats, error) {
+	stats, err := self.readProcNetDev(pid)
+	if err != nil {
+		return info.NetworkStats{}, err
+	}
+	return sumStats(stats), nil
+}
+
+// Sums the statistics of the interfaces but the loopback.
+func sumStats(stats []netdev.InterfaceStats) info.NetworkStats {
+	var ret info.NetworkStats
+	for _, stat := range stats {
+		if stat.Name == "lo" {
+			continue
+		}
+		ret.RxBytes += stat.RxBytes
+		ret.RxPackets += stat.RxPackets
+		ret.RxErrors += stat.RxErrors
+		ret.RxDropped += stat.RxDropped
+		ret.TxBytes += stat.TxBytes
+		ret.TxPackets += stat.TxPackets
+		ret.TxErrors += stat.TxErrors
+		ret.TxDropped += stat.TxDropped
+		ret.Interfaces = append(ret.Interfaces, stat.OfInterface(stat.Name))
+	}
+	return ret
+}
+
+func openNamespace(pid int) (namespace, error) {
+	return os.Open(procfs.ProcessPath(pid, "ns", "net"))
+}
+
+func readProcNetDev(pid int) ([]netdev.InterfaceStats, error) {
+	netDev := procfs.ProcessPath(pid, "net", "dev")
+	file, err := os.Open(netDev)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	stats, err := netdev.Parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %v", netDev, err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build linux
+
+package netns
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/docker/libcontainer/system"
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/utils/netdev"
+)
+
+// IFLA_STATS64, missing from the syscall package.
+const iflaStats64 = 23
+
+// Netlink messages are in the byte order of the machine.
+var nativeEndian binary.ByteOrder = binary.LittleEndian
+
+func init() {
+	var x uint16 = 1
+	if *(*byte)(unsafe.Pointer(&x)) == 0 {
+		nativeEndian = binary.BigEndian
+	}
+}
+
+// Reads the statistics of the interfaces of the namespace with a RTM_GETLINK
+// dump issued from inside it.
+func readLinkStats(ns namespace) ([]netdev.InterfaceStats, error) {
+	var stats []netdev.InterfaceStats
+	err := inNamespace(ns, func() error {
+		// The socket belongs to the namespace of the thread creating it.
+		rib, err := syscall.NetlinkRIB(syscall.RTM_GETLINK, syscall.AF_UNSPEC)
+		if err != nil {
+			return fmt.Errorf("failed to dump the links: %v", err)
+		}
+		msgs, err := syscall.ParseNetlinkMessage(rib)
+		if err != nil {
+			return fmt.Errorf("failed to parse the links: %v", err)
+		}
+		stats, err = parseLinkMessages(msgs)
+		return err
+	})
+	return stats, err
+}
+
+// Runs f on a thread moved to the namespace. Namespaces are per thread, so
+// the goroutine is locked to its thread until the thread is back in its own
+// namespace.
+func inNamespace(ns namespace, f func() error) error {
+	errs := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		own, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", syscall.Gettid()))
+		if err != nil {
+			runtime.UnlockOSThread()
+			errs <- fmt.Errorf("failed to open the network namespace of cAdvisor: %v", err)
+			return
+		}
+		defer own.Close()
+		if err := setns(ns.Fd()); err != nil {
+			runtime.UnlockOSThread()
+			errs <- err
+			return
+		}
+		err = f()
+		if restoreErr := setns(own.Fd()); restoreErr != nil {
+			// The thread stays locked so that no other goroutine runs in
+			// the namespace of the container, it ends with this goroutine.
+			glog.Errorf("Failed to restore the network namespace of a thread: %v", restoreErr)
+		} else {
+			runtime.UnlockOSThread()
+		}
+		errs <- err
+	}()
+	return <-errs
+}
+
+func setns(fd uintptr) error {
+	err := system.Setns(fd, syscall.CLONE_NEWNET)
+	if err == nil {
+		return nil
+	}
+	errno, ok := err.(syscall.Errno)
+	if !ok || errno == syscall.ENOSYS || errno == syscall.EPERM {
+		// Kernels older than 3.0 do not have setns, entering namespaces
+		// needs CAP_SYS_ADMIN and some architectures are not supported.
+		return setnsUnavailableError{err}
+	}
+	return fmt.Errorf("failed to enter network namespace: %v", err)
+}
+
+// Parses the RTM_NEWLINK messages of a link dump.
+func parseLinkMessages(msgs []syscall.NetlinkMessage) ([]netdev.InterfaceStats, error) {
+	var ret []netdev.InterfaceStats
+	for i := range msgs {
+		msg := &msgs[i]
+		switch msg.Header.Type {
+		case syscall.NLMSG_DONE:
+			return ret, nil
+		case syscall.NLMSG_ERROR:
+			return nil, fmt.Errorf("failed to dump the links: netlink error")
+		case syscall.RTM_NEWLINK:
+		default:
+			continue
+		}
+		attrs, err := syscall.ParseNetlinkRouteAttr(msg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse link attributes: %v", err)
+		}
+		var stat netdev.InterfaceStats
+		var counters, counters32 []byte
+		for _, attr := range attrs {
+			switch attr.Attr.Type {
+			case syscall.IFLA_IFNAME:
+				stat.Name = strings.TrimRight(string(attr.Value), "\x00")
+			case iflaStats64:
+				counters = attr.Value
+			case syscall.IFLA_STATS:
+				counters32 = attr.Value
+			}
+		}
+		// The 32-bit counters wrap, they are only used on kernels without
+		// the 64-bit ones.
+		var values []uint64
+		if counters != nil {
+			values = readCounters(counters, 8)
+		} else if counters32 != nil {
+			values = readCounters(counters32, 4)
+		} else {
+			continue
+		}
+		if len(values) < 8 {
+			return nil, fmt.Errorf("expected 8 statistics, found %d for link %q", len(values), stat.Name)
+		}
+		// Layout of struct rtnl_link_stats(64).
+		stat.RxPackets = values[0]
+		stat.TxPackets = values[1]
+		stat.RxBytes = values[2]
+		stat.TxBytes = values[3]
+		stat.RxErrors = values[4]
+		stat.TxErrors = values[5]
+		stat.RxDropped = values[6]
+		stat.TxDropped = values[7]
+		ret = append(ret, stat)
+	}
+	return ret, nil
+}
+
+// Reads the counters of the specified size, in bytes, from b.
+func readCounters(b []byte, size int) []uint64 {
+	values := make([]uint64, 0, len(b)/size)
+	for ; len(b) >= size; b = b[size:] {
+		if size == 8 {
+			values = append(values, nativeEndian.Uint64(b))
+		} else {
+			values = append(values, uint64(nativeEndian.Uint32(b)))
+		}
+	}
+	return values
+}
@@ -0,0 +1,165 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build linux
+
+package netns
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/google/cadvisor/utils/netdev"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Appends a route attribute, padded to 4 bytes.
+func appendAttr(b []byte, attrType uint16, value []byte) []byte {
+	attr := make([]byte, syscall.SizeofRtAttr, syscall.SizeofRtAttr+len(value)+3)
+	nativeEndian.PutUint16(attr[0:], uint16(syscall.SizeofRtAttr+len(value)))
+	nativeEndian.PutUint16(attr[2:], attrType)
+	attr = append(attr, value...)
+	for len(attr)%4 != 0 {
+		attr = append(attr, 0)
+	}
+	return append(b, attr...)
+}
+
+// Returns a RTM_NEWLINK message of the link with the counters of struct
+// rtnl_link_stats64, or rtnl_link_stats if size is 4.
+func linkMessage(name string, counters []uint64, size int) []byte {
+	value := make([]byte, size*len(counters))
+	for i, counter := range counters {
+		if size == 8 {
+			nativeEndian.PutUint64(value[i*8:], counter)
+		} else {
+			nativeEndian.PutUint32(value[i*4:], uint32(counter))
+		}
+	}
+	attrType := uint16(iflaStats64)
+	if size == 4 {
+		attrType = syscall.IFLA_STATS
+	}
+	body := make([]byte, syscall.SizeofIfInfomsg)
+	body = appendAttr(body, syscall.IFLA_IFNAME, append([]byte(name), 0))
+	body = appendAttr(body, attrType, value)
+
+	msg := make([]byte, syscall.NLMSG_HDRLEN, syscall.NLMSG_HDRLEN+len(body))
+	nativeEndian.PutUint32(msg[0:], uint32(syscall.NLMSG_HDRLEN+len(body)))
+	nativeEndian.PutUint16(msg[4:], syscall.RTM_NEWLINK)
+	return append(msg, body...)
+}
+
+func doneMessage() []byte {
+	msg := make([]byte, syscall.NLMSG_HDRLEN+4)
+	nativeEndian.PutUint32(msg[0:], uint32(len(msg)))
+	nativeEndian.PutUint16(msg[4:], syscall.NLMSG_DONE)
+	return msg
+}
+
+func parseLinks(t testing.TB, dump []byte) []netdev.InterfaceStats {
+	msgs, err := syscall.ParseNetlinkMessage(dump)
+	require.Nil(t, err)
+	stats, err := parseLinkMessages(msgs)
+	require.Nil(t, err)
+	return stats
+}
+
+func TestParseLinkMessages(t *testing.T) {
+	var dump []byte
+	dump = append(dump, linkMessage("lo", []uint64{1, 1, 100, 100, 0, 0, 0, 0}, 8)...)
+	dump = append(dump, linkMessage("eth0", []uint64{10, 20, 1 << 40, 2000, 1, 2, 3, 4, 0, 0}, 8)...)
+	dump = append(dump, doneMessage()...)
+
+	stats := parseLinks(t, dump)
+	require.Equal(t, 2, len(stats))
+	assert.Equal(t, "lo", stats[0].Name)
+	eth0 := stats[1]
+	assert.Equal(t, "eth0", eth0.Name)
+	assert.Equal(t, uint64(10), eth0.RxPackets)
+	assert.Equal(t, uint64(20), eth0.TxPackets)
+	assert.Equal(t, uint64(1<<40), eth0.RxBytes)
+	assert.Equal(t, uint64(2000), eth0.TxBytes)
+	assert.Equal(t, uint64(1), eth0.RxErrors)
+	assert.Equal(t, uint64(2), eth0.TxErrors)
+	assert.Equal(t, uint64(3), eth0.RxDropped)
+	assert.Equal(t, uint64(4), eth0.TxDropped)
+}
+
+func TestParseLinkMessages32BitCounters(t *testing.T) {
+	dump := linkMessage("eth0", []uint64{10, 20, 1000, 2000, 1, 2, 3, 4}, 4)
+	stats := parseLinks(t, dump)
+	require.Equal(t, 1, len(stats))
+	assert.Equal(t, uint64(1000), stats[0].RxBytes)
+	assert.Equal(t, uint64(4), stats[0].TxDropped)
+}
+
+func TestParseLinkMessagesTooFewCounters(t *testing.T) {
+	msgs, err := syscall.ParseNetlinkMessage(linkMessage("eth0", []uint64{10, 20}, 8))
+	require.Nil(t, err)
+	_, err = parseLinkMessages(msgs)
+	assert.NotNil(t, err)
+}
+
+const benchmarkInterfaces = 256
+
+// The link dump and /proc/<pid>/net/dev of a container with many interfaces.
+func benchmarkSources() ([]byte, []byte) {
+	var dump []byte
+	netDev := bytes.NewBufferString("Inter-|   Receive                                                |  Transmit\n" +
+		" face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed\n")
+	for i := 0; i < benchmarkInterfaces; i++ {
+		name := fmt.Sprintf("eth%d", i)
+		dump = append(dump, linkMessage(name, []uint64{1234567, 765432, 1234567890, 987654321, 1, 3, 2, 4}, 8)...)
+		fmt.Fprintf(netDev, "%6s:1234567890 1234567    1    2    0     0          0      1234 987654321  765432    3    4    0     0       0          0\n", name)
+	}
+	return append(dump, doneMessage()...), netDev.Bytes()
+}
+
+func BenchmarkParseLinkMessages(b *testing.B) {
+	dump, _ := benchmarkSources()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		parseLinks(b, dump)
+	}
+}
+
+func BenchmarkParseProcNetDev(b *testing.B) {
+	_, netDev := benchmarkSources()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := netdev.Parse(bytes.NewReader(netDev))
+		require.Nil(b, err)
+	}
+}
+
+// Reads the links of the namespace of the benchmark, which needs
+// CAP_SYS_ADMIN.
+func BenchmarkReadLinkStats(b *testing.B) {
+	ns, err := os.Open("/proc/self/ns/net")
+	require.Nil(b, err)
+	defer ns.Close()
+	if _, err := readLinkStats(ns); err != nil {
+		b.Skipf("cannot read the links over netlink: %v", err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := readLinkStats(ns)
+		require.Nil(b, err)
+	}
+}
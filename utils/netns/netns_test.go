@@ -0,0 +1,218 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netns
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/utils/netdev"
+	"github.com/google/cadvisor/utils/procfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeNamespace struct {
+	pid    int
+	closed bool
+}
+
+func (self *fakeNamespace) Fd() uintptr {
+	return uintptr(self.pid)
+}
+
+func (self *fakeNamespace) Close() error {
+	self.closed = true
+	return nil
+}
+
+// A collector reading the specified statistics, recording the namespaces it
+// opens and how many times each source is read.
+type fakeSources struct {
+	opened     []*fakeNamespace
+	linkReads  int
+	procReads  int
+	linkStats  []netdev.InterfaceStats
+	linkErr    error
+	procStats  []netdev.InterfaceStats
+	procErr    error
+	openNsErr  error
+	lastLinkNs namespace
+}
+
+func (self *fakeSources) collector() *Collector {
+	return &Collector{
+		openNamespace: func(pid int) (namespace, error) {
+			if self.openNsErr != nil {
+				return nil, self.openNsErr
+			}
+			ns := &fakeNamespace{pid: pid}
+			self.opened = append(self.opened, ns)
+			return ns, nil
+		},
+		readLinkStats: func(ns namespace) ([]netdev.InterfaceStats, error) {
+			self.linkReads++
+			self.lastLinkNs = ns
+			return self.linkStats, self.linkErr
+		},
+		readProcNetDev: func(pid int) ([]netdev.InterfaceStats, error) {
+			self.procReads++
+			return self.procStats, self.procErr
+		},
+	}
+}
+
+func interfaceStats(name string, rxBytes, txBytes uint64) netdev.InterfaceStats {
+	return netdev.InterfaceStats{
+		Name: name,
+		NetworkStats: info.NetworkStats{
+			RxBytes: rxBytes,
+			TxBytes: txBytes,
+		},
+	}
+}
+
+func TestGetStatsSumsInterfacesButLoopback(t *testing.T) {
+	sources := &fakeSources{
+		linkStats: []netdev.InterfaceStats{
+			interfaceStats("lo", 1000, 1000),
+			interfaceStats("eth0", 10, 20),
+			interfaceStats("eth1", 1, 2),
+		},
+	}
+	stats, err := sources.collector().GetStats(1234)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(11), stats.RxBytes)
+	assert.Equal(t, uint64(22), stats.TxBytes)
+	require.Equal(t, 2, len(stats.Interfaces))
+	assert.Equal(t, "eth0", stats.Interfaces[0].Name)
+	assert.Equal(t, "eth1", stats.Interfaces[1].Name)
+	assert.Equal(t, 0, sources.procReads)
+}
+
+func TestGetStatsKeepsNamespaceOpen(t *testing.T) {
+	sources := &fakeSources{}
+	collector := sources.collector()
+	for i := 0; i < 3; i++ {
+		_, err := collector.GetStats(1234)
+		require.Nil(t, err)
+	}
+	require.Equal(t, 1, len(sources.opened))
+	assert.False(t, sources.opened[0].closed)
+	assert.Equal(t, 3, sources.linkReads)
+}
+
+func TestGetStatsReopensNamespaceOfNewInit(t *testing.T) {
+	sources := &fakeSources{}
+	collector := sources.collector()
+	_, err := collector.GetStats(1234)
+	require.Nil(t, err)
+	_, err = collector.GetStats(5678)
+	require.Nil(t, err)
+
+	require.Equal(t, 2, len(sources.opened))
+	assert.True(t, sources.opened[0].closed)
+	assert.False(t, sources.opened[1].closed)
+	assert.Equal(t, sources.opened[1], sources.lastLinkNs)
+}
+
+func TestGetStatsFallsBackToProcfsWithoutSetns(t *testing.T) {
+	sources := &fakeSources{
+		linkErr:   setnsUnavailableError{syscall.EPERM},
+		procStats: []netdev.InterfaceStats{interfaceStats("eth0", 10, 20)},
+	}
+	collector := sources.collector()
+	for i := 0; i < 2; i++ {
+		stats, err := collector.GetStats(1234)
+		require.Nil(t, err)
+		assert.Equal(t, uint64(10), stats.RxBytes)
+	}
+	// Netlink is not retried once setns failed, and the namespace is not kept.
+	assert.Equal(t, 1, sources.linkReads)
+	assert.Equal(t, 2, sources.procReads)
+	require.Equal(t, 1, len(sources.opened))
+	assert.True(t, sources.opened[0].closed)
+}
+
+func TestGetStatsFallbackErrors(t *testing.T) {
+	sources := &fakeSources{
+		linkErr: setnsUnavailableError{syscall.ENOSYS},
+		procErr: errors.New("no such file"),
+	}
+	_, err := sources.collector().GetStats(1234)
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, sources.procReads)
+}
+
+func TestGetStatsDoesNotFallBackOnOtherErrors(t *testing.T) {
+	sources := &fakeSources{
+		linkErr: errors.New("failed to dump the links"),
+	}
+	collector := sources.collector()
+	for i := 0; i < 2; i++ {
+		_, err := collector.GetStats(1234)
+		assert.NotNil(t, err)
+	}
+	assert.Equal(t, 2, sources.linkReads)
+	assert.Equal(t, 0, sources.procReads)
+}
+
+func TestGetStatsNamespaceOpenError(t *testing.T) {
+	sources := &fakeSources{
+		openNsErr: errors.New("no such process"),
+	}
+	_, err := sources.collector().GetStats(1234)
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, sources.linkReads)
+	assert.Equal(t, 0, sources.procReads)
+}
+
+func TestClose(t *testing.T) {
+	sources := &fakeSources{}
+	collector := sources.collector()
+	_, err := collector.GetStats(1234)
+	require.Nil(t, err)
+	require.Nil(t, collector.Close())
+	require.Equal(t, 1, len(sources.opened))
+	assert.True(t, sources.opened[0].closed)
+
+	// The namespace is not reopened once closed.
+	_, err = collector.GetStats(1234)
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, len(sources.opened))
+}
+
+func TestReadProcNetDev(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netns")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	netDev, err := ioutil.ReadFile("../netdev/testdata/net-dev")
+	require.Nil(t, err)
+	require.Nil(t, os.MkdirAll(path.Join(dir, "1234", "net"), 0755))
+	require.Nil(t, ioutil.WriteFile(path.Join(dir, "1234", "net", "dev"), netDev, 0644))
+
+	defer procfs.SetRoot(dir)()
+
+	stats, err := readProcNetDev(1234)
+	require.Nil(t, err)
+	assert.Equal(t, 5, len(stats))
+	_, err = readProcNetDev(5678)
+	assert.NotNil(t, err)
+}
@@ -14,17 +14,50 @@
 
 package procfs
 
-/*
-#include <unistd.h>
-*/
-import "C"
-import "time"
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+	"unsafe"
+
+	"github.com/golang/glog"
+)
+
+// Type of the entry of the auxiliary vector holding the frequency of the
+// clock ticks of the kernel, sysconf(_SC_CLK_TCK).
+const atClkTck = 17
+
+// Frequency of the clock ticks assumed when it cannot be read, that of most
+// kernels.
+const defaultUserHz = 100
 
 var userHz uint64
 
 func init() {
-	userHzLong := C.sysconf(C._SC_CLK_TCK)
-	userHz = uint64(userHzLong)
+	var err error
+	userHz, err = readUserHz("/proc/self/auxv")
+	if err != nil {
+		glog.Warningf("Assuming %d clock ticks per second: %v", defaultUserHz, err)
+		userHz = defaultUserHz
+	}
+}
+
+// Reads the frequency of the clock ticks of the kernel from the auxiliary
+// vector of the process, pairs of native words, rather than through cgo.
+func readUserHz(auxvPath string) (uint64, error) {
+	auxv, err := ioutil.ReadFile(auxvPath)
+	if err != nil {
+		return 0, err
+	}
+	wordSize := int(unsafe.Sizeof(uintptr(0)))
+	for i := 0; i+2*wordSize <= len(auxv); i += 2 * wordSize {
+		key := *(*uintptr)(unsafe.Pointer(&auxv[i]))
+		value := *(*uintptr)(unsafe.Pointer(&auxv[i+wordSize]))
+		if key == atClkTck && value != 0 {
+			return uint64(value), nil
+		}
+	}
+	return 0, fmt.Errorf("no clock tick frequency in %q", auxvPath)
 }
 
 func JiffiesToDuration(jiffies uint64) time.Duration {
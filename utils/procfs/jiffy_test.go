@@ -0,0 +1,58 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package procfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"unsafe"
+)
+
+// Writes an auxiliary vector of the specified pairs of native words.
+func writeAuxv(t *testing.T, dir string, words ...uintptr) string {
+	wordSize := int(unsafe.Sizeof(uintptr(0)))
+	auxv := make([]byte, len(words)*wordSize)
+	for i, word := range words {
+		*(*uintptr)(unsafe.Pointer(&auxv[i*wordSize])) = word
+	}
+	auxvPath := path.Join(dir, "auxv")
+	if err := ioutil.WriteFile(auxvPath, auxv, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return auxvPath
+}
+
+func TestReadUserHz(t *testing.T) {
+	dir, err := ioutil.TempDir("", "procfs-auxv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// AT_PAGESZ, then AT_CLKTCK.
+	userHz, err := readUserHz(writeAuxv(t, dir, 6, 4096, atClkTck, 250, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userHz != 250 {
+		t.Errorf("Read %d clock ticks per second, expected 250", userHz)
+	}
+
+	if _, err := readUserHz(writeAuxv(t, dir, 6, 4096, 0, 0)); err == nil {
+		t.Errorf("Expected an error without AT_CLKTCK")
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package procfs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Names of the limits in /proc/<pid>/limits that we report.
+const (
+	limitOpenFiles    = "Max open files"
+	limitProcesses    = "Max processes"
+	limitLockedMemory = "Max locked memory"
+)
+
+// Gets the OOM score adjustment and resource limits of the specified process.
+func GetProcessLimits(pid int) (*info.ProcessLimits, error) {
+	out, err := ioutil.ReadFile(ProcessPath(pid, "limits"))
+	if err != nil {
+		return nil, err
+	}
+	limits, err := parseLimits(string(out))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse limits of process %d: %v", pid, err)
+	}
+
+	out, err = ioutil.ReadFile(ProcessPath(pid, "oom_score_adj"))
+	if err != nil {
+		return nil, err
+	}
+	limits.OomScoreAdj, err = strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse oom_score_adj %q of process %d: %v", string(out), pid, err)
+	}
+	return limits, nil
+}
+
+// Parses the contents of /proc/<pid>/limits. e.g.:
+//
+// Limit                     Soft Limit           Hard Limit           Units
+// Max open files            1024                 4096                 files
+func parseLimits(limitsFile string) (*info.ProcessLimits, error) {
+	limits := &info.ProcessLimits{}
+	for _, line := range strings.Split(limitsFile, "\n") {
+		var limit *info.ResourceLimit
+		var name string
+		switch {
+		case strings.HasPrefix(line, limitOpenFiles):
+			limit, name = &limits.OpenFiles, limitOpenFiles
+		case strings.HasPrefix(line, limitProcesses):
+			limit, name = &limits.Processes, limitProcesses
+		case strings.HasPrefix(line, limitLockedMemory):
+			limit, name = &limits.LockedMemory, limitLockedMemory
+		default:
+			continue
+		}
+
+		// The remaining fields are: <soft> <hard> [<units>]
+		fields := strings.Fields(line[len(name):])
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("malformed limit line %q", line)
+		}
+		var err error
+		limit.Soft, err = parseLimitValue(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		limit.Hard, err = parseLimitValue(fields[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return limits, nil
+}
+
+// Unlimited values are represented as math.MaxUint64.
+func parseLimitValue(value string) (uint64, error) {
+	if value == "unlimited" {
+		return math.MaxUint64, nil
+	}
+	val, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse limit value %q: %v", value, err)
+	}
+	return val, nil
+}
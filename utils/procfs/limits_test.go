@@ -0,0 +1,113 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package procfs
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+const limitsFixture = `Limit                     Soft Limit           Hard Limit           Units     
+Max cpu time              unlimited            unlimited            seconds   
+Max file size             unlimited            unlimited            bytes     
+Max data size             unlimited            unlimited            bytes     
+Max stack size            8388608              unlimited            bytes     
+Max core file size        0                    unlimited            bytes     
+Max resident set          unlimited            unlimited            bytes     
+Max processes             63428                63428                processes 
+Max open files            512                  1024                 files     
+Max locked memory         65536                65536                bytes     
+Max address space         unlimited            unlimited            bytes     
+Max file locks            unlimited            unlimited            locks     
+Max pending signals       63428                63428                signals   
+Max msgqueue size         819200               819200               bytes     
+Max nice priority         0                    0                    
+Max realtime priority     0                    0                    
+Max realtime timeout      unlimited            unlimited            us        
+`
+
+func TestParseLimits(t *testing.T) {
+	limits, err := parseLimits(limitsFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &info.ProcessLimits{
+		OpenFiles:    info.ResourceLimit{Soft: 512, Hard: 1024},
+		Processes:    info.ResourceLimit{Soft: 63428, Hard: 63428},
+		LockedMemory: info.ResourceLimit{Soft: 65536, Hard: 65536},
+	}
+	if !reflect.DeepEqual(limits, expected) {
+		t.Errorf("expected limits %+v, got %+v", expected, limits)
+	}
+}
+
+func TestParseLimitsUnlimited(t *testing.T) {
+	limits, err := parseLimits("Max locked memory         unlimited            unlimited            bytes\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits.LockedMemory.Soft != math.MaxUint64 || limits.LockedMemory.Hard != math.MaxUint64 {
+		t.Errorf("expected unlimited locked memory, got %+v", limits.LockedMemory)
+	}
+}
+
+func TestParseLimitsMalformed(t *testing.T) {
+	_, err := parseLimits("Max open files            abc                  1024                 files\n")
+	if err == nil {
+		t.Errorf("expected error parsing malformed limits")
+	}
+}
+
+func TestGetProcessLimits(t *testing.T) {
+	dir, err := ioutil.TempDir("", "procfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer SetRoot(dir)()
+
+	pidDir := path.Join(dir, "42")
+	if err := os.Mkdir(pidDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(pidDir, "limits"), []byte(limitsFixture), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(pidDir, "oom_score_adj"), []byte("-500\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	limits, err := GetProcessLimits(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits.OomScoreAdj != -500 {
+		t.Errorf("expected oom_score_adj of -500, got %d", limits.OomScoreAdj)
+	}
+	if limits.OpenFiles.Soft != 512 {
+		t.Errorf("expected soft open files limit of 512, got %d", limits.OpenFiles.Soft)
+	}
+
+	_, err = GetProcessLimits(43)
+	if err == nil {
+		t.Errorf("expected error for missing process")
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package procfs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strconv"
+)
+
+// Root of the proc filesystem.
+var procRoot = "/proc"
+
+// Points the package at the proc filesystem at the specified directory (e.g.:
+// a fake one in tests) until the returned function is called.
+func SetRoot(root string) func() {
+	original := procRoot
+	procRoot = root
+	return func() {
+		procRoot = original
+	}
+}
+
+// Returns the path of the specified file of the proc filesystem, e.g.:
+// Path("self") for /proc/self.
+func Path(elem ...string) string {
+	return path.Join(append([]string{procRoot}, elem...)...)
+}
+
+// Returns the path of the specified file of a process, e.g.:
+// ProcessPath(pid, "stat") for /proc/<pid>/stat.
+func ProcessPath(pid int, elem ...string) string {
+	return Path(append([]string{strconv.Itoa(pid)}, elem...)...)
+}
+
+// Reads /proc/<pid>/stat of the specified process.
+func ReadProcessStat(pid int) (*ProcessStat, error) {
+	out, err := ioutil.ReadFile(ProcessPath(pid, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	stat, err := ParseProcessStat(string(out))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the stat of process %d: %v", pid, err)
+	}
+	return stat, nil
+}
+
+// Reads /proc/<pid>/status of the specified process.
+func ReadProcessStatus(pid int) (*ProcessStatus, error) {
+	out, err := ioutil.ReadFile(ProcessPath(pid, "status"))
+	if err != nil {
+		return nil, err
+	}
+	status, err := ParseProcessStatus(string(out))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the status of process %d: %v", pid, err)
+	}
+	return status, nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package procfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestReadProcessStat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "procfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer SetRoot(dir)()
+	if err := os.MkdirAll(path.Join(dir, "42"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(dir, "42", "stat"), []byte("42 (sleep) S 1 42 42 0 -1 4202752 500 0 0 0 250 125 0 0 20 0 1 0 1000 200000000 5000 18446744073709551615\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if ProcessPath(42, "stat") != path.Join(dir, "42", "stat") {
+		t.Errorf("Unexpected path %q of the stat of process 42", ProcessPath(42, "stat"))
+	}
+	stat, err := ReadProcessStat(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Comm != "sleep" || stat.UserTime != 250 || stat.StartTime != 1000 {
+		t.Errorf("Unexpected stat %+v", stat)
+	}
+	if _, err := ReadProcessStat(43); !os.IsNotExist(err) {
+		t.Errorf("Expected the stat of a missing process not to exist, got %v", err)
+	}
+}
+
+func TestSetRootIsRestored(t *testing.T) {
+	original := Path()
+	restore := SetRoot("testdata")
+	if Path("stat") != "testdata/stat" {
+		t.Errorf("Unexpected path %q with the root set to testdata", Path("stat"))
+	}
+	restore()
+	if Path() != original {
+		t.Errorf("Root %q not restored to %q", Path(), original)
+	}
+}
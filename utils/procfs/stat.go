@@ -0,0 +1,87 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package procfs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The parts of /proc/<pid>/stat that we report.
+type ProcessStat struct {
+	// Name of the executable.
+	Comm string
+
+	// State of the process, e.g.: ProcessStateUninterruptible.
+	State string
+
+	// CPU time spent in user and kernel mode, in jiffies.
+	UserTime   uint64
+	SystemTime uint64
+
+	// Time the process started after boot, in jiffies. Together with the PID
+	// it identifies the process across PID reuse.
+	StartTime uint64
+
+	// Resident set size, in pages.
+	Rss uint64
+}
+
+// States of processes in /proc/<pid>/stat.
+const (
+	ProcessStateRunning         = "R"
+	ProcessStateSleeping        = "S"
+	ProcessStateUninterruptible = "D"
+	ProcessStateZombie          = "Z"
+)
+
+// Parses the contents of /proc/<pid>/stat, e.g.:
+//
+// 1234 (docker) S 1 1234 1234 0 -1 4202752 5000 0 0 0 150 75 0 0 20 0 12 0 1000 ...
+func ParseProcessStat(contents string) (*ProcessStat, error) {
+	// The executable name may contain spaces and parentheses, the other fields follow the last ')'.
+	start := strings.Index(contents, "(")
+	end := strings.LastIndex(contents, ")")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("malformed stat %q", contents)
+	}
+	// Fields from the state (3rd field) onwards.
+	fields := strings.Fields(contents[end+1:])
+	const rssField = 24 - 3
+	if len(fields) <= rssField {
+		return nil, fmt.Errorf("malformed stat %q", contents)
+	}
+	stat := &ProcessStat{
+		Comm:  contents[start+1 : end],
+		State: fields[0],
+	}
+	for _, field := range []struct {
+		index int
+		value *uint64
+	}{
+		{14 - 3, &stat.UserTime},
+		{15 - 3, &stat.SystemTime},
+		{22 - 3, &stat.StartTime},
+		{rssField, &stat.Rss},
+	} {
+		value, err := strconv.ParseUint(fields[field.index], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed stat field %d %q: %v", field.index+3, fields[field.index], err)
+		}
+		*field.value = value
+	}
+	return stat, nil
+}
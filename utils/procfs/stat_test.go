@@ -0,0 +1,74 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package procfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseProcessStat(t *testing.T) {
+	stat, err := ParseProcessStat("42 (my (weird) name) R 1 42 42 0 -1 4202752 500 0 0 0 250 125 0 0 20 0 10 0 1000 200000000 5000 18446744073709551615")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &ProcessStat{
+		Comm:       "my (weird) name",
+		State:      ProcessStateRunning,
+		UserTime:   250,
+		SystemTime: 125,
+		StartTime:  1000,
+		Rss:        5000,
+	}
+	if !reflect.DeepEqual(stat, expected) {
+		t.Errorf("Parsed %+v, expected %+v", stat, expected)
+	}
+}
+
+func TestParseProcessStatState(t *testing.T) {
+	for _, c := range []struct {
+		contents string
+		comm     string
+		state    string
+	}{
+		{"7 (kworker/0:1) D 2 0 0 0 -1 69238880 0 0 0 0 0 5 0 0 20 0 1 0 12 0 0 18446744073709551615", "kworker/0:1", ProcessStateUninterruptible},
+		{"8 (sh) Z 1 8 8 0 -1 4227084 120 0 0 0 0 0 0 0 20 0 1 0 40 0 0 18446744073709551615", "sh", ProcessStateZombie},
+		// The name looks like it ends with a state, only the last ')' ends it.
+		{"9 (a) Z (b) S 1 9 9 0 -1 4202752 500 0 0 0 1 1 0 0 20 0 1 0 100 200000000 100 18446744073709551615", "a) Z (b", ProcessStateSleeping},
+		{"10 (spaced name ) D 1 10 10 0 -1 4202752 500 0 0 0 1 1 0 0 20 0 1 0 100 200000000 100 18446744073709551615", "spaced name ", ProcessStateUninterruptible},
+	} {
+		stat, err := ParseProcessStat(c.contents)
+		if err != nil {
+			t.Errorf("Failed to parse %q: %v", c.contents, err)
+			continue
+		}
+		if stat.Comm != c.comm || stat.State != c.state {
+			t.Errorf("Parsed name %q and state %q from %q, expected %q and %q", stat.Comm, stat.State, c.contents, c.comm, c.state)
+		}
+	}
+}
+
+func TestParseProcessStatMalformed(t *testing.T) {
+	for _, contents := range []string{
+		"",
+		"42 no name S 1",
+		"42 (short) S 1 42 42",
+		"42 (bad) S 1 42 42 0 -1 4202752 500 0 0 0 x 125 0 0 20 0 10 0 1000 200000000 5000",
+	} {
+		if _, err := ParseProcessStat(contents); err == nil {
+			t.Errorf("Expected an error parsing %q", contents)
+		}
+	}
+}
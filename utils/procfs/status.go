@@ -0,0 +1,73 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package procfs
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The parts of /proc/<pid>/status that we report.
+type ProcessStatus struct {
+	// Number of times the main thread of the process gave up the CPU and was
+	// preempted.
+	VoluntaryCtxtSwitches    uint64
+	NonvoluntaryCtxtSwitches uint64
+}
+
+// Parses the contents of /proc/<pid>/status, e.g.:
+//
+// Name:	bash
+// State:	S (sleeping)
+// ...
+// voluntary_ctxt_switches:	150
+// nonvoluntary_ctxt_switches:	545
+//
+// Fails if the kernel does not report the context switches.
+func ParseProcessStatus(contents string) (*ProcessStatus, error) {
+	status := &ProcessStatus{}
+	found := 0
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		var value *uint64
+		switch parts[0] {
+		case "voluntary_ctxt_switches":
+			value = &status.VoluntaryCtxtSwitches
+		case "nonvoluntary_ctxt_switches":
+			value = &status.NonvoluntaryCtxtSwitches
+		default:
+			continue
+		}
+		n, err := strconv.ParseUint(strings.TrimSpace(parts[1]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed status line %q: %v", scanner.Text(), err)
+		}
+		*value = n
+		found++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if found != 2 {
+		return nil, fmt.Errorf("no context switches in status %q", contents)
+	}
+	return status, nil
+}
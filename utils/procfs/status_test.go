@@ -0,0 +1,53 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package procfs
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestParseProcessStatus(t *testing.T) {
+	contents, err := ioutil.ReadFile("testdata/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := ParseProcessStatus(string(contents))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &ProcessStatus{
+		VoluntaryCtxtSwitches:    18446,
+		NonvoluntaryCtxtSwitches: 312,
+	}
+	if !reflect.DeepEqual(status, expected) {
+		t.Errorf("Parsed %+v, expected %+v", status, expected)
+	}
+}
+
+func TestParseProcessStatusMalformed(t *testing.T) {
+	for _, contents := range []string{
+		"",
+		// Kernels before 2.6.23 do not report context switches.
+		"Name:\tsh\nState:\tS (sleeping)\nPid:\t42\n",
+		"voluntary_ctxt_switches:\t10\n",
+		"voluntary_ctxt_switches:\tmany\nnonvoluntary_ctxt_switches:\t5\n",
+	} {
+		if _, err := ParseProcessStatus(contents); err == nil {
+			t.Errorf("Expected an error parsing %q", contents)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package procfs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Files of the machine health gauges, relative to procRoot.
+const (
+	entropyAvailFile   = "sys/kernel/random/entropy_avail"
+	fileNrFile         = "sys/fs/file-nr"
+	conntrackCountFile = "sys/net/netfilter/nf_conntrack_count"
+	conntrackMaxFile   = "sys/net/netfilter/nf_conntrack_max"
+	pidMaxFile         = "sys/kernel/pid_max"
+	loadavgFile        = "loadavg"
+)
+
+// Gets the health gauges of the machine. Gauges whose files do not exist are
+// left nil, any other failure to read or parse them is an error.
+func GetSystemHealth() (*info.SystemHealthStats, error) {
+	health := &info.SystemHealthStats{}
+
+	entropy, err := readUint(entropyAvailFile)
+	if err == nil {
+		health.EntropyAvailable = &entropy
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	out, err := readProcFile(fileNrFile)
+	if err == nil {
+		health.FileHandles, err = parseFileNr(out)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %q: %v", fileNrFile, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	health.Conntrack, err = readTableUsage(conntrackCountFile, conntrackMaxFile)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err = readProcFile(loadavgFile)
+	if err == nil {
+		var tasks uint64
+		tasks, err = parseLoadavgTasks(out)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %q: %v", loadavgFile, err)
+		}
+		var pidMax uint64
+		pidMax, err = readUint(pidMaxFile)
+		if err == nil {
+			health.Pids = &info.KernelTableUsage{Used: tasks, Max: pidMax}
+		}
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return health, nil
+}
+
+// Reads the usage of a kernel table from the files of its count and maximum.
+// Nil if either file does not exist.
+func readTableUsage(countFile, maxFile string) (*info.KernelTableUsage, error) {
+	count, err := readUint(countFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	max, err := readUint(maxFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &info.KernelTableUsage{Used: count, Max: max}, nil
+}
+
+func readProcFile(name string) (string, error) {
+	out, err := ioutil.ReadFile(Path(name))
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// Reads a file holding a single unsigned integer.
+func readUint(name string) (uint64, error) {
+	out, err := readProcFile(name)
+	if err != nil {
+		return 0, err
+	}
+	val, err := strconv.ParseUint(strings.TrimSpace(out), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %q: %v", name, err)
+	}
+	return val, nil
+}
+
+// Parses the contents of /proc/sys/fs/file-nr: <allocated> <free> <max>. e.g.:
+//
+// 9952	0	3247493
+//
+// Kernels since 2.6 always report 0 free handles, so allocated is the number in use.
+func parseFileNr(fileNr string) (*info.KernelTableUsage, error) {
+	fields := strings.Fields(fileNr)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("expected 3 fields, found %d", len(fields))
+	}
+	allocated, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	free, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	max, err := strconv.ParseUint(fields[2], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if free > allocated {
+		free = allocated
+	}
+	return &info.KernelTableUsage{Used: allocated - free, Max: max}, nil
+}
+
+// Parses the number of tasks (processes and threads) from /proc/loadavg. e.g.:
+//
+// 0.20 0.18 0.12 1/80 11206
+//
+// where 80 is the number of tasks.
+func parseLoadavgTasks(loadavg string) (uint64, error) {
+	fields := strings.Fields(loadavg)
+	if len(fields) < 4 {
+		return 0, fmt.Errorf("expected at least 4 fields, found %d", len(fields))
+	}
+	parts := strings.Split(fields[3], "/")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("malformed running/total tasks %q", fields[3])
+	}
+	return strconv.ParseUint(parts[1], 10, 64)
+}
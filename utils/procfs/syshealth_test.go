@@ -0,0 +1,118 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package procfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+func withProcRoot(t *testing.T, root string, f func()) {
+	defer SetRoot(root)()
+	f()
+}
+
+func TestGetSystemHealth(t *testing.T) {
+	withProcRoot(t, "testdata/syshealth", func() {
+		health, err := GetSystemHealth()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entropy := uint64(3754)
+		expected := &info.SystemHealthStats{
+			EntropyAvailable: &entropy,
+			FileHandles:      &info.KernelTableUsage{Used: 9952, Max: 3247493},
+			Conntrack:        &info.KernelTableUsage{Used: 412, Max: 262144},
+			Pids:             &info.KernelTableUsage{Used: 80, Max: 4194304},
+		}
+		if !reflect.DeepEqual(health, expected) {
+			t.Errorf("expected system health %+v, got %+v", expected, health)
+		}
+	})
+}
+
+func TestGetSystemHealthMissingFiles(t *testing.T) {
+	withProcRoot(t, "testdata/syshealth-partial", func() {
+		health, err := GetSystemHealth()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if health.EntropyAvailable == nil || *health.EntropyAvailable != 256 {
+			t.Errorf("expected entropy of 256, got %v", health.EntropyAvailable)
+		}
+		if health.FileHandles != nil {
+			t.Errorf("expected no file handles without file-nr, got %+v", health.FileHandles)
+		}
+		if health.Conntrack != nil {
+			t.Errorf("expected no conntrack without nf_conntrack_count, got %+v", health.Conntrack)
+		}
+		if health.Pids != nil {
+			t.Errorf("expected no pids without pid_max, got %+v", health.Pids)
+		}
+	})
+}
+
+func TestGetSystemHealthMalformed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "procfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.MkdirAll(path.Join(dir, "sys/kernel/random"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(dir, entropyAvailFile), []byte("abc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	withProcRoot(t, dir, func() {
+		if _, err := GetSystemHealth(); err == nil {
+			t.Errorf("expected error for malformed entropy_avail")
+		}
+	})
+}
+
+func TestParseFileNr(t *testing.T) {
+	usage, err := parseFileNr("1024\t24\t8192\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &info.KernelTableUsage{Used: 1000, Max: 8192}
+	if !reflect.DeepEqual(usage, expected) {
+		t.Errorf("expected file handles %+v, got %+v", expected, usage)
+	}
+
+	if _, err := parseFileNr("1024 8192\n"); err == nil {
+		t.Errorf("expected error parsing file-nr with missing fields")
+	}
+}
+
+func TestParseLoadavgTasks(t *testing.T) {
+	tasks, err := parseLoadavgTasks("0.20 0.18 0.12 1/80 11206\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tasks != 80 {
+		t.Errorf("expected 80 tasks, got %d", tasks)
+	}
+
+	if _, err := parseLoadavgTasks("0.20 0.18 0.12 80 11206\n"); err == nil {
+		t.Errorf("expected error parsing malformed loadavg")
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Usage of the filesystem project quotas (e.g.: XFS with prjquota) which
+// some storage drivers use to limit the size of a container's writable
+// layer. Reading the quota is a single syscall, unlike walking the
+// directory with du.
+package quota
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Returned when the directory is not part of a project, or the project has
+// no quota on its filesystem.
+var ErrNoQuota = errors.New("no project quota")
+
+type Usage struct {
+	// Bytes used by the project.
+	Used uint64
+
+	// Hard limit of the project in bytes, 0 if unlimited.
+	Limit uint64
+}
+
+// The syscalls used to read the quotas, replaced in tests.
+var (
+	// Returns the ID of the project the directory belongs to, 0 if none.
+	getProjectId = projectId
+
+	// Returns the quota of the project on the block device.
+	getProjectQuota = projectQuota
+)
+
+// Returns the usage of the project quota of dir, which is on device (e.g.:
+// "/dev/sda1"). Returns ErrNoQuota if dir is not under a project quota.
+func GetUsage(device, dir string) (Usage, error) {
+	id, err := getProjectId(dir)
+	if err != nil {
+		return Usage{}, fmt.Errorf("failed to get the project of %q: %v", dir, err)
+	}
+	if id == 0 {
+		return Usage{}, ErrNoQuota
+	}
+	usage, err := getProjectQuota(device, id)
+	if err == ErrNoQuota {
+		return Usage{}, err
+	}
+	if err != nil {
+		return Usage{}, fmt.Errorf("failed to get the quota of project %d on %q: %v", id, device, err)
+	}
+	return usage, nil
+}
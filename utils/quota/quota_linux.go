@@ -0,0 +1,109 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build linux
+
+package quota
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// From <linux/fs.h>.
+const fsIocFsGetXattr = 0x801c581f
+
+// From <linux/quota.h> and <linux/dqblk_xfs.h>.
+const (
+	prjQuota    = 2
+	qXGetQuota  = 'X'<<8 + 3
+	basicBlock  = 512
+	subCmdShift = 8
+)
+
+// struct fsxattr from <linux/fs.h>.
+type fsxattr struct {
+	xflags     uint32
+	extsize    uint32
+	nextents   uint32
+	projid     uint32
+	cowextsize uint32
+	pad        [8]byte
+}
+
+// struct fs_disk_quota from <linux/dqblk_xfs.h>.
+type fsDiskQuota struct {
+	version      int8
+	flags        int8
+	fieldmask    uint16
+	id           uint32
+	blkHardlimit uint64
+	blkSoftlimit uint64
+	inoHardlimit uint64
+	inoSoftlimit uint64
+	bcount       uint64
+	icount       uint64
+	itimer       int32
+	btimer       int32
+	iwarns       uint16
+	bwarns       uint16
+	padding2     int32
+	rtbHardlimit uint64
+	rtbSoftlimit uint64
+	rtbcount     uint64
+	rtbtimer     int32
+	rtbwarns     uint16
+	padding3     int16
+	padding4     [8]byte
+}
+
+func projectId(dir string) (uint32, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var attr fsxattr
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), fsIocFsGetXattr, uintptr(unsafe.Pointer(&attr)))
+	if errno != 0 {
+		// Filesystems without project support do not implement the ioctl.
+		if errno == syscall.ENOTTY || errno == syscall.EOPNOTSUPP || errno == syscall.EINVAL {
+			return 0, nil
+		}
+		return 0, &os.PathError{Op: "ioctl", Path: dir, Err: errno}
+	}
+	return attr.projid, nil
+}
+
+func projectQuota(device string, id uint32) (Usage, error) {
+	devicePtr, err := syscall.BytePtrFromString(device)
+	if err != nil {
+		return Usage{}, err
+	}
+	var quota fsDiskQuota
+	_, _, errno := syscall.Syscall6(syscall.SYS_QUOTACTL, qXGetQuota<<subCmdShift|prjQuota, uintptr(unsafe.Pointer(devicePtr)), uintptr(id), uintptr(unsafe.Pointer(&quota)), 0, 0)
+	if errno != 0 {
+		// Quotas are not enabled on the filesystem, or the project has none.
+		if errno == syscall.ENOSYS || errno == syscall.ESRCH || errno == syscall.ENOENT || errno == syscall.ENOTTY {
+			return Usage{}, ErrNoQuota
+		}
+		return Usage{}, &os.SyscallError{Syscall: "quotactl", Err: errno}
+	}
+	return Usage{
+		Used:  quota.bcount * basicBlock,
+		Limit: quota.blkHardlimit * basicBlock,
+	}, nil
+}
@@ -0,0 +1,32 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build linux
+
+package quota
+
+import (
+	"testing"
+	"unsafe"
+)
+
+// The structs are passed to the kernel, their sizes must match the C ones.
+func TestStructSizes(t *testing.T) {
+	if size := unsafe.Sizeof(fsxattr{}); size != 28 {
+		t.Errorf("struct fsxattr is 28 bytes, got %d", size)
+	}
+	if size := unsafe.Sizeof(fsDiskQuota{}); size != 112 {
+		t.Errorf("struct fs_disk_quota is 112 bytes, got %d", size)
+	}
+}
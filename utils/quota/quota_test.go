@@ -0,0 +1,85 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quota
+
+import (
+	"errors"
+	"testing"
+)
+
+// Replaces the syscalls with ones returning the given project and quota.
+func fakeSyscalls(t *testing.T, id uint32, idErr error, usage Usage, quotaErr error) func() {
+	oldProjectId, oldProjectQuota := getProjectId, getProjectQuota
+	getProjectId = func(dir string) (uint32, error) {
+		return id, idErr
+	}
+	getProjectQuota = func(device string, projectId uint32) (Usage, error) {
+		if projectId != id {
+			t.Errorf("quota read for project %d, expected %d", projectId, id)
+		}
+		return usage, quotaErr
+	}
+	return func() {
+		getProjectId, getProjectQuota = oldProjectId, oldProjectQuota
+	}
+}
+
+func TestGetUsage(t *testing.T) {
+	expected := Usage{Used: 1 << 20, Limit: 10 << 30}
+	defer fakeSyscalls(t, 42, nil, expected, nil)()
+
+	usage, err := GetUsage("/dev/sda1", "/var/lib/docker/overlay/abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage != expected {
+		t.Errorf("expected usage %+v, got %+v", expected, usage)
+	}
+}
+
+func TestGetUsageNoProject(t *testing.T) {
+	defer fakeSyscalls(t, 0, nil, Usage{}, errors.New("quota must not be read"))()
+
+	_, err := GetUsage("/dev/sda1", "/var/lib/docker/aufs/diff/abc")
+	if err != ErrNoQuota {
+		t.Errorf("expected ErrNoQuota, got %v", err)
+	}
+}
+
+func TestGetUsageQuotasDisabled(t *testing.T) {
+	defer fakeSyscalls(t, 42, nil, Usage{}, ErrNoQuota)()
+
+	_, err := GetUsage("/dev/sda1", "/var/lib/docker/overlay/abc")
+	if err != ErrNoQuota {
+		t.Errorf("expected ErrNoQuota, got %v", err)
+	}
+}
+
+func TestGetUsageErrors(t *testing.T) {
+	func() {
+		defer fakeSyscalls(t, 0, errors.New("permission denied"), Usage{}, nil)()
+		_, err := GetUsage("/dev/sda1", "/var/lib/docker/overlay/abc")
+		if err == nil || err == ErrNoQuota {
+			t.Errorf("expected a failure to get the project, got %v", err)
+		}
+	}()
+	func() {
+		defer fakeSyscalls(t, 42, nil, Usage{}, errors.New("input/output error"))()
+		_, err := GetUsage("/dev/sda1", "/var/lib/docker/overlay/abc")
+		if err == nil || err == ErrNoQuota {
+			t.Errorf("expected a failure to get the quota, got %v", err)
+		}
+	}()
+}
@@ -0,0 +1,235 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package selfcheck cross-checks the stats collected for a container against
+// its cgroup files, read through a minimal reader independent of the
+// collection pipeline. A collected value outside of those read right before
+// and right after its collection points at a unit conversion or field mapping
+// bug in the pipeline.
+package selfcheck
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Fields cross-checked.
+const (
+	CpuTotal                     = "cpu.usage.total"
+	CpuUser                      = "cpu.usage.user"
+	CpuSystem                    = "cpu.usage.system"
+	MemoryUsage                  = "memory.usage"
+	MemoryPgfault                = "memory.container_data.pgfault"
+	MemoryPgmajfault             = "memory.container_data.pgmajfault"
+	MemoryHierarchicalPgfault    = "memory.hierarchical_data.pgfault"
+	MemoryHierarchicalPgmajfault = "memory.hierarchical_data.pgmajfault"
+)
+
+// USER_HZ, the unit of the times of cpuacct.stat, is 100 on all the
+// architectures Linux supports.
+const userHz = 100
+
+// Values of the fields, by name (e.g.: MemoryUsage).
+type Values map[string]uint64
+
+// Reads the values of the fields from the cgroup files of a container.
+// cgroupPath returns the directory of the container in the hierarchy of a
+// subsystem. Fields whose subsystem or file is missing are left out.
+func Read(cgroupPath func(subsystem string) (string, error)) (Values, error) {
+	values := make(Values)
+	if dir, err := cgroupPath("cpuacct"); err == nil {
+		if err := readCpuacct(dir, values); err != nil {
+			return nil, err
+		}
+	}
+	if dir, err := cgroupPath("memory"); err == nil {
+		if err := readMemory(dir, values); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+func readCpuacct(dir string, values Values) error {
+	usage, err := readUint(path.Join(dir, "cpuacct.usage"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	values[CpuTotal] = usage
+	stat, err := readStatFile(path.Join(dir, "cpuacct.stat"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	const nanosecondsPerTick = uint64(time.Second) / userHz
+	if user, ok := stat["user"]; ok {
+		values[CpuUser] = user * nanosecondsPerTick
+	}
+	if system, ok := stat["system"]; ok {
+		values[CpuSystem] = system * nanosecondsPerTick
+	}
+	return nil
+}
+
+func readMemory(dir string, values Values) error {
+	usage, err := readUint(path.Join(dir, "memory.usage_in_bytes"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	values[MemoryUsage] = usage
+	stat, err := readStatFile(path.Join(dir, "memory.stat"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for field, key := range map[string]string{
+		MemoryPgfault:                "pgfault",
+		MemoryPgmajfault:             "pgmajfault",
+		MemoryHierarchicalPgfault:    "total_pgfault",
+		MemoryHierarchicalPgmajfault: "total_pgmajfault",
+	} {
+		if v, ok := stat[key]; ok {
+			values[field] = v
+		}
+	}
+	return nil
+}
+
+func readUint(file string) (uint64, error) {
+	out, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %q: %v", file, err)
+	}
+	return v, nil
+}
+
+// Reads a file of "<key> <value>" lines, e.g.: memory.stat.
+func readStatFile(file string) (map[string]uint64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	stat := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %q of %q: %v", fields[0], file, err)
+		}
+		stat[fields[0]] = v
+	}
+	return stat, scanner.Err()
+}
+
+// Returns the values of the fields in the stats collected by the pipeline.
+// The fields of sections missing from the stats are left out.
+func FromStats(stats *info.ContainerStats) Values {
+	values := make(Values)
+	if !stats.Missing(info.StatsSectionCpu) {
+		values[CpuTotal] = stats.Cpu.Usage.Total
+		values[CpuUser] = stats.Cpu.Usage.User
+		values[CpuSystem] = stats.Cpu.Usage.System
+	}
+	if !stats.Missing(info.StatsSectionMemory) {
+		values[MemoryUsage] = stats.Memory.Usage
+		values[MemoryPgfault] = stats.Memory.ContainerData.Pgfault
+		values[MemoryPgmajfault] = stats.Memory.ContainerData.Pgmajfault
+		values[MemoryHierarchicalPgfault] = stats.Memory.HierarchicalData.Pgfault
+		values[MemoryHierarchicalPgmajfault] = stats.Memory.HierarchicalData.Pgmajfault
+	}
+	return values
+}
+
+// A collected value outside of the values read around its collection.
+type Discrepancy struct {
+	Field string
+
+	// Collected value.
+	Value uint64
+
+	// Values read before and after the collection, in increasing order.
+	Min uint64
+	Max uint64
+}
+
+func (self Discrepancy) String() string {
+	return fmt.Sprintf("%s of %d not within [%d, %d]", self.Field, self.Value, self.Min, self.Max)
+}
+
+// Compares the values collected with those read before and after their
+// collection. A collected value may be outside of the values read by the
+// tolerance, relative to them, e.g.: 0.01 for 1%, to allow for gauges which
+// move during the collection. Only the fields of all three are compared.
+// Returns the discrepancies ordered by field.
+func Compare(before, after, collected Values, tolerance float64) []Discrepancy {
+	var discrepancies []Discrepancy
+	for field, value := range collected {
+		first, ok := before[field]
+		if !ok {
+			continue
+		}
+		last, ok := after[field]
+		if !ok {
+			continue
+		}
+		min, max := first, last
+		if min > max {
+			min, max = max, min
+		}
+		if float64(value) < float64(min)*(1-tolerance) || float64(value) > float64(max)*(1+tolerance) {
+			discrepancies = append(discrepancies, Discrepancy{
+				Field: field,
+				Value: value,
+				Min:   min,
+				Max:   max,
+			})
+		}
+	}
+	sort.Sort(byField(discrepancies))
+	return discrepancies
+}
+
+type byField []Discrepancy
+
+func (s byField) Len() int           { return len(s) }
+func (s byField) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byField) Less(i, j int) bool { return s[i].Field < s[j].Field }
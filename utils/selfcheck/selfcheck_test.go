@@ -0,0 +1,117 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package selfcheck
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Writes the files of a cgroup in a temporary directory per subsystem.
+func writeCgroup(t *testing.T, files map[string]string) (string, func(string) (string, error)) {
+	root, err := ioutil.TempDir("", "selfcheck")
+	require.Nil(t, err)
+	for name, contents := range files {
+		subsystem := name[:len(name)-len(path.Ext(name))]
+		dir := path.Join(root, subsystem)
+		require.Nil(t, os.MkdirAll(dir, 0755))
+		require.Nil(t, ioutil.WriteFile(path.Join(dir, name), []byte(contents), 0644))
+	}
+	return root, func(subsystem string) (string, error) {
+		dir := path.Join(root, subsystem)
+		if _, err := os.Stat(dir); err != nil {
+			return "", fmt.Errorf("no %s hierarchy", subsystem)
+		}
+		return dir, nil
+	}
+}
+
+func TestRead(t *testing.T) {
+	root, cgroupPath := writeCgroup(t, map[string]string{
+		"cpuacct.usage":         "2500000000\n",
+		"cpuacct.stat":          "user 150\nsystem 50\n",
+		"memory.usage_in_bytes": "1048576\n",
+		"memory.stat":           "cache 4096\npgfault 10\npgmajfault 1\ntotal_pgfault 30\ntotal_pgmajfault 3\n",
+	})
+	defer os.RemoveAll(root)
+
+	values, err := Read(cgroupPath)
+	require.Nil(t, err)
+	assert.Equal(t, Values{
+		CpuTotal:                     2500000000,
+		CpuUser:                      1500000000,
+		CpuSystem:                    500000000,
+		MemoryUsage:                  1048576,
+		MemoryPgfault:                10,
+		MemoryPgmajfault:             1,
+		MemoryHierarchicalPgfault:    30,
+		MemoryHierarchicalPgmajfault: 3,
+	}, values)
+}
+
+func TestReadWithoutSubsystem(t *testing.T) {
+	root, cgroupPath := writeCgroup(t, map[string]string{
+		"memory.usage_in_bytes": "1048576\n",
+	})
+	defer os.RemoveAll(root)
+
+	values, err := Read(cgroupPath)
+	require.Nil(t, err)
+	assert.Equal(t, Values{MemoryUsage: 1048576}, values)
+}
+
+func TestReadInvalidFile(t *testing.T) {
+	root, cgroupPath := writeCgroup(t, map[string]string{
+		"cpuacct.usage": "lots\n",
+	})
+	defer os.RemoveAll(root)
+
+	_, err := Read(cgroupPath)
+	assert.NotNil(t, err)
+}
+
+func TestFromStatsSkipsMissingSections(t *testing.T) {
+	stats := &info.ContainerStats{PartialFailure: []string{info.StatsSectionMemory}}
+	stats.Cpu.Usage.Total = 100
+	values := FromStats(stats)
+	assert.Equal(t, Values{CpuTotal: 100, CpuUser: 0, CpuSystem: 0}, values)
+}
+
+func TestCompare(t *testing.T) {
+	before := Values{CpuTotal: 1000, MemoryUsage: 2000, MemoryHierarchicalPgfault: 30}
+	after := Values{CpuTotal: 1100, MemoryUsage: 1900, MemoryHierarchicalPgfault: 32}
+
+	// Within the window, or outside of it by less than the tolerance.
+	collected := Values{CpuTotal: 1050, MemoryUsage: 2010, MemoryHierarchicalPgfault: 31, MemoryPgfault: 5}
+	assert.Equal(t, 0, len(Compare(before, after, collected, 0.01)))
+
+	// The local counter reported as the hierarchical one.
+	collected = Values{CpuTotal: 1100, MemoryUsage: 1900, MemoryHierarchicalPgfault: 10}
+	assert.Equal(t, []Discrepancy{{Field: MemoryHierarchicalPgfault, Value: 10, Min: 30, Max: 32}}, Compare(before, after, collected, 0.01))
+
+	// Ticks not converted to nanoseconds.
+	collected = Values{CpuTotal: 11, MemoryUsage: 2100}
+	assert.Equal(t, []Discrepancy{
+		{Field: CpuTotal, Value: 11, Min: 1000, Max: 1100},
+		{Field: MemoryUsage, Value: 2100, Min: 1900, Max: 2000},
+	}, Compare(before, after, collected, 0.01))
+}
@@ -0,0 +1,98 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sem
+
+import (
+	"flag"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var maxConcurrentExpensiveOps = flag.Int("max_concurrent_expensive_ops", 4, "Largest total weight of the expensive collectors (e.g.: du on the filesystems of Docker containers, weighing 2, and CPU sampling of processes, weighing 1) running at once across all containers. Zero is no limit")
+var expensiveOpsWaitDeadline = flag.Duration("expensive_ops_wait_deadline", 2*time.Second, "Longest time an expensive collector waits for its turn under --max_concurrent_expensive_ops. It is then skipped and its last data is reported as stale")
+
+// Weights of the expensive collectors.
+const (
+	// du walks the whole filesystem of the container.
+	DuWeight int64 = 2
+
+	// CPU sampling reads a few files of each process of the container.
+	CpuSamplingWeight int64 = 1
+)
+
+var (
+	expensiveOnce sync.Once
+	expensive     *Weighted
+)
+
+// Returns the semaphore shared by the expensive collectors, sized by
+// --max_concurrent_expensive_ops.
+func Expensive() *Weighted {
+	expensiveOnce.Do(func() {
+		expensive = NewWeighted(int64(*maxConcurrentExpensiveOps))
+	})
+	return expensive
+}
+
+// Acquires the weight of the collector (e.g.: "du") from the semaphore shared
+// by the expensive collectors, waiting at most --expensive_ops_wait_deadline.
+// Returns the function releasing it, or a *DeadlineError if the collector
+// must be skipped.
+func AcquireExpensive(collector string, weight int64) (func(), error) {
+	s := Expensive()
+	start := time.Now()
+	err := s.Acquire(weight, *expensiveOpsWaitDeadline)
+	waitSeconds.WithLabelValues(collector).Observe(time.Since(start).Seconds())
+	if err != nil {
+		skippedTotal.WithLabelValues(collector).Inc()
+		glog.V(3).Infof("Skipping the %s collector: %v", collector, err)
+		return nil, err
+	}
+	return func() {
+		s.Release(weight)
+	}, nil
+}
+
+var (
+	waitSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "cadvisor",
+		Subsystem: "expensive_ops",
+		Name:      "wait_seconds",
+		Help:      "Time the expensive collectors waited for their turn under --max_concurrent_expensive_ops, by collector.",
+		Buckets:   []float64{0.001, 0.01, 0.1, 0.5, 1, 2, 5, 10},
+	}, []string{"collector"})
+	skippedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cadvisor",
+		Subsystem: "expensive_ops",
+		Name:      "skipped_total",
+		Help:      "Number of runs of the expensive collectors skipped because they waited past --expensive_ops_wait_deadline, by collector.",
+	}, []string{"collector"})
+	weightInUse = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "cadvisor",
+		Subsystem: "expensive_ops",
+		Name:      "weight_in_use",
+		Help:      "Total weight of the expensive collectors currently running.",
+	}, func() float64 {
+		return float64(Expensive().Used())
+	})
+)
+
+// The Prometheus metrics of the contention of the expensive collectors.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{waitSeconds, skippedTotal, weightInUse}
+}
@@ -0,0 +1,167 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Weighted semaphores bounding the concurrency of the expensive collectors
+// (e.g.: du) across all the containers, so that together they do not
+// saturate the machine.
+package sem
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Error returned when a weight could not be acquired before the deadline.
+type DeadlineError struct {
+	Weight   int64
+	Deadline time.Duration
+}
+
+func (self *DeadlineError) Error() string {
+	return fmt.Sprintf("could not acquire a weight of %d within %v", self.Weight, self.Deadline)
+}
+
+// A semaphore of a total weight shared by its holders. Waiters are granted
+// their weight in the order they arrived: a heavy waiter is not starved by
+// lighter ones arriving after it, which wait behind it.
+type Weighted struct {
+	size int64
+
+	lock sync.Mutex
+	// Weight currently held.
+	used int64
+	// Waiters, oldest first.
+	waiters list.List
+}
+
+type waiter struct {
+	weight int64
+	// Closed once the weight is granted.
+	ready chan struct{}
+}
+
+// Returns a semaphore of the specified total weight. A size of zero or less
+// does not bound the weight held.
+func NewWeighted(size int64) *Weighted {
+	return &Weighted{
+		size: size,
+	}
+}
+
+// Total weight of the semaphore, zero or less if unbounded.
+func (self *Weighted) Size() int64 {
+	return self.size
+}
+
+// Weight currently held.
+func (self *Weighted) Used() int64 {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.used
+}
+
+// Weights larger than the semaphore are held alone.
+func (self *Weighted) clamp(weight int64) int64 {
+	if weight > self.size {
+		return self.size
+	}
+	return weight
+}
+
+// Acquires the weight, waiting at most until the deadline for earlier
+// holders to release theirs. Returns a *DeadlineError if it could not, in
+// which case nothing is held. A deadline of zero or less does not wait.
+func (self *Weighted) Acquire(weight int64, deadline time.Duration) error {
+	if self.size <= 0 {
+		return nil
+	}
+	weight = self.clamp(weight)
+
+	self.lock.Lock()
+	if self.waiters.Len() == 0 && self.used+weight <= self.size {
+		self.used += weight
+		self.lock.Unlock()
+		return nil
+	}
+	if deadline <= 0 {
+		self.lock.Unlock()
+		return &DeadlineError{weight, deadline}
+	}
+	w := &waiter{
+		weight: weight,
+		ready:  make(chan struct{}),
+	}
+	elem := self.waiters.PushBack(w)
+	self.lock.Unlock()
+
+	timer := time.NewTimer(deadline)
+	defer timer.Stop()
+	select {
+	case <-w.ready:
+		return nil
+	case <-timer.C:
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	select {
+	case <-w.ready:
+		// Granted while timing out.
+		return nil
+	default:
+	}
+	isFirst := self.waiters.Front() == elem
+	self.waiters.Remove(elem)
+	if isFirst {
+		// Those behind may fit now.
+		self.grant()
+	}
+	return &DeadlineError{weight, deadline}
+}
+
+// Releases the weight, which must have been acquired.
+func (self *Weighted) Release(weight int64) {
+	if self.size <= 0 {
+		return
+	}
+	weight = self.clamp(weight)
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.used -= weight
+	if self.used < 0 {
+		panic("sem: released more than held")
+	}
+	self.grant()
+}
+
+// Grants their weight to the oldest waiters while it is available. Must be
+// called with the lock held.
+func (self *Weighted) grant() {
+	for {
+		front := self.waiters.Front()
+		if front == nil {
+			return
+		}
+		w := front.Value.(*waiter)
+		if self.used+w.weight > self.size {
+			return
+		}
+		self.used += w.weight
+		self.waiters.Remove(front)
+		close(w.ready)
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sem
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Waits until the semaphore has the specified number of waiters.
+func waitForWaiters(t *testing.T, s *Weighted, n int) {
+	for i := 0; i < 1000; i++ {
+		s.lock.Lock()
+		waiting := s.waiters.Len()
+		s.lock.Unlock()
+		if waiting == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected %d waiters", n)
+}
+
+func TestAcquireRelease(t *testing.T) {
+	s := NewWeighted(4)
+	require.Nil(t, s.Acquire(3, 0))
+	assert.Equal(t, int64(3), s.Used())
+	require.Nil(t, s.Acquire(1, 0))
+
+	// Does not fit and does not wait.
+	err := s.Acquire(1, 0)
+	require.NotNil(t, err)
+	_, ok := err.(*DeadlineError)
+	assert.True(t, ok)
+
+	s.Release(3)
+	s.Release(1)
+	assert.Equal(t, int64(0), s.Used())
+}
+
+func TestWeightLargerThanSizeRunsAlone(t *testing.T) {
+	s := NewWeighted(2)
+	require.Nil(t, s.Acquire(5, 0))
+	assert.Equal(t, int64(2), s.Used())
+	assert.NotNil(t, s.Acquire(1, 0))
+	s.Release(5)
+	assert.Equal(t, int64(0), s.Used())
+}
+
+func TestUnbounded(t *testing.T) {
+	s := NewWeighted(0)
+	for i := 0; i < 100; i++ {
+		require.Nil(t, s.Acquire(10, 0))
+	}
+	s.Release(10)
+}
+
+func TestDeadline(t *testing.T) {
+	s := NewWeighted(1)
+	require.Nil(t, s.Acquire(1, 0))
+
+	start := time.Now()
+	err := s.Acquire(1, 20*time.Millisecond)
+	require.NotNil(t, err)
+	assert.True(t, time.Since(start) >= 20*time.Millisecond)
+	assert.Equal(t, &DeadlineError{Weight: 1, Deadline: 20 * time.Millisecond}, err)
+
+	// The waiter which gave up holds nothing.
+	s.Release(1)
+	assert.Equal(t, int64(0), s.Used())
+	require.Nil(t, s.Acquire(1, 0))
+}
+
+func TestGrantedWithinDeadline(t *testing.T) {
+	s := NewWeighted(1)
+	require.Nil(t, s.Acquire(1, 0))
+
+	done := make(chan error)
+	go func() {
+		done <- s.Acquire(1, 10*time.Second)
+	}()
+	waitForWaiters(t, s, 1)
+	s.Release(1)
+	assert.Nil(t, <-done)
+	assert.Equal(t, int64(1), s.Used())
+}
+
+// A heavy collector is not starved by light ones arriving after it.
+func TestFifoFairness(t *testing.T) {
+	s := NewWeighted(2)
+	require.Nil(t, s.Acquire(1, 0))
+
+	var (
+		lock  sync.Mutex
+		order []string
+		wg    sync.WaitGroup
+	)
+	collector := func(name string, weight int64) {
+		defer wg.Done()
+		if !assert.Nil(t, s.Acquire(weight, 10*time.Second)) {
+			return
+		}
+		lock.Lock()
+		order = append(order, name)
+		lock.Unlock()
+		s.Release(weight)
+	}
+
+	wg.Add(1)
+	go collector("du", 2)
+	waitForWaiters(t, s, 1)
+
+	// Although one unit is free, light collectors queue behind the heavy one.
+	wg.Add(2)
+	go collector("cpu_sampling-1", 1)
+	waitForWaiters(t, s, 2)
+	go collector("cpu_sampling-2", 1)
+	waitForWaiters(t, s, 3)
+	assert.Equal(t, int64(1), s.Used())
+
+	s.Release(1)
+	wg.Wait()
+	require.Equal(t, 3, len(order))
+	assert.Equal(t, "du", order[0])
+	assert.Equal(t, int64(0), s.Used())
+}
+
+// Those behind a waiter which gave up are granted their weight if it fits.
+func TestGiveUpUnblocksOthers(t *testing.T) {
+	s := NewWeighted(2)
+	require.Nil(t, s.Acquire(1, 0))
+
+	heavy := make(chan error)
+	go func() {
+		heavy <- s.Acquire(2, 20*time.Millisecond)
+	}()
+	waitForWaiters(t, s, 1)
+	light := make(chan error)
+	go func() {
+		light <- s.Acquire(1, 10*time.Second)
+	}()
+	waitForWaiters(t, s, 2)
+
+	assert.NotNil(t, <-heavy)
+	assert.Nil(t, <-light)
+	assert.Equal(t, int64(2), s.Used())
+}
+
+// Synthetic collectors never run more than the size at once, and are skipped
+// rather than waiting past the deadline.
+func TestBoundsConcurrency(t *testing.T) {
+	s := NewWeighted(3)
+	var (
+		lock             sync.Mutex
+		running, peak    int64
+		granted, skipped int
+		wg               sync.WaitGroup
+	)
+	for i := 0; i < 30; i++ {
+		weight := int64(1 + i%2)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.Acquire(weight, 5*time.Millisecond); err != nil {
+				lock.Lock()
+				skipped++
+				lock.Unlock()
+				return
+			}
+			lock.Lock()
+			granted++
+			running += weight
+			if running > peak {
+				peak = running
+			}
+			lock.Unlock()
+			time.Sleep(2 * time.Millisecond)
+			lock.Lock()
+			running -= weight
+			lock.Unlock()
+			s.Release(weight)
+		}()
+	}
+	wg.Wait()
+	assert.True(t, peak <= 3, "peak weight %d", peak)
+	assert.Equal(t, 30, granted+skipped)
+	assert.True(t, granted > 0)
+	assert.Equal(t, int64(0), s.Used())
+}
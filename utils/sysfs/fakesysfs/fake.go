@@ -51,8 +51,9 @@ func (self *FileInfo) Sys() interface{} {
 }
 
 type FakeSysFs struct {
-	info  FileInfo
-	cache sysfs.CacheInfo
+	info       FileInfo
+	cache      sysfs.CacheInfo
+	onlineCpus string
 }
 
 func (self *FakeSysFs) GetBlockDevices() ([]os.FileInfo, error) {
@@ -112,3 +113,11 @@ func (self *FakeSysFs) SetEntryName(name string) {
 func (self *FakeSysFs) GetSystemUUID() (string, error) {
 	return "1F862619-BA9F-4526-8F85-ECEAF0C97430", nil
 }
+
+func (self *FakeSysFs) GetOnlineCpus() (string, error) {
+	return self.onlineCpus, nil
+}
+
+func (self *FakeSysFs) SetOnlineCpus(online string) {
+	self.onlineCpus = online
+}
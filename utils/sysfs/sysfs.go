@@ -26,6 +26,7 @@ import (
 const (
 	blockDir = "/sys/block"
 	cacheDir = "/sys/devices/system/cpu/cpu"
+	cpuDir   = "/sys/devices/system/cpu"
 	netDir   = "/sys/class/net"
 	dmiDir   = "/sys/class/dmi"
 )
@@ -64,6 +65,9 @@ type SysFs interface {
 	GetCacheInfo(cpu int, cache string) (CacheInfo, error)
 
 	GetSystemUUID() (string, error)
+
+	// Get the list of the online cpus, e.g.: "0-3,6".
+	GetOnlineCpus() (string, error)
 }
 
 type realSysFs struct{}
@@ -239,3 +243,11 @@ func (self *realSysFs) GetSystemUUID() (string, error) {
 	}
 	return strings.TrimSpace(string(id)), nil
 }
+
+func (self *realSysFs) GetOnlineCpus() (string, error) {
+	online, err := ioutil.ReadFile(path.Join(cpuDir, "online"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(online)), nil
+}
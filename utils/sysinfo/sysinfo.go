@@ -17,6 +17,7 @@ package sysinfo
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -208,3 +209,17 @@ func getNetworkStats(name string, sysFs sysfs.SysFs) (info.NetworkStats, error)
 func GetSystemUUID(sysFs sysfs.SysFs) (string, error) {
 	return sysFs.GetSystemUUID()
 }
+
+// Returns the sorted ids of the online cpus.
+func GetOnlineCpus(sysFs sysfs.SysFs) ([]int, error) {
+	online, err := sysFs.GetOnlineCpus()
+	if err != nil {
+		return nil, err
+	}
+	cpus, err := info.ParseCpuList(online)
+	if err != nil {
+		return nil, err
+	}
+	sort.Ints(cpus)
+	return cpus, nil
+}
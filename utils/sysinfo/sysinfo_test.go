@@ -15,6 +15,7 @@
 package sysinfo
 
 import (
+	"reflect"
 	"testing"
 
 	info "github.com/google/cadvisor/info/v1"
@@ -125,7 +126,20 @@ func TestGetNetworkStats(t *testing.T) {
 	if err != nil {
 		t.Errorf("call to getNetworkStats() failed with %s", err)
 	}
-	if expected_stats != netStats {
+	if !reflect.DeepEqual(expected_stats, netStats) {
 		t.Errorf("expected to get stats %+v, got %+v", expected_stats, netStats)
 	}
 }
+
+func TestGetOnlineCpus(t *testing.T) {
+	fakeSys := &fakesysfs.FakeSysFs{}
+	fakeSys.SetOnlineCpus("4-5,0-1")
+	cpus, err := GetOnlineCpus(fakeSys)
+	if err != nil {
+		t.Errorf("call to GetOnlineCpus() failed with %s", err)
+	}
+	expected := []int{0, 1, 4, 5}
+	if !reflect.DeepEqual(expected, cpus) {
+		t.Errorf("expected online cpus %v, got %v", expected, cpus)
+	}
+}
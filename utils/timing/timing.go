@@ -0,0 +1,71 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Utilities for timing the sections of an operation.
+package timing
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A named section of an operation and how long it took.
+type Section struct {
+	Name     string
+	Duration time.Duration
+}
+
+// Records how long each section of an operation takes, in the order the
+// sections end. Not thread-safe.
+type Sections struct {
+	sections []Section
+}
+
+func NewSections() *Sections {
+	return &Sections{}
+}
+
+// Starts timing the named section. The returned function ends it.
+//
+// e.g.:
+// defer sections.Time("cgroup read")()
+func (self *Sections) Time(name string) func() {
+	start := time.Now()
+	return func() {
+		self.Add(name, time.Since(start))
+	}
+}
+
+// Records a section timed elsewhere.
+func (self *Sections) Add(name string, duration time.Duration) {
+	self.sections = append(self.sections, Section{
+		Name:     name,
+		Duration: duration,
+	})
+}
+
+// Returns the sections recorded so far.
+func (self *Sections) Sections() []Section {
+	return self.sections
+}
+
+// Returns the sections as: "cgroup read: 3ms, storage write: 1ms".
+func (self *Sections) String() string {
+	parts := make([]string, 0, len(self.sections))
+	for _, section := range self.sections {
+		parts = append(parts, fmt.Sprintf("%s: %v", section.Name, section.Duration))
+	}
+	return strings.Join(parts, ", ")
+}
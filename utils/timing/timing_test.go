@@ -0,0 +1,49 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSections(t *testing.T) {
+	sections := NewSections()
+	end := sections.Time("slow")
+	time.Sleep(10 * time.Millisecond)
+	end()
+	sections.Add("fixed", time.Second)
+
+	recorded := sections.Sections()
+	if len(recorded) != 2 {
+		t.Fatalf("expected 2 sections, found %+v", recorded)
+	}
+	if recorded[0].Name != "slow" || recorded[0].Duration < 10*time.Millisecond {
+		t.Errorf("expected the slow section to take at least 10ms, found %+v", recorded[0])
+	}
+	if recorded[1].Name != "fixed" || recorded[1].Duration != time.Second {
+		t.Errorf("expected the fixed section to take 1s, found %+v", recorded[1])
+	}
+}
+
+func TestSectionsString(t *testing.T) {
+	sections := NewSections()
+	sections.Add("cgroup read", 3*time.Millisecond)
+	sections.Add("storage write", time.Millisecond)
+	expected := "cgroup read: 3ms, storage write: 1ms"
+	if s := sections.String(); s != expected {
+		t.Errorf("expected %q, found %q", expected, s)
+	}
+}
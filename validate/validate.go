@@ -29,6 +29,9 @@ import (
 	"github.com/docker/libcontainer/cgroups"
 	dclient "github.com/fsouza/go-dockerclient"
 	"github.com/google/cadvisor/container/docker"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/google/cadvisor/storage"
 	"github.com/google/cadvisor/utils"
 )
 
@@ -197,6 +200,7 @@ func validateDockerInfo() (string, string) {
 			} else {
 				desc += "\tCgroups are being created through cgroup filesystem.\n"
 			}
+			desc += fmt.Sprintf("\tContainers are placed under cgroup %q unless started with --cgroup-parent.\n", docker.CgroupParent())
 			if strings.Contains(execDriver, "native") {
 				stateFile := docker.DockerStateDir()
 				if !utils.FileExists(stateFile) {
@@ -284,6 +288,73 @@ func validateIoScheduler(containerManager manager.Manager) (string, string) {
 	return Supported, desc
 }
 
+func validateStorageDrivers(statuses []storage.ConnectionStatus) (string, string) {
+	if len(statuses) == 0 {
+		return Recommended, "No storage driver is in use.\n"
+	}
+	validation := Recommended
+	lines := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		if status.Verified {
+			lines = append(lines, fmt.Sprintf("Connected to storage driver %q.", status.Name))
+			continue
+		}
+		validation = Unsupported
+		lines = append(lines, fmt.Sprintf("Storage driver %q is degraded after %d failed connection attempts, retrying at %v. %d stats were dropped. Last error: %s", status.Name, status.Attempts, status.NextRetry, status.DroppedStats, status.LastError))
+	}
+	return validation, strings.Join(lines, "\n\t") + "\n"
+}
+
+func validateClockOffsets(offsets []info.ClockOffset) (string, string) {
+	if len(offsets) == 0 {
+		return Unknown, "The clock is not compared with any source of time.\n"
+	}
+	validation := Recommended
+	lines := make([]string, 0, len(offsets))
+	for _, offset := range offsets {
+		if offset.Skewed {
+			validation = Unsupported
+		}
+		switch {
+		case offset.Error != "" && offset.Offset == 0:
+			lines = append(lines, fmt.Sprintf("Failed to compare the clock with %q at %v: %s", offset.Source, offset.Timestamp, offset.Error))
+		case offset.Timestamp.IsZero():
+			lines = append(lines, fmt.Sprintf("The clock was not compared with %q yet.", offset.Source))
+		case offset.Skewed:
+			lines = append(lines, fmt.Sprintf("The clock is skewed: %v off %q, stats written to shared backends may be out of order. Measured at %v.", offset.Offset, offset.Source, offset.Timestamp))
+		default:
+			lines = append(lines, fmt.Sprintf("The clock is %v off %q. Measured at %v.", offset.Offset, offset.Source, offset.Timestamp))
+		}
+	}
+	return validation, strings.Join(lines, "\n\t") + "\n"
+}
+
+func describeStorageFilters(statuses []storage.FilterStatus) string {
+	if len(statuses) == 0 {
+		return "Storage driver filters: None\n\n"
+	}
+	out := "Storage driver filters:\n"
+	for _, status := range statuses {
+		out += fmt.Sprintf("\t%s: only writing the stats of %s, %d stats filtered out\n", status.Name, status.Filter, status.Filtered)
+	}
+	return out + "\n"
+}
+
+func describeFailedContainers(failed []v2.FailedContainer) string {
+	if len(failed) == 0 {
+		return "Failed containers: None\n\n"
+	}
+	out := "Failed containers:\n"
+	for _, cont := range failed {
+		retry := "not retrying until detected again"
+		if !cont.NextRetry.IsZero() {
+			retry = fmt.Sprintf("retrying at %v", cont.NextRetry)
+		}
+		out += fmt.Sprintf("\t%s: %d failed attempts, %s. Last error: %s\n", cont.Name, cont.Attempts, retry, cont.LastError)
+	}
+	return out + "\n"
+}
+
 func HandleRequest(w http.ResponseWriter, containerManager manager.Manager) error {
 	// Get cAdvisor version Info.
 	versionInfo, err := containerManager.GetVersionInfo()
@@ -313,6 +384,23 @@ func HandleRequest(w http.ResponseWriter, containerManager manager.Manager) erro
 
 	ioSchedulerValidation, desc := validateIoScheduler(containerManager)
 	out += fmt.Sprintf(OutputFormat, "Block device setup", ioSchedulerValidation, desc)
+
+	storageValidation, desc := validateStorageDrivers(storage.ConnectionStatuses())
+	out += fmt.Sprintf(OutputFormat, "Storage driver connection", storageValidation, desc)
+	out += describeStorageFilters(storage.FilterStatuses())
+
+	machineInfo, err := containerManager.GetMachineInfo()
+	if err != nil {
+		return err
+	}
+	clockValidation, desc := validateClockOffsets(machineInfo.ClockOffsets)
+	out += fmt.Sprintf(OutputFormat, "Clock skew", clockValidation, desc)
+
+	failedContainers, err := containerManager.GetFailedContainers()
+	if err != nil {
+		return err
+	}
+	out += describeFailedContainers(failedContainers)
 	_, err = w.Write([]byte(out))
 	return err
 }